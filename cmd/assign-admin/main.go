@@ -3,6 +3,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -44,7 +45,17 @@ func main() {
 	userRoleRepo := repository.NewUserRoleRepository(database.GetDB())
 
 	// 初始化 Service
-	rbacService := service.NewRBACService(roleRepo, permRepo, userRoleRepo)
+	extraSystemPerms := make([]model.Permission, 0, len(cfg.RBAC.SystemPermissions))
+	for _, perm := range cfg.RBAC.SystemPermissions {
+		extraSystemPerms = append(extraSystemPerms, model.Permission{
+			Resource:    perm.Resource,
+			Action:      perm.Action,
+			Description: perm.Description,
+		})
+	}
+	rbacService := service.NewRBACService(roleRepo, permRepo, userRoleRepo, &service.RBACServiceConfig{
+		ExtraSystemPermissions: extraSystemPerms,
+	})
 
 	// 确保默认角色和权限已初始化
 	if err := rbacService.InitDefaultRolesAndPermissions(ctx); err != nil {
@@ -62,6 +73,10 @@ func main() {
 
 	// 分配超级管理员角色
 	if err := rbacService.AssignRoleByCode(ctx, user.ID, model.RoleSuperAdmin); err != nil {
+		if errors.Is(err, service.ErrRoleAlreadyAssigned) {
+			fmt.Printf("用户 %s (%s) 已拥有超级管理员角色\n", user.Username, user.Email)
+			return
+		}
 		log.Fatalf("分配角色失败: %v", err)
 	}
 
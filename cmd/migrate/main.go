@@ -37,6 +37,11 @@ func main() {
 	// 执行迁移
 	log.Println("开始执行数据库迁移...")
 
+	// user_roles 建唯一索引前先清理软删除和重复的记录
+	if err := cleanupUserRoles(cfg.Database.Driver); err != nil {
+		log.Fatalf("清理 user_roles 失败: %v", err)
+	}
+
 	// 迁移所有模型
 	models := []any{
 		&model.User{},
@@ -46,7 +51,12 @@ func main() {
 		&model.Role{},
 		&model.Permission{},
 		&model.UserRole{},
+		&model.ApplicationRole{},
 		&model.RolePermission{},
+		&model.AuditLog{},
+		&model.PasswordHistory{},
+		&model.Scope{},
+		&model.UserConsent{},
 	}
 
 	for _, m := range models {
@@ -55,16 +65,19 @@ func main() {
 		}
 	}
 
-	// 额外迁移：允许 applications.org_id 为 NULL（系统级应用）
-	if cfg.Database.Driver == "postgres" {
-		if err := database.GetDB().Exec("ALTER TABLE applications ALTER COLUMN org_id DROP NOT NULL").Error; err != nil {
-			log.Printf("忽略 org_id DROP NOT NULL 迁移错误（可能已是 NULL）: %v", err)
+	// 版本化迁移：每个版本只执行一次
+	report, err := database.Migrate()
+	if report != nil {
+		for _, m := range report.Skipped {
+			log.Printf("跳过已应用的迁移 %d: %s", m.Version, m.Name)
 		}
-	} else if cfg.Database.Driver == "mysql" {
-		if err := database.GetDB().Exec("ALTER TABLE applications MODIFY COLUMN org_id char(36) NULL").Error; err != nil {
-			log.Printf("忽略 org_id NULL 迁移错误（可能已是 NULL）: %v", err)
+		for _, m := range report.Applied {
+			log.Printf("已应用迁移 %d: %s", m.Version, m.Name)
 		}
 	}
+	if err != nil {
+		log.Fatalf("版本化迁移失败: %v", err)
+	}
 
 	log.Println("数据库迁移完成！")
 
@@ -76,6 +89,41 @@ func main() {
 	log.Println("  - user_org_bindings (用户-组织绑定表)")
 	log.Println("  - roles (角色表)")
 	log.Println("  - permissions (权限表)")
-	log.Println("  - user_roles (用户角色关联表)")
+	log.Println("  - user_roles (用户角色关联表，(user_id, role_id) 唯一，支持到期时间)")
 	log.Println("  - role_permissions (角色权限关联表)")
+	log.Println("  - password_histories (密码历史，防止重用最近的密码)")
+	log.Println("  - schema_migrations (已执行的迁移版本)")
+}
+
+// cleanupUserRoles 为 (user_id, role_id) 唯一索引做准备：
+// 物理删除软删除的用户角色，同一用户同一角色只保留 id 最小的一条
+func cleanupUserRoles(driver string) error {
+	db := database.GetDB()
+	if !db.Migrator().HasTable(&model.UserRole{}) {
+		return nil
+	}
+
+	if err := db.Exec("DELETE FROM user_roles WHERE deleted_at IS NOT NULL").Error; err != nil {
+		return err
+	}
+
+	var dedupe string
+	switch driver {
+	case "postgres":
+		dedupe = "DELETE FROM user_roles a USING user_roles b WHERE a.user_id = b.user_id AND a.role_id = b.role_id AND a.id > b.id"
+	case "mysql":
+		dedupe = "DELETE a FROM user_roles a JOIN user_roles b ON a.user_id = b.user_id AND a.role_id = b.role_id AND a.id > b.id"
+	case "sqlite":
+		dedupe = "DELETE FROM user_roles WHERE EXISTS (SELECT 1 FROM user_roles b WHERE b.user_id = user_roles.user_id AND b.role_id = user_roles.role_id AND user_roles.id > b.id)"
+	default:
+		return nil
+	}
+	result := db.Exec(dedupe)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("已删除 %d 条重复的用户角色", result.RowsAffected)
+	}
+	return nil
 }
@@ -42,8 +42,10 @@ func main() {
 
 	// 注意依赖顺序：先删子表再删父表
 	dropOrder := []any{
+		&model.AuditLog{},
 		&model.RolePermission{},
 		&model.UserRole{},
+		&model.ApplicationRole{},
 		&model.UserOrgBinding{},
 		&model.Application{},
 		&model.Permission{},
@@ -73,6 +75,7 @@ func main() {
 			&model.Application{},
 			&model.UserOrgBinding{},
 			&model.UserRole{},
+			&model.ApplicationRole{},
 			&model.RolePermission{},
 		}
 		for _, t := range createOrder {
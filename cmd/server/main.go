@@ -2,22 +2,27 @@ package main
 
 import (
 	"context"
-	"crypto/rand"
+	"crypto"
+	"crypto/ecdsa"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/pu-ac-cn/uac-backend/internal/config"
 	"github.com/pu-ac-cn/uac-backend/internal/database"
+	"github.com/pu-ac-cn/uac-backend/internal/feature"
 	"github.com/pu-ac-cn/uac-backend/internal/handler"
+	"github.com/pu-ac-cn/uac-backend/internal/metrics"
 	"github.com/pu-ac-cn/uac-backend/internal/middleware"
 	"github.com/pu-ac-cn/uac-backend/internal/model"
 	"github.com/pu-ac-cn/uac-backend/internal/redis"
@@ -27,12 +32,18 @@ import (
 	"github.com/pu-ac-cn/uac-backend/web"
 )
 
+// publicAPIPrefix 公开只读端点的路径前缀
+const publicAPIPrefix = "/api/v1/public/"
+
 func main() {
 	// 加载配置
 	cfg, err := config.Load()
 	if err != nil {
 		log.Fatalf("加载配置失败: %v", err)
 	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("配置校验失败: %v", err)
+	}
 
 	// 初始化数据库连接
 	if err := database.Init(&cfg.Database); err != nil {
@@ -57,34 +68,58 @@ func main() {
 		&model.Role{},
 		&model.Permission{},
 		&model.UserRole{},
+		&model.ApplicationRole{},
+		&model.AuditLog{},
+		&model.PasswordHistory{},
+		&model.Scope{},
+		&model.UserConsent{},
 	); err != nil {
 		log.Fatalf("数据库迁移失败: %v", err)
 	}
+	report, err := database.Migrate()
+	if err != nil {
+		log.Fatalf("版本化迁移失败: %v", err)
+	}
+	for _, m := range report.Applied {
+		log.Printf("已应用迁移 %d: %s", m.Version, m.Name)
+	}
 	log.Println("数据库迁移完成")
 
 	// 初始化 Repository
 	userRepo := repository.NewUserRepository(database.GetDB())
 	orgRepo := repository.NewOrganizationRepository(database.GetDB())
 	bindingRepo := repository.NewUserOrgBindingRepository(database.GetDB())
+	passwordHistoryRepo := repository.NewPasswordHistoryRepository(database.GetDB())
 
-	// 加载或生成 RSA 密钥对
-	privateKey, err := loadOrGenerateRSAKey(cfg.JWT.PrivateKeyPath, cfg.JWT.PublicKeyPath)
+	// 加载或生成签名密钥对
+	privateKey, err := loadOrGenerateSigningKey(cfg.JWT.Algorithm, cfg.JWT.PrivateKeyPEM, cfg.JWT.PrivateKeyPath, cfg.JWT.PublicKeyPath)
 	if err != nil {
-		log.Fatalf("加载 RSA 密钥失败: %v", err)
+		log.Fatalf("加载签名密钥失败: %v", err)
 	}
-	log.Println("RSA 密钥加载成功")
+	log.Printf("%s 签名密钥加载成功", cfg.JWT.Algorithm)
 
 	// 初始化 Service
-	userService := service.NewUserService(userRepo, bindingRepo, orgRepo)
-	authService := service.NewAuthService(userRepo)
 	tokenService := service.NewTokenService(&service.TokenServiceConfig{
-		PrivateKey:    privateKey,
-		PublicKey:     &privateKey.PublicKey,
-		KeyID:         "key-1",
-		Issuer:        cfg.JWT.Issuer,
-		AccessExpiry:  cfg.JWT.AccessExpiry,
-		RefreshExpiry: cfg.JWT.RefreshExpiry,
-		CodeExpiry:    10 * time.Minute,
+		Algorithm:         cfg.JWT.Algorithm,
+		PrivateKey:        privateKey,
+		PublicKey:         privateKey.Public(),
+		KeyID:             "key-1",
+		Issuer:            cfg.JWT.Issuer,
+		AccessExpiry:      cfg.JWT.AccessExpiry,
+		RefreshExpiry:     cfg.JWT.RefreshExpiry,
+		CodeExpiry:        10 * time.Minute,
+		Leeway:            cfg.JWT.Leeway,
+		AccessTokenClaims: cfg.JWT.AccessTokenClaims,
+		IDTokenClaims:     cfg.JWT.IDTokenClaims,
+		Redis:             redis.GetClient(),
+	})
+	userService := service.NewUserService(userRepo, bindingRepo, orgRepo, &service.UserServiceConfig{
+		AllowDuplicatePhone: cfg.User.AllowDuplicatePhone,
+		DefaultCountryCode:  cfg.User.DefaultCountryCode,
+		TokenService:        tokenService,
+		PasswordHistory:     passwordHistoryRepo,
+		PasswordHistorySize: cfg.User.PasswordHistory,
+		Redis:               redis.GetClient(),
 	})
 
 	// 初始化应用服务
@@ -92,13 +127,63 @@ func main() {
 	appService := service.NewApplicationService(appRepo, orgRepo)
 
 	// 初始化会话服务
-	sessionService := service.NewSessionService(redis.GetClient(), nil)
+	sessionService := service.NewSessionService(redis.GetClient(), &service.SessionServiceConfig{
+		MaxActiveSessions:  cfg.Session.MaxActive,
+		SessionLimitPolicy: cfg.Session.LimitPolicy,
+	})
+
+	// 初始化认证服务
+	emailSender := service.NewLogEmailSender()
+	events := service.NewLogEventPublisher()
+	if cfg.Webhook.URL != "" {
+		events = service.NewWebhookEventPublisher(cfg.Webhook.URL, cfg.Webhook.Secret)
+	}
+	authService := service.NewAuthService(userRepo, &service.AuthServiceConfig{
+		Redis:               redis.GetClient(),
+		TokenService:        tokenService,
+		SessionService:      sessionService,
+		EmailSender:         emailSender,
+		ResetURL:            cfg.JWT.Issuer + "/reset-password",
+		DefaultCountryCode:  cfg.User.DefaultCountryCode,
+		AllowDuplicatePhone: cfg.User.AllowDuplicatePhone,
+		Events:              events,
+		PasswordHistory:     passwordHistoryRepo,
+		PasswordHistorySize: cfg.User.PasswordHistory,
+	})
+
+	// 初始化多因素认证服务
+	mfaService := service.NewMFAService(userRepo, &service.MFAServiceConfig{
+		Redis: redis.GetClient(),
+	})
 
 	// 初始化 RBAC 服务
 	roleRepo := repository.NewRoleRepository(database.GetDB())
 	permRepo := repository.NewPermissionRepository(database.GetDB())
 	userRoleRepo := repository.NewUserRoleRepository(database.GetDB())
-	rbacService := service.NewRBACService(roleRepo, permRepo, userRoleRepo)
+	appRoleRepo := repository.NewAppRoleRepository(database.GetDB())
+	extraSystemPerms := make([]model.Permission, 0, len(cfg.RBAC.SystemPermissions))
+	for _, perm := range cfg.RBAC.SystemPermissions {
+		extraSystemPerms = append(extraSystemPerms, model.Permission{
+			Resource:    perm.Resource,
+			Action:      perm.Action,
+			Description: perm.Description,
+		})
+	}
+	rbacService := service.NewRBACService(roleRepo, permRepo, userRoleRepo, &service.RBACServiceConfig{
+		ExtraSystemPermissions: extraSystemPerms,
+		AppRoleRepo:            appRoleRepo,
+		OrgAccess:              userService,
+		Redis:                  redis.GetClient(),
+	})
+
+	// 初始化未登录账户自动禁用服务
+	inactivityService := service.NewInactivityService(userRepo, userRoleRepo, &service.InactivityServiceConfig{
+		Threshold:         time.Duration(cfg.Inactivity.Days) * 24 * time.Hour,
+		ExemptUsernames:   cfg.Inactivity.ExemptUsers,
+		ExemptSuperAdmins: cfg.Inactivity.ExemptSuperAdmins,
+		DryRun:            cfg.Inactivity.DryRun,
+		Events:            events,
+	})
 
 	// 初始化默认角色和权限
 	if err := rbacService.InitDefaultRolesAndPermissions(context.Background()); err != nil {
@@ -107,17 +192,71 @@ func main() {
 		log.Println("默认角色和权限初始化完成")
 	}
 
+	// 初始化系统内置 scope 说明
+	scopeService := service.NewScopeService(repository.NewScopeRepository(database.GetDB()))
+	if err := scopeService.InitDefaultScopes(context.Background()); err != nil {
+		log.Printf("初始化系统 scope 失败: %v", err)
+	}
+	consentService := service.NewConsentService(repository.NewConsentRepository(database.GetDB()), tokenService)
+
+	// 初始化审计日志服务
+	auditService := service.NewAuditService(repository.NewAuditLogRepository(database.GetDB()))
+
 	// 初始化组织服务
-	orgService := service.NewOrganizationService(orgRepo)
+	orgService := service.NewOrganizationService(orgRepo, appRepo)
+
+	// 初始化邮箱验证服务
+	verificationService := service.NewEmailVerificationService(redis.GetClient(), userService, emailSender, cfg.JWT.Issuer+"/api/v1/auth/verify-email")
+
+	// 初始化功能开关
+	features := feature.New(cfg.FeatureValues())
+
+	// 密码强度策略
+	passwordPolicy := service.PasswordPolicy{
+		MinLength:      cfg.User.PasswordPolicy.MinLength,
+		RequireUpper:   cfg.User.PasswordPolicy.RequireUpper,
+		RequireLower:   cfg.User.PasswordPolicy.RequireLower,
+		RequireDigit:   cfg.User.PasswordPolicy.RequireDigit,
+		RequireSpecial: cfg.User.PasswordPolicy.RequireSpecial,
+	}
 
 	// 初始化 Handler
-	authHandler := handler.NewAuthHandler(userService, authService, tokenService, rbacService)
-	oauthHandler := handler.NewOAuthHandler(appService, tokenService, sessionService)
-	oidcHandler := handler.NewOIDCHandler(userService, tokenService, cfg.JWT.Issuer)
+	authHandler := handler.NewAuthHandler(userService, authService, tokenService, sessionService, rbacService)
+	authHandler.SetMFAService(mfaService)
+	authHandler.SetPasswordPolicy(passwordPolicy)
+	oauthHandler := handler.NewOAuthHandler(appService, tokenService, sessionService, features)
+	oauthHandler.SetRBACService(rbacService)
+	oauthHandler.SetConsentServices(scopeService, orgService)
+	if cfg.OAuth.RequireConsent {
+		oauthHandler.SetConsentService(consentService)
+	}
+	oauthHandler.SetRequireRefreshGrantForOfflineAccess(cfg.OAuth.RequireRefreshGrantForOfflineAccess)
+	oidcHandler := handler.NewOIDCHandler(userService, tokenService, appService, sessionService, cfg.JWT.Issuer, features)
+	casHandler := handler.NewCASHandler(sessionService, userService)
 	rbacHandler := handler.NewRBACHandler(rbacService)
-	userHandler := handler.NewUserHandler(userService)
+	userHandler := handler.NewUserHandler(userService, rbacService)
+	userHandler.SetPasswordPolicy(passwordPolicy)
 	appHandler := handler.NewAppHandler(appService)
-	orgHandler := handler.NewOrgHandler(orgService)
+	appHandler.SetExtraScopes(cfg.OAuth.ExtraScopes)
+	appHandler.SetMaxTokenTTL(max(cfg.JWT.AccessExpiry, cfg.JWT.RefreshExpiry))
+	appHandler.SetRBACService(rbacService)
+	orgHandler := handler.NewOrgHandler(orgService, appService)
+	orgHandler.SetUserService(userService)
+	orgHandler.SetAppValidator(appHandler)
+	orgHandler.SetRBACService(rbacService)
+	sessionHandler := handler.NewSessionHandler(sessionService)
+	verificationHandler := handler.NewVerificationHandler(verificationService)
+	auditHandler := handler.NewAuditHandler(auditService)
+	tokenHandler := handler.NewTokenHandler(tokenService)
+	mfaHandler := handler.NewMFAHandler(mfaService)
+	authorizedAppHandler := handler.NewAuthorizedAppHandler(consentService, appService)
+
+	// 关键安全操作写入审计日志
+	authHandler.SetAuditService(auditService)
+	rbacHandler.SetAuditService(auditService)
+	userHandler.SetAuditService(auditService)
+	appHandler.SetAuditService(auditService)
+	tokenHandler.SetAuditService(auditService)
 
 	// 设置 Gin 模式
 	if cfg.Server.Mode == "release" {
@@ -130,7 +269,32 @@ func main() {
 	// 全局中间件
 	router.Use(middleware.Logger())
 	router.Use(middleware.Recovery())
-	router.Use(middleware.CORS())
+	// 限制并发请求数，健康检查和监控指标不受影响
+	router.Use(middleware.ConcurrencyLimit(cfg.Server.MaxConcurrentRequests, "/health", "/metrics"))
+	router.Use(middleware.BodyLimit(cfg.Server.MaxBodyBytes, bodyLimitOverrides(cfg)...))
+	router.Use(middleware.CORS(publicAPIPrefix))
+
+	// 认证端点限流，防止暴力破解
+	rateLimiter := middleware.NewRateLimiter(redis.GetClient(), rateLimitOptions(cfg))
+	authRateLimit := rateLimiter.Handler()
+	authHandler.SetLoginRateLimiter(rateLimiter, "/api/v1/auth/login")
+
+	// 应用可热更新的配置，收到 SIGHUP 时重新加载
+	applyHotConfig(cfg, rateLimiter)
+	reloader := config.NewReloader(config.LoadedFiles(), cfg)
+	reloader.OnReload(func(c *config.Config) {
+		applyHotConfig(c, rateLimiter)
+	})
+	reloadCtx, stopReload := context.WithCancel(context.Background())
+	defer stopReload()
+	reloader.WatchSignals(reloadCtx)
+
+	// 定时禁用长期未登录的账户
+	jobCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	if cfg.Inactivity.Enabled {
+		go inactivityService.Run(jobCtx, cfg.Inactivity.Interval)
+	}
 
 	// 健康检查
 	router.GET("/health", func(c *gin.Context) {
@@ -149,14 +313,31 @@ func main() {
 			redisStatus = "error"
 		}
 
-		response.Success(c, gin.H{
+		health := gin.H{
 			"status":   "ok",
 			"time":     time.Now().Format(time.RFC3339),
 			"database": dbStatus,
 			"redis":    redisStatus,
-		})
+		}
+		// 连接池统计，便于排查连接耗尽
+		if stats, err := database.Stats(); err == nil {
+			health["database_pool"] = gin.H{
+				"max_open": stats.MaxOpenConnections,
+				"open":     stats.OpenConnections,
+				"in_use":   stats.InUse,
+				"idle":     stats.Idle,
+				"wait":     stats.WaitCount,
+			}
+		}
+		response.Success(c, health)
 	})
 
+	// Prometheus 监控指标，由监控系统抓取，不经过 JWT 认证
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
+
+	// 维护模式，健康检查和监控指标不受影响
+	router.Use(middleware.Maintenance())
+
 	// API 路由组
 	api := router.Group("/api/v1")
 	{
@@ -164,12 +345,23 @@ func main() {
 			response.Success(c, "pong")
 		})
 
+		// 公开只读端点，不允许携带凭据时对所有来源开放跨域
+		public := api.Group("/public")
+		{
+			public.GET("/orgs/:slug/branding", orgHandler.GetPublicBranding)
+		}
+
 		// 认证路由（公开）
 		auth := api.Group("/auth")
 		{
-			auth.POST("/register", authHandler.Register)
-			auth.POST("/login", authHandler.Login)
+			auth.POST("/register", authRateLimit, authHandler.Register)
+			auth.POST("/login", authRateLimit, authHandler.Login)
+			auth.GET("/status", authRateLimit, authHandler.GetAuthStatus)
+			auth.POST("/mfa/verify", authRateLimit, authHandler.VerifyMFA)
 			auth.POST("/refresh", authHandler.RefreshToken)
+			auth.GET("/verify-email", verificationHandler.VerifyEmail)
+			auth.POST("/forgot-password", authRateLimit, authHandler.ForgotPassword)
+			auth.POST("/reset-password", authRateLimit, authHandler.ResetPassword)
 		}
 
 		// 需要认证的路由
@@ -181,6 +373,15 @@ func main() {
 			authRequired.PUT("/auth/me", userHandler.UpdateCurrentUser)
 			authRequired.POST("/auth/change-password", userHandler.ChangePassword)
 			authRequired.GET("/auth/permissions", rbacHandler.GetCurrentUserPermissions)
+			authRequired.GET("/auth/sessions", sessionHandler.ListMySessions)
+			authRequired.DELETE("/auth/sessions/:session_id", sessionHandler.DeleteMySession)
+			authRequired.GET("/auth/authorized-apps", authorizedAppHandler.ListAuthorizedApps)
+			authRequired.DELETE("/auth/authorized-apps/:client_id", authorizedAppHandler.RevokeAuthorizedApp)
+			authRequired.POST("/auth/send-verification-email", verificationHandler.SendVerificationEmail)
+			authRequired.POST("/auth/mfa/totp", mfaHandler.EnrollTOTP)
+			authRequired.POST("/auth/mfa/totp/verify", mfaHandler.ConfirmTOTP)
+			authRequired.DELETE("/auth/mfa/totp", mfaHandler.DisableTOTP)
+			authRequired.POST("/auth/mfa/recovery-codes", mfaHandler.RegenerateRecoveryCodes)
 		}
 
 		// 用户管理路由（需要管理员权限）
@@ -190,22 +391,34 @@ func main() {
 		{
 			users.GET("", userHandler.ListUsers)
 			users.GET("/:id", userHandler.GetUser)
+			users.GET("/:id/full", userHandler.GetUserFull)
+			users.GET("/:id/assignable-roles", userHandler.ListAssignableRoles)
 			users.POST("", userHandler.CreateUser)
+			users.POST("/import", userHandler.ImportUsers)
+			users.POST("/merge", middleware.RequireRole(rbacService, model.RoleSuperAdmin), userHandler.MergeUsers)
 			users.PUT("/:id", userHandler.UpdateUser)
 			users.DELETE("/:id", userHandler.DeleteUser)
+			users.POST("/:id/restore", middleware.RequireRole(rbacService, model.RoleSuperAdmin), userHandler.RestoreUser)
+			users.POST("/:id/invalidate-tokens", authHandler.InvalidateUserTokens)
 		}
 
 		// 应用管理路由（需要管理员权限）
 		apps := api.Group("/apps")
 		apps.Use(middleware.JWTAuth(tokenService))
-		apps.Use(middleware.RequireAnyRole(rbacService, model.RoleSuperAdmin, model.RoleOrgAdmin))
 		{
-			apps.GET("", appHandler.ListApps)
-			apps.GET("/:id", appHandler.GetApp)
-			apps.POST("", appHandler.CreateApp)
+			// 非管理员可以修改自己创建的应用，由 handler 检查所有权
 			apps.PUT("/:id", appHandler.UpdateApp)
-			apps.DELETE("/:id", appHandler.DeleteApp)
-			apps.POST("/:id/reset-secret", appHandler.ResetSecret)
+
+			adminApps := apps.Group("")
+			adminApps.Use(middleware.RequireAnyRole(rbacService, model.RoleSuperAdmin, model.RoleOrgAdmin))
+			adminApps.GET("", appHandler.ListApps)
+			adminApps.GET("/:id", appHandler.GetApp)
+			adminApps.POST("", appHandler.CreateApp)
+			adminApps.DELETE("/:id", appHandler.DeleteApp)
+			adminApps.POST("/:id/reset-secret", appHandler.ResetSecret)
+			adminApps.GET("/:id/roles", appHandler.GetAppRoles)
+			adminApps.POST("/:id/roles", appHandler.AssignAppRole)
+			adminApps.DELETE("/:id/roles/:role_id", appHandler.RevokeAppRole)
 		}
 
 		// 组织管理路由（需要管理员权限）
@@ -214,11 +427,19 @@ func main() {
 		orgs.Use(middleware.RequireAnyRole(rbacService, model.RoleSuperAdmin, model.RoleOrgAdmin))
 		{
 			orgs.GET("", orgHandler.ListOrgs)
-			orgs.GET("/:id", orgHandler.GetOrg)
 			orgs.POST("", orgHandler.CreateOrg)
-			orgs.PUT("/:id", orgHandler.UpdateOrg)
-			orgs.DELETE("/:id", orgHandler.DeleteOrg)
-			orgs.PUT("/:id/branding", orgHandler.UpdateBranding)
+			orgs.POST("/import", orgHandler.ImportOrg)
+
+			// 具体组织的操作要求调用者属于该组织
+			orgMember := middleware.RequireOrgMembership(userService, rbacService, "id")
+			orgs.GET("/:id", orgMember, orgHandler.GetOrg)
+			orgs.PUT("/:id", orgMember, orgHandler.UpdateOrg)
+			orgs.DELETE("/:id", orgMember, orgHandler.DeleteOrg)
+			orgs.PUT("/:id/branding", orgMember, orgHandler.UpdateBranding)
+			orgs.GET("/:id/members", orgMember, orgHandler.ListMembers)
+			orgs.POST("/:id/members", orgMember, orgHandler.AddMember)
+			orgs.DELETE("/:id/members/:user_id", orgMember, orgHandler.RemoveMember)
+			orgs.GET("/:id/export", orgMember, orgHandler.ExportOrg)
 		}
 
 		// RBAC 管理路由（需要管理员权限）
@@ -234,31 +455,60 @@ func main() {
 			rbac.DELETE("/roles/:id", rbacHandler.DeleteRole)
 			rbac.POST("/roles/:id/permissions", rbacHandler.AddPermissionsToRole)
 			rbac.DELETE("/roles/:id/permissions", rbacHandler.RemovePermissionsFromRole)
+			rbac.PUT("/roles/:id/permissions", rbacHandler.SetRolePermissions)
 
 			// 权限管理
 			rbac.GET("/permissions", rbacHandler.ListPermissions)
 			rbac.GET("/permissions/:id", rbacHandler.GetPermission)
 			rbac.POST("/permissions", rbacHandler.CreatePermission)
 			rbac.DELETE("/permissions/:id", rbacHandler.DeletePermission)
+			rbac.POST("/permissions/:id/roles", rbacHandler.AddPermissionToRoles)
 
 			// 获取角色权限
 			rbac.GET("/roles/:id/permissions", rbacHandler.GetRolePermissions)
+			rbac.GET("/roles/:id/assignable-permissions", rbacHandler.ListAssignablePermissions)
 
 			// 用户角色管理（使用不同的路径避免冲突）
 			rbac.GET("/user-roles/:user_id", rbacHandler.GetUserRoles)
 			rbac.POST("/user-roles/:user_id", rbacHandler.AssignRole)
 			rbac.DELETE("/user-roles/:user_id/:role_id", rbacHandler.RevokeRole)
 		}
+
+		// 审计日志（仅超级管理员）
+		audit := api.Group("/audit-logs")
+		audit.Use(middleware.JWTAuth(tokenService))
+		audit.Use(middleware.RequireRole(rbacService, model.RoleSuperAdmin))
+		{
+			audit.GET("", auditHandler.ListAuditLogs)
+		}
+
+		// 管理员调试工具（仅超级管理员）
+		admin := api.Group("/admin")
+		admin.Use(middleware.JWTAuth(tokenService))
+		admin.Use(middleware.RequireRole(rbacService, model.RoleSuperAdmin))
+		{
+			admin.POST("/tokens/decode", tokenHandler.DecodeToken)
+		}
 	}
 
 	// OAuth 2.0/2.1 路由
 	oauth := router.Group("/oauth")
 	{
 		oauth.GET("/authorize", middleware.OptionalJWTAuth(tokenService), oauthHandler.Authorize)
-		oauth.POST("/token", oauthHandler.Token)
+		oauth.POST("/authorize/decision", middleware.JWTAuth(tokenService), oauthHandler.AuthorizeDecision)
+		oauth.GET("/consent-info", oauthHandler.ConsentInfo)
+		oauth.POST("/token", authRateLimit, oauthHandler.Token)
 		oauth.POST("/revoke", oauthHandler.Revoke)
 		oauth.POST("/introspect", oauthHandler.Introspect)
-		oauth.GET("/userinfo", middleware.JWTAuth(tokenService), oidcHandler.UserInfo)
+		oauth.GET("/userinfo", middleware.JWTAuth(tokenService), middleware.RequireScope("openid"), oidcHandler.UserInfo)
+		oauth.GET("/logout", oidcHandler.EndSession)
+	}
+
+	// CAS 协议路由
+	cas := router.Group("/cas")
+	{
+		cas.GET("/serviceValidate", casHandler.ServiceValidate)
+		cas.GET("/p3/serviceValidate", casHandler.ServiceValidate)
 	}
 
 	// OIDC 发现端点
@@ -276,7 +526,7 @@ func main() {
 			Mode:      staticMode,
 			DiskPath:  cfg.Static.Path,
 			IndexFile: "index.html",
-			APIPrefix: []string{"/api/", "/oauth/", "/.well-known/", "/health"},
+			APIPrefix: []string{"/api/", "/oauth/", "/cas/", "/.well-known/", "/health", "/metrics"},
 		})
 
 		// 设置静态文件路由和 SPA 处理
@@ -322,26 +572,63 @@ func main() {
 	log.Println("服务已关闭")
 }
 
-// loadOrGenerateRSAKey 加载或生成 RSA 密钥对
-// 如果密钥文件存在则加载，否则生成新密钥并保存到文件
-func loadOrGenerateRSAKey(privateKeyPath, publicKeyPath string) (*rsa.PrivateKey, error) {
+// rateLimitOptions 根据配置生成限流参数
+func rateLimitOptions(cfg *config.Config) middleware.RateLimitOptions {
+	return middleware.RateLimitOptions{
+		Window:   cfg.RateLimit.Window,
+		Limit:    cfg.RateLimit.Limit,
+		Disabled: !cfg.RateLimit.Enabled,
+	}
+}
+
+// bodyLimitOverrides 根据配置生成路径级请求体上限
+func bodyLimitOverrides(cfg *config.Config) []middleware.BodyLimitOverride {
+	overrides := make([]middleware.BodyLimitOverride, 0, len(cfg.Server.BodyLimitOverrides))
+	for _, o := range cfg.Server.BodyLimitOverrides {
+		overrides = append(overrides, middleware.BodyLimitOverride{PathPrefix: o.Path, MaxBytes: o.MaxBytes})
+	}
+	return overrides
+}
+
+// applyHotConfig 应用可热更新的配置：日志级别、限流、跨域与维护模式
+func applyHotConfig(cfg *config.Config, rateLimiter *middleware.RateLimiter) {
+	if err := middleware.SetLogLevel(cfg.Log.Level); err != nil {
+		log.Printf("无效的日志级别 %q: %v", cfg.Log.Level, err)
+	}
+	rateLimiter.Update(rateLimitOptions(cfg))
+	middleware.SetCORSOrigins(cfg.CORS.AllowedOrigins)
+	middleware.SetCORSCredentials(cfg.CORS.AllowCredentials)
+	middleware.SetMaintenance(cfg.Server.Maintenance)
+}
+
+// loadOrGenerateSigningKey 加载或生成 alg 对应的签名密钥对
+// 优先使用配置中的 PEM 内容，其次加载密钥文件，都不存在时生成新密钥并保存到文件
+// PEM 内容或已存在的密钥文件无法解析、或与 alg 不匹配时返回错误，避免覆盖现有密钥
+func loadOrGenerateSigningKey(alg, privateKeyPEM, privateKeyPath, publicKeyPath string) (crypto.Signer, error) {
+	if strings.TrimSpace(privateKeyPEM) != "" {
+		privateKey, err := service.ParseSigningKeyPEM([]byte(privateKeyPEM), alg)
+		if err != nil {
+			return nil, fmt.Errorf("解析配置中的私钥失败: %w", err)
+		}
+		log.Println("从配置加载签名私钥")
+		return privateKey, nil
+	}
+
 	// 尝试加载已有的私钥
 	if privateKeyPath != "" {
 		if privateKeyData, err := os.ReadFile(privateKeyPath); err == nil {
-			block, _ := pem.Decode(privateKeyData)
-			if block != nil && block.Type == "RSA PRIVATE KEY" {
-				privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
-				if err == nil {
-					log.Printf("从文件加载 RSA 私钥: %s", privateKeyPath)
-					return privateKey, nil
-				}
+			privateKey, err := service.ParseSigningKeyPEM(privateKeyData, alg)
+			if err != nil {
+				return nil, fmt.Errorf("解析私钥文件 %s 失败: %w", privateKeyPath, err)
 			}
+			log.Printf("从文件加载签名私钥: %s", privateKeyPath)
+			return privateKey, nil
 		}
 	}
 
 	// 生成新的密钥对
-	log.Println("生成新的 RSA 密钥对...")
-	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	log.Printf("生成新的 %s 密钥对...", alg)
+	privateKey, err := service.GenerateSigningKey(alg)
 	if err != nil {
 		return nil, err
 	}
@@ -351,16 +638,16 @@ func loadOrGenerateRSAKey(privateKeyPath, publicKeyPath string) (*rsa.PrivateKey
 		if err := savePrivateKey(privateKeyPath, privateKey); err != nil {
 			log.Printf("警告: 保存私钥失败: %v", err)
 		} else {
-			log.Printf("RSA 私钥已保存到: %s", privateKeyPath)
+			log.Printf("签名私钥已保存到: %s", privateKeyPath)
 		}
 	}
 
 	// 保存公钥到文件
 	if publicKeyPath != "" {
-		if err := savePublicKey(publicKeyPath, &privateKey.PublicKey); err != nil {
+		if err := savePublicKey(publicKeyPath, privateKey.Public()); err != nil {
 			log.Printf("警告: 保存公钥失败: %v", err)
 		} else {
-			log.Printf("RSA 公钥已保存到: %s", publicKeyPath)
+			log.Printf("签名公钥已保存到: %s", publicKeyPath)
 		}
 	}
 
@@ -368,17 +655,26 @@ func loadOrGenerateRSAKey(privateKeyPath, publicKeyPath string) (*rsa.PrivateKey
 }
 
 // savePrivateKey 保存私钥到 PEM 文件
-func savePrivateKey(path string, key *rsa.PrivateKey) error {
+// RSA 私钥使用 PKCS1 格式，ECDSA 私钥使用 SEC1 格式
+func savePrivateKey(path string, key crypto.Signer) error {
 	// 确保目录存在
 	dir := filepath.Dir(path)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return err
 	}
 
-	keyBytes := x509.MarshalPKCS1PrivateKey(key)
-	block := &pem.Block{
-		Type:  "RSA PRIVATE KEY",
-		Bytes: keyBytes,
+	var block *pem.Block
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		block = &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(k)}
+	case *ecdsa.PrivateKey:
+		keyBytes, err := x509.MarshalECPrivateKey(k)
+		if err != nil {
+			return err
+		}
+		block = &pem.Block{Type: "EC PRIVATE KEY", Bytes: keyBytes}
+	default:
+		return fmt.Errorf("不支持的私钥类型 %T", key)
 	}
 
 	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
@@ -391,7 +687,7 @@ func savePrivateKey(path string, key *rsa.PrivateKey) error {
 }
 
 // savePublicKey 保存公钥到 PEM 文件
-func savePublicKey(path string, key *rsa.PublicKey) error {
+func savePublicKey(path string, key crypto.PublicKey) error {
 	// 确保目录存在
 	dir := filepath.Dir(path)
 	if err := os.MkdirAll(dir, 0755); err != nil {
@@ -1,19 +1,154 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/pu-ac-cn/uac-backend/internal/feature"
 	"github.com/spf13/viper"
 )
 
 // Config 应用配置
 type Config struct {
-	Server   ServerConfig   `mapstructure:"server"`
-	Database DatabaseConfig `mapstructure:"database"`
-	Redis    RedisConfig    `mapstructure:"redis"`
-	JWT      JWTConfig      `mapstructure:"jwt"`
-	Static   StaticConfig   `mapstructure:"static"`
+	Server    ServerConfig    `mapstructure:"server"`
+	Database  DatabaseConfig  `mapstructure:"database"`
+	Redis     RedisConfig     `mapstructure:"redis"`
+	JWT       JWTConfig       `mapstructure:"jwt"`
+	Static    StaticConfig    `mapstructure:"static"`
+	RateLimit RateLimitConfig `mapstructure:"rate_limit"`
+	Log       LogConfig       `mapstructure:"log"`
+	CORS      CORSConfig      `mapstructure:"cors"`
+	User      UserConfig      `mapstructure:"user"`
+	Webhook   WebhookConfig   `mapstructure:"webhook"`
+	Session   SessionConfig   `mapstructure:"session"`
+	OAuth     OAuthConfig     `mapstructure:"oauth"`
+	// Inactivity 长期未登录账户自动禁用
+	Inactivity InactivityConfig `mapstructure:"inactivity"`
+	// RBAC 权限配置
+	RBAC RBACConfig `mapstructure:"rbac"`
+	// Features 功能开关，键为开关名称（如 allow_plain_pkce），未配置的使用默认值
+	Features map[string]bool `mapstructure:"features"`
+}
+
+// OAuthConfig OAuth 协议配置
+type OAuthConfig struct {
+	// RequirePKCES256 只接受 S256 方式的 PKCE，等同于关闭 allow_plain_pkce 功能开关
+	RequirePKCES256 bool `mapstructure:"require_pkce_s256"`
+	// ExtraScopes 除 OIDC 标准 scope 外，应用可申请的自定义 scope
+	ExtraScopes []string `mapstructure:"extra_scopes"`
+	// RequireRefreshGrantForOfflineAccess 授权时申请 offline_access 的应用必须允许 refresh_token 授权类型
+	RequireRefreshGrantForOfflineAccess bool `mapstructure:"require_refresh_grant_for_offline_access"`
+	// RequireConsent 首次授权应用时要求用户在同意页确认，已同意过的应用再次授权时跳过
+	RequireConsent bool `mapstructure:"require_consent"`
+}
+
+// FeatureValues 返回生效的功能开关配置
+// oauth.require_pkce_s256 开启时覆盖 features.allow_plain_pkce
+func (c *Config) FeatureValues() map[string]bool {
+	values := make(map[string]bool, len(c.Features)+1)
+	for name, enabled := range c.Features {
+		values[name] = enabled
+	}
+	if c.OAuth.RequirePKCES256 {
+		values[feature.AllowPlainPKCE] = false
+	}
+	return values
+}
+
+// SessionConfig 登录会话配置
+type SessionConfig struct {
+	// MaxActive 每个用户的最大活跃会话数，0 表示不限制
+	MaxActive int `mapstructure:"max_active"`
+	// LimitPolicy 达到上限时的策略：reject 拒绝新登录，evict_oldest 结束最早的会话
+	LimitPolicy string `mapstructure:"limit_policy"`
+}
+
+// InactivityConfig 长期未登录账户自动禁用配置
+type InactivityConfig struct {
+	// Enabled 是否启用定时检查
+	Enabled bool `mapstructure:"enabled"`
+	// Days 超过该天数未登录的账户被禁用
+	Days int `mapstructure:"days"`
+	// Interval 检查间隔
+	Interval time.Duration `mapstructure:"interval"`
+	// DryRun 仅记录将被禁用的账户，不实际修改
+	DryRun bool `mapstructure:"dry_run"`
+	// ExemptUsers 不受影响的服务账号用户名
+	ExemptUsers []string `mapstructure:"exempt_users"`
+	// ExemptSuperAdmins 是否跳过超级管理员
+	ExemptSuperAdmins bool `mapstructure:"exempt_super_admins"`
+}
+
+// RBACConfig 权限配置
+type RBACConfig struct {
+	// SystemPermissions 初始化时额外创建的系统内置权限，与默认权限一样不可修改或删除
+	SystemPermissions []SystemPermissionConfig `mapstructure:"system_permissions"`
+}
+
+// SystemPermissionConfig 额外的系统内置权限
+type SystemPermissionConfig struct {
+	Resource    string `mapstructure:"resource"`
+	Action      string `mapstructure:"action"`
+	Description string `mapstructure:"description"`
+}
+
+// WebhookConfig 事件推送配置
+type WebhookConfig struct {
+	// URL 接收事件的地址，为空时事件仅记录日志
+	URL string `mapstructure:"url"`
+	// Secret 请求签名密钥，签名放在 X-UAC-Signature 头
+	Secret string `mapstructure:"secret"`
+}
+
+// UserConfig 用户账户配置
+type UserConfig struct {
+	// AllowDuplicatePhone 允许多个用户使用同一手机号，默认要求唯一；开启后不支持手机号登录
+	AllowDuplicatePhone bool `mapstructure:"allow_duplicate_phone"`
+	// DefaultCountryCode 手机号未带国家码时使用的默认国家码
+	DefaultCountryCode string `mapstructure:"default_country_code"`
+	// PasswordHistory 修改密码时禁止重用的最近密码数量，负数关闭检查
+	PasswordHistory int `mapstructure:"password_history"`
+	// PasswordPolicy 密码强度策略
+	PasswordPolicy PasswordPolicyConfig `mapstructure:"password_policy"`
+}
+
+// PasswordPolicyConfig 密码强度策略配置，默认最少 8 位且包含大写字母、小写字母和数字
+type PasswordPolicyConfig struct {
+	MinLength      int  `mapstructure:"min_length"`
+	RequireUpper   bool `mapstructure:"require_upper"`
+	RequireLower   bool `mapstructure:"require_lower"`
+	RequireDigit   bool `mapstructure:"require_digit"`
+	RequireSpecial bool `mapstructure:"require_special"`
+}
+
+// LogConfig 日志配置
+type LogConfig struct {
+	// Level 日志级别：debug、info、warn、error
+	Level string `mapstructure:"level"`
+}
+
+// CORSConfig 跨域配置
+type CORSConfig struct {
+	// AllowedOrigins 允许的来源白名单，支持精确来源和 https://*.example.com 形式的通配子域
+	// 为空表示不允许任何跨域来源；* 允许所有来源但不携带凭据
+	AllowedOrigins []string `mapstructure:"allowed_origins"`
+	// AllowCredentials 是否允许跨域请求携带凭据，关闭后公开只读端点对所有来源开放
+	AllowCredentials bool `mapstructure:"allow_credentials"`
+}
+
+// RateLimitConfig 限流配置
+type RateLimitConfig struct {
+	// Enabled 是否启用认证端点限流
+	Enabled bool `mapstructure:"enabled"`
+	// Window 计数窗口
+	Window time.Duration `mapstructure:"window"`
+	// Limit 每个 IP 在窗口内对单个端点的最大请求数
+	Limit int `mapstructure:"limit"`
 }
 
 // StaticConfig 静态文件配置
@@ -29,12 +164,29 @@ type StaticConfig struct {
 // 全局配置实例
 var globalConfig *Config
 
+// loadedFiles 最近一次加载的配置文件，用于热更新时重新读取
+var loadedFiles []string
+
 // ServerConfig 服务器配置
 type ServerConfig struct {
 	Addr         string        `mapstructure:"addr"`
 	Mode         string        `mapstructure:"mode"`
 	ReadTimeout  time.Duration `mapstructure:"read_timeout"`
 	WriteTimeout time.Duration `mapstructure:"write_timeout"`
+	// Maintenance 维护模式，开启后 API 返回 503
+	Maintenance bool `mapstructure:"maintenance"`
+	// MaxConcurrentRequests 同时处理的最大请求数，超出返回 503，0 表示不限制
+	MaxConcurrentRequests int `mapstructure:"max_concurrent_requests"`
+	// MaxBodyBytes 请求体大小上限（字节），超出返回 413，0 表示不限制
+	MaxBodyBytes int64 `mapstructure:"max_body_bytes"`
+	// BodyLimitOverrides 按路径前缀覆盖请求体大小上限
+	BodyLimitOverrides []BodyLimitOverride `mapstructure:"body_limit_overrides"`
+}
+
+// BodyLimitOverride 路径级请求体大小上限
+type BodyLimitOverride struct {
+	Path     string `mapstructure:"path"`      // 路径前缀
+	MaxBytes int64  `mapstructure:"max_bytes"` // 上限（字节），0 表示不限制
 }
 
 // DatabaseConfig 数据库配置
@@ -42,6 +194,13 @@ type DatabaseConfig struct {
 	Driver   string         `mapstructure:"driver"`
 	Postgres PostgresConfig `mapstructure:"postgres"`
 	MySQL    MySQLConfig    `mapstructure:"mysql"`
+	SQLite   SQLiteConfig   `mapstructure:"sqlite"`
+	// 连接池参数，为 0 时使用 database 包的默认值
+	MaxOpenConns    int           `mapstructure:"max_open_conns"`    // 最大打开连接数
+	MaxIdleConns    int           `mapstructure:"max_idle_conns"`    // 最大空闲连接数，不能超过 MaxOpenConns
+	ConnMaxLifetime time.Duration `mapstructure:"conn_max_lifetime"` // 连接最大生命周期，SQLite 连接始终不过期
+	// SlowQueryThreshold 执行时间超过该值的 SQL 记录为慢查询，0 表示不记录
+	SlowQueryThreshold time.Duration `mapstructure:"slow_query_threshold"`
 }
 
 // PostgresConfig PostgreSQL 配置
@@ -66,6 +225,15 @@ type MySQLConfig struct {
 	Loc       string `mapstructure:"loc"`
 }
 
+// SQLiteMemory SQLite 内存数据库路径，进程退出后数据丢失
+const SQLiteMemory = ":memory:"
+
+// SQLiteConfig SQLite 配置，适用于本地开发和测试
+type SQLiteConfig struct {
+	// Path 数据库文件路径，":memory:" 表示内存数据库
+	Path string `mapstructure:"path"`
+}
+
 // RedisConfig Redis 配置
 type RedisConfig struct {
 	Addr     string `mapstructure:"addr"`
@@ -75,48 +243,132 @@ type RedisConfig struct {
 
 // JWTConfig JWT 配置
 type JWTConfig struct {
-	PrivateKeyPath string        `mapstructure:"private_key_path"`
-	PublicKeyPath  string        `mapstructure:"public_key_path"`
-	Issuer         string        `mapstructure:"issuer"`
-	AccessExpiry   time.Duration `mapstructure:"access_expiry"`
-	RefreshExpiry  time.Duration `mapstructure:"refresh_expiry"`
+	// Algorithm 令牌签名算法：RS256 或 ES256，私钥类型须与之匹配
+	Algorithm      string `mapstructure:"algorithm"`
+	PrivateKeyPath string `mapstructure:"private_key_path"`
+	PublicKeyPath  string `mapstructure:"public_key_path"`
+	// PrivateKeyPEM PEM 格式的私钥内容，优先于 PrivateKeyPath，便于容器化部署通过环境变量注入
+	// 可通过 UAC_JWT_PRIVATE_KEY 或 UAC_JWT_PRIVATE_KEY_PEM 环境变量设置
+	PrivateKeyPEM string        `mapstructure:"private_key_pem"`
+	Issuer        string        `mapstructure:"issuer"`
+	AccessExpiry  time.Duration `mapstructure:"access_expiry"`
+	RefreshExpiry time.Duration `mapstructure:"refresh_expiry"`
+	// Leeway 校验令牌时间声明时容忍的时钟偏移
+	Leeway time.Duration `mapstructure:"leeway"`
+	// AccessTokenClaims 访问令牌允许携带的用户声明，为空表示全部
+	// 配置为 ["sub"] 时访问令牌不含个人信息，由 UserInfo 端点提供
+	AccessTokenClaims []string `mapstructure:"access_token_claims"`
+	// IDTokenClaims ID 令牌允许携带的用户声明，为空表示全部
+	IDTokenClaims []string `mapstructure:"id_token_claims"`
 }
 
-// Load 加载配置
-// 支持从配置文件和环境变量加载配置
-// 环境变量格式：UAC_SERVER_ADDR, UAC_DATABASE_DRIVER 等
-func Load() (*Config, error) {
-	viper.SetConfigName("config")
-	viper.SetConfigType("yaml")
-	viper.AddConfigPath("./configs")
-	viper.AddConfigPath(".")
+// validJWTAlgorithms 支持的令牌签名算法
+var validJWTAlgorithms = map[string]bool{"RS256": true, "ES256": true}
 
-	// 支持环境变量覆盖
-	// 环境变量前缀为 UAC，使用下划线分隔
-	// 例如：UAC_SERVER_ADDR 对应 server.addr
-	viper.SetEnvPrefix("UAC")
-	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
-	viper.AutomaticEnv()
+// DefaultIssuer 默认的令牌签发者，生产环境必须替换为实际的服务地址
+const DefaultIssuer = "unified-auth-center"
 
-	// 设置默认值
-	setDefaults()
+// ModeRelease 生产运行模式
+const ModeRelease = "release"
 
-	if err := viper.ReadInConfig(); err != nil {
-		// 配置文件不存在时使用默认值
-		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
-			return nil, err
+// Validate 校验配置，release 模式下额外强制生产环境安全项
+func (c *Config) Validate() error {
+	if err := c.validateReloadable(); err != nil {
+		return err
+	}
+	if !validJWTAlgorithms[c.JWT.Algorithm] {
+		return fmt.Errorf("不支持的 jwt.algorithm: %q，可选 RS256 或 ES256", c.JWT.Algorithm)
+	}
+	if c.User.PasswordPolicy.MinLength < 1 {
+		return fmt.Errorf("user.password_policy.min_length 必须大于 0")
+	}
+	if err := c.Database.validatePool(); err != nil {
+		return err
+	}
+	if c.Server.Mode == ModeRelease {
+		return c.validateRelease()
+	}
+	return nil
+}
+
+// validatePool 校验数据库连接池参数
+func (c *DatabaseConfig) validatePool() error {
+	if c.MaxOpenConns < 0 || c.MaxIdleConns < 0 || c.ConnMaxLifetime < 0 {
+		return fmt.Errorf("database 连接池参数不能为负数")
+	}
+	if c.MaxOpenConns > 0 && c.MaxIdleConns > c.MaxOpenConns {
+		return fmt.Errorf("database.max_idle_conns (%d) 不能大于 database.max_open_conns (%d)", c.MaxIdleConns, c.MaxOpenConns)
+	}
+	return nil
+}
+
+// validateRelease 校验生产环境必需的安全配置，一次返回全部缺失项
+func (c *Config) validateRelease() error {
+	var errs []error
+	if issuer := strings.TrimSpace(c.JWT.Issuer); issuer == "" || issuer == DefaultIssuer {
+		errs = append(errs, fmt.Errorf("release 模式下必须将 jwt.issuer 配置为实际的服务地址"))
+	}
+	if strings.TrimSpace(c.JWT.PrivateKeyPath) == "" && strings.TrimSpace(c.JWT.PrivateKeyPEM) == "" {
+		errs = append(errs, fmt.Errorf("release 模式下必须配置 jwt.private_key_path 或 jwt.private_key_pem"))
+	}
+	switch c.Database.Driver {
+	case "mysql":
+		if c.Database.MySQL.Password == "" {
+			errs = append(errs, fmt.Errorf("release 模式下 database.mysql.password 不能为空"))
+		}
+	case "sqlite":
+		if strings.TrimSpace(c.Database.SQLite.Path) == "" || c.Database.SQLite.Path == SQLiteMemory {
+			errs = append(errs, fmt.Errorf("release 模式下 database.sqlite.path 不能为空或使用内存数据库"))
+		}
+	default:
+		if c.Database.Postgres.Password == "" {
+			errs = append(errs, fmt.Errorf("release 模式下 database.postgres.password 不能为空"))
 		}
 	}
+	return errors.Join(errs...)
+}
 
-	var cfg Config
-	if err := viper.Unmarshal(&cfg); err != nil {
-		return nil, err
+// EnvVar 选择环境配置覆盖文件的环境变量，例如 UAC_ENV=prod 加载 config.prod.yaml
+const EnvVar = "UAC_ENV"
+
+// configSearchPaths 配置文件搜索目录
+var configSearchPaths = []string{"./configs", "."}
+
+// Load 加载配置
+// 基础配置优先使用 config.base.yaml，不存在时使用 config.yaml；
+// 设置 UAC_ENV 时在同一目录下合并 config.<env>.yaml
+// 环境变量优先级最高，格式：UAC_SERVER_ADDR, UAC_DATABASE_DRIVER 等
+func Load() (*Config, error) {
+	base, dir := findBaseConfig()
+	if base == "" {
+		// 配置文件不存在时使用默认值
+		log.Printf("未找到配置文件，使用默认配置")
+		return LoadFiles()
 	}
 
-	// 保存全局配置实例
-	globalConfig = &cfg
+	files := []string{base}
+	if env := os.Getenv(EnvVar); env != "" {
+		overlay := filepath.Join(dir, "config."+env+".yaml")
+		if _, err := os.Stat(overlay); err != nil {
+			return nil, fmt.Errorf("环境配置文件 %s 不存在: %w", overlay, err)
+		}
+		files = append(files, overlay)
+	}
 
-	return &cfg, nil
+	return LoadFiles(files...)
+}
+
+// findBaseConfig 在搜索目录中查找基础配置文件
+func findBaseConfig() (string, string) {
+	for _, dir := range configSearchPaths {
+		for _, name := range []string{"config.base.yaml", "config.yaml"} {
+			path := filepath.Join(dir, name)
+			if _, err := os.Stat(path); err == nil {
+				return path, dir
+			}
+		}
+	}
+	return "", ""
 }
 
 // Get 获取全局配置实例
@@ -127,58 +379,145 @@ func Get() *Config {
 
 // LoadFromFile 从指定路径加载配置文件
 func LoadFromFile(path string) (*Config, error) {
-	viper.SetConfigFile(path)
+	return LoadFiles(path)
+}
+
+// LoadFiles 按顺序加载并深度合并多个配置文件，后面的文件覆盖前面的同名配置
+// 环境变量优先级高于所有配置文件
+func LoadFiles(paths ...string) (*Config, error) {
+	cfg, err := readFiles(paths)
+	if err != nil {
+		return nil, err
+	}
+
+	// 保存全局配置实例
+	globalConfig = cfg
+	loadedFiles = paths
+
+	return cfg, nil
+}
+
+// LoadedFiles 返回最近一次加载的配置文件列表
+func LoadedFiles() []string {
+	return loadedFiles
+}
+
+// readFiles 读取并合并配置文件，不修改全局配置
+func readFiles(paths []string) (*Config, error) {
+	v := viper.New()
 
 	// 支持环境变量覆盖
-	viper.SetEnvPrefix("UAC")
-	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
-	viper.AutomaticEnv()
+	// 环境变量前缀为 UAC，使用下划线分隔
+	// 例如：UAC_SERVER_ADDR 对应 server.addr
+	v.SetEnvPrefix("UAC")
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+	// 私钥内容额外支持更短的环境变量名
+	_ = v.BindEnv("jwt.private_key_pem", "UAC_JWT_PRIVATE_KEY_PEM", "UAC_JWT_PRIVATE_KEY")
 
 	// 设置默认值
-	setDefaults()
+	setDefaults(v)
 
-	if err := viper.ReadInConfig(); err != nil {
-		return nil, err
+	for i, path := range paths {
+		v.SetConfigFile(path)
+		var err error
+		if i == 0 {
+			err = v.ReadInConfig()
+		} else {
+			err = v.MergeInConfig()
+		}
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("加载配置文件: %s", path)
 	}
 
 	var cfg Config
-	if err := viper.Unmarshal(&cfg); err != nil {
+	if err := v.Unmarshal(&cfg); err != nil {
 		return nil, err
 	}
 
-	globalConfig = &cfg
+	// issuer 去掉尾部斜杠，避免拼接端点地址时出现双斜杠，且与令牌 iss 保持一致
+	cfg.JWT.Issuer = strings.TrimRight(cfg.JWT.Issuer, "/")
 	return &cfg, nil
 }
 
 // setDefaults 设置默认值
-func setDefaults() {
+func setDefaults(v *viper.Viper) {
 	// 服务器默认配置
-	viper.SetDefault("server.addr", ":8080")
-	viper.SetDefault("server.mode", "debug")
-	viper.SetDefault("server.read_timeout", "10s")
-	viper.SetDefault("server.write_timeout", "10s")
+	v.SetDefault("server.addr", ":8080")
+	v.SetDefault("server.mode", "debug")
+	v.SetDefault("server.read_timeout", "10s")
+	v.SetDefault("server.write_timeout", "10s")
+	v.SetDefault("server.maintenance", false)
+	v.SetDefault("server.max_concurrent_requests", 0)
+	v.SetDefault("server.max_body_bytes", 1<<20)
 
 	// 数据库默认配置
-	viper.SetDefault("database.driver", "postgres")
-	viper.SetDefault("database.postgres.host", "localhost")
-	viper.SetDefault("database.postgres.port", 5432)
-	viper.SetDefault("database.postgres.user", "postgres")
-	viper.SetDefault("database.postgres.password", "")
-	viper.SetDefault("database.postgres.dbname", "unified_auth")
-	viper.SetDefault("database.postgres.sslmode", "disable")
+	v.SetDefault("database.driver", "postgres")
+	v.SetDefault("database.postgres.host", "localhost")
+	v.SetDefault("database.postgres.port", 5432)
+	v.SetDefault("database.postgres.user", "postgres")
+	v.SetDefault("database.postgres.password", "")
+	v.SetDefault("database.postgres.dbname", "unified_auth")
+	v.SetDefault("database.postgres.sslmode", "disable")
+	v.SetDefault("database.sqlite.path", "uac.db")
+	v.SetDefault("database.max_open_conns", 100)
+	v.SetDefault("database.max_idle_conns", 10)
+	v.SetDefault("database.conn_max_lifetime", "1h")
+	v.SetDefault("database.slow_query_threshold", "200ms")
 
 	// Redis 默认配置
-	viper.SetDefault("redis.addr", "localhost:6379")
-	viper.SetDefault("redis.password", "")
-	viper.SetDefault("redis.db", 0)
+	v.SetDefault("redis.addr", "localhost:6379")
+	v.SetDefault("redis.password", "")
+	v.SetDefault("redis.db", 0)
 
 	// JWT 默认配置
-	viper.SetDefault("jwt.issuer", "unified-auth-center")
-	viper.SetDefault("jwt.access_expiry", "2h")
-	viper.SetDefault("jwt.refresh_expiry", "168h")
+	v.SetDefault("jwt.algorithm", "RS256")
+	v.SetDefault("jwt.issuer", DefaultIssuer)
+	v.SetDefault("jwt.access_expiry", "2h")
+	v.SetDefault("jwt.refresh_expiry", "168h")
+	v.SetDefault("jwt.leeway", "30s")
 
 	// 静态文件默认配置
-	viper.SetDefault("static.enabled", true)
-	viper.SetDefault("static.mode", "embed")
-	viper.SetDefault("static.path", "./web/dist")
+	v.SetDefault("static.enabled", true)
+	v.SetDefault("static.mode", "embed")
+	v.SetDefault("static.path", "./web/dist")
+
+	// 限流默认配置
+	v.SetDefault("rate_limit.enabled", true)
+	v.SetDefault("rate_limit.window", "1m")
+	v.SetDefault("rate_limit.limit", 10)
+
+	// 日志默认配置
+	v.SetDefault("log.level", "info")
+
+	// 跨域默认配置
+	v.SetDefault("cors.allow_credentials", true)
+
+	// 用户默认配置
+	v.SetDefault("user.allow_duplicate_phone", false)
+	v.SetDefault("user.default_country_code", "86")
+	v.SetDefault("user.password_history", 5)
+	v.SetDefault("user.password_policy.min_length", 8)
+	v.SetDefault("user.password_policy.require_upper", true)
+	v.SetDefault("user.password_policy.require_lower", true)
+	v.SetDefault("user.password_policy.require_digit", true)
+	v.SetDefault("user.password_policy.require_special", false)
+
+	// OAuth 默认配置：默认兼容 plain 方式的 PKCE
+	v.SetDefault("oauth.require_pkce_s256", false)
+	v.SetDefault("oauth.require_refresh_grant_for_offline_access", true)
+	v.SetDefault("oauth.require_consent", true)
+
+	// 会话默认配置
+	v.SetDefault("session.max_active", 0)
+	v.SetDefault("session.limit_policy", "evict_oldest")
+
+	// 未登录账户自动禁用默认关闭
+	v.SetDefault("inactivity.enabled", false)
+	v.SetDefault("inactivity.days", 90)
+	v.SetDefault("inactivity.interval", "24h")
+	v.SetDefault("inactivity.dry_run", false)
+	v.SetDefault("inactivity.exempt_super_admins", true)
 }
@@ -0,0 +1,114 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+)
+
+// validLogLevels 支持的日志级别
+var validLogLevels = map[string]bool{"debug": true, "info": true, "warn": true, "error": true}
+
+// validateReloadable 校验可热更新部分的配置
+func (c *Config) validateReloadable() error {
+	if !validLogLevels[strings.ToLower(c.Log.Level)] {
+		return fmt.Errorf("无效的日志级别: %q", c.Log.Level)
+	}
+	if c.RateLimit.Enabled {
+		if c.RateLimit.Window <= 0 {
+			return fmt.Errorf("限流窗口必须大于 0: %s", c.RateLimit.Window)
+		}
+		if c.RateLimit.Limit <= 0 {
+			return fmt.Errorf("限流阈值必须大于 0: %d", c.RateLimit.Limit)
+		}
+	}
+	for _, origin := range c.CORS.AllowedOrigins {
+		if strings.TrimSpace(origin) == "" {
+			return fmt.Errorf("跨域来源不能为空")
+		}
+	}
+	return nil
+}
+
+// Reloader 配置热更新器
+// 仅日志级别、限流、跨域和维护模式可热更新，数据库、Redis、密钥等保持启动时的值
+type Reloader struct {
+	files    []string
+	current  atomic.Pointer[Config]
+	mu       sync.Mutex
+	handlers []func(*Config)
+}
+
+// NewReloader 创建配置热更新器，files 为需要重新读取的配置文件
+func NewReloader(files []string, cfg *Config) *Reloader {
+	r := &Reloader{files: files}
+	r.current.Store(cfg)
+	return r
+}
+
+// Current 获取当前生效的配置
+func (r *Reloader) Current() *Config {
+	return r.current.Load()
+}
+
+// OnReload 注册配置更新后的回调
+func (r *Reloader) OnReload(fn func(*Config)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers = append(r.handlers, fn)
+}
+
+// Reload 重新读取配置文件，校验通过后替换可热更新的配置
+// 校验失败时保留当前配置并返回错误
+func (r *Reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	loaded, err := readFiles(r.files)
+	if err != nil {
+		return fmt.Errorf("读取配置失败: %w", err)
+	}
+	if err := loaded.Validate(); err != nil {
+		return err
+	}
+
+	next := *r.current.Load()
+	next.Log = loaded.Log
+	next.RateLimit = loaded.RateLimit
+	next.CORS = loaded.CORS
+	next.Server.Maintenance = loaded.Server.Maintenance
+	r.current.Store(&next)
+
+	for _, fn := range r.handlers {
+		fn(&next)
+	}
+	return nil
+}
+
+// WatchSignals 收到 SIGHUP 时重新加载配置，ctx 结束后停止监听
+func (r *Reloader) WatchSignals(ctx context.Context) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+
+	go func() {
+		defer signal.Stop(ch)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ch:
+				if err := r.Reload(); err != nil {
+					log.Printf("重新加载配置失败，保持当前配置: %v", err)
+					continue
+				}
+				log.Printf("配置已重新加载: %s", strings.Join(r.files, ", "))
+			}
+		}
+	}()
+}
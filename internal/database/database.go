@@ -1,14 +1,16 @@
 package database
 
 import (
+	"database/sql"
 	"fmt"
 	"time"
 
 	"github.com/pu-ac-cn/uac-backend/internal/config"
+	"github.com/pu-ac-cn/uac-backend/internal/middleware"
 	"gorm.io/driver/mysql"
 	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
 )
 
 var db *gorm.DB
@@ -42,13 +44,15 @@ func Init(cfg *config.DatabaseConfig) error {
 			cfg.MySQL.Loc,
 		)
 		dialector = mysql.Open(dsn)
+	case "sqlite":
+		dialector = sqlite.Open(SQLiteDSN(cfg.SQLite.Path))
 	default:
 		return fmt.Errorf("不支持的数据库驱动: %s", cfg.Driver)
 	}
 
 	var err error
 	db, err = gorm.Open(dialector, &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
+		Logger: newGormLogger(middleware.GetLogger(), cfg.SlowQueryThreshold),
 	})
 	if err != nil {
 		return fmt.Errorf("连接数据库失败: %w", err)
@@ -60,14 +64,61 @@ func Init(cfg *config.DatabaseConfig) error {
 		return fmt.Errorf("获取数据库连接池失败: %w", err)
 	}
 
-	// 设置连接池参数
-	sqlDB.SetMaxIdleConns(10)           // 最大空闲连接数
-	sqlDB.SetMaxOpenConns(100)          // 最大打开连接数
-	sqlDB.SetConnMaxLifetime(time.Hour) // 连接最大生命周期
+	configurePool(sqlDB, cfg)
 
 	return nil
 }
 
+// 连接池默认参数
+const (
+	DefaultMaxOpenConns    = 100
+	DefaultMaxIdleConns    = 10
+	DefaultConnMaxLifetime = time.Hour
+)
+
+// configurePool 按配置设置连接池参数，未配置的参数使用默认值
+func configurePool(sqlDB *sql.DB, cfg *config.DatabaseConfig) {
+	maxOpen, maxIdle, lifetime := cfg.MaxOpenConns, cfg.MaxIdleConns, cfg.ConnMaxLifetime
+	if maxOpen == 0 {
+		maxOpen = DefaultMaxOpenConns
+	}
+	if maxIdle == 0 {
+		maxIdle = DefaultMaxIdleConns
+	}
+	if lifetime == 0 {
+		lifetime = DefaultConnMaxLifetime
+	}
+	if cfg.Driver == "sqlite" {
+		// 共享缓存的内存数据库在最后一个连接关闭时销毁，连接不过期
+		lifetime = 0
+	}
+
+	sqlDB.SetMaxOpenConns(maxOpen)
+	sqlDB.SetMaxIdleConns(maxIdle)
+	sqlDB.SetConnMaxLifetime(lifetime)
+}
+
+// Stats 返回连接池统计信息
+func Stats() (sql.DBStats, error) {
+	if db == nil {
+		return sql.DBStats{}, fmt.Errorf("数据库未初始化")
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return sql.DBStats{}, err
+	}
+	return sqlDB.Stats(), nil
+}
+
+// SQLiteDSN 根据数据库路径生成 SQLite 连接串，开启外键约束和忙等待
+// ":memory:" 使用共享缓存，使连接池中的所有连接访问同一个内存数据库
+func SQLiteDSN(path string) string {
+	if path == "" || path == config.SQLiteMemory {
+		return "file::memory:?cache=shared&_foreign_keys=1&_busy_timeout=5000"
+	}
+	return "file:" + path + "?_foreign_keys=1&_busy_timeout=5000"
+}
+
 // GetDB 获取数据库实例
 func GetDB() *gorm.DB {
 	return db
@@ -1,9 +1,11 @@
 package database
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/pu-ac-cn/uac-backend/internal/config"
+	"github.com/pu-ac-cn/uac-backend/internal/model"
 )
 
 // 测试用的数据库配置
@@ -169,3 +171,86 @@ func TestAutoMigrateNotInitialized(t *testing.T) {
 		t.Error("期望返回错误，但没有")
 	}
 }
+
+// TestInitSQLite 测试 SQLite 内存数据库初始化并迁移全部模型
+func TestInitSQLite(t *testing.T) {
+	cfg := &config.DatabaseConfig{
+		Driver: "sqlite",
+		SQLite: config.SQLiteConfig{Path: config.SQLiteMemory},
+	}
+	if err := Init(cfg); err != nil {
+		t.Fatalf("初始化 SQLite 失败: %v", err)
+	}
+	defer Close()
+
+	if err := Ping(); err != nil {
+		t.Errorf("Ping 失败: %v", err)
+	}
+
+	// char(36)、json 等类型在 SQLite 下也能正常迁移
+	if err := AutoMigrate(
+		&model.User{},
+		&model.Organization{},
+		&model.Application{},
+		&model.UserOrgBinding{},
+		&model.Role{},
+		&model.Permission{},
+		&model.UserRole{},
+		&model.ApplicationRole{},
+		&model.RolePermission{},
+		&model.AuditLog{},
+	); err != nil {
+		t.Fatalf("AutoMigrate 失败: %v", err)
+	}
+
+	user := &model.User{Username: "alice", Email: "alice@example.com"}
+	if err := GetDB().Create(user).Error; err != nil {
+		t.Fatalf("写入用户失败: %v", err)
+	}
+	var got model.User
+	if err := GetDB().First(&got, "id = ?", user.ID).Error; err != nil {
+		t.Fatalf("读取用户失败: %v", err)
+	}
+	if got.Username != "alice" {
+		t.Errorf("Username = %s, 期望 alice", got.Username)
+	}
+}
+
+// TestInitPoolConfig 测试连接池参数按配置生效，未配置时使用默认值
+func TestInitPoolConfig(t *testing.T) {
+	cfg := &config.DatabaseConfig{
+		Driver:       "sqlite",
+		SQLite:       config.SQLiteConfig{Path: config.SQLiteMemory},
+		MaxOpenConns: 5,
+	}
+	if err := Init(cfg); err != nil {
+		t.Fatalf("初始化 SQLite 失败: %v", err)
+	}
+	stats, err := Stats()
+	if err != nil {
+		t.Fatalf("Stats 失败: %v", err)
+	}
+	if stats.MaxOpenConnections != 5 {
+		t.Errorf("MaxOpenConnections = %d, 期望 5", stats.MaxOpenConnections)
+	}
+	Close()
+
+	cfg.MaxOpenConns = 0
+	if err := Init(cfg); err != nil {
+		t.Fatalf("初始化 SQLite 失败: %v", err)
+	}
+	defer Close()
+	if stats, _ := Stats(); stats.MaxOpenConnections != DefaultMaxOpenConns {
+		t.Errorf("MaxOpenConnections = %d, 期望默认值 %d", stats.MaxOpenConnections, DefaultMaxOpenConns)
+	}
+}
+
+// TestSQLiteDSN 测试 SQLite 连接串
+func TestSQLiteDSN(t *testing.T) {
+	if dsn := SQLiteDSN(config.SQLiteMemory); !strings.HasPrefix(dsn, "file::memory:?cache=shared") {
+		t.Errorf("内存数据库连接串错误: %s", dsn)
+	}
+	if dsn := SQLiteDSN("data/uac.db"); !strings.HasPrefix(dsn, "file:data/uac.db?") {
+		t.Errorf("文件数据库连接串错误: %s", dsn)
+	}
+}
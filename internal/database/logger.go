@@ -0,0 +1,89 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pu-ac-cn/uac-backend/internal/middleware"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// gormLogger 将 GORM 日志写入项目的 zap logger
+// SQL 错误记录为 error，慢查询记录为 warn，其余 SQL 记录为 debug，均带上 context 中的请求 ID
+type gormLogger struct {
+	log           *zap.Logger
+	level         logger.LogLevel
+	slowThreshold time.Duration
+}
+
+// newGormLogger 创建 GORM 日志适配器，slowThreshold 为 0 时不记录慢查询
+func newGormLogger(log *zap.Logger, slowThreshold time.Duration) logger.Interface {
+	return &gormLogger{
+		log:           log,
+		level:         logger.Info,
+		slowThreshold: slowThreshold,
+	}
+}
+
+func (l *gormLogger) LogMode(level logger.LogLevel) logger.Interface {
+	clone := *l
+	clone.level = level
+	return &clone
+}
+
+func (l *gormLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= logger.Info {
+		l.log.Info(fmt.Sprintf(msg, args...), requestIDField(ctx))
+	}
+}
+
+func (l *gormLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= logger.Warn {
+		l.log.Warn(fmt.Sprintf(msg, args...), requestIDField(ctx))
+	}
+}
+
+func (l *gormLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= logger.Error {
+		l.log.Error(fmt.Sprintf(msg, args...), requestIDField(ctx))
+	}
+}
+
+func (l *gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if l.level <= logger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	switch {
+	// 记录不存在属于正常的业务分支，由调用方处理
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound) && l.level >= logger.Error:
+		sql, rows := fc()
+		l.log.Error("SQL 执行失败", sqlFields(ctx, sql, rows, elapsed, zap.Error(err))...)
+	case l.slowThreshold > 0 && elapsed > l.slowThreshold && l.level >= logger.Warn:
+		sql, rows := fc()
+		l.log.Warn("慢查询", sqlFields(ctx, sql, rows, elapsed, zap.Duration("threshold", l.slowThreshold))...)
+	case l.level >= logger.Info && l.log.Core().Enabled(zap.DebugLevel):
+		sql, rows := fc()
+		l.log.Debug("SQL", sqlFields(ctx, sql, rows, elapsed)...)
+	}
+}
+
+// requestIDField 请求 ID 日志字段，不在请求中执行（如迁移、定时任务）时为空
+func requestIDField(ctx context.Context) zap.Field {
+	return zap.String("request_id", middleware.RequestIDFromContext(ctx))
+}
+
+// sqlFields SQL 日志的公共字段
+func sqlFields(ctx context.Context, sql string, rows int64, elapsed time.Duration, extra ...zap.Field) []zap.Field {
+	return append([]zap.Field{
+		requestIDField(ctx),
+		zap.String("sql", sql),
+		zap.Int64("rows", rows),
+		zap.Duration("duration", elapsed),
+	}, extra...)
+}
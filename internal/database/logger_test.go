@@ -0,0 +1,109 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pu-ac-cn/uac-backend/internal/config"
+	"github.com/pu-ac-cn/uac-backend/internal/middleware"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// openObservedSQLite 打开使用可观察 logger 的 SQLite 内存数据库
+func openObservedSQLite(t *testing.T, level zapcore.Level, slowThreshold time.Duration) (*gorm.DB, *observer.ObservedLogs) {
+	core, logs := observer.New(level)
+	gdb, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{
+		Logger: newGormLogger(zap.New(core), slowThreshold),
+	})
+	if err != nil {
+		t.Fatalf("打开 SQLite 失败: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := gdb.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return gdb, logs
+}
+
+// TestGormLoggerSlowQuery 测试超过阈值的查询记录为慢查询并带上请求 ID
+func TestGormLoggerSlowQuery(t *testing.T) {
+	gdb, logs := openObservedSQLite(t, zapcore.InfoLevel, time.Nanosecond)
+
+	ctx := middleware.WithRequestID(context.Background(), "req-slow")
+	var n int
+	if err := gdb.WithContext(ctx).Raw("SELECT 1").Scan(&n).Error; err != nil {
+		t.Fatalf("查询失败: %v", err)
+	}
+
+	entries := logs.FilterMessage("慢查询").All()
+	if len(entries) != 1 {
+		t.Fatalf("期望 1 条慢查询日志, 实际 %d", len(entries))
+	}
+	fields := entries[0].ContextMap()
+	if fields["request_id"] != "req-slow" {
+		t.Errorf("request_id = %v, 期望 req-slow", fields["request_id"])
+	}
+	if fields["sql"] != "SELECT 1" {
+		t.Errorf("sql = %v, 期望 SELECT 1", fields["sql"])
+	}
+	if entries[0].Level != zapcore.WarnLevel {
+		t.Errorf("慢查询日志级别 = %s, 期望 warn", entries[0].Level)
+	}
+}
+
+// TestGormLoggerError 测试 SQL 错误记录为 error，记录不存在不视为错误，未超过阈值的查询不记录
+func TestGormLoggerError(t *testing.T) {
+	gdb, logs := openObservedSQLite(t, zapcore.InfoLevel, time.Hour)
+	ctx := middleware.WithRequestID(context.Background(), "req-error")
+
+	if err := gdb.WithContext(ctx).Exec("SELECT * FROM missing_table").Error; err == nil {
+		t.Fatal("期望查询不存在的表返回错误")
+	}
+	// 记录不存在
+	type logTestRecord struct{ ID int }
+	if err := gdb.AutoMigrate(&logTestRecord{}); err != nil {
+		t.Fatalf("建表失败: %v", err)
+	}
+	if err := gdb.WithContext(ctx).First(&logTestRecord{}).Error; !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("期望 ErrRecordNotFound, 实际 %v", err)
+	}
+
+	entries := logs.All()
+	if len(entries) != 1 {
+		t.Fatalf("期望只记录 1 条 SQL 错误日志, 实际 %d: %+v", len(entries), entries)
+	}
+	if entries[0].Message != "SQL 执行失败" || entries[0].Level != zapcore.ErrorLevel {
+		t.Errorf("日志 = %s/%s, 期望 SQL 执行失败/error", entries[0].Message, entries[0].Level)
+	}
+	if entries[0].ContextMap()["request_id"] != "req-error" {
+		t.Errorf("request_id = %v, 期望 req-error", entries[0].ContextMap()["request_id"])
+	}
+}
+
+// TestInitUsesConfiguredSlowThreshold 测试 Init 使用配置的慢查询阈值
+func TestInitUsesConfiguredSlowThreshold(t *testing.T) {
+	cfg := &config.DatabaseConfig{
+		Driver:             "sqlite",
+		SQLite:             config.SQLiteConfig{Path: config.SQLiteMemory},
+		SlowQueryThreshold: 150 * time.Millisecond,
+	}
+	if err := Init(cfg); err != nil {
+		t.Fatalf("初始化 SQLite 失败: %v", err)
+	}
+	defer Close()
+
+	l, ok := GetDB().Logger.(*gormLogger)
+	if !ok {
+		t.Fatalf("GORM logger 类型为 %T, 期望 *gormLogger", GetDB().Logger)
+	}
+	if l.slowThreshold != 150*time.Millisecond {
+		t.Errorf("slowThreshold = %s, 期望 150ms", l.slowThreshold)
+	}
+}
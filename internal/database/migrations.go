@@ -0,0 +1,162 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/pu-ac-cn/uac-backend/internal/model"
+	"gorm.io/gorm"
+)
+
+// Migration 版本化迁移，按 Version 升序执行且每个版本只执行一次
+// AutoMigrate 只能新增表和列，修改约束、清洗数据等变更通过迁移完成
+type Migration struct {
+	Version int64                // 版本号，递增且不可修改
+	Name    string               // 迁移说明
+	Up      func(*gorm.DB) error // 迁移逻辑，在事务中执行
+}
+
+// SchemaMigration 已执行的迁移版本记录
+type SchemaMigration struct {
+	Version   int64     `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"size:255"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+// TableName 指定表名
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// MigrationReport 迁移执行结果
+type MigrationReport struct {
+	Applied []Migration // 本次执行的迁移
+	Skipped []Migration // 此前已执行而跳过的迁移
+}
+
+// Migrations 正式迁移列表，新增迁移追加到末尾并使用更大的版本号
+var Migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "applications.org_id 允许为 NULL（系统级应用）",
+		Up:      migrateApplicationOrgIDNullable,
+	},
+	{
+		Version: 2,
+		Name:    "audit_logs 目标索引加入 created_at",
+		Up:      migrateAuditLogTargetIndex,
+	},
+	{
+		Version: 3,
+		Name:    "users 用户名和邮箱唯一索引排除已删除用户",
+		Up:      migrateUserUniqueIndexes,
+	},
+}
+
+// Migrate 在当前数据库上执行全部正式迁移
+func Migrate() (*MigrationReport, error) {
+	if db == nil {
+		return nil, fmt.Errorf("数据库未初始化")
+	}
+	return RunMigrations(db, Migrations)
+}
+
+// RunMigrations 按版本号顺序执行尚未执行的迁移，并记录到 schema_migrations 表
+// 某个迁移失败时停止执行，之前成功的迁移已记录，修复后重新运行会从失败的版本继续
+func RunMigrations(db *gorm.DB, migrations []Migration) (*MigrationReport, error) {
+	if err := db.AutoMigrate(&SchemaMigration{}); err != nil {
+		return nil, fmt.Errorf("创建 schema_migrations 表失败: %w", err)
+	}
+
+	var versions []int64
+	if err := db.Model(&SchemaMigration{}).Pluck("version", &versions).Error; err != nil {
+		return nil, fmt.Errorf("读取已执行的迁移失败: %w", err)
+	}
+	applied := make(map[int64]bool, len(versions))
+	for _, v := range versions {
+		applied[v] = true
+	}
+
+	sorted := make([]Migration, len(migrations))
+	copy(sorted, migrations)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i-1].Version == sorted[i].Version {
+			return nil, fmt.Errorf("迁移版本号重复: %d", sorted[i].Version)
+		}
+	}
+
+	report := &MigrationReport{}
+	for _, m := range sorted {
+		if applied[m.Version] {
+			report.Skipped = append(report.Skipped, m)
+			continue
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return report, fmt.Errorf("执行迁移 %d（%s）失败: %w", m.Version, m.Name, err)
+		}
+		report.Applied = append(report.Applied, m)
+	}
+	return report, nil
+}
+
+// migrateApplicationOrgIDNullable 去掉 applications.org_id 的非空约束
+// 旧版本建表时 org_id 为 NOT NULL，系统级应用需要存储 NULL
+func migrateApplicationOrgIDNullable(tx *gorm.DB) error {
+	switch tx.Dialector.Name() {
+	case "postgres":
+		return tx.Exec("ALTER TABLE applications ALTER COLUMN org_id DROP NOT NULL").Error
+	case "mysql":
+		return tx.Exec("ALTER TABLE applications MODIFY COLUMN org_id char(36) NULL").Error
+	default:
+		// SQLite 由 AutoMigrate 建表，org_id 本身允许为 NULL
+		return nil
+	}
+}
+
+// migrateAuditLogTargetIndex 删除旧的 (resource, resource_id) 索引
+// AutoMigrate 已按新定义创建 (resource, resource_id, created_at) 索引，旧索引是其前缀，保留只会增加写入开销
+func migrateAuditLogTargetIndex(tx *gorm.DB) error {
+	const oldIndex = "idx_audit_logs_target"
+	if !tx.Migrator().HasIndex("audit_logs", oldIndex) {
+		return nil
+	}
+	return tx.Migrator().DropIndex("audit_logs", oldIndex)
+}
+
+// migrateUserUniqueIndexes 将 users 的用户名、邮箱唯一索引重建为只约束未删除的用户
+// 索引名不变，AutoMigrate 不会重建已存在的同名索引，需在此删除后按新定义创建
+// MySQL 不支持部分索引，改用函数索引（需 MySQL 8.0.13+），已删除用户的索引值为 NULL 不参与唯一约束
+func migrateUserUniqueIndexes(tx *gorm.DB) error {
+	if !tx.Migrator().HasTable(&model.User{}) {
+		return nil
+	}
+	for _, column := range []string{"username", "email"} {
+		index := "idx_users_" + column
+		if tx.Migrator().HasIndex(&model.User{}, index) {
+			if err := tx.Migrator().DropIndex(&model.User{}, index); err != nil {
+				return err
+			}
+		}
+		if tx.Dialector.Name() == "mysql" {
+			sql := fmt.Sprintf("CREATE UNIQUE INDEX %s ON users ((CASE WHEN deleted_at IS NULL THEN %s END))", index, column)
+			if err := tx.Exec(sql).Error; err != nil {
+				return err
+			}
+			continue
+		}
+		if err := tx.Migrator().CreateIndex(&model.User{}, index); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package database
+
+import (
+	"errors"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func openTestSQLite(t *testing.T) *gorm.DB {
+	d, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{
+		Logger: logger.Discard,
+	})
+	if err != nil {
+		t.Fatalf("打开 SQLite 失败: %v", err)
+	}
+	sqlDB, _ := d.DB()
+	t.Cleanup(func() { sqlDB.Close() })
+	return d
+}
+
+// TestRunMigrations 测试迁移按版本顺序执行且只执行一次
+func TestRunMigrations(t *testing.T) {
+	d := openTestSQLite(t)
+
+	var order []int64
+	record := func(v int64) func(*gorm.DB) error {
+		return func(*gorm.DB) error {
+			order = append(order, v)
+			return nil
+		}
+	}
+	migrations := []Migration{
+		{Version: 2, Name: "second", Up: record(2)},
+		{Version: 1, Name: "first", Up: record(1)},
+	}
+
+	report, err := RunMigrations(d, migrations)
+	if err != nil {
+		t.Fatalf("RunMigrations 失败: %v", err)
+	}
+	if len(order) != 2 || order[0] != 1 || order[1] != 2 {
+		t.Errorf("执行顺序 = %v, 期望 [1 2]", order)
+	}
+	if len(report.Applied) != 2 || len(report.Skipped) != 0 {
+		t.Errorf("首次执行 applied=%d skipped=%d", len(report.Applied), len(report.Skipped))
+	}
+
+	// 再次执行时全部跳过，新增的版本才会执行
+	migrations = append(migrations, Migration{Version: 3, Name: "third", Up: record(3)})
+	report, err = RunMigrations(d, migrations)
+	if err != nil {
+		t.Fatalf("RunMigrations 失败: %v", err)
+	}
+	if len(order) != 3 || order[2] != 3 {
+		t.Errorf("执行顺序 = %v, 期望 [1 2 3]", order)
+	}
+	if len(report.Applied) != 1 || len(report.Skipped) != 2 {
+		t.Errorf("再次执行 applied=%d skipped=%d", len(report.Applied), len(report.Skipped))
+	}
+
+	var count int64
+	d.Model(&SchemaMigration{}).Count(&count)
+	if count != 3 {
+		t.Errorf("schema_migrations 记录数 = %d, 期望 3", count)
+	}
+}
+
+// TestRunMigrations_Failure 测试迁移失败时不记录版本且停止执行后续迁移
+func TestRunMigrations_Failure(t *testing.T) {
+	d := openTestSQLite(t)
+
+	failing := errors.New("boom")
+	ran := false
+	migrations := []Migration{
+		{Version: 1, Name: "fail", Up: func(*gorm.DB) error { return failing }},
+		{Version: 2, Name: "after", Up: func(*gorm.DB) error { ran = true; return nil }},
+	}
+	if _, err := RunMigrations(d, migrations); !errors.Is(err, failing) {
+		t.Fatalf("期望返回迁移错误, 实际 %v", err)
+	}
+	if ran {
+		t.Error("失败后不应继续执行后续迁移")
+	}
+
+	var count int64
+	d.Model(&SchemaMigration{}).Count(&count)
+	if count != 0 {
+		t.Errorf("失败的迁移不应记录, 实际记录数 %d", count)
+	}
+
+	// 重复的版本号在执行前报错
+	dup := []Migration{
+		{Version: 5, Name: "a", Up: func(*gorm.DB) error { return nil }},
+		{Version: 5, Name: "b", Up: func(*gorm.DB) error { return nil }},
+	}
+	if _, err := RunMigrations(d, dup); err == nil {
+		t.Error("重复版本号期望返回错误")
+	}
+	d.Model(&SchemaMigration{}).Count(&count)
+	if count != 0 {
+		t.Errorf("版本号重复时不应执行任何迁移, 实际记录数 %d", count)
+	}
+}
+
+// TestMigrations_SQLite 测试正式迁移在 SQLite 上可执行
+func TestMigrations_SQLite(t *testing.T) {
+	d := openTestSQLite(t)
+	if _, err := RunMigrations(d, Migrations); err != nil {
+		t.Fatalf("正式迁移失败: %v", err)
+	}
+}
+
+// TestMigrations_AuditLogTargetIndex 测试删除旧的审计日志目标索引
+func TestMigrations_AuditLogTargetIndex(t *testing.T) {
+	d := openTestSQLite(t)
+	if err := d.Exec("CREATE TABLE audit_logs (id TEXT PRIMARY KEY, resource TEXT, resource_id TEXT, created_at DATETIME)").Error; err != nil {
+		t.Fatalf("建表失败: %v", err)
+	}
+	if err := d.Exec("CREATE INDEX idx_audit_logs_target ON audit_logs (resource, resource_id)").Error; err != nil {
+		t.Fatalf("创建旧索引失败: %v", err)
+	}
+
+	if _, err := RunMigrations(d, Migrations); err != nil {
+		t.Fatalf("正式迁移失败: %v", err)
+	}
+	if d.Migrator().HasIndex("audit_logs", "idx_audit_logs_target") {
+		t.Error("旧的目标索引应被删除")
+	}
+}
+
+// TestMigrations_UserUniqueIndexes 测试用户名和邮箱唯一索引重建后不再约束已删除用户
+func TestMigrations_UserUniqueIndexes(t *testing.T) {
+	d := openTestSQLite(t)
+	if err := d.Exec("CREATE TABLE users (id TEXT PRIMARY KEY, username TEXT, email TEXT, deleted_at DATETIME)").Error; err != nil {
+		t.Fatalf("建表失败: %v", err)
+	}
+	for _, column := range []string{"username", "email"} {
+		if err := d.Exec("CREATE UNIQUE INDEX idx_users_" + column + " ON users (" + column + ")").Error; err != nil {
+			t.Fatalf("创建旧索引失败: %v", err)
+		}
+	}
+	if err := d.Exec("INSERT INTO users (id, username, email, deleted_at) VALUES ('u1', 'alice', 'alice@example.com', CURRENT_TIMESTAMP)").Error; err != nil {
+		t.Fatalf("插入已删除用户失败: %v", err)
+	}
+
+	if _, err := RunMigrations(d, Migrations); err != nil {
+		t.Fatalf("正式迁移失败: %v", err)
+	}
+
+	insert := "INSERT INTO users (id, username, email) VALUES (?, 'alice', 'alice@example.com')"
+	if err := d.Exec(insert, "u2").Error; err != nil {
+		t.Errorf("已删除用户的用户名和邮箱应可重新使用: %v", err)
+	}
+	if err := d.Exec(insert, "u3").Error; err == nil {
+		t.Error("未删除用户之间的用户名和邮箱仍应唯一")
+	}
+}
@@ -0,0 +1,109 @@
+// Package feature 功能开关
+// 用于按部署切换行为，并对仍在使用的已弃用行为给出提示
+package feature
+
+import (
+	"log"
+	"sort"
+	"sync"
+)
+
+// 已注册的功能开关
+const (
+	AllowPlainPKCE     = "allow_plain_pkce"     // 允许 plain 方式的 PKCE
+	AllowOAuth20       = "allow_oauth_2_0"      // 允许 OAuth 2.0 模式的应用
+	AllowHTTPRedirects = "allow_http_redirects" // 允许非本机的 http 回调地址
+)
+
+// Flag 功能开关定义
+type Flag struct {
+	Name        string
+	Default     bool   // 配置未指定时的取值
+	Deprecated  bool   // 开启时使用该行为会输出弃用警告
+	Description string // 弃用警告中的说明
+}
+
+// registry 全部功能开关，默认值保持现有行为
+var registry = map[string]Flag{
+	AllowPlainPKCE: {
+		Name:        AllowPlainPKCE,
+		Default:     true,
+		Deprecated:  true,
+		Description: "plain 方式的 PKCE 不能防止授权码截获，请改用 S256",
+	},
+	AllowOAuth20: {
+		Name:        AllowOAuth20,
+		Default:     true,
+		Deprecated:  true,
+		Description: "OAuth 2.0 模式不强制 PKCE，请将应用切换到 OAuth 2.1",
+	},
+	AllowHTTPRedirects: {
+		Name:        AllowHTTPRedirects,
+		Default:     true,
+		Deprecated:  true,
+		Description: "非本机的 http 回调地址可能泄露授权码，请改用 https",
+	},
+}
+
+// Registered 返回全部已注册的功能开关名称
+func Registered() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Flags 功能开关取值
+type Flags struct {
+	values map[string]bool
+	warn   func(msg string)
+	warned sync.Map
+}
+
+// New 根据配置创建功能开关，未配置的开关使用默认值
+// warn 可选，用于输出弃用警告，默认写入标准日志
+func New(values map[string]bool, warn ...func(msg string)) *Flags {
+	f := &Flags{values: make(map[string]bool, len(registry))}
+	if len(warn) > 0 && warn[0] != nil {
+		f.warn = warn[0]
+	} else {
+		f.warn = func(msg string) { log.Print(msg) }
+	}
+
+	for name, flag := range registry {
+		f.values[name] = flag.Default
+	}
+	for name, enabled := range values {
+		if _, ok := registry[name]; !ok {
+			f.warn("未知的功能开关: " + name)
+			continue
+		}
+		f.values[name] = enabled
+	}
+	return f
+}
+
+// Enabled 检查功能开关是否开启，nil 视为全部使用默认值
+func (f *Flags) Enabled(name string) bool {
+	if f == nil {
+		return registry[name].Default
+	}
+	return f.values[name]
+}
+
+// Use 在即将执行受开关控制的行为时调用，返回是否允许
+// 允许且该行为已弃用时，每个开关只输出一次警告
+func (f *Flags) Use(name string) bool {
+	if !f.Enabled(name) {
+		return false
+	}
+	flag := registry[name]
+	if f != nil && flag.Deprecated {
+		if _, loaded := f.warned.LoadOrStore(name, true); !loaded {
+			f.warn("使用了已弃用的行为 " + name + "：" + flag.Description)
+		}
+	}
+	return true
+}
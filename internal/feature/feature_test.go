@@ -0,0 +1,53 @@
+package feature
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordWarnings 返回记录警告的函数和已记录的警告
+func recordWarnings() (func(string), *[]string) {
+	var warnings []string
+	return func(msg string) { warnings = append(warnings, msg) }, &warnings
+}
+
+func TestFlags_Defaults(t *testing.T) {
+	f := New(nil)
+	for _, name := range Registered() {
+		assert.Equal(t, registry[name].Default, f.Enabled(name), name)
+	}
+
+	// nil 与默认值一致
+	var nilFlags *Flags
+	assert.True(t, nilFlags.Enabled(AllowPlainPKCE))
+	assert.True(t, nilFlags.Use(AllowPlainPKCE))
+}
+
+func TestFlags_ConfigOverrides(t *testing.T) {
+	warn, warnings := recordWarnings()
+	f := New(map[string]bool{AllowPlainPKCE: false, "allow_magic": true}, warn)
+
+	assert.False(t, f.Enabled(AllowPlainPKCE))
+	assert.False(t, f.Use(AllowPlainPKCE))
+	assert.True(t, f.Enabled(AllowOAuth20))
+	assert.False(t, f.Enabled("allow_magic"))
+
+	assert.Len(t, *warnings, 1)
+	assert.Contains(t, (*warnings)[0], "allow_magic")
+}
+
+func TestFlags_UseWarnsOnce(t *testing.T) {
+	warn, warnings := recordWarnings()
+	f := New(nil, warn)
+
+	for i := 0; i < 3; i++ {
+		assert.True(t, f.Use(AllowPlainPKCE))
+	}
+	assert.Len(t, *warnings, 1)
+	assert.Contains(t, (*warnings)[0], AllowPlainPKCE)
+
+	// 不同开关分别提示
+	assert.True(t, f.Use(AllowHTTPRedirects))
+	assert.Len(t, *warnings, 2)
+}
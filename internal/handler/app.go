@@ -2,7 +2,12 @@
 package handler
 
 import (
+	"errors"
+	"fmt"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/pu-ac-cn/uac-backend/internal/model"
@@ -13,7 +18,13 @@ import (
 
 // AppHandler 应用管理处理器
 type AppHandler struct {
-	appService service.ApplicationService
+	appService  service.ApplicationService
+	rbacService service.RBACService
+	auditor
+	// 除 OIDC 标准 scope 外允许配置的 scope
+	extraScopes map[string]bool
+	// 应用自定义令牌有效期的上限，0 表示不限制
+	maxTokenTTL time.Duration
 }
 
 // NewAppHandler 创建应用管理处理器
@@ -21,6 +32,66 @@ func NewAppHandler(appSvc service.ApplicationService) *AppHandler {
 	return &AppHandler{appService: appSvc}
 }
 
+// SetExtraScopes 设置应用可申请的自定义 scope
+func (h *AppHandler) SetExtraScopes(scopes []string) {
+	h.extraScopes = make(map[string]bool, len(scopes))
+	for _, scope := range scopes {
+		h.extraScopes[scope] = true
+	}
+}
+
+// SetMaxTokenTTL 设置应用自定义令牌有效期的上限
+// 撤销记录只保留全局令牌有效期，超过该值的令牌会在撤销记录过期后重新生效，因此不允许超出
+func (h *AppHandler) SetMaxTokenTTL(ttl time.Duration) {
+	h.maxTokenTTL = ttl
+}
+
+// SetRBACService 设置 RBAC 服务，用于管理服务账号应用的角色
+func (h *AppHandler) SetRBACService(rbacSvc service.RBACService) {
+	h.rbacService = rbacSvc
+}
+
+// validateAppSettings 校验回调地址均为合法的绝对 URL，scope 和授权类型均在系统已知集合内，令牌有效期不超过上限
+func (h *AppHandler) validateAppSettings(app *model.Application) error {
+	for _, uris := range []model.StringSlice{app.RedirectURIs, app.PostLogoutRedirectURIs} {
+		for _, uri := range uris {
+			if !isValidRedirectURI(uri) {
+				return fmt.Errorf("无效的回调地址: %s", uri)
+			}
+		}
+	}
+	for _, scope := range app.AllowedScopes {
+		if _, ok := scopeClaims[scope]; !ok && !h.extraScopes[scope] {
+			return fmt.Errorf("未知的 scope: %s", scope)
+		}
+	}
+	for _, grantType := range app.AllowedGrantTypes {
+		if _, ok := grantHandlers[grantType]; !ok {
+			return fmt.Errorf("不支持的授权类型: %s", grantType)
+		}
+	}
+	if h.maxTokenTTL > 0 {
+		limit := int(h.maxTokenTTL / time.Second)
+		if app.AccessTokenTTL > limit || app.RefreshTokenTTL > limit {
+			return fmt.Errorf("令牌有效期不能超过 %d 秒", limit)
+		}
+	}
+	return nil
+}
+
+// isValidRedirectURI 回调地址必须是不含片段的绝对 URL，http(s) 地址还须包含主机
+// 允许移动端应用使用的自定义 scheme（如 com.example.app:/callback）
+func isValidRedirectURI(uri string) bool {
+	u, err := url.Parse(uri)
+	if err != nil || !u.IsAbs() || u.Fragment != "" {
+		return false
+	}
+	if (u.Scheme == "http" || u.Scheme == "https") && u.Host == "" {
+		return false
+	}
+	return true
+}
+
 // ListApps 获取应用列表
 // GET /api/v1/apps
 func (h *AppHandler) ListApps(c *gin.Context) {
@@ -37,6 +108,12 @@ func (h *AppHandler) ListApps(c *gin.Context) {
 		PageSize: pageSize,
 	}
 
+	fields, err := parseFields(c, appResponseFields)
+	if err != nil {
+		response.ErrorWithMsg(c, response.CodeInvalidRequest, err.Error())
+		return
+	}
+
 	apps, total, err := h.appService.List(c.Request.Context(), filter, pagination)
 	if err != nil {
 		response.Error(c, response.CodeServerError)
@@ -45,38 +122,44 @@ func (h *AppHandler) ListApps(c *gin.Context) {
 
 	list := make([]gin.H, len(apps))
 	for i, app := range apps {
-		list[i] = h.appToResponse(app)
+		list[i] = fields.apply(h.appToResponse(app))
 	}
 
-	response.Success(c, gin.H{
-		"list":      list,
-		"total":     total,
-		"page":      page,
-		"page_size": pageSize,
-	})
+	response.Page(c, list, total, page, pageSize)
 }
 
 // GetApp 获取应用详情
 // GET /api/v1/apps/:id
 func (h *AppHandler) GetApp(c *gin.Context) {
 	id := c.Param("id")
+	fields, err := parseFields(c, appResponseFields)
+	if err != nil {
+		response.ErrorWithMsg(c, response.CodeInvalidRequest, err.Error())
+		return
+	}
 	app, err := h.appService.GetByID(c.Request.Context(), id)
 	if err != nil {
 		response.ErrorWithMsg(c, response.CodeAppNotFound, "应用不存在")
 		return
 	}
 
-	response.Success(c, h.appToResponse(app))
+	response.Success(c, fields.apply(h.appToResponse(app)))
 }
 
 // CreateAppRequest 创建应用请求
 type CreateAppRequest struct {
-	Name          string   `json:"name" binding:"required"`
-	Description   string   `json:"description"`
-	OrgID         string   `json:"org_id"`
-	RedirectURIs  []string `json:"redirect_uris"`
-	AllowedScopes []string `json:"allowed_scopes"`
-	OAuthMode     string   `json:"oauth_mode"`
+	Name                   string   `json:"name" binding:"required"`
+	Description            string   `json:"description"`
+	OrgID                  string   `json:"org_id"`
+	RedirectURIs           []string `json:"redirect_uris"`
+	PostLogoutRedirectURIs []string `json:"post_logout_redirect_uris"`
+	AllowSubpathRedirect   bool     `json:"allow_subpath_redirect"`
+	AllowedScopes          []string `json:"allowed_scopes"`
+	AllowedGrantTypes      []string `json:"allowed_grant_types"` // 为空表示允许全部授权类型
+	OAuthMode              string   `json:"oauth_mode"`
+	AccessTokenTTL         int      `json:"access_token_ttl" binding:"min=0"`  // 秒，0 表示使用全局默认
+	RefreshTokenTTL        int      `json:"refresh_token_ttl" binding:"min=0"` // 秒，0 表示使用全局默认
+	ServiceAccount         bool     `json:"service_account"`                   // 服务账号应用，客户端凭证令牌携带应用角色
 }
 
 // CreateApp 创建应用
@@ -93,19 +176,38 @@ func (h *AppHandler) CreateApp(c *gin.Context) {
 		orgIDPtr = &req.OrgID
 	}
 	app := &model.Application{
-		Name:          req.Name,
-		Description:   req.Description,
-		OrgID:         orgIDPtr, // 为空表示系统级应用
-		RedirectURIs:  req.RedirectURIs,
-		AllowedScopes: req.AllowedScopes,
-		OAuthVersion:  req.OAuthMode,
+		Name:                   req.Name,
+		Description:            req.Description,
+		OrgID:                  orgIDPtr, // 为空表示系统级应用
+		RedirectURIs:           req.RedirectURIs,
+		PostLogoutRedirectURIs: req.PostLogoutRedirectURIs,
+		AllowSubpathRedirect:   req.AllowSubpathRedirect,
+		AllowedScopes:          req.AllowedScopes,
+		AllowedGrantTypes:      req.AllowedGrantTypes,
+		OAuthVersion:           req.OAuthMode,
+		AccessTokenTTL:         req.AccessTokenTTL,
+		RefreshTokenTTL:        req.RefreshTokenTTL,
+		ServiceAccount:         req.ServiceAccount,
+		CreatedBy:              c.GetString("user_id"),
 	}
 
 	if app.OAuthVersion == "" {
 		app.OAuthVersion = model.OAuthVersion21
 	}
+	if err := h.validateAppSettings(app); err != nil {
+		response.ErrorWithMsg(c, response.CodeInvalidRequest, err.Error())
+		return
+	}
 
 	clientSecret, err := h.appService.Create(c.Request.Context(), app)
+	if errors.Is(err, service.ErrOrgAppQuotaExceeded) {
+		response.ErrorWithMsg(c, response.CodeForbidden, err.Error())
+		return
+	}
+	if errors.Is(err, repository.ErrAppClientIDExists) {
+		response.Error(c, response.CodeClientIDExists)
+		return
+	}
 	if err != nil {
 		response.ErrorWithMsg(c, response.CodeServerError, err.Error())
 		return
@@ -119,12 +221,49 @@ func (h *AppHandler) CreateApp(c *gin.Context) {
 
 // UpdateAppRequest 更新应用请求
 type UpdateAppRequest struct {
-	Name          string   `json:"name"`
-	Description   string   `json:"description"`
-	RedirectURIs  []string `json:"redirect_uris"`
-	AllowedScopes []string `json:"allowed_scopes"`
-	OAuthMode     string   `json:"oauth_mode"`
-	Status        string   `json:"status"`
+	Name                   string   `json:"name"`
+	Description            string   `json:"description"`
+	RedirectURIs           []string `json:"redirect_uris"`
+	PostLogoutRedirectURIs []string `json:"post_logout_redirect_uris"`
+	AllowSubpathRedirect   *bool    `json:"allow_subpath_redirect"`
+	AllowedScopes          []string `json:"allowed_scopes"`
+	AllowedGrantTypes      []string `json:"allowed_grant_types"`
+	OAuthMode              string   `json:"oauth_mode"`
+	AccessTokenTTL         *int     `json:"access_token_ttl" binding:"omitempty,min=0"`
+	RefreshTokenTTL        *int     `json:"refresh_token_ttl" binding:"omitempty,min=0"`
+	ServiceAccount         *bool    `json:"service_account"`
+	Status                 string   `json:"status"`
+}
+
+// privilegedFields 返回本次提交的、仅管理员可修改的字段
+// 应用创建者只能修改名称、描述和回调地址，授权类型、服务账号等字段会扩大应用的权限
+func (r *UpdateAppRequest) privilegedFields() []string {
+	var fields []string
+	if r.AllowSubpathRedirect != nil {
+		fields = append(fields, "allow_subpath_redirect")
+	}
+	if r.AllowedScopes != nil {
+		fields = append(fields, "allowed_scopes")
+	}
+	if r.AllowedGrantTypes != nil {
+		fields = append(fields, "allowed_grant_types")
+	}
+	if r.OAuthMode != "" {
+		fields = append(fields, "oauth_mode")
+	}
+	if r.AccessTokenTTL != nil {
+		fields = append(fields, "access_token_ttl")
+	}
+	if r.RefreshTokenTTL != nil {
+		fields = append(fields, "refresh_token_ttl")
+	}
+	if r.ServiceAccount != nil {
+		fields = append(fields, "service_account")
+	}
+	if r.Status != "" {
+		fields = append(fields, "status")
+	}
+	return fields
 }
 
 // UpdateApp 更新应用
@@ -137,11 +276,37 @@ func (h *AppHandler) UpdateApp(c *gin.Context) {
 		return
 	}
 
+	// 只校验本次提交的字段，避免历史数据阻塞其他字段的修改
+	submitted := &model.Application{
+		RedirectURIs:           req.RedirectURIs,
+		PostLogoutRedirectURIs: req.PostLogoutRedirectURIs,
+		AllowedScopes:          req.AllowedScopes,
+		AllowedGrantTypes:      req.AllowedGrantTypes,
+	}
+	if req.AccessTokenTTL != nil {
+		submitted.AccessTokenTTL = *req.AccessTokenTTL
+	}
+	if req.RefreshTokenTTL != nil {
+		submitted.RefreshTokenTTL = *req.RefreshTokenTTL
+	}
+	if err := h.validateAppSettings(submitted); err != nil {
+		response.ErrorWithMsg(c, response.CodeInvalidRequest, err.Error())
+		return
+	}
+
 	app, err := h.appService.GetByID(c.Request.Context(), id)
 	if err != nil {
 		response.ErrorWithMsg(c, response.CodeAppNotFound, "应用不存在")
 		return
 	}
+	admin, ok := h.authorizeAppWrite(c, app)
+	if !ok {
+		return
+	}
+	if fields := req.privilegedFields(); !admin && len(fields) > 0 {
+		response.ErrorWithMsg(c, response.CodeForbidden, "仅管理员可修改: "+strings.Join(fields, ", "))
+		return
+	}
 
 	if req.Name != "" {
 		app.Name = req.Name
@@ -152,12 +317,30 @@ func (h *AppHandler) UpdateApp(c *gin.Context) {
 	if req.RedirectURIs != nil {
 		app.RedirectURIs = req.RedirectURIs
 	}
+	if req.PostLogoutRedirectURIs != nil {
+		app.PostLogoutRedirectURIs = req.PostLogoutRedirectURIs
+	}
+	if req.AllowSubpathRedirect != nil {
+		app.AllowSubpathRedirect = *req.AllowSubpathRedirect
+	}
 	if req.AllowedScopes != nil {
 		app.AllowedScopes = req.AllowedScopes
 	}
+	if req.AllowedGrantTypes != nil {
+		app.AllowedGrantTypes = req.AllowedGrantTypes
+	}
 	if req.OAuthMode != "" {
 		app.OAuthVersion = req.OAuthMode
 	}
+	if req.AccessTokenTTL != nil {
+		app.AccessTokenTTL = *req.AccessTokenTTL
+	}
+	if req.RefreshTokenTTL != nil {
+		app.RefreshTokenTTL = *req.RefreshTokenTTL
+	}
+	if req.ServiceAccount != nil {
+		app.ServiceAccount = *req.ServiceAccount
+	}
 	if req.Status != "" {
 		app.Status = req.Status
 	}
@@ -170,11 +353,48 @@ func (h *AppHandler) UpdateApp(c *gin.Context) {
 	response.Success(c, h.appToResponse(app))
 }
 
+// authorizeAppWrite 检查当前用户能否修改应用，不能时写入错误响应
+// 管理员可修改任意应用；其他用户须拥有 app:write 权限或是应用的创建者
+// admin 表示当前用户是否为管理员，未配置 RBAC 服务时视为管理员
+func (h *AppHandler) authorizeAppWrite(c *gin.Context, app *model.Application) (admin, ok bool) {
+	if h.rbacService == nil {
+		return true, true
+	}
+	ctx := c.Request.Context()
+	userID := c.GetString("user_id")
+	for _, role := range []string{model.RoleSuperAdmin, model.RoleOrgAdmin} {
+		if has, err := h.rbacService.HasRole(ctx, userID, role); err == nil && has {
+			return true, true
+		}
+	}
+
+	allowed, err := h.rbacService.CheckResourceAccess(ctx, userID, model.ResourceApp, model.ActionWrite, app.CreatedBy)
+	if err != nil {
+		response.Error(c, response.CodeServerError)
+		return false, false
+	}
+	if !allowed {
+		response.ErrorWithMsg(c, response.CodeForbidden, "只能修改自己创建的应用")
+		return false, false
+	}
+	return false, true
+}
+
 // DeleteApp 删除应用
-// DELETE /api/v1/apps/:id
+// DELETE /api/v1/apps/:id[?dry_run=true]
 func (h *AppHandler) DeleteApp(c *gin.Context) {
 	id := c.Param("id")
 
+	if isDryRun(c) {
+		app, err := h.appService.GetByID(c.Request.Context(), id)
+		if err != nil {
+			response.Error(c, response.CodeAppNotFound)
+			return
+		}
+		respondDeleteImpact(c, newDeleteImpact("app", app.ID, app.Name))
+		return
+	}
+
 	if err := h.appService.Delete(c.Request.Context(), id); err != nil {
 		response.Error(c, response.CodeServerError)
 		return
@@ -189,6 +409,7 @@ func (h *AppHandler) ResetSecret(c *gin.Context) {
 	id := c.Param("id")
 
 	newSecret, err := h.appService.ResetSecret(c.Request.Context(), id)
+	h.audit(c, &model.AuditLog{Action: model.AuditActionResetSecret, Resource: "application", ResourceID: id}, err)
 	if err != nil {
 		response.ErrorWithMsg(c, response.CodeServerError, err.Error())
 		return
@@ -197,6 +418,102 @@ func (h *AppHandler) ResetSecret(c *gin.Context) {
 	response.Success(c, gin.H{"client_secret": newSecret})
 }
 
+// serviceAccountApp 获取服务账号应用，应用不存在或不是服务账号时写入错误响应
+func (h *AppHandler) serviceAccountApp(c *gin.Context) (*model.Application, bool) {
+	if h.rbacService == nil {
+		response.Error(c, response.CodeUnavailable)
+		return nil, false
+	}
+	app, err := h.appService.GetByID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		response.ErrorWithMsg(c, response.CodeAppNotFound, "应用不存在")
+		return nil, false
+	}
+	if !app.ServiceAccount {
+		response.ErrorWithMsg(c, response.CodeInvalidRequest, "应用不是服务账号")
+		return nil, false
+	}
+	return app, true
+}
+
+// GetAppRoles 获取服务账号应用的角色
+// GET /api/v1/apps/:id/roles
+func (h *AppHandler) GetAppRoles(c *gin.Context) {
+	app, ok := h.serviceAccountApp(c)
+	if !ok {
+		return
+	}
+	roles, err := h.rbacService.GetAppRoles(c.Request.Context(), app.ID)
+	if err != nil {
+		response.Error(c, response.CodeServerError)
+		return
+	}
+	response.Success(c, roles)
+}
+
+// AssignAppRole 为服务账号应用分配角色
+// POST /api/v1/apps/:id/roles
+func (h *AppHandler) AssignAppRole(c *gin.Context) {
+	var req struct {
+		RoleID string `json:"role_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ErrorWithMsg(c, response.CodeInvalidRequest, "参数错误: "+err.Error())
+		return
+	}
+	app, ok := h.serviceAccountApp(c)
+	if !ok {
+		return
+	}
+
+	err := h.rbacService.AssignAppRole(c.Request.Context(), c.GetString("user_id"), app.ID, req.RoleID)
+	h.audit(c, &model.AuditLog{
+		Action:     model.AuditActionAssignRole,
+		Resource:   "application",
+		ResourceID: app.ID,
+		Detail:     model.JSONMap{"role_id": req.RoleID},
+	}, err)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrRoleNotFound):
+			response.Error(c, response.CodeRoleNotFound)
+		case errors.Is(err, service.ErrRoleNotAssignable):
+			response.ErrorWithMsg(c, response.CodeForbidden, err.Error())
+		case errors.Is(err, service.ErrRoleAlreadyAssigned):
+			response.ErrorWithMsg(c, response.CodeInvalidRequest, "应用已拥有该角色")
+		default:
+			response.Error(c, response.CodeServerError)
+		}
+		return
+	}
+
+	response.Success(c, gin.H{"message": "角色分配成功"})
+}
+
+// RevokeAppRole 撤销服务账号应用的角色
+// DELETE /api/v1/apps/:id/roles/:role_id
+func (h *AppHandler) RevokeAppRole(c *gin.Context) {
+	app, ok := h.serviceAccountApp(c)
+	if !ok {
+		return
+	}
+	roleID := c.Param("role_id")
+
+	err := h.rbacService.RevokeAppRole(c.Request.Context(), app.ID, roleID)
+	h.audit(c, &model.AuditLog{
+		Action:     model.AuditActionRevokeRole,
+		Resource:   "application",
+		ResourceID: app.ID,
+		Detail:     model.JSONMap{"role_id": roleID},
+	}, err)
+	if err != nil {
+		response.Error(c, response.CodeServerError)
+		return
+	}
+
+	response.Success(c, gin.H{"message": "角色撤销成功"})
+}
+
 // appToResponse 将应用转换为响应格式
 func (h *AppHandler) appToResponse(app *model.Application) gin.H {
 	var orgID any
@@ -206,16 +523,23 @@ func (h *AppHandler) appToResponse(app *model.Application) gin.H {
 		orgID = nil
 	}
 	return gin.H{
-		"id":             app.ID,
-		"org_id":         orgID,
-		"name":           app.Name,
-		"description":    app.Description,
-		"client_id":      app.ClientID,
-		"redirect_uris":  app.RedirectURIs,
-		"allowed_scopes": app.AllowedScopes,
-		"oauth_mode":     app.OAuthVersion,
-		"status":         app.Status,
-		"created_at":     app.CreatedAt,
-		"updated_at":     app.UpdatedAt,
+		"id":                        app.ID,
+		"org_id":                    orgID,
+		"name":                      app.Name,
+		"description":               app.Description,
+		"client_id":                 app.ClientID,
+		"redirect_uris":             app.RedirectURIs,
+		"post_logout_redirect_uris": app.PostLogoutRedirectURIs,
+		"allow_subpath_redirect":    app.AllowSubpathRedirect,
+		"allowed_scopes":            app.AllowedScopes,
+		"allowed_grant_types":       app.AllowedGrantTypes,
+		"access_token_ttl":          app.AccessTokenTTL,
+		"refresh_token_ttl":         app.RefreshTokenTTL,
+		"oauth_mode":                app.OAuthVersion,
+		"service_account":           app.ServiceAccount,
+		"status":                    app.Status,
+		"created_by":                app.CreatedBy,
+		"created_at":                app.CreatedAt,
+		"updated_at":                app.UpdatedAt,
 	}
 }
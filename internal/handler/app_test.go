@@ -0,0 +1,264 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pu-ac-cn/uac-backend/internal/model"
+	"github.com/pu-ac-cn/uac-backend/internal/repository"
+	"github.com/pu-ac-cn/uac-backend/internal/service"
+	"github.com/stretchr/testify/assert"
+)
+
+// stubAppStore 内存中保存应用的应用服务桩
+type stubAppStore struct {
+	service.ApplicationService
+	apps map[string]*model.Application
+}
+
+func (s *stubAppStore) Create(ctx context.Context, app *model.Application) (string, error) {
+	app.ID = "app-new"
+	s.apps[app.ID] = app
+	return "secret", nil
+}
+
+func (s *stubAppStore) GetByID(ctx context.Context, id string) (*model.Application, error) {
+	if app, ok := s.apps[id]; ok {
+		return app, nil
+	}
+	return nil, repository.ErrAppNotFound
+}
+
+func (s *stubAppStore) Update(ctx context.Context, app *model.Application) error {
+	s.apps[app.ID] = app
+	return nil
+}
+
+func setupAppValidationTest(t *testing.T) (*gin.Engine, *stubAppStore) {
+	gin.SetMode(gin.TestMode)
+	legacy := &model.Application{Name: "旧应用", AllowedScopes: model.StringSlice{"legacy"}}
+	legacy.ID = "app-1"
+	store := &stubAppStore{apps: map[string]*model.Application{legacy.ID: legacy}}
+
+	h := NewAppHandler(store)
+	h.SetExtraScopes([]string{"read"})
+	router := gin.New()
+	router.POST("/api/v1/apps", h.CreateApp)
+	router.PUT("/api/v1/apps/:id", h.UpdateApp)
+	return router, store
+}
+
+func sendAppRequest(router *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestAppHandler_CreateApp_Validation(t *testing.T) {
+	router, store := setupAppValidationTest(t)
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"限定授权类型", `{"name":"a","allowed_grant_types":["authorization_code","refresh_token"]}`, http.StatusOK},
+		{"合法配置", `{"name":"a","redirect_uris":["https://a.example.com/cb","com.example.app:/cb"],"allowed_scopes":["openid","profile","read"]}`, http.StatusOK},
+		{"相对地址", `{"name":"a","redirect_uris":["/cb"]}`, http.StatusBadRequest},
+		{"缺少主机", `{"name":"a","redirect_uris":["https:///cb"]}`, http.StatusBadRequest},
+		{"包含片段", `{"name":"a","redirect_uris":["https://a.example.com/cb#x"]}`, http.StatusBadRequest},
+		{"非法注销地址", `{"name":"a","post_logout_redirect_uris":["not a url"]}`, http.StatusBadRequest},
+		{"未知 scope", `{"name":"a","allowed_scopes":["openid","admin"]}`, http.StatusBadRequest},
+		{"不支持的授权类型", `{"name":"a","allowed_grant_types":["password"]}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := sendAppRequest(router, http.MethodPost, "/api/v1/apps", tt.body)
+			assert.Equal(t, tt.want, w.Code, w.Body.String())
+		})
+	}
+
+	created := store.apps["app-new"]
+	if assert.NotNil(t, created) {
+		assert.Equal(t, model.StringSlice{"openid", "profile", "read"}, created.AllowedScopes)
+	}
+}
+
+func TestAppHandler_UpdateApp_Validation(t *testing.T) {
+	router, store := setupAppValidationTest(t)
+
+	w := sendAppRequest(router, http.MethodPut, "/api/v1/apps/app-1", `{"redirect_uris":["ftp//broken"]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = sendAppRequest(router, http.MethodPut, "/api/v1/apps/app-1", `{"allowed_scopes":["unknown"]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, model.StringSlice{"legacy"}, store.apps["app-1"].AllowedScopes)
+
+	// 未提交的字段不校验，历史数据不影响修改其他字段
+	w = sendAppRequest(router, http.MethodPut, "/api/v1/apps/app-1", `{"name":"新名称"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "新名称", store.apps["app-1"].Name)
+}
+
+func TestAppHandler_TokenTTLLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	legacy := &model.Application{Name: "旧应用"}
+	legacy.ID = "app-1"
+	store := &stubAppStore{apps: map[string]*model.Application{legacy.ID: legacy}}
+	h := NewAppHandler(store)
+	h.SetMaxTokenTTL(time.Hour)
+	router := gin.New()
+	router.POST("/api/v1/apps", h.CreateApp)
+	router.PUT("/api/v1/apps/:id", h.UpdateApp)
+
+	w := sendAppRequest(router, http.MethodPost, "/api/v1/apps", `{"name":"a","access_token_ttl":3601}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = sendAppRequest(router, http.MethodPost, "/api/v1/apps", `{"name":"a","refresh_token_ttl":86400}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = sendAppRequest(router, http.MethodPost, "/api/v1/apps", `{"name":"a","access_token_ttl":600,"refresh_token_ttl":3600}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = sendAppRequest(router, http.MethodPut, "/api/v1/apps/app-1", `{"refresh_token_ttl":7200}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, 0, store.apps["app-1"].RefreshTokenTTL)
+	w = sendAppRequest(router, http.MethodPut, "/api/v1/apps/app-1", `{"refresh_token_ttl":3600}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 3600, store.apps["app-1"].RefreshTokenTTL)
+}
+
+func (s *stubAppRoleRBACService) AssignAppRole(ctx context.Context, operatorID, appID, roleID string) error {
+	s.roles = append(s.roles, &model.Role{BaseModel: model.BaseModel{ID: roleID}})
+	return nil
+}
+
+func TestAppHandler_AssignAppRole(t *testing.T) {
+	router, store := setupAppValidationTest(t)
+	svcApp := &model.Application{Name: "服务账号", ServiceAccount: true}
+	svcApp.ID = "app-svc"
+	store.apps[svcApp.ID] = svcApp
+
+	rbacService := &stubAppRoleRBACService{}
+	h := NewAppHandler(store)
+	h.SetRBACService(rbacService)
+	router.POST("/api/v1/apps/:id/roles", h.AssignAppRole)
+
+	// 普通应用不能分配角色
+	w := sendAppRequest(router, http.MethodPost, "/api/v1/apps/app-1/roles", `{"role_id":"role-1"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Empty(t, rbacService.roles)
+
+	w = sendAppRequest(router, http.MethodPost, "/api/v1/apps/app-svc/roles", `{"role_id":"role-1"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Len(t, rbacService.roles, 1)
+}
+
+// stubOwnershipRBACService admins 拥有管理员角色，其他用户只能访问自己拥有的资源
+type stubOwnershipRBACService struct {
+	service.RBACService
+	admins map[string]bool
+}
+
+func (s *stubOwnershipRBACService) HasRole(ctx context.Context, userID, roleCode string) (bool, error) {
+	return roleCode == model.RoleOrgAdmin && s.admins[userID], nil
+}
+
+func (s *stubOwnershipRBACService) CheckResourceAccess(ctx context.Context, userID, resource, action, ownerID string) (bool, error) {
+	return ownerID != "" && ownerID == userID, nil
+}
+
+func TestAppHandler_UpdateApp_Ownership(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	own := &model.Application{Name: "我的应用", CreatedBy: "alice"}
+	own.ID = "app-alice"
+	other := &model.Application{Name: "别人的应用", CreatedBy: "bob"}
+	other.ID = "app-bob"
+	store := &stubAppStore{apps: map[string]*model.Application{own.ID: own, other.ID: other}}
+
+	h := NewAppHandler(store)
+	h.SetRBACService(&stubOwnershipRBACService{admins: map[string]bool{"admin": true}})
+	update := func(userID, appID, name string) *httptest.ResponseRecorder {
+		router := gin.New()
+		router.PUT("/api/v1/apps/:id", func(c *gin.Context) {
+			c.Set("user_id", userID)
+			h.UpdateApp(c)
+		})
+		return sendAppRequest(router, http.MethodPut, "/api/v1/apps/"+appID, `{"name":"`+name+`"}`)
+	}
+
+	// 普通用户能改自己的应用
+	w := update("alice", "app-alice", "改名")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "改名", store.apps["app-alice"].Name)
+
+	// 不能改别人的应用
+	w = update("alice", "app-bob", "篡改")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, "别人的应用", store.apps["app-bob"].Name)
+
+	// 管理员不受所有权限制
+	w = update("admin", "app-bob", "管理员修改")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "管理员修改", store.apps["app-bob"].Name)
+}
+
+func TestAppHandler_UpdateApp_OwnerPrivilegedFields(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	own := &model.Application{Name: "我的应用", CreatedBy: "alice", AllowedGrantTypes: []string{"authorization_code"}}
+	own.ID = "app-alice"
+	store := &stubAppStore{apps: map[string]*model.Application{own.ID: own}}
+
+	h := NewAppHandler(store)
+	h.SetRBACService(&stubOwnershipRBACService{admins: map[string]bool{"admin": true}})
+	update := func(userID, body string) *httptest.ResponseRecorder {
+		router := gin.New()
+		router.PUT("/api/v1/apps/:id", func(c *gin.Context) {
+			c.Set("user_id", userID)
+			h.UpdateApp(c)
+		})
+		return sendAppRequest(router, http.MethodPut, "/api/v1/apps/app-alice", body)
+	}
+
+	// 创建者不能把应用改为服务账号
+	w := update("alice", `{"service_account":true}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "service_account")
+	assert.False(t, store.apps["app-alice"].ServiceAccount)
+
+	// 夹带特权字段时整个请求被拒绝
+	w = update("alice", `{"name":"改名","allowed_grant_types":["client_credentials"]}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, "我的应用", store.apps["app-alice"].Name)
+	assert.Equal(t, []string{"authorization_code"}, []string(store.apps["app-alice"].AllowedGrantTypes))
+
+	// 描述和回调地址可以修改
+	w = update("alice", `{"description":"说明","redirect_uris":["https://alice.example.com/callback"]}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "说明", store.apps["app-alice"].Description)
+
+	// 管理员可以修改特权字段
+	w = update("admin", `{"service_account":true}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.True(t, store.apps["app-alice"].ServiceAccount)
+}
+
+func TestAppHandler_CreateApp_RecordsCreator(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := &stubAppStore{apps: map[string]*model.Application{}}
+	h := NewAppHandler(store)
+	router := gin.New()
+	router.POST("/api/v1/apps", func(c *gin.Context) {
+		c.Set("user_id", "alice")
+		h.CreateApp(c)
+	})
+
+	w := sendAppRequest(router, http.MethodPost, "/api/v1/apps", `{"name":"a"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "alice", store.apps["app-new"].CreatedBy)
+}
@@ -0,0 +1,98 @@
+// Package handler HTTP 处理器
+package handler
+
+import (
+	"log"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pu-ac-cn/uac-backend/internal/model"
+	"github.com/pu-ac-cn/uac-backend/internal/repository"
+	"github.com/pu-ac-cn/uac-backend/internal/service"
+	"github.com/pu-ac-cn/uac-backend/pkg/response"
+)
+
+// auditor 嵌入处理器以记录审计日志，未设置审计服务时不记录
+type auditor struct {
+	auditService service.AuditService
+}
+
+// SetAuditService 设置审计服务
+func (a *auditor) SetAuditService(svc service.AuditService) {
+	a.auditService = svc
+}
+
+// audit 记录一条审计日志，操作者取自认证中间件，并补充请求的 IP 和 User-Agent
+// err 非空时记为失败并写入失败原因；记录失败只输出日志，不影响业务响应
+func (a *auditor) audit(c *gin.Context, entry *model.AuditLog, err error) {
+	if a.auditService == nil {
+		return
+	}
+	if entry.UserID == "" {
+		entry.UserID = c.GetString("user_id")
+	}
+	entry.IP = c.ClientIP()
+	entry.UserAgent = c.Request.UserAgent()
+	entry.Result = model.AuditResultSuccess
+	if err != nil {
+		entry.Result = model.AuditResultFailure
+		if entry.Detail == nil {
+			entry.Detail = model.JSONMap{}
+		}
+		entry.Detail["error"] = err.Error()
+	}
+	if recordErr := a.auditService.Record(c.Request.Context(), entry); recordErr != nil {
+		log.Printf("记录审计日志失败 %s: %v", entry.Action, recordErr)
+	}
+}
+
+// AuditHandler 审计日志处理器
+type AuditHandler struct {
+	auditService service.AuditService
+}
+
+// NewAuditHandler 创建审计日志处理器
+func NewAuditHandler(auditSvc service.AuditService) *AuditHandler {
+	return &AuditHandler{auditService: auditSvc}
+}
+
+// ListAuditLogs 分页查询审计日志
+// GET /api/v1/audit-logs
+// 支持按操作者 user_id、操作类型 action、目标 target_type/target_id 和 created_after/created_before 时间范围过滤
+func (h *AuditHandler) ListAuditLogs(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	filter := &repository.AuditLogFilter{
+		UserID:     c.Query("user_id"),
+		Action:     c.Query("action"),
+		Resource:   c.Query("target_type"),
+		ResourceID: c.Query("target_id"),
+	}
+	var err error
+	if filter.CreatedAfter, err = parseTimeQuery(c, "created_after"); err != nil {
+		response.ErrorWithMsg(c, response.CodeInvalidFormat, "created_after 格式错误")
+		return
+	}
+	if filter.CreatedBefore, err = parseTimeQuery(c, "created_before"); err != nil {
+		response.ErrorWithMsg(c, response.CodeInvalidFormat, "created_before 格式错误")
+		return
+	}
+
+	logs, total, err := h.auditService.List(c.Request.Context(), filter, &repository.Pagination{
+		Page:     page,
+		PageSize: pageSize,
+	})
+	if err != nil {
+		response.Error(c, response.CodeServerError)
+		return
+	}
+
+	response.Page(c, logs, total, page, pageSize)
+}
@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pu-ac-cn/uac-backend/internal/model"
+	"github.com/pu-ac-cn/uac-backend/internal/repository"
+	"github.com/pu-ac-cn/uac-backend/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubAuditService 记录写入的审计日志和最近一次查询条件
+type stubAuditService struct {
+	entries []*model.AuditLog
+	filter  *repository.AuditLogFilter
+	page    *repository.Pagination
+}
+
+func (s *stubAuditService) Record(ctx context.Context, entry *model.AuditLog) error {
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+func (s *stubAuditService) List(ctx context.Context, filter *repository.AuditLogFilter, page *repository.Pagination) ([]*model.AuditLog, int64, error) {
+	s.filter = filter
+	s.page = page
+	return s.entries, int64(len(s.entries)), nil
+}
+
+// stubAssignRBACService 仅实现分配角色的 RBAC 服务
+type stubAssignRBACService struct {
+	service.RBACService
+}
+
+func (s *stubAssignRBACService) AssignRole(ctx context.Context, userID, roleID string) error {
+	switch roleID {
+	case "missing":
+		return service.ErrRoleNotFound
+	case "assigned":
+		return service.ErrRoleAlreadyAssigned
+	case "other-org":
+		return service.ErrRoleOrgMismatch
+	}
+	return nil
+}
+
+func TestAuditor_LoginFailureRecorded(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	user := &model.User{Username: "alice", Phone: "+8613800138000"}
+	user.ID = "user-1"
+	audits := &stubAuditService{}
+	h := NewAuthHandler(nil, &stubAuthService{user: user}, nil, nil)
+	h.SetAuditService(audits)
+
+	router := gin.New()
+	router.POST("/api/v1/auth/login", h.Login)
+
+	body, _ := json.Marshal(LoginRequest{Phone: "+8613900139000", Password: "Password123"})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "audit-test")
+	req.RemoteAddr = "203.0.113.9:51234"
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	require.Len(t, audits.entries, 1)
+	entry := audits.entries[0]
+	assert.Equal(t, model.AuditActionLogin, entry.Action)
+	assert.Equal(t, model.AuditResultFailure, entry.Result)
+	assert.Empty(t, entry.UserID)
+	assert.Equal(t, "203.0.113.9", entry.IP)
+	assert.Equal(t, "audit-test", entry.UserAgent)
+	assert.Equal(t, "+8613900139000", entry.Detail["identifier"])
+	assert.Equal(t, service.ErrInvalidCredentials.Error(), entry.Detail["error"])
+}
+
+func TestAuditor_AssignRoleRecorded(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	audits := &stubAuditService{}
+	h := NewRBACHandler(&stubAssignRBACService{})
+	h.SetAuditService(audits)
+
+	router := gin.New()
+	router.POST("/api/v1/user-roles/:user_id", func(c *gin.Context) {
+		c.Set("user_id", "admin-1")
+		h.AssignRole(c)
+	})
+
+	for _, roleID := range []string{"role-1", "missing"} {
+		body, _ := json.Marshal(map[string]string{"role_id": roleID})
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/user-roles/user-2", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	require.Len(t, audits.entries, 2)
+	for _, entry := range audits.entries {
+		assert.Equal(t, model.AuditActionAssignRole, entry.Action)
+		assert.Equal(t, "admin-1", entry.UserID)
+		assert.Equal(t, "user", entry.Resource)
+		assert.Equal(t, "user-2", entry.ResourceID)
+	}
+	assert.Equal(t, model.AuditResultSuccess, audits.entries[0].Result)
+	assert.Equal(t, "role-1", audits.entries[0].Detail["role_id"])
+	assert.Equal(t, model.AuditResultFailure, audits.entries[1].Result)
+}
+
+func TestAuditHandler_ListAuditLogs_Filters(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	audits := &stubAuditService{}
+	router := gin.New()
+	router.GET("/api/v1/audit-logs", NewAuditHandler(audits).ListAuditLogs)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/audit-logs?user_id=admin-1&action=role.assign"+
+		"&target_type=user&target_id=user-2&created_after=2024-01-01&created_before=2024-02-01", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	require.NotNil(t, audits.filter)
+	assert.Equal(t, "admin-1", audits.filter.UserID)
+	assert.Equal(t, model.AuditActionAssignRole, audits.filter.Action)
+	assert.Equal(t, "user", audits.filter.Resource)
+	assert.Equal(t, "user-2", audits.filter.ResourceID)
+	assert.True(t, audits.filter.CreatedAfter.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)))
+	assert.True(t, audits.filter.CreatedBefore.Equal(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)))
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/audit-logs?created_before=tomorrow", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Contains(t, w.Body.String(), "created_before")
+}
+
+func TestAuditHandler_ListAuditLogs_PageSize(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	audits := &stubAuditService{}
+	router := gin.New()
+	router.GET("/api/v1/audit-logs", NewAuditHandler(audits).ListAuditLogs)
+
+	tests := []struct {
+		query    string
+		page     int
+		pageSize int
+	}{
+		{"", 1, 20},
+		{"?page=2&page_size=50", 2, 50},
+		{"?page_size=0", 1, 20},
+		{"?page_size=-1", 1, 20},
+		{"?page_size=100000", 1, 20},
+		{"?page=0&page_size=abc", 1, 20},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/audit-logs"+tt.query, nil))
+		require.Equal(t, http.StatusOK, w.Code, tt.query)
+		assert.Equal(t, tt.page, audits.page.Page, tt.query)
+		assert.Equal(t, tt.pageSize, audits.page.PageSize, tt.query)
+	}
+}
@@ -2,7 +2,11 @@
 package handler
 
 import (
+	"errors"
+
 	"github.com/gin-gonic/gin"
+	"github.com/pu-ac-cn/uac-backend/internal/metrics"
+	"github.com/pu-ac-cn/uac-backend/internal/middleware"
 	"github.com/pu-ac-cn/uac-backend/internal/model"
 	"github.com/pu-ac-cn/uac-backend/internal/service"
 	"github.com/pu-ac-cn/uac-backend/pkg/response"
@@ -10,18 +14,26 @@ import (
 
 // AuthHandler 认证处理器
 type AuthHandler struct {
-	userService  service.UserService
-	authService  service.AuthService
-	tokenService service.TokenService
-	rbacService  service.RBACService
+	userService    service.UserService
+	authService    service.AuthService
+	tokenService   service.TokenService
+	sessionService service.SessionService
+	rbacService    service.RBACService
+	loginLimiter   *middleware.RateLimiter // 登录接口使用的限流器，用于查询限流状态
+	loginPath      string
+	mfaService     service.MFAService // 开启 MFA 的用户登录需通过第二步验证
+	passwordPolicy service.PasswordPolicy
+	auditor
 }
 
 // NewAuthHandler 创建认证处理器
-func NewAuthHandler(userSvc service.UserService, authSvc service.AuthService, tokenSvc service.TokenService, rbacSvc ...service.RBACService) *AuthHandler {
+func NewAuthHandler(userSvc service.UserService, authSvc service.AuthService, tokenSvc service.TokenService, sessionSvc service.SessionService, rbacSvc ...service.RBACService) *AuthHandler {
 	h := &AuthHandler{
-		userService:  userSvc,
-		authService:  authSvc,
-		tokenService: tokenSvc,
+		userService:    userSvc,
+		authService:    authSvc,
+		tokenService:   tokenSvc,
+		sessionService: sessionSvc,
+		passwordPolicy: service.DefaultPasswordPolicy(),
 	}
 	if len(rbacSvc) > 0 {
 		h.rbacService = rbacSvc[0]
@@ -29,11 +41,27 @@ func NewAuthHandler(userSvc service.UserService, authSvc service.AuthService, to
 	return h
 }
 
+// SetLoginRateLimiter 设置登录接口的限流器和路由路径，GetAuthStatus 据此返回当前 IP 的登录限流状态
+func (h *AuthHandler) SetLoginRateLimiter(limiter *middleware.RateLimiter, loginPath string) {
+	h.loginLimiter = limiter
+	h.loginPath = loginPath
+}
+
+// SetMFAService 设置多因素认证服务，未设置时登录不校验 MFA
+func (h *AuthHandler) SetMFAService(mfaSvc service.MFAService) {
+	h.mfaService = mfaSvc
+}
+
+// SetPasswordPolicy 设置注册和重置密码时使用的密码策略，未设置时使用默认策略
+func (h *AuthHandler) SetPasswordPolicy(policy service.PasswordPolicy) {
+	h.passwordPolicy = policy
+}
+
 // RegisterRequest 注册请求
 type RegisterRequest struct {
 	Username    string `json:"username" binding:"required,min=3,max=50"`
 	Email       string `json:"email" binding:"required,email"`
-	Password    string `json:"password" binding:"required,min=8"`
+	Password    string `json:"password" binding:"required"`
 	DisplayName string `json:"display_name"`
 	Phone       string `json:"phone"`
 }
@@ -42,6 +70,7 @@ type RegisterRequest struct {
 type LoginRequest struct {
 	Username string `json:"username"` // 用户名或邮箱
 	Email    string `json:"email"`
+	Phone    string `json:"phone"` // 已验证的手机号
 	Password string `json:"password" binding:"required"`
 }
 
@@ -63,8 +92,8 @@ func (h *AuthHandler) Register(c *gin.Context) {
 	}
 
 	// 检查密码强度
-	if !service.IsPasswordStrong(req.Password) {
-		response.ErrorWithMsg(c, response.CodeInvalidRequest, "密码强度不足，需要至少8位，包含大写字母、小写字母和数字")
+	if err := h.passwordPolicy.Validate(req.Password); err != nil {
+		response.ErrorWithMsg(c, response.CodeInvalidRequest, err.Error())
 		return
 	}
 
@@ -86,6 +115,9 @@ func (h *AuthHandler) Register(c *gin.Context) {
 			response.Error(c, response.CodeEmailExists)
 			return
 		}
+		if respondPhoneError(c, err) {
+			return
+		}
 		response.Error(c, response.CodeServerError)
 		return
 	}
@@ -120,42 +152,186 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	// 必须提供用户名或邮箱
-	if req.Username == "" && req.Email == "" {
-		response.ErrorWithMsg(c, response.CodeInvalidRequest, "请提供用户名或邮箱")
+	// 必须提供用户名、邮箱或手机号
+	if req.Username == "" && req.Email == "" && req.Phone == "" {
+		response.ErrorWithMsg(c, response.CodeInvalidRequest, "请提供用户名、邮箱或手机号")
 		return
 	}
 
 	var user *model.User
 	var err error
 
-	// 根据用户名或邮箱认证
-	if req.Email != "" {
+	// 根据邮箱、手机号或用户名认证
+	switch {
+	case req.Email != "":
 		user, err = h.authService.AuthenticateByEmail(c.Request.Context(), req.Email, req.Password)
-	} else {
+	case req.Phone != "":
+		user, err = h.authService.AuthenticateByPhone(c.Request.Context(), req.Phone, req.Password)
+	default:
 		user, err = h.authService.Authenticate(c.Request.Context(), req.Username, req.Password)
 	}
 
 	if err != nil {
-		switch err {
-		case service.ErrInvalidCredentials:
-			response.Error(c, response.CodeInvalidCredentials)
-		case service.ErrAccountLocked:
-			response.Error(c, response.CodeAccountLocked)
-		case service.ErrAccountDisabled:
-			response.Error(c, response.CodeForbidden)
+		h.audit(c, &model.AuditLog{
+			Action:   model.AuditActionLogin,
+			Resource: "user",
+			Detail:   model.JSONMap{"identifier": loginIdentifier(&req)},
+		}, err)
+		respondLoginError(c, err)
+		return
+	}
+
+	// 开启 MFA 的用户先签发短期 mfa_token，通过 TOTP 校验后再签发正式令牌
+	if user.MFAEnabled && h.mfaService != nil {
+		mfaToken, err := h.mfaService.IssueMFAToken(c.Request.Context(), user.ID)
+		if err != nil {
+			response.Error(c, response.CodeServerError)
+			return
+		}
+		metrics.Login(metrics.LoginMFARequired)
+		response.ErrorWithData(c, response.CodeMFARequired, gin.H{
+			"mfa_token":  mfaToken,
+			"expires_in": int(service.MFATokenExpiry.Seconds()),
+		})
+		return
+	}
+
+	h.issueLoginTokens(c, user)
+}
+
+// MFAVerifyRequest 登录第二步 MFA 验证请求
+type MFAVerifyRequest struct {
+	MFAToken     string `json:"mfa_token" binding:"required"`
+	Code         string `json:"code"`          // TOTP 验证码
+	RecoveryCode string `json:"recovery_code"` // 丢失身份验证器时使用恢复码代替验证码
+}
+
+// VerifyMFA 登录第二步，校验 mfa_token 和 TOTP 验证码（或恢复码）后签发令牌
+// POST /api/v1/auth/mfa/verify
+func (h *AuthHandler) VerifyMFA(c *gin.Context) {
+	var req MFAVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ErrorWithMsg(c, response.CodeInvalidRequest, "参数错误: "+err.Error())
+		return
+	}
+	if req.Code == "" && req.RecoveryCode == "" {
+		response.ErrorWithMsg(c, response.CodeInvalidRequest, "请提供验证码或恢复码")
+		return
+	}
+	if h.mfaService == nil {
+		response.ErrorWithMsg(c, response.CodeUnavailable, "多因素认证未启用")
+		return
+	}
+
+	var userID string
+	var err error
+	if req.RecoveryCode != "" {
+		userID, err = h.mfaService.VerifyMFATokenWithRecoveryCode(c.Request.Context(), req.MFAToken, req.RecoveryCode)
+	} else {
+		userID, err = h.mfaService.VerifyMFAToken(c.Request.Context(), req.MFAToken, req.Code)
+	}
+	if err != nil {
+		h.audit(c, &model.AuditLog{
+			Action:   model.AuditActionLogin,
+			Resource: "user",
+			Detail:   model.JSONMap{"step": "mfa"},
+		}, err)
+		switch {
+		case errors.Is(err, service.ErrMFATokenInvalid):
+			metrics.Login(metrics.LoginMFAFailed)
+			response.ErrorWithMsg(c, response.CodeInvalidToken, err.Error())
+		case errors.Is(err, service.ErrInvalidTOTPCode):
+			metrics.Login(metrics.LoginMFAFailed)
+			response.Error(c, response.CodeInvalidCode)
+		case errors.Is(err, service.ErrInvalidRecovery):
+			metrics.Login(metrics.LoginMFAFailed)
+			response.ErrorWithMsg(c, response.CodeInvalidCode, err.Error())
 		default:
+			metrics.Login(metrics.LoginError)
 			response.Error(c, response.CodeServerError)
 		}
 		return
 	}
 
+	user, err := h.userService.GetByID(c.Request.Context(), userID)
+	if err != nil {
+		response.Error(c, response.CodeUserNotFound)
+		return
+	}
+	// 账户可能在密码校验与 MFA 校验之间被禁用或锁定
+	if err := accountStatusError(user); err != nil {
+		h.audit(c, &model.AuditLog{
+			UserID:   user.ID,
+			Action:   model.AuditActionLogin,
+			Resource: "user",
+			Detail:   model.JSONMap{"step": "mfa"},
+		}, err)
+		respondLoginError(c, err)
+		return
+	}
+	h.issueLoginTokens(c, user)
+}
+
+// accountStatusError 返回账户当前不能登录的原因，与密码认证的检查一致
+func accountStatusError(user *model.User) error {
+	if user.IsLocked() {
+		return service.ErrAccountLocked
+	}
+	if !user.IsActive() {
+		return service.ErrAccountDisabled
+	}
+	return nil
+}
+
+// respondLoginError 按登录失败原因写入错误响应并记录指标
+func respondLoginError(c *gin.Context, err error) {
+	switch err {
+	case service.ErrInvalidCredentials:
+		metrics.Login(metrics.LoginInvalidCredentials)
+		response.Error(c, response.CodeInvalidCredentials)
+	case service.ErrAccountLocked:
+		metrics.Login(metrics.LoginLocked)
+		response.Error(c, response.CodeAccountLocked)
+	case service.ErrAccountDisabled:
+		metrics.Login(metrics.LoginDisabled)
+		response.Error(c, response.CodeForbidden)
+	default:
+		metrics.Login(metrics.LoginError)
+		response.Error(c, response.CodeServerError)
+	}
+}
+
+// issueLoginTokens 创建登录会话并签发访问令牌和刷新令牌
+func (h *AuthHandler) issueLoginTokens(c *gin.Context, user *model.User) {
 	// 生成令牌
 	claims := &service.TokenClaims{
 		UserID:   user.ID,
 		Username: user.Username,
 		Email:    user.Email,
 		Scopes:   []string{"openid", "profile", "email"},
+		FamilyID: service.NewTokenFamilyID(),
+	}
+
+	// 创建登录会话，会话 ID 写入令牌以便识别当前会话
+	// 防止会话固定：会话 ID 始终由服务端生成，客户端携带的旧会话在登录时结束
+	if h.sessionService != nil {
+		if oldSessionID := h.presentedSessionID(c); oldSessionID != "" {
+			_ = h.sessionService.Delete(c.Request.Context(), oldSessionID)
+		}
+		session := &model.Session{
+			UserID:    user.ID,
+			IPAddress: c.ClientIP(),
+			UserAgent: c.Request.UserAgent(),
+		}
+		if err := h.sessionService.Create(c.Request.Context(), session); err != nil {
+			if errors.Is(err, service.ErrTooManySessions) {
+				response.Error(c, response.CodeTooManySessions)
+				return
+			}
+			response.Error(c, response.CodeServerError)
+			return
+		}
+		claims.SessionID = session.ID
 	}
 
 	accessToken, err := h.tokenService.GenerateAccessToken(c.Request.Context(), claims)
@@ -170,14 +346,82 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
+	h.audit(c, &model.AuditLog{
+		UserID:     user.ID,
+		Action:     model.AuditActionLogin,
+		Resource:   "user",
+		ResourceID: user.ID,
+		Detail:     model.JSONMap{"session_id": claims.SessionID},
+	}, nil)
+	metrics.Login(metrics.LoginSuccess)
+	metrics.TokenIssued(metrics.GrantLogin)
+
 	response.Success(c, TokenResponse{
 		AccessToken:  accessToken,
 		RefreshToken: refreshToken,
 		TokenType:    "Bearer",
-		ExpiresIn:    900, // 15 分钟
+		ExpiresIn:    expiresIn(h.tokenService),
 	})
 }
 
+// GetAuthStatus 查询账户锁定状态和当前 IP 的登录限流状态
+// 用户不存在时返回与未锁定账户相同的结果
+// GET /api/v1/auth/status?username=
+func (h *AuthHandler) GetAuthStatus(c *gin.Context) {
+	username := c.Query("username")
+	if username == "" {
+		response.ErrorWithMsg(c, response.CodeInvalidRequest, "请提供用户名")
+		return
+	}
+
+	lockout, err := h.authService.GetLockoutStatus(c.Request.Context(), username)
+	if err != nil {
+		response.Error(c, response.CodeServerError)
+		return
+	}
+
+	result := gin.H{
+		"locked":      lockout.Locked,
+		"unlock_at":   lockout.UnlockAt,
+		"retry_after": lockout.RetryAfter,
+	}
+	if h.loginLimiter != nil {
+		if status := h.loginLimiter.Status(c.Request.Context(), c.ClientIP(), h.loginPath); status != nil {
+			result["rate_limit"] = gin.H{
+				"limit":       status.Limit,
+				"remaining":   status.Remaining,
+				"retry_after": status.RetryAfter,
+			}
+		}
+	}
+	response.Success(c, result)
+}
+
+// loginIdentifier 返回登录请求中使用的账号标识，用于记录失败的登录尝试
+func loginIdentifier(req *LoginRequest) string {
+	switch {
+	case req.Email != "":
+		return req.Email
+	case req.Phone != "":
+		return req.Phone
+	default:
+		return req.Username
+	}
+}
+
+// presentedSessionID 获取客户端在登录前携带的会话 ID
+func (h *AuthHandler) presentedSessionID(c *gin.Context) string {
+	token := c.GetHeader("Authorization")
+	if len(token) <= 7 || token[:7] != "Bearer " {
+		return ""
+	}
+	claims, err := h.tokenService.ValidateToken(c.Request.Context(), token[7:])
+	if err != nil {
+		return ""
+	}
+	return claims.SessionID
+}
+
 // RefreshToken 刷新令牌
 // POST /api/v1/auth/refresh
 func (h *AuthHandler) RefreshToken(c *gin.Context) {
@@ -191,6 +435,11 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 
 	// 验证刷新令牌
 	claims, err := h.tokenService.ValidateToken(c.Request.Context(), req.RefreshToken)
+	if errors.Is(err, service.ErrRefreshTokenUsed) {
+		revokeReusedRefreshToken(c, h.tokenService, claims)
+		response.Error(c, response.CodeInvalidRefreshToken)
+		return
+	}
 	if err != nil {
 		response.Error(c, response.CodeInvalidRefreshToken)
 		return
@@ -201,28 +450,131 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 		return
 	}
 
-	// 撤销旧的刷新令牌（轮换）
-	h.tokenService.RevokeToken(c.Request.Context(), req.RefreshToken)
+	// 会话已被登出时不允许继续刷新
+	if h.sessionService != nil && claims.SessionID != "" {
+		if _, err := h.sessionService.Get(c.Request.Context(), claims.SessionID); err != nil {
+			response.Error(c, response.CodeInvalidRefreshToken)
+			return
+		}
+	}
+
+	// 轮换旧的刷新令牌，并发请求中只有一个能成功
+	if err := h.tokenService.RotateRefreshToken(c.Request.Context(), claims); err != nil {
+		if errors.Is(err, service.ErrRefreshTokenUsed) {
+			revokeReusedRefreshToken(c, h.tokenService, claims)
+			response.Error(c, response.CodeInvalidRefreshToken)
+			return
+		}
+		response.Error(c, response.CodeServerError)
+		return
+	}
 
 	// 生成新令牌
 	newClaims := &service.TokenClaims{
-		UserID:   claims.UserID,
-		Username: claims.Username,
-		Email:    claims.Email,
-		Scopes:   claims.Scopes,
+		UserID:    claims.UserID,
+		Username:  claims.Username,
+		Email:     claims.Email,
+		Scopes:    claims.Scopes,
+		SessionID: claims.SessionID,
+		FamilyID:  claims.FamilyID,
 	}
 
 	accessToken, _ := h.tokenService.GenerateAccessToken(c.Request.Context(), newClaims)
 	refreshToken, _ := h.tokenService.GenerateRefreshToken(c.Request.Context(), newClaims)
+	metrics.TokenIssued("refresh_token")
 
 	response.Success(c, TokenResponse{
 		AccessToken:  accessToken,
 		RefreshToken: refreshToken,
 		TokenType:    "Bearer",
-		ExpiresIn:    900,
+		ExpiresIn:    expiresIn(h.tokenService),
 	})
 }
 
+// ForgotPasswordRequest 忘记密码请求
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ForgotPassword 忘记密码，发送重置邮件
+// POST /api/v1/auth/forgot-password
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ErrorWithMsg(c, response.CodeInvalidRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	if err := h.authService.InitiatePasswordReset(c.Request.Context(), req.Email); err != nil {
+		response.Error(c, response.CodeServerError)
+		return
+	}
+
+	// 无论邮箱是否存在都返回相同响应，防止账户枚举
+	response.Success(c, gin.H{"message": "如果该邮箱已注册，重置邮件将很快送达"})
+}
+
+// ResetPasswordRequest 重置密码请求
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// ResetPassword 使用重置令牌设置新密码
+// POST /api/v1/auth/reset-password
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ErrorWithMsg(c, response.CodeInvalidRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	// 检查密码强度
+	if err := h.passwordPolicy.Validate(req.NewPassword); err != nil {
+		response.ErrorWithMsg(c, response.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	err := h.authService.CompletePasswordReset(c.Request.Context(), req.Token, req.NewPassword)
+	h.audit(c, &model.AuditLog{Action: model.AuditActionResetPassword, Resource: "user"}, err)
+	if err != nil {
+		if err == service.ErrResetTokenInvalid || err == service.ErrPasswordReused {
+			response.ErrorWithMsg(c, response.CodeInvalidRequest, err.Error())
+			return
+		}
+		response.Error(c, response.CodeServerError)
+		return
+	}
+
+	response.Success(c, gin.H{"message": "密码重置成功，请重新登录"})
+}
+
+// InvalidateUserTokens 作废用户尚未使用的密码重置和邮箱验证令牌
+// POST /api/v1/users/:id/invalidate-tokens
+func (h *AuthHandler) InvalidateUserTokens(c *gin.Context) {
+	userID := c.Param("id")
+	count, err := h.authService.InvalidatePendingTokens(c.Request.Context(), userID)
+	h.audit(c, &model.AuditLog{
+		Action:     model.AuditActionInvalidateTokens,
+		Resource:   "user",
+		ResourceID: userID,
+		Detail:     model.JSONMap{"invalidated": count},
+	}, err)
+	if err != nil {
+		switch err {
+		case service.ErrUserNotFound:
+			response.Error(c, response.CodeUserNotFound)
+		case service.ErrResetUnavailable:
+			response.ErrorWithMsg(c, response.CodeUnavailable, err.Error())
+		default:
+			response.Error(c, response.CodeServerError)
+		}
+		return
+	}
+
+	response.Success(c, gin.H{"invalidated": count})
+}
+
 // Logout 用户登出
 // POST /api/v1/auth/logout
 func (h *AuthHandler) Logout(c *gin.Context) {
@@ -233,6 +585,19 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 		h.tokenService.RevokeToken(c.Request.Context(), token)
 	}
 
+	// 结束当前会话
+	if sessionID := c.GetString("session_id"); sessionID != "" && h.sessionService != nil {
+		_ = h.sessionService.Delete(c.Request.Context(), sessionID)
+	}
+
+	userID := c.GetString("user_id")
+	h.audit(c, &model.AuditLog{
+		Action:     model.AuditActionLogout,
+		Resource:   "user",
+		ResourceID: userID,
+		Detail:     model.JSONMap{"session_id": c.GetString("session_id")},
+	}, nil)
+
 	response.Success(c, gin.H{"message": "登出成功"})
 }
 
@@ -0,0 +1,361 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/pu-ac-cn/uac-backend/internal/model"
+	"github.com/pu-ac-cn/uac-backend/internal/service"
+	"github.com/pu-ac-cn/uac-backend/pkg/response"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubAuthService 仅实现登录所需方法的认证服务
+type stubAuthService struct {
+	service.AuthService
+	user *model.User
+}
+
+func (s *stubAuthService) Authenticate(ctx context.Context, username, password string) (*model.User, error) {
+	return s.user, nil
+}
+
+func (s *stubAuthService) AuthenticateByPhone(ctx context.Context, phone, password string) (*model.User, error) {
+	if phone != s.user.Phone {
+		return nil, service.ErrInvalidCredentials
+	}
+	return s.user, nil
+}
+
+func (s *stubAuthService) GetLockoutStatus(ctx context.Context, username string) (*service.LockoutStatus, error) {
+	if s.user == nil || username != s.user.Username || !s.user.IsLocked() {
+		return &service.LockoutStatus{}, nil
+	}
+	return &service.LockoutStatus{
+		Locked:     true,
+		UnlockAt:   s.user.LockedUntil,
+		RetryAfter: int(time.Until(*s.user.LockedUntil) / time.Second),
+	}, nil
+}
+
+// setupLoginTestRouter 创建登录测试路由
+func setupLoginTestRouter(t *testing.T) (*gin.Engine, service.TokenService, service.SessionService) {
+	gin.SetMode(gin.TestMode)
+
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		client.Close()
+		mr.Close()
+	})
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	tokenService := service.NewTokenService(&service.TokenServiceConfig{
+		PrivateKey:    privateKey,
+		PublicKey:     &privateKey.PublicKey,
+		KeyID:         "test-key",
+		Issuer:        "http://localhost:8080",
+		AccessExpiry:  15 * time.Minute,
+		RefreshExpiry: 7 * 24 * time.Hour,
+		CodeExpiry:    10 * time.Minute,
+	})
+	sessionService := service.NewSessionService(client, nil)
+
+	user := &model.User{Username: "alice", Email: "alice@example.com", Phone: "+8613800138000"}
+	user.ID = "user-1"
+	authService := &stubAuthService{user: user}
+	h := NewAuthHandler(nil, authService, tokenService, sessionService)
+
+	router := gin.New()
+	router.POST("/api/v1/auth/login", h.Login)
+	return router, tokenService, sessionService
+}
+
+// loginSessionID 执行登录并返回访问令牌中的会话 ID
+func loginSessionID(t *testing.T, router *gin.Engine, tokenService service.TokenService, bearer string) string {
+	body, _ := json.Marshal(LoginRequest{Username: "alice", Password: "Password123"})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		Data TokenResponse `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	claims, err := tokenService.ValidateToken(context.Background(), resp.Data.AccessToken)
+	require.NoError(t, err)
+	return claims.SessionID
+}
+
+func TestAuthHandler_Login_RotatesSessionID(t *testing.T) {
+	router, tokenService, sessionService := setupLoginTestRouter(t)
+	ctx := context.Background()
+
+	// 登录前客户端已持有一个会话（例如被攻击者预置）
+	preAuth := &model.Session{ID: "attacker-chosen-session", UserID: "user-1"}
+	require.NoError(t, sessionService.Create(ctx, preAuth))
+	preToken, err := tokenService.GenerateAccessToken(ctx, &service.TokenClaims{UserID: "user-1", SessionID: preAuth.ID})
+	require.NoError(t, err)
+
+	sessionID := loginSessionID(t, router, tokenService, preToken)
+	assert.NotEmpty(t, sessionID)
+	assert.NotEqual(t, preAuth.ID, sessionID)
+
+	// 旧会话在登录时结束，新会话有效
+	_, err = sessionService.Get(ctx, preAuth.ID)
+	assert.Error(t, err)
+	_, err = sessionService.Get(ctx, sessionID)
+	assert.NoError(t, err)
+}
+
+func TestAuthHandler_Login_SessionIDUnpredictable(t *testing.T) {
+	router, tokenService, _ := setupLoginTestRouter(t)
+
+	first := loginSessionID(t, router, tokenService, "")
+	second := loginSessionID(t, router, tokenService, "")
+
+	// 会话 ID 为随机 UUID，每次登录都不同
+	assert.NotEqual(t, first, second)
+	for _, id := range []string{first, second} {
+		parsed, err := uuid.Parse(id)
+		require.NoError(t, err)
+		assert.Equal(t, uuid.Version(4), parsed.Version())
+	}
+}
+
+func TestAuthHandler_Login_ByPhone(t *testing.T) {
+	router, _, _ := setupLoginTestRouter(t)
+
+	login := func(phone string) int {
+		body, _ := json.Marshal(LoginRequest{Phone: phone, Password: "Password123"})
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, login("+8613800138000"))
+	assert.Equal(t, http.StatusUnauthorized, login("+8613900139000"))
+}
+
+func TestAuthHandler_Login_ExpiresInMatchesConfig(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	tokenService := service.NewTokenService(&service.TokenServiceConfig{
+		PrivateKey:    privateKey,
+		PublicKey:     &privateKey.PublicKey,
+		KeyID:         "test-key",
+		Issuer:        "http://localhost:8080",
+		AccessExpiry:  2 * time.Hour,
+		RefreshExpiry: 7 * 24 * time.Hour,
+	})
+	user := &model.User{Username: "alice"}
+	user.ID = "user-1"
+	h := NewAuthHandler(nil, &stubAuthService{user: user}, tokenService, nil)
+	router := gin.New()
+	router.POST("/api/v1/auth/login", h.Login)
+
+	body, _ := json.Marshal(LoginRequest{Username: "alice", Password: "Password123"})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		Data TokenResponse `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, 7200, resp.Data.ExpiresIn)
+	assertExpiresIn(t, tokenService, resp.Data.AccessToken, float64(resp.Data.ExpiresIn))
+}
+
+func TestAuthHandler_GetAuthStatus(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	lockedUntil := time.Now().Add(10 * time.Minute)
+	user := &model.User{Username: "alice", LockedUntil: &lockedUntil}
+	h := NewAuthHandler(nil, &stubAuthService{user: user}, nil, nil)
+	router := gin.New()
+	router.GET("/api/v1/auth/status", h.GetAuthStatus)
+
+	status := func(username string) map[string]interface{} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/auth/status?username="+username, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		var resp struct {
+			Data map[string]interface{} `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp.Data
+	}
+
+	locked := status("alice")
+	assert.Equal(t, true, locked["locked"])
+	assert.NotNil(t, locked["unlock_at"])
+	assert.Greater(t, locked["retry_after"], float64(0))
+
+	// 未知用户返回中性状态，与未锁定账户无法区分
+	unknown := status("nobody")
+	assert.Equal(t, false, unknown["locked"])
+	assert.Nil(t, unknown["unlock_at"])
+	assert.Equal(t, float64(0), unknown["retry_after"])
+	assert.NotContains(t, unknown, "rate_limit")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/auth/status", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// stubMFAService 固定签发 mfa-token、只接受 123456 的 MFA 服务
+type stubMFAService struct {
+	service.MFAService
+	userID string
+}
+
+func (s *stubMFAService) IssueMFAToken(ctx context.Context, userID string) (string, error) {
+	s.userID = userID
+	return "mfa-token", nil
+}
+
+func (s *stubMFAService) VerifyMFAToken(ctx context.Context, mfaToken, code string) (string, error) {
+	if mfaToken != "mfa-token" || s.userID == "" {
+		return "", service.ErrMFATokenInvalid
+	}
+	if code != "123456" {
+		return "", service.ErrInvalidTOTPCode
+	}
+	return s.userID, nil
+}
+
+func TestAuthHandler_Login_MFARequired(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	tokenService := service.NewTokenService(&service.TokenServiceConfig{
+		PrivateKey:   privateKey,
+		PublicKey:    &privateKey.PublicKey,
+		KeyID:        "test-key",
+		Issuer:       "http://localhost:8080",
+		AccessExpiry: 15 * time.Minute,
+	})
+	user := &model.User{Username: "alice", MFAEnabled: true, Status: model.StatusActive}
+	user.ID = "user-1"
+	h := NewAuthHandler(&stubUserService{user: user}, &stubAuthService{user: user}, tokenService, nil)
+	h.SetMFAService(&stubMFAService{})
+	router := gin.New()
+	router.POST("/api/v1/auth/login", h.Login)
+	router.POST("/api/v1/auth/mfa/verify", h.VerifyMFA)
+
+	post := func(path string, payload interface{}) (*httptest.ResponseRecorder, int, map[string]interface{}) {
+		body, _ := json.Marshal(payload)
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var resp struct {
+			Code int                    `json:"code"`
+			Data map[string]interface{} `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return w, resp.Code, resp.Data
+	}
+
+	// 第一步只返回 mfa_token，不签发正式令牌
+	w, code, data := post("/api/v1/auth/login", LoginRequest{Username: "alice", Password: "Password123"})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, response.CodeMFARequired, code)
+	assert.Equal(t, "mfa-token", data["mfa_token"])
+	assert.NotContains(t, data, "access_token")
+
+	_, code, _ = post("/api/v1/auth/mfa/verify", MFAVerifyRequest{MFAToken: "mfa-token", Code: "000000"})
+	assert.Equal(t, response.CodeInvalidCode, code)
+	w, _, _ = post("/api/v1/auth/mfa/verify", MFAVerifyRequest{MFAToken: "other", Code: "123456"})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// 第二步校验 TOTP 后签发令牌
+	w, _, data = post("/api/v1/auth/mfa/verify", MFAVerifyRequest{MFAToken: "mfa-token", Code: "123456"})
+	require.Equal(t, http.StatusOK, w.Code)
+	claims, err := tokenService.ValidateToken(context.Background(), data["access_token"].(string))
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", claims.UserID)
+}
+
+func TestAuthHandler_VerifyMFA_AccountStatusChanged(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	tokenService := service.NewTokenService(&service.TokenServiceConfig{
+		PrivateKey:   privateKey,
+		PublicKey:    &privateKey.PublicKey,
+		KeyID:        "test-key",
+		Issuer:       "http://localhost:8080",
+		AccessExpiry: 15 * time.Minute,
+	})
+
+	tests := []struct {
+		name     string
+		change   func(u *model.User)
+		wantCode int
+	}{
+		{"禁用", func(u *model.User) { u.Status = model.StatusDisabled }, response.CodeForbidden},
+		{"锁定", func(u *model.User) {
+			lockedUntil := time.Now().Add(time.Hour)
+			u.LockedUntil = &lockedUntil
+		}, response.CodeAccountLocked},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := &model.User{Username: "alice", MFAEnabled: true, Status: model.StatusActive}
+			user.ID = "user-1"
+			h := NewAuthHandler(&stubUserService{user: user}, &stubAuthService{user: user}, tokenService, nil)
+			h.SetMFAService(&stubMFAService{})
+			router := gin.New()
+			router.POST("/api/v1/auth/login", h.Login)
+			router.POST("/api/v1/auth/mfa/verify", h.VerifyMFA)
+			post := func(path string, payload interface{}) *httptest.ResponseRecorder {
+				body, _ := json.Marshal(payload)
+				req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+				req.Header.Set("Content-Type", "application/json")
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+				return w
+			}
+
+			w := post("/api/v1/auth/login", LoginRequest{Username: "alice", Password: "Password123"})
+			require.Equal(t, http.StatusForbidden, w.Code)
+
+			// mfa_token 签发后账户状态发生变化
+			tt.change(user)
+
+			w = post("/api/v1/auth/mfa/verify", MFAVerifyRequest{MFAToken: "mfa-token", Code: "123456"})
+			var resp struct {
+				Code int                    `json:"code"`
+				Data map[string]interface{} `json:"data"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			assert.Equal(t, tt.wantCode, resp.Code)
+			assert.NotContains(t, resp.Data, "access_token")
+		})
+	}
+}
@@ -0,0 +1,75 @@
+// Package handler HTTP 处理器
+package handler
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pu-ac-cn/uac-backend/internal/service"
+	"github.com/pu-ac-cn/uac-backend/pkg/response"
+)
+
+// AuthorizedAppHandler 当前用户已授权应用管理处理器
+type AuthorizedAppHandler struct {
+	consentService service.ConsentService
+	appService     service.ApplicationService
+}
+
+// NewAuthorizedAppHandler 创建已授权应用管理处理器
+func NewAuthorizedAppHandler(consentSvc service.ConsentService, appSvc service.ApplicationService) *AuthorizedAppHandler {
+	return &AuthorizedAppHandler{consentService: consentSvc, appService: appSvc}
+}
+
+// AuthorizedApp 用户授权过的应用
+type AuthorizedApp struct {
+	ClientID    string    `json:"client_id"`
+	AppName     string    `json:"app_name"` // 应用已删除时为 client_id
+	Description string    `json:"description"`
+	Scopes      []string  `json:"scopes"`
+	GrantedAt   time.Time `json:"granted_at"` // 首次授权时间
+	UpdatedAt   time.Time `json:"updated_at"` // 最近一次扩大授权范围的时间
+}
+
+// ListAuthorizedApps 列出当前用户授权过的应用
+// GET /api/v1/auth/authorized-apps
+func (h *AuthorizedAppHandler) ListAuthorizedApps(c *gin.Context) {
+	ctx := c.Request.Context()
+	consents, err := h.consentService.ListByUser(ctx, c.GetString("user_id"))
+	if err != nil {
+		response.Error(c, response.CodeServerError)
+		return
+	}
+
+	list := make([]AuthorizedApp, len(consents))
+	for i, consent := range consents {
+		list[i] = AuthorizedApp{
+			ClientID:  consent.ClientID,
+			AppName:   consent.ClientID,
+			Scopes:    consent.Scopes,
+			GrantedAt: consent.CreatedAt,
+			UpdatedAt: consent.UpdatedAt,
+		}
+		if app, err := h.appService.GetByClientID(ctx, consent.ClientID); err == nil {
+			list[i].AppName = app.Name
+			list[i].Description = app.Description
+		}
+	}
+
+	response.Success(c, list)
+}
+
+// RevokeAuthorizedApp 撤销当前用户对应用的授权，该应用此前获得的令牌随之失效
+// DELETE /api/v1/auth/authorized-apps/:client_id
+func (h *AuthorizedAppHandler) RevokeAuthorizedApp(c *gin.Context) {
+	err := h.consentService.Revoke(c.Request.Context(), c.GetString("user_id"), c.Param("client_id"))
+	if err != nil {
+		if errors.Is(err, service.ErrConsentNotFound) {
+			response.ErrorWithMsg(c, response.CodeAppNotFound, err.Error())
+			return
+		}
+		response.Error(c, response.CodeServerError)
+		return
+	}
+	response.Success(c, gin.H{"message": "已撤销授权"})
+}
@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pu-ac-cn/uac-backend/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthorizedAppHandler_ListAndRevoke(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	consents := newStubConsentService()
+	consents.consents["user-1/client-a"] = &model.UserConsent{UserID: "user-1", ClientID: "client-a", Scopes: model.StringSlice{"openid", "email"}}
+	consents.consents["user-2/client-a"] = &model.UserConsent{UserID: "user-2", ClientID: "client-a", Scopes: model.StringSlice{"openid"}}
+	apps := &stubAppService{apps: map[string]*model.Application{
+		"client-a": {Name: "第三方应用", ClientID: "client-a", Description: "示例"},
+	}}
+	h := NewAuthorizedAppHandler(consents, apps)
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", "user-1")
+		c.Next()
+	})
+	router.GET("/api/v1/auth/authorized-apps", h.ListAuthorizedApps)
+	router.DELETE("/api/v1/auth/authorized-apps/:client_id", h.RevokeAuthorizedApp)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/auth/authorized-apps", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Data []AuthorizedApp `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Data, 1, "只列出当前用户的授权")
+	assert.Equal(t, "第三方应用", resp.Data[0].AppName)
+	assert.Equal(t, []string{"openid", "email"}, resp.Data[0].Scopes)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/v1/auth/authorized-apps/client-a", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{"user-1/client-a"}, consents.revoked)
+	assert.Contains(t, consents.consents, "user-2/client-a", "不影响其他用户的授权")
+
+	// 已撤销或从未授权
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/v1/auth/authorized-apps/client-a", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,136 @@
+// Package handler HTTP 处理器
+package handler
+
+import (
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/pu-ac-cn/uac-backend/internal/service"
+)
+
+// CAS 协议错误码
+const (
+	CASInvalidRequest = "INVALID_REQUEST"
+	CASInvalidTicket  = "INVALID_TICKET"
+	CASInvalidService = "INVALID_SERVICE"
+	CASInternalError  = "INTERNAL_ERROR"
+)
+
+// casNamespace CAS 响应 XML 命名空间
+const casNamespace = "http://www.yale.edu/tp/cas"
+
+// CASAttributes CAS 用户属性
+type CASAttributes struct {
+	UserID      string `xml:"cas:user_id" json:"user_id"`
+	Email       string `xml:"cas:email,omitempty" json:"email,omitempty"`
+	DisplayName string `xml:"cas:display_name,omitempty" json:"display_name,omitempty"`
+}
+
+// CASAuthenticationSuccess CAS 验证成功结果
+type CASAuthenticationSuccess struct {
+	User       string         `xml:"cas:user" json:"user"`
+	Attributes *CASAttributes `xml:"cas:attributes,omitempty" json:"attributes,omitempty"`
+}
+
+// CASAuthenticationFailure CAS 验证失败结果
+type CASAuthenticationFailure struct {
+	Code        string `xml:"code,attr" json:"code"`
+	Description string `xml:",chardata" json:"description"`
+}
+
+// CASServiceResponse CAS 验证响应，XML 与 JSON 使用同一结构
+type CASServiceResponse struct {
+	XMLName   xml.Name                  `xml:"cas:serviceResponse" json:"-"`
+	Namespace string                    `xml:"xmlns:cas,attr" json:"-"`
+	Success   *CASAuthenticationSuccess `xml:"cas:authenticationSuccess,omitempty" json:"authenticationSuccess,omitempty"`
+	Failure   *CASAuthenticationFailure `xml:"cas:authenticationFailure,omitempty" json:"authenticationFailure,omitempty"`
+}
+
+// CASHandler CAS 协议处理器
+type CASHandler struct {
+	sessionService service.SessionService
+	userService    service.UserService
+}
+
+// NewCASHandler 创建 CAS 处理器
+func NewCASHandler(sessionSvc service.SessionService, userSvc service.UserService) *CASHandler {
+	return &CASHandler{
+		sessionService: sessionSvc,
+		userService:    userSvc,
+	}
+}
+
+// ServiceValidate 验证 Service Ticket
+// GET /cas/serviceValidate
+// GET /cas/p3/serviceValidate
+// 默认返回 XML，format=json 或 Accept: application/json 时返回 JSON
+func (h *CASHandler) ServiceValidate(c *gin.Context) {
+	ticket := c.Query("ticket")
+	svc := c.Query("service")
+	if ticket == "" || svc == "" {
+		h.failure(c, CASInvalidRequest, "缺少 ticket 或 service 参数")
+		return
+	}
+
+	st, err := h.sessionService.ValidateST(c.Request.Context(), ticket, svc)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrSTNotFound), errors.Is(err, service.ErrSTExpired), errors.Is(err, service.ErrSTUsed):
+			h.failure(c, CASInvalidTicket, "票据 "+ticket+" 无效: "+err.Error())
+		case errors.Is(err, service.ErrSTServiceMismatch):
+			h.failure(c, CASInvalidService, "票据 "+ticket+" 与服务不匹配")
+		default:
+			h.failure(c, CASInternalError, "票据验证失败")
+		}
+		return
+	}
+
+	user, err := h.userService.GetByID(c.Request.Context(), st.UserID)
+	if err != nil {
+		h.failure(c, CASInvalidTicket, "票据 "+ticket+" 对应的用户不存在")
+		return
+	}
+
+	h.respond(c, &CASServiceResponse{
+		Success: &CASAuthenticationSuccess{
+			User: user.Username,
+			Attributes: &CASAttributes{
+				UserID:      user.ID,
+				Email:       user.Email,
+				DisplayName: user.DisplayName,
+			},
+		},
+	})
+}
+
+// failure 返回 CAS 验证失败响应
+func (h *CASHandler) failure(c *gin.Context, code, description string) {
+	h.respond(c, &CASServiceResponse{
+		Failure: &CASAuthenticationFailure{Code: code, Description: description},
+	})
+}
+
+// respond 按协商的格式输出 CAS 响应，CAS 协议的成功与失败均返回 200
+func (h *CASHandler) respond(c *gin.Context, resp *CASServiceResponse) {
+	if casFormat(c) == binding.MIMEJSON {
+		c.JSON(http.StatusOK, gin.H{"serviceResponse": resp})
+		return
+	}
+	resp.Namespace = casNamespace
+	c.XML(http.StatusOK, resp)
+}
+
+// casFormat 确定响应格式：format 参数优先，其次为 Accept 头，默认 XML
+func casFormat(c *gin.Context) string {
+	switch strings.ToLower(c.Query("format")) {
+	case "json":
+		return binding.MIMEJSON
+	case "xml":
+		return binding.MIMEXML
+	}
+	return c.NegotiateFormat(binding.MIMEXML, binding.MIMEJSON)
+}
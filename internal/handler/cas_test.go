@@ -0,0 +1,227 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/pu-ac-cn/uac-backend/internal/model"
+	"github.com/pu-ac-cn/uac-backend/internal/service"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const casTestService = "https://app.example.com/login"
+
+// stubCASUserService 仅实现 GetByID 的用户服务
+type stubCASUserService struct {
+	service.UserService
+	user *model.User
+}
+
+func (s *stubCASUserService) GetByID(ctx context.Context, id string) (*model.User, error) {
+	if s.user == nil || s.user.ID != id {
+		return nil, service.ErrUserNotFound
+	}
+	return s.user, nil
+}
+
+// casResult XML 与 JSON 响应解析后的统一结果
+type casResult struct {
+	User        string
+	UserID      string
+	Email       string
+	DisplayName string
+	Code        string
+	Description string
+}
+
+// casXMLBody 按本地名称解析 CAS XML 响应
+type casXMLBody struct {
+	Success *struct {
+		User       string `xml:"user"`
+		Attributes struct {
+			UserID      string `xml:"user_id"`
+			Email       string `xml:"email"`
+			DisplayName string `xml:"display_name"`
+		} `xml:"attributes"`
+	} `xml:"authenticationSuccess"`
+	Failure *struct {
+		Code        string `xml:"code,attr"`
+		Description string `xml:",chardata"`
+	} `xml:"authenticationFailure"`
+}
+
+func setupCASTest(t *testing.T) (*gin.Engine, service.SessionService) {
+	gin.SetMode(gin.TestMode)
+
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		client.Close()
+		mr.Close()
+	})
+
+	sessionService := service.NewSessionService(client, nil)
+	user := &model.User{Username: "alice", Email: "alice@example.com", DisplayName: "Alice"}
+	user.ID = "user-1"
+	h := NewCASHandler(sessionService, &stubCASUserService{user: user})
+
+	router := gin.New()
+	router.GET("/cas/serviceValidate", h.ServiceValidate)
+	router.GET("/cas/p3/serviceValidate", h.ServiceValidate)
+	return router, sessionService
+}
+
+// issueST 为测试用户签发 Service Ticket
+func issueST(t *testing.T, sessionService service.SessionService) string {
+	ctx := context.Background()
+	tgt, err := sessionService.CreateTGT(ctx, "user-1", "session-1")
+	require.NoError(t, err)
+	st, err := sessionService.CreateST(ctx, tgt.ID, casTestService)
+	require.NoError(t, err)
+	return st.Ticket
+}
+
+// casValidate 调用验证端点并解析响应，query 为附加参数
+func casValidate(t *testing.T, router *gin.Engine, ticket, svc, query, accept string) (*casResult, string) {
+	target := "/cas/p3/serviceValidate?ticket=" + url.QueryEscape(ticket) + "&service=" + url.QueryEscape(svc)
+	if query != "" {
+		target += "&" + query
+	}
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	contentType := w.Header().Get("Content-Type")
+	result := &casResult{}
+	if strings.HasPrefix(contentType, "application/json") {
+		var body struct {
+			ServiceResponse CASServiceResponse `json:"serviceResponse"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		if s := body.ServiceResponse.Success; s != nil {
+			result.User = s.User
+			if s.Attributes != nil {
+				result.UserID = s.Attributes.UserID
+				result.Email = s.Attributes.Email
+				result.DisplayName = s.Attributes.DisplayName
+			}
+		}
+		if f := body.ServiceResponse.Failure; f != nil {
+			result.Code = f.Code
+			result.Description = f.Description
+		}
+		return result, contentType
+	}
+
+	assert.Contains(t, w.Body.String(), `xmlns:cas="http://www.yale.edu/tp/cas"`)
+	var body casXMLBody
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &body))
+	if s := body.Success; s != nil {
+		result.User = s.User
+		result.UserID = s.Attributes.UserID
+		result.Email = s.Attributes.Email
+		result.DisplayName = s.Attributes.DisplayName
+	}
+	if f := body.Failure; f != nil {
+		result.Code = f.Code
+		result.Description = f.Description
+	}
+	return result, contentType
+}
+
+func TestCASHandler_ServiceValidate_XMLAndJSONEquivalent(t *testing.T) {
+	router, sessionService := setupCASTest(t)
+
+	xmlResult, xmlType := casValidate(t, router, issueST(t, sessionService), casTestService, "", "")
+	jsonResult, jsonType := casValidate(t, router, issueST(t, sessionService), casTestService, "format=json", "")
+
+	assert.True(t, strings.HasPrefix(xmlType, "application/xml"))
+	assert.True(t, strings.HasPrefix(jsonType, "application/json"))
+	assert.Equal(t, &casResult{User: "alice", UserID: "user-1", Email: "alice@example.com", DisplayName: "Alice"}, xmlResult)
+	assert.Equal(t, xmlResult, jsonResult)
+}
+
+func TestCASHandler_ServiceValidate_FailureEquivalent(t *testing.T) {
+	router, sessionService := setupCASTest(t)
+
+	tests := []struct {
+		name   string
+		ticket string
+		svc    string
+		code   string
+	}{
+		{"缺少参数", "", casTestService, CASInvalidRequest},
+		{"票据不存在", "ST-unknown", casTestService, CASInvalidTicket},
+		{"服务不匹配", "", "https://other.example.com", CASInvalidService},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ticket := func() string {
+				if tt.code == CASInvalidService {
+					return issueST(t, sessionService)
+				}
+				return tt.ticket
+			}
+			xmlResult, _ := casValidate(t, router, ticket(), tt.svc, "", "")
+			jsonResult, _ := casValidate(t, router, ticket(), tt.svc, "", "application/json")
+
+			assert.Equal(t, tt.code, xmlResult.Code)
+			assert.Empty(t, xmlResult.User)
+			assert.NotEmpty(t, xmlResult.Description)
+			assert.Equal(t, xmlResult.Code, jsonResult.Code)
+			assert.Empty(t, jsonResult.User)
+		})
+	}
+}
+
+func TestCASHandler_ServiceValidate_TicketSingleUse(t *testing.T) {
+	router, sessionService := setupCASTest(t)
+	ticket := issueST(t, sessionService)
+
+	first, _ := casValidate(t, router, ticket, casTestService, "format=json", "")
+	assert.Equal(t, "alice", first.User)
+
+	second, _ := casValidate(t, router, ticket, casTestService, "format=json", "")
+	assert.Equal(t, CASInvalidTicket, second.Code)
+}
+
+func TestCASFormat(t *testing.T) {
+	router, sessionService := setupCASTest(t)
+
+	tests := []struct {
+		name   string
+		query  string
+		accept string
+		want   string
+	}{
+		{"默认 XML", "", "", "application/xml"},
+		{"任意类型", "", "*/*", "application/xml"},
+		{"Accept JSON", "", "application/json", "application/json"},
+		{"Accept XML 优先", "", "application/xml, application/json", "application/xml"},
+		{"format 参数优先", "format=xml", "application/json", "application/xml"},
+		{"format=json", "format=JSON", "text/html", "application/json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, contentType := casValidate(t, router, issueST(t, sessionService), casTestService, tt.query, tt.accept)
+			assert.True(t, strings.HasPrefix(contentType, tt.want), contentType)
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pu-ac-cn/uac-backend/internal/repository"
+	"github.com/pu-ac-cn/uac-backend/pkg/response"
+)
+
+// impactPreviewLimit 删除预览中每类关联资源最多列出的数量
+const impactPreviewLimit = 100
+
+// ImpactItem 受影响的资源
+type ImpactItem struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// ImpactGroup 同一类受影响的资源，Total 为总数，Items 最多列出 impactPreviewLimit 个
+type ImpactGroup struct {
+	Total int64        `json:"total"`
+	Items []ImpactItem `json:"items"`
+}
+
+// DeleteImpact 删除操作的影响预览
+type DeleteImpact struct {
+	DryRun   bool                   `json:"dry_run"`
+	Resource string                 `json:"resource"`
+	ID       string                 `json:"id"`
+	Name     string                 `json:"name"`
+	Affected map[string]ImpactGroup `json:"affected"`
+}
+
+// isDryRun 是否为试运行请求（?dry_run=true）
+// 试运行执行全部校验并计算影响范围，但不修改任何数据
+func isDryRun(c *gin.Context) bool {
+	dryRun, _ := strconv.ParseBool(c.Query("dry_run"))
+	return dryRun
+}
+
+// newDeleteImpact 创建删除影响预览
+func newDeleteImpact(resource, id, name string) *DeleteImpact {
+	return &DeleteImpact{
+		DryRun:   true,
+		Resource: resource,
+		ID:       id,
+		Name:     name,
+		Affected: make(map[string]ImpactGroup),
+	}
+}
+
+// impactPage 删除预览查询关联资源使用的分页
+func impactPage() *repository.Pagination {
+	return &repository.Pagination{Page: 1, PageSize: impactPreviewLimit}
+}
+
+// respondDeleteImpact 返回删除影响预览
+func respondDeleteImpact(c *gin.Context, impact *DeleteImpact) {
+	response.SuccessWithMsg(c, "试运行，未执行删除", impact)
+}
@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 各资源可通过 ?fields= 选择的响应字段
+var (
+	userResponseFields = []string{"id", "username", "email", "display_name", "phone", "status", "email_verified", "phone_verified", "created_at", "updated_at"}
+	appResponseFields  = []string{"id", "org_id", "name", "description", "client_id", "redirect_uris", "post_logout_redirect_uris", "allow_subpath_redirect", "allowed_scopes", "allowed_grant_types", "access_token_ttl", "refresh_token_ttl", "oauth_mode", "service_account", "status", "created_by", "created_at", "updated_at"}
+	orgResponseFields  = []string{"id", "tenant_id", "name", "slug", "description", "branding", "status", "max_apps", "max_users", "created_at", "updated_at"}
+)
+
+// fieldSelector 请求选择的响应字段，nil 表示返回完整响应
+type fieldSelector map[string]bool
+
+// parseFields 解析 ?fields=id,username 参数，字段不在 allowed 内时返回错误
+// 只在构造响应时裁剪字段，不影响数据库查询
+func parseFields(c *gin.Context, allowed []string) (fieldSelector, error) {
+	value := strings.TrimSpace(c.Query("fields"))
+	if value == "" {
+		return nil, nil
+	}
+	allowedSet := make(map[string]bool, len(allowed))
+	for _, field := range allowed {
+		allowedSet[field] = true
+	}
+	selected := fieldSelector{}
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if !allowedSet[field] {
+			return nil, fmt.Errorf("不支持的字段: %s", field)
+		}
+		selected[field] = true
+	}
+	return selected, nil
+}
+
+// apply 仅保留选择的字段
+func (f fieldSelector) apply(data gin.H) gin.H {
+	if f == nil {
+		return data
+	}
+	projected := make(gin.H, len(f))
+	for field := range f {
+		if value, ok := data[field]; ok {
+			projected[field] = value
+		}
+	}
+	return projected
+}
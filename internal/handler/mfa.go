@@ -0,0 +1,94 @@
+// Package handler HTTP 处理器
+package handler
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pu-ac-cn/uac-backend/internal/service"
+	"github.com/pu-ac-cn/uac-backend/pkg/response"
+)
+
+// MFAHandler 多因素认证管理处理器
+type MFAHandler struct {
+	mfaService service.MFAService
+}
+
+// NewMFAHandler 创建多因素认证管理处理器
+func NewMFAHandler(mfaSvc service.MFAService) *MFAHandler {
+	return &MFAHandler{mfaService: mfaSvc}
+}
+
+// TOTPCodeRequest TOTP 验证码请求
+type TOTPCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// EnrollTOTP 为当前用户生成 TOTP 密钥，返回 otpauth URI 供生成二维码
+// POST /api/v1/auth/mfa/totp
+func (h *MFAHandler) EnrollTOTP(c *gin.Context) {
+	enrollment, err := h.mfaService.EnrollTOTP(c.Request.Context(), c.GetString("user_id"))
+	if err != nil {
+		respondMFAError(c, err)
+		return
+	}
+	response.Success(c, enrollment)
+}
+
+// ConfirmTOTP 校验身份验证器生成的验证码，成功后开启 MFA
+// POST /api/v1/auth/mfa/totp/verify
+func (h *MFAHandler) ConfirmTOTP(c *gin.Context) {
+	var req TOTPCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ErrorWithMsg(c, response.CodeInvalidRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	if err := h.mfaService.VerifyTOTP(c.Request.Context(), c.GetString("user_id"), req.Code); err != nil {
+		respondMFAError(c, err)
+		return
+	}
+	response.Success(c, gin.H{"mfa_enabled": true})
+}
+
+// DisableTOTP 校验验证码后关闭当前用户的 MFA
+// DELETE /api/v1/auth/mfa/totp
+func (h *MFAHandler) DisableTOTP(c *gin.Context) {
+	var req TOTPCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ErrorWithMsg(c, response.CodeInvalidRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	if err := h.mfaService.DisableTOTP(c.Request.Context(), c.GetString("user_id"), req.Code); err != nil {
+		respondMFAError(c, err)
+		return
+	}
+	response.Success(c, gin.H{"mfa_enabled": false})
+}
+
+// RegenerateRecoveryCodes 为当前用户生成新的恢复码，旧恢复码全部作废
+// 明文恢复码只在本次响应中返回
+// POST /api/v1/auth/mfa/recovery-codes
+func (h *MFAHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	codes, err := h.mfaService.GenerateRecoveryCodes(c.Request.Context(), c.GetString("user_id"))
+	if err != nil {
+		respondMFAError(c, err)
+		return
+	}
+	response.Success(c, gin.H{"recovery_codes": codes})
+}
+
+// respondMFAError 将 MFA 服务错误映射为响应
+func respondMFAError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrUserNotFound):
+		response.Error(c, response.CodeUserNotFound)
+	case errors.Is(err, service.ErrInvalidTOTPCode):
+		response.Error(c, response.CodeInvalidCode)
+	case errors.Is(err, service.ErrMFAAlreadyEnabled), errors.Is(err, service.ErrMFANotEnrolled), errors.Is(err, service.ErrMFANotEnabled):
+		response.ErrorWithMsg(c, response.CodeInvalidRequest, err.Error())
+	default:
+		response.Error(c, response.CodeServerError)
+	}
+}
@@ -4,13 +4,23 @@ package handler
 import (
 	"crypto/sha256"
 	"encoding/base64"
+	"errors"
 	"net/http"
 	"net/url"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/pu-ac-cn/uac-backend/internal/feature"
+	"github.com/pu-ac-cn/uac-backend/internal/metrics"
+	"github.com/pu-ac-cn/uac-backend/internal/middleware"
+	"github.com/pu-ac-cn/uac-backend/internal/model"
+	"github.com/pu-ac-cn/uac-backend/internal/repository"
 	"github.com/pu-ac-cn/uac-backend/internal/service"
 	"github.com/pu-ac-cn/uac-backend/pkg/response"
+	"go.uber.org/zap"
 )
 
 // OAuthHandler OAuth 2.0/2.1 处理器
@@ -18,39 +28,87 @@ type OAuthHandler struct {
 	appService     service.ApplicationService
 	tokenService   service.TokenService
 	sessionService service.SessionService
+	rbacService    service.RBACService
+	scopeService   service.ScopeService        // 同意页的 scope 说明，未设置时以代码作为名称
+	orgService     service.OrganizationService // 同意页展示应用所属组织的品牌
+	consentService service.ConsentService      // 用户授权记录，未设置时不展示同意页直接签发授权码
+	features       *feature.Flags
+	// 授权时不校验 offline_access 与 refresh_token 授权类型是否匹配
+	skipOfflineAccessCheck bool
 }
 
 // NewOAuthHandler 创建 OAuth 处理器
-func NewOAuthHandler(appSvc service.ApplicationService, tokenSvc service.TokenService, sessionSvc service.SessionService) *OAuthHandler {
-	return &OAuthHandler{
+// features 可选，未提供时各功能开关使用默认值
+func NewOAuthHandler(appSvc service.ApplicationService, tokenSvc service.TokenService, sessionSvc service.SessionService, features ...*feature.Flags) *OAuthHandler {
+	h := &OAuthHandler{
 		appService:     appSvc,
 		tokenService:   tokenSvc,
 		sessionService: sessionSvc,
 	}
+	if len(features) > 0 {
+		h.features = features[0]
+	}
+	return h
+}
+
+// SetRBACService 设置 RBAC 服务，用于向服务账号应用的客户端凭证令牌写入角色和权限
+func (h *OAuthHandler) SetRBACService(rbacSvc service.RBACService) {
+	h.rbacService = rbacSvc
+}
+
+// SetConsentServices 设置授权同意页使用的 scope 说明服务和组织服务
+func (h *OAuthHandler) SetConsentServices(scopeSvc service.ScopeService, orgSvc service.OrganizationService) {
+	h.scopeService = scopeSvc
+	h.orgService = orgSvc
+}
+
+// SetConsentService 设置用户授权记录服务，设置后首次授权应用需要用户在同意页确认
+func (h *OAuthHandler) SetConsentService(consentSvc service.ConsentService) {
+	h.consentService = consentSvc
+}
+
+// SetRequireRefreshGrantForOfflineAccess 设置授权时申请 offline_access 是否要求应用允许 refresh_token，默认要求
+func (h *OAuthHandler) SetRequireRefreshGrantForOfflineAccess(require bool) {
+	h.skipOfflineAccessCheck = !require
 }
 
 // AuthorizeRequest 授权请求参数
 type AuthorizeRequest struct {
-	ResponseType        string `form:"response_type" binding:"required"`
-	ClientID            string `form:"client_id" binding:"required"`
-	RedirectURI         string `form:"redirect_uri" binding:"required"`
-	Scope               string `form:"scope"`
-	State               string `form:"state"`
-	CodeChallenge       string `form:"code_challenge"`
-	CodeChallengeMethod string `form:"code_challenge_method"`
-	Nonce               string `form:"nonce"` // OIDC
+	ResponseType        string `form:"response_type" json:"response_type" binding:"required"`
+	ClientID            string `form:"client_id" json:"client_id" binding:"required"`
+	RedirectURI         string `form:"redirect_uri" json:"redirect_uri" binding:"required"`
+	Scope               string `form:"scope" json:"scope"`
+	State               string `form:"state" json:"state"`
+	CodeChallenge       string `form:"code_challenge" json:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method" json:"code_challenge_method"`
+	Nonce               string `form:"nonce" json:"nonce"`   // OIDC
+	Prompt              string `form:"prompt" json:"prompt"` // OIDC：none 静默鉴权，login 强制重新登录，consent 强制展示同意页
 }
 
 // TokenRequest 令牌请求参数
 type TokenRequest struct {
-	GrantType    string `form:"grant_type" binding:"required"`
-	Code         string `form:"code"`
-	RedirectURI  string `form:"redirect_uri"`
-	ClientID     string `form:"client_id"`
-	ClientSecret string `form:"client_secret"`
-	CodeVerifier string `form:"code_verifier"`
-	RefreshToken string `form:"refresh_token"`
-	Scope        string `form:"scope"`
+	GrantType    string `form:"grant_type" json:"grant_type" binding:"required"`
+	Code         string `form:"code" json:"code"`
+	RedirectURI  string `form:"redirect_uri" json:"redirect_uri"`
+	ClientID     string `form:"client_id" json:"client_id"`
+	ClientSecret string `form:"client_secret" json:"client_secret"`
+	CodeVerifier string `form:"code_verifier" json:"code_verifier"`
+	RefreshToken string `form:"refresh_token" json:"refresh_token"`
+	Scope        string `form:"scope" json:"scope"`
+}
+
+// AuthorizeDecisionRequest 同意页提交的授权决定，携带原始授权请求参数
+type AuthorizeDecisionRequest struct {
+	AuthorizeRequest
+	Decision string `json:"decision" binding:"required,oneof=approve deny"` // approve 同意，deny 拒绝
+}
+
+// authorizeError 授权请求校验失败的原因
+// redirectURI 为空表示客户端或重定向 URI 不可信，不能跳转回客户端
+type authorizeError struct {
+	redirectURI string
+	code        string
+	description string
 }
 
 // Authorize 授权端点
@@ -62,36 +120,148 @@ func (h *OAuthHandler) Authorize(c *gin.Context) {
 		return
 	}
 
+	if _, authErr := h.validateAuthorizeRequest(c, &req); authErr != nil {
+		h.redirectError(c, authErr.redirectURI, authErr.code, authErr.description, req.State)
+		return
+	}
+	prompts := strings.Fields(req.Prompt)
+
+	// 检查用户是否已登录
+	userID, exists := c.Get("user_id")
+	if !exists {
+		// 静默鉴权不展示登录页，直接告知客户端需要登录
+		if containsScope(prompts, "none") {
+			h.redirectError(c, req.RedirectURI, "login_required", "用户未登录", req.State)
+			return
+		}
+		h.redirectToLogin(c)
+		return
+	}
+
+	// 强制重新登录：即使已有会话也跳转登录页
+	if containsScope(prompts, "login") {
+		h.redirectToLogin(c)
+		return
+	}
+
+	// 首次授权或 prompt=consent 时由用户在同意页确认，确认后经 /oauth/authorize/decision 签发授权码
+	if h.consentService != nil {
+		consented := false
+		if !containsScope(prompts, "consent") {
+			var err error
+			consented, err = h.consentService.HasConsent(c.Request.Context(), userID.(string), req.ClientID, strings.Fields(req.Scope))
+			if err != nil {
+				h.redirectError(c, req.RedirectURI, "server_error", "查询授权记录失败", req.State)
+				return
+			}
+		}
+		if !consented {
+			if containsScope(prompts, "none") {
+				h.redirectError(c, req.RedirectURI, "consent_required", "用户尚未授权该应用", req.State)
+				return
+			}
+			c.Redirect(http.StatusFound, "/consent?"+c.Request.URL.RawQuery)
+			return
+		}
+	}
+
+	redirectURL, err := h.issueAuthorizationCode(c, &req, userID.(string))
+	if err != nil {
+		h.redirectError(c, req.RedirectURI, "server_error", "生成授权码失败", req.State)
+		return
+	}
+	c.Redirect(http.StatusFound, redirectURL)
+}
+
+// AuthorizeDecision 处理用户在同意页的授权决定
+// 同意时记录授权并签发授权码，拒绝时返回带 error=access_denied 的回调地址，由前端跳转回客户端
+// POST /oauth/authorize/decision
+func (h *OAuthHandler) AuthorizeDecision(c *gin.Context) {
+	// 只接受用户登录签发的令牌，客户端获得的访问令牌不能代替用户同意授权
+	if c.GetString("client_id") != "" || c.GetString("session_id") == "" {
+		response.Error(c, response.CodeForbidden)
+		return
+	}
+
+	var req AuthorizeDecisionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ErrorWithMsg(c, response.CodeInvalidRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	if _, authErr := h.validateAuthorizeRequest(c, &req.AuthorizeRequest); authErr != nil {
+		switch authErr.code {
+		case "invalid_client":
+			response.ErrorWithMsg(c, response.CodeInvalidClient, authErr.description)
+		case "invalid_scope":
+			response.ErrorWithMsg(c, response.CodeInvalidScope, authErr.description)
+		default:
+			response.ErrorWithMsg(c, response.CodeInvalidRequest, authErr.description)
+		}
+		return
+	}
+
+	if req.Decision == "deny" {
+		response.ErrorWithData(c, response.CodeAccessDenied, gin.H{
+			"redirect_uri": buildRedirectURL(req.RedirectURI, url.Values{
+				"error":             {"access_denied"},
+				"error_description": {"用户拒绝授权"},
+			}, req.State),
+		})
+		return
+	}
+
+	userID := c.GetString("user_id")
+	if h.consentService != nil {
+		if err := h.consentService.Grant(c.Request.Context(), userID, req.ClientID, strings.Fields(req.Scope)); err != nil {
+			response.Error(c, response.CodeServerError)
+			return
+		}
+	}
+
+	redirectURL, err := h.issueAuthorizationCode(c, &req.AuthorizeRequest, userID)
+	if err != nil {
+		response.Error(c, response.CodeServerError)
+		return
+	}
+	response.Success(c, gin.H{"redirect_uri": redirectURL})
+}
+
+// validateAuthorizeRequest 校验授权请求的客户端、重定向 URI、响应类型、PKCE、权限范围和 prompt
+// 未指定 code_challenge_method 时补全为 plain
+func (h *OAuthHandler) validateAuthorizeRequest(c *gin.Context, req *AuthorizeRequest) (*model.Application, *authorizeError) {
 	// 验证客户端
 	app, err := h.appService.GetByClientID(c.Request.Context(), req.ClientID)
+	// 客户端或重定向 URI 无效时直接返回错误，不能跳转到未经验证的地址
 	if err != nil {
-		h.redirectError(c, req.RedirectURI, "invalid_client", "客户端不存在", req.State)
-		return
+		return nil, &authorizeError{code: "invalid_client", description: "客户端不存在"}
 	}
 
 	// 验证重定向 URI
-	if !h.isValidRedirectURI(app.RedirectURIs, req.RedirectURI) {
-		h.redirectError(c, req.RedirectURI, "invalid_request", "重定向 URI 无效", req.State)
-		return
+	if !h.isValidRedirectURI(app.RedirectURIs, req.RedirectURI, app.AllowSubpathRedirect) {
+		return nil, &authorizeError{code: "invalid_request", description: "重定向 URI 无效"}
+	}
+	if isInsecureRedirectURI(req.RedirectURI) && !h.features.Use(feature.AllowHTTPRedirects) {
+		return nil, &authorizeError{code: "invalid_request", description: "重定向 URI 必须使用 https"}
+	}
+	if !h.allowOAuthVersion(app) {
+		return nil, &authorizeError{redirectURI: req.RedirectURI, code: "unauthorized_client", description: "OAuth 2.0 模式已停用"}
 	}
 
 	// 验证响应类型
 	if req.ResponseType != "code" {
 		// OAuth 2.1 不支持隐式模式
 		if req.ResponseType == "token" && app.OAuthVersion == "2.1" {
-			h.redirectError(c, req.RedirectURI, "unsupported_response_type", "OAuth 2.1 不支持隐式模式", req.State)
-			return
+			return nil, &authorizeError{redirectURI: req.RedirectURI, code: "unsupported_response_type", description: "OAuth 2.1 不支持隐式模式"}
 		}
 		if req.ResponseType != "token" {
-			h.redirectError(c, req.RedirectURI, "unsupported_response_type", "不支持的响应类型", req.State)
-			return
+			return nil, &authorizeError{redirectURI: req.RedirectURI, code: "unsupported_response_type", description: "不支持的响应类型"}
 		}
 	}
 
 	// OAuth 2.1 强制要求 PKCE
 	if app.OAuthVersion == "2.1" && req.CodeChallenge == "" {
-		h.redirectError(c, req.RedirectURI, "invalid_request", "OAuth 2.1 要求使用 PKCE", req.State)
-		return
+		return nil, &authorizeError{redirectURI: req.RedirectURI, code: "invalid_request", description: "OAuth 2.1 要求使用 PKCE"}
 	}
 
 	// 验证 code_challenge_method
@@ -100,77 +270,220 @@ func (h *OAuthHandler) Authorize(c *gin.Context) {
 			req.CodeChallengeMethod = "plain"
 		}
 		if req.CodeChallengeMethod != "plain" && req.CodeChallengeMethod != "S256" {
-			h.redirectError(c, req.RedirectURI, "invalid_request", "不支持的 code_challenge_method", req.State)
-			return
+			return nil, &authorizeError{redirectURI: req.RedirectURI, code: "invalid_request", description: "不支持的 code_challenge_method"}
+		}
+		if req.CodeChallengeMethod == "plain" && !h.features.Use(feature.AllowPlainPKCE) {
+			return nil, &authorizeError{redirectURI: req.RedirectURI, code: "invalid_request", description: "不支持 plain 方式的 PKCE，请使用 S256"}
 		}
 	}
 
 	// 验证权限范围
 	requestedScopes := strings.Split(req.Scope, " ")
 	if !h.isValidScopes(app.AllowedScopes, requestedScopes) {
-		h.redirectError(c, req.RedirectURI, "invalid_scope", "请求的权限范围无效", req.State)
-		return
+		return nil, &authorizeError{redirectURI: req.RedirectURI, code: "invalid_scope", description: "请求的权限范围无效"}
+	}
+	// offline_access 意味着客户端期望刷新令牌，应用不允许 refresh_token 时提前拒绝
+	if !h.skipOfflineAccessCheck && containsScope(requestedScopes, "offline_access") && !app.AllowsGrantType("refresh_token") {
+		return nil, &authorizeError{redirectURI: req.RedirectURI, code: "invalid_scope", description: "应用不允许使用刷新令牌，不能申请 offline_access"}
 	}
 
-	// 检查用户是否已登录
-	userID, exists := c.Get("user_id")
-	if !exists {
-		// 重定向到登录页面，登录后返回
-		loginURL := "/login?redirect=" + url.QueryEscape(c.Request.URL.String())
-		c.Redirect(http.StatusFound, loginURL)
-		return
+	// 验证 prompt：none 不能与其他值同时使用
+	prompts := strings.Fields(req.Prompt)
+	if containsScope(prompts, "none") && len(prompts) > 1 {
+		return nil, &authorizeError{redirectURI: req.RedirectURI, code: "invalid_request", description: "prompt=none 不能与其他值同时使用"}
 	}
+	return app, nil
+}
 
-	// 生成授权码
+// issueAuthorizationCode 为已登录用户签发授权码，返回带 code 和 state 的回调地址
+func (h *OAuthHandler) issueAuthorizationCode(c *gin.Context, req *AuthorizeRequest, userID string) (string, error) {
 	authCode := &service.AuthorizationCode{
 		ClientID:            req.ClientID,
-		UserID:              userID.(string),
+		UserID:              userID,
+		Username:            c.GetString("username"),
+		Email:               c.GetString("email"),
 		RedirectURI:         req.RedirectURI,
 		Scopes:              strings.Split(req.Scope, " "),
 		CodeChallenge:       req.CodeChallenge,
 		CodeChallengeMethod: req.CodeChallengeMethod,
+		Nonce:               req.Nonce,
+		AuthTime:            h.authTime(c),
+		SessionID:           c.GetString("session_id"),
 	}
 
 	code, err := h.tokenService.GenerateAuthorizationCode(c.Request.Context(), authCode)
 	if err != nil {
-		h.redirectError(c, req.RedirectURI, "server_error", "生成授权码失败", req.State)
-		return
+		return "", err
 	}
+	return buildRedirectURL(req.RedirectURI, url.Values{"code": {code}}, req.State), nil
+}
 
-	// 重定向回客户端
-	redirectURL, _ := url.Parse(req.RedirectURI)
+// buildRedirectURL 在回调地址上追加参数，state 非空时原样带回
+func buildRedirectURL(redirectURI string, params url.Values, state string) string {
+	redirectURL, err := url.Parse(redirectURI)
+	if err != nil {
+		return redirectURI
+	}
 	query := redirectURL.Query()
-	query.Set("code", code)
-	if req.State != "" {
-		query.Set("state", req.State)
+	for key, values := range params {
+		query[key] = values
+	}
+	if state != "" {
+		query.Set("state", state)
 	}
 	redirectURL.RawQuery = query.Encode()
+	return redirectURL.String()
+}
 
-	c.Redirect(http.StatusFound, redirectURL.String())
+// authTime 获取用户实际完成认证的时间
+// 优先使用登录会话的创建时间，无会话时使用访问令牌的签发时间
+func (h *OAuthHandler) authTime(c *gin.Context) time.Time {
+	if sessionID := c.GetString("session_id"); sessionID != "" && h.sessionService != nil {
+		if session, err := h.sessionService.Get(c.Request.Context(), sessionID); err == nil {
+			return session.CreatedAt
+		}
+	}
+	if claims, ok := c.Get("claims"); ok {
+		if tokenClaims, ok := claims.(*service.TokenClaims); ok && tokenClaims.IssuedAt != nil {
+			return tokenClaims.IssuedAt.Time
+		}
+	}
+	return time.Now()
+}
+
+// ScopeInfo 同意页展示的 scope 说明
+type ScopeInfo struct {
+	Code        string `json:"code"`
+	DisplayName string `json:"display_name"`
+	Description string `json:"description"`
+}
+
+// ConsentInfo 授权同意页数据
+type ConsentInfo struct {
+	ClientID    string      `json:"client_id"`
+	AppName     string      `json:"app_name"`
+	Description string      `json:"description"`
+	LogoURL     string      `json:"logo_url"` // 来自应用所属组织的品牌配置，系统级应用为空
+	Scopes      []ScopeInfo `json:"scopes"`
+}
+
+// ConsentInfo 返回授权同意页展示的应用信息和各 scope 的说明
+// GET /oauth/consent-info?client_id=&scope=
+func (h *OAuthHandler) ConsentInfo(c *gin.Context) {
+	clientID := c.Query("client_id")
+	if clientID == "" {
+		response.ErrorWithMsg(c, response.CodeMissingParam, "缺少 client_id")
+		return
+	}
+
+	ctx := c.Request.Context()
+	app, err := h.appService.GetByClientID(ctx, clientID)
+	if err != nil {
+		response.Error(c, response.CodeAppNotFound)
+		return
+	}
+
+	requestedScopes := strings.Fields(c.Query("scope"))
+	if !h.isValidScopes(app.AllowedScopes, requestedScopes) {
+		response.Error(c, response.CodeInvalidScope)
+		return
+	}
+
+	info := ConsentInfo{
+		ClientID:    app.ClientID,
+		AppName:     app.Name,
+		Description: app.Description,
+		Scopes:      make([]ScopeInfo, 0, len(requestedScopes)),
+	}
+	if app.OrgID != nil && *app.OrgID != "" && h.orgService != nil {
+		if org, err := h.orgService.GetByID(ctx, *app.OrgID); err == nil {
+			info.LogoURL = org.Branding.LogoURL
+		}
+	}
+
+	var scopes []*model.Scope
+	if h.scopeService != nil {
+		if scopes, err = h.scopeService.Describe(ctx, requestedScopes); err != nil {
+			response.Error(c, response.CodeServerError)
+			return
+		}
+	} else {
+		for _, code := range requestedScopes {
+			scopes = append(scopes, &model.Scope{Code: code, DisplayName: code})
+		}
+	}
+	for _, scope := range scopes {
+		info.Scopes = append(info.Scopes, ScopeInfo{
+			Code:        scope.Code,
+			DisplayName: scope.DisplayName,
+			Description: scope.Description,
+		})
+	}
+
+	response.Success(c, info)
 }
 
 // Token 令牌端点
 // POST /oauth/token
 func (h *OAuthHandler) Token(c *gin.Context) {
+	// 标准要求 application/x-www-form-urlencoded，同时兼容习惯发送 JSON 的前端和 SDK
 	var req TokenRequest
-	if err := c.ShouldBind(&req); err != nil {
+	var err error
+	if c.ContentType() == binding.MIMEJSON {
+		err = c.ShouldBindJSON(&req)
+	} else {
+		err = c.ShouldBindWith(&req, binding.Form)
+	}
+	if err != nil {
 		h.tokenError(c, "invalid_request", "参数错误")
 		return
 	}
 
-	switch req.GrantType {
-	case "authorization_code":
-		h.handleAuthorizationCode(c, &req)
-	case "refresh_token":
-		h.handleRefreshToken(c, &req)
-	case "password":
-		// OAuth 2.1 不支持密码模式
+	// 客户端凭证可通过 Basic 认证或请求体提交，各授权类型统一使用解析结果
+	clientID, clientSecret, err := h.clientCredentials(c, req.ClientID, req.ClientSecret)
+	if err != nil {
+		h.tokenError(c, "invalid_request", err.Error())
+		return
+	}
+	req.ClientID, req.ClientSecret = clientID, clientSecret
+
+	// OAuth 2.1 不支持密码模式
+	if req.GrantType == "password" {
 		h.tokenError(c, "unsupported_grant_type", "不支持密码模式")
-	case "client_credentials":
-		h.handleClientCredentials(c, &req)
-	default:
+		return
+	}
+
+	handle, ok := grantHandlers[req.GrantType]
+	if !ok {
 		h.tokenError(c, "unsupported_grant_type", "不支持的授权类型")
+		return
+	}
+	handle(h, c, &req)
+
+	// 只统计已注册的授权类型，避免任意 grant_type 造成指标标签膨胀
+	if c.Writer.Status() == http.StatusOK {
+		metrics.TokenIssued(req.GrantType)
+	} else {
+		metrics.TokenRequestFailed(req.GrantType)
+	}
+}
+
+// grantHandlers Token 端点支持的授权类型
+// Discovery 文档的 grant_types_supported 由此生成，新增授权类型只需在此注册
+var grantHandlers = map[string]func(*OAuthHandler, *gin.Context, *TokenRequest){
+	"authorization_code": (*OAuthHandler).handleAuthorizationCode,
+	"refresh_token":      (*OAuthHandler).handleRefreshToken,
+	"client_credentials": (*OAuthHandler).handleClientCredentials,
+}
+
+// SupportedGrantTypes 返回 Token 端点支持的授权类型
+func SupportedGrantTypes() []string {
+	grantTypes := make([]string, 0, len(grantHandlers))
+	for grantType := range grantHandlers {
+		grantTypes = append(grantTypes, grantType)
 	}
+	sort.Strings(grantTypes)
+	return grantTypes
 }
 
 // handleAuthorizationCode 处理授权码模式
@@ -195,29 +508,45 @@ func (h *OAuthHandler) handleAuthorizationCode(c *gin.Context, req *TokenRequest
 		return
 	}
 
+	// 授权码必须由申请它的客户端兑换
+	if req.ClientID == "" {
+		h.tokenError(c, "invalid_request", "缺少 client_id")
+		return
+	}
+	if req.ClientID != authCode.ClientID {
+		h.tokenError(c, "invalid_grant", "客户端 ID 不匹配")
+		return
+	}
+
 	// 验证客户端
 	app, err := h.appService.GetByClientID(c.Request.Context(), authCode.ClientID)
 	if err != nil {
 		h.tokenError(c, "invalid_client", "客户端不存在")
 		return
 	}
-
-	// 验证客户端凭证
-	if req.ClientID != "" && req.ClientID != authCode.ClientID {
-		h.tokenError(c, "invalid_client", "客户端 ID 不匹配")
+	if !h.allowOAuthVersion(app) {
+		h.tokenError(c, "unauthorized_client", "OAuth 2.0 模式已停用")
+		return
+	}
+	if !app.AllowsGrantType("authorization_code") {
+		h.tokenError(c, "unauthorized_client", "应用不允许使用该授权类型")
 		return
 	}
 
-	// 验证 Client Secret（如果提供）
-	if req.ClientSecret != "" {
+	// 机密客户端必须提供有效的密钥，只有公开客户端可省略并依赖 PKCE
+	if app.IsConfidential() {
+		if req.ClientSecret == "" {
+			h.tokenError(c, "invalid_client", "缺少客户端凭证")
+			return
+		}
 		if !app.VerifyClientSecret(req.ClientSecret) {
 			h.tokenError(c, "invalid_client", "客户端密钥错误")
 			return
 		}
 	}
 
-	// 验证重定向 URI
-	if req.RedirectURI != "" && req.RedirectURI != authCode.RedirectURI {
+	// 验证重定向 URI：授权时使用了 redirect_uri，令牌请求必须携带且完全一致
+	if authCode.RedirectURI != "" && req.RedirectURI != authCode.RedirectURI {
 		h.tokenError(c, "invalid_grant", "重定向 URI 不匹配")
 		return
 	}
@@ -234,38 +563,58 @@ func (h *OAuthHandler) handleAuthorizationCode(c *gin.Context, req *TokenRequest
 		}
 	}
 
-	// 生成令牌
+	// 生成令牌，每次授权码兑换开启新的令牌族
 	claims := &service.TokenClaims{
 		UserID:   authCode.UserID,
 		ClientID: authCode.ClientID,
 		Scopes:   authCode.Scopes,
+		FamilyID: service.NewTokenFamilyID(),
 	}
 
-	accessToken, err := h.tokenService.GenerateAccessToken(c.Request.Context(), claims)
+	// 应用配置了自定义有效期时覆盖全局默认值
+	accessTTL := appAccessTokenTTL(h.tokenService, app)
+	accessToken, err := h.tokenService.GenerateAccessTokenWithTTL(c.Request.Context(), claims, accessTTL)
 	if err != nil {
 		h.tokenError(c, "server_error", "生成访问令牌失败")
 		return
 	}
 
-	refreshToken, err := h.tokenService.GenerateRefreshToken(c.Request.Context(), claims)
-	if err != nil {
-		h.tokenError(c, "server_error", "生成刷新令牌失败")
-		return
-	}
-
 	// 构建响应
 	scope := strings.Join(authCode.Scopes, " ")
 	resp := gin.H{
-		"access_token":  accessToken,
-		"token_type":    "Bearer",
-		"expires_in":    900, // 15 分钟
-		"refresh_token": refreshToken,
-		"scope":         scope,
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   int(accessTTL.Seconds()),
+		"scope":        scope,
+	}
+
+	// 应用不允许 refresh_token 授权类型时不签发刷新令牌
+	if app.AllowsGrantType("refresh_token") {
+		refreshToken, err := h.tokenService.GenerateRefreshTokenWithTTL(c.Request.Context(), claims, app.RefreshTokenLifetime())
+		if err != nil {
+			h.tokenError(c, "server_error", "生成刷新令牌失败")
+			return
+		}
+		resp["refresh_token"] = refreshToken
 	}
 
 	// 如果请求了 openid scope，生成 ID Token
 	if containsScope(authCode.Scopes, "openid") {
-		idToken, err := h.tokenService.GenerateIDToken(c.Request.Context(), claims)
+		// ID Token 携带按 scope 授予的用户资料
+		idClaims := &service.TokenClaims{
+			UserID:    authCode.UserID,
+			Username:  authCode.Username,
+			Email:     authCode.Email,
+			AppID:     authCode.ClientID,
+			ClientID:  authCode.ClientID,
+			Scopes:    authCode.Scopes,
+			Nonce:     authCode.Nonce,
+			SessionID: authCode.SessionID,
+		}
+		if !authCode.AuthTime.IsZero() {
+			idClaims.AuthTime = authCode.AuthTime.Unix()
+		}
+		idToken, err := h.tokenService.GenerateIDToken(c.Request.Context(), idClaims)
 		if err == nil {
 			resp["id_token"] = idToken
 		}
@@ -283,6 +632,11 @@ func (h *OAuthHandler) handleRefreshToken(c *gin.Context, req *TokenRequest) {
 
 	// 验证刷新令牌
 	claims, err := h.tokenService.ValidateToken(c.Request.Context(), req.RefreshToken)
+	if errors.Is(err, service.ErrRefreshTokenUsed) {
+		revokeReusedRefreshToken(c, h.tokenService, claims)
+		h.tokenError(c, "invalid_grant", "刷新令牌已被使用")
+		return
+	}
 	if err != nil {
 		h.tokenError(c, "invalid_grant", "刷新令牌无效或已过期")
 		return
@@ -293,30 +647,87 @@ func (h *OAuthHandler) handleRefreshToken(c *gin.Context, req *TokenRequest) {
 		return
 	}
 
-	// 撤销旧的刷新令牌（轮换）
-	h.tokenService.RevokeToken(c.Request.Context(), req.RefreshToken)
+	// 刷新令牌只能由签发时的客户端使用
+	if req.ClientID == "" {
+		h.tokenError(c, "invalid_client", "缺少客户端凭证")
+		return
+	}
+	if req.ClientID != claims.ClientID {
+		h.tokenError(c, "invalid_client", "客户端 ID 不匹配")
+		return
+	}
+	app, err := h.appService.GetByClientID(c.Request.Context(), claims.ClientID)
+	if errors.Is(err, repository.ErrAppNotFound) {
+		h.tokenError(c, "invalid_grant", "客户端不存在")
+		return
+	}
+	if err != nil {
+		h.tokenError(c, "server_error", "查询客户端失败")
+		return
+	}
+	if !app.IsActive() {
+		h.tokenError(c, "invalid_grant", "应用已禁用")
+		return
+	}
+	// 机密客户端必须提供有效的密钥，公开客户端仅凭 client_id
+	if app.IsConfidential() && (req.ClientSecret == "" || !app.VerifyClientSecret(req.ClientSecret)) {
+		h.tokenError(c, "invalid_client", "客户端认证失败")
+		return
+	}
+	if !app.AllowsGrantType("refresh_token") {
+		h.tokenError(c, "unauthorized_client", "应用不允许使用该授权类型")
+		return
+	}
+
+	// 按应用配置的有效期签发
+	accessTTL := appAccessTokenTTL(h.tokenService, app)
+	refreshTTL := app.RefreshTokenLifetime()
 
-	// 生成新令牌
+	// 轮换旧的刷新令牌，并发请求中只有一个能成功
+	if err := h.tokenService.RotateRefreshToken(c.Request.Context(), claims); err != nil {
+		if errors.Is(err, service.ErrRefreshTokenUsed) {
+			revokeReusedRefreshToken(c, h.tokenService, claims)
+			h.tokenError(c, "invalid_grant", "刷新令牌已被使用")
+			return
+		}
+		h.tokenError(c, "server_error", "刷新令牌轮换失败")
+		return
+	}
+
+	// 生成新令牌，沿用原令牌族
 	newClaims := &service.TokenClaims{
 		UserID:   claims.UserID,
 		ClientID: claims.ClientID,
 		Username: claims.Username,
 		Email:    claims.Email,
 		Scopes:   claims.Scopes,
+		FamilyID: claims.FamilyID,
 	}
 
-	accessToken, _ := h.tokenService.GenerateAccessToken(c.Request.Context(), newClaims)
-	refreshToken, _ := h.tokenService.GenerateRefreshToken(c.Request.Context(), newClaims)
+	accessToken, _ := h.tokenService.GenerateAccessTokenWithTTL(c.Request.Context(), newClaims, accessTTL)
+	refreshToken, _ := h.tokenService.GenerateRefreshTokenWithTTL(c.Request.Context(), newClaims, refreshTTL)
 
 	c.JSON(http.StatusOK, gin.H{
 		"access_token":  accessToken,
 		"token_type":    "Bearer",
-		"expires_in":    900,
+		"expires_in":    int(accessTTL.Seconds()),
 		"refresh_token": refreshToken,
 		"scope":         strings.Join(claims.Scopes, " "),
 	})
 }
 
+// revokeReusedRefreshToken 已轮换的刷新令牌被再次提交时撤销整个令牌族并记录安全事件
+// 合法客户端与攻击者此时都持有同一族的令牌，无法区分，因此全部失效并要求重新登录
+func revokeReusedRefreshToken(c *gin.Context, tokenService service.TokenService, claims *service.TokenClaims) {
+	_ = tokenService.RevokeTokenFamily(c.Request.Context(), claims.FamilyID)
+	middleware.GetLogger().Warn("检测到刷新令牌重复使用，已撤销令牌族",
+		zap.String("family_id", claims.FamilyID),
+		zap.String("user_id", claims.UserID),
+		zap.String("client_id", claims.ClientID),
+		zap.String("ip", c.ClientIP()),
+	)
+}
+
 // handleClientCredentials 处理客户端凭证模式
 func (h *OAuthHandler) handleClientCredentials(c *gin.Context, req *TokenRequest) {
 	if req.ClientID == "" || req.ClientSecret == "" {
@@ -335,14 +746,41 @@ func (h *OAuthHandler) handleClientCredentials(c *gin.Context, req *TokenRequest
 		h.tokenError(c, "invalid_client", "客户端密钥错误")
 		return
 	}
+	if !h.allowOAuthVersion(app) {
+		h.tokenError(c, "unauthorized_client", "OAuth 2.0 模式已停用")
+		return
+	}
+	if !app.AllowsGrantType("client_credentials") {
+		h.tokenError(c, "unauthorized_client", "应用不允许使用该授权类型")
+		return
+	}
+
+	// 校验请求的权限范围，未指定时授予应用允许的全部范围
+	scopes := strings.Fields(req.Scope)
+	if len(scopes) == 0 {
+		scopes = append([]string{}, app.AllowedScopes...)
+	} else if !h.isValidScopes(app.AllowedScopes, scopes) {
+		h.tokenError(c, "invalid_scope", "请求的权限范围无效")
+		return
+	}
 
 	// 生成访问令牌（无用户上下文）
 	claims := &service.TokenClaims{
 		ClientID: req.ClientID,
-		Scopes:   strings.Split(req.Scope, " "),
+		Scopes:   scopes,
+	}
+	if app.ServiceAccount && h.rbacService != nil {
+		roles, permissions, err := h.serviceAccountGrants(c, app.ID)
+		if err != nil {
+			h.tokenError(c, "server_error", "获取应用角色失败")
+			return
+		}
+		claims.Roles = roles
+		claims.Permissions = permissions
 	}
 
-	accessToken, err := h.tokenService.GenerateAccessToken(c.Request.Context(), claims)
+	accessTTL := appAccessTokenTTL(h.tokenService, app)
+	accessToken, err := h.tokenService.GenerateAccessTokenWithTTL(c.Request.Context(), claims, accessTTL)
 	if err != nil {
 		h.tokenError(c, "server_error", "生成访问令牌失败")
 		return
@@ -351,14 +789,44 @@ func (h *OAuthHandler) handleClientCredentials(c *gin.Context, req *TokenRequest
 	c.JSON(http.StatusOK, gin.H{
 		"access_token": accessToken,
 		"token_type":   "Bearer",
-		"expires_in":   900,
-		"scope":        req.Scope,
+		"expires_in":   int(accessTTL.Seconds()),
+		"scope":        strings.Join(scopes, " "),
 	})
 }
 
+// serviceAccountGrants 返回服务账号应用启用中的角色代码及其权限代码的并集
+func (h *OAuthHandler) serviceAccountGrants(c *gin.Context, appID string) ([]string, []string, error) {
+	roles, err := h.rbacService.GetAppRoles(c.Request.Context(), appID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	roleCodes := make([]string, 0, len(roles))
+	permissions := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, role := range roles {
+		if !role.IsActive() {
+			continue
+		}
+		roleCodes = append(roleCodes, role.Code)
+		for _, perm := range role.Permissions {
+			if !seen[perm.Code] {
+				seen[perm.Code] = true
+				permissions = append(permissions, perm.Code)
+			}
+		}
+	}
+	sort.Strings(permissions)
+	return roleCodes, permissions, nil
+}
+
 // Revoke 令牌撤销端点
 // POST /oauth/revoke
 func (h *OAuthHandler) Revoke(c *gin.Context) {
+	if _, ok := h.authenticateClient(c); !ok {
+		return
+	}
+
 	token := c.PostForm("token")
 	if token == "" {
 		response.ErrorWithMsg(c, response.CodeInvalidRequest, "缺少令牌")
@@ -375,6 +843,11 @@ func (h *OAuthHandler) Revoke(c *gin.Context) {
 // Introspect 令牌内省端点
 // POST /oauth/introspect
 func (h *OAuthHandler) Introspect(c *gin.Context) {
+	// RFC 7662: 内省端点要求客户端认证，防止泄露令牌信息
+	if _, ok := h.authenticateClient(c); !ok {
+		return
+	}
+
 	token := c.PostForm("token")
 	if token == "" {
 		c.JSON(http.StatusOK, gin.H{"active": false})
@@ -403,6 +876,30 @@ func (h *OAuthHandler) Introspect(c *gin.Context) {
 
 // 辅助方法
 
+// redirectToLogin 重定向到登录页面，登录后返回当前授权请求
+// 返回地址去掉 prompt=login，避免重新登录后再次被要求登录
+func (h *OAuthHandler) redirectToLogin(c *gin.Context) {
+	returnURL := *c.Request.URL
+	query := returnURL.Query()
+	if prompts := strings.Fields(query.Get("prompt")); containsScope(prompts, "login") {
+		remaining := make([]string, 0, len(prompts))
+		for _, p := range prompts {
+			if p != "login" {
+				remaining = append(remaining, p)
+			}
+		}
+		if len(remaining) > 0 {
+			query.Set("prompt", strings.Join(remaining, " "))
+		} else {
+			query.Del("prompt")
+		}
+		returnURL.RawQuery = query.Encode()
+	}
+
+	loginURL := "/login?redirect=" + url.QueryEscape(returnURL.String())
+	c.Redirect(http.StatusFound, loginURL)
+}
+
 // redirectError 重定向错误响应
 func (h *OAuthHandler) redirectError(c *gin.Context, redirectURI, errorCode, errorDesc, state string) {
 	if redirectURI == "" {
@@ -427,11 +924,58 @@ func (h *OAuthHandler) redirectError(c *gin.Context, redirectURI, errorCode, err
 	c.Redirect(http.StatusFound, redirectURL.String())
 }
 
-// tokenError 令牌端点错误响应
+// errClientCredentialsConflict Basic 认证与请求体中的客户端凭证不一致
+var errClientCredentialsConflict = errors.New("Authorization 头与请求体中的客户端凭证不一致")
+
+// clientCredentials 获取客户端凭证（client_secret_basic / client_secret_post）
+// 优先使用 HTTP Basic 认证，请求体中的 formID/formSecret 作为回退；两处都提供且不一致时返回错误
+func (h *OAuthHandler) clientCredentials(c *gin.Context, formID, formSecret string) (string, string, error) {
+	id, secret, ok := c.Request.BasicAuth()
+	if !ok {
+		return formID, formSecret, nil
+	}
+
+	// RFC 6749 2.3.1: Basic 认证中的凭证需先进行 URL 编码
+	if decoded, err := url.QueryUnescape(id); err == nil {
+		id = decoded
+	}
+	if decoded, err := url.QueryUnescape(secret); err == nil {
+		secret = decoded
+	}
+
+	if (formID != "" && formID != id) || (formSecret != "" && formSecret != secret) {
+		return "", "", errClientCredentialsConflict
+	}
+	return id, secret, nil
+}
+
+// authenticateClient 校验客户端凭证，失败时写入 401 响应
+func (h *OAuthHandler) authenticateClient(c *gin.Context) (*model.Application, bool) {
+	clientID, clientSecret, err := h.clientCredentials(c, c.PostForm("client_id"), c.PostForm("client_secret"))
+	if err != nil {
+		h.tokenError(c, "invalid_request", err.Error())
+		return nil, false
+	}
+	if clientID == "" || clientSecret == "" {
+		h.tokenError(c, "invalid_client", "缺少客户端凭证")
+		return nil, false
+	}
+
+	app, err := h.appService.ValidateClientCredentials(c.Request.Context(), clientID, clientSecret)
+	if err != nil {
+		h.tokenError(c, "invalid_client", "客户端认证失败")
+		return nil, false
+	}
+	return app, true
+}
+
+// tokenError 令牌端点错误响应，响应体为 RFC 6749 5.2 的 {error, error_description}
+// invalid_client 返回 401，并按 RFC 6749 通过 WWW-Authenticate 告知客户端使用 Basic 认证
 func (h *OAuthHandler) tokenError(c *gin.Context, errorCode, errorDesc string) {
 	status := http.StatusBadRequest
 	if errorCode == "invalid_client" {
 		status = http.StatusUnauthorized
+		c.Header("WWW-Authenticate", `Basic realm="oauth"`)
 	}
 
 	c.JSON(status, gin.H{
@@ -441,15 +985,53 @@ func (h *OAuthHandler) tokenError(c *gin.Context, errorCode, errorDesc string) {
 }
 
 // isValidRedirectURI 验证重定向 URI
-func (h *OAuthHandler) isValidRedirectURI(allowedURIs []string, uri string) bool {
+// 默认要求与注册地址完全一致；allowSubpath 开启时允许同 scheme、host、port 下的子路径
+func (h *OAuthHandler) isValidRedirectURI(allowedURIs []string, uri string, allowSubpath bool) bool {
 	for _, allowed := range allowedURIs {
 		if allowed == uri {
 			return true
 		}
+		if allowSubpath && isSubpathRedirect(allowed, uri) {
+			return true
+		}
 	}
 	return false
 }
 
+// isSubpathRedirect 检查 uri 是否为注册地址 allowed 下的子路径
+// 拒绝 userinfo、片段、路径穿越及编码后的分隔符，防止借子路径匹配实现开放重定向
+func isSubpathRedirect(allowed, uri string) bool {
+	base, err := url.Parse(allowed)
+	if err != nil || base.Scheme == "" || base.Host == "" {
+		return false
+	}
+	target, err := url.Parse(uri)
+	if err != nil || target.Opaque != "" || target.User != nil || target.Fragment != "" {
+		return false
+	}
+	if !strings.EqualFold(target.Scheme, base.Scheme) || !strings.EqualFold(target.Host, base.Host) {
+		return false
+	}
+
+	// 注册地址带查询参数时要求完全一致
+	if base.RawQuery != "" && target.RawQuery != base.RawQuery {
+		return false
+	}
+
+	rawPath := strings.ToLower(target.EscapedPath())
+	if strings.Contains(rawPath, "%2f") || strings.Contains(rawPath, "%5c") || strings.Contains(target.Path, "\\") {
+		return false
+	}
+	for _, segment := range strings.Split(target.Path, "/") {
+		if segment == "." || segment == ".." {
+			return false
+		}
+	}
+
+	prefix := strings.TrimSuffix(base.Path, "/")
+	return target.Path == base.Path || strings.HasPrefix(target.Path, prefix+"/")
+}
+
 // isValidScopes 验证权限范围
 func (h *OAuthHandler) isValidScopes(allowedScopes, requestedScopes []string) bool {
 	allowedSet := make(map[string]bool)
@@ -468,6 +1050,41 @@ func (h *OAuthHandler) isValidScopes(allowedScopes, requestedScopes []string) bo
 	return true
 }
 
+// allowOAuthVersion 检查应用的 OAuth 版本是否可用，OAuth 2.0 模式受功能开关控制
+func (h *OAuthHandler) allowOAuthVersion(app *model.Application) bool {
+	if app.OAuthVersion != model.OAuthVersion20 {
+		return true
+	}
+	return h.features.Use(feature.AllowOAuth20)
+}
+
+// isInsecureRedirectURI 检查是否为非本机的 http 回调地址
+// 本机回环地址供原生应用使用，始终允许 http
+func isInsecureRedirectURI(uri string) bool {
+	u, err := url.Parse(uri)
+	if err != nil || !strings.EqualFold(u.Scheme, "http") {
+		return false
+	}
+	switch u.Hostname() {
+	case "localhost", "127.0.0.1", "::1":
+		return false
+	}
+	return true
+}
+
+// expiresIn 访问令牌有效期（秒），与令牌中 exp - iat 一致
+func expiresIn(tokenService service.TokenService) int {
+	return int(tokenService.AccessTokenTTL().Seconds())
+}
+
+// appAccessTokenTTL 应用的访问令牌有效期，未配置时使用全局默认值
+func appAccessTokenTTL(tokenService service.TokenService, app *model.Application) time.Duration {
+	if ttl := app.AccessTokenLifetime(); ttl > 0 {
+		return ttl
+	}
+	return tokenService.AccessTokenTTL()
+}
+
 // verifyPKCE 验证 PKCE
 func (h *OAuthHandler) verifyPKCE(challenge, method, verifier string) bool {
 	if method == "plain" || method == "" {
@@ -139,14 +139,14 @@ func TestProperty_RedirectURIValidation(t *testing.T) {
 
 	properties.Property("有效 redirect URI 应通过验证", prop.ForAll(
 		func(uri string) bool {
-			return h.isValidRedirectURI(allowedURIs, uri)
+			return h.isValidRedirectURI(allowedURIs, uri, false)
 		},
 		validURIGen,
 	))
 
 	properties.Property("无效 redirect URI 应失败", prop.ForAll(
 		func(uri string) bool {
-			return !h.isValidRedirectURI(allowedURIs, uri)
+			return !h.isValidRedirectURI(allowedURIs, uri, false)
 		},
 		invalidURIGen,
 	))
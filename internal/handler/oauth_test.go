@@ -1,9 +1,12 @@
 package handler
 
 import (
+	"bytes"
+	"context"
 	"crypto/rand"
 	"crypto/rsa"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -12,7 +15,12 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pu-ac-cn/uac-backend/internal/config"
+	"github.com/pu-ac-cn/uac-backend/internal/feature"
+	"github.com/pu-ac-cn/uac-backend/internal/middleware"
 	"github.com/pu-ac-cn/uac-backend/internal/model"
+	"github.com/pu-ac-cn/uac-backend/internal/repository"
 	"github.com/pu-ac-cn/uac-backend/internal/service"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -36,6 +44,29 @@ func (m *mockAppService) GetByClientID(clientID string) (*model.Application, err
 	return nil, service.ErrAppIDEmpty
 }
 
+func (s *stubAppService) ValidateClientCredentials(ctx context.Context, clientID, clientSecret string) (*model.Application, error) {
+	app, err := s.GetByClientID(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+	if !app.VerifyClientSecret(clientSecret) {
+		return nil, errors.New("Client Secret 验证失败")
+	}
+	return app, nil
+}
+
+// setupClientAuthTest 创建需要客户端认证的端点测试环境，预置机密客户端 client-c
+func setupClientAuthTest(t *testing.T) (*gin.Engine, service.TokenService) {
+	router, oauthHandler, tokenService := setupOAuthTestRouter(t)
+	app := &model.Application{ClientID: "client-c", OAuthVersion: model.OAuthVersion20, Status: model.StatusActive, AllowedScopes: model.StringSlice{"read"}}
+	require.NoError(t, app.SetClientSecret("secret-c"))
+	oauthHandler.appService = &stubAppService{apps: map[string]*model.Application{"client-c": app}}
+	router.POST("/oauth/revoke", oauthHandler.Revoke)
+	router.POST("/oauth/introspect", oauthHandler.Introspect)
+	router.POST("/oauth/token", oauthHandler.Token)
+	return router, tokenService
+}
+
 func (m *mockAppService) AddApp(app *model.Application) {
 	m.apps[app.ClientID] = app
 }
@@ -68,13 +99,12 @@ func setupOAuthTestRouter(t *testing.T) (*gin.Engine, *OAuthHandler, service.Tok
 }
 
 func TestOAuthHandler_Token_RefreshToken(t *testing.T) {
-	router, oauthHandler, tokenService := setupOAuthTestRouter(t)
-
-	router.POST("/oauth/token", oauthHandler.Token)
+	router, tokenService := setupClientAuthTest(t)
 
 	// 先生成一个刷新令牌
 	claims := &service.TokenClaims{
 		UserID:   "user-123",
+		ClientID: "client-c",
 		Username: "testuser",
 		Email:    "test@example.com",
 		Scopes:   []string{"openid", "profile"},
@@ -86,6 +116,8 @@ func TestOAuthHandler_Token_RefreshToken(t *testing.T) {
 	form := url.Values{}
 	form.Set("grant_type", "refresh_token")
 	form.Set("refresh_token", refreshToken)
+	form.Set("client_id", "client-c")
+	form.Set("client_secret", "secret-c")
 
 	req := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
@@ -104,6 +136,47 @@ func TestOAuthHandler_Token_RefreshToken(t *testing.T) {
 	assert.Equal(t, "Bearer", resp["token_type"])
 }
 
+func TestOAuthHandler_Token_RefreshTokenJSONBody(t *testing.T) {
+	router, tokenService := setupClientAuthTest(t)
+
+	claims := &service.TokenClaims{
+		UserID:   "user-123",
+		ClientID: "client-c",
+		Username: "testuser",
+		Scopes:   []string{"openid", "profile"},
+	}
+	refreshToken, err := tokenService.GenerateRefreshToken(nil, claims)
+	require.NoError(t, err)
+
+	body, err := json.Marshal(map[string]string{
+		"grant_type":    "refresh_token",
+		"refresh_token": refreshToken,
+		"client_id":     "client-c",
+		"client_secret": "secret-c",
+	})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/oauth/token", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.NotEmpty(t, resp["access_token"])
+	assert.NotEmpty(t, resp["refresh_token"])
+
+	// JSON 缺少 grant_type 时返回 invalid_request
+	req = httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(`{"refresh_token":"x"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "invalid_request", resp["error"])
+}
+
 func TestOAuthHandler_Token_UnsupportedGrantType(t *testing.T) {
 	router, oauthHandler, _ := setupOAuthTestRouter(t)
 
@@ -153,10 +226,87 @@ func TestOAuthHandler_Token_InvalidRefreshToken(t *testing.T) {
 	assert.Equal(t, "invalid_grant", resp["error"])
 }
 
-func TestOAuthHandler_Revoke(t *testing.T) {
+func TestOAuthHandler_Token_RefreshTokenReuse(t *testing.T) {
+	router, tokenService := setupClientAuthTest(t)
+
+	refresh := func(token string) *httptest.ResponseRecorder {
+		form := url.Values{}
+		form.Set("grant_type", "refresh_token")
+		form.Set("refresh_token", token)
+		form.Set("client_id", "client-c")
+		form.Set("client_secret", "secret-c")
+		return postTokenForm(router, form)
+	}
+
+	original, err := tokenService.GenerateRefreshToken(nil, &service.TokenClaims{UserID: "user-123", ClientID: "client-c", Scopes: []string{"openid"}})
+	require.NoError(t, err)
+
+	// 合法客户端正常轮换
+	w := refresh(original)
+	require.Equal(t, http.StatusOK, w.Code)
+	var rotated map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &rotated))
+
+	// 被盗的旧刷新令牌再次提交
+	w = refresh(original)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "invalid_grant")
+
+	// 同一令牌族下轮换出的令牌全部失效
+	_, err = tokenService.ValidateToken(nil, rotated["access_token"].(string))
+	assert.ErrorIs(t, err, service.ErrInvalidToken)
+	w = refresh(rotated["refresh_token"].(string))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "invalid_grant")
+}
+
+func TestOAuthHandler_Token_RefreshTokenClientAuth(t *testing.T) {
 	router, oauthHandler, tokenService := setupOAuthTestRouter(t)
+	confidential := &model.Application{ClientID: "client-c", Status: model.StatusActive}
+	require.NoError(t, confidential.SetClientSecret("secret-c"))
+	oauthHandler.appService = &stubAppService{apps: map[string]*model.Application{
+		"client-c": confidential,
+		"client-p": {ClientID: "client-p", Status: model.StatusActive},
+		"client-d": {ClientID: "client-d", Status: model.StatusDisabled},
+	}}
+	router.POST("/oauth/token", oauthHandler.Token)
 
-	router.POST("/oauth/revoke", oauthHandler.Revoke)
+	tests := []struct {
+		name        string
+		tokenClient string
+		form        url.Values
+		wantStatus  int
+		wantError   string
+	}{
+		{"机密客户端缺少密钥", "client-c", url.Values{"client_id": {"client-c"}}, http.StatusUnauthorized, "invalid_client"},
+		{"机密客户端密钥错误", "client-c", url.Values{"client_id": {"client-c"}, "client_secret": {"wrong"}}, http.StatusUnauthorized, "invalid_client"},
+		{"缺少客户端", "client-p", url.Values{}, http.StatusUnauthorized, "invalid_client"},
+		{"其他客户端使用", "client-c", url.Values{"client_id": {"client-p"}}, http.StatusUnauthorized, "invalid_client"},
+		{"应用不存在", "client-x", url.Values{"client_id": {"client-x"}}, http.StatusBadRequest, "invalid_grant"},
+		{"应用已禁用", "client-d", url.Values{"client_id": {"client-d"}}, http.StatusBadRequest, "invalid_grant"},
+		{"机密客户端认证通过", "client-c", url.Values{"client_id": {"client-c"}, "client_secret": {"secret-c"}}, http.StatusOK, ""},
+		{"公开客户端", "client-p", url.Values{"client_id": {"client-p"}}, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			refreshToken, err := tokenService.GenerateRefreshToken(nil, &service.TokenClaims{UserID: "user-123", ClientID: tt.tokenClient})
+			require.NoError(t, err)
+			tt.form.Set("grant_type", "refresh_token")
+			tt.form.Set("refresh_token", refreshToken)
+
+			w := postTokenForm(router, tt.form)
+			assert.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+			if tt.wantError != "" {
+				var resp map[string]interface{}
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+				assert.Equal(t, tt.wantError, resp["error"])
+			}
+		})
+	}
+}
+
+func TestOAuthHandler_Revoke(t *testing.T) {
+	router, tokenService := setupClientAuthTest(t)
 
 	// 生成一个令牌
 	claims := &service.TokenClaims{
@@ -167,6 +317,8 @@ func TestOAuthHandler_Revoke(t *testing.T) {
 	// 撤销令牌
 	form := url.Values{}
 	form.Set("token", accessToken)
+	form.Set("client_id", "client-c")
+	form.Set("client_secret", "secret-c")
 
 	req := httptest.NewRequest(http.MethodPost, "/oauth/revoke", strings.NewReader(form.Encode()))
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
@@ -179,9 +331,7 @@ func TestOAuthHandler_Revoke(t *testing.T) {
 }
 
 func TestOAuthHandler_Introspect(t *testing.T) {
-	router, oauthHandler, tokenService := setupOAuthTestRouter(t)
-
-	router.POST("/oauth/introspect", oauthHandler.Introspect)
+	router, tokenService := setupClientAuthTest(t)
 
 	// 生成一个有效令牌
 	claims := &service.TokenClaims{
@@ -194,6 +344,8 @@ func TestOAuthHandler_Introspect(t *testing.T) {
 	// 内省令牌
 	form := url.Values{}
 	form.Set("token", accessToken)
+	form.Set("client_id", "client-c")
+	form.Set("client_secret", "secret-c")
 
 	req := httptest.NewRequest(http.MethodPost, "/oauth/introspect", strings.NewReader(form.Encode()))
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
@@ -212,12 +364,12 @@ func TestOAuthHandler_Introspect(t *testing.T) {
 }
 
 func TestOAuthHandler_Introspect_InvalidToken(t *testing.T) {
-	router, oauthHandler, _ := setupOAuthTestRouter(t)
-
-	router.POST("/oauth/introspect", oauthHandler.Introspect)
+	router, _ := setupClientAuthTest(t)
 
 	form := url.Values{}
 	form.Set("token", "invalid-token")
+	form.Set("client_id", "client-c")
+	form.Set("client_secret", "secret-c")
 
 	req := httptest.NewRequest(http.MethodPost, "/oauth/introspect", strings.NewReader(form.Encode()))
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
@@ -255,3 +407,1203 @@ func TestVerifyPKCE_S256(t *testing.T) {
 	assert.True(t, h.verifyPKCE(challenge, "S256", verifier))
 	assert.False(t, h.verifyPKCE(challenge, "S256", "wrong-verifier"))
 }
+
+// stubAppService 应用服务桩，仅实现按 Client ID 查询
+type stubAppService struct {
+	service.ApplicationService
+	apps map[string]*model.Application
+}
+
+func (s *stubAppService) GetByClientID(ctx context.Context, clientID string) (*model.Application, error) {
+	if app, ok := s.apps[clientID]; ok {
+		return app, nil
+	}
+	return nil, repository.ErrAppNotFound
+}
+
+// setupAuthCodeTest 创建授权码模式测试环境，预置一个公开客户端
+func setupAuthCodeTest(t *testing.T) (*gin.Engine, service.TokenService) {
+	router, oauthHandler, tokenService := setupOAuthTestRouter(t)
+	oauthHandler.appService = &stubAppService{apps: map[string]*model.Application{
+		"client-a": {ClientID: "client-a", OAuthVersion: model.OAuthVersion20, Status: model.StatusActive, RedirectURIs: model.StringSlice{"https://a.example.com/cb"}},
+		"client-b": {ClientID: "client-b", OAuthVersion: model.OAuthVersion20, Status: model.StatusActive, RedirectURIs: model.StringSlice{"https://b.example.com/cb"}},
+	}}
+	router.POST("/oauth/token", oauthHandler.Token)
+	return router, tokenService
+}
+
+// issueTestCode 为指定客户端签发授权码
+func issueTestCode(t *testing.T, tokenService service.TokenService, clientID, redirectURI string) string {
+	code, err := tokenService.GenerateAuthorizationCode(context.Background(), &service.AuthorizationCode{
+		ClientID:    clientID,
+		UserID:      "user-123",
+		RedirectURI: redirectURI,
+		Scopes:      []string{"profile"},
+	})
+	require.NoError(t, err)
+	return code
+}
+
+// postTokenForm 提交令牌端点表单请求
+func postTokenForm(router *gin.Engine, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestOAuthHandler_AuthorizationCode_RedirectURIOmitted(t *testing.T) {
+	router, tokenService := setupAuthCodeTest(t)
+	code := issueTestCode(t, tokenService, "client-a", "https://a.example.com/cb")
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("client_id", "client-a")
+	w := postTokenForm(router, form)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var resp map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "invalid_grant", resp["error"])
+}
+
+func TestOAuthHandler_AuthorizationCode_RedirectURIMismatch(t *testing.T) {
+	router, tokenService := setupAuthCodeTest(t)
+	code := issueTestCode(t, tokenService, "client-a", "https://a.example.com/cb")
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("client_id", "client-a")
+	form.Set("redirect_uri", "https://evil.example.com/cb")
+	w := postTokenForm(router, form)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestOAuthHandler_AuthorizationCode_RedirectURIMatch(t *testing.T) {
+	router, tokenService := setupAuthCodeTest(t)
+	code := issueTestCode(t, tokenService, "client-a", "https://a.example.com/cb")
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("client_id", "client-a")
+	form.Set("redirect_uri", "https://a.example.com/cb")
+	w := postTokenForm(router, form)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.NotEmpty(t, resp["access_token"])
+}
+
+func TestOAuthHandler_AuthorizationCode_ConfidentialClientSecret(t *testing.T) {
+	router, oauthHandler, tokenService := setupOAuthTestRouter(t)
+	app := &model.Application{ClientID: "client-c", OAuthVersion: model.OAuthVersion20, Status: model.StatusActive, RedirectURIs: model.StringSlice{"https://c.example.com/cb"}}
+	require.NoError(t, app.SetClientSecret("secret-c"))
+	oauthHandler.appService = &stubAppService{apps: map[string]*model.Application{"client-c": app}}
+	router.POST("/oauth/token", oauthHandler.Token)
+
+	exchange := func(secret string) *httptest.ResponseRecorder {
+		form := url.Values{}
+		form.Set("grant_type", "authorization_code")
+		form.Set("code", issueTestCode(t, tokenService, "client-c", "https://c.example.com/cb"))
+		form.Set("client_id", "client-c")
+		form.Set("redirect_uri", "https://c.example.com/cb")
+		if secret != "" {
+			form.Set("client_secret", secret)
+		}
+		return postTokenForm(router, form)
+	}
+
+	// 机密客户端省略或提供错误的密钥均被拒绝
+	for _, secret := range []string{"", "wrong"} {
+		w := exchange(secret)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid_client")
+	}
+
+	w := exchange("secret-c")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+}
+
+func TestOAuthHandler_AuthorizationCode_IDTokenNonce(t *testing.T) {
+	router, oauthHandler, tokenService := setupOAuthTestRouter(t)
+	oauthHandler.appService = &stubAppService{apps: map[string]*model.Application{
+		"client-a": {
+			ClientID:      "client-a",
+			OAuthVersion:  model.OAuthVersion20,
+			Status:        model.StatusActive,
+			RedirectURIs:  model.StringSlice{"https://a.example.com/cb"},
+			AllowedScopes: model.StringSlice{"openid", "profile"},
+		},
+	}}
+
+	// 模拟已登录用户，访问令牌签发时间即认证时间
+	authTime := time.Now().Add(-5 * time.Minute).Truncate(time.Second)
+	loggedIn := func(c *gin.Context) {
+		claims := &service.TokenClaims{UserID: "user-123"}
+		claims.IssuedAt = jwt.NewNumericDate(authTime)
+		c.Set("user_id", "user-123")
+		c.Set("claims", claims)
+	}
+	router.GET("/oauth/authorize", loggedIn, oauthHandler.Authorize)
+	router.POST("/oauth/token", oauthHandler.Token)
+
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", "client-a")
+	query.Set("redirect_uri", "https://a.example.com/cb")
+	query.Set("scope", "openid profile")
+	query.Set("nonce", "n-0S6_WzA2Mj")
+	req := httptest.NewRequest(http.MethodGet, "/oauth/authorize?"+query.Encode(), nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusFound, w.Code)
+	location, err := url.Parse(w.Header().Get("Location"))
+	require.NoError(t, err)
+	code := location.Query().Get("code")
+	require.NotEmpty(t, code)
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("client_id", "client-a")
+	form.Set("redirect_uri", "https://a.example.com/cb")
+	w = postTokenForm(router, form)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	idClaims, err := tokenService.ValidateToken(context.Background(), resp["id_token"].(string))
+	require.NoError(t, err)
+	assert.Equal(t, "n-0S6_WzA2Mj", idClaims.Nonce)
+	assert.Equal(t, authTime.Unix(), idClaims.AuthTime)
+
+	// aud 与 azp 为兑换授权码的 client_id
+	assert.Equal(t, jwt.ClaimStrings{"client-a"}, idClaims.Audience)
+	assert.Equal(t, "client-a", idClaims.AuthorizedParty)
+
+	// 访问令牌不携带 nonce
+	accessClaims, err := tokenService.ValidateToken(context.Background(), resp["access_token"].(string))
+	require.NoError(t, err)
+	assert.Empty(t, accessClaims.Nonce)
+}
+
+// setupPromptTest 创建授权端点测试环境，loggedIn 表示用户是否已登录
+func setupPromptTest(t *testing.T, loggedIn bool) *gin.Engine {
+	router, oauthHandler, _ := setupOAuthTestRouter(t)
+	oauthHandler.appService = &stubAppService{apps: map[string]*model.Application{
+		"client-a": {
+			ClientID:      "client-a",
+			OAuthVersion:  model.OAuthVersion20,
+			Status:        model.StatusActive,
+			RedirectURIs:  model.StringSlice{"https://a.example.com/cb"},
+			AllowedScopes: model.StringSlice{"openid"},
+		},
+	}}
+	session := func(c *gin.Context) {
+		if loggedIn {
+			c.Set("user_id", "user-123")
+		}
+	}
+	router.GET("/oauth/authorize", session, oauthHandler.Authorize)
+	return router
+}
+
+// authorizeWithPrompt 以指定 prompt 请求授权端点，返回重定向地址
+func authorizeWithPrompt(t *testing.T, router *gin.Engine, prompt string) *url.URL {
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", "client-a")
+	query.Set("redirect_uri", "https://a.example.com/cb")
+	query.Set("scope", "openid")
+	query.Set("state", "xyz")
+	if prompt != "" {
+		query.Set("prompt", prompt)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/oauth/authorize?"+query.Encode(), nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusFound, w.Code)
+
+	location, err := url.Parse(w.Header().Get("Location"))
+	require.NoError(t, err)
+	return location
+}
+
+func TestOAuthHandler_Authorize_PromptNone(t *testing.T) {
+	// 未登录时直接返回 login_required，不跳转登录页
+	location := authorizeWithPrompt(t, setupPromptTest(t, false), "none")
+	assert.Equal(t, "a.example.com", location.Host)
+	assert.Equal(t, "login_required", location.Query().Get("error"))
+	assert.Equal(t, "xyz", location.Query().Get("state"))
+
+	// 已登录时静默签发授权码
+	location = authorizeWithPrompt(t, setupPromptTest(t, true), "none")
+	assert.Equal(t, "a.example.com", location.Host)
+	assert.NotEmpty(t, location.Query().Get("code"))
+
+	// none 不能与其他值组合
+	location = authorizeWithPrompt(t, setupPromptTest(t, true), "none login")
+	assert.Equal(t, "invalid_request", location.Query().Get("error"))
+}
+
+func TestOAuthHandler_Authorize_PromptLogin(t *testing.T) {
+	// 已登录仍强制跳转登录页
+	location := authorizeWithPrompt(t, setupPromptTest(t, true), "login")
+	assert.Equal(t, "/login", location.Path)
+	assert.Empty(t, location.Query().Get("code"))
+
+	// 登录后返回的授权请求不再携带 prompt=login，避免循环
+	returnURL, err := url.Parse(location.Query().Get("redirect"))
+	require.NoError(t, err)
+	assert.Equal(t, "/oauth/authorize", returnURL.Path)
+	assert.Empty(t, returnURL.Query().Get("prompt"))
+	assert.Equal(t, "client-a", returnURL.Query().Get("client_id"))
+
+	// 未指定 prompt 时已登录用户直接获得授权码
+	location = authorizeWithPrompt(t, setupPromptTest(t, true), "")
+	assert.NotEmpty(t, location.Query().Get("code"))
+}
+
+func TestOAuthHandler_AuthorizationCode_ClientIDOmitted(t *testing.T) {
+	router, tokenService := setupAuthCodeTest(t)
+	code := issueTestCode(t, tokenService, "client-a", "https://a.example.com/cb")
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", "https://a.example.com/cb")
+	w := postTokenForm(router, form)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var resp map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "invalid_request", resp["error"])
+}
+
+func TestOAuthHandler_AuthorizationCode_ClientIDMismatch(t *testing.T) {
+	router, tokenService := setupAuthCodeTest(t)
+	code := issueTestCode(t, tokenService, "client-a", "https://a.example.com/cb")
+
+	// 另一个公开客户端试图兑换 client-a 的授权码
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("client_id", "client-b")
+	form.Set("redirect_uri", "https://a.example.com/cb")
+	w := postTokenForm(router, form)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var resp map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "invalid_grant", resp["error"])
+}
+
+func TestOAuthHandler_AuthorizationCode_BasicAuthClientID(t *testing.T) {
+	router, tokenService := setupAuthCodeTest(t)
+
+	exchange := func(basicClientID string) *httptest.ResponseRecorder {
+		code := issueTestCode(t, tokenService, "client-a", "https://a.example.com/cb")
+		form := url.Values{}
+		form.Set("grant_type", "authorization_code")
+		form.Set("code", code)
+		form.Set("redirect_uri", "https://a.example.com/cb")
+		req := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth(basicClientID, "")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Basic 认证中的 client_id 与授权码不一致
+	w := exchange("client-b")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var resp map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "invalid_grant", resp["error"])
+
+	// Basic 认证中的 client_id 与授权码一致
+	w = exchange("client-a")
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestOAuthHandler_Introspect_ClientAuth(t *testing.T) {
+	router, tokenService := setupClientAuthTest(t)
+	accessToken, _ := tokenService.GenerateAccessToken(context.Background(), &service.TokenClaims{UserID: "user-123", Username: "testuser"})
+
+	introspect := func(form url.Values, basicID, basicSecret string) *httptest.ResponseRecorder {
+		form.Set("token", accessToken)
+		req := httptest.NewRequest(http.MethodPost, "/oauth/introspect", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if basicID != "" {
+			req.SetBasicAuth(basicID, basicSecret)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("无凭证", func(t *testing.T) {
+		w := introspect(url.Values{}, "", "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.NotContains(t, w.Body.String(), "testuser")
+	})
+
+	t.Run("错误凭证", func(t *testing.T) {
+		form := url.Values{}
+		form.Set("client_id", "client-c")
+		form.Set("client_secret", "wrong")
+		w := introspect(form, "", "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		var resp map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, "invalid_client", resp["error"])
+	})
+
+	t.Run("Basic 认证正确凭证", func(t *testing.T) {
+		w := introspect(url.Values{}, "client-c", "secret-c")
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var resp map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, true, resp["active"])
+		assert.Equal(t, "testuser", resp["username"])
+	})
+}
+
+func TestOAuthHandler_Token_InvalidClientWWWAuthenticate(t *testing.T) {
+	router, _ := setupClientAuthTest(t)
+
+	token := func(form url.Values, basicID, basicSecret string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if basicID != "" {
+			req.SetBasicAuth(basicID, basicSecret)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	for name, basicSecret := range map[string]string{"错误凭证": "wrong", "缺少凭证": ""} {
+		t.Run(name, func(t *testing.T) {
+			form := url.Values{"grant_type": {"client_credentials"}}
+			basicID := "client-c"
+			if basicSecret == "" {
+				basicID = ""
+			}
+			w := token(form, basicID, basicSecret)
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+			assert.Equal(t, `Basic realm="oauth"`, w.Header().Get("WWW-Authenticate"))
+
+			var resp map[string]interface{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			assert.Equal(t, "invalid_client", resp["error"])
+			assert.NotEmpty(t, resp["error_description"])
+			assert.Len(t, resp, 2)
+		})
+	}
+
+	// 非客户端认证错误返回 400，不带 WWW-Authenticate
+	w := token(url.Values{"grant_type": {"unknown"}}, "", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Empty(t, w.Header().Get("WWW-Authenticate"))
+}
+
+func TestOAuthHandler_Revoke_ClientAuth(t *testing.T) {
+	router, tokenService := setupClientAuthTest(t)
+	accessToken, _ := tokenService.GenerateAccessToken(context.Background(), &service.TokenClaims{UserID: "user-123"})
+
+	form := url.Values{}
+	form.Set("token", accessToken)
+	form.Set("client_id", "client-c")
+	form.Set("client_secret", "wrong")
+	req := httptest.NewRequest(http.MethodPost, "/oauth/revoke", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// 认证失败时令牌不应被撤销
+	_, err := tokenService.ValidateToken(context.Background(), accessToken)
+	assert.NoError(t, err)
+}
+
+func TestOAuthHandler_ClientCredentials_BasicAuth(t *testing.T) {
+	router, tokenService := setupClientAuthTest(t)
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("scope", "read")
+	req := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth("client-c", "secret-c")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+
+	claims, err := tokenService.ValidateToken(context.Background(), resp["access_token"].(string))
+	require.NoError(t, err)
+	assert.Equal(t, "client-c", claims.ClientID)
+}
+
+func TestOAuthHandler_ClientCredentials_Scope(t *testing.T) {
+	router, tokenService := setupClientAuthTest(t)
+
+	clientCredentials := func(scope string) *httptest.ResponseRecorder {
+		form := url.Values{}
+		form.Set("grant_type", "client_credentials")
+		if scope != "" {
+			form.Set("scope", scope)
+		}
+		req := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth("client-c", "secret-c")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// 应用只允许 read，请求 read write 被拒绝
+	w := clientCredentials("read write")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var resp map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "invalid_scope", resp["error"])
+
+	// 未指定 scope 时授予应用允许的范围
+	w = clientCredentials("")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "read", resp["scope"])
+	claims, err := tokenService.ValidateToken(context.Background(), resp["access_token"].(string))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"read"}, claims.Scopes)
+}
+
+func TestOAuthHandler_ClientCredentials_BasicAuthConflict(t *testing.T) {
+	router, _ := setupClientAuthTest(t)
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("client_id", "client-other")
+	req := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth("client-c", "secret-c")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var resp map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "invalid_request", resp["error"])
+}
+
+func TestIsValidRedirectURI_Subpath(t *testing.T) {
+	h := &OAuthHandler{}
+	allowed := []string{"https://app.example.com/cb", "http://localhost:3000/"}
+
+	valid := []string{
+		"https://app.example.com/cb",
+		"https://app.example.com/cb/",
+		"https://app.example.com/cb/deep/path",
+		"https://app.example.com/cb/page?from=login",
+		"http://localhost:3000/any/path",
+	}
+	for _, uri := range valid {
+		assert.True(t, h.isValidRedirectURI(allowed, uri, true), uri)
+	}
+
+	invalid := []string{
+		"https://evil.com/..",
+		"https://evil.com/cb",
+		"https://app.example.com.evil.com/cb",
+		"https://app.example.com@evil.com/cb",
+		"https://user@app.example.com/cb/x",
+		"//evil.com/cb",
+		"http://app.example.com/cb/x",
+		"https://app.example.com:8443/cb/x",
+		"http://localhost:3001/x",
+		"https://app.example.com/cbx",
+		"https://app.example.com/other",
+		"https://app.example.com/cb/../admin",
+		"https://app.example.com/cb/%2e%2e/admin",
+		"https://app.example.com/cb/..%2fadmin",
+		"https://app.example.com/cb/%5c%5cevil.com",
+		"https://app.example.com/cb/x#frag",
+		"javascript:alert(1)",
+	}
+	for _, uri := range invalid {
+		assert.False(t, h.isValidRedirectURI(allowed, uri, true), uri)
+	}
+
+	// 默认保持完全匹配
+	assert.False(t, h.isValidRedirectURI(allowed, "https://app.example.com/cb/deep/path", false))
+}
+
+func TestOAuthHandler_Authorize_InvalidRedirectURINotFollowed(t *testing.T) {
+	router := setupPromptTest(t, true)
+
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", "client-a")
+	query.Set("redirect_uri", "https://evil.com/..")
+	query.Set("scope", "openid")
+	req := httptest.NewRequest(http.MethodGet, "/oauth/authorize?"+query.Encode(), nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.NotEqual(t, http.StatusFound, w.Code)
+	assert.Empty(t, w.Header().Get("Location"))
+}
+
+// authorizePlainPKCE 以 plain 方式的 PKCE 请求授权端点，返回重定向地址
+func authorizePlainPKCE(t *testing.T, router *gin.Engine) *url.URL {
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", "client-a")
+	query.Set("redirect_uri", "https://a.example.com/cb")
+	query.Set("scope", "openid")
+	query.Set("code_challenge", "plain-challenge-value")
+	query.Set("code_challenge_method", "plain")
+	req := httptest.NewRequest(http.MethodGet, "/oauth/authorize?"+query.Encode(), nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusFound, w.Code)
+
+	location, err := url.Parse(w.Header().Get("Location"))
+	require.NoError(t, err)
+	return location
+}
+
+// setupFeatureTest 创建带功能开关的授权端点测试环境
+func setupFeatureTest(t *testing.T, values map[string]bool) (*gin.Engine, *[]string) {
+	var warnings []string
+	features := feature.New(values, func(msg string) { warnings = append(warnings, msg) })
+
+	router, oauthHandler, _ := setupOAuthTestRouter(t)
+	oauthHandler.features = features
+	oauthHandler.appService = &stubAppService{apps: map[string]*model.Application{
+		"client-a": {
+			ClientID:      "client-a",
+			OAuthVersion:  model.OAuthVersion20,
+			Status:        model.StatusActive,
+			RedirectURIs:  model.StringSlice{"https://a.example.com/cb"},
+			AllowedScopes: model.StringSlice{"openid"},
+		},
+	}}
+	router.GET("/oauth/authorize", func(c *gin.Context) { c.Set("user_id", "user-123") }, oauthHandler.Authorize)
+	return router, &warnings
+}
+
+func TestOAuthHandler_Authorize_PlainPKCEDisabled(t *testing.T) {
+	router, _ := setupFeatureTest(t, map[string]bool{feature.AllowPlainPKCE: false})
+
+	location := authorizePlainPKCE(t, router)
+	assert.Equal(t, "invalid_request", location.Query().Get("error"))
+	assert.Empty(t, location.Query().Get("code"))
+}
+
+func TestOAuthHandler_Authorize_PlainPKCEWarnsOnce(t *testing.T) {
+	router, warnings := setupFeatureTest(t, map[string]bool{feature.AllowOAuth20: true})
+
+	for i := 0; i < 2; i++ {
+		location := authorizePlainPKCE(t, router)
+		assert.NotEmpty(t, location.Query().Get("code"))
+	}
+
+	var plainWarnings int
+	for _, w := range *warnings {
+		if strings.Contains(w, feature.AllowPlainPKCE) {
+			plainWarnings++
+		}
+	}
+	assert.Equal(t, 1, plainWarnings)
+}
+
+func TestOAuthHandler_Authorize_OAuth20Disabled(t *testing.T) {
+	router, _ := setupFeatureTest(t, map[string]bool{feature.AllowOAuth20: false})
+
+	location := authorizeWithPrompt(t, router, "")
+	assert.Equal(t, "unauthorized_client", location.Query().Get("error"))
+}
+
+func TestIsInsecureRedirectURI(t *testing.T) {
+	assert.True(t, isInsecureRedirectURI("http://app.example.com/cb"))
+	assert.False(t, isInsecureRedirectURI("https://app.example.com/cb"))
+	assert.False(t, isInsecureRedirectURI("http://localhost:3000/cb"))
+	assert.False(t, isInsecureRedirectURI("http://127.0.0.1:8080/cb"))
+	assert.False(t, isInsecureRedirectURI("http://[::1]/cb"))
+}
+
+// assertExpiresIn 断言响应中的 expires_in 与访问令牌 exp - iat 一致
+func assertExpiresIn(t *testing.T, tokenService service.TokenService, accessToken string, expiresIn float64) {
+	claims, err := tokenService.ValidateToken(context.Background(), accessToken)
+	require.NoError(t, err)
+	assert.Equal(t, claims.ExpiresAt.Unix()-claims.IssuedAt.Unix(), int64(expiresIn))
+}
+
+func TestOAuthHandler_Token_ExpiresInMatchesConfig(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	tokenService := service.NewTokenService(&service.TokenServiceConfig{
+		PrivateKey:    privateKey,
+		PublicKey:     &privateKey.PublicKey,
+		KeyID:         "test-key",
+		Issuer:        "http://localhost:8080",
+		AccessExpiry:  2 * time.Hour,
+		RefreshExpiry: 7 * 24 * time.Hour,
+		CodeExpiry:    10 * time.Minute,
+	})
+
+	app := &model.Application{ClientID: "client-a", OAuthVersion: model.OAuthVersion21, Status: model.StatusActive, RedirectURIs: model.StringSlice{"https://a.example.com/cb"}}
+	require.NoError(t, app.SetClientSecret("secret-a"))
+	h := NewOAuthHandler(&stubAppService{apps: map[string]*model.Application{"client-a": app}}, tokenService, nil)
+	router := gin.New()
+	router.POST("/oauth/token", h.Token)
+
+	tokenResponse := func(form url.Values) map[string]interface{} {
+		w := postTokenForm(router, form)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var resp map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, float64(7200), resp["expires_in"])
+		assertExpiresIn(t, tokenService, resp["access_token"].(string), resp["expires_in"].(float64))
+		return resp
+	}
+
+	// 授权码
+	resp := tokenResponse(url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {issueTestCode(t, tokenService, "client-a", "https://a.example.com/cb")},
+		"client_id":     {"client-a"},
+		"client_secret": {"secret-a"},
+		"redirect_uri":  {"https://a.example.com/cb"},
+	})
+
+	// 刷新令牌
+	tokenResponse(url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {resp["refresh_token"].(string)},
+		"client_id":     {"client-a"},
+		"client_secret": {"secret-a"},
+	})
+
+	// 客户端凭证
+	tokenResponse(url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {"client-a"},
+		"client_secret": {"secret-a"},
+	})
+}
+
+func TestOAuthHandler_Token_AppTokenTTL(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	tokenService := service.NewTokenService(&service.TokenServiceConfig{
+		PrivateKey:    privateKey,
+		PublicKey:     &privateKey.PublicKey,
+		KeyID:         "test-key",
+		Issuer:        "http://localhost:8080",
+		AccessExpiry:  2 * time.Hour,
+		RefreshExpiry: 7 * 24 * time.Hour,
+		CodeExpiry:    10 * time.Minute,
+	})
+
+	// 应用配置 60 秒访问令牌、1 小时刷新令牌
+	app := &model.Application{
+		ClientID:        "client-a",
+		OAuthVersion:    model.OAuthVersion21,
+		Status:          model.StatusActive,
+		RedirectURIs:    model.StringSlice{"https://a.example.com/cb"},
+		AccessTokenTTL:  60,
+		RefreshTokenTTL: 3600,
+	}
+	require.NoError(t, app.SetClientSecret("secret-a"))
+	h := NewOAuthHandler(&stubAppService{apps: map[string]*model.Application{"client-a": app}}, tokenService, nil)
+	router := gin.New()
+	router.POST("/oauth/token", h.Token)
+
+	tokenResponse := func(form url.Values) map[string]interface{} {
+		w := postTokenForm(router, form)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var resp map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, float64(60), resp["expires_in"])
+		assertExpiresIn(t, tokenService, resp["access_token"].(string), resp["expires_in"].(float64))
+		return resp
+	}
+	assertRefreshTTL := func(refreshToken string) {
+		claims, err := tokenService.ValidateToken(context.Background(), refreshToken)
+		require.NoError(t, err)
+		assert.Equal(t, int64(3600), claims.ExpiresAt.Unix()-claims.IssuedAt.Unix())
+	}
+
+	// 授权码
+	resp := tokenResponse(url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {issueTestCode(t, tokenService, "client-a", "https://a.example.com/cb")},
+		"client_id":     {"client-a"},
+		"client_secret": {"secret-a"},
+		"redirect_uri":  {"https://a.example.com/cb"},
+	})
+	assertRefreshTTL(resp["refresh_token"].(string))
+
+	// 刷新令牌沿用应用配置
+	resp = tokenResponse(url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {resp["refresh_token"].(string)},
+		"client_id":     {"client-a"},
+		"client_secret": {"secret-a"},
+	})
+	assertRefreshTTL(resp["refresh_token"].(string))
+}
+
+func TestOAuthHandler_Authorize_RequirePKCES256(t *testing.T) {
+	cfg := &config.Config{OAuth: config.OAuthConfig{RequirePKCES256: true}}
+	router, _ := setupFeatureTest(t, cfg.FeatureValues())
+
+	// plain 被拒绝
+	location := authorizePlainPKCE(t, router)
+	assert.Equal(t, "invalid_request", location.Query().Get("error"))
+
+	// S256 正常签发授权码
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", "client-a")
+	query.Set("redirect_uri", "https://a.example.com/cb")
+	query.Set("scope", "openid")
+	query.Set("code_challenge", "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM")
+	query.Set("code_challenge_method", "S256")
+	req := httptest.NewRequest(http.MethodGet, "/oauth/authorize?"+query.Encode(), nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusFound, w.Code)
+	s256Location, err := url.Parse(w.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.NotEmpty(t, s256Location.Query().Get("code"))
+
+	// 发现文档只声明 S256
+	oidcHandler := NewOIDCHandler(nil, nil, nil, nil, "http://localhost:8080", feature.New(cfg.FeatureValues()))
+	assert.Equal(t, []string{"S256"}, oidcHandler.codeChallengeMethods())
+	assert.Equal(t, []string{"plain", "S256"}, NewOIDCHandler(nil, nil, nil, nil, "http://localhost:8080").codeChallengeMethods())
+}
+
+// stubAppRoleRBACService RBAC 服务桩，仅实现应用角色查询
+type stubAppRoleRBACService struct {
+	service.RBACService
+	roles []*model.Role
+}
+
+func (s *stubAppRoleRBACService) GetAppRoles(ctx context.Context, appID string) ([]*model.Role, error) {
+	return s.roles, nil
+}
+
+func TestOAuthHandler_ClientCredentials_ServiceAccount(t *testing.T) {
+	router, oauthHandler, tokenService := setupOAuthTestRouter(t)
+	app := &model.Application{ClientID: "svc", OAuthVersion: model.OAuthVersion20, Status: model.StatusActive, ServiceAccount: true}
+	require.NoError(t, app.SetClientSecret("svc-secret"))
+	oauthHandler.appService = &stubAppService{apps: map[string]*model.Application{"svc": app}}
+	rbacService := &stubAppRoleRBACService{roles: []*model.Role{
+		{Code: "user_reader", Status: model.StatusActive, Permissions: []model.Permission{{Code: "user:read"}}},
+		{Code: "disabled", Status: model.StatusDisabled, Permissions: []model.Permission{{Code: "user:delete"}}},
+	}}
+	oauthHandler.SetRBACService(rbacService)
+	router.POST("/oauth/token", oauthHandler.Token)
+
+	// 受保护的接口只通过令牌中的角色和权限鉴权，不查询 RBAC 服务
+	protected := router.Group("/api", middleware.JWTAuth(tokenService))
+	protected.GET("/users", middleware.RequirePermission(rbacService, "user", "read"), func(c *gin.Context) { c.Status(http.StatusOK) })
+	protected.DELETE("/users", middleware.RequirePermission(rbacService, "user", "delete"), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	form := url.Values{"grant_type": {"client_credentials"}}
+	req := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth("svc", "svc-secret")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	accessToken := resp["access_token"].(string)
+	claims, err := tokenService.ValidateToken(context.Background(), accessToken)
+	require.NoError(t, err)
+	assert.True(t, claims.IsServiceAccount())
+	assert.Equal(t, []string{"user_reader"}, claims.Roles)
+	assert.Equal(t, []string{"user:read"}, claims.Permissions)
+
+	call := func(method string) int {
+		req := httptest.NewRequest(method, "/api/users", nil)
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	assert.Equal(t, http.StatusOK, call(http.MethodGet))
+	assert.Equal(t, http.StatusForbidden, call(http.MethodDelete))
+}
+
+// setupOfflineAccessTest 创建授权端点测试环境：client-a 允许全部授权类型，client-b 只允许授权码模式
+func setupOfflineAccessTest(t *testing.T, requireRefreshGrant bool) (*gin.Engine, service.TokenService) {
+	router, oauthHandler, tokenService := setupOAuthTestRouter(t)
+	oauthHandler.SetRequireRefreshGrantForOfflineAccess(requireRefreshGrant)
+	newApp := func(clientID string, grantTypes model.StringSlice) *model.Application {
+		return &model.Application{
+			ClientID:          clientID,
+			OAuthVersion:      model.OAuthVersion20,
+			Status:            model.StatusActive,
+			RedirectURIs:      model.StringSlice{"https://" + clientID + ".example.com/cb"},
+			AllowedScopes:     model.StringSlice{"openid", "offline_access"},
+			AllowedGrantTypes: grantTypes,
+		}
+	}
+	oauthHandler.appService = &stubAppService{apps: map[string]*model.Application{
+		"client-a": newApp("client-a", nil),
+		"client-b": newApp("client-b", model.StringSlice{"authorization_code"}),
+	}}
+	router.GET("/oauth/authorize", func(c *gin.Context) { c.Set("user_id", "user-123") }, oauthHandler.Authorize)
+	router.POST("/oauth/token", oauthHandler.Token)
+	return router, tokenService
+}
+
+// authorizeOfflineAccess 以 openid offline_access 请求授权端点，返回重定向地址
+func authorizeOfflineAccess(t *testing.T, router *gin.Engine, clientID string) *url.URL {
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", clientID)
+	query.Set("redirect_uri", "https://"+clientID+".example.com/cb")
+	query.Set("scope", "openid offline_access")
+	req := httptest.NewRequest(http.MethodGet, "/oauth/authorize?"+query.Encode(), nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusFound, w.Code)
+
+	location, err := url.Parse(w.Header().Get("Location"))
+	require.NoError(t, err)
+	return location
+}
+
+func TestOAuthHandler_Authorize_OfflineAccessRequiresRefreshGrant(t *testing.T) {
+	router, _ := setupOfflineAccessTest(t, true)
+
+	// 不允许 refresh_token 的应用申请 offline_access 在授权阶段被拒绝
+	location := authorizeOfflineAccess(t, router, "client-b")
+	assert.Equal(t, "invalid_scope", location.Query().Get("error"))
+	assert.Empty(t, location.Query().Get("code"))
+
+	location = authorizeOfflineAccess(t, router, "client-a")
+	assert.NotEmpty(t, location.Query().Get("code"))
+
+	// 关闭校验后放行
+	router, _ = setupOfflineAccessTest(t, false)
+	location = authorizeOfflineAccess(t, router, "client-b")
+	assert.NotEmpty(t, location.Query().Get("code"))
+}
+
+func TestOAuthHandler_AuthorizationCode_NoRefreshTokenWithoutGrant(t *testing.T) {
+	router, tokenService := setupOfflineAccessTest(t, true)
+
+	exchange := func(clientID string) map[string]interface{} {
+		redirectURI := "https://" + clientID + ".example.com/cb"
+		form := url.Values{}
+		form.Set("grant_type", "authorization_code")
+		form.Set("code", issueTestCode(t, tokenService, clientID, redirectURI))
+		form.Set("client_id", clientID)
+		form.Set("redirect_uri", redirectURI)
+		w := postTokenForm(router, form)
+		require.Equal(t, http.StatusOK, w.Code)
+		var resp map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp
+	}
+
+	assert.NotEmpty(t, exchange("client-a")["refresh_token"])
+	assert.NotContains(t, exchange("client-b"), "refresh_token")
+}
+
+// stubScopeService 按代码返回预置的 scope 说明，未登记的以代码作为名称
+type stubScopeService struct {
+	service.ScopeService
+	scopes map[string]*model.Scope
+}
+
+func (s *stubScopeService) Describe(ctx context.Context, codes []string) ([]*model.Scope, error) {
+	result := make([]*model.Scope, 0, len(codes))
+	for _, code := range codes {
+		if scope, ok := s.scopes[code]; ok {
+			result = append(result, scope)
+			continue
+		}
+		result = append(result, &model.Scope{Code: code, DisplayName: code})
+	}
+	return result, nil
+}
+
+func TestOAuthHandler_ConsentInfo(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	orgID := "org-1"
+	app := &model.Application{
+		Name:          "第三方应用",
+		ClientID:      "client-a",
+		OrgID:         &orgID,
+		AllowedScopes: model.StringSlice{"openid", "email", "custom:read"},
+	}
+	systemApp := &model.Application{Name: "系统应用", ClientID: "client-sys", AllowedScopes: model.StringSlice{"openid"}}
+	h := NewOAuthHandler(&stubAppService{apps: map[string]*model.Application{"client-a": app, "client-sys": systemApp}}, nil, nil)
+	h.SetConsentServices(
+		&stubScopeService{scopes: map[string]*model.Scope{
+			"openid": {Code: "openid", DisplayName: "基本身份", Description: "使用你的账号登录该应用"},
+			"email":  {Code: "email", DisplayName: "邮箱地址", Description: "读取你的邮箱地址及验证状态"},
+		}},
+		&stubOrgService{orgs: map[string]*model.Organization{orgID: {Branding: model.Branding{LogoURL: "https://cdn.example.com/logo.png"}}}},
+	)
+	router := gin.New()
+	router.GET("/oauth/consent-info", h.ConsentInfo)
+
+	get := func(query string) (*httptest.ResponseRecorder, ConsentInfo) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/oauth/consent-info?"+query, nil))
+		var resp struct {
+			Data ConsentInfo `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return w, resp.Data
+	}
+
+	w, info := get("client_id=client-a&scope=" + url.QueryEscape("openid email custom:read"))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "第三方应用", info.AppName)
+	assert.Equal(t, "https://cdn.example.com/logo.png", info.LogoURL)
+	require.Len(t, info.Scopes, 3)
+	assert.Equal(t, ScopeInfo{Code: "openid", DisplayName: "基本身份", Description: "使用你的账号登录该应用"}, info.Scopes[0])
+	assert.Equal(t, "邮箱地址", info.Scopes[1].DisplayName)
+	assert.Equal(t, ScopeInfo{Code: "custom:read", DisplayName: "custom:read"}, info.Scopes[2], "未登记说明的 scope 以代码展示")
+
+	// 系统级应用没有组织品牌
+	w, info = get("client_id=client-sys&scope=openid")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, info.LogoURL)
+
+	// 应用未允许的 scope
+	w, _ = get("client_id=client-a&scope=phone")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w, _ = get("client_id=missing&scope=openid")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w, _ = get("scope=openid")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// stubConsentService 内存授权记录，key 为 userID/clientID
+type stubConsentService struct {
+	consents map[string]*model.UserConsent
+	revoked  []string
+}
+
+func newStubConsentService() *stubConsentService {
+	return &stubConsentService{consents: make(map[string]*model.UserConsent)}
+}
+
+func (s *stubConsentService) HasConsent(ctx context.Context, userID, clientID string, scopes []string) (bool, error) {
+	consent, ok := s.consents[userID+"/"+clientID]
+	if !ok {
+		return false, nil
+	}
+	for _, scope := range scopes {
+		if !containsScope(consent.Scopes, scope) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (s *stubConsentService) Grant(ctx context.Context, userID, clientID string, scopes []string) error {
+	key := userID + "/" + clientID
+	consent, ok := s.consents[key]
+	if !ok {
+		consent = &model.UserConsent{UserID: userID, ClientID: clientID}
+		s.consents[key] = consent
+	}
+	consent.Scopes = append(consent.Scopes, scopes...)
+	return nil
+}
+
+func (s *stubConsentService) ListByUser(ctx context.Context, userID string) ([]*model.UserConsent, error) {
+	var result []*model.UserConsent
+	for _, consent := range s.consents {
+		if consent.UserID == userID {
+			result = append(result, consent)
+		}
+	}
+	return result, nil
+}
+
+func (s *stubConsentService) Revoke(ctx context.Context, userID, clientID string) error {
+	key := userID + "/" + clientID
+	if _, ok := s.consents[key]; !ok {
+		return service.ErrConsentNotFound
+	}
+	delete(s.consents, key)
+	s.revoked = append(s.revoked, key)
+	return nil
+}
+
+func TestOAuthHandler_AuthorizeConsent(t *testing.T) {
+	router, oauthHandler, _ := setupOAuthTestRouter(t)
+	oauthHandler.appService = &stubAppService{apps: map[string]*model.Application{
+		"client-a": {
+			ClientID:      "client-a",
+			OAuthVersion:  model.OAuthVersion20,
+			Status:        model.StatusActive,
+			RedirectURIs:  model.StringSlice{"https://a.example.com/cb"},
+			AllowedScopes: model.StringSlice{"openid", "email"},
+		},
+	}}
+	oauthHandler.SetConsentService(newStubConsentService())
+	session := func(c *gin.Context) {
+		c.Set("user_id", "user-123")
+		c.Set("session_id", "session-1")
+	}
+	router.GET("/oauth/authorize", session, oauthHandler.Authorize)
+	router.POST("/oauth/authorize/decision", session, oauthHandler.AuthorizeDecision)
+
+	authorize := func(scope, prompt string) *url.URL {
+		query := url.Values{}
+		query.Set("response_type", "code")
+		query.Set("client_id", "client-a")
+		query.Set("redirect_uri", "https://a.example.com/cb")
+		query.Set("scope", scope)
+		query.Set("state", "xyz")
+		if prompt != "" {
+			query.Set("prompt", prompt)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/oauth/authorize?"+query.Encode(), nil))
+		require.Equal(t, http.StatusFound, w.Code)
+		location, err := url.Parse(w.Header().Get("Location"))
+		require.NoError(t, err)
+		return location
+	}
+	decide := func(decision string) (*httptest.ResponseRecorder, *url.URL) {
+		body, _ := json.Marshal(map[string]string{
+			"response_type": "code",
+			"client_id":     "client-a",
+			"redirect_uri":  "https://a.example.com/cb",
+			"scope":         "openid",
+			"state":         "xyz",
+			"decision":      decision,
+		})
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/oauth/authorize/decision", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		var resp struct {
+			Data struct {
+				RedirectURI string `json:"redirect_uri"`
+			} `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		location, err := url.Parse(resp.Data.RedirectURI)
+		require.NoError(t, err)
+		return w, location
+	}
+
+	// 首次授权跳转同意页，原始请求参数原样带上
+	location := authorize("openid", "")
+	assert.Equal(t, "/consent", location.Path)
+	assert.Equal(t, "client-a", location.Query().Get("client_id"))
+	assert.Empty(t, location.Query().Get("code"))
+
+	// 静默鉴权不展示同意页
+	location = authorize("openid", "none")
+	assert.Equal(t, "consent_required", location.Query().Get("error"))
+
+	// 拒绝授权
+	w, location := decide("deny")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, "a.example.com", location.Host)
+	assert.Equal(t, "access_denied", location.Query().Get("error"))
+	assert.Equal(t, "xyz", location.Query().Get("state"))
+	assert.Equal(t, "/consent", authorize("openid", "").Path, "拒绝不记录授权")
+
+	// 同意后签发授权码
+	w, location = decide("approve")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "a.example.com", location.Host)
+	assert.NotEmpty(t, location.Query().Get("code"))
+	assert.Equal(t, "xyz", location.Query().Get("state"))
+
+	// 已授权的应用再次授权直接签发授权码
+	location = authorize("openid", "")
+	assert.NotEmpty(t, location.Query().Get("code"))
+
+	// 申请新的 scope 或 prompt=consent 时重新征求同意
+	assert.Equal(t, "/consent", authorize("openid email", "").Path)
+	assert.Equal(t, "/consent", authorize("openid", "consent").Path)
+
+	// 非法的决定
+	w, _ = decide("maybe")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestOAuthHandler_AuthorizeDecision_RequiresLoginToken(t *testing.T) {
+	router, oauthHandler, _ := setupOAuthTestRouter(t)
+	oauthHandler.appService = &stubAppService{apps: map[string]*model.Application{
+		"client-a": {
+			ClientID:     "client-a",
+			OAuthVersion: model.OAuthVersion20,
+			Status:       model.StatusActive,
+			RedirectURIs: model.StringSlice{"https://a.example.com/cb"},
+		},
+	}}
+	consents := newStubConsentService()
+	oauthHandler.SetConsentService(consents)
+
+	// 模拟 JWTAuth 中间件写入的令牌信息
+	withToken := func(clientID, sessionID string) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			c.Set("user_id", "user-123")
+			c.Set("client_id", clientID)
+			c.Set("session_id", sessionID)
+		}
+	}
+	router.POST("/client-token/decision", withToken("client-b", ""), oauthHandler.AuthorizeDecision)
+	router.POST("/no-session/decision", withToken("", ""), oauthHandler.AuthorizeDecision)
+	router.POST("/login/decision", withToken("", "session-1"), oauthHandler.AuthorizeDecision)
+
+	decide := func(path string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]string{
+			"response_type": "code",
+			"client_id":     "client-a",
+			"redirect_uri":  "https://a.example.com/cb",
+			"decision":      "approve",
+		})
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// 客户端签发的访问令牌和不属于登录会话的令牌都不能代替用户同意
+	assert.Equal(t, http.StatusForbidden, decide("/client-token/decision").Code)
+	assert.Equal(t, http.StatusForbidden, decide("/no-session/decision").Code)
+	assert.Empty(t, consents.consents)
+
+	w := decide("/login/decision")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NotEmpty(t, consents.consents)
+}
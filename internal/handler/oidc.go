@@ -2,30 +2,84 @@
 package handler
 
 import (
+	"crypto"
+	"crypto/ecdsa"
 	"crypto/rsa"
 	"encoding/base64"
+	"fmt"
 	"math/big"
 	"net/http"
+	"net/url"
+	"sort"
 
 	"github.com/gin-gonic/gin"
+	"github.com/pu-ac-cn/uac-backend/internal/feature"
 	"github.com/pu-ac-cn/uac-backend/internal/service"
 	"github.com/pu-ac-cn/uac-backend/pkg/response"
 )
 
 // OIDCHandler OIDC 处理器
 type OIDCHandler struct {
-	userService  service.UserService
-	tokenService service.TokenService
-	issuer       string
+	userService    service.UserService
+	tokenService   service.TokenService
+	appService     service.ApplicationService
+	sessionService service.SessionService
+	features       *feature.Flags
+	issuer         string
 }
 
 // NewOIDCHandler 创建 OIDC 处理器
-func NewOIDCHandler(userSvc service.UserService, tokenSvc service.TokenService, issuer string) *OIDCHandler {
-	return &OIDCHandler{
-		userService:  userSvc,
-		tokenService: tokenSvc,
-		issuer:       issuer,
+// issuer 去掉尾部斜杠，与令牌中的 iss 保持一致；features 可选，用于生成发现文档
+func NewOIDCHandler(userSvc service.UserService, tokenSvc service.TokenService, appSvc service.ApplicationService, sessionSvc service.SessionService, issuer string, features ...*feature.Flags) *OIDCHandler {
+	h := &OIDCHandler{
+		userService:    userSvc,
+		tokenService:   tokenSvc,
+		appService:     appSvc,
+		sessionService: sessionSvc,
+		issuer:         service.NormalizeIssuer(issuer),
 	}
+	if len(features) > 0 {
+		h.features = features[0]
+	}
+	return h
+}
+
+// scopeClaims 支持的 scope 及其在 UserInfo 中授予的声明
+// Discovery 文档的 scopes_supported 与 claims_supported 由此生成，修改 UserInfo 时需同步
+var scopeClaims = map[string][]string{
+	"openid":         {"sub"},
+	"profile":        {"name", "preferred_username", "picture"},
+	"email":          {"email", "email_verified"},
+	"phone":          {"phone_number", "phone_number_verified"},
+	"offline_access": nil,
+}
+
+// tokenClaims ID 令牌中与 scope 无关的标准声明
+var tokenClaims = []string{"iss", "aud", "exp", "iat", "auth_time", "nonce", "sid"}
+
+// SupportedScopes 返回支持的 scope
+func SupportedScopes() []string {
+	scopes := make([]string, 0, len(scopeClaims))
+	for scope := range scopeClaims {
+		scopes = append(scopes, scope)
+	}
+	sort.Strings(scopes)
+	return scopes
+}
+
+// supportedClaims 返回支持的声明：标准令牌声明加上各 scope 授予的声明
+func supportedClaims() []string {
+	seen := make(map[string]bool)
+	claims := []string{}
+	for _, scope := range SupportedScopes() {
+		for _, claim := range scopeClaims[scope] {
+			if !seen[claim] {
+				seen[claim] = true
+				claims = append(claims, claim)
+			}
+		}
+	}
+	return append(claims, tokenClaims...)
 }
 
 // UserInfo 用户信息端点
@@ -38,16 +92,23 @@ func (h *OIDCHandler) UserInfo(c *gin.Context) {
 		return
 	}
 
+	// 获取令牌的 scopes（由认证中间件设置）
+	scopes, _ := c.Get("scopes")
+	scopeList, _ := scopes.([]string)
+
+	// UserInfo 仅对 OIDC 授权签发的令牌开放
+	if !containsScope(scopeList, "openid") {
+		c.Header("WWW-Authenticate", `Bearer error="insufficient_scope", scope="openid"`)
+		response.ErrorWithMsg(c, response.CodeForbidden, "令牌缺少 openid scope")
+		return
+	}
+
 	user, err := h.userService.GetByID(c.Request.Context(), userID.(string))
 	if err != nil {
 		response.Error(c, response.CodeUserNotFound)
 		return
 	}
 
-	// 获取请求的 scopes
-	scopes, _ := c.Get("scopes")
-	scopeList, _ := scopes.([]string)
-
 	// 构建响应
 	resp := gin.H{
 		"sub": user.ID,
@@ -75,6 +136,77 @@ func (h *OIDCHandler) UserInfo(c *gin.Context) {
 	c.JSON(http.StatusOK, resp)
 }
 
+// logoutCompletePage 未指定或不允许跳转时展示的注销完成页
+const logoutCompletePage = `<!DOCTYPE html>
+<html lang="zh-CN">
+<head><meta charset="utf-8"><title>已注销</title></head>
+<body><p>您已成功注销。</p></body>
+</html>`
+
+// EndSession RP 发起的注销端点
+// GET /oauth/logout?id_token_hint=...&post_logout_redirect_uri=...&state=...
+// 销毁 ID 令牌对应的登录会话，跳转地址在应用允许列表中时 302 跳转，否则展示注销完成页
+func (h *OIDCHandler) EndSession(c *gin.Context) {
+	hint := c.Query("id_token_hint")
+	if hint == "" {
+		response.ErrorWithMsg(c, response.CodeInvalidRequest, "缺少 id_token_hint 参数")
+		return
+	}
+
+	claims, err := h.tokenService.ValidateIDTokenHint(c.Request.Context(), hint)
+	if err != nil {
+		response.ErrorWithMsg(c, response.CodeInvalidToken, "id_token_hint 无效")
+		return
+	}
+
+	// client_id 可选，提供时必须与 ID 令牌的受众一致
+	clientID := c.Query("client_id")
+	if clientID == "" && len(claims.Audience) > 0 {
+		clientID = claims.Audience[0]
+	}
+	if clientID == "" || !containsScope(claims.Audience, clientID) {
+		response.ErrorWithMsg(c, response.CodeInvalidRequest, "client_id 与 id_token_hint 不匹配")
+		return
+	}
+
+	// 销毁会话：ID 令牌带 sid 时只结束对应会话，否则结束该用户全部会话
+	ctx := c.Request.Context()
+	if claims.SessionID != "" {
+		err = h.sessionService.Delete(ctx, claims.SessionID)
+	} else {
+		err = h.sessionService.DeleteByUserID(ctx, claims.UserID)
+	}
+	if err != nil {
+		response.Error(c, response.CodeServerError)
+		return
+	}
+
+	redirectURI := c.Query("post_logout_redirect_uri")
+	if redirectURI != "" {
+		if app, err := h.appService.GetByClientID(ctx, clientID); err == nil && app.HasPostLogoutRedirectURI(redirectURI) {
+			if redirectURL, err := url.Parse(redirectURI); err == nil {
+				if state := c.Query("state"); state != "" {
+					query := redirectURL.Query()
+					query.Set("state", state)
+					redirectURL.RawQuery = query.Encode()
+				}
+				c.Redirect(http.StatusFound, redirectURL.String())
+				return
+			}
+		}
+	}
+
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(logoutCompletePage))
+}
+
+// codeChallengeMethods 返回支持的 PKCE 方式，plain 受功能开关控制
+func (h *OIDCHandler) codeChallengeMethods() []string {
+	if h.features.Enabled(feature.AllowPlainPKCE) {
+		return []string{"plain", "S256"}
+	}
+	return []string{"S256"}
+}
+
 // Discovery OIDC 发现文档端点
 // GET /.well-known/openid-configuration
 func (h *OIDCHandler) Discovery(c *gin.Context) {
@@ -83,37 +215,67 @@ func (h *OIDCHandler) Discovery(c *gin.Context) {
 		"authorization_endpoint":                h.issuer + "/oauth/authorize",
 		"token_endpoint":                        h.issuer + "/oauth/token",
 		"userinfo_endpoint":                     h.issuer + "/oauth/userinfo",
+		"end_session_endpoint":                  h.issuer + "/oauth/logout",
 		"jwks_uri":                              h.issuer + "/.well-known/jwks.json",
 		"revocation_endpoint":                   h.issuer + "/oauth/revoke",
 		"introspection_endpoint":                h.issuer + "/oauth/introspect",
 		"response_types_supported":              []string{"code"},
-		"grant_types_supported":                 []string{"authorization_code", "refresh_token", "client_credentials"},
+		"grant_types_supported":                 SupportedGrantTypes(),
 		"subject_types_supported":               []string{"public"},
-		"id_token_signing_alg_values_supported": []string{"RS256"},
-		"scopes_supported":                      []string{"openid", "profile", "email", "phone", "offline_access"},
-		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post"},
-		"claims_supported": []string{
-			"sub", "iss", "aud", "exp", "iat", "auth_time",
-			"name", "preferred_username", "email", "email_verified",
-			"phone_number", "phone_number_verified", "picture",
-		},
-		"code_challenge_methods_supported": []string{"plain", "S256"},
+		"id_token_signing_alg_values_supported": []string{h.tokenService.SigningAlgorithm()},
+		"scopes_supported":                      SupportedScopes(),
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post", "none"},
+		"claims_supported":                      supportedClaims(),
+		"code_challenge_methods_supported":      h.codeChallengeMethods(),
 	})
 }
 
 // JWKS JSON Web Key Set 端点
 // GET /.well-known/jwks.json
 func (h *OIDCHandler) JWKS(c *gin.Context) {
-	publicKey := h.tokenService.GetPublicKey()
-	keyID := h.tokenService.GetKeyID()
-
-	jwk := rsaPublicKeyToJWK(publicKey, keyID)
+	jwk, err := publicKeyToJWK(h.tokenService.GetPublicKey(), h.tokenService.GetKeyID())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"keys": []gin.H{jwk},
 	})
 }
 
+// publicKeyToJWK 将签名公钥转换为 JWK 格式，支持 RSA 和 P-256 ECDSA
+func publicKeyToJWK(key crypto.PublicKey, keyID string) (gin.H, error) {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return rsaPublicKeyToJWK(k, keyID), nil
+	case *ecdsa.PublicKey:
+		return ecPublicKeyToJWK(k, keyID)
+	default:
+		return nil, fmt.Errorf("不支持的公钥类型 %T", key)
+	}
+}
+
+// ecPublicKeyToJWK 将 P-256 ECDSA 公钥转换为 JWK 格式（RFC 7518 6.2）
+func ecPublicKeyToJWK(key *ecdsa.PublicKey, keyID string) (gin.H, error) {
+	ecdhKey, err := key.ECDH()
+	if err != nil {
+		return nil, err
+	}
+	// 未压缩点格式：0x04 || X || Y，X、Y 各 32 字节
+	point := ecdhKey.Bytes()
+	size := (len(point) - 1) / 2
+	return gin.H{
+		"kty": "EC",
+		"use": "sig",
+		"alg": "ES256",
+		"kid": keyID,
+		"crv": key.Curve.Params().Name,
+		"x":   base64.RawURLEncoding.EncodeToString(point[1 : 1+size]),
+		"y":   base64.RawURLEncoding.EncodeToString(point[1+size:]),
+	}, nil
+}
+
 // rsaPublicKeyToJWK 将 RSA 公钥转换为 JWK 格式
 func rsaPublicKeyToJWK(key *rsa.PublicKey, keyID string) gin.H {
 	return gin.H{
@@ -1,16 +1,26 @@
 package handler
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
+	"encoding/base64"
 	"encoding/json"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
 	"github.com/gin-gonic/gin"
+	"github.com/pu-ac-cn/uac-backend/internal/middleware"
+	"github.com/pu-ac-cn/uac-backend/internal/model"
 	"github.com/pu-ac-cn/uac-backend/internal/service"
+	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -34,7 +44,7 @@ func setupOIDCTestRouter(t *testing.T) (*gin.Engine, *OIDCHandler, service.Token
 	})
 
 	// 创建 OIDC handler
-	oidcHandler := NewOIDCHandler(nil, tokenService, "http://localhost:8080")
+	oidcHandler := NewOIDCHandler(nil, tokenService, nil, nil, "http://localhost:8080")
 
 	router := gin.New()
 	return router, oidcHandler, tokenService
@@ -61,6 +71,7 @@ func TestOIDCHandler_Discovery(t *testing.T) {
 	assert.Equal(t, "http://localhost:8080/oauth/authorize", resp["authorization_endpoint"])
 	assert.Equal(t, "http://localhost:8080/oauth/token", resp["token_endpoint"])
 	assert.Equal(t, "http://localhost:8080/oauth/userinfo", resp["userinfo_endpoint"])
+	assert.Equal(t, "http://localhost:8080/oauth/logout", resp["end_session_endpoint"])
 	assert.Equal(t, "http://localhost:8080/.well-known/jwks.json", resp["jwks_uri"])
 
 	// 验证支持的响应类型
@@ -81,6 +92,69 @@ func TestOIDCHandler_Discovery(t *testing.T) {
 	assert.Contains(t, scopes, "email")
 }
 
+func TestOIDCHandler_Discovery_ReflectsRegistries(t *testing.T) {
+	router, oidcHandler, _ := setupOIDCTestRouter(t)
+	router.GET("/.well-known/openid-configuration", oidcHandler.Discovery)
+
+	req := httptest.NewRequest(http.MethodGet, "/.well-known/openid-configuration", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		GrantTypes  []string `json:"grant_types_supported"`
+		Scopes      []string `json:"scopes_supported"`
+		Claims      []string `json:"claims_supported"`
+		AuthMethods []string `json:"token_endpoint_auth_methods_supported"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+
+	assert.Equal(t, SupportedGrantTypes(), resp.GrantTypes)
+	assert.NotContains(t, resp.GrantTypes, "password")
+	assert.Equal(t, SupportedScopes(), resp.Scopes)
+	for _, claims := range scopeClaims {
+		for _, claim := range claims {
+			assert.Contains(t, resp.Claims, claim)
+		}
+	}
+	assert.Contains(t, resp.Claims, "nonce")
+	assert.Contains(t, resp.AuthMethods, "none")
+}
+
+func TestOIDCHandler_Discovery_IssuerTrailingSlash(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	tokenService := service.NewTokenService(&service.TokenServiceConfig{
+		PrivateKey:   privateKey,
+		PublicKey:    &privateKey.PublicKey,
+		KeyID:        "test-key-1",
+		Issuer:       "http://localhost:8080/",
+		AccessExpiry: 15 * time.Minute,
+	})
+	oidcHandler := NewOIDCHandler(nil, tokenService, nil, nil, "http://localhost:8080/")
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/.well-known/openid-configuration", oidcHandler.Discovery)
+
+	req := httptest.NewRequest(http.MethodGet, "/.well-known/openid-configuration", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "http://localhost:8080", resp["issuer"])
+	assert.Equal(t, "http://localhost:8080/oauth/token", resp["token_endpoint"])
+
+	token, err := tokenService.GenerateAccessToken(context.Background(), &service.TokenClaims{UserID: "user-1"})
+	require.NoError(t, err)
+	claims, err := tokenService.ValidateToken(context.Background(), token)
+	require.NoError(t, err)
+	assert.Equal(t, resp["issuer"], claims.Issuer)
+}
+
 func TestOIDCHandler_JWKS(t *testing.T) {
 	router, oidcHandler, _ := setupOIDCTestRouter(t)
 
@@ -112,6 +186,56 @@ func TestOIDCHandler_JWKS(t *testing.T) {
 	assert.NotEmpty(t, key["e"])
 }
 
+func TestOIDCHandler_JWKS_ES256(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tokenService := service.NewTokenService(&service.TokenServiceConfig{
+		Algorithm:    service.AlgES256,
+		PrivateKey:   privateKey,
+		PublicKey:    &privateKey.PublicKey,
+		KeyID:        "ec-key-1",
+		Issuer:       "http://localhost:8080",
+		AccessExpiry: 15 * time.Minute,
+	})
+	oidcHandler := NewOIDCHandler(nil, tokenService, nil, nil, "http://localhost:8080")
+	router := gin.New()
+	router.GET("/.well-known/jwks.json", oidcHandler.JWKS)
+	router.GET("/.well-known/openid-configuration", oidcHandler.Discovery)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		Keys []map[string]string `json:"keys"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Keys, 1)
+	key := resp.Keys[0]
+	assert.Equal(t, "EC", key["kty"])
+	assert.Equal(t, "ES256", key["alg"])
+	assert.Equal(t, "P-256", key["crv"])
+	assert.Equal(t, "ec-key-1", key["kid"])
+	assert.NotContains(t, key, "n")
+
+	// x、y 还原后与原公钥一致
+	x, err := base64.RawURLEncoding.DecodeString(key["x"])
+	require.NoError(t, err)
+	y, err := base64.RawURLEncoding.DecodeString(key["y"])
+	require.NoError(t, err)
+	assert.Len(t, x, 32)
+	assert.Len(t, y, 32)
+	assert.Equal(t, 0, new(big.Int).SetBytes(x).Cmp(privateKey.X))
+	assert.Equal(t, 0, new(big.Int).SetBytes(y).Cmp(privateKey.Y))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/.well-known/openid-configuration", nil))
+	var discovery map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &discovery))
+	assert.Equal(t, []interface{}{"ES256"}, discovery["id_token_signing_alg_values_supported"])
+}
+
 func TestOIDCHandler_UserInfo_Unauthorized(t *testing.T) {
 	router, oidcHandler, _ := setupOIDCTestRouter(t)
 
@@ -125,3 +249,211 @@ func TestOIDCHandler_UserInfo_Unauthorized(t *testing.T) {
 	// 没有认证信息应返回错误
 	assert.Equal(t, http.StatusUnauthorized, w.Code)
 }
+
+// setupUserInfoTest 创建经过 JWT 认证中间件的 UserInfo 路由
+func setupUserInfoTest(t *testing.T) (*gin.Engine, service.TokenService) {
+	router, _, tokenService := setupOIDCTestRouter(t)
+
+	user := &model.User{
+		Username:      "alice",
+		Email:         "alice@example.com",
+		DisplayName:   "Alice",
+		Phone:         "13800000000",
+		EmailVerified: true,
+	}
+	user.ID = "user-1"
+	oidcHandler := NewOIDCHandler(&stubUserService{user: user}, tokenService, nil, nil, "http://localhost:8080")
+
+	router.GET("/oauth/userinfo", middleware.JWTAuth(tokenService), oidcHandler.UserInfo)
+	return router, tokenService
+}
+
+// getUserInfo 使用指定 scope 的访问令牌请求 UserInfo
+func getUserInfo(t *testing.T, router *gin.Engine, tokenService service.TokenService, scopes ...string) *httptest.ResponseRecorder {
+	token, err := tokenService.GenerateAccessToken(context.Background(), &service.TokenClaims{UserID: "user-1", Scopes: scopes})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/oauth/userinfo", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestOIDCHandler_UserInfo_FiltersByTokenScope(t *testing.T) {
+	router, tokenService := setupUserInfoTest(t)
+
+	w := getUserInfo(t, router, tokenService, "openid", "email")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, map[string]interface{}{
+		"sub":            "user-1",
+		"email":          "alice@example.com",
+		"email_verified": true,
+	}, resp)
+
+	// profile 与 phone scope 返回对应字段
+	w = getUserInfo(t, router, tokenService, "openid", "profile", "phone")
+	require.Equal(t, http.StatusOK, w.Code)
+	resp = nil
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "alice", resp["preferred_username"])
+	assert.Equal(t, "13800000000", resp["phone_number"])
+	assert.NotContains(t, resp, "email")
+}
+
+func TestOIDCHandler_UserInfo_RequiresOpenIDScope(t *testing.T) {
+	router, tokenService := setupUserInfoTest(t)
+
+	w := getUserInfo(t, router, tokenService, "profile", "email")
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Header().Get("WWW-Authenticate"), "insufficient_scope")
+	assert.NotContains(t, w.Body.String(), "alice")
+}
+
+const logoutTestRedirect = "https://app.example.com/logged-out"
+
+// setupEndSessionTest 创建注销测试环境，预置一个登记了注销跳转地址的应用和两个登录会话
+func setupEndSessionTest(t *testing.T, accessExpiry time.Duration) (*gin.Engine, service.TokenService, service.SessionService) {
+	gin.SetMode(gin.TestMode)
+
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		client.Close()
+		mr.Close()
+	})
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	tokenService := service.NewTokenService(&service.TokenServiceConfig{
+		PrivateKey:   privateKey,
+		PublicKey:    &privateKey.PublicKey,
+		KeyID:        "test-key-1",
+		Issuer:       "http://localhost:8080",
+		AccessExpiry: accessExpiry,
+	})
+
+	sessionService := service.NewSessionService(client, nil)
+	for _, id := range []string{"session-1", "session-2"} {
+		require.NoError(t, sessionService.Create(context.Background(), &model.Session{ID: id, UserID: "user-1"}))
+	}
+
+	app := &model.Application{ClientID: "client-1", PostLogoutRedirectURIs: model.StringSlice{logoutTestRedirect}}
+	appService := &stubAppService{apps: map[string]*model.Application{"client-1": app}}
+
+	h := NewOIDCHandler(nil, tokenService, appService, sessionService, "http://localhost:8080")
+	router := gin.New()
+	router.GET("/oauth/logout", h.EndSession)
+	return router, tokenService, sessionService
+}
+
+// issueIDToken 为 session-1 签发 ID 令牌
+func issueIDToken(t *testing.T, tokenService service.TokenService, sessionID string) string {
+	idToken, err := tokenService.GenerateIDToken(context.Background(), &service.TokenClaims{
+		UserID:    "user-1",
+		AppID:     "client-1",
+		SessionID: sessionID,
+		Scopes:    []string{"openid"},
+	})
+	require.NoError(t, err)
+	return idToken
+}
+
+func endSession(router *gin.Engine, query url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/oauth/logout?"+query.Encode(), nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestOIDCHandler_EndSession_RedirectsToRegisteredURI(t *testing.T) {
+	router, tokenService, sessionService := setupEndSessionTest(t, 15*time.Minute)
+
+	w := endSession(router, url.Values{
+		"id_token_hint":            {issueIDToken(t, tokenService, "session-1")},
+		"post_logout_redirect_uri": {logoutTestRedirect},
+		"state":                    {"xyz"},
+	})
+
+	require.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, logoutTestRedirect+"?state=xyz", w.Header().Get("Location"))
+
+	// 仅结束 ID 令牌对应的会话
+	_, err := sessionService.Get(context.Background(), "session-1")
+	assert.Error(t, err)
+	_, err = sessionService.Get(context.Background(), "session-2")
+	assert.NoError(t, err)
+}
+
+func TestOIDCHandler_EndSession_UnregisteredURIShowsCompletePage(t *testing.T) {
+	router, tokenService, sessionService := setupEndSessionTest(t, 15*time.Minute)
+
+	w := endSession(router, url.Values{
+		"id_token_hint":            {issueIDToken(t, tokenService, "session-1")},
+		"post_logout_redirect_uri": {"https://evil.example.com"},
+	})
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Location"))
+	assert.Contains(t, w.Body.String(), "已成功注销")
+	_, err := sessionService.Get(context.Background(), "session-1")
+	assert.Error(t, err)
+}
+
+func TestOIDCHandler_EndSession_WithoutSIDEndsAllSessions(t *testing.T) {
+	router, tokenService, sessionService := setupEndSessionTest(t, 15*time.Minute)
+
+	w := endSession(router, url.Values{"id_token_hint": {issueIDToken(t, tokenService, "")}})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	sessions, err := sessionService.ListByUserID(context.Background(), "user-1")
+	require.NoError(t, err)
+	assert.Empty(t, sessions)
+}
+
+func TestOIDCHandler_EndSession_AcceptsExpiredHint(t *testing.T) {
+	router, tokenService, sessionService := setupEndSessionTest(t, -time.Minute)
+
+	w := endSession(router, url.Values{
+		"id_token_hint":            {issueIDToken(t, tokenService, "session-1")},
+		"post_logout_redirect_uri": {logoutTestRedirect},
+	})
+
+	assert.Equal(t, http.StatusFound, w.Code)
+	_, err := sessionService.Get(context.Background(), "session-1")
+	assert.Error(t, err)
+}
+
+func TestOIDCHandler_EndSession_InvalidHint(t *testing.T) {
+	router, tokenService, sessionService := setupEndSessionTest(t, 15*time.Minute)
+
+	accessToken, err := tokenService.GenerateAccessToken(context.Background(), &service.TokenClaims{UserID: "user-1", SessionID: "session-1"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		query url.Values
+	}{
+		{"缺少 id_token_hint", url.Values{}},
+		{"非 ID 令牌", url.Values{"id_token_hint": {accessToken}}},
+		{"签名无效", url.Values{"id_token_hint": {issueIDToken(t, tokenService, "session-1") + "x"}}},
+		{"client_id 不匹配", url.Values{"id_token_hint": {issueIDToken(t, tokenService, "session-1")}, "client_id": {"client-2"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := endSession(router, tt.query)
+			assert.Empty(t, w.Header().Get("Location"))
+			assert.NotContains(t, w.Body.String(), "已成功注销")
+		})
+	}
+
+	// 校验失败时不销毁会话
+	_, err = sessionService.Get(context.Background(), "session-1")
+	assert.NoError(t, err)
+}
@@ -2,6 +2,8 @@
 package handler
 
 import (
+	"context"
+	"errors"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
@@ -13,12 +15,32 @@ import (
 
 // OrgHandler 组织管理处理器
 type OrgHandler struct {
-	orgService service.OrganizationService
+	orgService  service.OrganizationService
+	appService  service.ApplicationService
+	userService service.UserService
+	rbacService service.RBACService
+	// 导入组织时按创建应用的规则校验应用配置
+	appValidator *AppHandler
 }
 
 // NewOrgHandler 创建组织管理处理器
-func NewOrgHandler(orgSvc service.OrganizationService) *OrgHandler {
-	return &OrgHandler{orgService: orgSvc}
+// appSvc 可选，用于评估删除组织对下属应用的影响
+func NewOrgHandler(orgSvc service.OrganizationService, appSvc ...service.ApplicationService) *OrgHandler {
+	h := &OrgHandler{orgService: orgSvc}
+	if len(appSvc) > 0 {
+		h.appService = appSvc[0]
+	}
+	return h
+}
+
+// SetUserService 设置用户服务，启用组织成员管理
+func (h *OrgHandler) SetUserService(userSvc service.UserService) {
+	h.userService = userSvc
+}
+
+// SetAppValidator 设置应用配置的校验规则，导入组织时与创建应用使用同一套规则
+func (h *OrgHandler) SetAppValidator(appHandler *AppHandler) {
+	h.appValidator = appHandler
 }
 
 // ListOrgs 获取组织列表
@@ -33,8 +55,16 @@ func (h *OrgHandler) ListOrgs(c *gin.Context) {
 	}
 
 	pagination := &repository.Pagination{
-		Page:     page,
-		PageSize: pageSize,
+		Page:      page,
+		PageSize:  pageSize,
+		SortBy:    c.Query("sort_by"),
+		SortOrder: c.Query("order"),
+	}
+
+	fields, err := parseFields(c, orgResponseFields)
+	if err != nil {
+		response.ErrorWithMsg(c, response.CodeInvalidRequest, err.Error())
+		return
 	}
 
 	orgs, total, err := h.orgService.List(c.Request.Context(), filter, pagination)
@@ -45,28 +75,28 @@ func (h *OrgHandler) ListOrgs(c *gin.Context) {
 
 	list := make([]gin.H, len(orgs))
 	for i, org := range orgs {
-		list[i] = h.orgToResponse(org)
+		list[i] = fields.apply(h.orgToResponse(org))
 	}
 
-	response.Success(c, gin.H{
-		"list":      list,
-		"total":     total,
-		"page":      page,
-		"page_size": pageSize,
-	})
+	response.Page(c, list, total, page, pageSize)
 }
 
 // GetOrg 获取组织详情
 // GET /api/v1/orgs/:id
 func (h *OrgHandler) GetOrg(c *gin.Context) {
 	id := c.Param("id")
+	fields, err := parseFields(c, orgResponseFields)
+	if err != nil {
+		response.ErrorWithMsg(c, response.CodeInvalidRequest, err.Error())
+		return
+	}
 	org, err := h.orgService.GetByID(c.Request.Context(), id)
 	if err != nil {
 		response.ErrorWithMsg(c, response.CodeOrgNotFound, "组织不存在")
 		return
 	}
 
-	response.Success(c, h.orgToResponse(org))
+	response.Success(c, fields.apply(h.orgToResponse(org)))
 }
 
 // CreateOrgRequest 创建组织请求
@@ -74,6 +104,8 @@ type CreateOrgRequest struct {
 	Name        string          `json:"name" binding:"required"`
 	Description string          `json:"description"`
 	Branding    *model.Branding `json:"branding"`
+	MaxApps     int             `json:"max_apps" binding:"min=0"`  // 0 表示不限
+	MaxUsers    int             `json:"max_users" binding:"min=0"` // 0 表示不限
 }
 
 // CreateOrg 创建组织
@@ -88,6 +120,8 @@ func (h *OrgHandler) CreateOrg(c *gin.Context) {
 	org := &model.Organization{
 		Name:        req.Name,
 		Description: req.Description,
+		MaxApps:     req.MaxApps,
+		MaxUsers:    req.MaxUsers,
 	}
 
 	if req.Branding != nil {
@@ -95,6 +129,14 @@ func (h *OrgHandler) CreateOrg(c *gin.Context) {
 	}
 
 	if err := h.orgService.Create(c.Request.Context(), org); err != nil {
+		if errors.Is(err, repository.ErrOrgSlugExists) {
+			response.Error(c, response.CodeOrgSlugExists)
+			return
+		}
+		if errors.Is(err, service.ErrInvalidBranding) {
+			response.ErrorWithMsg(c, response.CodeInvalidRequest, err.Error())
+			return
+		}
 		response.ErrorWithMsg(c, response.CodeServerError, err.Error())
 		return
 	}
@@ -108,6 +150,8 @@ type UpdateOrgRequest struct {
 	Description string          `json:"description"`
 	Branding    *model.Branding `json:"branding"`
 	Status      string          `json:"status"`
+	MaxApps     *int            `json:"max_apps" binding:"omitempty,min=0"`
+	MaxUsers    *int            `json:"max_users" binding:"omitempty,min=0"`
 }
 
 // UpdateOrg 更新组织
@@ -138,8 +182,18 @@ func (h *OrgHandler) UpdateOrg(c *gin.Context) {
 	if req.Status != "" {
 		org.Status = req.Status
 	}
+	if req.MaxApps != nil {
+		org.MaxApps = *req.MaxApps
+	}
+	if req.MaxUsers != nil {
+		org.MaxUsers = *req.MaxUsers
+	}
 
 	if err := h.orgService.Update(c.Request.Context(), org); err != nil {
+		if errors.Is(err, service.ErrInvalidBranding) {
+			response.ErrorWithMsg(c, response.CodeInvalidRequest, err.Error())
+			return
+		}
 		response.Error(c, response.CodeServerError)
 		return
 	}
@@ -148,18 +202,166 @@ func (h *OrgHandler) UpdateOrg(c *gin.Context) {
 }
 
 // DeleteOrg 删除组织
-// DELETE /api/v1/orgs/:id
+// DELETE /api/v1/orgs/:id[?dry_run=true][&cascade=true]
 func (h *OrgHandler) DeleteOrg(c *gin.Context) {
 	id := c.Param("id")
+	// ?cascade=true 时一并删除组织下的应用、角色和用户绑定
+	cascade, _ := strconv.ParseBool(c.Query("cascade"))
+
+	if isDryRun(c) {
+		org, err := h.orgService.GetByID(c.Request.Context(), id)
+		if err != nil {
+			response.ErrorWithMsg(c, response.CodeOrgNotFound, "组织不存在")
+			return
+		}
+		if !cascade {
+			if err := h.orgService.CheckDelete(c.Request.Context(), org.ID); err != nil {
+				respondDeleteOrgError(c, err)
+				return
+			}
+		}
+		impact, err := h.deleteOrgImpact(c.Request.Context(), org, cascade)
+		if err != nil {
+			response.Error(c, response.CodeServerError)
+			return
+		}
+		respondDeleteImpact(c, impact)
+		return
+	}
 
-	if err := h.orgService.Delete(c.Request.Context(), id); err != nil {
-		response.Error(c, response.CodeServerError)
+	// 组织级角色在删除事务中一并删除，先取出持有这些角色的用户，删除后清除其角色缓存
+	var roleUserIDs []string
+	if h.rbacService != nil {
+		ids, err := h.rbacService.OrgRoleUserIDs(c.Request.Context(), id)
+		if err != nil {
+			response.Error(c, response.CodeServerError)
+			return
+		}
+		roleUserIDs = ids
+	}
+
+	deleteOrg := h.orgService.Delete
+	if cascade {
+		deleteOrg = h.orgService.DeleteCascade
+	}
+	if err := deleteOrg(c.Request.Context(), id); err != nil {
+		respondDeleteOrgError(c, err)
 		return
 	}
+	if h.rbacService != nil {
+		h.rbacService.InvalidateUserRolesCache(c.Request.Context(), roleUserIDs...)
+	}
 
 	response.Success(c, gin.H{"message": "删除成功"})
 }
 
+// respondDeleteOrgError 返回删除组织失败的响应
+func respondDeleteOrgError(c *gin.Context, err error) {
+	switch err {
+	case repository.ErrOrgNotFound:
+		response.ErrorWithMsg(c, response.CodeOrgNotFound, "组织不存在")
+	case repository.ErrOrgHasApps:
+		response.ErrorWithMsg(c, response.CodeInvalidRequest, "组织下存在应用，请先删除应用或使用 cascade=true 级联删除")
+	default:
+		response.Error(c, response.CodeServerError)
+	}
+}
+
+// deleteOrgImpact 计算删除组织的影响范围
+// 直接删除和级联删除都会清理组织级角色和权限，级联删除还会删除应用和成员绑定
+func (h *OrgHandler) deleteOrgImpact(ctx context.Context, org *model.Organization, cascade bool) (*DeleteImpact, error) {
+	impact := newDeleteImpact("org", org.ID, org.Name)
+	if h.appService != nil {
+		apps, total, err := h.appService.ListByOrgID(ctx, org.ID, impactPage())
+		if err != nil {
+			return nil, err
+		}
+		group := ImpactGroup{Total: total, Items: []ImpactItem{}}
+		for _, app := range apps {
+			group.Items = append(group.Items, ImpactItem{ID: app.ID, Name: app.Name})
+		}
+		impact.Affected["apps"] = group
+	}
+
+	if h.rbacService != nil {
+		roles, err := h.orgRolesImpact(ctx, org.ID)
+		if err != nil {
+			return nil, err
+		}
+		impact.Affected["roles"] = roles
+
+		perms, err := h.orgPermissionsImpact(ctx, org.ID)
+		if err != nil {
+			return nil, err
+		}
+		impact.Affected["permissions"] = perms
+	}
+
+	if cascade && h.userService != nil {
+		bindings, total, err := h.userService.ListOrgMembers(ctx, org.ID, impactPage())
+		if err != nil {
+			return nil, err
+		}
+		group := ImpactGroup{Total: total, Items: []ImpactItem{}}
+		for _, b := range bindings {
+			item := ImpactItem{ID: b.UserID}
+			if b.User != nil {
+				item.Name = b.User.Username
+			}
+			group.Items = append(group.Items, item)
+		}
+		impact.Affected["members"] = group
+	}
+	return impact, nil
+}
+
+// orgRolesImpact 统计组织自有角色，ListRoles 同时返回系统级角色，需逐页过滤后计数
+func (h *OrgHandler) orgRolesImpact(ctx context.Context, orgID string) (ImpactGroup, error) {
+	group := ImpactGroup{Items: []ImpactItem{}}
+	for page := 1; ; page++ {
+		roles, total, err := h.rbacService.ListRoles(ctx, orgID, &repository.Pagination{Page: page, PageSize: impactPreviewLimit})
+		if err != nil {
+			return group, err
+		}
+		for _, role := range roles {
+			if role.OrgID != orgID {
+				continue
+			}
+			group.Total++
+			if len(group.Items) < impactPreviewLimit {
+				group.Items = append(group.Items, ImpactItem{ID: role.ID, Name: role.Name})
+			}
+		}
+		if len(roles) < impactPreviewLimit || int64(page*impactPreviewLimit) >= total {
+			return group, nil
+		}
+	}
+}
+
+// orgPermissionsImpact 统计组织自有权限，系统级权限不受影响
+func (h *OrgHandler) orgPermissionsImpact(ctx context.Context, orgID string) (ImpactGroup, error) {
+	group := ImpactGroup{Items: []ImpactItem{}}
+	filter := &repository.PermissionFilter{OrgID: orgID}
+	for page := 1; ; page++ {
+		perms, total, err := h.rbacService.ListPermissions(ctx, filter, &repository.Pagination{Page: page, PageSize: impactPreviewLimit})
+		if err != nil {
+			return group, err
+		}
+		for _, perm := range perms {
+			if perm.OrgID != orgID {
+				continue
+			}
+			group.Total++
+			if len(group.Items) < impactPreviewLimit {
+				group.Items = append(group.Items, ImpactItem{ID: perm.ID, Name: perm.Code})
+			}
+		}
+		if len(perms) < impactPreviewLimit || int64(page*impactPreviewLimit) >= total {
+			return group, nil
+		}
+	}
+}
+
 // UpdateBrandingRequest 更新品牌配置请求
 type UpdateBrandingRequest struct {
 	LogoURL      string `json:"logo_url"`
@@ -186,13 +388,36 @@ func (h *OrgHandler) UpdateBranding(c *gin.Context) {
 	}
 
 	if err := h.orgService.UpdateBranding(c.Request.Context(), id, branding); err != nil {
-		response.ErrorWithMsg(c, response.CodeServerError, err.Error())
+		switch {
+		case errors.Is(err, service.ErrInvalidBranding):
+			response.ErrorWithMsg(c, response.CodeInvalidRequest, err.Error())
+		case errors.Is(err, repository.ErrOrgNotFound):
+			response.ErrorWithMsg(c, response.CodeOrgNotFound, "组织不存在")
+		default:
+			response.ErrorWithMsg(c, response.CodeServerError, err.Error())
+		}
 		return
 	}
 
 	response.Success(c, gin.H{"message": "品牌配置更新成功"})
 }
 
+// GetPublicBranding 获取组织品牌配置，供登录页等公开页面使用
+// GET /api/v1/public/orgs/:slug/branding
+func (h *OrgHandler) GetPublicBranding(c *gin.Context) {
+	org, err := h.orgService.GetBySlug(c.Request.Context(), c.Param("slug"))
+	if err != nil || !org.IsActive() {
+		response.Error(c, response.CodeOrgNotFound)
+		return
+	}
+
+	response.Success(c, gin.H{
+		"name":     org.Name,
+		"slug":     org.Slug,
+		"branding": org.Branding,
+	})
+}
+
 // orgToResponse 将组织转换为响应格式
 func (h *OrgHandler) orgToResponse(org *model.Organization) gin.H {
 	return gin.H{
@@ -203,7 +428,117 @@ func (h *OrgHandler) orgToResponse(org *model.Organization) gin.H {
 		"description": org.Description,
 		"branding":    org.Branding,
 		"status":      org.Status,
+		"max_apps":    org.MaxApps,
+		"max_users":   org.MaxUsers,
 		"created_at":  org.CreatedAt,
 		"updated_at":  org.UpdatedAt,
 	}
 }
+
+// ListMembers 分页列出组织成员
+// GET /api/v1/orgs/:id/members
+func (h *OrgHandler) ListMembers(c *gin.Context) {
+	org, ok := h.memberOrg(c)
+	if !ok {
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	bindings, total, err := h.userService.ListOrgMembers(c.Request.Context(), org.ID, &repository.Pagination{Page: page, PageSize: pageSize})
+	if err != nil {
+		response.Error(c, response.CodeServerError)
+		return
+	}
+
+	list := make([]gin.H, 0, len(bindings))
+	for _, binding := range bindings {
+		item := gin.H{
+			"user_id":   binding.UserID,
+			"joined_at": binding.CreatedAt,
+		}
+		if binding.User != nil {
+			item["username"] = binding.User.Username
+			item["email"] = binding.User.Email
+			item["display_name"] = binding.User.DisplayName
+			item["status"] = binding.User.Status
+		}
+		list = append(list, item)
+	}
+
+	response.Page(c, list, total, page, pageSize)
+}
+
+// AddMemberRequest 绑定组织成员请求
+type AddMemberRequest struct {
+	UserID string `json:"user_id" binding:"required"`
+}
+
+// AddMember 绑定用户到组织
+// POST /api/v1/orgs/:id/members
+func (h *OrgHandler) AddMember(c *gin.Context) {
+	var req AddMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ErrorWithMsg(c, response.CodeInvalidRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	org, ok := h.memberOrg(c)
+	if !ok {
+		return
+	}
+
+	err := h.userService.BindOrganization(c.Request.Context(), req.UserID, org.ID)
+	switch {
+	case err == nil:
+		response.Success(c, gin.H{"message": "绑定成功"})
+	case errors.Is(err, repository.ErrUserNotFound), errors.Is(err, service.ErrUserNotFound):
+		response.Error(c, response.CodeUserNotFound)
+	case errors.Is(err, repository.ErrBindingExists):
+		response.Error(c, response.CodeBindingExists)
+	case errors.Is(err, service.ErrOrgUserQuotaExceeded):
+		response.ErrorWithMsg(c, response.CodeForbidden, err.Error())
+	default:
+		response.Error(c, response.CodeServerError)
+	}
+}
+
+// RemoveMember 从组织解绑用户
+// DELETE /api/v1/orgs/:id/members/:user_id
+func (h *OrgHandler) RemoveMember(c *gin.Context) {
+	org, ok := h.memberOrg(c)
+	if !ok {
+		return
+	}
+
+	err := h.userService.UnbindOrganization(c.Request.Context(), c.Param("user_id"), org.ID)
+	switch {
+	case err == nil:
+		response.Success(c, gin.H{"message": "解绑成功"})
+	case errors.Is(err, repository.ErrBindingNotFound):
+		response.Error(c, response.CodeBindingNotFound)
+	default:
+		response.Error(c, response.CodeServerError)
+	}
+}
+
+// memberOrg 成员管理的公共前置检查：用户服务已配置且组织存在
+func (h *OrgHandler) memberOrg(c *gin.Context) (*model.Organization, bool) {
+	if h.userService == nil {
+		response.Error(c, response.CodeServerError)
+		return nil, false
+	}
+	org, err := h.orgService.GetByID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		response.ErrorWithMsg(c, response.CodeOrgNotFound, "组织不存在")
+		return nil, false
+	}
+	return org, true
+}
@@ -0,0 +1,520 @@
+// Package handler HTTP 处理器
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pu-ac-cn/uac-backend/internal/model"
+	"github.com/pu-ac-cn/uac-backend/internal/repository"
+	"github.com/pu-ac-cn/uac-backend/internal/service"
+	"github.com/pu-ac-cn/uac-backend/pkg/response"
+)
+
+// OrgExportVersion 组织导出数据包格式版本
+const OrgExportVersion = 1
+
+// exportPageSize 导出时分页读取的批大小
+const exportPageSize = 100
+
+// OrgExportBundle 组织导出数据包，导入时按此结构在新组织中重建
+type OrgExportBundle struct {
+	Version      int                   `json:"version"`
+	ExportedAt   time.Time             `json:"exported_at"`
+	Organization OrgExportOrganization `json:"organization"`
+	Apps         []OrgExportApp        `json:"apps"`
+	Roles        []OrgExportRole       `json:"roles"`
+	Members      []OrgExportMember     `json:"members"`
+}
+
+// OrgExportOrganization 组织基本信息
+type OrgExportOrganization struct {
+	Name        string         `json:"name"`
+	Slug        string         `json:"slug"`
+	Description string         `json:"description"`
+	Branding    model.Branding `json:"branding"`
+	Status      string         `json:"status"`
+	MaxApps     int            `json:"max_apps"`
+	MaxUsers    int            `json:"max_users"`
+}
+
+// OrgExportApp 应用配置，不包含 Client Secret，导入时重新生成凭证
+type OrgExportApp struct {
+	Name                   string   `json:"name"`
+	ClientID               string   `json:"client_id"`
+	Description            string   `json:"description"`
+	OAuthVersion           string   `json:"oauth_version"`
+	Protocol               string   `json:"protocol"`
+	Status                 string   `json:"status"`
+	RedirectURIs           []string `json:"redirect_uris"`
+	PostLogoutRedirectURIs []string `json:"post_logout_redirect_uris"`
+	AllowSubpathRedirect   bool     `json:"allow_subpath_redirect"`
+	AllowedScopes          []string `json:"allowed_scopes"`
+	AllowedGrantTypes      []string `json:"allowed_grant_types"`
+	AccessTokenTTL         int      `json:"access_token_ttl"`
+	RefreshTokenTTL        int      `json:"refresh_token_ttl"`
+	ServiceAccount         bool     `json:"service_account"`
+}
+
+// OrgExportRole 组织角色及其权限代码
+type OrgExportRole struct {
+	Code        string   `json:"code"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Status      string   `json:"status"`
+	Permissions []string `json:"permissions"`
+}
+
+// OrgExportMember 组织成员及其在该组织内的角色代码
+type OrgExportMember struct {
+	UserID   string   `json:"user_id"`
+	Username string   `json:"username"`
+	Email    string   `json:"email"`
+	Roles    []string `json:"roles"`
+}
+
+// SetRBACService 设置 RBAC 服务，启用组织导出导入
+func (h *OrgHandler) SetRBACService(rbacSvc service.RBACService) {
+	h.rbacService = rbacSvc
+}
+
+// ExportOrg 导出组织的应用、角色和成员
+// 数据包按批次读取并流式写出，大型组织不会一次性加载到内存
+// GET /api/v1/orgs/:id/export
+func (h *OrgHandler) ExportOrg(c *gin.Context) {
+	if h.appService == nil || h.userService == nil || h.rbacService == nil {
+		response.Error(c, response.CodeServerError)
+		return
+	}
+	ctx := c.Request.Context()
+	org, err := h.orgService.GetByID(ctx, c.Param("id"))
+	if err != nil {
+		response.ErrorWithMsg(c, response.CodeOrgNotFound, "组织不存在")
+		return
+	}
+
+	c.Header("Content-Type", "application/json; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="org-%s.json"`, org.Slug))
+	c.Status(http.StatusOK)
+
+	w := &exportWriter{c: c, enc: json.NewEncoder(c.Writer)}
+	w.raw(`{"version":`)
+	w.value(OrgExportVersion)
+	w.raw(`,"exported_at":`)
+	w.value(time.Now().UTC())
+	w.raw(`,"organization":`)
+	w.value(OrgExportOrganization{
+		Name:        org.Name,
+		Slug:        org.Slug,
+		Description: org.Description,
+		Branding:    org.Branding,
+		Status:      org.Status,
+		MaxApps:     org.MaxApps,
+		MaxUsers:    org.MaxUsers,
+	})
+	w.array("apps", func() error { return h.exportApps(ctx, org.ID, w) })
+	w.array("roles", func() error { return h.exportRoles(ctx, org.ID, w) })
+	w.array("members", func() error { return h.exportMembers(ctx, org.ID, w) })
+	w.raw(`}`)
+
+	// 响应头已发送，无法再返回错误码；中断输出使数据包不完整，导入时会被拒绝
+	if w.err != nil {
+		_ = c.Error(w.err)
+		c.Abort()
+	}
+}
+
+// exportApps 分批写出组织下的应用
+func (h *OrgHandler) exportApps(ctx context.Context, orgID string, w *exportWriter) error {
+	for page := 1; ; page++ {
+		apps, total, err := h.appService.ListByOrgID(ctx, orgID, &repository.Pagination{Page: page, PageSize: exportPageSize})
+		if err != nil {
+			return err
+		}
+		for _, app := range apps {
+			w.item(OrgExportApp{
+				Name:                   app.Name,
+				ClientID:               app.ClientID,
+				Description:            app.Description,
+				OAuthVersion:           app.OAuthVersion,
+				Protocol:               app.Protocol,
+				Status:                 app.Status,
+				RedirectURIs:           app.RedirectURIs,
+				PostLogoutRedirectURIs: app.PostLogoutRedirectURIs,
+				AllowSubpathRedirect:   app.AllowSubpathRedirect,
+				AllowedScopes:          app.AllowedScopes,
+				AllowedGrantTypes:      app.AllowedGrantTypes,
+				AccessTokenTTL:         app.AccessTokenTTL,
+				RefreshTokenTTL:        app.RefreshTokenTTL,
+				ServiceAccount:         app.ServiceAccount,
+			})
+		}
+		if len(apps) < exportPageSize || int64(page*exportPageSize) >= total || w.err != nil {
+			return nil
+		}
+	}
+}
+
+// exportRoles 分批写出组织自有角色，系统级角色不属于组织，不导出
+func (h *OrgHandler) exportRoles(ctx context.Context, orgID string, w *exportWriter) error {
+	for page := 1; ; page++ {
+		roles, total, err := h.rbacService.ListRoles(ctx, orgID, &repository.Pagination{Page: page, PageSize: exportPageSize})
+		if err != nil {
+			return err
+		}
+		for _, role := range roles {
+			if role.OrgID != orgID {
+				continue
+			}
+			perms, err := h.rbacService.GetRolePermissions(ctx, role.ID)
+			if err != nil {
+				return err
+			}
+			codes := make([]string, 0, len(perms))
+			for _, perm := range perms {
+				codes = append(codes, perm.Code)
+			}
+			w.item(OrgExportRole{
+				Code:        role.Code,
+				Name:        role.Name,
+				Description: role.Description,
+				Status:      role.Status,
+				Permissions: codes,
+			})
+		}
+		if len(roles) < exportPageSize || int64(page*exportPageSize) >= total || w.err != nil {
+			return nil
+		}
+	}
+}
+
+// exportMembers 分批写出组织成员及其在该组织内的角色
+func (h *OrgHandler) exportMembers(ctx context.Context, orgID string, w *exportWriter) error {
+	for page := 1; ; page++ {
+		bindings, total, err := h.userService.ListOrgMembers(ctx, orgID, &repository.Pagination{Page: page, PageSize: exportPageSize})
+		if err != nil {
+			return err
+		}
+		for _, binding := range bindings {
+			roles, err := h.rbacService.GetUserRoles(ctx, binding.UserID)
+			if err != nil {
+				return err
+			}
+			member := OrgExportMember{UserID: binding.UserID, Roles: []string{}}
+			if binding.User != nil {
+				member.Username = binding.User.Username
+				member.Email = binding.User.Email
+			}
+			for _, role := range roles {
+				if role.OrgID == orgID {
+					member.Roles = append(member.Roles, role.Code)
+				}
+			}
+			w.item(member)
+		}
+		if len(bindings) < exportPageSize || int64(page*exportPageSize) >= total || w.err != nil {
+			return nil
+		}
+	}
+}
+
+// exportWriter 以流式方式拼接导出 JSON，出错后后续写入全部跳过
+type exportWriter struct {
+	c     *gin.Context
+	enc   *json.Encoder
+	err   error
+	first bool // 当前数组尚未写入元素
+}
+
+func (w *exportWriter) raw(s string) {
+	if w.err == nil {
+		_, w.err = w.c.Writer.WriteString(s)
+	}
+}
+
+func (w *exportWriter) value(v interface{}) {
+	if w.err == nil {
+		w.err = w.enc.Encode(v)
+	}
+}
+
+// array 写出名为 name 的数组字段，fill 通过 item 写入元素，每批结束后刷新到客户端
+func (w *exportWriter) array(name string, fill func() error) {
+	w.raw(`,"` + name + `":[`)
+	w.first = true
+	if err := fill(); err != nil && w.err == nil {
+		w.err = err
+	}
+	w.raw(`]`)
+}
+
+func (w *exportWriter) item(v interface{}) {
+	if !w.first {
+		w.raw(`,`)
+	}
+	w.first = false
+	w.value(v)
+	if w.err == nil {
+		w.c.Writer.Flush()
+	}
+}
+
+// ImportedApp 导入后重新生成凭证的应用，Client Secret 只在本次响应中返回
+type ImportedApp struct {
+	Name           string `json:"name"`
+	SourceClientID string `json:"source_client_id"`
+	ClientID       string `json:"client_id"`
+	ClientSecret   string `json:"client_secret"`
+}
+
+// ImportedRole 导入后的角色，角色代码全局唯一，冲突时追加组织后缀
+type ImportedRole struct {
+	SourceCode string `json:"source_code"`
+	Code       string `json:"code"`
+	ID         string `json:"id"`
+}
+
+// ImportOrg 根据导出数据包创建新组织并重建应用、角色和成员
+// 查询参数 name 可覆盖新组织名称；成员用户或权限已不存在时记录在 skipped 中
+// 组织和应用配置在写入前按创建时的规则校验，导入中途失败时删除已创建的组织
+// POST /api/v1/orgs/import[?name=]
+func (h *OrgHandler) ImportOrg(c *gin.Context) {
+	if h.appService == nil || h.userService == nil || h.rbacService == nil || h.appValidator == nil {
+		response.Error(c, response.CodeServerError)
+		return
+	}
+	var bundle OrgExportBundle
+	if err := c.ShouldBindJSON(&bundle); err != nil {
+		response.ErrorWithMsg(c, response.CodeInvalidRequest, "参数错误: "+err.Error())
+		return
+	}
+	if bundle.Version != OrgExportVersion {
+		response.ErrorWithMsg(c, response.CodeInvalidRequest, fmt.Sprintf("不支持的导出版本: %d", bundle.Version))
+		return
+	}
+
+	ctx := c.Request.Context()
+	src := bundle.Organization
+	org := &model.Organization{
+		Name:        c.DefaultQuery("name", src.Name),
+		Description: src.Description,
+		Branding:    src.Branding,
+		Status:      src.Status,
+		MaxApps:     src.MaxApps,
+		MaxUsers:    src.MaxUsers,
+	}
+	apps := make([]*model.Application, 0, len(bundle.Apps))
+	for _, src := range bundle.Apps {
+		app := &model.Application{
+			Name:                   src.Name,
+			Description:            src.Description,
+			OAuthVersion:           src.OAuthVersion,
+			Protocol:               src.Protocol,
+			Status:                 src.Status,
+			RedirectURIs:           src.RedirectURIs,
+			PostLogoutRedirectURIs: src.PostLogoutRedirectURIs,
+			AllowSubpathRedirect:   src.AllowSubpathRedirect,
+			AllowedScopes:          src.AllowedScopes,
+			AllowedGrantTypes:      src.AllowedGrantTypes,
+			AccessTokenTTL:         src.AccessTokenTTL,
+			RefreshTokenTTL:        src.RefreshTokenTTL,
+			ServiceAccount:         src.ServiceAccount,
+			CreatedBy:              c.GetString("user_id"),
+		}
+		if app.OAuthVersion == "" {
+			app.OAuthVersion = model.OAuthVersion21
+		}
+		apps = append(apps, app)
+	}
+	if err := h.validateImport(org, apps, len(bundle.Members)); err != nil {
+		response.ErrorWithMsg(c, response.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	if err := h.orgService.Create(ctx, org); err != nil {
+		if errors.Is(err, repository.ErrOrgSlugExists) {
+			response.Error(c, response.CodeOrgSlugExists)
+			return
+		}
+		if errors.Is(err, service.ErrInvalidBranding) || errors.Is(err, service.ErrOrgNameEmpty) {
+			response.ErrorWithMsg(c, response.CodeInvalidRequest, err.Error())
+			return
+		}
+		response.ErrorWithMsg(c, response.CodeServerError, err.Error())
+		return
+	}
+
+	result, err := h.importOrgContents(ctx, c.GetString("user_id"), org, &bundle, apps)
+	if err != nil {
+		h.rollbackImport(ctx, org.ID)
+		response.ErrorWithMsg(c, response.CodeServerError, "导入失败: "+err.Error())
+		return
+	}
+	result["organization"] = h.orgToResponse(org)
+	response.Success(c, result)
+}
+
+// validateImport 在写入前校验组织状态、配额以及各应用的配置，规则与创建组织和应用一致
+func (h *OrgHandler) validateImport(org *model.Organization, apps []*model.Application, members int) error {
+	if !isImportableStatus(org.Status) {
+		return fmt.Errorf("无效的组织状态: %s", org.Status)
+	}
+	if org.MaxApps > 0 && len(apps) > org.MaxApps {
+		return fmt.Errorf("应用数量超过组织上限 %d", org.MaxApps)
+	}
+	if org.MaxUsers > 0 && members > org.MaxUsers {
+		return fmt.Errorf("成员数量超过组织上限 %d", org.MaxUsers)
+	}
+	for _, app := range apps {
+		if strings.TrimSpace(app.Name) == "" {
+			return service.ErrAppNameEmpty
+		}
+		if !isImportableStatus(app.Status) {
+			return fmt.Errorf("应用 %s: 无效的状态: %s", app.Name, app.Status)
+		}
+		if app.AccessTokenTTL < 0 || app.RefreshTokenTTL < 0 {
+			return fmt.Errorf("应用 %s: 令牌有效期不能为负数", app.Name)
+		}
+		if err := h.appValidator.validateAppSettings(app); err != nil {
+			return fmt.Errorf("应用 %s: %w", app.Name, err)
+		}
+	}
+	return nil
+}
+
+// isImportableStatus 导入的组织和应用状态只能为空（使用默认值）、启用或禁用
+func isImportableStatus(status string) bool {
+	return status == "" || status == model.StatusActive || status == model.StatusDisabled
+}
+
+// importOrgContents 在新组织中重建应用、角色和成员，返回导入结果
+// 成员用户或权限已不存在时跳过该条目，其余错误直接返回，由调用方回滚
+func (h *OrgHandler) importOrgContents(ctx context.Context, operatorID string, org *model.Organization, bundle *OrgExportBundle, apps []*model.Application) (gin.H, error) {
+	skipped := []string{}
+	imported := make([]ImportedApp, 0, len(apps))
+	for i, app := range apps {
+		app.OrgID = &org.ID
+		secret, err := h.appService.Create(ctx, app)
+		if err != nil {
+			return nil, fmt.Errorf("应用 %s: %w", app.Name, err)
+		}
+		imported = append(imported, ImportedApp{Name: app.Name, SourceClientID: bundle.Apps[i].ClientID, ClientID: app.ClientID, ClientSecret: secret})
+	}
+
+	permIDs, err := h.permissionIDsByCode(ctx, org.ID)
+	if err != nil {
+		return nil, err
+	}
+	roles := make([]ImportedRole, 0, len(bundle.Roles))
+	roleIDs := make(map[string]string, len(bundle.Roles)) // 原角色代码 -> 新角色 ID
+	for _, src := range bundle.Roles {
+		role, err := h.importRole(ctx, org, src)
+		if err != nil {
+			return nil, fmt.Errorf("角色 %s: %w", src.Code, err)
+		}
+		roleIDs[src.Code] = role.ID
+		roles = append(roles, ImportedRole{SourceCode: src.Code, Code: role.Code, ID: role.ID})
+
+		ids := make([]string, 0, len(src.Permissions))
+		for _, code := range src.Permissions {
+			if id, ok := permIDs[code]; ok {
+				ids = append(ids, id)
+			} else {
+				skipped = append(skipped, fmt.Sprintf("角色 %s 的权限 %s: 权限不存在", src.Code, code))
+			}
+		}
+		if len(ids) > 0 {
+			if err := h.rbacService.SetRolePermissions(ctx, operatorID, role.ID, ids); err != nil {
+				return nil, fmt.Errorf("角色 %s 的权限: %w", src.Code, err)
+			}
+		}
+	}
+
+	members := 0
+	for _, src := range bundle.Members {
+		err := h.userService.BindOrganization(ctx, src.UserID, org.ID)
+		if errors.Is(err, repository.ErrUserNotFound) {
+			skipped = append(skipped, fmt.Sprintf("成员 %s: %v", src.UserID, err))
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("成员 %s: %w", src.UserID, err)
+		}
+		members++
+		for _, code := range src.Roles {
+			roleID, ok := roleIDs[code]
+			if !ok {
+				continue
+			}
+			if err := h.rbacService.AssignRole(ctx, src.UserID, roleID); err != nil {
+				return nil, fmt.Errorf("成员 %s 的角色 %s: %w", src.UserID, code, err)
+			}
+		}
+	}
+
+	return gin.H{
+		"apps":    imported,
+		"roles":   roles,
+		"members": members,
+		"skipped": skipped,
+	}, nil
+}
+
+// rollbackImport 删除导入失败的组织及其下已创建的应用、角色和成员绑定，并清除受影响用户的角色缓存
+// 请求被取消时同样需要清理，因此不继承请求的取消信号
+func (h *OrgHandler) rollbackImport(ctx context.Context, orgID string) {
+	ctx = context.WithoutCancel(ctx)
+	userIDs, _ := h.rbacService.OrgRoleUserIDs(ctx, orgID)
+	if err := h.orgService.DeleteCascade(ctx, orgID); err != nil {
+		return
+	}
+	h.rbacService.InvalidateUserRolesCache(ctx, userIDs...)
+}
+
+// importRole 在新组织中创建角色，原代码已被占用时追加组织 ID 前缀作为后缀
+func (h *OrgHandler) importRole(ctx context.Context, org *model.Organization, src OrgExportRole) (*model.Role, error) {
+	role := &model.Role{
+		OrgID:       org.ID,
+		Code:        src.Code,
+		Name:        src.Name,
+		Description: src.Description,
+		Status:      src.Status,
+	}
+	err := h.rbacService.CreateRole(ctx, role)
+	if errors.Is(err, service.ErrRoleCodeExists) {
+		suffix := org.ID
+		if len(suffix) > 8 {
+			suffix = suffix[:8]
+		}
+		role.Code = src.Code + "_" + suffix
+		err = h.rbacService.CreateRole(ctx, role)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+// permissionIDsByCode 返回组织可用权限（含系统级权限）的代码到 ID 映射
+func (h *OrgHandler) permissionIDsByCode(ctx context.Context, orgID string) (map[string]string, error) {
+	ids := make(map[string]string)
+	filter := &repository.PermissionFilter{OrgID: orgID}
+	for page := 1; ; page++ {
+		perms, total, err := h.rbacService.ListPermissions(ctx, filter, &repository.Pagination{Page: page, PageSize: exportPageSize})
+		if err != nil {
+			return nil, err
+		}
+		for _, perm := range perms {
+			ids[perm.Code] = perm.ID
+		}
+		if len(perms) < exportPageSize || int64(page*exportPageSize) >= total {
+			return ids, nil
+		}
+	}
+}
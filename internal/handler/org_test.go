@@ -0,0 +1,756 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pu-ac-cn/uac-backend/internal/model"
+	"github.com/pu-ac-cn/uac-backend/internal/repository"
+	"github.com/pu-ac-cn/uac-backend/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubOrgService 组织服务桩，记录删除调用
+type stubOrgService struct {
+	service.OrganizationService
+	orgs     map[string]*model.Organization
+	deleted  []string
+	cascaded []string
+	hasApps  map[string]bool // 存在应用的组织，非级联删除时被拒绝
+}
+
+func (s *stubOrgService) GetByID(ctx context.Context, id string) (*model.Organization, error) {
+	if org, ok := s.orgs[id]; ok {
+		return org, nil
+	}
+	return nil, repository.ErrOrgNotFound
+}
+
+func (s *stubOrgService) GetBySlug(ctx context.Context, slug string) (*model.Organization, error) {
+	for _, org := range s.orgs {
+		if org.Slug == slug {
+			return org, nil
+		}
+	}
+	return nil, repository.ErrOrgNotFound
+}
+
+func (s *stubOrgService) CheckDelete(ctx context.Context, id string) error {
+	if s.hasApps[id] {
+		return repository.ErrOrgHasApps
+	}
+	return nil
+}
+
+func (s *stubOrgService) Delete(ctx context.Context, id string) error {
+	if err := s.CheckDelete(ctx, id); err != nil {
+		return err
+	}
+	s.deleted = append(s.deleted, id)
+	delete(s.orgs, id)
+	return nil
+}
+
+func (s *stubOrgService) DeleteCascade(ctx context.Context, id string) error {
+	s.cascaded = append(s.cascaded, id)
+	delete(s.orgs, id)
+	return nil
+}
+
+// stubOrgAppService 按组织查询应用的应用服务桩
+type stubOrgAppService struct {
+	service.ApplicationService
+	apps []*model.Application
+}
+
+func (s *stubOrgAppService) ListByOrgID(ctx context.Context, orgID string, page *repository.Pagination) ([]*model.Application, int64, error) {
+	var result []*model.Application
+	for _, app := range s.apps {
+		if app.OrgID != nil && *app.OrgID == orgID {
+			result = append(result, app)
+		}
+	}
+	return result, int64(len(result)), nil
+}
+
+func setupOrgDeleteTest(t *testing.T) (*gin.Engine, *stubOrgService) {
+	gin.SetMode(gin.TestMode)
+
+	org := &model.Organization{Name: "测试组织"}
+	org.ID = "org-1"
+	orgID := org.ID
+	app1 := &model.Application{Name: "应用一", OrgID: &orgID}
+	app1.ID = "app-1"
+	app2 := &model.Application{Name: "应用二", OrgID: &orgID}
+	app2.ID = "app-2"
+
+	orgSvc := &stubOrgService{orgs: map[string]*model.Organization{org.ID: org}}
+	appSvc := &stubOrgAppService{apps: []*model.Application{app1, app2}}
+	h := NewOrgHandler(orgSvc, appSvc)
+
+	router := gin.New()
+	router.DELETE("/api/v1/orgs/:id", h.DeleteOrg)
+	return router, orgSvc
+}
+
+func TestOrgHandler_DeleteOrg_DryRun(t *testing.T) {
+	router, orgSvc := setupOrgDeleteTest(t)
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/orgs/org-1?dry_run=true", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Data DeleteImpact `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+
+	assert.True(t, resp.Data.DryRun)
+	assert.Equal(t, "org-1", resp.Data.ID)
+	apps := resp.Data.Affected["apps"]
+	assert.Equal(t, int64(2), apps.Total)
+	assert.ElementsMatch(t, []ImpactItem{{ID: "app-1", Name: "应用一"}, {ID: "app-2", Name: "应用二"}}, apps.Items)
+
+	// 试运行不执行删除
+	assert.Empty(t, orgSvc.deleted)
+	assert.Contains(t, orgSvc.orgs, "org-1")
+}
+
+func TestOrgHandler_DeleteOrg_DryRunHasApps(t *testing.T) {
+	router, orgSvc := setupOrgDeleteTest(t)
+	orgSvc.hasApps = map[string]bool{"org-1": true}
+
+	// 非级联试运行与直接删除一样被拒绝
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/orgs/org-1?dry_run=true", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// 级联试运行通过校验
+	req = httptest.NewRequest(http.MethodDelete, "/api/v1/orgs/org-1?dry_run=true&cascade=true", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	assert.Empty(t, orgSvc.deleted)
+	assert.Empty(t, orgSvc.cascaded)
+	assert.Contains(t, orgSvc.orgs, "org-1")
+}
+
+func TestOrgHandler_DeleteOrg_DryRunCascade(t *testing.T) {
+	_, orgSvc, appSvc, userSvc, rbacSvc := setupOrgExportTest(t)
+	orgPerm := &model.Permission{OrgID: "org-1", Resource: "report", Action: model.ActionRead, Code: "report:read"}
+	orgPerm.ID = "perm-report-read"
+	rbacSvc.perms = append(rbacSvc.perms, orgPerm)
+
+	h := NewOrgHandler(orgSvc, appSvc)
+	h.SetUserService(userSvc)
+	h.SetRBACService(rbacSvc)
+	router := gin.New()
+	router.DELETE("/api/v1/orgs/:id", h.DeleteOrg)
+
+	impactOf := func(query string) DeleteImpact {
+		req := httptest.NewRequest(http.MethodDelete, "/api/v1/orgs/org-1?dry_run=true"+query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		var resp struct {
+			Data DeleteImpact `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp.Data
+	}
+
+	impact := impactOf("&cascade=true")
+	assert.Equal(t, []ImpactItem{{ID: "app-portal", Name: "门户"}}, impact.Affected["apps"].Items)
+	// 系统级角色和权限不受影响
+	assert.Equal(t, ImpactGroup{Total: 1, Items: []ImpactItem{{ID: "role-dev", Name: "开发者"}}}, impact.Affected["roles"])
+	assert.Equal(t, ImpactGroup{Total: 1, Items: []ImpactItem{{ID: "perm-report-read", Name: "report:read"}}}, impact.Affected["permissions"])
+	members := impact.Affected["members"]
+	assert.Equal(t, int64(1), members.Total)
+	assert.Equal(t, "user-1", members.Items[0].ID)
+
+	// 直接删除不解除成员绑定
+	impact = impactOf("")
+	assert.Contains(t, impact.Affected, "roles")
+	assert.NotContains(t, impact.Affected, "members")
+
+	assert.Empty(t, orgSvc.deleted)
+	assert.Empty(t, orgSvc.cascaded)
+}
+
+func TestOrgHandler_DeleteOrg_InvalidatesRoleCache(t *testing.T) {
+	_, orgSvc, appSvc, userSvc, rbacSvc := setupOrgExportTest(t)
+	h := NewOrgHandler(orgSvc, appSvc)
+	h.SetUserService(userSvc)
+	h.SetRBACService(rbacSvc)
+	router := gin.New()
+	router.DELETE("/api/v1/orgs/:id", h.DeleteOrg)
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/orgs/org-1?cascade=true", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{"org-1"}, orgSvc.cascaded)
+	// 持有组织级角色的用户的角色缓存被清除
+	assert.Equal(t, []string{"user-1"}, rbacSvc.invalidated)
+}
+
+func TestOrgHandler_DeleteOrg_DryRunNotFound(t *testing.T) {
+	router, orgSvc := setupOrgDeleteTest(t)
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/orgs/missing?dry_run=true", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Empty(t, orgSvc.deleted)
+}
+
+func TestOrgHandler_DeleteOrg(t *testing.T) {
+	router, orgSvc := setupOrgDeleteTest(t)
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/orgs/org-1", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{"org-1"}, orgSvc.deleted)
+}
+
+func TestOrgHandler_DeleteOrg_Cascade(t *testing.T) {
+	router, orgSvc := setupOrgDeleteTest(t)
+	orgSvc.hasApps = map[string]bool{"org-1": true}
+
+	// 存在应用时拒绝直接删除
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/orgs/org-1", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Empty(t, orgSvc.deleted)
+	assert.Contains(t, orgSvc.orgs, "org-1")
+
+	// cascade=true 级联删除
+	req = httptest.NewRequest(http.MethodDelete, "/api/v1/orgs/org-1?cascade=true", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{"org-1"}, orgSvc.cascaded)
+	assert.NotContains(t, orgSvc.orgs, "org-1")
+}
+
+func TestOrgHandler_GetPublicBranding(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	active := &model.Organization{
+		Name:     "研发部",
+		Slug:     "rd",
+		Status:   model.StatusActive,
+		Branding: model.Branding{LogoURL: "https://cdn.example.com/logo.png", PrimaryColor: "#1677ff"},
+	}
+	active.ID = "org-1"
+	disabled := &model.Organization{Name: "已停用", Slug: "old", Status: model.StatusDisabled}
+	disabled.ID = "org-2"
+
+	h := NewOrgHandler(&stubOrgService{orgs: map[string]*model.Organization{
+		active.ID:   active,
+		disabled.ID: disabled,
+	}})
+	router := gin.New()
+	router.GET("/api/v1/public/orgs/:slug/branding", h.GetPublicBranding)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/public/orgs/rd/branding", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "研发部", resp.Data["name"])
+	assert.NotContains(t, resp.Data, "id")
+	branding := resp.Data["branding"].(map[string]interface{})
+	assert.Equal(t, "#1677ff", branding["primary_color"])
+
+	// 已停用或不存在的组织不公开品牌配置
+	for _, slug := range []string{"old", "missing"} {
+		req = httptest.NewRequest(http.MethodGet, "/api/v1/public/orgs/"+slug+"/branding", nil)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Contains(t, w.Body.String(), "组织不存在", slug)
+	}
+}
+
+// stubMemberUserService 内存中维护组织成员的用户服务桩
+type stubMemberUserService struct {
+	service.UserService
+	users   map[string]*model.User
+	members map[string]bool // 键为 "userID/orgID"
+}
+
+func (s *stubMemberUserService) ListOrgMembers(ctx context.Context, orgID string, page *repository.Pagination) ([]*model.UserOrgBinding, int64, error) {
+	var bindings []*model.UserOrgBinding
+	for id, user := range s.users {
+		if s.members[id+"/"+orgID] {
+			bindings = append(bindings, &model.UserOrgBinding{UserID: id, OrgID: orgID, User: user})
+		}
+	}
+	return bindings, int64(len(bindings)), nil
+}
+
+func (s *stubMemberUserService) BindOrganization(ctx context.Context, userID, orgID string) error {
+	if _, ok := s.users[userID]; !ok {
+		return repository.ErrUserNotFound
+	}
+	if s.members[userID+"/"+orgID] {
+		return repository.ErrBindingExists
+	}
+	s.members[userID+"/"+orgID] = true
+	return nil
+}
+
+func (s *stubMemberUserService) UnbindOrganization(ctx context.Context, userID, orgID string) error {
+	if !s.members[userID+"/"+orgID] {
+		return repository.ErrBindingNotFound
+	}
+	delete(s.members, userID+"/"+orgID)
+	return nil
+}
+
+func setupOrgMemberTest(t *testing.T) (*gin.Engine, *stubMemberUserService) {
+	gin.SetMode(gin.TestMode)
+
+	org := &model.Organization{Name: "研发部"}
+	org.ID = "org-1"
+	alice := &model.User{Username: "alice", Email: "alice@example.com"}
+	alice.ID = "user-1"
+	bob := &model.User{Username: "bob", Email: "bob@example.com"}
+	bob.ID = "user-2"
+
+	userSvc := &stubMemberUserService{
+		users:   map[string]*model.User{alice.ID: alice, bob.ID: bob},
+		members: map[string]bool{"user-1/org-1": true},
+	}
+	h := NewOrgHandler(&stubOrgService{orgs: map[string]*model.Organization{org.ID: org}})
+	h.SetUserService(userSvc)
+
+	router := gin.New()
+	router.GET("/api/v1/orgs/:id/members", h.ListMembers)
+	router.POST("/api/v1/orgs/:id/members", h.AddMember)
+	router.DELETE("/api/v1/orgs/:id/members/:user_id", h.RemoveMember)
+	return router, userSvc
+}
+
+func addOrgMember(router *gin.Engine, orgID, userID string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/orgs/"+orgID+"/members", strings.NewReader(`{"user_id":"`+userID+`"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestOrgHandler_ListMembers(t *testing.T) {
+	router, _ := setupOrgMemberTest(t)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/orgs/org-1/members", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		Data struct {
+			List  []map[string]interface{} `json:"list"`
+			Total int64                    `json:"total"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, int64(1), resp.Data.Total)
+	require.Len(t, resp.Data.List, 1)
+	assert.Equal(t, "user-1", resp.Data.List[0]["user_id"])
+	assert.Equal(t, "alice", resp.Data.List[0]["username"])
+
+	// 组织不存在
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/orgs/missing/members", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestOrgHandler_AddMember(t *testing.T) {
+	router, userSvc := setupOrgMemberTest(t)
+
+	w := addOrgMember(router, "org-1", "user-2")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, userSvc.members["user-2/org-1"])
+
+	// 已是成员返回冲突
+	w = addOrgMember(router, "org-1", "user-1")
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	// 用户不存在
+	w = addOrgMember(router, "org-1", "missing")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestOrgHandler_RemoveMember(t *testing.T) {
+	router, userSvc := setupOrgMemberTest(t)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/v1/orgs/org-1/members/user-1", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.False(t, userSvc.members["user-1/org-1"])
+
+	// 非成员
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/v1/orgs/org-1/members/user-2", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func (s *stubOrgService) Create(ctx context.Context, org *model.Organization) error {
+	org.ID = fmt.Sprintf("org-%d", len(s.orgs)+1)
+	org.Slug = org.ID
+	s.orgs[org.ID] = org
+	return nil
+}
+
+func (s *stubOrgAppService) Create(ctx context.Context, app *model.Application) (string, error) {
+	app.ID = fmt.Sprintf("app-%d", len(s.apps)+1)
+	app.ClientID = "client-" + app.ID
+	s.apps = append(s.apps, app)
+	return "secret-" + app.ID, nil
+}
+
+// stubExportRBACService 内存中维护角色、权限和用户角色的 RBAC 服务桩
+type stubExportRBACService struct {
+	service.RBACService
+	roles     []*model.Role
+	perms     []*model.Permission
+	rolePerms map[string][]string // 角色 ID -> 权限 ID
+	userRoles map[string][]string // 用户 ID -> 角色 ID
+	// invalidated 被清除角色缓存的用户
+	invalidated []string
+	// assignErr 非空时分配角色返回该错误
+	assignErr error
+}
+
+func (s *stubExportRBACService) ListRoles(ctx context.Context, orgID string, page *repository.Pagination) ([]*model.Role, int64, error) {
+	var roles []*model.Role
+	for _, role := range s.roles {
+		if role.OrgID == orgID || role.OrgID == "" {
+			roles = append(roles, role)
+		}
+	}
+	return roles, int64(len(roles)), nil
+}
+
+func (s *stubExportRBACService) CreateRole(ctx context.Context, role *model.Role) error {
+	for _, r := range s.roles {
+		if r.Code == role.Code {
+			return service.ErrRoleCodeExists
+		}
+	}
+	role.ID = fmt.Sprintf("role-%d", len(s.roles)+1)
+	s.roles = append(s.roles, role)
+	return nil
+}
+
+func (s *stubExportRBACService) GetRolePermissions(ctx context.Context, roleID string) ([]model.Permission, error) {
+	var perms []model.Permission
+	for _, id := range s.rolePerms[roleID] {
+		for _, perm := range s.perms {
+			if perm.ID == id {
+				perms = append(perms, *perm)
+			}
+		}
+	}
+	return perms, nil
+}
+
+func (s *stubExportRBACService) SetRolePermissions(ctx context.Context, operatorID, roleID string, permissionIDs []string) error {
+	s.rolePerms[roleID] = permissionIDs
+	return nil
+}
+
+func (s *stubExportRBACService) ListPermissions(ctx context.Context, filter *repository.PermissionFilter, page *repository.Pagination) ([]*model.Permission, int64, error) {
+	return s.perms, int64(len(s.perms)), nil
+}
+
+func (s *stubExportRBACService) GetUserRoles(ctx context.Context, userID string) ([]*model.Role, error) {
+	var roles []*model.Role
+	for _, id := range s.userRoles[userID] {
+		for _, role := range s.roles {
+			if role.ID == id {
+				roles = append(roles, role)
+			}
+		}
+	}
+	return roles, nil
+}
+
+func (s *stubExportRBACService) OrgRoleUserIDs(ctx context.Context, orgID string) ([]string, error) {
+	var ids []string
+	for userID, roleIDs := range s.userRoles {
+		for _, roleID := range roleIDs {
+			if role := s.role(roleID); role != nil && role.OrgID == orgID {
+				ids = append(ids, userID)
+				break
+			}
+		}
+	}
+	return ids, nil
+}
+
+func (s *stubExportRBACService) InvalidateUserRolesCache(ctx context.Context, userIDs ...string) {
+	s.invalidated = append(s.invalidated, userIDs...)
+}
+
+func (s *stubExportRBACService) role(id string) *model.Role {
+	for _, role := range s.roles {
+		if role.ID == id {
+			return role
+		}
+	}
+	return nil
+}
+
+func (s *stubExportRBACService) AssignRole(ctx context.Context, userID, roleID string) error {
+	if s.assignErr != nil {
+		return s.assignErr
+	}
+	s.userRoles[userID] = append(s.userRoles[userID], roleID)
+	return nil
+}
+
+func setupOrgExportTest(t *testing.T) (*gin.Engine, *stubOrgService, *stubOrgAppService, *stubMemberUserService, *stubExportRBACService) {
+	gin.SetMode(gin.TestMode)
+
+	org := &model.Organization{Name: "研发部", Slug: "rd", MaxApps: 5}
+	org.ID = "org-1"
+	orgID := org.ID
+	app := &model.Application{Name: "门户", ClientID: "client-portal", OrgID: &orgID, RedirectURIs: model.StringSlice{"https://portal.example.com/cb"}}
+	app.ID = "app-portal"
+	alice := &model.User{Username: "alice", Email: "alice@example.com"}
+	alice.ID = "user-1"
+
+	systemRole := &model.Role{Code: model.RoleUser, Name: "普通用户", IsSystem: true}
+	systemRole.ID = "role-user"
+	devRole := &model.Role{OrgID: orgID, Code: "rd_dev", Name: "开发者"}
+	devRole.ID = "role-dev"
+	perm := &model.Permission{Resource: model.ResourceApp, Action: model.ActionRead, Code: "app:read"}
+	perm.ID = "perm-app-read"
+
+	orgSvc := &stubOrgService{orgs: map[string]*model.Organization{org.ID: org}}
+	appSvc := &stubOrgAppService{apps: []*model.Application{app}}
+	userSvc := &stubMemberUserService{
+		users:   map[string]*model.User{alice.ID: alice},
+		members: map[string]bool{"user-1/org-1": true},
+	}
+	rbacSvc := &stubExportRBACService{
+		roles:     []*model.Role{systemRole, devRole},
+		perms:     []*model.Permission{perm},
+		rolePerms: map[string][]string{devRole.ID: {perm.ID}},
+		userRoles: map[string][]string{alice.ID: {systemRole.ID, devRole.ID}},
+	}
+	h := NewOrgHandler(orgSvc, appSvc)
+	h.SetUserService(userSvc)
+	h.SetRBACService(rbacSvc)
+	h.SetAppValidator(NewAppHandler(appSvc))
+
+	router := gin.New()
+	router.GET("/api/v1/orgs/:id/export", h.ExportOrg)
+	router.POST("/api/v1/orgs/import", h.ImportOrg)
+	return router, orgSvc, appSvc, userSvc, rbacSvc
+}
+
+func TestOrgHandler_ExportOrg(t *testing.T) {
+	router, _, _, _, _ := setupOrgExportTest(t)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/orgs/org-1/export", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "org-rd.json")
+
+	var bundle OrgExportBundle
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &bundle))
+	assert.Equal(t, OrgExportVersion, bundle.Version)
+	assert.Equal(t, "研发部", bundle.Organization.Name)
+
+	require.Len(t, bundle.Apps, 1)
+	assert.Equal(t, "门户", bundle.Apps[0].Name)
+	assert.Equal(t, []string{"https://portal.example.com/cb"}, bundle.Apps[0].RedirectURIs)
+
+	// 系统级角色不属于组织，不导出
+	require.Len(t, bundle.Roles, 1)
+	assert.Equal(t, "rd_dev", bundle.Roles[0].Code)
+	assert.Equal(t, []string{"app:read"}, bundle.Roles[0].Permissions)
+
+	require.Len(t, bundle.Members, 1)
+	assert.Equal(t, "user-1", bundle.Members[0].UserID)
+	assert.Equal(t, []string{"rd_dev"}, bundle.Members[0].Roles)
+
+	// 组织不存在
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/orgs/missing/export", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestOrgHandler_ImportOrg_RoundTrip(t *testing.T) {
+	router, orgSvc, appSvc, userSvc, rbacSvc := setupOrgExportTest(t)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/orgs/org-1/export", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/orgs/import?name=研发部副本", strings.NewReader(w.Body.String()))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var resp struct {
+		Data struct {
+			Organization map[string]interface{} `json:"organization"`
+			Apps         []ImportedApp          `json:"apps"`
+			Roles        []ImportedRole         `json:"roles"`
+			Members      int                    `json:"members"`
+			Skipped      []string               `json:"skipped"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Empty(t, resp.Data.Skipped)
+
+	newOrgID := resp.Data.Organization["id"].(string)
+	require.Contains(t, orgSvc.orgs, newOrgID)
+	assert.Equal(t, "研发部副本", orgSvc.orgs[newOrgID].Name)
+	assert.Equal(t, 5, orgSvc.orgs[newOrgID].MaxApps)
+
+	// 应用重建在新组织下，并重新生成凭证
+	require.Len(t, resp.Data.Apps, 1)
+	assert.Equal(t, "client-portal", resp.Data.Apps[0].SourceClientID)
+	assert.NotEqual(t, "client-portal", resp.Data.Apps[0].ClientID)
+	assert.NotEmpty(t, resp.Data.Apps[0].ClientSecret)
+	imported, _, _ := appSvc.ListByOrgID(context.Background(), newOrgID, nil)
+	require.Len(t, imported, 1)
+	assert.Equal(t, model.StringSlice{"https://portal.example.com/cb"}, imported[0].RedirectURIs)
+
+	// 角色代码冲突时追加组织后缀，权限保持一致
+	require.Len(t, resp.Data.Roles, 1)
+	role := resp.Data.Roles[0]
+	assert.Equal(t, "rd_dev", role.SourceCode)
+	assert.Equal(t, "rd_dev_"+newOrgID, role.Code)
+	assert.Equal(t, []string{"perm-app-read"}, rbacSvc.rolePerms[role.ID])
+
+	// 成员绑定到新组织并恢复组织角色
+	assert.Equal(t, 1, resp.Data.Members)
+	assert.True(t, userSvc.members["user-1/"+newOrgID])
+	assert.Contains(t, rbacSvc.userRoles["user-1"], role.ID)
+}
+
+func TestOrgHandler_ImportOrg_UnsupportedVersion(t *testing.T) {
+	router, orgSvc, _, _, _ := setupOrgExportTest(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/orgs/import", strings.NewReader(`{"version":99,"organization":{"name":"x"}}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Len(t, orgSvc.orgs, 1)
+}
+
+func TestOrgHandler_ImportOrg_Validation(t *testing.T) {
+	router, orgSvc, appSvc, _, _ := setupOrgExportTest(t)
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"无效的组织状态", `{"version":1,"organization":{"name":"x","status":"deleted"}}`},
+		{"无效的应用状态", `{"version":1,"organization":{"name":"x"},"apps":[{"name":"a","status":"unknown"}]}`},
+		{"应用回调地址无效", `{"version":1,"organization":{"name":"x"},"apps":[{"name":"a","redirect_uris":["/cb"]}]}`},
+		{"应用 scope 未知", `{"version":1,"organization":{"name":"x"},"apps":[{"name":"a","allowed_scopes":["admin"]}]}`},
+		{"应用名称为空", `{"version":1,"organization":{"name":"x"},"apps":[{"name":" "}]}`},
+		{"应用数量超过上限", `{"version":1,"organization":{"name":"x","max_apps":1},"apps":[{"name":"a"},{"name":"b"}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/orgs/import", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+		})
+	}
+
+	// 校验失败时不创建任何数据
+	assert.Len(t, orgSvc.orgs, 1)
+	assert.Len(t, appSvc.apps, 1)
+}
+
+func TestOrgHandler_ImportOrg_RollbackOnFailure(t *testing.T) {
+	router, orgSvc, _, _, rbacSvc := setupOrgExportTest(t)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/orgs/org-1/export", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// 恢复成员角色时失败，已创建的组织被删除
+	rbacSvc.assignErr = errors.New("数据库不可用")
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/orgs/import", strings.NewReader(w.Body.String()))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	require.Len(t, orgSvc.cascaded, 1)
+	assert.NotContains(t, orgSvc.orgs, orgSvc.cascaded[0])
+	assert.Len(t, orgSvc.orgs, 1)
+}
+
+func (s *stubOrgService) UpdateBranding(ctx context.Context, id string, branding *model.Branding) error {
+	if branding.PrimaryColor != "" && !strings.HasPrefix(branding.PrimaryColor, "#") {
+		return fmt.Errorf("%w: 主题色必须为 #RRGGBB 格式", service.ErrInvalidBranding)
+	}
+	org, ok := s.orgs[id]
+	if !ok {
+		return repository.ErrOrgNotFound
+	}
+	org.Branding = *branding
+	return nil
+}
+
+func TestOrgHandler_UpdateBranding_Invalid(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	org := &model.Organization{Name: "研发部"}
+	org.ID = "org-1"
+	h := NewOrgHandler(&stubOrgService{orgs: map[string]*model.Organization{org.ID: org}})
+	router := gin.New()
+	router.PUT("/api/v1/orgs/:id/branding", h.UpdateBranding)
+
+	put := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := put("/api/v1/orgs/org-1/branding", `{"primary_color":"red"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "#RRGGBB")
+
+	w = put("/api/v1/orgs/missing/branding", `{"primary_color":"#1677ff"}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = put("/api/v1/orgs/org-1/branding", `{"primary_color":"#1677ff"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "#1677ff", org.Branding.PrimaryColor)
+}
@@ -2,6 +2,9 @@
 package handler
 
 import (
+	"strconv"
+	"time"
+
 	"github.com/gin-gonic/gin"
 	"github.com/pu-ac-cn/uac-backend/internal/model"
 	"github.com/pu-ac-cn/uac-backend/internal/repository"
@@ -12,6 +15,7 @@ import (
 // RBACHandler RBAC 处理器
 type RBACHandler struct {
 	rbacService service.RBACService
+	auditor
 }
 
 // NewRBACHandler 创建 RBAC 处理器
@@ -69,7 +73,7 @@ func (h *RBACHandler) CreateRole(c *gin.Context) {
 
 	// 添加权限
 	if len(req.Permissions) > 0 {
-		_ = h.rbacService.AddPermissionsToRole(c.Request.Context(), role.ID, req.Permissions)
+		_ = h.rbacService.AddPermissionsToRole(c.Request.Context(), c.GetString("user_id"), role.ID, req.Permissions)
 	}
 
 	response.Success(c, role)
@@ -126,9 +130,34 @@ func (h *RBACHandler) UpdateRole(c *gin.Context) {
 }
 
 // DeleteRole 删除角色
-// DELETE /api/v1/roles/:id
+// DELETE /api/v1/roles/:id[?dry_run=true]
 func (h *RBACHandler) DeleteRole(c *gin.Context) {
 	id := c.Param("id")
+
+	if isDryRun(c) {
+		role, err := h.rbacService.GetRole(c.Request.Context(), id)
+		if err != nil {
+			response.Error(c, response.CodeRoleNotFound)
+			return
+		}
+		if role.IsSystem {
+			response.ErrorWithMsg(c, response.CodeForbidden, "系统角色不能删除")
+			return
+		}
+		users, total, err := h.rbacService.GetRoleUsers(c.Request.Context(), role.ID, impactPage())
+		if err != nil {
+			response.Error(c, response.CodeServerError)
+			return
+		}
+		impact := newDeleteImpact("role", role.ID, role.Name)
+		group := ImpactGroup{Total: total, Items: []ImpactItem{}}
+		for _, u := range users {
+			group.Items = append(group.Items, ImpactItem{ID: u.ID, Name: u.Username})
+		}
+		impact.Affected["user_roles"] = group
+		respondDeleteImpact(c, impact)
+		return
+	}
 	if err := h.rbacService.DeleteRole(c.Request.Context(), id); err != nil {
 		if err == service.ErrRoleNotFound {
 			response.Error(c, response.CodeRoleNotFound)
@@ -159,24 +188,32 @@ func (h *RBACHandler) ListRoles(c *gin.Context) {
 		return
 	}
 
-	response.Success(c, gin.H{
-		"list":      roles,
-		"total":     total,
-		"page":      page.Page,
-		"page_size": page.PageSize,
-	})
+	response.Page(c, roles, total, page.Page, page.PageSize)
 }
 
-// ListPermissions 获取权限列表
-// GET /api/v1/permissions
+// ListPermissions 分页获取权限列表
+// GET /api/v1/permissions?resource=user&action=read&page=1&page_size=20
 func (h *RBACHandler) ListPermissions(c *gin.Context) {
-	orgID := c.Query("org_id")
-	permissions, err := h.rbacService.ListPermissions(c.Request.Context(), orgID)
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	filter := &repository.PermissionFilter{
+		OrgID:    c.Query("org_id"),
+		Resource: c.Query("resource"),
+		Action:   c.Query("action"),
+	}
+	pagination := &repository.Pagination{
+		Page:     page,
+		PageSize: pageSize,
+	}
+
+	permissions, total, err := h.rbacService.ListPermissions(c.Request.Context(), filter, pagination)
 	if err != nil {
 		response.Error(c, response.CodeServerError)
 		return
 	}
-	response.Success(c, permissions)
+
+	response.Page(c, permissions, total, pagination.Page, pagination.PageSize)
 }
 
 // GetPermission 获取权限详情
@@ -260,24 +297,59 @@ func (h *RBACHandler) GetRolePermissions(c *gin.Context) {
 	response.Success(c, permissions)
 }
 
+// ListAssignablePermissions 获取可分配给角色的权限
+// GET /api/v1/roles/:id/assignable-permissions
+func (h *RBACHandler) ListAssignablePermissions(c *gin.Context) {
+	permissions, err := h.rbacService.ListAssignablePermissions(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if err == service.ErrRoleNotFound {
+			response.Error(c, response.CodeRoleNotFound)
+			return
+		}
+		response.Error(c, response.CodeServerError)
+		return
+	}
+	response.Success(c, permissions)
+}
+
 // AssignRole 分配角色给用户
 // POST /api/v1/users/:user_id/roles
 func (h *RBACHandler) AssignRole(c *gin.Context) {
 	userID := c.Param("user_id")
 	var req struct {
-		RoleID string `json:"role_id" binding:"required"`
+		RoleID    string     `json:"role_id" binding:"required"`
+		ExpiresAt *time.Time `json:"expires_at"` // 可选，到期后角色自动失效
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		response.ErrorWithMsg(c, response.CodeInvalidRequest, "参数错误: "+err.Error())
 		return
 	}
 
-	if err := h.rbacService.AssignRole(c.Request.Context(), userID, req.RoleID); err != nil {
-		if err == service.ErrRoleNotFound {
+	detail := model.JSONMap{"role_id": req.RoleID}
+	var err error
+	if req.ExpiresAt != nil {
+		detail["expires_at"] = req.ExpiresAt
+		err = h.rbacService.AssignRoleWithExpiry(c.Request.Context(), userID, req.RoleID, *req.ExpiresAt)
+	} else {
+		err = h.rbacService.AssignRole(c.Request.Context(), userID, req.RoleID)
+	}
+	h.audit(c, &model.AuditLog{
+		Action:     model.AuditActionAssignRole,
+		Resource:   "user",
+		ResourceID: userID,
+		Detail:     detail,
+	}, err)
+	if err != nil {
+		switch err {
+		case service.ErrRoleNotFound:
 			response.Error(c, response.CodeRoleNotFound)
-			return
+		case service.ErrRoleAlreadyAssigned, service.ErrRoleExpiryInvalid:
+			response.ErrorWithMsg(c, response.CodeInvalidRequest, err.Error())
+		case service.ErrRoleOrgMismatch:
+			response.ErrorWithMsg(c, response.CodeForbidden, err.Error())
+		default:
+			response.Error(c, response.CodeServerError)
 		}
-		response.Error(c, response.CodeServerError)
 		return
 	}
 
@@ -290,7 +362,14 @@ func (h *RBACHandler) RevokeRole(c *gin.Context) {
 	userID := c.Param("user_id")
 	roleID := c.Param("role_id")
 
-	if err := h.rbacService.RevokeRole(c.Request.Context(), userID, roleID); err != nil {
+	err := h.rbacService.RevokeRole(c.Request.Context(), userID, roleID)
+	h.audit(c, &model.AuditLog{
+		Action:     model.AuditActionRevokeRole,
+		Resource:   "user",
+		ResourceID: userID,
+		Detail:     model.JSONMap{"role_id": roleID},
+	}, err)
+	if err != nil {
 		response.Error(c, response.CodeServerError)
 		return
 	}
@@ -363,14 +442,79 @@ func (h *RBACHandler) AddPermissionsToRole(c *gin.Context) {
 		return
 	}
 
-	if err := h.rbacService.AddPermissionsToRole(c.Request.Context(), roleID, req.PermissionIDs); err != nil {
-		response.Error(c, response.CodeServerError)
+	if err := h.rbacService.AddPermissionsToRole(c.Request.Context(), c.GetString("user_id"), roleID, req.PermissionIDs); err != nil {
+		switch err {
+		case service.ErrRoleNotFound:
+			response.Error(c, response.CodeRoleNotFound)
+		case service.ErrSystemRole:
+			response.ErrorWithMsg(c, response.CodeForbidden, "系统角色的权限不能修改")
+		default:
+			response.Error(c, response.CodeServerError)
+		}
 		return
 	}
 
 	response.Success(c, gin.H{"message": "权限添加成功"})
 }
 
+// SetRolePermissions 整体替换角色权限
+// PUT /api/v1/roles/:id/permissions
+func (h *RBACHandler) SetRolePermissions(c *gin.Context) {
+	roleID := c.Param("id")
+	var req struct {
+		PermissionIDs []string `json:"permission_ids" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ErrorWithMsg(c, response.CodeInvalidRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	if err := h.rbacService.SetRolePermissions(c.Request.Context(), c.GetString("user_id"), roleID, req.PermissionIDs); err != nil {
+		switch err {
+		case service.ErrRoleNotFound:
+			response.Error(c, response.CodeRoleNotFound)
+		case service.ErrPermissionNotFound:
+			response.Error(c, response.CodePermissionNotFound)
+		case service.ErrSystemRole:
+			response.ErrorWithMsg(c, response.CodeForbidden, "系统角色的权限不能修改")
+		default:
+			response.Error(c, response.CodeServerError)
+		}
+		return
+	}
+
+	response.Success(c, gin.H{"message": "权限设置成功"})
+}
+
+// AddPermissionToRoles 将权限批量添加到多个角色
+// POST /api/v1/permissions/:id/roles
+func (h *RBACHandler) AddPermissionToRoles(c *gin.Context) {
+	var req struct {
+		RoleIDs []string `json:"role_ids" binding:"required,min=1"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ErrorWithMsg(c, response.CodeInvalidRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	added, err := h.rbacService.AddPermissionToRoles(c.Request.Context(), c.Param("id"), req.RoleIDs)
+	if err != nil {
+		switch err {
+		case service.ErrPermissionNotFound:
+			response.Error(c, response.CodePermissionNotFound)
+		case service.ErrRoleNotFound:
+			response.Error(c, response.CodeRoleNotFound)
+		case service.ErrSystemRole:
+			response.ErrorWithMsg(c, response.CodeForbidden, "系统角色的权限不能修改")
+		default:
+			response.Error(c, response.CodeServerError)
+		}
+		return
+	}
+
+	response.Success(c, gin.H{"added_role_ids": added})
+}
+
 // RemovePermissionsFromRole 从角色移除权限
 // DELETE /api/v1/roles/:id/permissions
 func (h *RBACHandler) RemovePermissionsFromRole(c *gin.Context) {
@@ -383,8 +527,15 @@ func (h *RBACHandler) RemovePermissionsFromRole(c *gin.Context) {
 		return
 	}
 
-	if err := h.rbacService.RemovePermissionsFromRole(c.Request.Context(), roleID, req.PermissionIDs); err != nil {
-		response.Error(c, response.CodeServerError)
+	if err := h.rbacService.RemovePermissionsFromRole(c.Request.Context(), c.GetString("user_id"), roleID, req.PermissionIDs); err != nil {
+		switch err {
+		case service.ErrRoleNotFound:
+			response.Error(c, response.CodeRoleNotFound)
+		case service.ErrSystemRole:
+			response.ErrorWithMsg(c, response.CodeForbidden, "系统角色的权限不能修改")
+		default:
+			response.Error(c, response.CodeServerError)
+		}
 		return
 	}
 
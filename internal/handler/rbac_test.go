@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pu-ac-cn/uac-backend/internal/model"
+	"github.com/pu-ac-cn/uac-backend/internal/repository"
+	"github.com/pu-ac-cn/uac-backend/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubPermissionRBACService 记录权限列表查询条件的 RBAC 服务桩
+type stubPermissionRBACService struct {
+	service.RBACService
+	filter *repository.PermissionFilter
+	page   *repository.Pagination
+}
+
+func (s *stubPermissionRBACService) ListPermissions(ctx context.Context, filter *repository.PermissionFilter, page *repository.Pagination) ([]*model.Permission, int64, error) {
+	s.filter = filter
+	s.page = page
+	return []*model.Permission{{Code: "user:read", Resource: "user", Action: "read"}}, 42, nil
+}
+
+func TestRBACHandler_ListPermissions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	rbacSvc := &stubPermissionRBACService{}
+	router := gin.New()
+	router.GET("/api/v1/permissions", NewRBACHandler(rbacSvc).ListPermissions)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/permissions?resource=user&action=read&page=2&page_size=10", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		Data struct {
+			List     []model.Permission `json:"list"`
+			Total    int64              `json:"total"`
+			Page     int                `json:"page"`
+			PageSize int                `json:"page_size"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Len(t, resp.Data.List, 1)
+	assert.Equal(t, int64(42), resp.Data.Total)
+	assert.Equal(t, 2, resp.Data.Page)
+	assert.Equal(t, 10, resp.Data.PageSize)
+	assert.Equal(t, &repository.PermissionFilter{Resource: "user", Action: "read"}, rbacSvc.filter)
+
+	// 未提供分页参数时默认第一页
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/permissions", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, &repository.Pagination{Page: 1, PageSize: 20}, rbacSvc.page)
+}
+
+// stubSetPermissionsRBACService 保存角色权限集合的 RBAC 服务桩
+type stubSetPermissionsRBACService struct {
+	service.RBACService
+	permissions map[string][]string
+}
+
+func (s *stubSetPermissionsRBACService) SetRolePermissions(ctx context.Context, operatorID, roleID string, permissionIDs []string) error {
+	if roleID == "role-system" {
+		return service.ErrSystemRole
+	}
+	s.permissions[roleID] = permissionIDs
+	return nil
+}
+
+func TestRBACHandler_SetRolePermissions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	rbacSvc := &stubSetPermissionsRBACService{permissions: map[string][]string{"role-1": {"perm-old"}}}
+	router := gin.New()
+	router.PUT("/api/v1/roles/:id/permissions", NewRBACHandler(rbacSvc).SetRolePermissions)
+
+	put := func(roleID, body string) int {
+		req := httptest.NewRequest(http.MethodPut, "/api/v1/roles/"+roleID+"/permissions", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, put("role-1", `{"permission_ids":["perm-1","perm-2"]}`))
+	assert.Equal(t, []string{"perm-1", "perm-2"}, rbacSvc.permissions["role-1"])
+
+	assert.Equal(t, http.StatusForbidden, put("role-system", `{"permission_ids":["perm-1"]}`))
+	assert.Equal(t, http.StatusBadRequest, put("role-1", `{}`))
+}
+
+func TestRBACHandler_AssignRole_Errors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/v1/user-roles/:user_id", NewRBACHandler(&stubAssignRBACService{}).AssignRole)
+
+	assign := func(roleID string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/user-roles/user-2", strings.NewReader(`{"role_id":"`+roleID+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, assign("role-1"))
+	assert.Equal(t, http.StatusNotFound, assign("missing"))
+	assert.Equal(t, http.StatusBadRequest, assign("assigned"))
+	assert.Equal(t, http.StatusForbidden, assign("other-org"))
+}
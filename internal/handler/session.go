@@ -0,0 +1,86 @@
+// Package handler HTTP 处理器
+package handler
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pu-ac-cn/uac-backend/internal/service"
+	"github.com/pu-ac-cn/uac-backend/pkg/response"
+)
+
+// SessionHandler 会话管理处理器
+type SessionHandler struct {
+	sessionService service.SessionService
+}
+
+// NewSessionHandler 创建会话管理处理器
+func NewSessionHandler(sessionSvc service.SessionService) *SessionHandler {
+	return &SessionHandler{sessionService: sessionSvc}
+}
+
+// ListMySessions 获取当前用户的活跃会话
+// GET /api/v1/auth/sessions
+func (h *SessionHandler) ListMySessions(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		response.Error(c, response.CodeInvalidToken)
+		return
+	}
+
+	sessions, err := h.sessionService.ListByUserID(c.Request.Context(), userID.(string))
+	if err != nil {
+		response.Error(c, response.CodeServerError)
+		return
+	}
+
+	currentSessionID := c.GetString("session_id")
+	list := make([]gin.H, len(sessions))
+	for i, session := range sessions {
+		list[i] = gin.H{
+			"id":          session.ID,
+			"device_info": session.DeviceInfo,
+			"ip_address":  session.IPAddress,
+			"user_agent":  session.UserAgent,
+			"created_at":  session.CreatedAt,
+			"expires_at":  session.ExpiresAt,
+			"current":     currentSessionID != "" && session.ID == currentSessionID,
+		}
+	}
+
+	response.Success(c, list)
+}
+
+// DeleteMySession 登出当前用户的指定会话
+// DELETE /api/v1/auth/sessions/:session_id
+func (h *SessionHandler) DeleteMySession(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		response.Error(c, response.CodeInvalidToken)
+		return
+	}
+
+	sessionID := c.Param("session_id")
+	session, err := h.sessionService.Get(c.Request.Context(), sessionID)
+	if err != nil {
+		if errors.Is(err, service.ErrSessionNotFound) || errors.Is(err, service.ErrSessionExpired) {
+			response.Error(c, response.CodeSessionNotFound)
+			return
+		}
+		response.Error(c, response.CodeServerError)
+		return
+	}
+
+	// 只能登出自己的会话
+	if session.UserID != userID.(string) {
+		response.ErrorWithMsg(c, response.CodeForbidden, "不能操作其他用户的会话")
+		return
+	}
+
+	if err := h.sessionService.Delete(c.Request.Context(), sessionID); err != nil {
+		response.Error(c, response.CodeServerError)
+		return
+	}
+
+	response.Success(c, gin.H{"message": "会话已登出"})
+}
@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/pu-ac-cn/uac-backend/internal/model"
+	"github.com/pu-ac-cn/uac-backend/internal/service"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupSessionTestRouter 创建会话测试路由，模拟认证中间件写入的上下文
+func setupSessionTestRouter(t *testing.T, userID, sessionID string) (*gin.Engine, service.SessionService) {
+	gin.SetMode(gin.TestMode)
+
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		client.Close()
+		mr.Close()
+	})
+
+	sessionService := service.NewSessionService(client, nil)
+	h := NewSessionHandler(sessionService)
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", userID)
+		c.Set("session_id", sessionID)
+		c.Next()
+	})
+	router.GET("/api/v1/auth/sessions", h.ListMySessions)
+	router.DELETE("/api/v1/auth/sessions/:session_id", h.DeleteMySession)
+	return router, sessionService
+}
+
+func TestSessionHandler_ListMySessions(t *testing.T) {
+	router, sessionService := setupSessionTestRouter(t, "user-1", "session-current")
+	ctx := context.Background()
+
+	require.NoError(t, sessionService.Create(ctx, &model.Session{ID: "session-current", UserID: "user-1", IPAddress: "10.0.0.1"}))
+	require.NoError(t, sessionService.Create(ctx, &model.Session{ID: "session-other", UserID: "user-1", IPAddress: "10.0.0.2"}))
+	require.NoError(t, sessionService.Create(ctx, &model.Session{ID: "session-foreign", UserID: "user-2"}))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/auth/sessions", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		Code int `json:"code"`
+		Data []struct {
+			ID        string `json:"id"`
+			IPAddress string `json:"ip_address"`
+			Current   bool   `json:"current"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Data, 2)
+
+	for _, s := range resp.Data {
+		assert.Equal(t, s.ID == "session-current", s.Current)
+	}
+}
+
+func TestSessionHandler_DeleteMySession(t *testing.T) {
+	router, sessionService := setupSessionTestRouter(t, "user-1", "session-current")
+	ctx := context.Background()
+
+	require.NoError(t, sessionService.Create(ctx, &model.Session{ID: "session-other", UserID: "user-1"}))
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/auth/sessions/session-other", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	_, err := sessionService.Get(ctx, "session-other")
+	assert.ErrorIs(t, err, service.ErrSessionNotFound)
+}
+
+func TestSessionHandler_DeleteMySession_OtherUser(t *testing.T) {
+	router, sessionService := setupSessionTestRouter(t, "user-1", "session-current")
+	ctx := context.Background()
+
+	require.NoError(t, sessionService.Create(ctx, &model.Session{ID: "session-foreign", UserID: "user-2"}))
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/auth/sessions/session-foreign", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// 他人会话不应被删除
+	_, err := sessionService.Get(ctx, "session-foreign")
+	assert.NoError(t, err)
+}
+
+func TestSessionHandler_DeleteMySession_NotFound(t *testing.T) {
+	router, _ := setupSessionTestRouter(t, "user-1", "session-current")
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/auth/sessions/not-exist", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
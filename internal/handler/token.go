@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/pu-ac-cn/uac-backend/internal/model"
+	"github.com/pu-ac-cn/uac-backend/internal/service"
+	"github.com/pu-ac-cn/uac-backend/pkg/response"
+)
+
+// TokenHandler 令牌调试处理器，仅面向超级管理员
+type TokenHandler struct {
+	auditor
+	tokenService service.TokenService
+}
+
+// NewTokenHandler 创建令牌调试处理器
+func NewTokenHandler(tokenSvc service.TokenService) *TokenHandler {
+	return &TokenHandler{tokenService: tokenSvc}
+}
+
+// DecodeTokenRequest 解析令牌请求
+type DecodeTokenRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// DecodeToken 解析令牌，返回全部声明、kid 以及签名、过期、撤销检查结果
+// POST /api/v1/admin/tokens/decode
+func (h *TokenHandler) DecodeToken(c *gin.Context) {
+	var req DecodeTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, response.CodeInvalidRequest)
+		return
+	}
+
+	result, err := h.tokenService.InspectToken(c.Request.Context(), req.Token)
+	entry := &model.AuditLog{Action: model.AuditActionDecodeToken, Resource: "token"}
+	if result != nil {
+		// 只记录令牌标识，不记录令牌本身
+		entry.ResourceID, _ = result.Claims["jti"].(string)
+		entry.Detail = model.JSONMap{"sub": result.Claims["sub"], "valid": result.Valid}
+	}
+	h.audit(c, entry, err)
+	if err != nil {
+		response.ErrorWithMsg(c, response.CodeInvalidRequest, "令牌格式无效，无法解析")
+		return
+	}
+
+	response.Success(c, result)
+}
@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pu-ac-cn/uac-backend/internal/model"
+	"github.com/pu-ac-cn/uac-backend/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decodeToken(t *testing.T, router *gin.Engine, token string) (*httptest.ResponseRecorder, *service.TokenInspection) {
+	body, _ := json.Marshal(DecodeTokenRequest{Token: token})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/tokens/decode", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var resp struct {
+		Data *service.TokenInspection `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return w, resp.Data
+}
+
+func TestTokenHandler_DecodeToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	tokenService := service.NewTokenService(&service.TokenServiceConfig{
+		PrivateKey:    privateKey,
+		PublicKey:     &privateKey.PublicKey,
+		KeyID:         "test-key",
+		Issuer:        "http://localhost:8080",
+		AccessExpiry:  15 * time.Minute,
+		RefreshExpiry: 7 * 24 * time.Hour,
+		CodeExpiry:    10 * time.Minute,
+	})
+	audits := &stubAuditService{}
+	h := NewTokenHandler(tokenService)
+	h.SetAuditService(audits)
+	router := gin.New()
+	router.POST("/api/v1/admin/tokens/decode", h.DecodeToken)
+
+	ctx := context.Background()
+	token, err := tokenService.GenerateAccessToken(ctx, &service.TokenClaims{UserID: "user-1", ClientID: "client-a"})
+	require.NoError(t, err)
+
+	// 有效令牌完整解析
+	w, result := decodeToken(t, router, token)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, result.Valid)
+	assert.True(t, result.SignatureValid)
+	assert.Equal(t, "test-key", result.KeyID)
+	assert.Equal(t, "user-1", result.Claims["sub"])
+	assert.Equal(t, "client-a", result.Claims["client_id"])
+	assert.Empty(t, result.Reasons)
+
+	// 审计只记录令牌标识
+	require.Len(t, audits.entries, 1)
+	assert.Equal(t, model.AuditActionDecodeToken, audits.entries[0].Action)
+	assert.Equal(t, result.Claims["jti"], audits.entries[0].ResourceID)
+
+	// 撤销后给出具体原因
+	require.NoError(t, tokenService.RevokeToken(ctx, token))
+	w, result = decodeToken(t, router, token)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.False(t, result.Valid)
+	assert.True(t, result.Revoked)
+	assert.Equal(t, []string{"令牌已被撤销"}, result.Reasons)
+
+	// 格式无效
+	w, _ = decodeToken(t, router, "not-a-jwt")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
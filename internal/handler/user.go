@@ -2,7 +2,14 @@
 package handler
 
 import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/pu-ac-cn/uac-backend/internal/model"
@@ -13,12 +20,24 @@ import (
 
 // UserHandler 用户管理处理器
 type UserHandler struct {
-	userService service.UserService
+	userService    service.UserService
+	rbacService    service.RBACService
+	passwordPolicy service.PasswordPolicy
+	auditor
 }
 
 // NewUserHandler 创建用户管理处理器
-func NewUserHandler(userSvc service.UserService) *UserHandler {
-	return &UserHandler{userService: userSvc}
+func NewUserHandler(userSvc service.UserService, rbacSvc ...service.RBACService) *UserHandler {
+	h := &UserHandler{userService: userSvc, passwordPolicy: service.DefaultPasswordPolicy()}
+	if len(rbacSvc) > 0 {
+		h.rbacService = rbacSvc[0]
+	}
+	return h
+}
+
+// SetPasswordPolicy 设置创建用户和修改密码时使用的密码策略，未设置时使用默认策略
+func (h *UserHandler) SetPasswordPolicy(policy service.PasswordPolicy) {
+	h.passwordPolicy = policy
 }
 
 // ListUsers 获取用户列表
@@ -33,11 +52,37 @@ func (h *UserHandler) ListUsers(c *gin.Context) {
 		Username: c.Query("username"),
 		Email:    c.Query("email"),
 		Status:   c.Query("status"),
+		RoleCode: c.Query("role"),
+	}
+	if c.Query("include_deleted") == "true" {
+		// 已软删除的用户仅超级管理员可见
+		if !h.isSuperAdmin(c) {
+			response.ErrorWithMsg(c, response.CodeForbidden, "仅超级管理员可以查看已删除的用户")
+			return
+		}
+		filter.IncludeDeleted = true
+	}
+	var err error
+	if filter.CreatedAfter, err = parseTimeQuery(c, "created_after"); err != nil {
+		response.ErrorWithMsg(c, response.CodeInvalidFormat, "created_after 格式错误")
+		return
+	}
+	if filter.CreatedBefore, err = parseTimeQuery(c, "created_before"); err != nil {
+		response.ErrorWithMsg(c, response.CodeInvalidFormat, "created_before 格式错误")
+		return
 	}
 
 	pagination := &repository.Pagination{
-		Page:     page,
-		PageSize: pageSize,
+		Page:      page,
+		PageSize:  pageSize,
+		SortBy:    c.Query("sort_by"),
+		SortOrder: c.Query("order"),
+	}
+
+	fields, err := parseFields(c, userResponseFields)
+	if err != nil {
+		response.ErrorWithMsg(c, response.CodeInvalidRequest, err.Error())
+		return
 	}
 
 	users, total, err := h.userService.List(c.Request.Context(), filter, pagination)
@@ -49,39 +94,55 @@ func (h *UserHandler) ListUsers(c *gin.Context) {
 	// 转换为响应格式（隐藏敏感字段）
 	list := make([]gin.H, len(users))
 	for i, user := range users {
-		list[i] = gin.H{
-			"id":             user.ID,
-			"username":       user.Username,
-			"email":          user.Email,
-			"display_name":   user.DisplayName,
-			"phone":          user.Phone,
-			"status":         user.Status,
-			"email_verified": user.EmailVerified,
-			"phone_verified": user.PhoneVerified,
-			"created_at":     user.CreatedAt,
-			"updated_at":     user.UpdatedAt,
-		}
+		list[i] = fields.apply(userToResponse(user))
 	}
 
-	response.Success(c, gin.H{
-		"list":      list,
-		"total":     total,
-		"page":      page,
-		"page_size": pageSize,
-	})
+	response.Page(c, list, total, page, pageSize)
+}
+
+// isSuperAdmin 当前登录用户是否为超级管理员
+func (h *UserHandler) isSuperAdmin(c *gin.Context) bool {
+	if h.rbacService == nil {
+		return false
+	}
+	ok, err := h.rbacService.HasRole(c.Request.Context(), c.GetString("user_id"), model.RoleSuperAdmin)
+	return err == nil && ok
+}
+
+// parseTimeQuery 解析时间类型的查询参数，支持 RFC3339 和 2006-01-02 格式
+// 参数为空时返回零值
+func parseTimeQuery(c *gin.Context, key string) (time.Time, error) {
+	value := c.Query(key)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
 }
 
 // GetUser 获取用户详情
 // GET /api/v1/users/:id
 func (h *UserHandler) GetUser(c *gin.Context) {
 	id := c.Param("id")
+	fields, err := parseFields(c, userResponseFields)
+	if err != nil {
+		response.ErrorWithMsg(c, response.CodeInvalidRequest, err.Error())
+		return
+	}
 	user, err := h.userService.GetByID(c.Request.Context(), id)
 	if err != nil {
 		response.Error(c, response.CodeUserNotFound)
 		return
 	}
 
-	response.Success(c, gin.H{
+	response.Success(c, fields.apply(userToResponse(user)))
+}
+
+// userToResponse 将用户转换为响应格式（隐藏敏感字段）
+func userToResponse(user *model.User) gin.H {
+	data := gin.H{
 		"id":             user.ID,
 		"username":       user.Username,
 		"email":          user.Email,
@@ -92,6 +153,142 @@ func (h *UserHandler) GetUser(c *gin.Context) {
 		"phone_verified": user.PhoneVerified,
 		"created_at":     user.CreatedAt,
 		"updated_at":     user.UpdatedAt,
+	}
+	// 仅在查询包含已删除用户时出现
+	if user.DeletedAt.Valid {
+		data["deleted_at"] = user.DeletedAt.Time
+	}
+	return data
+}
+
+// ListAssignableRoles 获取当前管理员可分配给用户的角色
+// GET /api/v1/users/:id/assignable-roles
+func (h *UserHandler) ListAssignableRoles(c *gin.Context) {
+	operatorID := c.GetString("user_id")
+	if operatorID == "" {
+		response.Error(c, response.CodeInvalidToken)
+		return
+	}
+	if h.rbacService == nil {
+		response.Error(c, response.CodeServerError)
+		return
+	}
+
+	ctx := c.Request.Context()
+	user, err := h.userService.GetByID(ctx, c.Param("id"))
+	if err != nil {
+		response.Error(c, response.CodeUserNotFound)
+		return
+	}
+
+	// 组织角色仅限操作者与目标用户共同所属的组织
+	orgIDs, err := h.sharedOrgIDs(c, operatorID, user.ID)
+	if err != nil {
+		response.Error(c, response.CodeServerError)
+		return
+	}
+
+	roles, err := h.rbacService.ListAssignableRoles(ctx, operatorID, user.ID, orgIDs)
+	if err != nil {
+		response.Error(c, response.CodeServerError)
+		return
+	}
+	response.Success(c, roles)
+}
+
+// sharedOrgIDs 两个用户共同所属的组织 ID
+func (h *UserHandler) sharedOrgIDs(c *gin.Context, userA, userB string) ([]string, error) {
+	bindingsA, err := h.userService.ListUserOrganizations(c.Request.Context(), userA)
+	if err != nil {
+		return nil, err
+	}
+	bindingsB, err := h.userService.ListUserOrganizations(c.Request.Context(), userB)
+	if err != nil {
+		return nil, err
+	}
+
+	orgsA := make(map[string]bool, len(bindingsA))
+	for _, binding := range bindingsA {
+		orgsA[binding.OrgID] = true
+	}
+	orgIDs := make([]string, 0, len(bindingsB))
+	for _, binding := range bindingsB {
+		if orgsA[binding.OrgID] {
+			orgIDs = append(orgIDs, binding.OrgID)
+		}
+	}
+	return orgIDs, nil
+}
+
+// GetUserFull 获取用户完整信息（资料、角色、组织、账户安全状态）
+// GET /api/v1/users/:id/full
+// 密码哈希等敏感字段不会返回
+func (h *UserHandler) GetUserFull(c *gin.Context) {
+	ctx := c.Request.Context()
+	user, err := h.userService.GetByID(ctx, c.Param("id"))
+	if err != nil {
+		response.Error(c, response.CodeUserNotFound)
+		return
+	}
+
+	roles := []gin.H{}
+	if h.rbacService != nil {
+		userRoles, err := h.rbacService.GetUserRoles(ctx, user.ID)
+		if err != nil {
+			response.Error(c, response.CodeServerError)
+			return
+		}
+		for _, role := range userRoles {
+			roles = append(roles, gin.H{
+				"id":        role.ID,
+				"code":      role.Code,
+				"name":      role.Name,
+				"org_id":    role.OrgID,
+				"is_system": role.IsSystem,
+			})
+		}
+	}
+
+	bindings, err := h.userService.ListUserOrganizations(ctx, user.ID)
+	if err != nil {
+		response.Error(c, response.CodeServerError)
+		return
+	}
+	orgs := make([]gin.H, 0, len(bindings))
+	for _, b := range bindings {
+		org := gin.H{"id": b.OrgID, "joined_at": b.CreatedAt}
+		if b.Organization != nil {
+			org["name"] = b.Organization.Name
+			org["slug"] = b.Organization.Slug
+			org["status"] = b.Organization.Status
+		}
+		orgs = append(orgs, org)
+	}
+
+	response.Success(c, gin.H{
+		"profile": gin.H{
+			"id":           user.ID,
+			"username":     user.Username,
+			"email":        user.Email,
+			"display_name": user.DisplayName,
+			"phone":        user.Phone,
+			"avatar_url":   user.AvatarURL,
+			"status":       user.Status,
+			"created_at":   user.CreatedAt,
+			"updated_at":   user.UpdatedAt,
+		},
+		"roles":         roles,
+		"organizations": orgs,
+		"security": gin.H{
+			"email_verified":     user.EmailVerified,
+			"phone_verified":     user.PhoneVerified,
+			"locked":             user.IsLocked(),
+			"locked_until":       user.LockedUntil,
+			"failed_login_count": user.FailedLoginCount,
+			"first_login_at":     user.FirstLoginAt,
+			"last_login_at":      user.LastLoginAt,
+			"login_count":        user.LoginCount,
+		},
 	})
 }
 
@@ -99,7 +296,7 @@ func (h *UserHandler) GetUser(c *gin.Context) {
 type CreateUserRequest struct {
 	Username    string `json:"username" binding:"required,min=3"`
 	Email       string `json:"email" binding:"required,email"`
-	Password    string `json:"password" binding:"required,min=8"`
+	Password    string `json:"password" binding:"required"`
 	DisplayName string `json:"display_name"`
 	Phone       string `json:"phone"`
 }
@@ -121,8 +318,8 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 	}
 
 	// 检查密码强度
-	if !service.IsPasswordStrong(req.Password) {
-		response.ErrorWithMsg(c, response.CodeInvalidRequest, "密码强度不足，需要至少8位，包含大写字母、小写字母和数字")
+	if err := h.passwordPolicy.Validate(req.Password); err != nil {
+		response.ErrorWithMsg(c, response.CodeInvalidRequest, err.Error())
 		return
 	}
 
@@ -139,6 +336,9 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 			response.ErrorWithMsg(c, response.CodeUserExists, err.Error())
 			return
 		}
+		if respondPhoneError(c, err) {
+			return
+		}
 		response.ErrorWithMsg(c, response.CodeServerError, err.Error())
 		return
 	}
@@ -154,6 +354,158 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 	})
 }
 
+// ImportUsers 批量导入用户
+// POST /api/v1/users/import
+// 请求体为 JSON 数组，或通过 multipart 的 file 字段上传首行为表头的 CSV 文件
+// 逐行创建，部分失败不回滚已成功的行
+func (h *UserHandler) ImportUsers(c *gin.Context) {
+	var rows []CreateUserRequest
+	var err error
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		rows, err = readImportCSV(c)
+	} else {
+		rows, err = readImportJSON(c)
+	}
+	if err != nil {
+		if errors.Is(err, service.ErrImportTooLarge) {
+			response.ErrorWithMsg(c, response.CodeInvalidRequest,
+				fmt.Sprintf("单次最多导入 %d 行", service.MaxImportRows))
+			return
+		}
+		response.ErrorWithMsg(c, response.CodeInvalidRequest, "参数错误: "+err.Error())
+		return
+	}
+	if len(rows) == 0 {
+		response.ErrorWithMsg(c, response.CodeInvalidRequest, "导入数据为空")
+		return
+	}
+
+	// 密码强度与单个创建保持一致，未通过的行直接记为失败
+	results := make([]service.ImportResult, len(rows))
+	users := make([]*model.User, 0, len(rows))
+	passwords := make([]string, 0, len(rows))
+	rowIndex := make([]int, 0, len(rows))
+	for i, row := range rows {
+		if row.Password != "" {
+			if err := h.passwordPolicy.Validate(row.Password); err != nil {
+				results[i] = service.ImportResult{
+					Row:      i + 1,
+					Username: row.Username,
+					Error:    err.Error(),
+				}
+				continue
+			}
+		}
+		users = append(users, &model.User{
+			Username:    row.Username,
+			Email:       row.Email,
+			DisplayName: row.DisplayName,
+			Phone:       row.Phone,
+		})
+		passwords = append(passwords, row.Password)
+		rowIndex = append(rowIndex, i)
+	}
+
+	created, err := h.userService.BatchCreate(c.Request.Context(), users, passwords)
+	if err != nil {
+		response.Error(c, response.CodeServerError)
+		return
+	}
+	for j, result := range created {
+		i := rowIndex[j]
+		result.Row = i + 1
+		results[i] = result
+	}
+
+	succeeded := 0
+	for _, result := range results {
+		if result.Success {
+			succeeded++
+		}
+	}
+	response.Success(c, gin.H{
+		"total":     len(results),
+		"succeeded": succeeded,
+		"failed":    len(results) - succeeded,
+		"results":   results,
+	})
+}
+
+// readImportJSON 读取 JSON 数组形式的导入数据
+func readImportJSON(c *gin.Context) ([]CreateUserRequest, error) {
+	var rows []CreateUserRequest
+	if err := json.NewDecoder(c.Request.Body).Decode(&rows); err != nil {
+		return nil, err
+	}
+	if len(rows) > service.MaxImportRows {
+		return nil, service.ErrImportTooLarge
+	}
+	return rows, nil
+}
+
+// importCSVRequired CSV 表头必须包含的列，display_name 和 phone 可选
+var importCSVRequired = []string{"username", "email", "password"}
+
+// readImportCSV 读取上传的 CSV 导入文件，超过行数上限时立即停止读取
+func readImportCSV(c *gin.Context) ([]CreateUserRequest, error) {
+	header, err := c.FormFile("file")
+	if err != nil {
+		return nil, errors.New("缺少上传文件 file")
+	}
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.TrimLeadingSpace = true
+	names, err := reader.Read()
+	if err != nil {
+		return nil, errors.New("CSV 缺少表头")
+	}
+	columns := make(map[string]int, len(names))
+	for i, name := range names {
+		// Excel 导出的 UTF-8 CSV 可能带 BOM
+		name = strings.TrimPrefix(name, "\ufeff")
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range importCSVRequired {
+		if _, ok := columns[name]; !ok {
+			return nil, errors.New("CSV 缺少列: " + name)
+		}
+	}
+
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var rows []CreateUserRequest
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(rows) == service.MaxImportRows {
+			return nil, service.ErrImportTooLarge
+		}
+		rows = append(rows, CreateUserRequest{
+			Username:    field(record, "username"),
+			Email:       field(record, "email"),
+			Password:    field(record, "password"),
+			DisplayName: field(record, "display_name"),
+			Phone:       field(record, "phone"),
+		})
+	}
+	return rows, nil
+}
+
 // UpdateUser 更新用户
 // PUT /api/v1/users/:id
 func (h *UserHandler) UpdateUser(c *gin.Context) {
@@ -182,6 +534,9 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 	}
 
 	if err := h.userService.Update(c.Request.Context(), user); err != nil {
+		if respondPhoneError(c, err) {
+			return
+		}
 		response.Error(c, response.CodeServerError)
 		return
 	}
@@ -197,7 +552,7 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 }
 
 // DeleteUser 删除用户
-// DELETE /api/v1/users/:id
+// DELETE /api/v1/users/:id[?dry_run=true]
 func (h *UserHandler) DeleteUser(c *gin.Context) {
 	id := c.Param("id")
 
@@ -208,7 +563,34 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 		return
 	}
 
-	if err := h.userService.Delete(c.Request.Context(), id); err != nil {
+	if isDryRun(c) {
+		user, err := h.userService.GetByID(c.Request.Context(), id)
+		if err != nil {
+			response.Error(c, response.CodeUserNotFound)
+			return
+		}
+		bindings, err := h.userService.ListUserOrganizations(c.Request.Context(), user.ID)
+		if err != nil {
+			response.Error(c, response.CodeServerError)
+			return
+		}
+		impact := newDeleteImpact("user", user.ID, user.Username)
+		group := ImpactGroup{Total: int64(len(bindings)), Items: []ImpactItem{}}
+		for _, b := range bindings {
+			item := ImpactItem{ID: b.OrgID}
+			if b.Organization != nil {
+				item.Name = b.Organization.Name
+			}
+			group.Items = append(group.Items, item)
+		}
+		impact.Affected["org_bindings"] = group
+		respondDeleteImpact(c, impact)
+		return
+	}
+
+	err := h.userService.Delete(c.Request.Context(), id)
+	h.audit(c, &model.AuditLog{Action: model.AuditActionDeleteUser, Resource: "user", ResourceID: id}, err)
+	if err != nil {
 		response.Error(c, response.CodeServerError)
 		return
 	}
@@ -216,10 +598,92 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 	response.Success(c, gin.H{"message": "删除成功"})
 }
 
+// RestoreUser 恢复已软删除的用户
+// POST /api/v1/users/:id/restore
+func (h *UserHandler) RestoreUser(c *gin.Context) {
+	id := c.Param("id")
+	err := h.userService.RestoreUser(c.Request.Context(), id)
+	h.audit(c, &model.AuditLog{Action: model.AuditActionRestoreUser, Resource: "user", ResourceID: id}, err)
+	if err != nil {
+		if errors.Is(err, service.ErrUserNotFound) {
+			response.ErrorWithMsg(c, response.CodeUserNotFound, "用户不存在或未被删除")
+			return
+		}
+		if errors.Is(err, repository.ErrUserUsernameExists) {
+			response.ErrorWithMsg(c, response.CodeUserExists, "用户名已被其他用户占用，无法恢复")
+			return
+		}
+		if errors.Is(err, repository.ErrUserEmailExists) {
+			response.ErrorWithMsg(c, response.CodeEmailExists, "邮箱已被其他用户占用，无法恢复")
+			return
+		}
+		response.Error(c, response.CodeServerError)
+		return
+	}
+
+	response.Success(c, gin.H{"message": "恢复成功"})
+}
+
+// MergeUsersRequest 合并用户请求
+type MergeUsersRequest struct {
+	SourceID string `json:"source_id" binding:"required"`
+	TargetID string `json:"target_id" binding:"required"`
+}
+
+// MergeUsers 将源用户的组织、角色和审计记录合并到目标用户并删除源用户
+// POST /api/v1/users/merge
+func (h *UserHandler) MergeUsers(c *gin.Context) {
+	var req MergeUsersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ErrorWithMsg(c, response.CodeInvalidRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	// 不能合并掉自己
+	if c.GetString("user_id") == req.SourceID {
+		response.ErrorWithMsg(c, response.CodeForbidden, "不能合并当前登录的用户")
+		return
+	}
+
+	err := h.userService.MergeUsers(c.Request.Context(), req.SourceID, req.TargetID)
+	h.audit(c, &model.AuditLog{
+		Action:     model.AuditActionMergeUsers,
+		Resource:   "user",
+		ResourceID: req.TargetID,
+		Detail:     model.JSONMap{"source_id": req.SourceID},
+	}, err)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrUserNotFound):
+			response.Error(c, response.CodeUserNotFound)
+		case errors.Is(err, service.ErrMergeSameUser):
+			response.ErrorWithMsg(c, response.CodeInvalidRequest, err.Error())
+		default:
+			response.Error(c, response.CodeServerError)
+		}
+		return
+	}
+
+	response.Success(c, gin.H{"message": "合并成功"})
+}
+
+// respondPhoneError 手机号重复或格式无效时返回对应错误，返回是否已处理
+func respondPhoneError(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, service.ErrPhoneExists):
+		response.Error(c, response.CodePhoneExists)
+	case errors.Is(err, service.ErrPhoneInvalid):
+		response.ErrorWithMsg(c, response.CodeInvalidRequest, err.Error())
+	default:
+		return false
+	}
+	return true
+}
+
 // ChangePasswordRequest 修改密码请求
 type ChangePasswordRequest struct {
 	OldPassword string `json:"old_password" binding:"required"`
-	NewPassword string `json:"new_password" binding:"required,min=8"`
+	NewPassword string `json:"new_password" binding:"required"`
 }
 
 // ChangePassword 修改密码
@@ -238,8 +702,8 @@ func (h *UserHandler) ChangePassword(c *gin.Context) {
 	}
 
 	// 检查密码强度
-	if !service.IsPasswordStrong(req.NewPassword) {
-		response.ErrorWithMsg(c, response.CodeInvalidRequest, "密码强度不足，需要至少8位，包含大写字母、小写字母和数字")
+	if err := h.passwordPolicy.Validate(req.NewPassword); err != nil {
+		response.ErrorWithMsg(c, response.CodeInvalidRequest, err.Error())
 		return
 	}
 
@@ -248,6 +712,10 @@ func (h *UserHandler) ChangePassword(c *gin.Context) {
 			response.ErrorWithMsg(c, response.CodeInvalidCredentials, "原密码错误")
 			return
 		}
+		if err == service.ErrPasswordReused {
+			response.ErrorWithMsg(c, response.CodeInvalidRequest, err.Error())
+			return
+		}
 		response.Error(c, response.CodeServerError)
 		return
 	}
@@ -286,6 +754,9 @@ func (h *UserHandler) UpdateCurrentUser(c *gin.Context) {
 	// 不允许用户自己修改状态
 
 	if err := h.userService.Update(c.Request.Context(), user); err != nil {
+		if respondPhoneError(c, err) {
+			return
+		}
 		response.Error(c, response.CodeServerError)
 		return
 	}
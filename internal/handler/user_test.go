@@ -0,0 +1,582 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pu-ac-cn/uac-backend/internal/model"
+	"github.com/pu-ac-cn/uac-backend/internal/repository"
+	"github.com/pu-ac-cn/uac-backend/internal/service"
+	"github.com/pu-ac-cn/uac-backend/pkg/response"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// stubUserService 仅实现查询用户和组织绑定的用户服务
+type stubUserService struct {
+	service.UserService
+	user        *model.User
+	bindings    []*model.UserOrgBinding
+	orgBindings map[string][]*model.UserOrgBinding // 按用户区分的组织绑定，优先于 bindings
+	filter      *repository.UserFilter             // 最近一次 List 收到的过滤条件
+	imported    []*model.User                      // 最近一次 BatchCreate 收到的用户
+	merged      [2]string                          // 最近一次 MergeUsers 收到的源用户和目标用户
+	restored    string                             // 最近一次 RestoreUser 收到的用户
+	restoreErr  error                              // 非空时 RestoreUser 直接返回该错误
+}
+
+func (s *stubUserService) GetByID(ctx context.Context, id string) (*model.User, error) {
+	if s.user == nil || s.user.ID != id {
+		return nil, service.ErrUserNotFound
+	}
+	return s.user, nil
+}
+
+func (s *stubUserService) ListUserOrganizations(ctx context.Context, userID string) ([]*model.UserOrgBinding, error) {
+	if s.orgBindings != nil {
+		return s.orgBindings[userID], nil
+	}
+	return s.bindings, nil
+}
+
+func (s *stubUserService) List(ctx context.Context, filter *repository.UserFilter, page *repository.Pagination) ([]*model.User, int64, error) {
+	s.filter = filter
+	return []*model.User{s.user}, 1, nil
+}
+
+// RestoreUser 只能恢复 s.user 且要求其已被删除
+func (s *stubUserService) RestoreUser(ctx context.Context, id string) error {
+	s.restored = id
+	if s.restoreErr != nil {
+		return s.restoreErr
+	}
+	if s.user == nil || s.user.ID != id || !s.user.DeletedAt.Valid {
+		return service.ErrUserNotFound
+	}
+	s.user.DeletedAt = gorm.DeletedAt{}
+	return nil
+}
+
+// BatchCreate 与已有用户同名的行失败，其余行成功
+func (s *stubUserService) BatchCreate(ctx context.Context, users []*model.User, passwords []string) ([]service.ImportResult, error) {
+	s.imported = users
+	results := make([]service.ImportResult, len(users))
+	for i, user := range users {
+		results[i] = service.ImportResult{Row: i + 1, Username: user.Username}
+		if s.user != nil && s.user.Username == user.Username {
+			results[i].Error = repository.ErrUserUsernameExists.Error()
+			continue
+		}
+		results[i].Success = true
+		results[i].UserID = "imported-" + user.Username
+	}
+	return results, nil
+}
+
+// stubUserRBACService 仅实现查询用户角色的 RBAC 服务
+type stubUserRBACService struct {
+	service.RBACService
+	roles []*model.Role
+}
+
+func (s *stubUserRBACService) GetUserRoles(ctx context.Context, userID string) ([]*model.Role, error) {
+	return s.roles, nil
+}
+
+// stubSuperAdminRBACService 只有 superAdminID 拥有超级管理员角色
+type stubSuperAdminRBACService struct {
+	service.RBACService
+	superAdminID string
+}
+
+func (s *stubSuperAdminRBACService) HasRole(ctx context.Context, userID, roleCode string) (bool, error) {
+	return roleCode == model.RoleSuperAdmin && userID == s.superAdminID, nil
+}
+
+// stubAssignableRBACService 记录 ListAssignableRoles 收到的组织范围
+type stubAssignableRBACService struct {
+	service.RBACService
+	roles  []*model.Role
+	orgIDs []string
+}
+
+func (s *stubAssignableRBACService) ListAssignableRoles(ctx context.Context, operatorID, userID string, orgIDs []string) ([]*model.Role, error) {
+	s.orgIDs = orgIDs
+	return s.roles, nil
+}
+
+func setupUserFullTest(t *testing.T) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	lockedUntil := time.Now().Add(10 * time.Minute)
+	user := &model.User{
+		Username:         "alice",
+		Email:            "alice@example.com",
+		DisplayName:      "Alice",
+		Status:           model.StatusActive,
+		EmailVerified:    true,
+		FailedLoginCount: 5,
+		LockedUntil:      &lockedUntil,
+	}
+	user.ID = "user-1"
+	require.NoError(t, user.SetPassword("Password123"))
+
+	org := &model.Organization{Name: "研发部", Slug: "rd"}
+	org.ID = "org-1"
+	binding := &model.UserOrgBinding{UserID: user.ID, OrgID: org.ID, Organization: org}
+
+	role := &model.Role{Name: "组织管理员", Code: model.RoleOrgAdmin, OrgID: org.ID}
+	role.ID = "role-1"
+
+	h := NewUserHandler(
+		&stubUserService{user: user, bindings: []*model.UserOrgBinding{binding}},
+		&stubUserRBACService{roles: []*model.Role{role}},
+	)
+
+	router := gin.New()
+	router.GET("/api/v1/users/:id/full", h.GetUserFull)
+	return router
+}
+
+func TestUserHandler_GetUserFull(t *testing.T) {
+	router := setupUserFullTest(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/users/user-1/full", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		Data struct {
+			Profile       map[string]interface{}   `json:"profile"`
+			Roles         []map[string]interface{} `json:"roles"`
+			Organizations []map[string]interface{} `json:"organizations"`
+			Security      map[string]interface{}   `json:"security"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+
+	assert.Equal(t, "alice", resp.Data.Profile["username"])
+	require.Len(t, resp.Data.Roles, 1)
+	assert.Equal(t, model.RoleOrgAdmin, resp.Data.Roles[0]["code"])
+	require.Len(t, resp.Data.Organizations, 1)
+	assert.Equal(t, "org-1", resp.Data.Organizations[0]["id"])
+	assert.Equal(t, "研发部", resp.Data.Organizations[0]["name"])
+	assert.Equal(t, true, resp.Data.Security["locked"])
+	assert.Equal(t, float64(5), resp.Data.Security["failed_login_count"])
+}
+
+func TestUserHandler_GetUserFull_OmitsSecrets(t *testing.T) {
+	router := setupUserFullTest(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/users/user-1/full", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	body := w.Body.String()
+	assert.NotContains(t, body, "password")
+	assert.NotContains(t, body, "$2a$")
+	assert.NotContains(t, body, "secret")
+}
+
+func TestUserHandler_GetUserFull_NotFound(t *testing.T) {
+	router := setupUserFullTest(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/users/missing/full", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Contains(t, w.Body.String(), "用户不存在")
+}
+
+func TestUserHandler_ListUsers_CreatedAtAndRoleFilter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	user := &model.User{Username: "alice"}
+	user.ID = "user-1"
+	svc := &stubUserService{user: user}
+	router := gin.New()
+	router.GET("/api/v1/users", NewUserHandler(svc).ListUsers)
+
+	req := httptest.NewRequest(http.MethodGet,
+		"/api/v1/users?role=org_admin&created_after=2024-01-01&created_before=2024-02-01T08:00:00%2B08:00", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	require.NotNil(t, svc.filter)
+	assert.Equal(t, model.RoleOrgAdmin, svc.filter.RoleCode)
+	assert.True(t, svc.filter.CreatedAfter.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)))
+	assert.True(t, svc.filter.CreatedBefore.Equal(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)))
+}
+
+func TestUserHandler_ListUsers_InvalidCreatedAt(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	svc := &stubUserService{}
+	router := gin.New()
+	router.GET("/api/v1/users", NewUserHandler(svc).ListUsers)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/users?created_after=yesterday", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Contains(t, w.Body.String(), "created_after")
+	assert.Nil(t, svc.filter)
+}
+
+func TestUserHandler_ListUsers_IncludeDeleted(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	user := &model.User{Username: "alice"}
+	user.ID = "user-1"
+	user.DeletedAt = gorm.DeletedAt{Time: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), Valid: true}
+	svc := &stubUserService{user: user}
+	h := NewUserHandler(svc, &stubSuperAdminRBACService{superAdminID: "admin"})
+
+	list := func(operatorID string) *httptest.ResponseRecorder {
+		router := gin.New()
+		router.GET("/api/v1/users", func(c *gin.Context) {
+			c.Set("user_id", operatorID)
+			h.ListUsers(c)
+		})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/users?include_deleted=true", nil))
+		return w
+	}
+
+	// 组织管理员不能查看已删除的用户
+	w := list("org-admin")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Nil(t, svc.filter)
+
+	w = list("admin")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NotNil(t, svc.filter)
+	assert.True(t, svc.filter.IncludeDeleted)
+	assert.Contains(t, w.Body.String(), `"deleted_at":"2024-03-01T00:00:00Z"`)
+}
+
+func TestUserHandler_RestoreUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	user := &model.User{Username: "alice"}
+	user.ID = "user-1"
+	user.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	svc := &stubUserService{user: user}
+	router := gin.New()
+	router.POST("/api/v1/users/:id/restore", NewUserHandler(svc).RestoreUser)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/users/user-1/restore", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "user-1", svc.restored)
+	assert.False(t, user.DeletedAt.Valid)
+
+	// 未被删除的用户返回不存在
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/users/user-1/restore", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// 用户名已被重新注册时返回冲突
+	svc.restoreErr = repository.ErrUserUsernameExists
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/users/user-1/restore", nil))
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), fmt.Sprint(response.CodeUserExists))
+}
+
+// importResponse 批量导入接口的响应
+type importResponse struct {
+	Code int `json:"code"`
+	Data struct {
+		Total     int                    `json:"total"`
+		Succeeded int                    `json:"succeeded"`
+		Failed    int                    `json:"failed"`
+		Results   []service.ImportResult `json:"results"`
+	} `json:"data"`
+}
+
+func setupImportTest() (*gin.Engine, *stubUserService) {
+	gin.SetMode(gin.TestMode)
+	existing := &model.User{Username: "alice"}
+	existing.ID = "user-1"
+	svc := &stubUserService{user: existing}
+	router := gin.New()
+	router.POST("/api/v1/users/import", NewUserHandler(svc).ImportUsers)
+	return router, svc
+}
+
+func postImport(t *testing.T, router *gin.Engine, contentType string, body *bytes.Buffer) importResponse {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/users/import", body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var resp importResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return resp
+}
+
+// csvUpload 构造上传 CSV 文件的 multipart 请求体
+func csvUpload(t *testing.T, content string) (string, *bytes.Buffer) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("file", "users.csv")
+	require.NoError(t, err)
+	_, err = part.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	return writer.FormDataContentType(), body
+}
+
+func TestUserHandler_ImportUsers_JSON(t *testing.T) {
+	router, svc := setupImportTest()
+
+	body := bytes.NewBufferString(`[
+		{"username": "bob", "email": "bob@example.com", "password": "Password123"},
+		{"username": "alice", "email": "alice2@example.com", "password": "Password123"},
+		{"username": "carol", "email": "carol@example.com", "password": "weakpassword"},
+		{"username": "dave", "email": "dave@example.com", "password": "Password123", "display_name": "Dave"}
+	]`)
+	resp := postImport(t, router, "application/json", body)
+	require.Equal(t, 0, resp.Code)
+
+	assert.Equal(t, 4, resp.Data.Total)
+	assert.Equal(t, 2, resp.Data.Succeeded)
+	assert.Equal(t, 2, resp.Data.Failed)
+	require.Len(t, resp.Data.Results, 4)
+
+	// 行号对应原始顺序，弱密码的行不会提交给服务层
+	for i, result := range resp.Data.Results {
+		assert.Equal(t, i+1, result.Row)
+	}
+	assert.True(t, resp.Data.Results[0].Success)
+	assert.Equal(t, "用户名已存在", resp.Data.Results[1].Error)
+	assert.Contains(t, resp.Data.Results[2].Error, "密码强度不足")
+	assert.True(t, resp.Data.Results[3].Success)
+	assert.Equal(t, "imported-dave", resp.Data.Results[3].UserID)
+
+	require.Len(t, svc.imported, 3)
+	assert.Equal(t, "Dave", svc.imported[2].DisplayName)
+}
+
+func TestUserHandler_ImportUsers_CSV(t *testing.T) {
+	router, svc := setupImportTest()
+
+	contentType, body := csvUpload(t, "\ufeffUsername,Email,Password,Phone\n"+
+		"bob,bob@example.com,Password123,13800138000\n"+
+		"alice,alice2@example.com,Password123,\n")
+	resp := postImport(t, router, contentType, body)
+	require.Equal(t, 0, resp.Code)
+
+	assert.Equal(t, 2, resp.Data.Total)
+	assert.Equal(t, 1, resp.Data.Succeeded)
+	require.Len(t, svc.imported, 2)
+	assert.Equal(t, "bob@example.com", svc.imported[0].Email)
+	assert.Equal(t, "13800138000", svc.imported[0].Phone)
+	assert.False(t, resp.Data.Results[1].Success)
+}
+
+func TestUserHandler_ImportUsers_CSVMissingColumn(t *testing.T) {
+	router, svc := setupImportTest()
+
+	contentType, body := csvUpload(t, "username,email\nbob,bob@example.com\n")
+	resp := postImport(t, router, contentType, body)
+
+	assert.Equal(t, response.CodeInvalidRequest, resp.Code)
+	assert.Nil(t, svc.imported)
+}
+
+func TestUserHandler_ImportUsers_TooManyRows(t *testing.T) {
+	router, svc := setupImportTest()
+
+	var csvContent strings.Builder
+	csvContent.WriteString("username,email,password\n")
+	for i := 0; i <= service.MaxImportRows; i++ {
+		fmt.Fprintf(&csvContent, "user%d,user%d@example.com,Password123\n", i, i)
+	}
+	contentType, body := csvUpload(t, csvContent.String())
+	resp := postImport(t, router, contentType, body)
+	assert.Equal(t, response.CodeInvalidRequest, resp.Code)
+
+	rows := make([]CreateUserRequest, service.MaxImportRows+1)
+	data, err := json.Marshal(rows)
+	require.NoError(t, err)
+	resp = postImport(t, router, "application/json", bytes.NewBuffer(data))
+	assert.Equal(t, response.CodeInvalidRequest, resp.Code)
+
+	assert.Nil(t, svc.imported)
+}
+
+func TestUserHandler_ImportUsers_PasswordPolicy(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	svc := &stubUserService{user: &model.User{Username: "alice"}}
+	h := NewUserHandler(svc)
+	h.SetPasswordPolicy(service.PasswordPolicy{MinLength: 10, RequireSpecial: true})
+	router := gin.New()
+	router.POST("/api/v1/users/import", h.ImportUsers)
+
+	body := bytes.NewBufferString(`[
+		{"username": "bob", "email": "bob@example.com", "password": "password123"},
+		{"username": "carol", "email": "carol@example.com", "password": "short!"},
+		{"username": "dave", "email": "dave@example.com", "password": "long-enough!"}
+	]`)
+	resp := postImport(t, router, "application/json", body)
+	require.Equal(t, 0, resp.Code)
+	require.Len(t, resp.Data.Results, 3)
+
+	// 错误信息列出具体未满足的规则
+	assert.Equal(t, "密码强度不足：需要包含特殊字符", resp.Data.Results[0].Error)
+	assert.Equal(t, "密码强度不足：长度至少 10 位", resp.Data.Results[1].Error)
+	assert.True(t, resp.Data.Results[2].Success)
+	require.Len(t, svc.imported, 1)
+}
+
+func TestUserHandler_ListAssignableRoles(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	user := &model.User{Username: "alice"}
+	user.ID = "user-1"
+	userSvc := &stubUserService{
+		user: user,
+		orgBindings: map[string][]*model.UserOrgBinding{
+			"admin-1": {{UserID: "admin-1", OrgID: "org-a"}, {UserID: "admin-1", OrgID: "org-c"}},
+			"user-1":  {{UserID: "user-1", OrgID: "org-a"}, {UserID: "user-1", OrgID: "org-b"}},
+		},
+	}
+	role := &model.Role{Code: "auditor", OrgID: "org-a"}
+	role.ID = "role-a"
+	rbacSvc := &stubAssignableRBACService{roles: []*model.Role{role}}
+
+	h := NewUserHandler(userSvc, rbacSvc)
+	router := gin.New()
+	router.GET("/users/:id/assignable-roles", func(c *gin.Context) {
+		c.Set("user_id", "admin-1")
+	}, h.ListAssignableRoles)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/user-1/assignable-roles", nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// 组织范围限定为管理员与用户共同所属的组织
+	assert.Equal(t, []string{"org-a"}, rbacSvc.orgIDs)
+
+	var resp struct {
+		Data []*model.Role `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Data, 1)
+	assert.Equal(t, "role-a", resp.Data[0].ID)
+
+	// 用户不存在
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/missing/assignable-roles", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestUserHandler_FieldSelection(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	user := &model.User{Username: "alice", Email: "alice@example.com", Phone: "+8613800138000"}
+	user.ID = "user-1"
+	h := NewUserHandler(&stubUserService{user: user}, nil)
+	router := gin.New()
+	router.GET("/api/v1/users", h.ListUsers)
+	router.GET("/api/v1/users/:id", h.GetUser)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	// 详情只返回选择的字段
+	w := get("/api/v1/users/user-1?fields=id,username")
+	require.Equal(t, http.StatusOK, w.Code)
+	var detail struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &detail))
+	assert.Equal(t, map[string]interface{}{"id": "user-1", "username": "alice"}, detail.Data)
+
+	// 列表中的每一项同样裁剪，分页信息不受影响
+	w = get("/api/v1/users?fields=id,%20username")
+	require.Equal(t, http.StatusOK, w.Code)
+	var list struct {
+		Data struct {
+			List  []map[string]interface{} `json:"list"`
+			Total int64                    `json:"total"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Equal(t, int64(1), list.Data.Total)
+	require.Len(t, list.Data.List, 1)
+	assert.Equal(t, map[string]interface{}{"id": "user-1", "username": "alice"}, list.Data.List[0])
+
+	// 未指定时返回完整响应
+	w = get("/api/v1/users/user-1")
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &detail))
+	assert.Len(t, detail.Data, len(userResponseFields))
+
+	// 不在白名单内的字段被拒绝
+	w = get("/api/v1/users/user-1?fields=id,password_hash")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = get("/api/v1/users?fields=unknown")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestResponseFields_MatchResponses(t *testing.T) {
+	keys := func(data gin.H) []string {
+		fields := make([]string, 0, len(data))
+		for field := range data {
+			fields = append(fields, field)
+		}
+		return fields
+	}
+	assert.ElementsMatch(t, userResponseFields, keys(userToResponse(&model.User{})))
+	assert.ElementsMatch(t, appResponseFields, keys((&AppHandler{}).appToResponse(&model.Application{})))
+	assert.ElementsMatch(t, orgResponseFields, keys((&OrgHandler{}).orgToResponse(&model.Organization{})))
+}
+
+func (s *stubUserService) MergeUsers(ctx context.Context, sourceID, targetID string) error {
+	if s.user == nil || s.user.ID != targetID {
+		return service.ErrUserNotFound
+	}
+	s.merged = [2]string{sourceID, targetID}
+	return nil
+}
+
+func TestUserHandler_MergeUsers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	target := &model.User{Username: "local_user"}
+	target.ID = "user-target"
+	svc := &stubUserService{user: target}
+	router := gin.New()
+	router.POST("/api/v1/users/merge", func(c *gin.Context) {
+		c.Set("user_id", "admin-1")
+		c.Next()
+	}, NewUserHandler(svc).MergeUsers)
+
+	merge := func(body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/users/merge", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, merge(`{"source_id":"user-source","target_id":"user-target"}`))
+	assert.Equal(t, [2]string{"user-source", "user-target"}, svc.merged)
+
+	assert.Equal(t, http.StatusNotFound, merge(`{"source_id":"user-source","target_id":"missing"}`))
+	assert.Equal(t, http.StatusBadRequest, merge(`{"source_id":"user-source"}`))
+	// 不能合并掉当前登录的用户
+	assert.Equal(t, http.StatusForbidden, merge(`{"source_id":"admin-1","target_id":"user-target"}`))
+}
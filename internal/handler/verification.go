@@ -0,0 +1,67 @@
+// Package handler HTTP 处理器
+package handler
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pu-ac-cn/uac-backend/internal/repository"
+	"github.com/pu-ac-cn/uac-backend/internal/service"
+	"github.com/pu-ac-cn/uac-backend/pkg/response"
+)
+
+// VerificationHandler 邮箱验证处理器
+type VerificationHandler struct {
+	verificationService service.EmailVerificationService
+}
+
+// NewVerificationHandler 创建邮箱验证处理器
+func NewVerificationHandler(verificationSvc service.EmailVerificationService) *VerificationHandler {
+	return &VerificationHandler{verificationService: verificationSvc}
+}
+
+// SendVerificationEmail 向当前用户发送邮箱验证邮件
+// POST /api/v1/auth/send-verification-email
+func (h *VerificationHandler) SendVerificationEmail(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		response.Error(c, response.CodeInvalidToken)
+		return
+	}
+
+	if err := h.verificationService.SendVerificationEmail(c.Request.Context(), userID.(string)); err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			response.Error(c, response.CodeUserNotFound)
+			return
+		}
+		response.Error(c, response.CodeServerError)
+		return
+	}
+
+	response.Success(c, gin.H{"message": "验证邮件已发送"})
+}
+
+// VerifyEmail 校验邮箱验证链接
+// GET /api/v1/auth/verify-email?token=xxx
+func (h *VerificationHandler) VerifyEmail(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		response.ErrorWithMsg(c, response.CodeMissingParam, "缺少验证令牌")
+		return
+	}
+
+	if err := h.verificationService.VerifyEmail(c.Request.Context(), token); err != nil {
+		if errors.Is(err, service.ErrVerificationTokenInvalid) {
+			response.ErrorWithMsg(c, response.CodeInvalidRequest, err.Error())
+			return
+		}
+		if errors.Is(err, repository.ErrUserNotFound) {
+			response.Error(c, response.CodeUserNotFound)
+			return
+		}
+		response.Error(c, response.CodeServerError)
+		return
+	}
+
+	response.Success(c, gin.H{"message": "邮箱验证成功"})
+}
@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCounters(t *testing.T) {
+	before := testutil.ToFloat64(loginTotal.WithLabelValues(LoginSuccess))
+	Login(LoginSuccess)
+	assert.Equal(t, before+1, testutil.ToFloat64(loginTotal.WithLabelValues(LoginSuccess)))
+
+	before = testutil.ToFloat64(tokenIssuedTotal.WithLabelValues("authorization_code"))
+	TokenIssued("authorization_code")
+	assert.Equal(t, before+1, testutil.ToFloat64(tokenIssuedTotal.WithLabelValues("authorization_code")))
+
+	before = testutil.ToFloat64(tokenRequestFailuresTotal.WithLabelValues("authorization_code"))
+	TokenRequestFailed("authorization_code")
+	assert.Equal(t, before+1, testutil.ToFloat64(tokenRequestFailuresTotal.WithLabelValues("authorization_code")))
+
+	before = testutil.ToFloat64(tokenValidationTotal.WithLabelValues(ValidationExpired))
+	TokenValidation(ValidationExpired)
+	assert.Equal(t, before+1, testutil.ToFloat64(tokenValidationTotal.WithLabelValues(ValidationExpired)))
+}
+
+func TestActiveSessions(t *testing.T) {
+	before := testutil.ToFloat64(activeSessions)
+	SessionCreated()
+	SessionCreated()
+	assert.Equal(t, before+2, testutil.ToFloat64(activeSessions))
+
+	SessionsDeleted(2)
+	assert.Equal(t, before, testutil.ToFloat64(activeSessions))
+
+	// 未删除任何会话时不变
+	SessionsDeleted(0)
+	assert.Equal(t, before, testutil.ToFloat64(activeSessions))
+}
+
+func TestHandler(t *testing.T) {
+	Login(LoginInvalidCredentials)
+
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	assert.Contains(t, body, `login_total{result="invalid_credentials"}`)
+	assert.Contains(t, body, "active_sessions")
+	assert.Contains(t, body, "go_goroutines")
+}
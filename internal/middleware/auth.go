@@ -44,7 +44,7 @@ func JWTAuth(tokenService service.TokenService) gin.HandlerFunc {
 			return
 		}
 
-		// 检查令牌类型
+		// 只接受访问令牌，刷新令牌和 ID 令牌不能用于访问 API
 		if claims.Type != "access" {
 			response.ErrorWithMsg(c, response.CodeInvalidToken, "无效的令牌类型")
 			c.Abort()
@@ -55,7 +55,10 @@ func JWTAuth(tokenService service.TokenService) gin.HandlerFunc {
 		c.Set("user_id", claims.UserID)
 		c.Set("username", claims.Username)
 		c.Set("email", claims.Email)
+		c.Set("client_id", claims.ClientID)
+		c.Set("org_id", claims.OrgID)
 		c.Set("scopes", claims.Scopes)
+		c.Set("session_id", claims.SessionID)
 		c.Set("claims", claims)
 
 		c.Next()
@@ -82,7 +85,10 @@ func OptionalJWTAuth(tokenService service.TokenService) gin.HandlerFunc {
 			c.Set("user_id", claims.UserID)
 			c.Set("username", claims.Username)
 			c.Set("email", claims.Email)
+			c.Set("client_id", claims.ClientID)
+			c.Set("org_id", claims.OrgID)
 			c.Set("scopes", claims.Scopes)
+			c.Set("session_id", claims.SessionID)
 			c.Set("claims", claims)
 		}
 
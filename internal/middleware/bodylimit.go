@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pu-ac-cn/uac-backend/pkg/response"
+)
+
+// BodyLimitOverride 按路径前缀覆盖请求体大小上限，用于导入等需要较大请求体的端点
+type BodyLimitOverride struct {
+	PathPrefix string
+	MaxBytes   int64
+}
+
+// BodyLimit 限制请求体大小，超出时返回 413
+// Content-Length 已知且超限时直接拒绝；未知长度（分块传输）时通过 http.MaxBytesReader 在读取超限时报错，
+// 由处理器按参数错误返回。maxBytes <= 0 表示不限制；overrides 按最长前缀匹配覆盖默认上限
+func BodyLimit(maxBytes int64, overrides ...BodyLimitOverride) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit := maxBytes
+		matched := -1
+		for _, o := range overrides {
+			if strings.HasPrefix(c.Request.URL.Path, o.PathPrefix) && len(o.PathPrefix) > matched {
+				limit = o.MaxBytes
+				matched = len(o.PathPrefix)
+			}
+		}
+		if limit <= 0 || c.Request.Body == nil || c.Request.Body == http.NoBody {
+			c.Next()
+			return
+		}
+
+		if c.Request.ContentLength > limit {
+			response.Error(c, response.CodeRequestTooLarge)
+			c.Abort()
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		c.Next()
+	}
+}
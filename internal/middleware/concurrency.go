@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/pu-ac-cn/uac-backend/pkg/response"
+)
+
+// ConcurrencyLimit 限制同时处理的请求数，超出时直接返回 503，避免突发流量压垮数据库
+// n <= 0 时不限制；exemptPaths 中的路径（如健康检查）不占用名额也不会被拒绝
+// 名额在处理结束时通过 defer 释放，下游 panic 时同样会释放
+func ConcurrencyLimit(n int, exemptPaths ...string) gin.HandlerFunc {
+	if n <= 0 {
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	exempt := make(map[string]bool, len(exemptPaths))
+	for _, path := range exemptPaths {
+		exempt[path] = true
+	}
+	sem := make(chan struct{}, n)
+
+	return func(c *gin.Context) {
+		if exempt[c.Request.URL.Path] {
+			c.Next()
+			return
+		}
+
+		select {
+		case sem <- struct{}{}:
+		default:
+			c.Header("Retry-After", "1")
+			response.ErrorWithMsg(c, response.CodeUnavailable, "服务繁忙，请稍后重试")
+			c.Abort()
+			return
+		}
+		defer func() { <-sem }()
+		c.Next()
+	}
+}
@@ -2,22 +2,101 @@ package middleware
 
 import (
 	"net/http"
+	"strings"
+	"sync/atomic"
 
 	"github.com/gin-gonic/gin"
 )
 
+// corsOrigins 允许的跨域来源白名单，为空表示不允许任何跨域来源
+var corsOrigins atomic.Pointer[[]string]
+
+// corsNoCredentials 是否禁止跨域请求携带凭据，默认允许
+var corsNoCredentials atomic.Bool
+
+// SetCORSOrigins 设置允许的跨域来源，支持运行时调整
+// 支持精确来源（https://app.example.com）、通配子域（https://*.example.com）和 *
+func SetCORSOrigins(origins []string) {
+	corsOrigins.Store(&origins)
+}
+
+// SetCORSCredentials 设置是否允许跨域请求携带凭据，支持运行时调整
+// 不允许时公开端点对所有来源返回通配符 *
+func SetCORSCredentials(allow bool) {
+	corsNoCredentials.Store(!allow)
+}
+
+// matchOrigin 检查来源是否在白名单中
+// 精确匹配或通配子域匹配时 trusted 为 true；仅由 * 匹配时 trusted 为 false，此时不允许携带凭据
+func matchOrigin(origin string) (allowed, trusted bool) {
+	origins := corsOrigins.Load()
+	if origin == "" || origins == nil {
+		return false, false
+	}
+	for _, pattern := range *origins {
+		switch {
+		case pattern == "*":
+			allowed = true
+		case pattern == origin:
+			return true, true
+		case matchWildcardOrigin(pattern, origin):
+			return true, true
+		}
+	}
+	return allowed, false
+}
+
+// matchWildcardOrigin 匹配 scheme://*.domain 形式的通配子域，不匹配 domain 本身
+func matchWildcardOrigin(pattern, origin string) bool {
+	scheme, host, ok := strings.Cut(pattern, "://*.")
+	if !ok {
+		return false
+	}
+	prefix := scheme + "://"
+	if !strings.HasPrefix(origin, prefix) {
+		return false
+	}
+	sub := strings.TrimSuffix(strings.TrimPrefix(origin, prefix), "."+host)
+	return len(sub) < len(origin)-len(prefix) && sub != "" && !strings.ContainsAny(sub, "/:")
+}
+
+// isPublicPath 检查请求路径是否属于公开只读端点
+func isPublicPath(path string, publicPaths []string) bool {
+	for _, prefix := range publicPaths {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
 // CORS 跨域中间件
-func CORS() gin.HandlerFunc {
+// publicPaths 为公开只读端点的路径前缀，不允许携带凭据时这些端点对所有来源返回 *，
+// 其余端点始终按来源白名单逐一匹配
+func CORS(publicPaths ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		origin := c.Request.Header.Get("Origin")
+		credentials := !corsNoCredentials.Load()
 
-		// 设置 CORS 头
-		c.Header("Access-Control-Allow-Origin", origin)
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization, X-Request-ID, X-CSRF-Token")
-		c.Header("Access-Control-Expose-Headers", "Content-Length, X-Request-ID")
-		c.Header("Access-Control-Allow-Credentials", "true")
-		c.Header("Access-Control-Max-Age", "86400")
+		// 设置 CORS 头，只有白名单来源才回显 Origin
+		allowOrigin := ""
+		if !credentials && isPublicPath(c.Request.URL.Path, publicPaths) {
+			allowOrigin = "*"
+		} else if allowed, trusted := matchOrigin(origin); allowed {
+			allowOrigin = origin
+			credentials = credentials && trusted
+			c.Header("Vary", "Origin")
+		}
+		if allowOrigin != "" {
+			c.Header("Access-Control-Allow-Origin", allowOrigin)
+			c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+			c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization, X-Request-ID, X-CSRF-Token")
+			c.Header("Access-Control-Expose-Headers", "Content-Length, X-Request-ID")
+			if credentials {
+				c.Header("Access-Control-Allow-Credentials", "true")
+			}
+			c.Header("Access-Control-Max-Age", "86400")
+		}
 
 		// 安全响应头
 		c.Header("X-Content-Type-Options", "nosniff")
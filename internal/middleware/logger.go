@@ -1,18 +1,24 @@
 package middleware
 
 import (
+	"context"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/pu-ac-cn/uac-backend/pkg/response"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
 var logger *zap.Logger
 
+// logLevel 日志级别，支持运行时调整
+var logLevel = zap.NewAtomicLevelAt(zapcore.InfoLevel)
+
 func init() {
 	config := zap.NewProductionConfig()
+	config.Level = logLevel
 	config.EncoderConfig.TimeKey = "time"
 	config.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
 	config.EncoderConfig.MessageKey = "msg"
@@ -29,6 +35,30 @@ func GetLogger() *zap.Logger {
 	return logger
 }
 
+// SetLogLevel 设置日志级别
+func SetLogLevel(level string) error {
+	return logLevel.UnmarshalText([]byte(level))
+}
+
+// GetLogLevel 获取当前日志级别
+func GetLogLevel() string {
+	return logLevel.String()
+}
+
+// requestIDContextKey 请求 context 中保存请求 ID 的键
+type requestIDContextKey struct{}
+
+// WithRequestID 将请求 ID 写入 context，供 service、repository 等只持有 context 的层记录日志
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey{}, requestID)
+}
+
+// RequestIDFromContext 获取 context 中的请求 ID，不存在时返回空字符串
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDContextKey{}).(string)
+	return requestID
+}
+
 // Logger 日志中间件
 func Logger() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -37,7 +67,8 @@ func Logger() gin.HandlerFunc {
 		if requestID == "" {
 			requestID = uuid.New().String()
 		}
-		c.Set("request_id", requestID)
+		c.Set(response.RequestIDKey, requestID)
+		c.Request = c.Request.WithContext(WithRequestID(c.Request.Context(), requestID))
 		c.Header("X-Request-ID", requestID)
 
 		// 记录开始时间
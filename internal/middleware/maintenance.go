@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pu-ac-cn/uac-backend/pkg/response"
+)
+
+// maintenance 是否处于维护模式
+var maintenance atomic.Bool
+
+// SetMaintenance 开启或关闭维护模式，支持运行时调整
+func SetMaintenance(enabled bool) {
+	maintenance.Store(enabled)
+}
+
+// Maintenance 维护模式中间件，维护期间请求返回 503
+func Maintenance() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if maintenance.Load() {
+			c.Header("Retry-After", "300")
+			response.ErrorWithMsg(c, response.CodeUnavailable, "系统维护中，请稍后重试")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
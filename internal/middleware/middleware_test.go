@@ -1,11 +1,23 @@
 package middleware
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/alicebob/miniredis/v2"
 	"github.com/gin-gonic/gin"
+	"github.com/pu-ac-cn/uac-backend/internal/model"
+	"github.com/pu-ac-cn/uac-backend/internal/service"
+	"github.com/pu-ac-cn/uac-backend/pkg/response"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
 )
 
 func init() {
@@ -41,7 +53,9 @@ func TestLogger(t *testing.T) {
 func TestLoggerWithRequestID(t *testing.T) {
 	router := gin.New()
 	router.Use(Logger())
+	var ctxRequestID string
 	router.GET("/test", func(c *gin.Context) {
+		ctxRequestID = RequestIDFromContext(c.Request.Context())
 		c.String(http.StatusOK, "ok")
 	})
 
@@ -56,6 +70,50 @@ func TestLoggerWithRequestID(t *testing.T) {
 	if requestID != "custom-request-id" {
 		t.Errorf("期望 X-Request-ID 为 custom-request-id, 实际 %s", requestID)
 	}
+	// 请求 context 中同样携带请求 ID
+	if ctxRequestID != "custom-request-id" {
+		t.Errorf("期望 context 中的请求 ID 为 custom-request-id, 实际 %s", ctxRequestID)
+	}
+}
+
+// TestLoggerResponseRequestID 测试统一响应体携带 Logger 生成的请求 ID
+func TestLoggerResponseRequestID(t *testing.T) {
+	router := gin.New()
+	router.Use(Logger())
+	router.Use(Recovery())
+	router.GET("/ok", func(c *gin.Context) {
+		response.Success(c, nil)
+	})
+	router.GET("/error", func(c *gin.Context) {
+		response.ErrorWithMsg(c, response.CodeInvalidRequest, "参数错误")
+	})
+	router.GET("/panic", func(c *gin.Context) {
+		panic("测试 panic")
+	})
+
+	for _, path := range []string{"/ok", "/error", "/panic"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("X-Request-ID", "trace-123")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var body response.Response
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s 解析响应失败: %v", path, err)
+		}
+		if body.RequestID != "trace-123" {
+			t.Errorf("%s 期望响应体 request_id 为 trace-123, 实际 %q", path, body.RequestID)
+		}
+	}
+
+	// 未生成请求 ID 时响应体不包含该字段
+	bare := gin.New()
+	bare.GET("/ok", func(c *gin.Context) { response.Success(c, nil) })
+	w := httptest.NewRecorder()
+	bare.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ok", nil))
+	if strings.Contains(w.Body.String(), "request_id") {
+		t.Errorf("期望省略 request_id, 实际 %s", w.Body.String())
+	}
 }
 
 // TestRecovery 测试恢复中间件
@@ -80,6 +138,9 @@ func TestRecovery(t *testing.T) {
 
 // TestCORS 测试 CORS 中间件
 func TestCORS(t *testing.T) {
+	SetCORSOrigins([]string{"http://example.com"})
+	defer SetCORSOrigins(nil)
+
 	router := gin.New()
 	router.Use(CORS())
 	router.GET("/test", func(c *gin.Context) {
@@ -109,6 +170,9 @@ func TestCORS(t *testing.T) {
 
 // TestCORSPreflight 测试 CORS 预检请求
 func TestCORSPreflight(t *testing.T) {
+	SetCORSOrigins([]string{"http://example.com"})
+	defer SetCORSOrigins(nil)
+
 	router := gin.New()
 	router.Use(CORS())
 	router.GET("/test", func(c *gin.Context) {
@@ -128,6 +192,87 @@ func TestCORSPreflight(t *testing.T) {
 	}
 }
 
+// setupCORSRoutes 创建包含公开品牌端点和需认证 API 的路由，并在测试结束后恢复跨域配置
+func setupCORSRoutes(t *testing.T, origins []string, credentials bool) *gin.Engine {
+	SetCORSOrigins(origins)
+	SetCORSCredentials(credentials)
+	t.Cleanup(func() {
+		SetCORSOrigins(nil)
+		SetCORSCredentials(true)
+	})
+
+	router := gin.New()
+	router.Use(CORS("/api/v1/public/"))
+	router.GET("/api/v1/public/orgs/:slug/branding", func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+	router.GET("/api/v1/users", func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+	return router
+}
+
+// corsRequest 发送带 Origin 的请求
+func corsRequest(router *gin.Engine, method, path, origin string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("Origin", origin)
+	if method == http.MethodOptions {
+		req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// TestCORSPublicWildcardWithoutCredentials 测试关闭凭据后公开端点对所有来源返回 *
+func TestCORSPublicWildcardWithoutCredentials(t *testing.T) {
+	router := setupCORSRoutes(t, []string{"https://app.example.com"}, false)
+
+	for _, method := range []string{http.MethodGet, http.MethodOptions} {
+		w := corsRequest(router, method, "/api/v1/public/orgs/acme/branding", "https://other.example.org")
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+			t.Errorf("%s 期望 Access-Control-Allow-Origin 为 *, 实际 %q", method, got)
+		}
+		if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+			t.Errorf("%s 不应返回 Access-Control-Allow-Credentials, 实际 %q", method, got)
+		}
+	}
+}
+
+// TestCORSAPIRequiresAllowlistedOrigin 测试需认证的 API 只对白名单来源开放
+func TestCORSAPIRequiresAllowlistedOrigin(t *testing.T) {
+	for _, credentials := range []bool{true, false} {
+		router := setupCORSRoutes(t, []string{"https://app.example.com"}, credentials)
+
+		w := corsRequest(router, http.MethodGet, "/api/v1/users", "https://other.example.org")
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+			t.Errorf("credentials=%v 非白名单来源不应返回 Access-Control-Allow-Origin, 实际 %q", credentials, got)
+		}
+
+		w = corsRequest(router, http.MethodGet, "/api/v1/users", "https://app.example.com")
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+			t.Errorf("credentials=%v 白名单来源期望原样返回, 实际 %q", credentials, got)
+		}
+		wantCredentials := ""
+		if credentials {
+			wantCredentials = "true"
+		}
+		if got := w.Header().Get("Access-Control-Allow-Credentials"); got != wantCredentials {
+			t.Errorf("credentials=%v 期望 Access-Control-Allow-Credentials 为 %q, 实际 %q", credentials, wantCredentials, got)
+		}
+	}
+}
+
+// TestCORSPublicWithCredentials 测试允许凭据时公开端点仍按来源匹配
+func TestCORSPublicWithCredentials(t *testing.T) {
+	router := setupCORSRoutes(t, []string{"https://app.example.com"}, true)
+
+	w := corsRequest(router, http.MethodGet, "/api/v1/public/orgs/acme/branding", "https://other.example.org")
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("允许凭据时不应对非白名单来源开放, 实际 %q", got)
+	}
+}
+
 // TestSecurityHeaders 测试安全响应头
 func TestSecurityHeaders(t *testing.T) {
 	router := gin.New()
@@ -159,3 +304,546 @@ func TestGetLogger(t *testing.T) {
 		t.Error("GetLogger() 返回 nil")
 	}
 }
+
+// TestRateLimiter_Status 测试查询限流状态不增加计数
+func TestRateLimiter_Status(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("启动 miniredis 失败: %v", err)
+	}
+	defer mr.Close()
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	limiter := NewRateLimiter(client, RateLimitOptions{Window: time.Minute, Limit: 2})
+	router := gin.New()
+	router.POST("/login", limiter.Handler(), func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+	ctx := context.Background()
+
+	status := limiter.Status(ctx, "10.0.0.1", "/login")
+	if status == nil || status.Remaining != 2 || status.RetryAfter != 0 {
+		t.Fatalf("未请求时期望剩余 2 次, 实际 %+v", status)
+	}
+	if status := limiter.Status(ctx, "10.0.0.1", "/login"); status.Remaining != 2 {
+		t.Errorf("查询状态不应增加计数, 实际剩余 %d", status.Remaining)
+	}
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/login", nil)
+		req.RemoteAddr = "10.0.0.1:12345"
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	status = limiter.Status(ctx, "10.0.0.1", "/login")
+	if status.Remaining != 0 || status.RetryAfter != 60 {
+		t.Errorf("用尽后期望剩余 0 次且 60 秒后重试, 实际 %+v", status)
+	}
+
+	limiter.Update(RateLimitOptions{Disabled: true})
+	if status := limiter.Status(ctx, "10.0.0.1", "/login"); status != nil {
+		t.Errorf("限流关闭时期望 nil, 实际 %+v", status)
+	}
+}
+
+// TestRateLimit 测试基于 IP 的限流
+func TestRateLimit(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("启动 miniredis 失败: %v", err)
+	}
+	defer mr.Close()
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	router := gin.New()
+	router.Use(RateLimit(client, RateLimitOptions{Window: time.Minute, Limit: 2}))
+	router.POST("/login", func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+	router.POST("/token", func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+
+	send := func(path, ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		req.RemoteAddr = ip + ":12345"
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// 窗口内前 2 次放行
+	for i := 0; i < 2; i++ {
+		if w := send("/login", "10.0.0.1"); w.Code != http.StatusOK {
+			t.Fatalf("第 %d 次请求期望 200, 实际 %d", i+1, w.Code)
+		}
+	}
+
+	// 第 3 次被限流
+	w := send("/login", "10.0.0.1")
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("期望状态码 429, 实际 %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") != "60" {
+		t.Errorf("期望 Retry-After 为 60, 实际 %q", w.Header().Get("Retry-After"))
+	}
+
+	// 其他 IP 与其他路径独立计数
+	if w := send("/login", "10.0.0.2"); w.Code != http.StatusOK {
+		t.Errorf("其他 IP 期望 200, 实际 %d", w.Code)
+	}
+	if w := send("/token", "10.0.0.1"); w.Code != http.StatusOK {
+		t.Errorf("其他路径期望 200, 实际 %d", w.Code)
+	}
+
+	// 窗口结束后恢复
+	mr.FastForward(time.Minute)
+	if w := send("/login", "10.0.0.1"); w.Code != http.StatusOK {
+		t.Errorf("窗口结束后期望 200, 实际 %d", w.Code)
+	}
+}
+
+// TestSetLogLevel 测试运行时调整日志级别
+func TestSetLogLevel(t *testing.T) {
+	defer SetLogLevel("info")
+
+	if err := SetLogLevel("debug"); err != nil {
+		t.Fatalf("设置日志级别失败: %v", err)
+	}
+	if GetLogLevel() != "debug" {
+		t.Errorf("期望日志级别 debug, 实际 %s", GetLogLevel())
+	}
+	if !GetLogger().Core().Enabled(zap.DebugLevel) {
+		t.Error("debug 级别下应输出调试日志")
+	}
+
+	if err := SetLogLevel("verbose"); err == nil {
+		t.Error("无效日志级别应返回错误")
+	}
+}
+
+// TestCORSAllowedOrigins 测试跨域来源白名单
+func TestCORSAllowedOrigins(t *testing.T) {
+	tests := []struct {
+		name            string
+		origins         []string
+		origin          string
+		wantOrigin      string
+		wantCredentials string
+	}{
+		{"精确匹配", []string{"https://app.example.com"}, "https://app.example.com", "https://app.example.com", "true"},
+		{"非白名单来源", []string{"https://app.example.com"}, "https://evil.example.com", "", ""},
+		{"白名单为空不允许任何来源", nil, "https://app.example.com", "", ""},
+		{"通配子域", []string{"https://*.example.com"}, "https://app.example.com", "https://app.example.com", "true"},
+		{"通配多级子域", []string{"https://*.example.com"}, "https://a.b.example.com", "https://a.b.example.com", "true"},
+		{"通配不匹配主域", []string{"https://*.example.com"}, "https://example.com", "", ""},
+		{"通配不匹配相似域名", []string{"https://*.example.com"}, "https://evilexample.com", "", ""},
+		{"通配协议不同", []string{"https://*.example.com"}, "http://app.example.com", "", ""},
+		{"星号允许任意来源但不携带凭据", []string{"*"}, "https://any.example.org", "https://any.example.org", ""},
+	}
+
+	router := gin.New()
+	router.Use(CORS())
+	router.GET("/test", func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+	defer SetCORSOrigins(nil)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetCORSOrigins(tt.origins)
+			req := httptest.NewRequest(http.MethodGet, "/test", nil)
+			req.Header.Set("Origin", tt.origin)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("期望 Access-Control-Allow-Origin 为 %q, 实际 %q", tt.wantOrigin, got)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Credentials"); got != tt.wantCredentials {
+				t.Errorf("期望 Access-Control-Allow-Credentials 为 %q, 实际 %q", tt.wantCredentials, got)
+			}
+		})
+	}
+}
+
+// TestMaintenance 测试维护模式
+func TestMaintenance(t *testing.T) {
+	router := gin.New()
+	router.Use(Maintenance())
+	router.GET("/test", func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+
+	SetMaintenance(true)
+	defer SetMaintenance(false)
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("维护模式期望状态码 503, 实际 %d", w.Code)
+	}
+
+	SetMaintenance(false)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("关闭维护模式后期望状态码 200, 实际 %d", w.Code)
+	}
+}
+
+// TestConcurrencyLimit 测试超出并发上限的请求被拒绝，处理完成后名额释放
+func TestConcurrencyLimit(t *testing.T) {
+	const limit = 2
+	release := make(chan struct{})
+	entered := make(chan struct{}, limit)
+
+	router := gin.New()
+	router.Use(Recovery())
+	router.Use(ConcurrencyLimit(limit, "/health"))
+	router.GET("/slow", func(c *gin.Context) {
+		entered <- struct{}{}
+		<-release
+		c.String(http.StatusOK, "ok")
+	})
+	router.GET("/panic", func(c *gin.Context) {
+		panic("boom")
+	})
+	router.GET("/health", func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	// 占满全部名额
+	done := make(chan int, limit)
+	for i := 0; i < limit; i++ {
+		go func() { done <- get("/slow").Code }()
+	}
+	for i := 0; i < limit; i++ {
+		select {
+		case <-entered:
+		case <-time.After(5 * time.Second):
+			t.Fatal("请求未开始处理")
+		}
+	}
+
+	// 第 n+1 个请求被拒绝
+	w := get("/slow")
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("超出并发上限期望状态码 503, 实际 %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("期望设置 Retry-After 头")
+	}
+	// 豁免路径不受限制
+	if w := get("/health"); w.Code != http.StatusOK {
+		t.Errorf("健康检查期望状态码 200, 实际 %d", w.Code)
+	}
+
+	// 请求完成后名额释放
+	close(release)
+	for i := 0; i < limit; i++ {
+		if code := <-done; code != http.StatusOK {
+			t.Errorf("期望状态码 200, 实际 %d", code)
+		}
+	}
+
+	// panic 的请求同样释放名额
+	for i := 0; i < limit+1; i++ {
+		if w := get("/panic"); w.Code != http.StatusInternalServerError {
+			t.Errorf("panic 请求期望状态码 500, 实际 %d", w.Code)
+		}
+	}
+	if w := get("/slow"); w.Code != http.StatusOK {
+		t.Errorf("名额释放后期望状态码 200, 实际 %d", w.Code)
+	}
+}
+
+// TestRequireScope 测试 scope 检查中间件
+func TestRequireScope(t *testing.T) {
+	tests := []struct {
+		name       string
+		middleware gin.HandlerFunc
+		scopes     []string
+		wantStatus int
+	}{
+		{"包含全部 scope", RequireScope("openid", "profile"), []string{"openid", "profile", "email"}, http.StatusOK},
+		{"缺少部分 scope", RequireScope("openid", "profile"), []string{"openid"}, http.StatusForbidden},
+		{"包含任一 scope", RequireAnyScope("admin", "openid"), []string{"openid"}, http.StatusOK},
+		{"不包含任何 scope", RequireAnyScope("admin", "write"), []string{"openid"}, http.StatusForbidden},
+		{"令牌没有 scope", RequireScope("openid"), nil, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/test", func(c *gin.Context) {
+				c.Set("claims", &service.TokenClaims{UserID: "user-1", Scopes: tt.scopes})
+				c.Next()
+			}, tt.middleware, func(c *gin.Context) {
+				c.String(http.StatusOK, "ok")
+			})
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/test", nil))
+			if w.Code != tt.wantStatus {
+				t.Errorf("期望状态码 %d, 实际 %d", tt.wantStatus, w.Code)
+			}
+			if tt.wantStatus == http.StatusForbidden && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("scope 不足时期望设置 WWW-Authenticate 头")
+			}
+		})
+	}
+
+	// 未经过 JWTAuth 的请求视为未认证
+	router := gin.New()
+	router.GET("/test", RequireScope("openid"), func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/test", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("缺少令牌声明期望状态码 401, 实际 %d", w.Code)
+	}
+}
+
+// stubRBACService 仅实现 HasRole 和 GetUserRolesCached 的 RBAC 服务桩
+type stubRBACService struct {
+	service.RBACService
+	roles map[string][]string
+}
+
+func (s *stubRBACService) GetUserRolesCached(ctx context.Context, userID string) ([]string, error) {
+	return s.roles[userID], nil
+}
+
+func (s *stubRBACService) HasRole(ctx context.Context, userID, roleCode string) (bool, error) {
+	for _, code := range s.roles[userID] {
+		if code == roleCode {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// stubOrgAccess 组织成员关系桩，键为 "userID/orgID"
+type stubOrgAccess map[string]bool
+
+func (s stubOrgAccess) HasOrgAccess(ctx context.Context, userID, orgID string) (bool, error) {
+	return s[userID+"/"+orgID], nil
+}
+
+// TestRequireOrgMembership 测试组织成员检查中间件
+func TestRequireOrgMembership(t *testing.T) {
+	rbacService := &stubRBACService{roles: map[string][]string{
+		"admin":  {model.RoleSuperAdmin},
+		"member": {model.RoleOrgAdmin},
+	}}
+	orgAccess := stubOrgAccess{"member/org-1": true}
+
+	router := gin.New()
+	router.GET("/orgs/:id", func(c *gin.Context) {
+		if userID := c.GetHeader("X-User-ID"); userID != "" {
+			c.Set("user_id", userID)
+		}
+		c.Next()
+	}, RequireOrgMembership(orgAccess, rbacService, "id"), func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+
+	tests := []struct {
+		name   string
+		userID string
+		orgID  string
+		want   int
+	}{
+		{"成员可访问", "member", "org-1", http.StatusOK},
+		{"非成员被拒绝", "member", "org-2", http.StatusForbidden},
+		{"超级管理员不受限制", "admin", "org-2", http.StatusOK},
+		{"未登录", "", "org-1", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/orgs/"+tt.orgID, nil)
+			req.Header.Set("X-User-ID", tt.userID)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("期望状态码 %d, 实际 %d", tt.want, w.Code)
+			}
+		})
+	}
+}
+
+// TestRequireAnyRole 测试任一角色检查中间件
+func TestRequireAnyRole(t *testing.T) {
+	rbacService := &stubRBACService{roles: map[string][]string{
+		"admin":  {model.RoleSuperAdmin},
+		"member": {model.RoleOrgAdmin},
+		"plain":  {model.RoleUser},
+	}}
+
+	router := gin.New()
+	router.GET("/test", func(c *gin.Context) {
+		if userID := c.GetHeader("X-User-ID"); userID != "" {
+			c.Set("user_id", userID)
+		}
+		c.Next()
+	}, RequireAnyRole(rbacService, model.RoleSuperAdmin, model.RoleOrgAdmin), func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+
+	tests := []struct {
+		name   string
+		userID string
+		want   int
+	}{
+		{"超级管理员", "admin", http.StatusOK},
+		{"组织管理员", "member", http.StatusOK},
+		{"普通用户被拒绝", "plain", http.StatusForbidden},
+		{"未登录", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/test", nil)
+			req.Header.Set("X-User-ID", tt.userID)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("期望状态码 %d, 实际 %d", tt.want, w.Code)
+			}
+		})
+	}
+}
+
+// TestJWTAuth_TokenType 测试只有访问令牌可以访问受保护端点
+func TestJWTAuth_TokenType(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("生成密钥失败: %v", err)
+	}
+	tokenService := service.NewTokenService(&service.TokenServiceConfig{
+		PrivateKey:    key,
+		PublicKey:     &key.PublicKey,
+		Issuer:        "https://uac.example.com",
+		AccessExpiry:  time.Hour,
+		RefreshExpiry: time.Hour,
+	})
+
+	var got gin.H
+	router := gin.New()
+	router.GET("/test", JWTAuth(tokenService), func(c *gin.Context) {
+		got = gin.H{}
+		for _, key := range []string{"user_id", "client_id", "org_id"} {
+			got[key] = c.GetString(key)
+		}
+		got["scopes"] = c.GetStringSlice("scopes")
+		c.String(http.StatusOK, "ok")
+	})
+
+	ctx := context.Background()
+	newClaims := func() *service.TokenClaims {
+		return &service.TokenClaims{UserID: "user-1", ClientID: "client-1", OrgID: "org-1", Scopes: []string{"openid"}}
+	}
+	access, err := tokenService.GenerateAccessToken(ctx, newClaims())
+	if err != nil {
+		t.Fatalf("生成访问令牌失败: %v", err)
+	}
+	refresh, err := tokenService.GenerateRefreshToken(ctx, newClaims())
+	if err != nil {
+		t.Fatalf("生成刷新令牌失败: %v", err)
+	}
+	idToken, err := tokenService.GenerateIDToken(ctx, newClaims())
+	if err != nil {
+		t.Fatalf("生成 ID 令牌失败: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"访问令牌", access, http.StatusOK},
+		{"刷新令牌被拒绝", refresh, http.StatusUnauthorized},
+		{"ID 令牌被拒绝", idToken, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = nil
+			req := httptest.NewRequest(http.MethodGet, "/test", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("期望状态码 %d, 实际 %d", tt.want, w.Code)
+			}
+			if tt.want != http.StatusOK && got != nil {
+				t.Error("非访问令牌不应到达处理器")
+			}
+		})
+	}
+
+	// 访问令牌的身份信息写入上下文
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set("Authorization", "Bearer "+access)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	if got["user_id"] != "user-1" || got["client_id"] != "client-1" || got["org_id"] != "org-1" {
+		t.Errorf("上下文身份信息错误: %v", got)
+	}
+	if scopes, _ := got["scopes"].([]string); len(scopes) != 1 || scopes[0] != "openid" {
+		t.Errorf("上下文 scopes 错误: %v", got["scopes"])
+	}
+}
+
+// TestBodyLimit 测试请求体大小限制
+func TestBodyLimit(t *testing.T) {
+	router := gin.New()
+	router.Use(BodyLimit(16, BodyLimitOverride{PathPrefix: "/import", MaxBytes: 64}))
+	handler := func(c *gin.Context) {
+		var body map[string]string
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		c.String(http.StatusOK, "ok")
+	}
+	router.POST("/test", handler)
+	router.POST("/import", handler)
+
+	large := `{"data":"` + strings.Repeat("x", 32) + `"}`
+	tests := []struct {
+		name    string
+		path    string
+		body    string
+		chunked bool
+		want    int
+	}{
+		{"未超限", "/test", `{"a":"b"}`, false, http.StatusOK},
+		{"超大请求体被拒绝", "/test", large, false, http.StatusRequestEntityTooLarge},
+		{"未知长度读取时超限", "/test", large, true, http.StatusBadRequest},
+		{"路径覆盖上限", "/import", large, false, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.chunked {
+				req.ContentLength = -1
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("期望状态码 %d, 实际 %d: %s", tt.want, w.Code, w.Body.String())
+			}
+		})
+	}
+}
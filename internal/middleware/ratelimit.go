@@ -0,0 +1,136 @@
+package middleware
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pu-ac-cn/uac-backend/pkg/response"
+	"github.com/redis/go-redis/v9"
+)
+
+// rateLimitKeyPrefix 限流计数器 Redis key 前缀
+const rateLimitKeyPrefix = "ratelimit:"
+
+// RateLimitOptions 限流参数
+type RateLimitOptions struct {
+	// Window 固定计数窗口
+	Window time.Duration
+	// Limit 每个 IP 在窗口内对单个路径的最大请求数
+	Limit int
+	// Disabled 关闭限流
+	Disabled bool
+}
+
+// RateLimiter 基于 IP 的限流器，参数支持运行时调整
+type RateLimiter struct {
+	store *redis.Client
+	opts  atomic.Pointer[RateLimitOptions]
+}
+
+// NewRateLimiter 创建限流器
+func NewRateLimiter(store *redis.Client, opts RateLimitOptions) *RateLimiter {
+	l := &RateLimiter{store: store}
+	l.Update(opts)
+	return l
+}
+
+// Update 更新限流参数
+func (l *RateLimiter) Update(opts RateLimitOptions) {
+	if opts.Window <= 0 {
+		opts.Window = time.Minute
+	}
+	if opts.Limit <= 0 {
+		opts.Limit = 10
+	}
+	l.opts.Store(&opts)
+}
+
+// RateLimitStatus 某个 IP 对某个路径的限流状态
+type RateLimitStatus struct {
+	Limit      int // 窗口内允许的请求数
+	Remaining  int // 窗口内剩余的请求数
+	RetryAfter int // 已超限时距离窗口重置的秒数，未超限时为 0
+}
+
+// Status 查询 ip 对 path 的限流状态，不增加计数
+// 限流关闭或 Redis 不可用时返回 nil
+func (l *RateLimiter) Status(ctx context.Context, ip, path string) *RateLimitStatus {
+	opts := l.opts.Load()
+	if l.store == nil || opts.Disabled {
+		return nil
+	}
+
+	key := rateLimitKeyPrefix + ip + ":" + path
+	count, err := l.store.Get(ctx, key).Int64()
+	if err == redis.Nil {
+		count = 0
+	} else if err != nil {
+		return nil
+	}
+
+	status := &RateLimitStatus{Limit: opts.Limit, Remaining: opts.Limit - int(count)}
+	if status.Remaining <= 0 {
+		status.Remaining = 0
+		ttl, err := l.store.TTL(ctx, key).Result()
+		if err != nil || ttl <= 0 {
+			ttl = opts.Window
+		}
+		status.RetryAfter = int((ttl + time.Second - 1) / time.Second)
+	}
+	return status
+}
+
+// RateLimit 基于 IP 的限流中间件
+// 使用 Redis 固定窗口计数器，key 为 ratelimit:<ip>:<path>
+// Redis 不可用时放行请求，避免限流组件故障导致认证服务不可用
+func RateLimit(store *redis.Client, opts RateLimitOptions) gin.HandlerFunc {
+	return NewRateLimiter(store, opts).Handler()
+}
+
+// Handler 返回限流中间件
+func (l *RateLimiter) Handler() gin.HandlerFunc {
+	store := l.store
+	return func(c *gin.Context) {
+		opts := l.opts.Load()
+		if store == nil || opts.Disabled {
+			c.Next()
+			return
+		}
+
+		path := c.FullPath()
+		if path == "" {
+			path = c.Request.URL.Path
+		}
+		key := rateLimitKeyPrefix + c.ClientIP() + ":" + path
+		ctx := c.Request.Context()
+
+		count, err := store.Incr(ctx, key).Result()
+		if err != nil {
+			c.Next()
+			return
+		}
+		// 窗口内首个请求设置过期时间
+		if count == 1 {
+			store.Expire(ctx, key, opts.Window)
+		}
+
+		if count > int64(opts.Limit) {
+			ttl, err := store.TTL(ctx, key).Result()
+			if err != nil || ttl <= 0 {
+				// 过期时间丢失时重新设置，防止计数器永久存在
+				store.Expire(ctx, key, opts.Window)
+				ttl = opts.Window
+			}
+			retryAfter := int((ttl + time.Second - 1) / time.Second)
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			response.Error(c, response.CodeTooManyReq)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -2,7 +2,10 @@
 package middleware
 
 import (
+	"context"
+
 	"github.com/gin-gonic/gin"
+	"github.com/pu-ac-cn/uac-backend/internal/model"
 	"github.com/pu-ac-cn/uac-backend/internal/service"
 	"github.com/pu-ac-cn/uac-backend/pkg/response"
 )
@@ -19,6 +22,16 @@ func RequirePermission(rbacService service.RBACService, resource, action string)
 			return
 		}
 
+		if claims := serviceAccountClaims(c); claims != nil {
+			if !claims.HasPermission(resource, action) {
+				response.ErrorWithMsg(c, response.CodeForbidden, "没有权限执行此操作")
+				c.Abort()
+				return
+			}
+			c.Next()
+			return
+		}
+
 		// 检查权限
 		hasPermission, err := rbacService.CheckPermission(c.Request.Context(), userID.(string), resource, action)
 		if err != nil {
@@ -48,6 +61,16 @@ func RequireRole(rbacService service.RBACService, roleCode string) gin.HandlerFu
 			return
 		}
 
+		if claims := serviceAccountClaims(c); claims != nil {
+			if !claims.HasRole(roleCode) {
+				response.ErrorWithMsg(c, response.CodeForbidden, "没有权限执行此操作")
+				c.Abort()
+				return
+			}
+			c.Next()
+			return
+		}
+
 		hasRole, err := rbacService.HasRole(c.Request.Context(), userID.(string), roleCode)
 		if err != nil {
 			response.Error(c, response.CodeServerError)
@@ -66,7 +89,7 @@ func RequireRole(rbacService service.RBACService, roleCode string) gin.HandlerFu
 }
 
 // RequireAnyRole 任一角色检查中间件
-// 检查当前用户是否拥有任一指定角色
+// 检查当前用户是否拥有任一指定角色，用户角色通过 GetUserRolesCached 读取，避免每次请求查询数据库
 func RequireAnyRole(rbacService service.RBACService, roleCodes ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("user_id")
@@ -76,15 +99,26 @@ func RequireAnyRole(rbacService service.RBACService, roleCodes ...string) gin.Ha
 			return
 		}
 
-		for _, roleCode := range roleCodes {
-			hasRole, err := rbacService.HasRole(c.Request.Context(), userID.(string), roleCode)
+		var userRoles []string
+		if claims := serviceAccountClaims(c); claims != nil {
+			userRoles = claims.Roles
+		} else {
+			roles, err := rbacService.GetUserRolesCached(c.Request.Context(), userID.(string))
 			if err != nil {
-				continue
-			}
-			if hasRole {
-				c.Next()
+				response.Error(c, response.CodeServerError)
+				c.Abort()
 				return
 			}
+			userRoles = roles
+		}
+
+		for _, roleCode := range roleCodes {
+			for _, role := range userRoles {
+				if role == roleCode {
+					c.Next()
+					return
+				}
+			}
 		}
 
 		response.ErrorWithMsg(c, response.CodeForbidden, "没有权限执行此操作")
@@ -92,6 +126,56 @@ func RequireAnyRole(rbacService service.RBACService, roleCodes ...string) gin.Ha
 	}
 }
 
+// OrgAccessChecker 组织成员关系检查，service.UserService 实现了该接口
+type OrgAccessChecker interface {
+	HasOrgAccess(ctx context.Context, userID, orgID string) (bool, error)
+}
+
+// RequireOrgMembership 组织成员检查中间件
+// 检查当前用户是否属于路由参数 param 指定的组织，超级管理员不受限制
+func RequireOrgMembership(orgAccess OrgAccessChecker, rbacService service.RBACService, param string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("user_id")
+		if !exists {
+			response.Error(c, response.CodeInvalidToken)
+			c.Abort()
+			return
+		}
+
+		// 服务账号不属于任何组织，只有超级管理员角色可以访问
+		if claims := serviceAccountClaims(c); claims != nil {
+			if !claims.HasRole(model.RoleSuperAdmin) {
+				response.ErrorWithMsg(c, response.CodeForbidden, "不是该组织的成员")
+				c.Abort()
+				return
+			}
+			c.Next()
+			return
+		}
+
+		isSuperAdmin, err := rbacService.HasRole(c.Request.Context(), userID.(string), model.RoleSuperAdmin)
+		if err == nil && isSuperAdmin {
+			c.Next()
+			return
+		}
+
+		isMember, err := orgAccess.HasOrgAccess(c.Request.Context(), userID.(string), c.Param(param))
+		if err != nil {
+			response.Error(c, response.CodeServerError)
+			c.Abort()
+			return
+		}
+
+		if !isMember {
+			response.ErrorWithMsg(c, response.CodeForbidden, "不是该组织的成员")
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
 // LoadUserPermissions 加载用户权限到上下文
 // 用于在需要时获取用户的所有权限
 func LoadUserPermissions(rbacService service.RBACService) gin.HandlerFunc {
@@ -102,6 +186,13 @@ func LoadUserPermissions(rbacService service.RBACService) gin.HandlerFunc {
 			return
 		}
 
+		if claims := serviceAccountClaims(c); claims != nil {
+			c.Set("permissions", claims.Permissions)
+			c.Set("roles", claims.Roles)
+			c.Next()
+			return
+		}
+
 		permissions, err := rbacService.GetUserPermissions(c.Request.Context(), userID.(string))
 		if err == nil {
 			c.Set("permissions", permissions)
@@ -119,3 +210,17 @@ func LoadUserPermissions(rbacService service.RBACService) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// serviceAccountClaims 返回服务账号令牌的声明，普通用户令牌返回 nil
+// 服务账号的角色和权限在签发时写入令牌，无需查询数据库
+func serviceAccountClaims(c *gin.Context) *service.TokenClaims {
+	value, exists := c.Get("claims")
+	if !exists {
+		return nil
+	}
+	claims, ok := value.(*service.TokenClaims)
+	if !ok || !claims.IsServiceAccount() {
+		return nil
+	}
+	return claims
+}
@@ -16,11 +16,11 @@ func Recovery() gin.HandlerFunc {
 		defer func() {
 			if r := recover(); r != nil {
 				// 获取请求 ID
-				requestID, _ := c.Get("request_id")
+				requestID := c.GetString(response.RequestIDKey)
 
 				// 记录错误日志
 				logger.Error("服务器内部错误",
-					zap.Any("request_id", requestID),
+					zap.String("request_id", requestID),
 					zap.Any("error", r),
 					zap.String("stack", string(debug.Stack())),
 					zap.String("path", c.Request.URL.Path),
@@ -30,9 +30,10 @@ func Recovery() gin.HandlerFunc {
 
 				// 返回错误响应
 				c.AbortWithStatusJSON(http.StatusInternalServerError, response.Response{
-					Code: response.CodeServerError,
-					Msg:  "服务器内部错误，请稍后重试",
-					Data: nil,
+					Code:      response.CodeServerError,
+					Msg:       response.Message(c, response.CodeServerError),
+					Data:      nil,
+					RequestID: requestID,
 				})
 			}
 		}()
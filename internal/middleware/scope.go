@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pu-ac-cn/uac-backend/internal/service"
+	"github.com/pu-ac-cn/uac-backend/pkg/response"
+)
+
+// RequireScope scope 检查中间件，要求令牌包含全部指定的 scope
+// 需在 JWTAuth 之后使用
+func RequireScope(scopes ...string) gin.HandlerFunc {
+	return requireScopes(scopes, true)
+}
+
+// RequireAnyScope scope 检查中间件，要求令牌包含任一指定的 scope
+// 需在 JWTAuth 之后使用
+func RequireAnyScope(scopes ...string) gin.HandlerFunc {
+	return requireScopes(scopes, false)
+}
+
+// requireScopes 检查令牌 scope，all 为 true 时要求全部包含，否则包含任一即可
+func requireScopes(required []string, all bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, exists := c.Get("claims")
+		claims, ok := value.(*service.TokenClaims)
+		if !exists || !ok {
+			response.Error(c, response.CodeInvalidToken)
+			c.Abort()
+			return
+		}
+
+		granted := make(map[string]bool, len(claims.Scopes))
+		for _, scope := range claims.Scopes {
+			granted[scope] = true
+		}
+		matched := 0
+		for _, scope := range required {
+			if granted[scope] {
+				matched++
+			}
+		}
+
+		if (all && matched < len(required)) || (!all && matched == 0) {
+			// RFC 6750：scope 不足时通过 WWW-Authenticate 告知所需 scope
+			c.Header("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+strings.Join(required, " ")+`"`)
+			response.ErrorWithMsg(c, response.CodeForbidden, "令牌缺少所需的 scope")
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 )
@@ -14,16 +15,23 @@ import (
 // 应用属于组织，继承组织的品牌配置
 type Application struct {
 	BaseModel
-	OrgID            *string     `gorm:"type:char(36);index" json:"org_id"`                 // 所属组织 ID；NULL 表示系统级应用
-	Name             string      `gorm:"type:varchar(255);not null" json:"name"`            // 应用名称
-	ClientID         string      `gorm:"type:varchar(64);uniqueIndex" json:"client_id"`     // OAuth Client ID
-	ClientSecretHash string      `gorm:"type:varchar(255)" json:"-"`                        // Client Secret 哈希
-	OAuthVersion     string      `gorm:"type:varchar(10);default:2.1" json:"oauth_version"` // OAuth 版本：2.0 或 2.1
-	RedirectURIs     StringSlice `gorm:"type:json" json:"redirect_uris"`                    // 回调地址列表
-	AllowedScopes    StringSlice `gorm:"type:json" json:"allowed_scopes"`                   // 允许的权限范围
-	Protocol         string      `gorm:"type:varchar(20);default:oauth" json:"protocol"`    // 协议：oauth, saml, cas
-	Status           string      `gorm:"type:varchar(20);default:active" json:"status"`     // 状态
-	Description      string      `gorm:"type:text" json:"description"`                      // 应用描述
+	OrgID                  *string     `gorm:"type:char(36);index" json:"org_id"`                 // 所属组织 ID；NULL 表示系统级应用
+	Name                   string      `gorm:"type:varchar(255);not null" json:"name"`            // 应用名称
+	ClientID               string      `gorm:"type:varchar(64);uniqueIndex" json:"client_id"`     // OAuth Client ID
+	ClientSecretHash       string      `gorm:"type:varchar(255)" json:"-"`                        // Client Secret 哈希
+	OAuthVersion           string      `gorm:"type:varchar(10);default:2.1" json:"oauth_version"` // OAuth 版本：2.0 或 2.1
+	RedirectURIs           StringSlice `gorm:"type:json" json:"redirect_uris"`                    // 回调地址列表
+	PostLogoutRedirectURIs StringSlice `gorm:"type:json" json:"post_logout_redirect_uris"`        // 注销后允许跳转的地址列表
+	AllowSubpathRedirect   bool        `gorm:"default:false" json:"allow_subpath_redirect"`       // 允许回调到已注册地址的子路径
+	AllowedScopes          StringSlice `gorm:"type:json" json:"allowed_scopes"`                   // 允许的权限范围
+	AllowedGrantTypes      StringSlice `gorm:"type:json" json:"allowed_grant_types"`              // 允许的授权类型，为空表示全部允许
+	AccessTokenTTL         int         `gorm:"default:0" json:"access_token_ttl"`                 // 访问令牌有效期（秒），0 表示使用全局默认
+	RefreshTokenTTL        int         `gorm:"default:0" json:"refresh_token_ttl"`                // 刷新令牌有效期（秒），0 表示使用全局默认
+	Protocol               string      `gorm:"type:varchar(20);default:oauth" json:"protocol"`    // 协议：oauth, saml, cas
+	Status                 string      `gorm:"type:varchar(20);default:active" json:"status"`     // 状态
+	Description            string      `gorm:"type:text" json:"description"`                      // 应用描述
+	ServiceAccount         bool        `gorm:"default:false" json:"service_account"`              // 服务账号：客户端凭证令牌携带分配给应用的角色
+	CreatedBy              string      `gorm:"type:char(36);index" json:"created_by"`             // 创建者用户 ID，历史数据为空
 
 	// 关联
 	Organization *Organization `gorm:"foreignKey:OrgID" json:"organization,omitempty"`
@@ -34,6 +42,29 @@ func (Application) TableName() string {
 	return "applications"
 }
 
+// AccessTokenLifetime 应用自定义的访问令牌有效期，未配置时返回 0
+func (a *Application) AccessTokenLifetime() time.Duration {
+	return time.Duration(a.AccessTokenTTL) * time.Second
+}
+
+// AllowsGrantType 应用是否允许使用指定的授权类型，未配置时允许全部
+func (a *Application) AllowsGrantType(grantType string) bool {
+	if len(a.AllowedGrantTypes) == 0 {
+		return true
+	}
+	for _, t := range a.AllowedGrantTypes {
+		if t == grantType {
+			return true
+		}
+	}
+	return false
+}
+
+// RefreshTokenLifetime 应用自定义的刷新令牌有效期，未配置时返回 0
+func (a *Application) RefreshTokenLifetime() time.Duration {
+	return time.Duration(a.RefreshTokenTTL) * time.Second
+}
+
 // StringSlice 字符串切片类型，用于 JSON 存储
 type StringSlice []string
 
@@ -51,10 +82,19 @@ func (s *StringSlice) Scan(value interface{}) error {
 		*s = StringSlice{}
 		return nil
 	}
-	bytes, ok := value.([]byte)
-	if !ok {
+	var bytes []byte
+	switch v := value.(type) {
+	case []byte:
+		bytes = v
+	case string:
+		bytes = []byte(v)
+	default:
 		return errors.New("无法将值转换为 []byte")
 	}
+	if len(bytes) == 0 {
+		*s = StringSlice{}
+		return nil
+	}
 	return json.Unmarshal(bytes, s)
 }
 
@@ -78,6 +118,11 @@ func (a *Application) SetClientSecret(secret string) error {
 	return nil
 }
 
+// IsConfidential 是否为机密客户端，设置了 Client Secret 的应用在令牌端点必须认证
+func (a *Application) IsConfidential() bool {
+	return a.ClientSecretHash != ""
+}
+
 // VerifyClientSecret 验证 Client Secret
 func (a *Application) VerifyClientSecret(secret string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(a.ClientSecretHash), []byte(secret))
@@ -122,6 +167,16 @@ func (a *Application) HasRedirectURI(uri string) bool {
 	return false
 }
 
+// HasPostLogoutRedirectURI 检查注销后跳转地址是否在允许列表中
+func (a *Application) HasPostLogoutRedirectURI(uri string) bool {
+	for _, u := range a.PostLogoutRedirectURIs {
+		if u == uri {
+			return true
+		}
+	}
+	return false
+}
+
 // OAuth 版本常量
 const (
 	OAuthVersion20 = "2.0"
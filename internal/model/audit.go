@@ -0,0 +1,87 @@
+// Package model 数据模型定义
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// 审计操作类型
+const (
+	AuditActionLogin            = "auth.login"             // 登录
+	AuditActionLogout           = "auth.logout"            // 登出
+	AuditActionResetPassword    = "auth.reset_password"    // 重置密码
+	AuditActionAssignRole       = "role.assign"            // 分配角色
+	AuditActionRevokeRole       = "role.revoke"            // 撤销角色
+	AuditActionResetSecret      = "app.reset_secret"       // 重置应用密钥
+	AuditActionDeleteUser       = "user.delete"            // 删除用户
+	AuditActionRestoreUser      = "user.restore"           // 恢复已删除的用户
+	AuditActionMergeUsers       = "user.merge"             // 合并用户
+	AuditActionInvalidateTokens = "user.invalidate_tokens" // 作废一次性令牌
+	AuditActionDecodeToken      = "token.decode"           // 管理员解析令牌
+)
+
+// 审计结果
+const (
+	AuditResultSuccess = "success" // 成功
+	AuditResultFailure = "failure" // 失败
+)
+
+// AuditLog 审计日志，记录关键安全操作
+// 按操作目标和时间（Resource + ResourceID + CreatedAt）建立联合索引，便于按时间倒序查询某个资源的全部操作
+type AuditLog struct {
+	ID         string    `gorm:"type:char(36);primaryKey" json:"id"`
+	UserID     string    `gorm:"type:char(36);index" json:"user_id"`                                              // 操作者 ID，登录失败时可能为空
+	Action     string    `gorm:"type:varchar(50);index;not null" json:"action"`                                   // 操作类型
+	Resource   string    `gorm:"type:varchar(50);index:idx_audit_logs_target_time,priority:1" json:"resource"`    // 目标资源类型：user、role、application
+	ResourceID string    `gorm:"type:varchar(64);index:idx_audit_logs_target_time,priority:2" json:"resource_id"` // 目标资源 ID
+	IP         string    `gorm:"type:varchar(45)" json:"ip"`
+	UserAgent  string    `gorm:"type:varchar(500)" json:"user_agent"`
+	Result     string    `gorm:"type:varchar(20);not null" json:"result"` // success 或 failure
+	Detail     JSONMap   `gorm:"type:json" json:"detail,omitempty"`       // 附加信息，如失败原因
+	CreatedAt  time.Time `gorm:"index;index:idx_audit_logs_target_time,priority:3" json:"created_at"`
+}
+
+// TableName 指定表名
+func (AuditLog) TableName() string {
+	return "audit_logs"
+}
+
+// BeforeCreate 创建前自动生成 UUID
+func (a *AuditLog) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == "" {
+		a.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// JSONMap 键值对类型，用于 JSON 存储
+type JSONMap map[string]interface{}
+
+// Value 实现 driver.Valuer 接口
+func (m JSONMap) Value() (driver.Value, error) {
+	if m == nil {
+		return "{}", nil
+	}
+	return json.Marshal(m)
+}
+
+// Scan 实现 sql.Scanner 接口
+func (m *JSONMap) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*m = JSONMap{}
+		return nil
+	case []byte:
+		return json.Unmarshal(v, m)
+	case string:
+		return json.Unmarshal([]byte(v), m)
+	default:
+		return errors.New("无法将值转换为 JSONMap")
+	}
+}
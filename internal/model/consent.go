@@ -0,0 +1,14 @@
+package model
+
+// UserConsent 用户对应用的授权记录，已授权且覆盖所申请 scope 的应用再次授权时跳过同意页
+type UserConsent struct {
+	BaseModel
+	UserID   string      `gorm:"type:char(36);index;uniqueIndex:idx_user_consents_user_client;not null" json:"user_id"` // 用户 ID
+	ClientID string      `gorm:"type:varchar(100);uniqueIndex:idx_user_consents_user_client;not null" json:"client_id"` // 应用 client_id
+	Scopes   StringSlice `gorm:"type:json" json:"scopes"`                                                               // 用户已同意的权限范围
+}
+
+// TableName 指定表名
+func (UserConsent) TableName() string {
+	return "user_consents"
+}
@@ -16,6 +16,8 @@ type Organization struct {
 	Description string   `gorm:"type:text" json:"description"`                  // 组织描述
 	Branding    Branding `gorm:"type:json" json:"branding"`                     // 品牌配置
 	Status      string   `gorm:"type:varchar(20);default:active" json:"status"` // 状态：active, disabled
+	MaxApps     int      `gorm:"default:0" json:"max_apps"`                     // 应用数上限，0 表示不限
+	MaxUsers    int      `gorm:"default:0" json:"max_users"`                    // 绑定用户数上限，0 表示不限
 }
 
 // TableName 指定表名
@@ -1,6 +1,8 @@
 // Package model 定义数据模型
 package model
 
+import "time"
+
 // Role 角色模型
 type Role struct {
 	BaseModel
@@ -41,11 +43,12 @@ func (Permission) TableName() string {
 	return "permissions"
 }
 
-// UserRole 用户角色关联模型
+// UserRole 用户角色关联模型，同一用户同一角色只保留一条记录
 type UserRole struct {
 	BaseModel
-	UserID string `gorm:"type:char(36);index;not null" json:"user_id"` // 用户 ID
-	RoleID string `gorm:"type:char(36);index;not null" json:"role_id"` // 角色 ID
+	UserID    string     `gorm:"type:char(36);index;uniqueIndex:idx_user_roles_user_role;not null" json:"user_id"` // 用户 ID
+	RoleID    string     `gorm:"type:char(36);index;uniqueIndex:idx_user_roles_user_role;not null" json:"role_id"` // 角色 ID
+	ExpiresAt *time.Time `gorm:"index" json:"expires_at,omitempty"`                                                // 到期时间，为空表示永久有效
 
 	// 关联
 	User *User `gorm:"foreignKey:UserID" json:"user,omitempty"`
@@ -57,6 +60,21 @@ func (UserRole) TableName() string {
 	return "user_roles"
 }
 
+// ApplicationRole 应用角色关联模型，仅对服务账号应用生效
+type ApplicationRole struct {
+	BaseModel
+	AppID  string `gorm:"type:char(36);index;not null" json:"app_id"`  // 应用 ID
+	RoleID string `gorm:"type:char(36);index;not null" json:"role_id"` // 角色 ID
+
+	// 关联
+	Role *Role `gorm:"foreignKey:RoleID" json:"role,omitempty"`
+}
+
+// TableName 指定表名
+func (ApplicationRole) TableName() string {
+	return "application_roles"
+}
+
 // RolePermission 角色权限关联模型（GORM 自动创建，这里显式定义以便查询）
 type RolePermission struct {
 	RoleID       string `gorm:"type:char(36);primaryKey" json:"role_id"`
@@ -116,6 +134,29 @@ func DefaultSystemPermissions() []Permission {
 	return permissions
 }
 
+// SystemPermissions 受保护的系统内置权限：默认权限加上额外配置的权限
+// 额外权限未指定代码时按 resource:action 生成，与已有代码重复的会被忽略
+func SystemPermissions(extra []Permission) []Permission {
+	permissions := DefaultSystemPermissions()
+	seen := make(map[string]bool, len(permissions)+len(extra))
+	for _, perm := range permissions {
+		seen[perm.Code] = true
+	}
+	for _, perm := range extra {
+		if perm.Code == "" {
+			perm.Code = BuildPermissionCode(perm.Resource, perm.Action)
+		}
+		if seen[perm.Code] {
+			continue
+		}
+		seen[perm.Code] = true
+		perm.OrgID = ""
+		perm.IsSystem = true
+		permissions = append(permissions, perm)
+	}
+	return permissions
+}
+
 // DefaultSystemRoles 系统默认角色列表
 func DefaultSystemRoles() []Role {
 	return []Role{
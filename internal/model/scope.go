@@ -0,0 +1,51 @@
+package model
+
+// Scope OAuth 权限范围说明，用于在授权同意页展示应用申请的权限
+type Scope struct {
+	BaseModel
+	Code        string `gorm:"type:varchar(100);uniqueIndex" json:"code"` // scope 代码，与授权请求中的 scope 一致
+	DisplayName string `gorm:"type:varchar(100)" json:"display_name"`     // 展示名称
+	Description string `gorm:"type:varchar(500)" json:"description"`      // 面向用户的说明
+	IsSystem    bool   `gorm:"default:false" json:"is_system"`            // 是否系统内置 scope
+}
+
+// TableName 指定表名
+func (Scope) TableName() string {
+	return "scopes"
+}
+
+// DefaultSystemScopes 系统内置 scope 列表，对应 OIDC 标准 scope
+func DefaultSystemScopes() []Scope {
+	return []Scope{
+		{
+			Code:        "openid",
+			DisplayName: "基本身份",
+			Description: "使用你的账号登录该应用",
+			IsSystem:    true,
+		},
+		{
+			Code:        "profile",
+			DisplayName: "个人资料",
+			Description: "读取你的昵称、用户名和头像",
+			IsSystem:    true,
+		},
+		{
+			Code:        "email",
+			DisplayName: "邮箱地址",
+			Description: "读取你的邮箱地址及验证状态",
+			IsSystem:    true,
+		},
+		{
+			Code:        "phone",
+			DisplayName: "手机号",
+			Description: "读取你的手机号及验证状态",
+			IsSystem:    true,
+		},
+		{
+			Code:        "offline_access",
+			DisplayName: "离线访问",
+			Description: "在你未登录时继续访问已授权的数据",
+			IsSystem:    true,
+		},
+	}
+}
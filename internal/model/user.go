@@ -3,14 +3,17 @@ package model
 import (
 	"time"
 
+	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 // User 用户模型
 type User struct {
 	BaseModel
-	Username         string     `gorm:"type:varchar(100);uniqueIndex" json:"username"`
-	Email            string     `gorm:"type:varchar(255);uniqueIndex" json:"email"`
+	// 用户名和邮箱只在未删除的用户中唯一，软删除后可被重新注册
+	Username         string     `gorm:"type:varchar(100);uniqueIndex:idx_users_username,where:deleted_at IS NULL" json:"username"`
+	Email            string     `gorm:"type:varchar(255);uniqueIndex:idx_users_email,where:deleted_at IS NULL" json:"email"`
 	Phone            string     `gorm:"type:varchar(20);index" json:"phone,omitempty"`
 	PasswordHash     string     `gorm:"type:varchar(255)" json:"-"`
 	DisplayName      string     `gorm:"type:varchar(100)" json:"display_name"`
@@ -20,6 +23,14 @@ type User struct {
 	PhoneVerified    bool       `gorm:"default:false" json:"phone_verified"`
 	FailedLoginCount int        `gorm:"default:0" json:"-"`
 	LockedUntil      *time.Time `json:"-"`
+	TOTPSecret       string     `gorm:"type:varchar(64)" json:"-"`
+	MFAEnabled       bool       `gorm:"default:false" json:"mfa_enabled"`
+	// RecoveryCodes 未使用的 MFA 恢复码，每项为加盐哈希
+	RecoveryCodes StringSlice `gorm:"type:json" json:"-"`
+	// 登录统计仅由 RecordLogin 原子更新，Save 不会覆盖
+	FirstLoginAt *time.Time `gorm:"<-:create" json:"first_login_at,omitempty"`
+	LastLoginAt  *time.Time `gorm:"<-:create;index" json:"last_login_at,omitempty"`
+	LoginCount   int        `gorm:"<-:create;default:0" json:"login_count"`
 }
 
 // TableName 指定表名
@@ -43,6 +54,32 @@ func (u *User) VerifyPassword(password string) bool {
 	return err == nil
 }
 
+// PasswordHistory 用户设置过的密码哈希，用于防止重用最近的密码
+type PasswordHistory struct {
+	ID           string    `gorm:"type:char(36);primaryKey" json:"id"`
+	UserID       string    `gorm:"type:char(36);index;not null" json:"user_id"`
+	PasswordHash string    `gorm:"size:255;not null" json:"-"`
+	CreatedAt    time.Time `gorm:"index" json:"created_at"`
+}
+
+// TableName 指定表名
+func (PasswordHistory) TableName() string {
+	return "password_histories"
+}
+
+// BeforeCreate 创建前自动生成 UUID
+func (h *PasswordHistory) BeforeCreate(tx *gorm.DB) error {
+	if h.ID == "" {
+		h.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// Matches 检查密码是否与该历史记录一致
+func (h *PasswordHistory) Matches(password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(h.PasswordHash), []byte(password)) == nil
+}
+
 // IsActive 检查用户是否启用
 func (u *User) IsActive() bool {
 	return u.Status == StatusActive
@@ -50,17 +87,26 @@ func (u *User) IsActive() bool {
 
 // IsLocked 检查用户是否被锁定
 func (u *User) IsLocked() bool {
+	return u.IsLockedAt(time.Now())
+}
+
+// IsLockedAt 检查用户在指定时间是否处于锁定状态，锁定到期后自动解锁
+func (u *User) IsLockedAt(now time.Time) bool {
 	if u.LockedUntil == nil {
 		return false
 	}
-	return time.Now().Before(*u.LockedUntil)
+	return now.Before(*u.LockedUntil)
 }
 
 // IncrementFailedLogin 增加登录失败次数
-func (u *User) IncrementFailedLogin() {
+// 失败次数达到 maxAttempts 时锁定 lockDuration；上一次锁定已到期则重新计数
+func (u *User) IncrementFailedLogin(now time.Time, maxAttempts int, lockDuration time.Duration) {
+	if u.LockedUntil != nil && !now.Before(*u.LockedUntil) {
+		u.ResetFailedLogin()
+	}
 	u.FailedLoginCount++
-	if u.FailedLoginCount >= 5 {
-		lockTime := time.Now().Add(15 * time.Minute)
+	if u.FailedLoginCount >= maxAttempts {
+		lockTime := now.Add(lockDuration)
 		u.LockedUntil = &lockTime
 	}
 }
@@ -57,7 +57,7 @@ func (r *applicationRepository) Create(ctx context.Context, app *model.Applicati
 		return ErrAppClientIDExists
 	}
 
-	return r.db.WithContext(ctx).Create(app).Error
+	return translateUniqueViolation(r.db.WithContext(ctx).Create(app).Error, ErrAppClientIDExists)
 }
 
 // GetByID 根据 ID 获取应用
@@ -93,7 +93,13 @@ func (r *applicationRepository) Update(ctx context.Context, app *model.Applicati
 		"name",
 		"description",
 		"redirect_uris",
+		"post_logout_redirect_uris",
+		"allow_subpath_redirect",
 		"allowed_scopes",
+		"allowed_grant_types",
+		"access_token_ttl",
+		"refresh_token_ttl",
+		"service_account",
 		"protocol",
 		"status",
 		"client_secret_hash",
@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/pu-ac-cn/uac-backend/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestStringSlice_RoundTrip(t *testing.T) {
+	for _, original := range []model.StringSlice{
+		{"https://a.example.com/cb", "com.example.app:/cb?x=1,2"},
+		{"openid"},
+		{},
+	} {
+		value, err := original.Value()
+		require.NoError(t, err)
+
+		// 不同驱动分别以 []byte 或 string 返回 JSON 列
+		for _, raw := range []interface{}{value, string(value.([]byte))} {
+			var scanned model.StringSlice
+			require.NoError(t, scanned.Scan(raw))
+			assert.Equal(t, original, scanned)
+		}
+	}
+
+	var empty model.StringSlice
+	require.NoError(t, empty.Scan(nil))
+	assert.Equal(t, model.StringSlice{}, empty)
+
+	value, err := model.StringSlice(nil).Value()
+	require.NoError(t, err)
+	assert.Equal(t, "[]", value)
+}
+
+func TestApplicationRepository_UpdateWritesJSON(t *testing.T) {
+	db, _ := setupBlockingDB(t)
+
+	app := &model.Application{
+		Name:          "a",
+		RedirectURIs:  model.StringSlice{"https://a.example.com/cb"},
+		AllowedScopes: model.StringSlice{"openid", "profile"},
+	}
+	app.ID = "app-1"
+	sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		return tx.Model(app).Select("redirect_uris", "allowed_scopes").Updates(app)
+	})
+	assert.Contains(t, sql, `"redirect_uris"='["https://a.example.com/cb"]'`)
+	assert.Contains(t, sql, `"allowed_scopes"='["openid","profile"]'`)
+}
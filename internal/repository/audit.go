@@ -0,0 +1,85 @@
+// Package repository 数据访问层
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/pu-ac-cn/uac-backend/internal/model"
+	"gorm.io/gorm"
+)
+
+// AuditLogRepository 审计日志数据访问接口
+type AuditLogRepository interface {
+	Create(ctx context.Context, entry *model.AuditLog) error
+	List(ctx context.Context, filter *AuditLogFilter, page *Pagination) ([]*model.AuditLog, int64, error)
+}
+
+// AuditLogFilter 审计日志查询过滤器
+type AuditLogFilter struct {
+	UserID        string    // 操作者 ID
+	Action        string    // 操作类型
+	Resource      string    // 目标资源类型
+	ResourceID    string    // 目标资源 ID
+	CreatedAfter  time.Time // 不早于该时间，零值不限制
+	CreatedBefore time.Time // 早于该时间，零值不限制
+}
+
+// auditLogRepository 审计日志数据访问实现
+type auditLogRepository struct {
+	db *gorm.DB
+}
+
+// NewAuditLogRepository 创建审计日志数据访问实例
+func NewAuditLogRepository(db *gorm.DB) AuditLogRepository {
+	return &auditLogRepository{db: db}
+}
+
+// Create 写入审计日志
+func (r *auditLogRepository) Create(ctx context.Context, entry *model.AuditLog) error {
+	return r.db.WithContext(ctx).Create(entry).Error
+}
+
+// List 按过滤条件分页查询审计日志，按时间倒序
+func (r *auditLogRepository) List(ctx context.Context, filter *AuditLogFilter, page *Pagination) ([]*model.AuditLog, int64, error) {
+	var logs []*model.AuditLog
+	var total int64
+
+	query := applyAuditLogFilter(r.db.WithContext(ctx).Model(&model.AuditLog{}), filter)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if page != nil && page.Page > 0 && page.PageSize > 0 {
+		query = query.Offset((page.Page - 1) * page.PageSize).Limit(page.PageSize)
+	}
+	if err := query.Order("created_at DESC").Find(&logs).Error; err != nil {
+		return nil, 0, err
+	}
+	return logs, total, nil
+}
+
+// applyAuditLogFilter 按过滤条件拼接审计日志查询的 WHERE 条件
+func applyAuditLogFilter(query *gorm.DB, filter *AuditLogFilter) *gorm.DB {
+	if filter == nil {
+		return query
+	}
+	if filter.UserID != "" {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.Resource != "" {
+		query = query.Where("resource = ?", filter.Resource)
+	}
+	if filter.ResourceID != "" {
+		query = query.Where("resource_id = ?", filter.ResourceID)
+	}
+	if !filter.CreatedAfter.IsZero() {
+		query = query.Where("created_at >= ?", filter.CreatedAfter)
+	}
+	if !filter.CreatedBefore.IsZero() {
+		query = query.Where("created_at < ?", filter.CreatedBefore)
+	}
+	return query
+}
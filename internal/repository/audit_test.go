@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pu-ac-cn/uac-backend/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// auditLogListSQL 生成按过滤条件查询审计日志的 SQL，不实际执行
+func auditLogListSQL(t *testing.T, filter *AuditLogFilter) string {
+	db, _ := setupBlockingDB(t)
+	return db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		var logs []*model.AuditLog
+		return applyAuditLogFilter(tx.Model(&model.AuditLog{}), filter).Find(&logs)
+	})
+}
+
+func TestApplyAuditLogFilter_Target(t *testing.T) {
+	sql := auditLogListSQL(t, &AuditLogFilter{Resource: "user", ResourceID: "user-2"})
+
+	// 只按目标过滤，不限制操作者，可查到不同操作者对同一资源的操作
+	assert.Contains(t, sql, "resource = 'user'")
+	assert.Contains(t, sql, "resource_id = 'user-2'")
+	assert.NotContains(t, sql, "user_id")
+}
+
+func TestApplyAuditLogFilter_Combined(t *testing.T) {
+	sql := auditLogListSQL(t, &AuditLogFilter{
+		UserID:        "admin-1",
+		Action:        model.AuditActionAssignRole,
+		CreatedAfter:  time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		CreatedBefore: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+	})
+
+	assert.Contains(t, sql, "user_id = 'admin-1'")
+	assert.Contains(t, sql, "action = 'role.assign'")
+	assert.Contains(t, sql, "created_at >= '2024-01-01 00:00:00'")
+	assert.Contains(t, sql, "created_at < '2024-02-01 00:00:00'")
+	assert.NotContains(t, sql, "resource")
+}
+
+func TestAuditLog_Indexes(t *testing.T) {
+	s, err := schema.Parse(&model.AuditLog{}, &sync.Map{}, schema.NamingStrategy{})
+	require.NoError(t, err)
+
+	indexes := s.ParseIndexes()
+	target, ok := indexes["idx_audit_logs_target_time"]
+	require.True(t, ok, "缺少目标资源联合索引")
+	require.Len(t, target.Fields, 3)
+	assert.Equal(t, "resource", target.Fields[0].DBName)
+	assert.Equal(t, "resource_id", target.Fields[1].DBName)
+	assert.Equal(t, "created_at", target.Fields[2].DBName)
+
+	for _, name := range []string{"idx_audit_logs_user_id", "idx_audit_logs_action", "idx_audit_logs_created_at"} {
+		assert.Contains(t, indexes, name)
+	}
+}
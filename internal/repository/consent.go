@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/pu-ac-cn/uac-backend/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrConsentNotFound 授权记录不存在
+var ErrConsentNotFound = errors.New("授权记录不存在")
+
+// ConsentRepository 用户授权记录数据访问接口
+type ConsentRepository interface {
+	Get(ctx context.Context, userID, clientID string) (*model.UserConsent, error)
+	// Save 按 (user_id, client_id) upsert，已存在时覆盖授权范围
+	Save(ctx context.Context, consent *model.UserConsent) error
+	// ListByUser 列出用户的授权记录，最近授权的在前
+	ListByUser(ctx context.Context, userID string) ([]*model.UserConsent, error)
+	// Delete 物理删除授权记录，避免软删除的记录占用唯一索引
+	Delete(ctx context.Context, userID, clientID string) error
+}
+
+type consentRepository struct {
+	db *gorm.DB
+}
+
+// NewConsentRepository 创建用户授权记录数据访问实例
+func NewConsentRepository(db *gorm.DB) ConsentRepository {
+	return &consentRepository{db: db}
+}
+
+func (r *consentRepository) Get(ctx context.Context, userID, clientID string) (*model.UserConsent, error) {
+	var consent model.UserConsent
+	err := r.db.WithContext(ctx).Where("user_id = ? AND client_id = ?", userID, clientID).First(&consent).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrConsentNotFound
+		}
+		return nil, err
+	}
+	return &consent, nil
+}
+
+func (r *consentRepository) Save(ctx context.Context, consent *model.UserConsent) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "client_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"scopes", "updated_at", "deleted_at"}),
+	}).Create(consent).Error
+}
+
+func (r *consentRepository) ListByUser(ctx context.Context, userID string) ([]*model.UserConsent, error) {
+	var consents []*model.UserConsent
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("updated_at DESC").Find(&consents).Error
+	return consents, err
+}
+
+func (r *consentRepository) Delete(ctx context.Context, userID, clientID string) error {
+	result := r.db.WithContext(ctx).Unscoped().Where("user_id = ? AND client_id = ?", userID, clientID).Delete(&model.UserConsent{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrConsentNotFound
+	}
+	return nil
+}
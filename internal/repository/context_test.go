@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// blockingDriver 查询一直阻塞直到上下文结束的数据库驱动，模拟耗时查询
+type blockingDriver struct {
+	started chan struct{}
+}
+
+func (d *blockingDriver) Open(name string) (driver.Conn, error) {
+	return &blockingConn{started: d.started}, nil
+}
+
+type blockingConn struct {
+	started chan struct{}
+}
+
+func (c *blockingConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("不支持预编译")
+}
+
+func (c *blockingConn) Close() error { return nil }
+
+func (c *blockingConn) Begin() (driver.Tx, error) {
+	return blockingTx{}, nil
+}
+
+// blockingTx 空事务，事务内的语句同样阻塞
+type blockingTx struct{}
+
+func (blockingTx) Commit() error   { return nil }
+func (blockingTx) Rollback() error { return nil }
+
+func (c *blockingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.started <- struct{}{}
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (c *blockingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.started <- struct{}{}
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+// blockingDrivers 已注册的阻塞驱动数量，用于生成唯一的驱动名
+var blockingDrivers atomic.Int64
+
+// setupBlockingDB 创建查询会阻塞的 GORM 实例，返回查询开始的通知
+func setupBlockingDB(t *testing.T) (*gorm.DB, <-chan struct{}) {
+	started := make(chan struct{}, 1)
+	name := fmt.Sprintf("blocking-%d", blockingDrivers.Add(1))
+	sql.Register(name, &blockingDriver{started: started})
+
+	sqlDB, err := sql.Open(name, "")
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		Logger:                 logger.Discard,
+		SkipDefaultTransaction: true,
+	})
+	require.NoError(t, err)
+	return db, started
+}
+
+func TestRepository_CancelledContextAbortsQuery(t *testing.T) {
+	db, started := setupBlockingDB(t)
+	repo := NewUserRepository(db)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := repo.GetByID(ctx, "user-1")
+		done <- err
+	}()
+
+	// 查询已发出后取消请求上下文
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("查询未开始")
+	}
+	cancel()
+
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(5 * time.Second):
+		t.Fatal("取消上下文后查询未返回")
+	}
+}
+
+func TestRepository_ContextDeadlineAbortsWrite(t *testing.T) {
+	db, started := setupBlockingDB(t)
+	repo := NewOrganizationRepository(db)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := repo.Delete(ctx, "org-1")
+	select {
+	case <-started:
+	default:
+		t.Fatal("写操作未发出")
+	}
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// 各数据库驱动的唯一约束冲突错误码
+const (
+	pgUniqueViolation   = "23505" // PostgreSQL unique_violation
+	mysqlDuplicateEntry = 1062    // MySQL ER_DUP_ENTRY
+)
+
+// sqliteUniqueFailed SQLite 唯一约束冲突的错误消息前缀
+// 按消息匹配而不引用 cgo 驱动包的错误类型，PostgreSQL、MySQL 部署无需启用 CGO
+const sqliteUniqueFailed = "UNIQUE constraint failed: "
+
+// uniqueViolation 判断 err 是否为唯一约束冲突，返回冲突的约束（索引）名，无法获取时为空
+// 并发请求都通过了存在性检查后同时写入时，由数据库唯一索引兜底
+func uniqueViolation(err error) (string, bool) {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.ConstraintName, pgErr.Code == pgUniqueViolation
+	}
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		if mysqlErr.Number != mysqlDuplicateEntry {
+			return "", false
+		}
+		// 消息格式：Duplicate entry 'x' for key 'users.idx_users_email'
+		key := mysqlErr.Message
+		if i := strings.LastIndex(key, "for key "); i >= 0 {
+			key = strings.Trim(key[i+len("for key "):], "'`")
+		}
+		return key, true
+	}
+
+	// 消息格式：UNIQUE constraint failed: users.email，只能获取到列名
+	if err != nil {
+		msg := err.Error()
+		if i := strings.Index(msg, sqliteUniqueFailed); i >= 0 {
+			return msg[i+len(sqliteUniqueFailed):], true
+		}
+	}
+
+	return "", errors.Is(err, gorm.ErrDuplicatedKey)
+}
+
+// translateUniqueViolation 将唯一约束冲突转换为 domainErr，其它错误原样返回
+func translateUniqueViolation(err, domainErr error) error {
+	if _, ok := uniqueViolation(err); ok {
+		return domainErr
+	}
+	return err
+}
@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pu-ac-cn/uac-backend/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// duplicateDriver 模拟并发写入的数据库驱动：存在性检查总是返回 0，INSERT 返回唯一约束冲突
+type duplicateDriver struct {
+	err error
+}
+
+func (d *duplicateDriver) Open(name string) (driver.Conn, error) {
+	return &duplicateConn{err: d.err}, nil
+}
+
+type duplicateConn struct {
+	err error
+}
+
+func (c *duplicateConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("不支持预编译")
+}
+
+func (c *duplicateConn) Close() error { return nil }
+
+func (c *duplicateConn) Begin() (driver.Tx, error) { return recordingTx{}, nil }
+
+func (c *duplicateConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if strings.HasPrefix(query, "INSERT") {
+		return nil, c.err
+	}
+	return &countRow{}, nil
+}
+
+func (c *duplicateConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return nil, c.err
+}
+
+// countRow 返回一行 count 为 0 的结果
+type countRow struct {
+	done bool
+}
+
+func (r *countRow) Columns() []string { return []string{"count"} }
+func (r *countRow) Close() error      { return nil }
+
+func (r *countRow) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = int64(0)
+	return nil
+}
+
+// setupDuplicateDB 创建写入时返回 err 的 GORM 实例，dialect 为 postgres 或 mysql
+func setupDuplicateDB(t *testing.T, dialect string, err error) *gorm.DB {
+	name := fmt.Sprintf("duplicate-%d", blockingDrivers.Add(1))
+	sql.Register(name, &duplicateDriver{err: err})
+	sqlDB, openErr := sql.Open(name, "")
+	require.NoError(t, openErr)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	dialector := postgres.New(postgres.Config{Conn: sqlDB})
+	if dialect == "mysql" {
+		dialector = mysql.New(mysql.Config{Conn: sqlDB, SkipInitializeWithVersion: true})
+	}
+	db, openErr := gorm.Open(dialector, &gorm.Config{
+		Logger:                 logger.Discard,
+		SkipDefaultTransaction: true,
+	})
+	require.NoError(t, openErr)
+	return db
+}
+
+func pgUniqueError(constraint string) error {
+	return &pgconn.PgError{Code: "23505", ConstraintName: constraint}
+}
+
+func mysqlUniqueError(key string) error {
+	return &mysqldriver.MySQLError{Number: 1062, Message: "Duplicate entry 'x' for key '" + key + "'"}
+}
+
+func TestUserRepository_Create_UniqueViolation(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect string
+		err     error
+		want    error
+	}{
+		{"postgres username", "postgres", pgUniqueError("idx_users_username"), ErrUserUsernameExists},
+		{"postgres email", "postgres", pgUniqueError("idx_users_email"), ErrUserEmailExists},
+		{"mysql username", "mysql", mysqlUniqueError("users.idx_users_username"), ErrUserUsernameExists},
+		{"mysql email", "mysql", mysqlUniqueError("users.idx_users_email"), ErrUserEmailExists},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := NewUserRepository(setupDuplicateDB(t, tt.dialect, tt.err))
+			err := repo.Create(context.Background(), &model.User{Username: "alice", Email: "alice@example.com"})
+			assert.ErrorIs(t, err, tt.want)
+		})
+	}
+}
+
+func TestOrganizationRepository_Create_UniqueViolation(t *testing.T) {
+	for _, dialect := range []string{"postgres", "mysql"} {
+		t.Run(dialect, func(t *testing.T) {
+			err := pgUniqueError("idx_organizations_slug")
+			if dialect == "mysql" {
+				err = mysqlUniqueError("organizations.idx_organizations_slug")
+			}
+			repo := NewOrganizationRepository(setupDuplicateDB(t, dialect, err))
+			assert.ErrorIs(t, repo.Create(context.Background(), &model.Organization{Name: "Acme", Slug: "acme"}), ErrOrgSlugExists)
+		})
+	}
+}
+
+func TestApplicationRepository_Create_UniqueViolation(t *testing.T) {
+	repo := NewApplicationRepository(setupDuplicateDB(t, "postgres", pgUniqueError("idx_applications_client_id")))
+	err := repo.Create(context.Background(), &model.Application{Name: "app", ClientID: "client-1"})
+	assert.ErrorIs(t, err, ErrAppClientIDExists)
+}
+
+func TestUniqueViolation_OtherErrorsPassThrough(t *testing.T) {
+	fkErr := &pgconn.PgError{Code: "23503", ConstraintName: "fk_users_org"}
+	repo := NewUserRepository(setupDuplicateDB(t, "postgres", fkErr))
+	err := repo.Create(context.Background(), &model.User{Username: "alice", Email: "alice@example.com"})
+	assert.ErrorIs(t, err, fkErr)
+
+	_, ok := uniqueViolation(&mysqldriver.MySQLError{Number: 1452})
+	assert.False(t, ok)
+	_, ok = uniqueViolation(gorm.ErrDuplicatedKey)
+	assert.True(t, ok)
+	_, ok = uniqueViolation(errors.New("FOREIGN KEY constraint failed"))
+	assert.False(t, ok)
+}
+
+func TestUniqueViolation_SQLiteMessage(t *testing.T) {
+	column, ok := uniqueViolation(fmt.Errorf("insert: %w", errors.New("UNIQUE constraint failed: users.email")))
+	assert.True(t, ok)
+	assert.Equal(t, "users.email", column)
+	assert.ErrorIs(t, translateUserUniqueViolation(errors.New("UNIQUE constraint failed: users.username")), ErrUserUsernameExists)
+}
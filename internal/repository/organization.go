@@ -23,10 +23,15 @@ type OrganizationRepository interface {
 	GetBySlug(ctx context.Context, slug string) (*model.Organization, error)
 	Update(ctx context.Context, org *model.Organization) error
 	Delete(ctx context.Context, id string) error
+	// DeleteCascade 在事务中删除组织及其应用、角色和用户绑定
+	DeleteCascade(ctx context.Context, id string) error
 	List(ctx context.Context, filter *OrgFilter, page *Pagination) ([]*model.Organization, int64, error)
 	ExistsBySlug(ctx context.Context, slug string) (bool, error)
 }
 
+// orgSortColumns 组织列表允许排序的列
+var orgSortColumns = []string{"created_at", "updated_at", "name", "slug", "status"}
+
 // OrgFilter 组织查询过滤器
 type OrgFilter struct {
 	TenantID string // 租户 ID
@@ -34,12 +39,6 @@ type OrgFilter struct {
 	Status   string // 状态
 }
 
-// Pagination 分页参数
-type Pagination struct {
-	Page     int // 页码，从 1 开始
-	PageSize int // 每页数量
-}
-
 // organizationRepository 组织数据访问实现
 type organizationRepository struct {
 	db *gorm.DB
@@ -61,7 +60,7 @@ func (r *organizationRepository) Create(ctx context.Context, org *model.Organiza
 		return ErrOrgSlugExists
 	}
 
-	return r.db.WithContext(ctx).Create(org).Error
+	return translateUniqueViolation(r.db.WithContext(ctx).Create(org).Error, ErrOrgSlugExists)
 }
 
 // GetByID 根据 ID 获取组织
@@ -97,6 +96,8 @@ func (r *organizationRepository) Update(ctx context.Context, org *model.Organiza
 		"description": org.Description,
 		"branding":    org.Branding,
 		"status":      org.Status,
+		"max_apps":    org.MaxApps,
+		"max_users":   org.MaxUsers,
 	})
 	if result.Error != nil {
 		return result.Error
@@ -107,16 +108,45 @@ func (r *organizationRepository) Update(ctx context.Context, org *model.Organiza
 	return nil
 }
 
-// Delete 删除组织（软删除）
+// Delete 删除组织（软删除），组织级角色和权限一并删除
 func (r *organizationRepository) Delete(ctx context.Context, id string) error {
-	result := r.db.WithContext(ctx).Where("id = ?", id).Delete(&model.Organization{})
-	if result.Error != nil {
-		return result.Error
-	}
-	if result.RowsAffected == 0 {
-		return ErrOrgNotFound
-	}
-	return nil
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ?", id).Delete(&model.Organization{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrOrgNotFound
+		}
+		return deleteOrgScopedRBAC(tx, id)
+	})
+}
+
+// DeleteCascade 级联删除组织，组织级角色和权限及其用户分配、权限关联一并删除
+// 任一步失败整体回滚；组织不存在时返回 ErrOrgNotFound
+func (r *organizationRepository) DeleteCascade(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("org_id = ?", id).Delete(&model.Application{}).Error; err != nil {
+			return err
+		}
+
+		if err := deleteOrgScopedRBAC(tx, id); err != nil {
+			return err
+		}
+
+		if err := tx.Where("org_id = ?", id).Delete(&model.UserOrgBinding{}).Error; err != nil {
+			return err
+		}
+
+		result := tx.Where("id = ?", id).Delete(&model.Organization{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrOrgNotFound
+		}
+		return nil
+	})
 }
 
 // List 查询组织列表
@@ -150,8 +180,8 @@ func (r *organizationRepository) List(ctx context.Context, filter *OrgFilter, pa
 		query = query.Offset(offset).Limit(page.PageSize)
 	}
 
-	// 按创建时间倒序
-	if err := query.Order("created_at DESC").Find(&orgs).Error; err != nil {
+	// 默认按创建时间倒序
+	if err := query.Order(orderBy(page, orgSortColumns)).Find(&orgs).Error; err != nil {
 		return nil, 0, err
 	}
 
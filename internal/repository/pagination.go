@@ -0,0 +1,33 @@
+package repository
+
+import "strings"
+
+// Pagination 分页参数
+type Pagination struct {
+	Page      int    // 页码，从 1 开始
+	PageSize  int    // 每页数量
+	SortBy    string // 排序列，须在仓库的白名单内，否则使用默认排序
+	SortOrder string // 排序方向：asc 或 desc，默认 desc
+}
+
+// defaultSortColumn 默认排序列
+const defaultSortColumn = "created_at"
+
+// orderBy 生成排序子句
+// 列名直接拼入 SQL，只接受白名单中的列；非法列名回退到按创建时间倒序
+func orderBy(page *Pagination, allowed []string) string {
+	if page == nil || page.SortBy == "" {
+		return defaultSortColumn + " DESC"
+	}
+
+	for _, column := range allowed {
+		if column != page.SortBy {
+			continue
+		}
+		if strings.EqualFold(page.SortOrder, "asc") {
+			return column + " ASC"
+		}
+		return column + " DESC"
+	}
+	return defaultSortColumn + " DESC"
+}
@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrderBy(t *testing.T) {
+	tests := []struct {
+		name string
+		page *Pagination
+		want string
+	}{
+		{"未分页", nil, "created_at DESC"},
+		{"未指定排序", &Pagination{Page: 1, PageSize: 20}, "created_at DESC"},
+		{"白名单列升序", &Pagination{SortBy: "username", SortOrder: "asc"}, "username ASC"},
+		{"方向不区分大小写", &Pagination{SortBy: "status", SortOrder: "ASC"}, "status ASC"},
+		{"默认降序", &Pagination{SortBy: "email"}, "email DESC"},
+		{"非法方向回退降序", &Pagination{SortBy: "email", SortOrder: "sideways"}, "email DESC"},
+		{"非法列名", &Pagination{SortBy: "password_hash", SortOrder: "asc"}, "created_at DESC"},
+		{"SQL 注入", &Pagination{SortBy: "username; DROP TABLE users; --"}, "created_at DESC"},
+		{"列名大小写不同", &Pagination{SortBy: "USERNAME"}, "created_at DESC"},
+		{"表达式", &Pagination{SortBy: "(CASE WHEN 1=1 THEN username END)"}, "created_at DESC"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, orderBy(tt.page, userSortColumns))
+		})
+	}
+}
+
+func TestOrderBy_PerRepositoryWhitelist(t *testing.T) {
+	// 组织没有 username 列，不能借用户白名单排序
+	assert.Equal(t, "created_at DESC", orderBy(&Pagination{SortBy: "username"}, orgSortColumns))
+	assert.Equal(t, "name ASC", orderBy(&Pagination{SortBy: "name", SortOrder: "asc"}, orgSortColumns))
+	assert.Equal(t, "created_at DESC", orderBy(&Pagination{SortBy: "name"}, userSortColumns))
+}
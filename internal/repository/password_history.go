@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/pu-ac-cn/uac-backend/internal/model"
+	"gorm.io/gorm"
+)
+
+// PasswordHistoryRepository 密码历史数据访问接口
+type PasswordHistoryRepository interface {
+	// Add 记录用户新设置的密码哈希，只保留最近 keep 条，更早的记录被删除
+	Add(ctx context.Context, userID, passwordHash string, keep int) error
+	// ListRecent 按时间倒序返回用户最近的 limit 条密码历史
+	ListRecent(ctx context.Context, userID string, limit int) ([]*model.PasswordHistory, error)
+}
+
+// passwordHistoryRepository 密码历史数据访问实现
+type passwordHistoryRepository struct {
+	db *gorm.DB
+}
+
+// NewPasswordHistoryRepository 创建密码历史数据访问实例
+func NewPasswordHistoryRepository(db *gorm.DB) PasswordHistoryRepository {
+	return &passwordHistoryRepository{db: db}
+}
+
+func (r *passwordHistoryRepository) Add(ctx context.Context, userID, passwordHash string, keep int) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&model.PasswordHistory{UserID: userID, PasswordHash: passwordHash}).Error; err != nil {
+			return err
+		}
+
+		// 淘汰超出保留数量的旧记录；每次写入后最多多出一条，直接取全部 ID 即可
+		var ids []string
+		if err := tx.Model(&model.PasswordHistory{}).
+			Where("user_id = ?", userID).
+			Order("created_at DESC").Order("id DESC").
+			Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) <= keep {
+			return nil
+		}
+		return tx.Where("id IN ?", ids[keep:]).Delete(&model.PasswordHistory{}).Error
+	})
+}
+
+func (r *passwordHistoryRepository) ListRecent(ctx context.Context, userID string, limit int) ([]*model.PasswordHistory, error) {
+	var history []*model.PasswordHistory
+	err := r.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("created_at DESC").Order("id DESC").
+		Limit(limit).
+		Find(&history).Error
+	return history, err
+}
@@ -3,9 +3,17 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/pu-ac-cn/uac-backend/internal/model"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RBAC 仓库错误
+var (
+	ErrPermissionNotFound = errors.New("权限不存在")
 )
 
 // RoleRepository 角色仓库接口
@@ -18,7 +26,13 @@ type RoleRepository interface {
 	List(ctx context.Context, orgID string, page *Pagination) ([]*model.Role, int64, error)
 	AddPermissions(ctx context.Context, roleID string, permissionIDs []string) error
 	RemovePermissions(ctx context.Context, roleID string, permissionIDs []string) error
+	// SetPermissions 将角色的权限整体替换为 permissionIDs，任一权限不存在时返回 ErrPermissionNotFound
+	SetPermissions(ctx context.Context, roleID string, permissionIDs []string) error
+	// AddPermissionToRoles 在同一事务中将权限添加到多个角色，已存在的关联会被忽略
+	AddPermissionToRoles(ctx context.Context, permissionID string, roleIDs []string) error
 	GetPermissions(ctx context.Context, roleID string) ([]model.Permission, error)
+	// DeleteOrgScoped 删除组织级角色和权限，及其用户分配、应用分配和角色权限关联
+	DeleteOrgScoped(ctx context.Context, orgID string) error
 }
 
 // PermissionRepository 权限仓库接口
@@ -26,18 +40,42 @@ type PermissionRepository interface {
 	Create(ctx context.Context, perm *model.Permission) error
 	GetByID(ctx context.Context, id string) (*model.Permission, error)
 	GetByCode(ctx context.Context, code string) (*model.Permission, error)
+	Update(ctx context.Context, perm *model.Permission) error
 	Delete(ctx context.Context, id string) error
-	List(ctx context.Context, orgID string) ([]*model.Permission, error)
+	// List 查询权限列表，page 为 nil 时返回全部
+	List(ctx context.Context, filter *PermissionFilter, page *Pagination) ([]*model.Permission, int64, error)
 	BatchCreate(ctx context.Context, perms []model.Permission) error
 }
 
+// PermissionFilter 权限查询条件
+type PermissionFilter struct {
+	OrgID    string // 组织 ID，同时包含系统级权限
+	Resource string // 资源
+	Action   string // 操作
+}
+
 // UserRoleRepository 用户角色仓库接口
 type UserRoleRepository interface {
 	Assign(ctx context.Context, userID, roleID string) error
+	// AssignWithExpiry 分配到 expiresAt 为止有效的角色，nil 表示永久有效
+	// 已存在的（包括已过期的）分配会被覆盖
+	AssignWithExpiry(ctx context.Context, userID, roleID string, expiresAt *time.Time) error
 	Revoke(ctx context.Context, userID, roleID string) error
+	// GetUserRoles / GetRoleUsers / HasRole 均忽略已过期的分配
 	GetUserRoles(ctx context.Context, userID string) ([]*model.Role, error)
 	GetRoleUsers(ctx context.Context, roleID string, page *Pagination) ([]*model.User, int64, error)
 	HasRole(ctx context.Context, userID, roleCode string) (bool, error)
+	// ListUserIDsByRole / ListUserIDsByOrg 返回持有指定角色或任一组织级角色的用户 ID，包含已过期的分配
+	ListUserIDsByRole(ctx context.Context, roleID string) ([]string, error)
+	ListUserIDsByOrg(ctx context.Context, orgID string) ([]string, error)
+}
+
+// AppRoleRepository 应用角色仓库接口
+type AppRoleRepository interface {
+	Assign(ctx context.Context, appID, roleID string) error
+	Revoke(ctx context.Context, appID, roleID string) error
+	// GetAppRoles 获取应用的角色，包含角色的权限
+	GetAppRoles(ctx context.Context, appID string) ([]*model.Role, error)
 }
 
 // roleRepository 角色仓库实现
@@ -78,6 +116,42 @@ func (r *roleRepository) Delete(ctx context.Context, id string) error {
 	return r.db.WithContext(ctx).Delete(&model.Role{}, "id = ?", id).Error
 }
 
+func (r *roleRepository) DeleteOrgScoped(ctx context.Context, orgID string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return deleteOrgScopedRBAC(tx, orgID)
+	})
+}
+
+// deleteOrgScopedRBAC 在事务中删除组织级角色和权限
+// orgID 为空时不做任何操作，避免误删 org_id 为空的系统级角色和权限
+func deleteOrgScopedRBAC(tx *gorm.DB, orgID string) error {
+	if orgID == "" {
+		return nil
+	}
+
+	roleIDs := tx.Session(&gorm.Session{NewDB: true}).Model(&model.Role{}).Select("id").Where("org_id = ?", orgID)
+	if err := tx.Where("role_id IN (?)", roleIDs).Delete(&model.UserRole{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("role_id IN (?)", roleIDs).Delete(&model.ApplicationRole{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("role_id IN (?)", roleIDs).Delete(&model.RolePermission{}).Error; err != nil {
+		return err
+	}
+
+	// 组织级权限可能被系统级角色引用，先删除关联
+	permIDs := tx.Session(&gorm.Session{NewDB: true}).Model(&model.Permission{}).Select("id").Where("org_id = ?", orgID)
+	if err := tx.Where("permission_id IN (?)", permIDs).Delete(&model.RolePermission{}).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Where("org_id = ?", orgID).Delete(&model.Role{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("org_id = ?", orgID).Delete(&model.Permission{}).Error
+}
+
 func (r *roleRepository) List(ctx context.Context, orgID string, page *Pagination) ([]*model.Role, int64, error) {
 	var roles []*model.Role
 	var total int64
@@ -130,6 +204,42 @@ func (r *roleRepository) RemovePermissions(ctx context.Context, roleID string, p
 	return r.db.WithContext(ctx).Model(&role).Association("Permissions").Delete(permissions)
 }
 
+func (r *roleRepository) SetPermissions(ctx context.Context, roleID string, permissionIDs []string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var role model.Role
+		if err := tx.First(&role, "id = ?", roleID).Error; err != nil {
+			return err
+		}
+
+		permissions := make([]model.Permission, 0, len(permissionIDs))
+		if len(permissionIDs) > 0 {
+			if err := tx.Find(&permissions, "id IN ?", permissionIDs).Error; err != nil {
+				return err
+			}
+			if len(permissions) != len(permissionIDs) {
+				return ErrPermissionNotFound
+			}
+		}
+
+		if len(permissions) == 0 {
+			return tx.Model(&role).Association("Permissions").Clear()
+		}
+		return tx.Model(&role).Association("Permissions").Replace(permissions)
+	})
+}
+
+func (r *roleRepository) AddPermissionToRoles(ctx context.Context, permissionID string, roleIDs []string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, roleID := range roleIDs {
+			link := &model.RolePermission{RoleID: roleID, PermissionID: permissionID}
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(link).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
 func (r *roleRepository) GetPermissions(ctx context.Context, roleID string) ([]model.Permission, error) {
 	var role model.Role
 	if err := r.db.WithContext(ctx).Preload("Permissions").First(&role, "id = ?", roleID).Error; err != nil {
@@ -168,20 +278,50 @@ func (r *permissionRepository) GetByCode(ctx context.Context, code string) (*mod
 	return &perm, nil
 }
 
+func (r *permissionRepository) Update(ctx context.Context, perm *model.Permission) error {
+	return r.db.WithContext(ctx).Save(perm).Error
+}
+
 func (r *permissionRepository) Delete(ctx context.Context, id string) error {
 	return r.db.WithContext(ctx).Delete(&model.Permission{}, "id = ?", id).Error
 }
 
-func (r *permissionRepository) List(ctx context.Context, orgID string) ([]*model.Permission, error) {
+func (r *permissionRepository) List(ctx context.Context, filter *PermissionFilter, page *Pagination) ([]*model.Permission, int64, error) {
 	var perms []*model.Permission
-	query := r.db.WithContext(ctx).Model(&model.Permission{})
-	if orgID != "" {
-		query = query.Where("org_id = ? OR org_id = ''", orgID)
+	var total int64
+
+	query := applyPermissionFilter(r.db.WithContext(ctx).Model(&model.Permission{}), filter)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
 	}
+
+	// 按唯一的权限代码排序，保证分页结果稳定
+	query = query.Order("code ASC")
+	if page != nil {
+		query = query.Offset((page.Page - 1) * page.PageSize).Limit(page.PageSize)
+	}
+
 	if err := query.Find(&perms).Error; err != nil {
-		return nil, err
+		return nil, 0, err
 	}
-	return perms, nil
+	return perms, total, nil
+}
+
+// applyPermissionFilter 按过滤条件拼接权限查询的 WHERE 条件
+func applyPermissionFilter(query *gorm.DB, filter *PermissionFilter) *gorm.DB {
+	if filter == nil {
+		return query
+	}
+	if filter.OrgID != "" {
+		query = query.Where("org_id = ? OR org_id = ''", filter.OrgID)
+	}
+	if filter.Resource != "" {
+		query = query.Where("resource = ?", filter.Resource)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	return query
 }
 
 func (r *permissionRepository) BatchCreate(ctx context.Context, perms []model.Permission) error {
@@ -199,20 +339,36 @@ func NewUserRoleRepository(db *gorm.DB) UserRoleRepository {
 }
 
 func (r *userRoleRepository) Assign(ctx context.Context, userID, roleID string) error {
+	return r.AssignWithExpiry(ctx, userID, roleID, nil)
+}
+
+// AssignWithExpiry 通过 (user_id, role_id) 唯一索引 upsert，重新分配时覆盖到期时间
+func (r *userRoleRepository) AssignWithExpiry(ctx context.Context, userID, roleID string, expiresAt *time.Time) error {
 	userRole := &model.UserRole{
-		UserID: userID,
-		RoleID: roleID,
+		UserID:    userID,
+		RoleID:    roleID,
+		ExpiresAt: expiresAt,
 	}
-	return r.db.WithContext(ctx).Create(userRole).Error
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "role_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"expires_at", "updated_at", "deleted_at"}),
+	}).Create(userRole).Error
 }
 
+// Revoke 物理删除用户角色，避免软删除的记录占用唯一索引
 func (r *userRoleRepository) Revoke(ctx context.Context, userID, roleID string) error {
-	return r.db.WithContext(ctx).Where("user_id = ? AND role_id = ?", userID, roleID).Delete(&model.UserRole{}).Error
+	return r.db.WithContext(ctx).Unscoped().Where("user_id = ? AND role_id = ?", userID, roleID).Delete(&model.UserRole{}).Error
+}
+
+// unexpiredUserRoles 过滤掉 now 时已过期的用户角色
+func unexpiredUserRoles(query *gorm.DB, now time.Time) *gorm.DB {
+	return query.Where("user_roles.expires_at IS NULL OR user_roles.expires_at > ?", now)
 }
 
 func (r *userRoleRepository) GetUserRoles(ctx context.Context, userID string) ([]*model.Role, error) {
 	var userRoles []model.UserRole
-	if err := r.db.WithContext(ctx).Preload("Role.Permissions").Where("user_id = ?", userID).Find(&userRoles).Error; err != nil {
+	query := unexpiredUserRoles(r.db.WithContext(ctx).Preload("Role.Permissions").Where("user_id = ?", userID), time.Now())
+	if err := query.Find(&userRoles).Error; err != nil {
 		return nil, err
 	}
 
@@ -226,13 +382,14 @@ func (r *userRoleRepository) GetUserRoles(ctx context.Context, userID string) ([
 }
 
 func (r *userRoleRepository) GetRoleUsers(ctx context.Context, roleID string, page *Pagination) ([]*model.User, int64, error) {
+	now := time.Now()
 	var total int64
-	if err := r.db.WithContext(ctx).Model(&model.UserRole{}).Where("role_id = ?", roleID).Count(&total).Error; err != nil {
+	if err := unexpiredUserRoles(r.db.WithContext(ctx).Model(&model.UserRole{}).Where("role_id = ?", roleID), now).Count(&total).Error; err != nil {
 		return nil, 0, err
 	}
 
 	var userRoles []model.UserRole
-	query := r.db.WithContext(ctx).Preload("User").Where("role_id = ?", roleID)
+	query := unexpiredUserRoles(r.db.WithContext(ctx).Preload("User").Where("role_id = ?", roleID), now)
 	if page != nil {
 		query = query.Offset((page.Page - 1) * page.PageSize).Limit(page.PageSize)
 	}
@@ -251,9 +408,59 @@ func (r *userRoleRepository) GetRoleUsers(ctx context.Context, roleID string, pa
 
 func (r *userRoleRepository) HasRole(ctx context.Context, userID, roleCode string) (bool, error) {
 	var count int64
-	err := r.db.WithContext(ctx).Model(&model.UserRole{}).
+	query := r.db.WithContext(ctx).Model(&model.UserRole{}).
 		Joins("JOIN roles ON roles.id = user_roles.role_id").
-		Where("user_roles.user_id = ? AND roles.code = ?", userID, roleCode).
-		Count(&count).Error
+		Where("user_roles.user_id = ? AND roles.code = ?", userID, roleCode)
+	err := unexpiredUserRoles(query, time.Now()).Count(&count).Error
 	return count > 0, err
 }
+
+func (r *userRoleRepository) ListUserIDsByRole(ctx context.Context, roleID string) ([]string, error) {
+	var ids []string
+	err := r.db.WithContext(ctx).Model(&model.UserRole{}).Where("role_id = ?", roleID).Distinct().Pluck("user_id", &ids).Error
+	return ids, err
+}
+
+func (r *userRoleRepository) ListUserIDsByOrg(ctx context.Context, orgID string) ([]string, error) {
+	var ids []string
+	roleIDs := r.db.WithContext(ctx).Model(&model.Role{}).Select("id").Where("org_id = ?", orgID)
+	err := r.db.WithContext(ctx).Model(&model.UserRole{}).Where("role_id IN (?)", roleIDs).Distinct().Pluck("user_id", &ids).Error
+	return ids, err
+}
+
+// appRoleRepository 应用角色仓库实现
+type appRoleRepository struct {
+	db *gorm.DB
+}
+
+// NewAppRoleRepository 创建应用角色仓库
+func NewAppRoleRepository(db *gorm.DB) AppRoleRepository {
+	return &appRoleRepository{db: db}
+}
+
+func (r *appRoleRepository) Assign(ctx context.Context, appID, roleID string) error {
+	appRole := &model.ApplicationRole{
+		AppID:  appID,
+		RoleID: roleID,
+	}
+	return r.db.WithContext(ctx).Create(appRole).Error
+}
+
+func (r *appRoleRepository) Revoke(ctx context.Context, appID, roleID string) error {
+	return r.db.WithContext(ctx).Where("app_id = ? AND role_id = ?", appID, roleID).Delete(&model.ApplicationRole{}).Error
+}
+
+func (r *appRoleRepository) GetAppRoles(ctx context.Context, appID string) ([]*model.Role, error) {
+	var appRoles []model.ApplicationRole
+	if err := r.db.WithContext(ctx).Preload("Role.Permissions").Where("app_id = ?", appID).Find(&appRoles).Error; err != nil {
+		return nil, err
+	}
+
+	roles := make([]*model.Role, 0, len(appRoles))
+	for _, ar := range appRoles {
+		if ar.Role != nil {
+			roles = append(roles, ar.Role)
+		}
+	}
+	return roles, nil
+}
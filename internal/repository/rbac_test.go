@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pu-ac-cn/uac-backend/internal/model"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// permissionListSQL 生成按过滤条件查询权限列表的 SQL，不实际执行
+func permissionListSQL(t *testing.T, filter *PermissionFilter) string {
+	db, _ := setupBlockingDB(t)
+	return db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		var perms []*model.Permission
+		return applyPermissionFilter(tx.Model(&model.Permission{}), filter).Find(&perms)
+	})
+}
+
+func TestApplyPermissionFilter_NoFilter(t *testing.T) {
+	sql := permissionListSQL(t, nil)
+	assert.NotContains(t, sql, "resource =")
+	assert.NotContains(t, sql, "org_id =")
+}
+
+func TestApplyPermissionFilter_ResourceAndAction(t *testing.T) {
+	sql := permissionListSQL(t, &PermissionFilter{OrgID: "org-1", Resource: "user", Action: "read"})
+	assert.Contains(t, sql, `(org_id = 'org-1' OR org_id = '')`)
+	assert.Contains(t, sql, `resource = 'user'`)
+	assert.Contains(t, sql, `action = 'read'`)
+}
+
+func TestUnexpiredUserRoles(t *testing.T) {
+	db, _ := setupBlockingDB(t)
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		var userRoles []model.UserRole
+		return unexpiredUserRoles(tx.Where("user_id = ?", "user-1"), now).Find(&userRoles)
+	})
+	// 过期条件需要整体加括号，不能与 user_id 条件的 AND 混在一起
+	assert.Contains(t, sql, `user_id = 'user-1' AND (user_roles.expires_at IS NULL OR user_roles.expires_at > '2026-01-02 03:04:05')`)
+	assert.Contains(t, sql, `"user_roles"."deleted_at" IS NULL`)
+}
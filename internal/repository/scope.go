@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/pu-ac-cn/uac-backend/internal/model"
+	"gorm.io/gorm"
+)
+
+// ErrScopeNotFound scope 不存在
+var ErrScopeNotFound = errors.New("scope 不存在")
+
+// ScopeRepository scope 说明数据访问接口
+type ScopeRepository interface {
+	Create(ctx context.Context, scope *model.Scope) error
+	GetByCode(ctx context.Context, code string) (*model.Scope, error)
+	// ListByCodes 查询指定代码的 scope，不存在的代码被忽略
+	ListByCodes(ctx context.Context, codes []string) ([]*model.Scope, error)
+}
+
+type scopeRepository struct {
+	db *gorm.DB
+}
+
+// NewScopeRepository 创建 scope 数据访问实例
+func NewScopeRepository(db *gorm.DB) ScopeRepository {
+	return &scopeRepository{db: db}
+}
+
+func (r *scopeRepository) Create(ctx context.Context, scope *model.Scope) error {
+	return r.db.WithContext(ctx).Create(scope).Error
+}
+
+func (r *scopeRepository) GetByCode(ctx context.Context, code string) (*model.Scope, error) {
+	var scope model.Scope
+	err := r.db.WithContext(ctx).Where("code = ?", code).First(&scope).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrScopeNotFound
+		}
+		return nil, err
+	}
+	return &scope, nil
+}
+
+func (r *scopeRepository) ListByCodes(ctx context.Context, codes []string) ([]*model.Scope, error) {
+	var scopes []*model.Scope
+	if len(codes) == 0 {
+		return scopes, nil
+	}
+	err := r.db.WithContext(ctx).Where("code IN ?", codes).Find(&scopes).Error
+	return scopes, err
+}
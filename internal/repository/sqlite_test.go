@@ -0,0 +1,392 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pu-ac-cn/uac-backend/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// setupSQLiteDB 创建已迁移全部模型的 SQLite 内存数据库，用于执行真实 SQL
+// 每个测试使用独立的命名内存库，测试结束后随连接关闭销毁
+func setupSQLiteDB(t *testing.T) *gorm.DB {
+	name := fmt.Sprintf("sqlite-%d", blockingDrivers.Add(1))
+	db, err := gorm.Open(sqlite.Open("file:"+name+"?mode=memory&cache=shared&_foreign_keys=1"), &gorm.Config{
+		Logger:                 logger.Discard,
+		SkipDefaultTransaction: true,
+	})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	require.NoError(t, db.AutoMigrate(
+		&model.User{},
+		&model.Organization{},
+		&model.Application{},
+		&model.UserOrgBinding{},
+		&model.Role{},
+		&model.Permission{},
+		&model.UserRole{},
+		&model.ApplicationRole{},
+		&model.RolePermission{},
+		&model.AuditLog{},
+		&model.PasswordHistory{},
+		&model.Scope{},
+		&model.UserConsent{},
+	))
+	return db
+}
+
+func TestUserRepository_SQLite_UniqueViolation(t *testing.T) {
+	repo := NewUserRepository(setupSQLiteDB(t))
+	ctx := context.Background()
+
+	require.NoError(t, repo.Create(ctx, &model.User{Username: "alice", Email: "alice@example.com"}))
+	assert.ErrorIs(t, repo.Create(ctx, &model.User{Username: "alice", Email: "other@example.com"}), ErrUserUsernameExists)
+	assert.ErrorIs(t, repo.Create(ctx, &model.User{Username: "bob", Email: "alice@example.com"}), ErrUserEmailExists)
+}
+
+func TestUserRepository_SQLite_ConcurrentCreate(t *testing.T) {
+	repo := NewUserRepository(setupSQLiteDB(t))
+	ctx := context.Background()
+
+	const n = 10
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = repo.Create(ctx, &model.User{Username: "alice", Email: fmt.Sprintf("alice%d@example.com", i)})
+		}(i)
+	}
+	wg.Wait()
+
+	// 同名用户并发创建只有一个成功，其余由唯一索引拒绝
+	succeeded := 0
+	for _, err := range errs {
+		if err == nil {
+			succeeded++
+			continue
+		}
+		assert.ErrorIs(t, err, ErrUserUsernameExists)
+	}
+	assert.Equal(t, 1, succeeded)
+}
+
+func TestUserRepository_SQLite_SoftDeleteAndRestore(t *testing.T) {
+	repo := NewUserRepository(setupSQLiteDB(t))
+	ctx := context.Background()
+
+	alice := &model.User{Username: "alice", Email: "alice@example.com"}
+	require.NoError(t, repo.Create(ctx, alice))
+	require.NoError(t, repo.Create(ctx, &model.User{Username: "bob", Email: "bob@example.com"}))
+	require.NoError(t, repo.Delete(ctx, alice.ID))
+
+	_, err := repo.GetByID(ctx, alice.ID)
+	assert.ErrorIs(t, err, ErrUserNotFound)
+	_, err = repo.GetByUsername(ctx, "alice")
+	assert.ErrorIs(t, err, ErrUserNotFound)
+	_, total, err := repo.List(ctx, nil, nil)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, total)
+
+	// 数据仍保留，包含已删除时可以查到
+	list, total, err := repo.List(ctx, &UserFilter{Username: "alice", IncludeDeleted: true}, nil)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, total)
+	require.Len(t, list, 1)
+	assert.True(t, list[0].DeletedAt.Valid)
+
+	// 软删除的用户不再占用用户名和邮箱，恢复时与新用户冲突
+	newAlice := &model.User{Username: "alice", Email: "new@example.com"}
+	require.NoError(t, repo.Create(ctx, newAlice))
+	assert.ErrorIs(t, repo.Restore(ctx, alice.ID), ErrUserUsernameExists)
+	newAlice.Username = "alice2"
+	newAlice.Email = "alice@example.com"
+	require.NoError(t, repo.Update(ctx, newAlice))
+	assert.ErrorIs(t, repo.Restore(ctx, alice.ID), ErrUserEmailExists)
+	require.NoError(t, repo.Delete(ctx, newAlice.ID))
+
+	// 未删除的用户之间仍保持唯一
+	assert.ErrorIs(t, repo.Create(ctx, &model.User{Username: "bob", Email: "bob2@example.com"}), ErrUserUsernameExists)
+
+	require.NoError(t, repo.Restore(ctx, alice.ID))
+	restored, err := repo.GetByID(ctx, alice.ID)
+	require.NoError(t, err)
+	assert.False(t, restored.DeletedAt.Valid)
+
+	assert.ErrorIs(t, repo.Restore(ctx, alice.ID), ErrUserNotFound)
+	assert.ErrorIs(t, repo.Restore(ctx, "missing"), ErrUserNotFound)
+}
+
+func TestUserRepository_SQLite_DisableInactive(t *testing.T) {
+	db := setupSQLiteDB(t)
+	repo := NewUserRepository(db)
+	ctx := context.Background()
+	before := time.Now().Add(-90 * 24 * time.Hour)
+	longAgo := before.Add(-24 * time.Hour)
+
+	var users []*model.User
+	for _, name := range []string{"a", "b", "c"} {
+		user := &model.User{Username: name, Email: name + "@example.com", Status: model.StatusActive, LastLoginAt: &longAgo}
+		require.NoError(t, repo.Create(ctx, user))
+		users = append(users, user)
+	}
+
+	// 按 ID 分批列出
+	first, err := repo.ListInactive(ctx, before, "", 2)
+	require.NoError(t, err)
+	require.Len(t, first, 2)
+	rest, err := repo.ListInactive(ctx, before, first[1].ID, 2)
+	require.NoError(t, err)
+	require.Len(t, rest, 1)
+	assert.Greater(t, rest[0].ID, first[1].ID)
+
+	// 列出之后登录、改密的用户：条件不再满足，不被禁用，也不覆盖其他列
+	now := time.Now()
+	loggedIn := first[0]
+	_, err = repo.RecordLogin(ctx, loggedIn.ID, now)
+	require.NoError(t, err)
+	require.NoError(t, db.Model(&model.User{}).Where("id = ?", loggedIn.ID).Update("password_hash", "changed").Error)
+	ok, err := repo.DisableInactive(ctx, loggedIn.ID, before)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	current, err := repo.GetByID(ctx, loggedIn.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusActive, current.Status)
+	assert.Equal(t, "changed", current.PasswordHash)
+
+	// 仍未登录的用户被禁用，其他列保持不变
+	ok, err = repo.DisableInactive(ctx, first[1].ID, before)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	current, err = repo.GetByID(ctx, first[1].ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusDisabled, current.Status)
+	assert.Equal(t, first[1].Email, current.Email)
+
+	// 已禁用的用户不会重复处理
+	ok, err = repo.DisableInactive(ctx, first[1].ID, before)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestUserRepository_SQLite_ListByRole(t *testing.T) {
+	db := setupSQLiteDB(t)
+	users := NewUserRepository(db)
+	roles := NewRoleRepository(db)
+	userRoles := NewUserRoleRepository(db)
+	ctx := context.Background()
+
+	alice := &model.User{Username: "alice", Email: "alice@example.com", Status: model.StatusActive}
+	bob := &model.User{Username: "bob", Email: "bob@example.com", Status: model.StatusActive}
+	require.NoError(t, users.Create(ctx, alice))
+	require.NoError(t, users.Create(ctx, bob))
+
+	role := &model.Role{Name: "组织管理员", Code: model.RoleOrgAdmin}
+	require.NoError(t, roles.Create(ctx, role))
+	require.NoError(t, userRoles.Assign(ctx, alice.ID, role.ID))
+
+	list, total, err := users.List(ctx, &UserFilter{RoleCode: model.RoleOrgAdmin}, &Pagination{Page: 1, PageSize: 10})
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, total)
+	require.Len(t, list, 1)
+	assert.Equal(t, alice.ID, list[0].ID)
+
+	// 撤销后不再匹配
+	require.NoError(t, userRoles.Revoke(ctx, alice.ID, role.ID))
+	_, total, err = users.List(ctx, &UserFilter{RoleCode: model.RoleOrgAdmin}, &Pagination{Page: 1, PageSize: 10})
+	require.NoError(t, err)
+	assert.Zero(t, total)
+}
+
+func TestUserRoleRepository_SQLite_ListUserIDs(t *testing.T) {
+	db := setupSQLiteDB(t)
+	users := NewUserRepository(db)
+	roles := NewRoleRepository(db)
+	userRoles := NewUserRoleRepository(db)
+	ctx := context.Background()
+
+	var ids []string
+	for _, name := range []string{"alice", "bob", "carol"} {
+		user := &model.User{Username: name, Email: name + "@example.com"}
+		require.NoError(t, users.Create(ctx, user))
+		ids = append(ids, user.ID)
+	}
+	devRole := &model.Role{OrgID: "org-1", Name: "开发者", Code: "dev"}
+	opsRole := &model.Role{OrgID: "org-1", Name: "运维", Code: "ops"}
+	systemRole := &model.Role{Name: "普通用户", Code: model.RoleUser}
+	for _, role := range []*model.Role{devRole, opsRole, systemRole} {
+		require.NoError(t, roles.Create(ctx, role))
+	}
+	expired := time.Now().Add(-time.Hour)
+	require.NoError(t, userRoles.Assign(ctx, ids[0], devRole.ID))
+	require.NoError(t, userRoles.Assign(ctx, ids[0], opsRole.ID))
+	require.NoError(t, userRoles.AssignWithExpiry(ctx, ids[1], opsRole.ID, &expired))
+	require.NoError(t, userRoles.Assign(ctx, ids[2], systemRole.ID))
+
+	got, err := userRoles.ListUserIDsByRole(ctx, opsRole.ID)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, ids[:2], got)
+
+	// 每个用户只返回一次，系统级角色的持有者不包含在内
+	got, err = userRoles.ListUserIDsByOrg(ctx, "org-1")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, ids[:2], got)
+}
+
+func TestPasswordHistoryRepository_SQLite_Prune(t *testing.T) {
+	repo := NewPasswordHistoryRepository(setupSQLiteDB(t))
+	ctx := context.Background()
+
+	for _, hash := range []string{"h1", "h2", "h3", "h4"} {
+		require.NoError(t, repo.Add(ctx, "user-1", hash, 3))
+	}
+	require.NoError(t, repo.Add(ctx, "user-2", "other", 3))
+
+	history, err := repo.ListRecent(ctx, "user-1", 10)
+	require.NoError(t, err)
+	require.Len(t, history, 3, "超出保留数量的旧记录被删除")
+	assert.Equal(t, "h4", history[0].PasswordHash)
+	assert.Equal(t, "h2", history[2].PasswordHash)
+
+	history, err = repo.ListRecent(ctx, "user-1", 1)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, "h4", history[0].PasswordHash)
+}
+
+func TestConsentRepository_SQLite_Upsert(t *testing.T) {
+	repo := NewConsentRepository(setupSQLiteDB(t))
+	ctx := context.Background()
+
+	_, err := repo.Get(ctx, "user-1", "client-a")
+	assert.ErrorIs(t, err, ErrConsentNotFound)
+
+	require.NoError(t, repo.Save(ctx, &model.UserConsent{UserID: "user-1", ClientID: "client-a", Scopes: model.StringSlice{"openid"}}))
+	require.NoError(t, repo.Save(ctx, &model.UserConsent{UserID: "user-1", ClientID: "client-a", Scopes: model.StringSlice{"openid", "email"}}))
+
+	consent, err := repo.Get(ctx, "user-1", "client-a")
+	require.NoError(t, err)
+	assert.Equal(t, model.StringSlice{"openid", "email"}, consent.Scopes, "同一用户和应用只保留一条记录")
+}
+
+func TestConsentRepository_SQLite_ListAndDelete(t *testing.T) {
+	repo := NewConsentRepository(setupSQLiteDB(t))
+	ctx := context.Background()
+
+	require.NoError(t, repo.Save(ctx, &model.UserConsent{UserID: "user-1", ClientID: "client-a", Scopes: model.StringSlice{"openid"}}))
+	require.NoError(t, repo.Save(ctx, &model.UserConsent{UserID: "user-1", ClientID: "client-b", Scopes: model.StringSlice{"openid"}}))
+	require.NoError(t, repo.Save(ctx, &model.UserConsent{UserID: "user-2", ClientID: "client-a", Scopes: model.StringSlice{"openid"}}))
+
+	consents, err := repo.ListByUser(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Len(t, consents, 2)
+
+	require.NoError(t, repo.Delete(ctx, "user-1", "client-a"))
+	assert.ErrorIs(t, repo.Delete(ctx, "user-1", "client-a"), ErrConsentNotFound)
+	_, err = repo.Get(ctx, "user-2", "client-a")
+	assert.NoError(t, err, "不影响其他用户的授权")
+
+	// 撤销后可以重新授权
+	require.NoError(t, repo.Save(ctx, &model.UserConsent{UserID: "user-1", ClientID: "client-a", Scopes: model.StringSlice{"email"}}))
+	consent, err := repo.Get(ctx, "user-1", "client-a")
+	require.NoError(t, err)
+	assert.Equal(t, model.StringSlice{"email"}, consent.Scopes)
+}
+
+func TestOrganizationRepository_SQLite_DeleteRemovesOrgScopedRBAC(t *testing.T) {
+	db := setupSQLiteDB(t)
+	orgs := NewOrganizationRepository(db)
+	roles := NewRoleRepository(db)
+	perms := NewPermissionRepository(db)
+	userRoles := NewUserRoleRepository(db)
+	ctx := context.Background()
+
+	user := &model.User{Username: "member", Email: "member@example.com"}
+	require.NoError(t, NewUserRepository(db).Create(ctx, user))
+
+	for _, cascade := range []bool{false, true} {
+		slug := fmt.Sprintf("org-%t", cascade)
+		org := &model.Organization{Name: slug, Slug: slug}
+		require.NoError(t, orgs.Create(ctx, org))
+
+		orgRole := &model.Role{OrgID: org.ID, Name: "编辑", Code: slug + "-editor"}
+		systemRole := &model.Role{Name: "系统角色", Code: slug + "-system"}
+		orgPerm := &model.Permission{OrgID: org.ID, Resource: "doc", Action: "read", Code: slug + ":doc:read"}
+		require.NoError(t, roles.Create(ctx, orgRole))
+		require.NoError(t, roles.Create(ctx, systemRole))
+		require.NoError(t, perms.Create(ctx, orgPerm))
+		require.NoError(t, roles.AddPermissions(ctx, orgRole.ID, []string{orgPerm.ID}))
+		require.NoError(t, roles.AddPermissions(ctx, systemRole.ID, []string{orgPerm.ID}))
+		require.NoError(t, userRoles.Assign(ctx, user.ID, orgRole.ID))
+
+		if cascade {
+			require.NoError(t, orgs.DeleteCascade(ctx, org.ID))
+		} else {
+			require.NoError(t, orgs.Delete(ctx, org.ID))
+		}
+
+		// 删除组织后其组织级角色和权限被清除
+		_, err := roles.GetByID(ctx, orgRole.ID)
+		assert.Error(t, err, "cascade=%t", cascade)
+		_, err = perms.GetByID(ctx, orgPerm.ID)
+		assert.Error(t, err, "cascade=%t", cascade)
+		var assignments int64
+		require.NoError(t, db.Model(&model.UserRole{}).Where("role_id = ?", orgRole.ID).Count(&assignments).Error)
+		assert.Zero(t, assignments)
+
+		// 系统级角色保留，但不再关联已删除的组织级权限
+		kept, err := roles.GetByID(ctx, systemRole.ID)
+		require.NoError(t, err)
+		assert.Empty(t, kept.Permissions)
+	}
+}
+
+func TestAuditLogRepository_SQLite_ListByTarget(t *testing.T) {
+	repo := NewAuditLogRepository(setupSQLiteDB(t))
+	ctx := context.Background()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	entries := []*model.AuditLog{
+		{UserID: "admin-1", Action: model.AuditActionAssignRole, Resource: "user", ResourceID: "user-2"},
+		{UserID: "admin-2", Action: model.AuditActionRevokeRole, Resource: "user", ResourceID: "user-2"},
+		{UserID: "user-2", Action: model.AuditActionLogin, Resource: "user", ResourceID: "user-2"},
+		{UserID: "admin-1", Action: model.AuditActionAssignRole, Resource: "user", ResourceID: "user-3"},
+		{UserID: "admin-1", Action: model.AuditActionResetSecret, Resource: "application", ResourceID: "user-2"},
+	}
+	for i, entry := range entries {
+		entry.Result = model.AuditResultSuccess
+		entry.CreatedAt = base.Add(time.Duration(i) * time.Minute)
+		require.NoError(t, repo.Create(ctx, entry))
+	}
+
+	// 按目标过滤返回不同操作者对同一资源的操作，按时间倒序
+	logs, total, err := repo.List(ctx, &AuditLogFilter{Resource: "user", ResourceID: "user-2"}, nil)
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, total)
+	require.Len(t, logs, 3)
+	assert.Equal(t, []string{"user-2", "admin-2", "admin-1"}, []string{logs[0].UserID, logs[1].UserID, logs[2].UserID})
+
+	// 可与操作者和时间范围组合
+	logs, total, err = repo.List(ctx, &AuditLogFilter{
+		UserID:       "admin-1",
+		Resource:     "user",
+		ResourceID:   "user-2",
+		CreatedAfter: base,
+	}, nil)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, total)
+	require.Len(t, logs, 1)
+	assert.Equal(t, model.AuditActionAssignRole, logs[0].Action)
+}
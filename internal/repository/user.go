@@ -3,6 +3,8 @@ package repository
 import (
 	"context"
 	"errors"
+	"strings"
+	"time"
 
 	"github.com/pu-ac-cn/uac-backend/internal/model"
 	"gorm.io/gorm"
@@ -21,11 +23,30 @@ type UserRepository interface {
 	GetByID(ctx context.Context, id string) (*model.User, error)
 	GetByUsername(ctx context.Context, username string) (*model.User, error)
 	GetByEmail(ctx context.Context, email string) (*model.User, error)
+	GetByPhone(ctx context.Context, phone string) (*model.User, error)
 	Update(ctx context.Context, user *model.User) error
+	// Delete 软删除用户，数据保留但查询和登录都视为不存在
 	Delete(ctx context.Context, id string) error
+	// Restore 恢复已软删除的用户，用户不存在或未被删除时返回 ErrUserNotFound
+	// 用户名或邮箱在删除后已被其他用户占用时返回 ErrUserUsernameExists 或 ErrUserEmailExists
+	Restore(ctx context.Context, id string) error
 	List(ctx context.Context, filter *UserFilter, page *Pagination) ([]*model.User, int64, error)
 	ExistsByUsername(ctx context.Context, username string) (bool, error)
 	ExistsByEmail(ctx context.Context, email string) (bool, error)
+	ExistsByPhone(ctx context.Context, phone string) (bool, error)
+	// CountByPhone 统计使用该手机号的用户数
+	CountByPhone(ctx context.Context, phone string) (int64, error)
+	// RecordLogin 记录一次成功登录，返回是否为首次登录
+	RecordLogin(ctx context.Context, userID string, at time.Time) (bool, error)
+	// ListInactive 按 ID 顺序列出 before 之前未登录过的启用用户，从未登录的按创建时间计算
+	// 只返回 ID 大于 afterID 的最多 limit 个用户，用于分批处理
+	ListInactive(ctx context.Context, before time.Time, afterID string, limit int) ([]*model.User, error)
+	// DisableInactive 仅当用户仍为启用状态且 before 之前未登录过时将其禁用，返回是否禁用
+	// 只更新状态列，不会覆盖列出用户之后发生的登录、改密等修改
+	DisableInactive(ctx context.Context, id string, before time.Time) (bool, error)
+	// Merge 在事务中将源用户的组织绑定、角色和审计日志引用转移给目标用户，然后软删除源用户
+	// 目标用户已有的绑定和角色以目标为准；源用户已被删除时只转移残留数据，可重复执行
+	Merge(ctx context.Context, sourceID, targetID string) error
 }
 
 type UserOrgBindingRepository interface {
@@ -35,12 +56,20 @@ type UserOrgBindingRepository interface {
 	ListByUserID(ctx context.Context, userID string) ([]*model.UserOrgBinding, error)
 	ListByOrgID(ctx context.Context, orgID string, page *Pagination) ([]*model.UserOrgBinding, int64, error)
 	Exists(ctx context.Context, userID, orgID string) (bool, error)
+	CountByOrgID(ctx context.Context, orgID string) (int64, error)
 }
 
+// userSortColumns 用户列表允许排序的列
+var userSortColumns = []string{"created_at", "updated_at", "username", "email", "display_name", "status"}
+
 type UserFilter struct {
-	Username string
-	Email    string
-	Status   string
+	Username       string
+	Email          string
+	Status         string
+	CreatedAfter   time.Time // 创建时间不早于该时间，零值不限制
+	CreatedBefore  time.Time // 创建时间早于该时间，零值不限制
+	RoleCode       string    // 拥有指定角色代码
+	IncludeDeleted bool      // 包含已软删除的用户
 }
 
 type userRepository struct {
@@ -51,16 +80,22 @@ func NewUserRepository(db *gorm.DB) UserRepository {
 	return &userRepository{db: db}
 }
 
+// Create 创建用户，用户名和邮箱的唯一性由数据库唯一索引保证
+// 不做先查询再写入的前置检查，避免并发请求同时通过检查后重复插入
 func (r *userRepository) Create(ctx context.Context, user *model.User) error {
-	exists, _ := r.ExistsByUsername(ctx, user.Username)
-	if exists {
-		return ErrUserUsernameExists
+	return translateUserUniqueViolation(r.db.WithContext(ctx).Create(user).Error)
+}
+
+// translateUserUniqueViolation 将用户表的唯一约束冲突转换为用户名或邮箱已存在
+func translateUserUniqueViolation(err error) error {
+	constraint, ok := uniqueViolation(err)
+	if !ok {
+		return err
 	}
-	exists, _ = r.ExistsByEmail(ctx, user.Email)
-	if exists {
+	if strings.Contains(constraint, "email") {
 		return ErrUserEmailExists
 	}
-	return r.db.WithContext(ctx).Create(user).Error
+	return ErrUserUsernameExists
 }
 
 func (r *userRepository) GetByID(ctx context.Context, id string) (*model.User, error) {
@@ -99,10 +134,22 @@ func (r *userRepository) GetByEmail(ctx context.Context, email string) (*model.U
 	return &user, nil
 }
 
+func (r *userRepository) GetByPhone(ctx context.Context, phone string) (*model.User, error) {
+	var user model.User
+	err := r.db.WithContext(ctx).Where("phone = ?", phone).First(&user).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
 func (r *userRepository) Update(ctx context.Context, user *model.User) error {
 	result := r.db.WithContext(ctx).Save(user)
 	if result.Error != nil {
-		return result.Error
+		return translateUserUniqueViolation(result.Error)
 	}
 	if result.RowsAffected == 0 {
 		return ErrUserNotFound
@@ -121,21 +168,27 @@ func (r *userRepository) Delete(ctx context.Context, id string) error {
 	return nil
 }
 
+func (r *userRepository) Restore(ctx context.Context, id string) error {
+	result := r.db.WithContext(ctx).Unscoped().Model(&model.User{}).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		Update("deleted_at", nil)
+	if result.Error != nil {
+		return translateUserUniqueViolation(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
 func (r *userRepository) List(ctx context.Context, filter *UserFilter, page *Pagination) ([]*model.User, int64, error) {
 	var users []*model.User
 	var total int64
-	query := r.db.WithContext(ctx).Model(&model.User{})
-	if filter != nil {
-		if filter.Username != "" {
-			query = query.Where("username LIKE ?", "%"+filter.Username+"%")
-		}
-		if filter.Email != "" {
-			query = query.Where("email LIKE ?", "%"+filter.Email+"%")
-		}
-		if filter.Status != "" {
-			query = query.Where("status = ?", filter.Status)
-		}
+	db := r.db.WithContext(ctx)
+	if filter != nil && filter.IncludeDeleted {
+		db = db.Unscoped()
 	}
+	query := applyUserFilter(db.Model(&model.User{}), filter)
 	if err := query.Count(&total).Error; err != nil {
 		return nil, 0, err
 	}
@@ -143,12 +196,44 @@ func (r *userRepository) List(ctx context.Context, filter *UserFilter, page *Pag
 		offset := (page.Page - 1) * page.PageSize
 		query = query.Offset(offset).Limit(page.PageSize)
 	}
-	if err := query.Order("created_at DESC").Find(&users).Error; err != nil {
+	if err := query.Order(orderBy(page, userSortColumns)).Find(&users).Error; err != nil {
 		return nil, 0, err
 	}
 	return users, total, nil
 }
 
+// applyUserFilter 按过滤条件拼接用户查询的 WHERE 条件
+// 按角色过滤使用子查询关联 user_roles 和 roles，避免 JOIN 后重复行影响计数
+func applyUserFilter(query *gorm.DB, filter *UserFilter) *gorm.DB {
+	if filter == nil {
+		return query
+	}
+	if filter.Username != "" {
+		query = query.Where("users.username LIKE ?", "%"+filter.Username+"%")
+	}
+	if filter.Email != "" {
+		query = query.Where("users.email LIKE ?", "%"+filter.Email+"%")
+	}
+	if filter.Status != "" {
+		query = query.Where("users.status = ?", filter.Status)
+	}
+	if !filter.CreatedAfter.IsZero() {
+		query = query.Where("users.created_at >= ?", filter.CreatedAfter)
+	}
+	if !filter.CreatedBefore.IsZero() {
+		query = query.Where("users.created_at < ?", filter.CreatedBefore)
+	}
+	if filter.RoleCode != "" {
+		roleUsers := query.Session(&gorm.Session{NewDB: true}).
+			Table("user_roles").
+			Select("user_roles.user_id").
+			Joins("JOIN roles ON roles.id = user_roles.role_id AND roles.deleted_at IS NULL").
+			Where("user_roles.deleted_at IS NULL AND roles.code = ?", filter.RoleCode)
+		query = query.Where("users.id IN (?)", roleUsers)
+	}
+	return query
+}
+
 func (r *userRepository) ExistsByUsername(ctx context.Context, username string) (bool, error) {
 	var count int64
 	err := r.db.WithContext(ctx).Model(&model.User{}).Where("username = ?", username).Count(&count).Error
@@ -161,6 +246,107 @@ func (r *userRepository) ExistsByEmail(ctx context.Context, email string) (bool,
 	return count > 0, err
 }
 
+func (r *userRepository) ExistsByPhone(ctx context.Context, phone string) (bool, error) {
+	count, err := r.CountByPhone(ctx, phone)
+	return count > 0, err
+}
+
+func (r *userRepository) CountByPhone(ctx context.Context, phone string) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&model.User{}).Where("phone = ?", phone).Count(&count).Error
+	return count, err
+}
+
+// RecordLogin 记录成功登录
+// first_login_at 通过条件更新设置，并发登录时只有一个请求的更新生效
+func (r *userRepository) RecordLogin(ctx context.Context, userID string, at time.Time) (bool, error) {
+	db := r.db.WithContext(ctx)
+	result := db.Exec("UPDATE users SET first_login_at = ? WHERE id = ? AND first_login_at IS NULL", at, userID)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if err := db.Exec("UPDATE users SET login_count = login_count + 1, last_login_at = ? WHERE id = ?", at, userID).Error; err != nil {
+		return false, err
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *userRepository) ListInactive(ctx context.Context, before time.Time, afterID string, limit int) ([]*model.User, error) {
+	var users []*model.User
+	err := inactiveUsersQuery(r.db.WithContext(ctx), before).
+		Where("id > ?", afterID).
+		Order("id").
+		Limit(limit).
+		Find(&users).Error
+	return users, err
+}
+
+func (r *userRepository) DisableInactive(ctx context.Context, id string, before time.Time) (bool, error) {
+	result := inactiveUsersQuery(r.db.WithContext(ctx), before).
+		Where("id = ?", id).
+		Update("status", model.StatusDisabled)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *userRepository) Merge(ctx context.Context, sourceID, targetID string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var target, source model.User
+		if err := tx.Where("id = ?", targetID).First(&target).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrUserNotFound
+			}
+			return err
+		}
+		if err := tx.Unscoped().Where("id = ?", sourceID).First(&source).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrUserNotFound
+			}
+			return err
+		}
+
+		// 先删除与目标重复的组织绑定和角色，再把其余记录改到目标名下
+		targetOrgIDs := tx.Session(&gorm.Session{NewDB: true}).Model(&model.UserOrgBinding{}).Select("org_id").Where("user_id = ?", targetID)
+		if err := tx.Where("user_id = ? AND org_id IN (?)", sourceID, targetOrgIDs).Delete(&model.UserOrgBinding{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.UserOrgBinding{}).Where("user_id = ?", sourceID).Update("user_id", targetID).Error; err != nil {
+			return err
+		}
+
+		// 用户角色受 (user_id, role_id) 唯一索引约束，重复记录需物理删除
+		targetRoleIDs := tx.Session(&gorm.Session{NewDB: true}).Unscoped().Model(&model.UserRole{}).Select("role_id").Where("user_id = ?", targetID)
+		if err := tx.Unscoped().Where("user_id = ? AND role_id IN (?)", sourceID, targetRoleIDs).Delete(&model.UserRole{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.UserRole{}).Where("user_id = ?", sourceID).Update("user_id", targetID).Error; err != nil {
+			return err
+		}
+
+		// 审计日志中源用户作为操作者和操作目标的记录都归到目标用户
+		if err := tx.Model(&model.AuditLog{}).Where("user_id = ?", sourceID).Update("user_id", targetID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.AuditLog{}).Where("resource = ? AND resource_id = ?", "user", sourceID).Update("resource_id", targetID).Error; err != nil {
+			return err
+		}
+
+		if source.DeletedAt.Valid {
+			return nil
+		}
+		return tx.Delete(&source).Error
+	})
+}
+
+// inactiveUsersQuery 最近登录时间早于 before 的启用用户
+func inactiveUsersQuery(db *gorm.DB, before time.Time) *gorm.DB {
+	return db.Model(&model.User{}).
+		Where("status = ?", model.StatusActive).
+		Where("COALESCE(last_login_at, created_at) < ?", before)
+}
+
 // UserOrgBinding Repository
 
 type userOrgBindingRepository struct {
@@ -176,7 +362,7 @@ func (r *userOrgBindingRepository) Create(ctx context.Context, binding *model.Us
 	if exists {
 		return ErrBindingExists
 	}
-	return r.db.WithContext(ctx).Create(binding).Error
+	return translateUniqueViolation(r.db.WithContext(ctx).Create(binding).Error, ErrBindingExists)
 }
 
 func (r *userOrgBindingRepository) Delete(ctx context.Context, userID, orgID string) error {
@@ -230,3 +416,10 @@ func (r *userOrgBindingRepository) Exists(ctx context.Context, userID, orgID str
 	err := r.db.WithContext(ctx).Model(&model.UserOrgBinding{}).Where("user_id = ? AND org_id = ?", userID, orgID).Count(&count).Error
 	return count > 0, err
 }
+
+// CountByOrgID 统计组织绑定的用户数量
+func (r *userOrgBindingRepository) CountByOrgID(ctx context.Context, orgID string) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&model.UserOrgBinding{}).Where("org_id = ?", orgID).Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/pu-ac-cn/uac-backend/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// userListSQL 生成按过滤条件查询用户列表的 SQL，不实际执行
+func userListSQL(t *testing.T, filter *UserFilter) string {
+	db, _ := setupBlockingDB(t)
+	return db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		var users []*model.User
+		return applyUserFilter(tx.Model(&model.User{}), filter).Find(&users)
+	})
+}
+
+func TestApplyUserFilter_NoFilter(t *testing.T) {
+	sql := userListSQL(t, nil)
+	assert.Contains(t, sql, `FROM "users" WHERE "users"."deleted_at" IS NULL`)
+	assert.NotContains(t, sql, "user_roles")
+}
+
+func TestApplyUserFilter_CreatedAtAndRole(t *testing.T) {
+	after := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	before := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	sql := userListSQL(t, &UserFilter{
+		Status:        model.StatusActive,
+		CreatedAfter:  after,
+		CreatedBefore: before,
+		RoleCode:      model.RoleOrgAdmin,
+	})
+
+	assert.Contains(t, sql, "users.status = 'active'")
+	assert.Contains(t, sql, "users.created_at >= '2024-01-01 00:00:00'")
+	assert.Contains(t, sql, "users.created_at < '2024-02-01 00:00:00'")
+	// 角色通过子查询过滤，只匹配未删除的角色和关联
+	assert.Contains(t, sql, "users.id IN (SELECT user_roles.user_id FROM \"user_roles\" JOIN roles ON roles.id = user_roles.role_id AND roles.deleted_at IS NULL")
+	assert.Contains(t, sql, "user_roles.deleted_at IS NULL AND roles.code = 'org_admin'")
+	assert.Contains(t, sql, `"users"."deleted_at" IS NULL`)
+}
+
+func TestApplyUserFilter_OpenEndedRange(t *testing.T) {
+	sql := userListSQL(t, &UserFilter{CreatedAfter: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)})
+	assert.Contains(t, sql, "users.created_at >=")
+	assert.NotContains(t, sql, "users.created_at <")
+	assert.NotContains(t, sql, "user_roles")
+}
+
+func TestInactiveUsersQuery(t *testing.T) {
+	db, _ := setupBlockingDB(t)
+	before := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		var users []*model.User
+		return inactiveUsersQuery(tx, before).Find(&users)
+	})
+	assert.Contains(t, sql, `status = 'active'`)
+	assert.Contains(t, sql, `COALESCE(last_login_at, created_at) < '2024-01-01 00:00:00'`)
+	assert.Contains(t, sql, `"users"."deleted_at" IS NULL`)
+}
+
+// recordedExec 一条被记录的写语句
+type recordedExec struct {
+	query string
+	args  []driver.Value
+}
+
+// recordingDriver 记录执行的写语句的数据库驱动，查询总是返回一行 id 为第一个参数的未删除记录
+type recordingDriver struct {
+	execs *[]recordedExec
+}
+
+func (d *recordingDriver) Open(name string) (driver.Conn, error) {
+	return &recordingConn{execs: d.execs}, nil
+}
+
+type recordingConn struct {
+	execs *[]recordedExec
+}
+
+func (c *recordingConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("不支持预编译")
+}
+
+func (c *recordingConn) Close() error { return nil }
+
+func (c *recordingConn) Begin() (driver.Tx, error) { return recordingTx{}, nil }
+
+func (c *recordingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return &singleRow{id: args[0].Value}, nil
+}
+
+func (c *recordingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	*c.execs = append(*c.execs, recordedExec{query: query, args: values})
+	return driver.RowsAffected(1), nil
+}
+
+type recordingTx struct{}
+
+func (recordingTx) Commit() error   { return nil }
+func (recordingTx) Rollback() error { return nil }
+
+type singleRow struct {
+	id   driver.Value
+	done bool
+}
+
+func (r *singleRow) Columns() []string { return []string{"id", "deleted_at"} }
+func (r *singleRow) Close() error      { return nil }
+
+func (r *singleRow) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0], dest[1] = r.id, nil
+	return nil
+}
+
+func TestUserRepository_Merge(t *testing.T) {
+	var execs []recordedExec
+	name := fmt.Sprintf("recording-%d", blockingDrivers.Add(1))
+	sql.Register(name, &recordingDriver{execs: &execs})
+	sqlDB, err := sql.Open(name, "")
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: logger.Discard})
+	require.NoError(t, err)
+
+	require.NoError(t, NewUserRepository(db).Merge(context.Background(), "source", "target"))
+	require.Len(t, execs, 7)
+
+	// 先删除源用户与目标重复的组织绑定，再把其余绑定转给目标，目标最终拥有两者的并集
+	assert.Contains(t, execs[0].query, `UPDATE "user_org_bindings" SET "deleted_at"`)
+	assert.Contains(t, execs[0].query, `org_id IN (SELECT "org_id" FROM "user_org_bindings" WHERE user_id = $3`)
+	assert.Equal(t, []driver.Value{"source", "target"}, execs[0].args[1:])
+	assert.Contains(t, execs[1].query, `UPDATE "user_org_bindings" SET "user_id"`)
+	assert.Equal(t, "target", execs[1].args[0])
+	assert.Equal(t, "source", execs[1].args[2])
+
+	// 角色同理，但重复记录物理删除以免占用唯一索引
+	assert.Contains(t, execs[2].query, `DELETE FROM "user_roles"`)
+	assert.Contains(t, execs[2].query, `role_id IN (SELECT "role_id" FROM "user_roles" WHERE user_id = $2`)
+	assert.Equal(t, []driver.Value{"source", "target"}, execs[2].args)
+	assert.Contains(t, execs[3].query, `UPDATE "user_roles" SET "user_id"`)
+	assert.Equal(t, "target", execs[3].args[0])
+
+	// 审计日志的操作者和操作目标都转给目标用户
+	assert.Equal(t, []driver.Value{"target", "source"}, execs[4].args)
+	assert.Equal(t, []driver.Value{"target", "user", "source"}, execs[5].args)
+
+	// 最后软删除源用户
+	assert.Contains(t, execs[6].query, `UPDATE "users" SET "deleted_at"`)
+	assert.Equal(t, "source", execs[6].args[1])
+}
@@ -16,6 +16,8 @@ var (
 	ErrAppDisabled        = errors.New("应用已禁用")
 	ErrAppInvalidProtocol = errors.New("无效的协议类型")
 	ErrAppInvalidVersion  = errors.New("无效的 OAuth 版本")
+
+	ErrOrgAppQuotaExceeded = errors.New("组织应用数量已达上限")
 )
 
 type ApplicationService interface {
@@ -46,9 +48,19 @@ func (s *appService) Create(ctx context.Context, app *model.Application) (string
 	}
 	// 只有当指定了组织 ID 时才验证组织是否存在（支持系统级应用）
 	if s.orgRepo != nil && app.OrgID != nil && *app.OrgID != "" {
-		if _, err := s.orgRepo.GetByID(ctx, *app.OrgID); err != nil {
+		org, err := s.orgRepo.GetByID(ctx, *app.OrgID)
+		if err != nil {
 			return "", errors.New("组织不存在")
 		}
+		if org.MaxApps > 0 {
+			count, err := s.repo.CountByOrgID(ctx, org.ID)
+			if err != nil {
+				return "", err
+			}
+			if count >= int64(org.MaxApps) {
+				return "", ErrOrgAppQuotaExceeded
+			}
+		}
 	} else {
 		// 系统级应用：将空串标准化为 NULL
 		if app.OrgID != nil && *app.OrgID == "" {
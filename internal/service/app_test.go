@@ -122,6 +122,30 @@ func TestAppService_Create(t *testing.T) {
 	}
 }
 
+func TestAppService_Create_QuotaExceeded(t *testing.T) {
+	appRepo := newMockAppRepository()
+	orgRepo := newMockOrgRepository()
+	svc := NewApplicationService(appRepo, orgRepo)
+	ctx := context.Background()
+
+	org := &model.Organization{Name: "配额组织", Slug: "quota-app-org", MaxApps: 1}
+	orgSvc := NewOrganizationService(orgRepo)
+	_ = orgSvc.Create(ctx, org)
+
+	if _, err := svc.Create(ctx, &model.Application{Name: "应用一", OrgID: &org.ID}); err != nil {
+		t.Fatalf("未达上限时创建应用失败: %v", err)
+	}
+	if _, err := svc.Create(ctx, &model.Application{Name: "应用二", OrgID: &org.ID}); err != ErrOrgAppQuotaExceeded {
+		t.Errorf("期望错误 %v, 实际 %v", ErrOrgAppQuotaExceeded, err)
+	}
+
+	// 上限为 0 表示不限
+	org.MaxApps = 0
+	if _, err := svc.Create(ctx, &model.Application{Name: "应用二", OrgID: &org.ID}); err != nil {
+		t.Errorf("不限配额时创建应用失败: %v", err)
+	}
+}
+
 func TestAppService_ResetSecret(t *testing.T) {
 	appRepo := newMockAppRepository()
 	orgRepo := newMockOrgRepository()
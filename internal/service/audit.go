@@ -0,0 +1,47 @@
+// Package service 业务逻辑层
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/pu-ac-cn/uac-backend/internal/model"
+	"github.com/pu-ac-cn/uac-backend/internal/repository"
+)
+
+// ErrAuditActionEmpty 审计日志缺少操作类型
+var ErrAuditActionEmpty = errors.New("审计操作类型不能为空")
+
+// AuditService 审计日志服务接口
+type AuditService interface {
+	// Record 记录一条审计日志，未指定结果时视为成功
+	Record(ctx context.Context, entry *model.AuditLog) error
+	List(ctx context.Context, filter *repository.AuditLogFilter, page *repository.Pagination) ([]*model.AuditLog, int64, error)
+}
+
+// auditService 审计日志服务实现
+type auditService struct {
+	repo repository.AuditLogRepository
+}
+
+// NewAuditService 创建审计日志服务
+func NewAuditService(repo repository.AuditLogRepository) AuditService {
+	return &auditService{repo: repo}
+}
+
+func (s *auditService) Record(ctx context.Context, entry *model.AuditLog) error {
+	if entry.Action == "" {
+		return ErrAuditActionEmpty
+	}
+	if entry.Result == "" {
+		entry.Result = model.AuditResultSuccess
+	}
+	return s.repo.Create(ctx, entry)
+}
+
+func (s *auditService) List(ctx context.Context, filter *repository.AuditLogFilter, page *repository.Pagination) ([]*model.AuditLog, int64, error) {
+	if page == nil {
+		page = &repository.Pagination{Page: 1, PageSize: 20}
+	}
+	return s.repo.List(ctx, filter, page)
+}
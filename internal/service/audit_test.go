@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pu-ac-cn/uac-backend/internal/model"
+	"github.com/pu-ac-cn/uac-backend/internal/repository"
+)
+
+// mockAuditLogRepository 内存审计日志仓库，记录写入和查询参数
+type mockAuditLogRepository struct {
+	logs []*model.AuditLog
+	page *repository.Pagination
+}
+
+func (m *mockAuditLogRepository) Create(ctx context.Context, entry *model.AuditLog) error {
+	m.logs = append(m.logs, entry)
+	return nil
+}
+
+func (m *mockAuditLogRepository) List(ctx context.Context, filter *repository.AuditLogFilter, page *repository.Pagination) ([]*model.AuditLog, int64, error) {
+	m.page = page
+	return m.logs, int64(len(m.logs)), nil
+}
+
+func TestAuditService_Record(t *testing.T) {
+	repo := &mockAuditLogRepository{}
+	svc := NewAuditService(repo)
+	ctx := context.Background()
+
+	entry := &model.AuditLog{Action: model.AuditActionLogout, UserID: "user-1"}
+	if err := svc.Record(ctx, entry); err != nil {
+		t.Fatalf("记录审计日志失败: %v", err)
+	}
+	if entry.Result != model.AuditResultSuccess {
+		t.Errorf("未指定结果时期望记为 success, 实际 %q", entry.Result)
+	}
+
+	failed := &model.AuditLog{Action: model.AuditActionLogin, Result: model.AuditResultFailure}
+	if err := svc.Record(ctx, failed); err != nil {
+		t.Fatalf("记录审计日志失败: %v", err)
+	}
+	if failed.Result != model.AuditResultFailure {
+		t.Errorf("不应覆盖已指定的结果, 实际 %q", failed.Result)
+	}
+
+	if err := svc.Record(ctx, &model.AuditLog{}); err != ErrAuditActionEmpty {
+		t.Errorf("期望 ErrAuditActionEmpty, 实际 %v", err)
+	}
+	if len(repo.logs) != 2 {
+		t.Errorf("期望写入 2 条审计日志, 实际 %d", len(repo.logs))
+	}
+}
+
+func TestAuditService_ListDefaultPage(t *testing.T) {
+	repo := &mockAuditLogRepository{}
+	svc := NewAuditService(repo)
+
+	if _, _, err := svc.List(context.Background(), nil, nil); err != nil {
+		t.Fatalf("查询审计日志失败: %v", err)
+	}
+	if repo.page == nil || repo.page.Page != 1 || repo.page.PageSize != 20 {
+		t.Errorf("未指定分页时期望第 1 页每页 20 条, 实际 %+v", repo.page)
+	}
+}
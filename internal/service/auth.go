@@ -4,10 +4,13 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net/url"
 	"time"
 
 	"github.com/pu-ac-cn/uac-backend/internal/model"
 	"github.com/pu-ac-cn/uac-backend/internal/repository"
+	"github.com/redis/go-redis/v9"
 )
 
 // 认证相关错误
@@ -16,6 +19,8 @@ var (
 	ErrAccountLocked      = errors.New("账户已锁定，请稍后再试")
 	ErrAccountDisabled    = errors.New("账户已禁用")
 	ErrUserNotFound       = errors.New("用户不存在")
+	ErrResetTokenInvalid  = errors.New("重置链接无效或已过期")
+	ErrResetUnavailable   = errors.New("密码重置功能未启用")
 )
 
 // AuthService 认证服务接口
@@ -24,22 +29,71 @@ type AuthService interface {
 	Authenticate(ctx context.Context, username, password string) (*model.User, error)
 	// AuthenticateByEmail 通过邮箱验证用户凭据
 	AuthenticateByEmail(ctx context.Context, email, password string) (*model.User, error)
+	// AuthenticateByPhone 通过已验证的手机号验证用户凭据
+	AuthenticateByPhone(ctx context.Context, phone, password string) (*model.User, error)
 	// ChangePassword 修改密码
 	ChangePassword(ctx context.Context, userID, oldPassword, newPassword string) error
 	// ResetPassword 重置密码（管理员操作）
 	ResetPassword(ctx context.Context, userID, newPassword string) error
 	// UnlockAccount 解锁账户
 	UnlockAccount(ctx context.Context, userID string) error
+	// GetLockoutStatus 查询账户锁定状态，用户不存在时返回未锁定，不暴露账户是否存在
+	GetLockoutStatus(ctx context.Context, username string) (*LockoutStatus, error)
+	// InitiatePasswordReset 发起忘记密码流程（邮箱不存在时同样返回成功）
+	InitiatePasswordReset(ctx context.Context, email string) error
+	// CompletePasswordReset 使用重置令牌设置新密码
+	CompletePasswordReset(ctx context.Context, token, newPassword string) error
+	// InvalidatePendingTokens 作废用户尚未使用的密码重置和邮箱验证令牌，返回作废的数量
+	InvalidatePendingTokens(ctx context.Context, userID string) (int, error)
+}
+
+// AuthServiceConfig 认证服务配置
+type AuthServiceConfig struct {
+	Redis              *redis.Client    // 存储密码重置令牌
+	TokenService       TokenService     // 重置密码后使已签发令牌失效
+	SessionService     SessionService   // 重置密码后清除登录会话
+	EmailSender        EmailSender      // 发送密码重置邮件
+	ResetURL           string           // 密码重置页面地址
+	Now                func() time.Time // 当前时间，测试时可注入
+	DefaultCountryCode string           // 手机号登录时补充的默认国家码，默认 86
+	Events             EventPublisher   // 发布首次登录等事件
+	// AllowDuplicatePhone 允许多个用户使用同一手机号，此时无法确定登录的账户，不支持手机号登录
+	AllowDuplicatePhone bool
+	// PasswordHistory 密码历史存储，未设置时不检查密码重用
+	PasswordHistory repository.PasswordHistoryRepository
+	// PasswordHistorySize 禁止重用的最近密码数量，0 使用默认值 5，负数关闭检查
+	PasswordHistorySize int
 }
 
 // authService 认证服务实现
 type authService struct {
 	userRepo repository.UserRepository
+	config   *AuthServiceConfig
+	history  *passwordHistory
 }
 
 // NewAuthService 创建认证服务
-func NewAuthService(userRepo repository.UserRepository) AuthService {
-	return &authService{userRepo: userRepo}
+func NewAuthService(userRepo repository.UserRepository, config *AuthServiceConfig) AuthService {
+	if config == nil {
+		config = &AuthServiceConfig{}
+	}
+	if config.EmailSender == nil {
+		config.EmailSender = NewLogEmailSender()
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+	if config.DefaultCountryCode == "" {
+		config.DefaultCountryCode = DefaultCountryCode
+	}
+	if config.Events == nil {
+		config.Events = NewLogEventPublisher()
+	}
+	return &authService{
+		userRepo: userRepo,
+		config:   config,
+		history:  newPasswordHistory(config.PasswordHistory, config.PasswordHistorySize),
+	}
 }
 
 // Authenticate 验证用户凭据
@@ -60,10 +114,34 @@ func (s *authService) AuthenticateByEmail(ctx context.Context, email, password s
 	return s.validateAndAuthenticate(ctx, user, password)
 }
 
+// AuthenticateByPhone 通过手机号验证用户凭据
+// 手机号按 E.164 规范化后查找，仅已验证且只属于一个用户的手机号可用于登录
+func (s *authService) AuthenticateByPhone(ctx context.Context, phone, password string) (*model.User, error) {
+	if s.config.AllowDuplicatePhone {
+		return nil, ErrInvalidCredentials
+	}
+	normalized, err := NormalizePhone(phone, s.config.DefaultCountryCode)
+	if err != nil || normalized == "" {
+		return nil, ErrInvalidCredentials
+	}
+	// 开启唯一约束前遗留的重复号码同样拒绝，避免登录到任意一个同号用户
+	count, err := s.userRepo.CountByPhone(ctx, normalized)
+	if err != nil || count != 1 {
+		return nil, ErrInvalidCredentials
+	}
+	user, err := s.userRepo.GetByPhone(ctx, normalized)
+	if err != nil || !user.PhoneVerified {
+		return nil, ErrInvalidCredentials
+	}
+	return s.validateAndAuthenticate(ctx, user, password)
+}
+
 // validateAndAuthenticate 验证用户并执行认证
 func (s *authService) validateAndAuthenticate(ctx context.Context, user *model.User, password string) (*model.User, error) {
-	// 检查账户是否被锁定
-	if user.IsLocked() {
+	now := s.config.Now()
+
+	// 检查账户是否被锁定，锁定到期后自动解锁
+	if user.IsLockedAt(now) {
 		return nil, ErrAccountLocked
 	}
 
@@ -75,20 +153,40 @@ func (s *authService) validateAndAuthenticate(ctx context.Context, user *model.U
 	// 验证密码
 	if !user.VerifyPassword(password) {
 		// 增加失败次数
-		user.IncrementFailedLogin()
+		user.IncrementFailedLogin(now, MaxFailedAttempts, LockDuration)
 		_ = s.userRepo.Update(ctx, user)
 		return nil, ErrInvalidCredentials
 	}
 
-	// 登录成功，重置失败次数
-	if user.FailedLoginCount > 0 {
+	// 登录成功，重置失败次数与过期的锁定时间
+	if user.FailedLoginCount > 0 || user.LockedUntil != nil {
 		user.ResetFailedLogin()
 		_ = s.userRepo.Update(ctx, user)
 	}
 
+	s.recordLogin(ctx, user, now)
 	return user, nil
 }
 
+// recordLogin 记录登录次数，首次登录时发布 user.first_login 事件
+// 统计失败不影响登录
+func (s *authService) recordLogin(ctx context.Context, user *model.User, now time.Time) {
+	first, err := s.userRepo.RecordLogin(ctx, user.ID, now)
+	if err != nil || !first {
+		return
+	}
+	user.FirstLoginAt = &now
+	_ = s.config.Events.Publish(ctx, &Event{
+		Type:       EventUserFirstLogin,
+		UserID:     user.ID,
+		OccurredAt: now,
+		Data: map[string]interface{}{
+			"username": user.Username,
+			"email":    user.Email,
+		},
+	})
+}
+
 // ChangePassword 修改密码
 func (s *authService) ChangePassword(ctx context.Context, userID, oldPassword, newPassword string) error {
 	user, err := s.userRepo.GetByID(ctx, userID)
@@ -100,13 +198,20 @@ func (s *authService) ChangePassword(ctx context.Context, userID, oldPassword, n
 	if !user.VerifyPassword(oldPassword) {
 		return ErrInvalidCredentials
 	}
+	if err := s.history.check(ctx, user, newPassword); err != nil {
+		return err
+	}
 
 	// 设置新密码
 	if err := user.SetPassword(newPassword); err != nil {
 		return err
 	}
 
-	return s.userRepo.Update(ctx, user)
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return err
+	}
+	_ = s.history.record(ctx, user)
+	return nil
 }
 
 // ResetPassword 重置密码（管理员操作）
@@ -115,6 +220,9 @@ func (s *authService) ResetPassword(ctx context.Context, userID, newPassword str
 	if err != nil {
 		return ErrUserNotFound
 	}
+	if err := s.history.check(ctx, user, newPassword); err != nil {
+		return err
+	}
 
 	// 设置新密码
 	if err := user.SetPassword(newPassword); err != nil {
@@ -124,7 +232,11 @@ func (s *authService) ResetPassword(ctx context.Context, userID, newPassword str
 	// 重置登录失败次数和锁定状态
 	user.ResetFailedLogin()
 
-	return s.userRepo.Update(ctx, user)
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return err
+	}
+	_ = s.history.record(ctx, user)
+	return nil
 }
 
 // UnlockAccount 解锁账户
@@ -140,26 +252,120 @@ func (s *authService) UnlockAccount(ctx context.Context, userID string) error {
 	return s.userRepo.Update(ctx, user)
 }
 
-// IsPasswordStrong 检查密码强度
-// 密码要求：最小 8 位，包含大写字母、小写字母、数字
-func IsPasswordStrong(password string) bool {
-	if len(password) < 8 {
-		return false
+// LockoutStatus 账户锁定状态
+type LockoutStatus struct {
+	Locked     bool       // 当前是否锁定
+	UnlockAt   *time.Time // 自动解锁时间，未锁定时为空
+	RetryAfter int        // 距离解锁的秒数，未锁定时为 0
+}
+
+// GetLockoutStatus 查询账户锁定状态
+// 不存在、已禁用和未锁定的账户返回相同的结果，锁定到期视为未锁定
+func (s *authService) GetLockoutStatus(ctx context.Context, username string) (*LockoutStatus, error) {
+	status := &LockoutStatus{}
+	user, err := s.userRepo.GetByUsername(ctx, username)
+	if err != nil {
+		return status, nil
+	}
+
+	now := s.config.Now()
+	if user.IsLockedAt(now) {
+		unlockAt := *user.LockedUntil
+		status.Locked = true
+		status.UnlockAt = &unlockAt
+		status.RetryAfter = int((unlockAt.Sub(now) + time.Second - 1) / time.Second)
+	}
+	return status, nil
+}
+
+// passwordResetKeyPrefix 密码重置令牌 Redis key 前缀
+const passwordResetKeyPrefix = "password_reset:"
+
+// PasswordResetExpiry 密码重置令牌有效期
+const PasswordResetExpiry = 30 * time.Minute
+
+// InitiatePasswordReset 发起忘记密码流程
+func (s *authService) InitiatePasswordReset(ctx context.Context, email string) error {
+	if s.config.Redis == nil {
+		return ErrResetUnavailable
+	}
+
+	// 邮箱不存在或账户不可用时静默返回，防止账户枚举
+	user, err := s.userRepo.GetByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return nil
+		}
+		return err
+	}
+	if !user.IsActive() {
+		return nil
+	}
+
+	token := generateSecureCode(32)
+	if err := storePendingToken(ctx, s.config.Redis, passwordResetKeyPrefix+token, user.ID, PasswordResetExpiry); err != nil {
+		return fmt.Errorf("存储重置令牌失败: %w", err)
+	}
+
+	link := s.config.ResetURL + "?token=" + url.QueryEscape(token)
+	body := fmt.Sprintf("请在 30 分钟内点击以下链接重置密码：%s", link)
+	return s.config.EmailSender.Send(ctx, user.Email, "重置您的密码", body)
+}
+
+// CompletePasswordReset 使用重置令牌设置新密码
+// 成功后该用户已签发的令牌和登录会话全部失效
+func (s *authService) CompletePasswordReset(ctx context.Context, token, newPassword string) error {
+	if s.config.Redis == nil {
+		return ErrResetUnavailable
+	}
+	if token == "" {
+		return ErrResetTokenInvalid
+	}
+
+	// 先原子地取出令牌，保证并发请求中只有一个能使用同一令牌
+	key := passwordResetKeyPrefix + token
+	userID, ttl, err := claimPendingToken(ctx, s.config.Redis, key)
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return ErrResetTokenInvalid
+		}
+		return fmt.Errorf("获取重置令牌失败: %w", err)
 	}
 
-	var hasUpper, hasLower, hasDigit bool
-	for _, c := range password {
-		switch {
-		case c >= 'A' && c <= 'Z':
-			hasUpper = true
-		case c >= 'a' && c <= 'z':
-			hasLower = true
-		case c >= '0' && c <= '9':
-			hasDigit = true
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+	// 重用旧密码时放回令牌，用户可换一个密码重试
+	if err := s.history.check(ctx, user, newPassword); err != nil {
+		restorePendingToken(ctx, s.config.Redis, key, user.ID, ttl)
+		return err
+	}
+
+	if err := user.SetPassword(newPassword); err != nil {
+		return err
+	}
+	user.ResetFailedLogin()
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return err
+	}
+	_ = s.history.record(ctx, user)
+
+	// 从用户的令牌索引中移除
+	consumePendingToken(ctx, s.config.Redis, key, user.ID)
+
+	if s.config.TokenService != nil {
+		if err := s.config.TokenService.RevokeUserTokens(ctx, user.ID); err != nil {
+			return err
+		}
+	}
+	if s.config.SessionService != nil {
+		if err := s.config.SessionService.DeleteByUserID(ctx, user.ID); err != nil {
+			return err
 		}
 	}
 
-	return hasUpper && hasLower && hasDigit
+	return nil
 }
 
 // LockDuration 账户锁定时长
@@ -167,3 +373,15 @@ const LockDuration = 15 * time.Minute
 
 // MaxFailedAttempts 最大失败尝试次数
 const MaxFailedAttempts = 5
+
+// InvalidatePendingTokens 作废用户尚未使用的一次性令牌
+// 用于怀疑用户邮箱泄露时，使已发出的重置和验证链接立即失效
+func (s *authService) InvalidatePendingTokens(ctx context.Context, userID string) (int, error) {
+	if s.config.Redis == nil {
+		return 0, ErrResetUnavailable
+	}
+	if _, err := s.userRepo.GetByID(ctx, userID); err != nil {
+		return 0, ErrUserNotFound
+	}
+	return invalidatePendingTokens(ctx, s.config.Redis, userID)
+}
@@ -79,7 +79,7 @@ func TestProperty_LoginFailureLocking(t *testing.T) {
 	properties.Property("连续5次失败后锁定", prop.ForAll(
 		func(username string) bool {
 			userRepo := newMockUserRepository()
-			svc := NewAuthService(userRepo)
+			svc := NewAuthService(userRepo, nil)
 			ctx := context.Background()
 
 			// 创建用户
@@ -131,7 +131,7 @@ func TestProperty_PasswordStrength(t *testing.T) {
 
 	properties.Property("密码强度检查一致性", prop.ForAll(
 		func(password string) bool {
-			result := IsPasswordStrong(password)
+			result := DefaultPasswordPolicy().Validate(password) == nil
 
 			// 手动验证
 			if len(password) < 8 {
@@ -2,7 +2,10 @@ package service
 
 import (
 	"context"
+	"errors"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/pu-ac-cn/uac-backend/internal/model"
 )
@@ -10,7 +13,7 @@ import (
 // TestAuthService_Authenticate 测试用户认证
 func TestAuthService_Authenticate(t *testing.T) {
 	userRepo := newMockUserRepository()
-	svc := NewAuthService(userRepo)
+	svc := NewAuthService(userRepo, nil)
 	ctx := context.Background()
 
 	// 创建测试用户
@@ -69,9 +72,166 @@ func TestAuthService_Authenticate(t *testing.T) {
 }
 
 // TestAuthService_AccountLocking 测试账户锁定
+// TestAuthService_AuthenticateByPhone 测试手机号登录
+func TestAuthService_AuthenticateByPhone(t *testing.T) {
+	userRepo := newMockUserRepository()
+	svc := NewAuthService(userRepo, nil)
+	ctx := context.Background()
+
+	verified := &model.User{Username: "verified", Email: "verified@example.com", Phone: "+8613800138000", PhoneVerified: true, Status: model.StatusActive}
+	verified.SetPassword("Test1234")
+	userRepo.Create(ctx, verified)
+	unverified := &model.User{Username: "unverified", Email: "unverified@example.com", Phone: "+8613900139000", Status: model.StatusActive}
+	unverified.SetPassword("Test1234")
+	userRepo.Create(ctx, unverified)
+
+	// 已验证手机号，任意书写格式均可登录
+	for _, phone := range []string{"13800138000", "+86 138-0013-8000"} {
+		user, err := svc.AuthenticateByPhone(ctx, phone, "Test1234")
+		if err != nil {
+			t.Errorf("手机号 %s 登录失败: %v", phone, err)
+		} else if user.ID != verified.ID {
+			t.Errorf("登录用户不匹配: %s", user.ID)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		phone    string
+		password string
+	}{
+		{"未验证手机号", "13900139000", "Test1234"},
+		{"错误密码", "13800138000", "wrongpassword"},
+		{"手机号不存在", "13700137000", "Test1234"},
+		{"手机号格式无效", "abc", "Test1234"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := svc.AuthenticateByPhone(ctx, tt.phone, tt.password); err != ErrInvalidCredentials {
+				t.Errorf("期望 ErrInvalidCredentials, 实际 %v", err)
+			}
+		})
+	}
+
+	// 用户名与邮箱登录不受影响
+	if _, err := svc.Authenticate(ctx, "unverified", "Test1234"); err != nil {
+		t.Errorf("用户名登录失败: %v", err)
+	}
+	if _, err := svc.AuthenticateByEmail(ctx, "unverified@example.com", "Test1234"); err != nil {
+		t.Errorf("邮箱登录失败: %v", err)
+	}
+}
+
+// TestAuthService_AuthenticateByPhone_NotUnique 测试手机号不唯一时拒绝手机号登录
+func TestAuthService_AuthenticateByPhone_NotUnique(t *testing.T) {
+	userRepo := newMockUserRepository()
+	ctx := context.Background()
+	verified := &model.User{Username: "verified", Email: "verified@example.com", Phone: "+8613800138000", PhoneVerified: true, Status: model.StatusActive}
+	verified.SetPassword("Test1234")
+	userRepo.Create(ctx, verified)
+
+	// 未要求手机号唯一时无法确定登录的账户
+	svc := NewAuthService(userRepo, &AuthServiceConfig{AllowDuplicatePhone: true})
+	if _, err := svc.AuthenticateByPhone(ctx, "13800138000", "Test1234"); err != ErrInvalidCredentials {
+		t.Errorf("允许重复手机号时期望 ErrInvalidCredentials, 实际 %v", err)
+	}
+
+	// 开启唯一约束前遗留的同号用户
+	svc = NewAuthService(userRepo, nil)
+	duplicate := &model.User{Username: "duplicate", Email: "duplicate@example.com", Phone: "+8613800138000", PhoneVerified: true, Status: model.StatusActive}
+	duplicate.SetPassword("Test1234")
+	userRepo.Create(ctx, duplicate)
+	if _, err := svc.AuthenticateByPhone(ctx, "13800138000", "Test1234"); err != ErrInvalidCredentials {
+		t.Errorf("手机号重复时期望 ErrInvalidCredentials, 实际 %v", err)
+	}
+}
+
+// captureEventPublisher 记录发布的事件
+type captureEventPublisher struct {
+	mu     sync.Mutex
+	events []*Event
+}
+
+func (p *captureEventPublisher) Publish(ctx context.Context, event *Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events = append(p.events, event)
+	return nil
+}
+
+// TestAuthService_FirstLogin 测试首次登录记录与事件
+func TestAuthService_FirstLogin(t *testing.T) {
+	userRepo := newMockUserRepository()
+	events := &captureEventPublisher{}
+	now := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	svc := NewAuthService(userRepo, &AuthServiceConfig{Events: events, Now: func() time.Time { return now }})
+	ctx := context.Background()
+
+	user := &model.User{Username: "newbie", Email: "newbie@example.com", Status: model.StatusActive}
+	user.SetPassword("Test1234")
+	userRepo.Create(ctx, user)
+
+	// 首次登录记录时间并发布事件
+	result, err := svc.Authenticate(ctx, "newbie", "Test1234")
+	if err != nil {
+		t.Fatalf("登录失败: %v", err)
+	}
+	if result.FirstLoginAt == nil || !result.FirstLoginAt.Equal(now) {
+		t.Errorf("首次登录时间未设置: %v", result.FirstLoginAt)
+	}
+	if len(events.events) != 1 || events.events[0].Type != EventUserFirstLogin || events.events[0].UserID != user.ID {
+		t.Fatalf("期望一条 user.first_login 事件, 实际 %+v", events.events)
+	}
+
+	// 失败的登录和后续登录不再发布事件
+	now = now.Add(time.Hour)
+	svc.Authenticate(ctx, "newbie", "wrongpassword")
+	if _, err := svc.Authenticate(ctx, "newbie", "Test1234"); err != nil {
+		t.Fatalf("登录失败: %v", err)
+	}
+	if len(events.events) != 1 {
+		t.Errorf("后续登录不应发布事件, 实际 %d 条", len(events.events))
+	}
+	if !userRepo.firstLogins[user.ID].Equal(time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("首次登录时间不应被覆盖: %v", userRepo.firstLogins[user.ID])
+	}
+	if userRepo.loginCounts[user.ID] != 2 {
+		t.Errorf("期望登录次数 2, 实际 %d", userRepo.loginCounts[user.ID])
+	}
+}
+
+// TestAuthService_FirstLoginConcurrent 测试并发首次登录只发布一次事件
+func TestAuthService_FirstLoginConcurrent(t *testing.T) {
+	userRepo := newMockUserRepository()
+	events := &captureEventPublisher{}
+	svc := NewAuthService(userRepo, &AuthServiceConfig{Events: events})
+	ctx := context.Background()
+
+	user := &model.User{Username: "racer", Email: "racer@example.com", Status: model.StatusActive}
+	user.SetPassword("Test1234")
+	userRepo.Create(ctx, user)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			svc.Authenticate(ctx, "racer", "Test1234")
+		}()
+	}
+	wg.Wait()
+
+	if len(events.events) != 1 {
+		t.Errorf("并发首次登录应只发布一次事件, 实际 %d 条", len(events.events))
+	}
+	if userRepo.loginCounts[user.ID] != 10 {
+		t.Errorf("期望登录次数 10, 实际 %d", userRepo.loginCounts[user.ID])
+	}
+}
+
 func TestAuthService_AccountLocking(t *testing.T) {
 	userRepo := newMockUserRepository()
-	svc := NewAuthService(userRepo)
+	svc := NewAuthService(userRepo, nil)
 	ctx := context.Background()
 
 	// 创建测试用户
@@ -99,10 +259,102 @@ func TestAuthService_AccountLocking(t *testing.T) {
 	}
 }
 
+// TestAuthService_LockExpiry 测试锁定到期后自动解锁
+func TestAuthService_LockExpiry(t *testing.T) {
+	userRepo := newMockUserRepository()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	svc := NewAuthService(userRepo, &AuthServiceConfig{
+		Now: func() time.Time { return now },
+	})
+	ctx := context.Background()
+
+	user := &model.User{
+		Username: "expirytest",
+		Email:    "expiry@example.com",
+		Status:   model.StatusActive,
+	}
+	user.SetPassword("Test1234")
+	userRepo.Create(ctx, user)
+
+	for i := 0; i < MaxFailedAttempts; i++ {
+		svc.Authenticate(ctx, "expirytest", "wrong")
+	}
+
+	// 锁定期内即使密码正确也无法登录
+	now = now.Add(LockDuration - time.Second)
+	if _, err := svc.Authenticate(ctx, "expirytest", "Test1234"); err != ErrAccountLocked {
+		t.Errorf("期望 ErrAccountLocked, 实际 %v", err)
+	}
+
+	// 锁定到期后，单次失败不会立即重新锁定
+	now = now.Add(2 * time.Second)
+	if _, err := svc.Authenticate(ctx, "expirytest", "wrong"); err != ErrInvalidCredentials {
+		t.Errorf("期望 ErrInvalidCredentials, 实际 %v", err)
+	}
+
+	// 可以重新登录，且失败次数清零
+	result, err := svc.Authenticate(ctx, "expirytest", "Test1234")
+	if err != nil {
+		t.Fatalf("锁定到期后登录失败: %v", err)
+	}
+	if result.FailedLoginCount != 0 || result.LockedUntil != nil {
+		t.Errorf("登录成功后应清零失败次数, 实际 %d", result.FailedLoginCount)
+	}
+}
+
+// TestAuthService_GetLockoutStatus 测试查询账户锁定状态
+func TestAuthService_GetLockoutStatus(t *testing.T) {
+	userRepo := newMockUserRepository()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	svc := NewAuthService(userRepo, &AuthServiceConfig{
+		Now: func() time.Time { return now },
+	})
+	ctx := context.Background()
+
+	user := &model.User{
+		Username: "lockstatus",
+		Email:    "lockstatus@example.com",
+		Status:   model.StatusActive,
+	}
+	user.SetPassword("Test1234")
+	userRepo.Create(ctx, user)
+
+	for i := 0; i < MaxFailedAttempts; i++ {
+		svc.Authenticate(ctx, "lockstatus", "wrong")
+	}
+
+	status, err := svc.GetLockoutStatus(ctx, "lockstatus")
+	if err != nil {
+		t.Fatalf("查询锁定状态失败: %v", err)
+	}
+	if !status.Locked || status.UnlockAt == nil || !status.UnlockAt.After(now) {
+		t.Fatalf("期望锁定且解锁时间在未来, 实际 %+v", status)
+	}
+	if status.RetryAfter != int(LockDuration/time.Second) {
+		t.Errorf("期望 %d 秒后解锁, 实际 %d", int(LockDuration/time.Second), status.RetryAfter)
+	}
+
+	// 不存在的用户与未锁定的用户结果相同
+	unknown, err := svc.GetLockoutStatus(ctx, "nonexistent")
+	if err != nil {
+		t.Fatalf("查询锁定状态失败: %v", err)
+	}
+	if unknown.Locked || unknown.UnlockAt != nil || unknown.RetryAfter != 0 {
+		t.Errorf("未知用户期望中性状态, 实际 %+v", unknown)
+	}
+
+	// 锁定到期后视为未锁定
+	now = now.Add(LockDuration)
+	status, _ = svc.GetLockoutStatus(ctx, "lockstatus")
+	if status.Locked || status.UnlockAt != nil {
+		t.Errorf("锁定到期后期望未锁定, 实际 %+v", status)
+	}
+}
+
 // TestAuthService_ChangePassword 测试修改密码
 func TestAuthService_ChangePassword(t *testing.T) {
 	userRepo := newMockUserRepository()
-	svc := NewAuthService(userRepo)
+	svc := NewAuthService(userRepo, nil)
 	ctx := context.Background()
 
 	// 创建测试用户
@@ -143,7 +395,7 @@ func TestAuthService_ChangePassword(t *testing.T) {
 // TestAuthService_UnlockAccount 测试解锁账户
 func TestAuthService_UnlockAccount(t *testing.T) {
 	userRepo := newMockUserRepository()
-	svc := NewAuthService(userRepo)
+	svc := NewAuthService(userRepo, nil)
 	ctx := context.Background()
 
 	// 创建测试用户
@@ -180,8 +432,8 @@ func TestAuthService_UnlockAccount(t *testing.T) {
 	}
 }
 
-// TestIsPasswordStrong 测试密码强度检查
-func TestIsPasswordStrong(t *testing.T) {
+// TestPasswordPolicy_Default 测试默认密码策略
+func TestPasswordPolicy_Default(t *testing.T) {
 	tests := []struct {
 		password string
 		want     bool
@@ -196,12 +448,280 @@ func TestIsPasswordStrong(t *testing.T) {
 		{"", false},          // 空
 	}
 
+	policy := DefaultPasswordPolicy()
 	for _, tt := range tests {
 		t.Run(tt.password, func(t *testing.T) {
-			got := IsPasswordStrong(tt.password)
-			if got != tt.want {
-				t.Errorf("IsPasswordStrong(%q) = %v, want %v", tt.password, got, tt.want)
+			err := policy.Validate(tt.password)
+			if (err == nil) != tt.want {
+				t.Errorf("Validate(%q) = %v, want ok=%v", tt.password, err, tt.want)
 			}
 		})
 	}
 }
+
+// TestPasswordPolicy_Rules 测试各规则单独生效并返回对应的错误项
+func TestPasswordPolicy_Rules(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    PasswordPolicy
+		password  string
+		violation string
+	}{
+		{"最小长度", PasswordPolicy{MinLength: 12}, "short", "长度至少 12 位"},
+		{"大写字母", PasswordPolicy{RequireUpper: true}, "lower1!", "需要包含大写字母"},
+		{"小写字母", PasswordPolicy{RequireLower: true}, "UPPER1!", "需要包含小写字母"},
+		{"数字", PasswordPolicy{RequireDigit: true}, "NoDigits!", "需要包含数字"},
+		{"特殊字符", PasswordPolicy{RequireSpecial: true}, "Passw0rd", "需要包含特殊字符"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Validate(tt.password)
+			var policyErr *PasswordPolicyError
+			if !errors.As(err, &policyErr) {
+				t.Fatalf("期望 *PasswordPolicyError, 实际 %v", err)
+			}
+			if len(policyErr.Violations) != 1 || policyErr.Violations[0] != tt.violation {
+				t.Errorf("期望违反项 [%s], 实际 %v", tt.violation, policyErr.Violations)
+			}
+		})
+	}
+
+	// 特殊字符包含标点和符号
+	special := PasswordPolicy{RequireSpecial: true}
+	for _, password := range []string{"a!", "a#", "a+", "a~", "a，"} {
+		if err := special.Validate(password); err != nil {
+			t.Errorf("Validate(%q) 期望通过, 实际 %v", password, err)
+		}
+	}
+
+	// 多项不满足时全部列出
+	err := PasswordPolicy{MinLength: 10, RequireUpper: true, RequireSpecial: true}.Validate("abc")
+	want := "密码强度不足：长度至少 10 位；需要包含大写字母；需要包含特殊字符"
+	if err == nil || err.Error() != want {
+		t.Errorf("期望 %q, 实际 %v", want, err)
+	}
+}
+
+// setupPasswordResetTest 创建密码重置测试环境
+func setupPasswordResetTest(t *testing.T) (AuthService, *mockUserRepository, TokenService, SessionService, *captureEmailSender, *model.User) {
+	client, cleanup := setupTestRedis(t)
+	t.Cleanup(cleanup)
+
+	userRepo := newMockUserRepository()
+	tokenSvc := newTestTokenService()
+	sessionSvc := NewSessionService(client, nil)
+	sender := &captureEmailSender{}
+	svc := NewAuthService(userRepo, &AuthServiceConfig{
+		Redis:          client,
+		TokenService:   tokenSvc,
+		SessionService: sessionSvc,
+		EmailSender:    sender,
+		ResetURL:       "http://localhost:8080/reset-password",
+	})
+
+	user := &model.User{Username: "resetuser", Email: "reset@example.com", Status: model.StatusActive}
+	user.SetPassword("OldPass123")
+	userRepo.Create(context.Background(), user)
+	return svc, userRepo, tokenSvc, sessionSvc, sender, user
+}
+
+// TestAuthService_PasswordReset 测试忘记密码与重置流程
+func TestAuthService_PasswordReset(t *testing.T) {
+	svc, userRepo, tokenSvc, sessionSvc, sender, user := setupPasswordResetTest(t)
+	ctx := context.Background()
+
+	session := &model.Session{UserID: user.ID}
+	if err := sessionSvc.Create(ctx, session); err != nil {
+		t.Fatalf("创建会话失败: %v", err)
+	}
+	refreshToken, _ := tokenSvc.GenerateRefreshToken(ctx, &TokenClaims{UserID: user.ID})
+
+	// 令牌签发时间精度为秒，确保重置发生在之后的一秒
+	time.Sleep(time.Second)
+
+	if err := svc.InitiatePasswordReset(ctx, "reset@example.com"); err != nil {
+		t.Fatalf("发起重置失败: %v", err)
+	}
+	token := sender.tokenFromBody(t)
+
+	if err := svc.CompletePasswordReset(ctx, token, "NewPass123"); err != nil {
+		t.Fatalf("完成重置失败: %v", err)
+	}
+
+	updated, _ := userRepo.GetByID(ctx, user.ID)
+	if !updated.VerifyPassword("NewPass123") {
+		t.Error("新密码应生效")
+	}
+
+	// 旧刷新令牌与会话应失效
+	if _, err := tokenSvc.ValidateToken(ctx, refreshToken); err == nil {
+		t.Error("重置密码后旧刷新令牌应失效")
+	}
+	if _, err := sessionSvc.Get(ctx, session.ID); err == nil {
+		t.Error("重置密码后会话应失效")
+	}
+
+	// 重置令牌只能使用一次
+	if err := svc.CompletePasswordReset(ctx, token, "Another123"); err != ErrResetTokenInvalid {
+		t.Errorf("期望错误 %v, 实际 %v", ErrResetTokenInvalid, err)
+	}
+}
+
+// TestAuthService_PasswordReset_Concurrent 测试并发提交同一重置令牌只有一个成功
+func TestAuthService_PasswordReset_Concurrent(t *testing.T) {
+	svc, _, _, _, sender, _ := setupPasswordResetTest(t)
+	ctx := context.Background()
+
+	if err := svc.InitiatePasswordReset(ctx, "reset@example.com"); err != nil {
+		t.Fatalf("发起重置失败: %v", err)
+	}
+	token := sender.tokenFromBody(t)
+
+	const n = 8
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded := 0
+	start := make(chan struct{})
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			err := svc.CompletePasswordReset(ctx, token, "NewPass123")
+			if err == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			} else if err != ErrResetTokenInvalid {
+				t.Errorf("期望错误 %v, 实际 %v", ErrResetTokenInvalid, err)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if succeeded != 1 {
+		t.Errorf("同一重置令牌应只有一次成功, 实际 %d 次", succeeded)
+	}
+}
+
+// TestAuthService_PasswordReset_ReusedPasswordKeepsToken 测试重用旧密码时不消耗重置令牌
+func TestAuthService_PasswordReset_ReusedPasswordKeepsToken(t *testing.T) {
+	client, cleanup := setupTestRedis(t)
+	t.Cleanup(cleanup)
+	ctx := context.Background()
+
+	userRepo := newMockUserRepository()
+	sender := &captureEmailSender{}
+	svc := NewAuthService(userRepo, &AuthServiceConfig{
+		Redis:           client,
+		EmailSender:     sender,
+		ResetURL:        "http://localhost:8080/reset-password",
+		PasswordHistory: newMockPasswordHistoryRepository(),
+	})
+	user := &model.User{Username: "resetuser", Email: "reset@example.com", Status: model.StatusActive}
+	user.SetPassword("OldPass123")
+	userRepo.Create(ctx, user)
+
+	if err := svc.InitiatePasswordReset(ctx, user.Email); err != nil {
+		t.Fatalf("发起重置失败: %v", err)
+	}
+	token := sender.tokenFromBody(t)
+
+	if err := svc.CompletePasswordReset(ctx, token, "OldPass123"); !errors.Is(err, ErrPasswordReused) {
+		t.Fatalf("期望错误 %v, 实际 %v", ErrPasswordReused, err)
+	}
+	// 令牌放回后保留原有效期
+	if ttl := client.TTL(ctx, passwordResetKeyPrefix+token).Val(); ttl <= 0 || ttl > PasswordResetExpiry {
+		t.Errorf("放回的令牌应保留原有效期, 实际 %v", ttl)
+	}
+	if err := svc.CompletePasswordReset(ctx, token, "NewPass123"); err != nil {
+		t.Fatalf("换用新密码后重置应成功: %v", err)
+	}
+	if err := svc.CompletePasswordReset(ctx, token, "Another123"); err != ErrResetTokenInvalid {
+		t.Errorf("期望错误 %v, 实际 %v", ErrResetTokenInvalid, err)
+	}
+}
+
+// TestAuthService_InitiatePasswordReset_UnknownEmail 测试未注册邮箱不泄露账户信息
+func TestAuthService_InitiatePasswordReset_UnknownEmail(t *testing.T) {
+	svc, _, _, _, sender, _ := setupPasswordResetTest(t)
+
+	if err := svc.InitiatePasswordReset(context.Background(), "unknown@example.com"); err != nil {
+		t.Errorf("未注册邮箱应返回成功, 实际 %v", err)
+	}
+	if sender.count != 0 {
+		t.Error("未注册邮箱不应发送邮件")
+	}
+}
+
+// TestAuthService_InvalidatePendingTokens 测试作废用户未使用的重置和验证令牌
+func TestAuthService_InvalidatePendingTokens(t *testing.T) {
+	client, cleanup := setupTestRedis(t)
+	t.Cleanup(cleanup)
+	ctx := context.Background()
+
+	userRepo := newMockUserRepository()
+	sender := &captureEmailSender{}
+	svc := NewAuthService(userRepo, &AuthServiceConfig{
+		Redis:       client,
+		EmailSender: sender,
+		ResetURL:    "http://localhost:8080/reset-password",
+	})
+	userSvc := NewUserService(userRepo, newMockBindingRepository(), nil)
+	verifySvc := NewEmailVerificationService(client, userSvc, sender, "http://localhost:8080/api/v1/auth/verify-email")
+
+	user := &model.User{Username: "victim", Email: "victim@example.com", Status: model.StatusActive}
+	user.SetPassword("OldPass123")
+	userRepo.Create(ctx, user)
+	other := &model.User{Username: "other", Email: "other@example.com", Status: model.StatusActive}
+	other.SetPassword("OldPass123")
+	userRepo.Create(ctx, other)
+
+	if err := svc.InitiatePasswordReset(ctx, user.Email); err != nil {
+		t.Fatalf("发起重置失败: %v", err)
+	}
+	resetToken := sender.tokenFromBody(t)
+	if err := verifySvc.SendVerificationEmail(ctx, user.ID); err != nil {
+		t.Fatalf("发送验证邮件失败: %v", err)
+	}
+	verifyToken := sender.tokenFromBody(t)
+	if err := svc.InitiatePasswordReset(ctx, other.Email); err != nil {
+		t.Fatalf("发起重置失败: %v", err)
+	}
+	otherToken := sender.tokenFromBody(t)
+
+	count, err := svc.InvalidatePendingTokens(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("作废令牌失败: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("期望作废 2 个令牌, 实际 %d", count)
+	}
+
+	// 作废前签发的令牌不再可用
+	if err := svc.CompletePasswordReset(ctx, resetToken, "NewPass123"); err != ErrResetTokenInvalid {
+		t.Errorf("期望错误 %v, 实际 %v", ErrResetTokenInvalid, err)
+	}
+	if err := verifySvc.VerifyEmail(ctx, verifyToken); err != ErrVerificationTokenInvalid {
+		t.Errorf("期望错误 %v, 实际 %v", ErrVerificationTokenInvalid, err)
+	}
+	updated, _ := userRepo.GetByID(ctx, user.ID)
+	if !updated.VerifyPassword("OldPass123") {
+		t.Error("作废后不应能通过旧令牌修改密码")
+	}
+
+	// 其他用户的令牌不受影响
+	if err := svc.CompletePasswordReset(ctx, otherToken, "NewPass123"); err != nil {
+		t.Errorf("其他用户的重置令牌应仍然有效: %v", err)
+	}
+
+	// 已使用的令牌从索引中移除，不会重复计数
+	if count, err := svc.InvalidatePendingTokens(ctx, other.ID); err != nil || count != 0 {
+		t.Errorf("期望没有可作废的令牌, 实际 %d, %v", count, err)
+	}
+	if _, err := svc.InvalidatePendingTokens(ctx, "missing"); err != ErrUserNotFound {
+		t.Errorf("期望错误 %v, 实际 %v", ErrUserNotFound, err)
+	}
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/pu-ac-cn/uac-backend/internal/model"
+	"github.com/pu-ac-cn/uac-backend/internal/repository"
+)
+
+// ConsentService 用户授权记录服务
+type ConsentService interface {
+	// HasConsent 用户是否已同意应用申请的全部 scope
+	HasConsent(ctx context.Context, userID, clientID string, scopes []string) (bool, error)
+	// Grant 记录用户同意的 scope，与此前已同意的 scope 合并
+	Grant(ctx context.Context, userID, clientID string, scopes []string) error
+	// ListByUser 列出用户授权过的应用
+	ListByUser(ctx context.Context, userID string) ([]*model.UserConsent, error)
+	// Revoke 撤销用户对应用的授权，并使该应用此前获得的该用户令牌失效
+	Revoke(ctx context.Context, userID, clientID string) error
+}
+
+// ErrConsentNotFound 用户未授权过该应用
+var ErrConsentNotFound = errors.New("未授权过该应用")
+
+type consentService struct {
+	repo         repository.ConsentRepository
+	tokenService TokenService
+}
+
+// NewConsentService 创建用户授权记录服务
+// tokenSvc 可选，为空时撤销授权不使已签发的令牌失效
+func NewConsentService(repo repository.ConsentRepository, tokenSvc TokenService) ConsentService {
+	return &consentService{repo: repo, tokenService: tokenSvc}
+}
+
+func (s *consentService) HasConsent(ctx context.Context, userID, clientID string, scopes []string) (bool, error) {
+	consent, err := s.repo.Get(ctx, userID, clientID)
+	if err != nil {
+		if errors.Is(err, repository.ErrConsentNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	for _, scope := range scopes {
+		if scope != "" && !containsString(consent.Scopes, scope) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (s *consentService) Grant(ctx context.Context, userID, clientID string, scopes []string) error {
+	granted := model.StringSlice{}
+	consent, err := s.repo.Get(ctx, userID, clientID)
+	switch {
+	case err == nil:
+		granted = append(granted, consent.Scopes...)
+	case !errors.Is(err, repository.ErrConsentNotFound):
+		return err
+	}
+	for _, scope := range scopes {
+		if scope != "" && !containsString(granted, scope) {
+			granted = append(granted, scope)
+		}
+	}
+	return s.repo.Save(ctx, &model.UserConsent{UserID: userID, ClientID: clientID, Scopes: granted})
+}
+
+func (s *consentService) ListByUser(ctx context.Context, userID string) ([]*model.UserConsent, error) {
+	return s.repo.ListByUser(ctx, userID)
+}
+
+func (s *consentService) Revoke(ctx context.Context, userID, clientID string) error {
+	if err := s.repo.Delete(ctx, userID, clientID); err != nil {
+		if errors.Is(err, repository.ErrConsentNotFound) {
+			return ErrConsentNotFound
+		}
+		return err
+	}
+	if s.tokenService == nil {
+		return nil
+	}
+	return s.tokenService.RevokeUserClientTokens(ctx, userID, clientID)
+}
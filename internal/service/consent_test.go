@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pu-ac-cn/uac-backend/internal/model"
+	"github.com/pu-ac-cn/uac-backend/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockConsentRepository 内存授权记录仓库
+type mockConsentRepository struct {
+	consents map[string]*model.UserConsent
+}
+
+func newMockConsentRepository() *mockConsentRepository {
+	return &mockConsentRepository{consents: make(map[string]*model.UserConsent)}
+}
+
+func (m *mockConsentRepository) Get(ctx context.Context, userID, clientID string) (*model.UserConsent, error) {
+	if consent, ok := m.consents[userID+"/"+clientID]; ok {
+		return consent, nil
+	}
+	return nil, repository.ErrConsentNotFound
+}
+
+func (m *mockConsentRepository) Save(ctx context.Context, consent *model.UserConsent) error {
+	m.consents[consent.UserID+"/"+consent.ClientID] = consent
+	return nil
+}
+
+func (m *mockConsentRepository) ListByUser(ctx context.Context, userID string) ([]*model.UserConsent, error) {
+	var result []*model.UserConsent
+	for _, consent := range m.consents {
+		if consent.UserID == userID {
+			result = append(result, consent)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockConsentRepository) Delete(ctx context.Context, userID, clientID string) error {
+	key := userID + "/" + clientID
+	if _, ok := m.consents[key]; !ok {
+		return repository.ErrConsentNotFound
+	}
+	delete(m.consents, key)
+	return nil
+}
+
+func TestConsentService_GrantAndHasConsent(t *testing.T) {
+	repo := newMockConsentRepository()
+	svc := NewConsentService(repo, nil)
+	ctx := context.Background()
+
+	ok, err := svc.HasConsent(ctx, "user-1", "client-a", []string{"openid"})
+	require.NoError(t, err)
+	assert.False(t, ok, "未授权过的应用")
+
+	require.NoError(t, svc.Grant(ctx, "user-1", "client-a", []string{"openid", "profile"}))
+	ok, err = svc.HasConsent(ctx, "user-1", "client-a", []string{"openid"})
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// 申请未同意过的 scope 需要重新确认
+	ok, err = svc.HasConsent(ctx, "user-1", "client-a", []string{"openid", "email"})
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// 再次授权与已同意的 scope 合并
+	require.NoError(t, svc.Grant(ctx, "user-1", "client-a", []string{"email", "openid"}))
+	assert.Equal(t, model.StringSlice{"openid", "profile", "email"}, repo.consents["user-1/client-a"].Scopes)
+
+	// 授权记录按用户区分
+	ok, err = svc.HasConsent(ctx, "user-2", "client-a", []string{"openid"})
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestConsentService_Revoke(t *testing.T) {
+	tokenService := newTestTokenService()
+	svc := NewConsentService(newMockConsentRepository(), tokenService)
+	ctx := context.Background()
+
+	require.NoError(t, svc.Grant(ctx, "user-1", "client-a", []string{"openid"}))
+	require.NoError(t, svc.Grant(ctx, "user-1", "client-b", []string{"openid"}))
+	refresh := func(userID, clientID string) string {
+		token, err := tokenService.GenerateRefreshToken(ctx, &TokenClaims{UserID: userID, ClientID: clientID, FamilyID: userID + clientID})
+		require.NoError(t, err)
+		return token
+	}
+	revokedToken := refresh("user-1", "client-a")
+	otherClientToken := refresh("user-1", "client-b")
+	otherUserToken := refresh("user-2", "client-a")
+
+	// 撤销以秒为界，等到下一秒使上面的令牌早于撤销时间
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+	require.NoError(t, svc.Revoke(ctx, "user-1", "client-a"))
+
+	_, err := tokenService.ValidateToken(ctx, revokedToken)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	_, err = tokenService.ValidateToken(ctx, otherClientToken)
+	assert.NoError(t, err, "其他应用的令牌不受影响")
+	_, err = tokenService.ValidateToken(ctx, otherUserToken)
+	assert.NoError(t, err, "其他用户的令牌不受影响")
+
+	// 撤销后重新授权签发的令牌有效
+	_, err = tokenService.ValidateToken(ctx, refresh("user-1", "client-a"))
+	assert.NoError(t, err)
+
+	consents, err := svc.ListByUser(ctx, "user-1")
+	require.NoError(t, err)
+	require.Len(t, consents, 1)
+	assert.Equal(t, "client-b", consents[0].ClientID)
+
+	assert.ErrorIs(t, svc.Revoke(ctx, "user-1", "client-a"), ErrConsentNotFound)
+}
@@ -0,0 +1,28 @@
+// Package service 业务逻辑层
+package service
+
+import (
+	"context"
+	"log"
+)
+
+// EmailSender 邮件发送接口
+// 默认实现仅记录日志，接入 SMTP 等真实通道时替换实现即可
+type EmailSender interface {
+	Send(ctx context.Context, to, subject, body string) error
+}
+
+// logEmailSender 仅打印日志的邮件发送实现
+type logEmailSender struct{}
+
+// NewLogEmailSender 创建仅记录日志的邮件发送器
+func NewLogEmailSender() EmailSender {
+	return &logEmailSender{}
+}
+
+// Send 将收件人和主题输出到日志
+// 正文包含密码重置、邮箱验证等一次性链接，不写入日志
+func (s *logEmailSender) Send(ctx context.Context, to, subject, body string) error {
+	log.Printf("发送邮件 -> %s 主题: %s", to, subject)
+	return nil
+}
@@ -0,0 +1,30 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestLogEmailSender_Send 测试日志发送器不输出邮件正文
+func TestLogEmailSender_Send(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	body := "点击链接重置密码: https://example.com/reset-password?token=secret-token"
+	if err := NewLogEmailSender().Send(context.Background(), "user@example.com", "重置密码", body); err != nil {
+		t.Fatalf("发送邮件失败: %v", err)
+	}
+
+	output := buf.String()
+	if !strings.Contains(output, "user@example.com") || !strings.Contains(output, "重置密码") {
+		t.Errorf("日志应包含收件人和主题: %s", output)
+	}
+	if strings.Contains(output, "secret-token") {
+		t.Errorf("日志不应包含邮件正文: %s", output)
+	}
+}
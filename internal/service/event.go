@@ -0,0 +1,116 @@
+// Package service 业务逻辑层
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// 事件类型
+const (
+	EventUserFirstLogin       = "user.first_login"       // 用户首次登录
+	EventUserDisabledInactive = "user.disabled_inactive" // 用户长期未登录被自动禁用
+)
+
+// Event 对外集成的业务事件
+type Event struct {
+	Type       string                 `json:"type"`
+	UserID     string                 `json:"user_id"`
+	OccurredAt time.Time              `json:"occurred_at"`
+	Data       map[string]interface{} `json:"data,omitempty"`
+}
+
+// EventPublisher 事件发布接口
+// 默认实现仅记录日志，配置 Webhook 地址后推送到外部系统
+type EventPublisher interface {
+	Publish(ctx context.Context, event *Event) error
+}
+
+// logEventPublisher 仅打印日志的事件发布实现
+type logEventPublisher struct{}
+
+// NewLogEventPublisher 创建仅记录日志的事件发布器
+func NewLogEventPublisher() EventPublisher {
+	return &logEventPublisher{}
+}
+
+// Publish 将事件输出到日志
+func (p *logEventPublisher) Publish(ctx context.Context, event *Event) error {
+	log.Printf("事件 %s 用户: %s", event.Type, event.UserID)
+	return nil
+}
+
+// WebhookSignatureHeader Webhook 请求签名头，值为 sha256=<HMAC-SHA256(secret, body) 十六进制>
+const WebhookSignatureHeader = "X-UAC-Signature"
+
+// webhookTimeout Webhook 请求超时时间
+const webhookTimeout = 5 * time.Second
+
+// webhookEventPublisher 以 HTTP POST 推送事件的发布实现
+type webhookEventPublisher struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+// NewWebhookEventPublisher 创建 Webhook 事件发布器
+// 事件在后台异步推送，失败只记录日志，不影响登录等主流程
+func NewWebhookEventPublisher(url, secret string) EventPublisher {
+	return &webhookEventPublisher{
+		url:    url,
+		secret: secret,
+		client: &http.Client{Timeout: webhookTimeout},
+	}
+}
+
+// Publish 异步推送事件
+func (p *webhookEventPublisher) Publish(ctx context.Context, event *Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("序列化事件失败: %w", err)
+	}
+
+	go func() {
+		if err := p.send(body); err != nil {
+			log.Printf("推送事件 %s 失败: %v", event.Type, err)
+		}
+	}()
+	return nil
+}
+
+// send 发送 Webhook 请求
+func (p *webhookEventPublisher) send(body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.secret != "" {
+		req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(p.secret, body))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Webhook 返回状态码 %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// SignWebhookPayload 计算 Webhook 请求体签名，接收方可用同一密钥校验
+func SignWebhookPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
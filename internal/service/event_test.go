@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestWebhookEventPublisher 测试 Webhook 推送事件并携带签名
+func TestWebhookEventPublisher(t *testing.T) {
+	type delivery struct {
+		body      []byte
+		signature string
+	}
+	received := make(chan delivery, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- delivery{body: body, signature: r.Header.Get(WebhookSignatureHeader)}
+	}))
+	defer server.Close()
+
+	publisher := NewWebhookEventPublisher(server.URL, "webhook-secret")
+	err := publisher.Publish(context.Background(), &Event{Type: EventUserFirstLogin, UserID: "user-123", OccurredAt: time.Now()})
+	if err != nil {
+		t.Fatalf("发布事件失败: %v", err)
+	}
+
+	select {
+	case d := <-received:
+		var event Event
+		if err := json.Unmarshal(d.body, &event); err != nil {
+			t.Fatalf("解析事件失败: %v", err)
+		}
+		if event.Type != EventUserFirstLogin || event.UserID != "user-123" {
+			t.Errorf("事件内容不匹配: %+v", event)
+		}
+		if d.signature != SignWebhookPayload("webhook-secret", d.body) {
+			t.Errorf("签名不匹配: %s", d.signature)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("未收到 Webhook 请求")
+	}
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/pu-ac-cn/uac-backend/internal/model"
+	"github.com/pu-ac-cn/uac-backend/internal/repository"
+)
+
+// 未登录账户自动禁用默认参数
+const (
+	DefaultInactivityThreshold     = 90 * 24 * time.Hour // 默认未登录时长阈值
+	DefaultInactivityCheckInterval = 24 * time.Hour      // 默认检查间隔
+	DefaultInactivityBatchSize     = 500                 // 默认每批处理的用户数
+)
+
+// InactivityService 长期未登录账户自动禁用服务
+type InactivityService interface {
+	// DisableInactiveUsers 禁用超过阈值未登录的用户，返回被禁用（试运行时为将被禁用）的用户
+	DisableInactiveUsers(ctx context.Context) ([]*model.User, error)
+	// Run 立即执行一次检查，之后按 interval 周期执行，ctx 结束后返回
+	Run(ctx context.Context, interval time.Duration)
+}
+
+// InactivityServiceConfig 未登录账户自动禁用配置
+type InactivityServiceConfig struct {
+	Threshold         time.Duration    // 未登录时长阈值，默认 90 天
+	ExemptUsernames   []string         // 不受影响的服务账号
+	ExemptSuperAdmins bool             // 是否跳过超级管理员
+	DryRun            bool             // 试运行，仅记录不修改
+	BatchSize         int              // 每批查询的用户数，默认 500
+	Events            EventPublisher   // 事件发布器，默认仅记录日志
+	Now               func() time.Time // 当前时间，便于测试
+}
+
+type inactivityService struct {
+	userRepo     repository.UserRepository
+	userRoleRepo repository.UserRoleRepository
+	config       *InactivityServiceConfig
+	exempt       map[string]bool
+}
+
+// NewInactivityService 创建未登录账户自动禁用服务
+// userRoleRepo 仅在跳过超级管理员时使用，可为 nil
+func NewInactivityService(userRepo repository.UserRepository, userRoleRepo repository.UserRoleRepository, cfg *InactivityServiceConfig) InactivityService {
+	if cfg == nil {
+		cfg = &InactivityServiceConfig{}
+	}
+	if cfg.Threshold <= 0 {
+		cfg.Threshold = DefaultInactivityThreshold
+	}
+	if cfg.Events == nil {
+		cfg.Events = NewLogEventPublisher()
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = DefaultInactivityBatchSize
+	}
+	exempt := make(map[string]bool, len(cfg.ExemptUsernames))
+	for _, username := range cfg.ExemptUsernames {
+		exempt[username] = true
+	}
+	return &inactivityService{
+		userRepo:     userRepo,
+		userRoleRepo: userRoleRepo,
+		config:       cfg,
+		exempt:       exempt,
+	}
+}
+
+func (s *inactivityService) DisableInactiveUsers(ctx context.Context) ([]*model.User, error) {
+	now := s.config.Now()
+	before := now.Add(-s.config.Threshold)
+
+	var disabled []*model.User
+	afterID := ""
+	for {
+		users, err := s.userRepo.ListInactive(ctx, before, afterID, s.config.BatchSize)
+		if err != nil {
+			return disabled, err
+		}
+		for _, user := range users {
+			if s.disableUser(ctx, user, before, now) {
+				disabled = append(disabled, user)
+			}
+		}
+		if len(users) < s.config.BatchSize {
+			return disabled, nil
+		}
+		afterID = users[len(users)-1].ID
+	}
+}
+
+// disableUser 禁用单个未登录用户并发布事件，返回是否（试运行时为将会）被禁用
+// 用户在列出之后登录过或已被修改状态时条件更新不生效，跳过该用户
+func (s *inactivityService) disableUser(ctx context.Context, user *model.User, before, now time.Time) bool {
+	if s.isExempt(ctx, user) {
+		return false
+	}
+	if s.config.DryRun {
+		return true
+	}
+
+	ok, err := s.userRepo.DisableInactive(ctx, user.ID, before)
+	if err != nil {
+		log.Printf("禁用未登录用户 %s 失败: %v", user.ID, err)
+		return false
+	}
+	if !ok {
+		return false
+	}
+	user.Status = model.StatusDisabled
+
+	data := map[string]interface{}{
+		"username":  user.Username,
+		"threshold": s.config.Threshold.String(),
+	}
+	if user.LastLoginAt != nil {
+		data["last_login_at"] = *user.LastLoginAt
+	}
+	_ = s.config.Events.Publish(ctx, &Event{
+		Type:       EventUserDisabledInactive,
+		UserID:     user.ID,
+		OccurredAt: now,
+		Data:       data,
+	})
+	return true
+}
+
+// isExempt 服务账号及（按配置）超级管理员不受影响
+// 角色查询失败时保守地跳过该用户
+func (s *inactivityService) isExempt(ctx context.Context, user *model.User) bool {
+	if s.exempt[user.Username] {
+		return true
+	}
+	if !s.config.ExemptSuperAdmins || s.userRoleRepo == nil {
+		return false
+	}
+	isSuperAdmin, err := s.userRoleRepo.HasRole(ctx, user.ID, model.RoleSuperAdmin)
+	return err != nil || isSuperAdmin
+}
+
+func (s *inactivityService) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultInactivityCheckInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		users, err := s.DisableInactiveUsers(ctx)
+		switch {
+		case err != nil:
+			log.Printf("检查未登录账户失败: %v", err)
+		case s.config.DryRun && len(users) > 0:
+			log.Printf("试运行：%d 个账户超过 %s 未登录，将被禁用", len(users), s.config.Threshold)
+		case len(users) > 0:
+			log.Printf("已禁用 %d 个超过 %s 未登录的账户", len(users), s.config.Threshold)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pu-ac-cn/uac-backend/internal/model"
+)
+
+// setupInactivityTest 创建超过 90 天未登录、最近登录过、服务账号、超级管理员四个用户
+func setupInactivityTest(t *testing.T, dryRun bool) (*mockUserRepository, InactivityService, *captureEventPublisher) {
+	t.Helper()
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	longAgo := now.Add(-100 * 24 * time.Hour)
+	recently := now.Add(-24 * time.Hour)
+
+	userRepo := newMockUserRepository()
+	for _, user := range []*model.User{
+		{Username: "dormant", Email: "dormant@example.com", LastLoginAt: &longAgo},
+		{Username: "active", Email: "active@example.com", LastLoginAt: &recently},
+		{Username: "svc_sync", Email: "svc@example.com", LastLoginAt: &longAgo},
+		{Username: "root", Email: "root@example.com", LastLoginAt: &longAgo},
+	} {
+		user.Status = model.StatusActive
+		user.CreatedAt = longAgo
+		if err := userRepo.Create(context.Background(), user); err != nil {
+			t.Fatalf("创建用户失败: %v", err)
+		}
+	}
+
+	userRoleRepo := new(MockUserRoleRepository)
+	userRoleRepo.On("HasRole", context.Background(), "test-user-dormant", model.RoleSuperAdmin).Return(false, nil)
+	userRoleRepo.On("HasRole", context.Background(), "test-user-root", model.RoleSuperAdmin).Return(true, nil)
+
+	events := &captureEventPublisher{}
+	svc := NewInactivityService(userRepo, userRoleRepo, &InactivityServiceConfig{
+		Threshold:         90 * 24 * time.Hour,
+		ExemptUsernames:   []string{"svc_sync"},
+		ExemptSuperAdmins: true,
+		DryRun:            dryRun,
+		Events:            events,
+		Now:               func() time.Time { return now },
+	})
+	return userRepo, svc, events
+}
+
+// TestInactivityService_DisableInactiveUsers 测试超过阈值未登录的用户被禁用
+func TestInactivityService_DisableInactiveUsers(t *testing.T) {
+	userRepo, svc, events := setupInactivityTest(t, false)
+
+	disabled, err := svc.DisableInactiveUsers(context.Background())
+	if err != nil {
+		t.Fatalf("禁用未登录用户失败: %v", err)
+	}
+	if len(disabled) != 1 || disabled[0].Username != "dormant" {
+		t.Fatalf("期望仅禁用 dormant, 实际 %v", disabled)
+	}
+
+	expected := map[string]string{
+		"test-user-dormant":  model.StatusDisabled,
+		"test-user-active":   model.StatusActive,
+		"test-user-svc_sync": model.StatusActive,
+		"test-user-root":     model.StatusActive,
+	}
+	for id, status := range expected {
+		if got := userRepo.users[id].Status; got != status {
+			t.Errorf("用户 %s 状态期望 %s, 实际 %s", id, status, got)
+		}
+	}
+
+	if len(events.events) != 1 {
+		t.Fatalf("期望发布 1 个事件, 实际 %d", len(events.events))
+	}
+	if events.events[0].Type != EventUserDisabledInactive || events.events[0].UserID != "test-user-dormant" {
+		t.Errorf("事件不符: %+v", events.events[0])
+	}
+}
+
+// TestInactivityService_DryRun 测试试运行不修改用户状态
+func TestInactivityService_DryRun(t *testing.T) {
+	userRepo, svc, events := setupInactivityTest(t, true)
+
+	disabled, err := svc.DisableInactiveUsers(context.Background())
+	if err != nil {
+		t.Fatalf("试运行失败: %v", err)
+	}
+	if len(disabled) != 1 || disabled[0].Username != "dormant" {
+		t.Fatalf("期望报告 dormant 将被禁用, 实际 %v", disabled)
+	}
+	if got := userRepo.users["test-user-dormant"].Status; got != model.StatusActive {
+		t.Errorf("试运行不应修改状态, 实际 %s", got)
+	}
+	if len(events.events) != 0 {
+		t.Errorf("试运行不应发布事件, 实际 %d", len(events.events))
+	}
+}
+
+// loginAfterListRepository 在列出未登录用户后模拟这些用户立即登录
+type loginAfterListRepository struct {
+	*mockUserRepository
+	loginAt time.Time
+}
+
+func (r *loginAfterListRepository) ListInactive(ctx context.Context, before time.Time, afterID string, limit int) ([]*model.User, error) {
+	users, err := r.mockUserRepository.ListInactive(ctx, before, afterID, limit)
+	for _, user := range users {
+		r.users[user.ID].LastLoginAt = &r.loginAt
+	}
+	return users, err
+}
+
+// TestInactivityService_SkipsUserWhoLoggedIn 测试列出之后登录过的用户不会被禁用
+func TestInactivityService_SkipsUserWhoLoggedIn(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	longAgo := now.Add(-100 * 24 * time.Hour)
+	userRepo := newMockUserRepository()
+	user := &model.User{Username: "dormant", Email: "dormant@example.com", Status: model.StatusActive, LastLoginAt: &longAgo}
+	if err := userRepo.Create(context.Background(), user); err != nil {
+		t.Fatalf("创建用户失败: %v", err)
+	}
+
+	events := &captureEventPublisher{}
+	svc := NewInactivityService(&loginAfterListRepository{mockUserRepository: userRepo, loginAt: now}, nil, &InactivityServiceConfig{
+		Events: events,
+		Now:    func() time.Time { return now },
+	})
+	disabled, err := svc.DisableInactiveUsers(context.Background())
+	if err != nil {
+		t.Fatalf("禁用未登录用户失败: %v", err)
+	}
+	if len(disabled) != 0 {
+		t.Errorf("刚登录的用户不应被禁用, 实际 %v", disabled)
+	}
+	if got := userRepo.users[user.ID].Status; got != model.StatusActive {
+		t.Errorf("用户状态期望 %s, 实际 %s", model.StatusActive, got)
+	}
+	if len(events.events) != 0 {
+		t.Errorf("未禁用时不应发布事件, 实际 %d", len(events.events))
+	}
+}
+
+// TestInactivityService_Batches 测试分批处理全部未登录用户
+func TestInactivityService_Batches(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	longAgo := now.Add(-100 * 24 * time.Hour)
+	userRepo := newMockUserRepository()
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		user := &model.User{Username: name, Email: name + "@example.com", Status: model.StatusActive, LastLoginAt: &longAgo}
+		if err := userRepo.Create(context.Background(), user); err != nil {
+			t.Fatalf("创建用户失败: %v", err)
+		}
+	}
+
+	for _, dryRun := range []bool{true, false} {
+		svc := NewInactivityService(userRepo, nil, &InactivityServiceConfig{
+			BatchSize: 2,
+			DryRun:    dryRun,
+			Events:    &captureEventPublisher{},
+			Now:       func() time.Time { return now },
+		})
+		disabled, err := svc.DisableInactiveUsers(context.Background())
+		if err != nil {
+			t.Fatalf("禁用未登录用户失败: %v", err)
+		}
+		if len(disabled) != 5 {
+			t.Errorf("试运行 %v: 期望处理 5 个用户, 实际 %d", dryRun, len(disabled))
+		}
+	}
+	for id, user := range userRepo.users {
+		if user.Status != model.StatusDisabled {
+			t.Errorf("用户 %s 应被禁用", id)
+		}
+	}
+}
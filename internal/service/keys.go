@@ -0,0 +1,85 @@
+package service
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidPrivateKey 私钥格式无效或与签名算法不匹配
+var ErrInvalidPrivateKey = errors.New("无效的私钥")
+
+// ErrUnsupportedAlgorithm 不支持的令牌签名算法
+var ErrUnsupportedAlgorithm = errors.New("不支持的签名算法")
+
+// ParseSigningKeyPEM 解析 PEM 编码的签名私钥，私钥类型须与 alg 匹配：RS256 为 RSA，ES256 为 P-256 ECDSA
+// 支持 PKCS1（RSA PRIVATE KEY）、SEC1（EC PRIVATE KEY）和 PKCS8（PRIVATE KEY）格式
+// 文件和环境变量等所有来源的私钥都通过此函数解析
+func ParseSigningKeyPEM(data []byte, alg string) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%w: 不是 PEM 格式", ErrInvalidPrivateKey)
+	}
+
+	var key any
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%w: 不支持的 PEM 类型 %q", ErrInvalidPrivateKey, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPrivateKey, err)
+	}
+	return checkSigningKey(key, alg)
+}
+
+// ParseRSAPrivateKeyPEM 解析 PEM 编码的 RSA 私钥，支持 PKCS1 和 PKCS8 格式
+func ParseRSAPrivateKeyPEM(data []byte) (*rsa.PrivateKey, error) {
+	key, err := ParseSigningKeyPEM(data, AlgRS256)
+	if err != nil {
+		return nil, err
+	}
+	return key.(*rsa.PrivateKey), nil
+}
+
+// GenerateSigningKey 为 alg 生成新的签名私钥：RS256 生成 RSA 2048，ES256 生成 P-256 ECDSA
+func GenerateSigningKey(alg string) (crypto.Signer, error) {
+	switch alg {
+	case AlgRS256:
+		return rsa.GenerateKey(rand.Reader, 2048)
+	case AlgES256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedAlgorithm, alg)
+	}
+}
+
+// checkSigningKey 校验私钥类型与签名算法匹配
+func checkSigningKey(key any, alg string) (crypto.Signer, error) {
+	switch alg {
+	case AlgRS256:
+		if k, ok := key.(*rsa.PrivateKey); ok {
+			return k, nil
+		}
+		return nil, fmt.Errorf("%w: RS256 需要 RSA 私钥", ErrInvalidPrivateKey)
+	case AlgES256:
+		if k, ok := key.(*ecdsa.PrivateKey); ok && k.Curve == elliptic.P256() {
+			return k, nil
+		}
+		return nil, fmt.Errorf("%w: ES256 需要 P-256 ECDSA 私钥", ErrInvalidPrivateKey)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedAlgorithm, alg)
+	}
+}
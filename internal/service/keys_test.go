@@ -0,0 +1,102 @@
+package service
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRSAPrivateKeyPEM(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	formats := map[string]*pem.Block{
+		"PKCS1": {Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)},
+		"PKCS8": {Type: "PRIVATE KEY", Bytes: pkcs8},
+	}
+	for name, block := range formats {
+		t.Run(name, func(t *testing.T) {
+			parsed, err := ParseRSAPrivateKeyPEM(pem.EncodeToMemory(block))
+			require.NoError(t, err)
+			assert.True(t, key.Equal(parsed))
+		})
+	}
+}
+
+func TestParseRSAPrivateKeyPEM_Invalid(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	ecPKCS8, err := x509.MarshalPKCS8PrivateKey(ecKey)
+	require.NoError(t, err)
+
+	inputs := map[string][]byte{
+		"非 PEM":         []byte("not a key"),
+		"损坏的 PKCS1":     pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: []byte("garbage")}),
+		"非 RSA 的 PKCS8": pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: ecPKCS8}),
+		"公钥":            pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: []byte("x")}),
+	}
+	for name, data := range inputs {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseRSAPrivateKeyPEM(data)
+			assert.ErrorIs(t, err, ErrInvalidPrivateKey)
+		})
+	}
+}
+
+func TestParseSigningKeyPEM_ES256(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	sec1, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	for name, block := range map[string]*pem.Block{
+		"SEC1":  {Type: "EC PRIVATE KEY", Bytes: sec1},
+		"PKCS8": {Type: "PRIVATE KEY", Bytes: pkcs8},
+	} {
+		t.Run(name, func(t *testing.T) {
+			parsed, err := ParseSigningKeyPEM(pem.EncodeToMemory(block), AlgES256)
+			require.NoError(t, err)
+			assert.True(t, key.Equal(parsed))
+
+			// 私钥类型与算法不匹配
+			_, err = ParseSigningKeyPEM(pem.EncodeToMemory(block), AlgRS256)
+			assert.ErrorIs(t, err, ErrInvalidPrivateKey)
+		})
+	}
+
+	// 非 P-256 曲线不能用于 ES256
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	p384Bytes, err := x509.MarshalECPrivateKey(p384)
+	require.NoError(t, err)
+	_, err = ParseSigningKeyPEM(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: p384Bytes}), AlgES256)
+	assert.ErrorIs(t, err, ErrInvalidPrivateKey)
+
+	_, err = ParseSigningKeyPEM(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: sec1}), "HS256")
+	assert.ErrorIs(t, err, ErrUnsupportedAlgorithm)
+}
+
+func TestGenerateSigningKey(t *testing.T) {
+	rsaKey, err := GenerateSigningKey(AlgRS256)
+	require.NoError(t, err)
+	assert.IsType(t, &rsa.PrivateKey{}, rsaKey)
+
+	ecKey, err := GenerateSigningKey(AlgES256)
+	require.NoError(t, err)
+	assert.IsType(t, &ecdsa.PrivateKey{}, ecKey)
+
+	_, err = GenerateSigningKey("none")
+	assert.ErrorIs(t, err, ErrUnsupportedAlgorithm)
+}
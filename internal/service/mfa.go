@@ -0,0 +1,411 @@
+// Package service 多因素认证服务
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pu-ac-cn/uac-backend/internal/model"
+	"github.com/pu-ac-cn/uac-backend/internal/repository"
+	"github.com/redis/go-redis/v9"
+)
+
+// MFA 相关错误
+var (
+	ErrMFAAlreadyEnabled = errors.New("多因素认证已开启")
+	ErrMFANotEnrolled    = errors.New("尚未绑定身份验证器")
+	ErrInvalidTOTPCode   = errors.New("验证码错误")
+	ErrMFATokenInvalid   = errors.New("MFA 令牌无效或已过期")
+	ErrMFANotEnabled     = errors.New("多因素认证未开启")
+	ErrInvalidRecovery   = errors.New("恢复码错误或已使用")
+)
+
+// TOTP 参数（RFC 6238 默认值，兼容主流身份验证器）
+const (
+	TOTPDigits = 6
+	TOTPPeriod = 30 * time.Second
+	// TOTPSkew 允许前后各偏移的时间步数，容忍客户端时钟误差
+	TOTPSkew = 1
+	// totpSecretSize 密钥字节数（160 位）
+	totpSecretSize = 20
+)
+
+// RecoveryCodeCount 每次生成的恢复码数量
+const RecoveryCodeCount = 10
+
+// recoveryCodeSize 恢复码随机字节数，编码后为 10 个字符，按 5-5 分组展示
+const recoveryCodeSize = 6
+
+// MFATokenExpiry 登录第二步使用的 mfa_token 有效期
+const MFATokenExpiry = 5 * time.Minute
+
+// MaxMFAAttempts 同一个 mfa_token 允许的验证码错误次数，超过后令牌作废
+const MaxMFAAttempts = 5
+
+// DefaultTOTPIssuer 默认的 otpauth URI 发行方名称
+const DefaultTOTPIssuer = "UAC"
+
+// mfa 相关 Redis key 前缀
+const (
+	mfaTokenKeyPrefix    = "mfa_token:"
+	mfaAttemptsKeyPrefix = "mfa_attempts:"
+	totpUsedKeyPrefix    = "totp_used:"
+	recoveryUsedPrefix   = "recovery_used:"
+)
+
+// TOTPEnrollment TOTP 绑定信息
+type TOTPEnrollment struct {
+	Secret string `json:"secret"` // Base32 编码的密钥，供手动输入
+	URI    string `json:"uri"`    // otpauth URI，供生成二维码
+}
+
+// MFAService 多因素认证服务接口
+type MFAService interface {
+	// EnrollTOTP 为用户生成新的 TOTP 密钥，首次 VerifyTOTP 成功后才开启 MFA
+	EnrollTOTP(ctx context.Context, userID string) (*TOTPEnrollment, error)
+	// VerifyTOTP 校验 6 位验证码，已绑定未开启时校验成功即开启 MFA
+	VerifyTOTP(ctx context.Context, userID, code string) error
+	// DisableTOTP 校验验证码后关闭 MFA 并清除密钥
+	DisableTOTP(ctx context.Context, userID, code string) error
+	// IssueMFAToken 为通过密码校验的用户签发短期 mfa_token
+	IssueMFAToken(ctx context.Context, userID string) (string, error)
+	// VerifyMFAToken 校验 mfa_token 和 TOTP 验证码，成功后令牌作废并返回用户 ID
+	VerifyMFAToken(ctx context.Context, mfaToken, code string) (string, error)
+	// VerifyMFATokenWithRecoveryCode 使用恢复码代替 TOTP 完成登录第二步，恢复码使用后失效
+	VerifyMFATokenWithRecoveryCode(ctx context.Context, mfaToken, recoveryCode string) (string, error)
+	// GenerateRecoveryCodes 为已开启 MFA 的用户生成一组新的恢复码，旧恢复码全部作废
+	GenerateRecoveryCodes(ctx context.Context, userID string) ([]string, error)
+}
+
+// MFAServiceConfig 多因素认证服务配置
+type MFAServiceConfig struct {
+	Redis  *redis.Client    // 存储 mfa_token 和已使用的验证码
+	Issuer string           // otpauth URI 中的发行方名称，默认 UAC
+	Now    func() time.Time // 当前时间，测试时可注入
+}
+
+type mfaService struct {
+	userRepo repository.UserRepository
+	config   *MFAServiceConfig
+}
+
+// NewMFAService 创建多因素认证服务
+func NewMFAService(userRepo repository.UserRepository, config *MFAServiceConfig) MFAService {
+	if config == nil {
+		config = &MFAServiceConfig{}
+	}
+	if config.Issuer == "" {
+		config.Issuer = DefaultTOTPIssuer
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+	return &mfaService{userRepo: userRepo, config: config}
+}
+
+// EnrollTOTP 生成 TOTP 密钥
+// 已开启 MFA 的用户需先关闭才能重新绑定，避免覆盖正在使用的密钥
+func (s *mfaService) EnrollTOTP(ctx context.Context, userID string) (*TOTPEnrollment, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	if user.MFAEnabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	raw := make([]byte, totpSecretSize)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("生成 TOTP 密钥失败: %w", err)
+	}
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(raw)
+
+	user.TOTPSecret = secret
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+
+	return &TOTPEnrollment{
+		Secret: secret,
+		URI:    totpURI(s.config.Issuer, user.Username, secret),
+	}, nil
+}
+
+// VerifyTOTP 校验验证码
+func (s *mfaService) VerifyTOTP(ctx context.Context, userID, code string) error {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+	if err := s.checkCode(ctx, user, code); err != nil {
+		return err
+	}
+
+	if !user.MFAEnabled {
+		user.MFAEnabled = true
+		return s.userRepo.Update(ctx, user)
+	}
+	return nil
+}
+
+// DisableTOTP 关闭 MFA
+func (s *mfaService) DisableTOTP(ctx context.Context, userID, code string) error {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+	if err := s.checkCode(ctx, user, code); err != nil {
+		return err
+	}
+
+	user.MFAEnabled = false
+	user.TOTPSecret = ""
+	user.RecoveryCodes = nil
+	return s.userRepo.Update(ctx, user)
+}
+
+// IssueMFAToken 签发 mfa_token
+func (s *mfaService) IssueMFAToken(ctx context.Context, userID string) (string, error) {
+	token := generateSecureCode(32)
+	if err := s.config.Redis.Set(ctx, mfaTokenKeyPrefix+token, userID, MFATokenExpiry).Err(); err != nil {
+		return "", fmt.Errorf("存储 MFA 令牌失败: %w", err)
+	}
+	return token, nil
+}
+
+// VerifyMFAToken 校验 mfa_token 和验证码
+func (s *mfaService) VerifyMFAToken(ctx context.Context, mfaToken, code string) (string, error) {
+	return s.verifyMFAToken(ctx, mfaToken, func(user *model.User) error {
+		return s.checkCode(ctx, user, code)
+	})
+}
+
+// VerifyMFATokenWithRecoveryCode 使用恢复码校验 mfa_token
+func (s *mfaService) VerifyMFATokenWithRecoveryCode(ctx context.Context, mfaToken, recoveryCode string) (string, error) {
+	return s.verifyMFAToken(ctx, mfaToken, func(user *model.User) error {
+		return s.useRecoveryCode(ctx, user, recoveryCode)
+	})
+}
+
+// verifyMFAToken 取出 mfa_token 对应的用户并执行第二因素校验
+// 校验失败达到 MaxMFAAttempts 次后令牌作废，需重新输入密码
+func (s *mfaService) verifyMFAToken(ctx context.Context, mfaToken string, check func(user *model.User) error) (string, error) {
+	if mfaToken == "" {
+		return "", ErrMFATokenInvalid
+	}
+	key := mfaTokenKeyPrefix + mfaToken
+	userID, err := s.config.Redis.Get(ctx, key).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return "", ErrMFATokenInvalid
+		}
+		return "", fmt.Errorf("获取 MFA 令牌失败: %w", err)
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return "", ErrMFATokenInvalid
+	}
+
+	attemptsKey := mfaAttemptsKeyPrefix + mfaToken
+	if err := check(user); err != nil {
+		if errors.Is(err, ErrInvalidTOTPCode) || errors.Is(err, ErrInvalidRecovery) {
+			attempts, _ := s.config.Redis.Incr(ctx, attemptsKey).Result()
+			s.config.Redis.Expire(ctx, attemptsKey, MFATokenExpiry)
+			if attempts >= MaxMFAAttempts {
+				s.config.Redis.Del(ctx, key, attemptsKey)
+			}
+		}
+		return "", err
+	}
+
+	// mfa_token 一次性使用
+	s.config.Redis.Del(ctx, key, attemptsKey)
+	return user.ID, nil
+}
+
+// GenerateRecoveryCodes 生成恢复码，只返回一次明文，存储加盐哈希
+func (s *mfaService) GenerateRecoveryCodes(ctx context.Context, userID string) ([]string, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	if !user.MFAEnabled {
+		return nil, ErrMFANotEnabled
+	}
+
+	codes := make([]string, RecoveryCodeCount)
+	hashes := make(model.StringSlice, RecoveryCodeCount)
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	for i := range codes {
+		raw := make([]byte, recoveryCodeSize)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, fmt.Errorf("生成恢复码失败: %w", err)
+		}
+		code := strings.ToLower(encoding.EncodeToString(raw))
+		codes[i] = code[:5] + "-" + code[5:]
+		if hashes[i], err = hashRecoveryCode(code); err != nil {
+			return nil, err
+		}
+	}
+
+	user.RecoveryCodes = hashes
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// useRecoveryCode 校验恢复码并将其从用户的恢复码中移除
+func (s *mfaService) useRecoveryCode(ctx context.Context, user *model.User, recoveryCode string) error {
+	code := normalizeRecoveryCode(recoveryCode)
+	for i, stored := range user.RecoveryCodes {
+		if !matchRecoveryCode(stored, code) {
+			continue
+		}
+
+		// 并发提交同一恢复码时只允许一次成功
+		if s.config.Redis != nil {
+			fresh, err := s.config.Redis.SetNX(ctx, recoveryUsedPrefix+user.ID+":"+stored, 1, MFATokenExpiry).Result()
+			if err != nil {
+				return fmt.Errorf("记录恢复码使用失败: %w", err)
+			}
+			if !fresh {
+				return ErrInvalidRecovery
+			}
+		}
+
+		remaining := make(model.StringSlice, 0, len(user.RecoveryCodes)-1)
+		remaining = append(remaining, user.RecoveryCodes[:i]...)
+		remaining = append(remaining, user.RecoveryCodes[i+1:]...)
+		user.RecoveryCodes = remaining
+		return s.userRepo.Update(ctx, user)
+	}
+	return ErrInvalidRecovery
+}
+
+// normalizeRecoveryCode 去除分隔符和空白并转为小写，允许用户按任意分组输入
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+// hashRecoveryCode 计算恢复码的加盐哈希，格式为 hex(salt)$hex(sha256(salt||code))
+func hashRecoveryCode(code string) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("生成恢复码盐值失败: %w", err)
+	}
+	return hex.EncodeToString(salt) + "$" + recoveryCodeDigest(salt, code), nil
+}
+
+// matchRecoveryCode 校验恢复码是否与存储的哈希匹配
+func matchRecoveryCode(stored, code string) bool {
+	saltHex, digest, ok := strings.Cut(stored, "$")
+	if !ok {
+		return false
+	}
+	salt, err := hex.DecodeString(saltHex)
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(recoveryCodeDigest(salt, code)), []byte(digest)) == 1
+}
+
+func recoveryCodeDigest(salt []byte, code string) string {
+	h := sha256.New()
+	h.Write(salt)
+	h.Write([]byte(code))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// checkCode 校验用户的 TOTP 验证码，同一时间步的验证码不可重复使用
+func (s *mfaService) checkCode(ctx context.Context, user *model.User, code string) error {
+	if user.TOTPSecret == "" {
+		return ErrMFANotEnrolled
+	}
+	step, ok := ValidateTOTP(user.TOTPSecret, code, s.config.Now())
+	if !ok {
+		return ErrInvalidTOTPCode
+	}
+
+	if s.config.Redis != nil {
+		usedKey := totpUsedKeyPrefix + user.ID + ":" + strconv.FormatUint(step, 10)
+		fresh, err := s.config.Redis.SetNX(ctx, usedKey, 1, TOTPPeriod*(2*TOTPSkew+1)).Result()
+		if err != nil {
+			return fmt.Errorf("记录验证码使用失败: %w", err)
+		}
+		if !fresh {
+			return ErrInvalidTOTPCode
+		}
+	}
+	return nil
+}
+
+// GenerateTOTP 计算指定时间的 TOTP 验证码（RFC 6238，HMAC-SHA1）
+func GenerateTOTP(secret string, t time.Time) (string, error) {
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		return "", fmt.Errorf("无效的 TOTP 密钥: %w", err)
+	}
+	return hotp(key, uint64(t.Unix())/uint64(TOTPPeriod/time.Second)), nil
+}
+
+// ValidateTOTP 校验验证码，允许前后 TOTPSkew 个时间步的偏移
+// 校验成功时返回匹配的时间步
+func ValidateTOTP(secret, code string, t time.Time) (uint64, bool) {
+	if len(code) != TOTPDigits {
+		return 0, false
+	}
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		return 0, false
+	}
+
+	current := uint64(t.Unix()) / uint64(TOTPPeriod/time.Second)
+	for offset := -TOTPSkew; offset <= TOTPSkew; offset++ {
+		step := current + uint64(int64(offset))
+		if subtle.ConstantTimeCompare([]byte(hotp(key, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// hotp 计算 HOTP 值（RFC 4226）
+func hotp(key []byte, counter uint64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", TOTPDigits, value%1000000)
+}
+
+// totpURI 生成 otpauth URI
+// 格式：otpauth://totp/<issuer>:<account>?secret=...&issuer=...&algorithm=SHA1&digits=6&period=30
+func totpURI(issuer, account, secret string) string {
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", strconv.Itoa(TOTPDigits))
+	params.Set("period", strconv.Itoa(int(TOTPPeriod/time.Second)))
+	label := url.PathEscape(issuer + ":" + account)
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
@@ -0,0 +1,212 @@
+package service
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pu-ac-cn/uac-backend/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateTOTP_RFC6238Vectors(t *testing.T) {
+	// RFC 6238 附录 B 的 SHA1 测试向量（密钥 "12345678901234567890"），取后 6 位
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	vectors := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+	for unix, want := range vectors {
+		code, err := GenerateTOTP(secret, time.Unix(unix, 0))
+		require.NoError(t, err)
+		assert.Equal(t, want, code, "t=%d", unix)
+	}
+}
+
+func TestValidateTOTP_Skew(t *testing.T) {
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	now := time.Unix(1234567890, 0)
+	code, err := GenerateTOTP(secret, now)
+	require.NoError(t, err)
+
+	_, ok := ValidateTOTP(secret, code, now.Add(TOTPPeriod))
+	assert.True(t, ok, "允许一个时间步的偏移")
+	_, ok = ValidateTOTP(secret, code, now.Add(3*TOTPPeriod))
+	assert.False(t, ok)
+	_, ok = ValidateTOTP(secret, "12345", now)
+	assert.False(t, ok)
+}
+
+func setupMFATest(t *testing.T, now *time.Time) (MFAService, *model.User) {
+	client, cleanup := setupTestRedis(t)
+	t.Cleanup(cleanup)
+
+	userRepo := newMockUserRepository()
+	user := &model.User{Username: "mfauser", Email: "mfa@example.com"}
+	require.NoError(t, userRepo.Create(context.Background(), user))
+
+	svc := NewMFAService(userRepo, &MFAServiceConfig{
+		Redis: client,
+		Now:   func() time.Time { return *now },
+	})
+	return svc, user
+}
+
+func TestMFAService_EnrollVerifyDisable(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	svc, user := setupMFATest(t, &now)
+	ctx := context.Background()
+
+	enrollment, err := svc.EnrollTOTP(ctx, user.ID)
+	require.NoError(t, err)
+	assert.False(t, user.MFAEnabled, "验证前不开启 MFA")
+
+	u, err := url.Parse(enrollment.URI)
+	require.NoError(t, err)
+	assert.Equal(t, "otpauth", u.Scheme)
+	assert.Equal(t, "totp", u.Host)
+	assert.True(t, strings.HasSuffix(u.Path, ":mfauser"))
+	assert.Equal(t, enrollment.Secret, u.Query().Get("secret"))
+	assert.Equal(t, DefaultTOTPIssuer, u.Query().Get("issuer"))
+
+	assert.ErrorIs(t, svc.VerifyTOTP(ctx, user.ID, "000000"), ErrInvalidTOTPCode)
+
+	code, err := GenerateTOTP(enrollment.Secret, now)
+	require.NoError(t, err)
+	require.NoError(t, svc.VerifyTOTP(ctx, user.ID, code))
+	assert.True(t, user.MFAEnabled)
+
+	// 同一验证码不可重复使用
+	assert.ErrorIs(t, svc.VerifyTOTP(ctx, user.ID, code), ErrInvalidTOTPCode)
+
+	// 已开启时不能重新绑定
+	_, err = svc.EnrollTOTP(ctx, user.ID)
+	assert.ErrorIs(t, err, ErrMFAAlreadyEnabled)
+
+	now = now.Add(TOTPPeriod)
+	code, err = GenerateTOTP(enrollment.Secret, now)
+	require.NoError(t, err)
+	require.NoError(t, svc.DisableTOTP(ctx, user.ID, code))
+	assert.False(t, user.MFAEnabled)
+	assert.Empty(t, user.TOTPSecret)
+	assert.ErrorIs(t, svc.VerifyTOTP(ctx, user.ID, code), ErrMFANotEnrolled)
+}
+
+func TestMFAService_MFAToken(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	svc, user := setupMFATest(t, &now)
+	ctx := context.Background()
+
+	enrollment, err := svc.EnrollTOTP(ctx, user.ID)
+	require.NoError(t, err)
+
+	token, err := svc.IssueMFAToken(ctx, user.ID)
+	require.NoError(t, err)
+
+	_, err = svc.VerifyMFAToken(ctx, token, "000000")
+	assert.ErrorIs(t, err, ErrInvalidTOTPCode)
+
+	code, err := GenerateTOTP(enrollment.Secret, now)
+	require.NoError(t, err)
+	userID, err := svc.VerifyMFAToken(ctx, token, code)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, userID)
+
+	// mfa_token 一次性使用
+	_, err = svc.VerifyMFAToken(ctx, token, code)
+	assert.ErrorIs(t, err, ErrMFATokenInvalid)
+}
+
+func TestMFAService_MFATokenAttemptsExhausted(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	svc, user := setupMFATest(t, &now)
+	ctx := context.Background()
+
+	enrollment, err := svc.EnrollTOTP(ctx, user.ID)
+	require.NoError(t, err)
+	token, err := svc.IssueMFAToken(ctx, user.ID)
+	require.NoError(t, err)
+
+	for i := 0; i < MaxMFAAttempts; i++ {
+		_, err = svc.VerifyMFAToken(ctx, token, "000000")
+		assert.ErrorIs(t, err, ErrInvalidTOTPCode)
+	}
+
+	// 错误次数用尽后正确的验证码也无法使用该令牌
+	code, err := GenerateTOTP(enrollment.Secret, now)
+	require.NoError(t, err)
+	_, err = svc.VerifyMFAToken(ctx, token, code)
+	assert.ErrorIs(t, err, ErrMFATokenInvalid)
+}
+
+// enableMFA 为用户绑定并开启 TOTP
+func enableMFA(t *testing.T, svc MFAService, userID string, now time.Time) {
+	enrollment, err := svc.EnrollTOTP(context.Background(), userID)
+	require.NoError(t, err)
+	code, err := GenerateTOTP(enrollment.Secret, now)
+	require.NoError(t, err)
+	require.NoError(t, svc.VerifyTOTP(context.Background(), userID, code))
+}
+
+func TestMFAService_GenerateRecoveryCodes(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	svc, user := setupMFATest(t, &now)
+	ctx := context.Background()
+
+	_, err := svc.GenerateRecoveryCodes(ctx, user.ID)
+	assert.ErrorIs(t, err, ErrMFANotEnabled)
+
+	enableMFA(t, svc, user.ID, now)
+	codes, err := svc.GenerateRecoveryCodes(ctx, user.ID)
+	require.NoError(t, err)
+	require.Len(t, codes, RecoveryCodeCount)
+	require.Len(t, user.RecoveryCodes, RecoveryCodeCount)
+
+	// 只存储加盐哈希，相同明文不会出现在存储中
+	for i, code := range codes {
+		assert.Regexp(t, `^[a-z2-7]{5}-[a-z2-7]{5}$`, code)
+		assert.NotContains(t, user.RecoveryCodes[i], normalizeRecoveryCode(code))
+	}
+
+	// 重新生成后旧恢复码作废
+	token, err := svc.IssueMFAToken(ctx, user.ID)
+	require.NoError(t, err)
+	_, err = svc.GenerateRecoveryCodes(ctx, user.ID)
+	require.NoError(t, err)
+	_, err = svc.VerifyMFATokenWithRecoveryCode(ctx, token, codes[0])
+	assert.ErrorIs(t, err, ErrInvalidRecovery)
+}
+
+func TestMFAService_RecoveryCodeSingleUse(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	svc, user := setupMFATest(t, &now)
+	ctx := context.Background()
+
+	enableMFA(t, svc, user.ID, now)
+	codes, err := svc.GenerateRecoveryCodes(ctx, user.ID)
+	require.NoError(t, err)
+
+	token, err := svc.IssueMFAToken(ctx, user.ID)
+	require.NoError(t, err)
+	// 输入时忽略大小写和分隔符
+	userID, err := svc.VerifyMFATokenWithRecoveryCode(ctx, token, strings.ToUpper(strings.ReplaceAll(codes[0], "-", "")))
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, userID)
+	assert.Len(t, user.RecoveryCodes, RecoveryCodeCount-1)
+
+	// 同一恢复码只能使用一次
+	token, err = svc.IssueMFAToken(ctx, user.ID)
+	require.NoError(t, err)
+	_, err = svc.VerifyMFATokenWithRecoveryCode(ctx, token, codes[0])
+	assert.ErrorIs(t, err, ErrInvalidRecovery)
+
+	// 其它恢复码仍然可用
+	userID, err = svc.VerifyMFATokenWithRecoveryCode(ctx, token, codes[1])
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, userID)
+}
@@ -4,6 +4,9 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
 	"strings"
 
 	"github.com/google/uuid"
@@ -15,6 +18,8 @@ import (
 var (
 	ErrOrgNameEmpty = errors.New("组织名称不能为空")
 	ErrOrgIDEmpty   = errors.New("组织 ID 不能为空")
+
+	ErrInvalidBranding = errors.New("品牌配置无效")
 )
 
 // OrganizationService 组织服务接口
@@ -24,18 +29,48 @@ type OrganizationService interface {
 	GetBySlug(ctx context.Context, slug string) (*model.Organization, error)
 	Update(ctx context.Context, org *model.Organization) error
 	Delete(ctx context.Context, id string) error
+	// CheckDelete 执行非级联删除前的校验，组织下还有应用时返回 ErrOrgHasApps
+	CheckDelete(ctx context.Context, id string) error
+	DeleteCascade(ctx context.Context, id string) error
 	List(ctx context.Context, filter *repository.OrgFilter, page *repository.Pagination) ([]*model.Organization, int64, error)
 	UpdateBranding(ctx context.Context, id string, branding *model.Branding) error
 }
 
 // organizationService 组织服务实现
 type organizationService struct {
-	repo repository.OrganizationRepository
+	repo    repository.OrganizationRepository
+	appRepo repository.ApplicationRepository
 }
 
 // NewOrganizationService 创建组织服务实例
-func NewOrganizationService(repo repository.OrganizationRepository) OrganizationService {
-	return &organizationService{repo: repo}
+// 传入 appRepo 时，删除前检查组织下是否还有应用
+func NewOrganizationService(repo repository.OrganizationRepository, appRepo ...repository.ApplicationRepository) OrganizationService {
+	s := &organizationService{repo: repo}
+	if len(appRepo) > 0 {
+		s.appRepo = appRepo[0]
+	}
+	return s
+}
+
+// 由名称生成 slug 时的规则
+var (
+	slugInvalidChars = regexp.MustCompile(`[^a-z0-9-]+`)
+	slugRepeatDashes = regexp.MustCompile(`-{2,}`)
+)
+
+// maxBaseSlugLen 生成 slug 的最大长度，为唯一性后缀预留空间
+const maxBaseSlugLen = 90
+
+// slugify 将组织名称转写为 slug：小写、空白转连字符、去除非法字符
+// 名称中没有可用字符（如纯中文名称）时返回空字符串
+func slugify(name string) string {
+	slug := strings.Join(strings.Fields(strings.ToLower(name)), "-")
+	slug = slugInvalidChars.ReplaceAllString(slug, "")
+	slug = slugRepeatDashes.ReplaceAllString(slug, "-")
+	if len(slug) > maxBaseSlugLen {
+		slug = slug[:maxBaseSlugLen]
+	}
+	return strings.Trim(slug, "-")
 }
 
 // generateSlug 生成组织标识（使用短 UUID）
@@ -53,9 +88,21 @@ func (s *organizationService) Create(ctx context.Context, org *model.Organizatio
 		return ErrOrgNameEmpty
 	}
 
-	// 自动生成 slug
+	// 自动生成 slug，优先由名称转写
 	if org.Slug == "" {
-		org.Slug = generateSlug()
+		base := slugify(org.Name)
+		if base == "" {
+			base = generateSlug()
+		}
+		slug, err := s.uniqueSlug(ctx, base)
+		if err != nil {
+			return err
+		}
+		org.Slug = slug
+	}
+
+	if err := validateBranding(&org.Branding); err != nil {
+		return err
 	}
 
 	// 设置默认状态
@@ -66,6 +113,21 @@ func (s *organizationService) Create(ctx context.Context, org *model.Organizatio
 	return s.repo.Create(ctx, org)
 }
 
+// uniqueSlug 在 base 已被占用时依次追加 -2、-3…直到唯一
+func (s *organizationService) uniqueSlug(ctx context.Context, base string) (string, error) {
+	slug := base
+	for i := 2; ; i++ {
+		exists, err := s.repo.ExistsBySlug(ctx, slug)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return slug, nil
+		}
+		slug = fmt.Sprintf("%s-%d", base, i)
+	}
+}
+
 // GetByID 根据 ID 获取组织
 func (s *organizationService) GetByID(ctx context.Context, id string) (*model.Organization, error) {
 	if id == "" {
@@ -92,17 +154,45 @@ func (s *organizationService) Update(ctx context.Context, org *model.Organizatio
 	if org.Name == "" {
 		return ErrOrgNameEmpty
 	}
+	if err := validateBranding(&org.Branding); err != nil {
+		return err
+	}
 
 	return s.repo.Update(ctx, org)
 }
 
 // Delete 删除组织
 func (s *organizationService) Delete(ctx context.Context, id string) error {
+	if err := s.CheckDelete(ctx, id); err != nil {
+		return err
+	}
+	return s.repo.Delete(ctx, id)
+}
+
+// CheckDelete 校验组织能否直接删除
+func (s *organizationService) CheckDelete(ctx context.Context, id string) error {
 	if id == "" {
 		return ErrOrgIDEmpty
 	}
-	// TODO: 检查组织下是否有应用，有则不允许删除或级联删除
-	return s.repo.Delete(ctx, id)
+	// 组织下还有应用时不允许直接删除，需使用 DeleteCascade
+	if s.appRepo != nil {
+		count, err := s.appRepo.CountByOrgID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return repository.ErrOrgHasApps
+		}
+	}
+	return nil
+}
+
+// DeleteCascade 删除组织及其应用、角色和用户绑定
+func (s *organizationService) DeleteCascade(ctx context.Context, id string) error {
+	if id == "" {
+		return ErrOrgIDEmpty
+	}
+	return s.repo.DeleteCascade(ctx, id)
 }
 
 // List 查询组织列表
@@ -126,6 +216,9 @@ func (s *organizationService) UpdateBranding(ctx context.Context, id string, bra
 	if id == "" {
 		return ErrOrgIDEmpty
 	}
+	if err := validateBranding(branding); err != nil {
+		return err
+	}
 
 	// 获取现有组织
 	org, err := s.repo.GetByID(ctx, id)
@@ -137,3 +230,50 @@ func (s *organizationService) UpdateBranding(ctx context.Context, id string, bra
 	org.Branding = *branding
 	return s.repo.Update(ctx, org)
 }
+
+// MaxCustomCSSLength 自定义 CSS 的最大长度
+const MaxCustomCSSLength = 10000
+
+var (
+	brandingColorPattern = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
+	// cssDangerousPattern 自定义 CSS 中可跳出 <style> 或执行脚本的片段
+	cssDangerousPattern = regexp.MustCompile(`(?i)<\s*/?\s*(style|script)[^>]*>?|javascript\s*:|expression\s*\(|@import`)
+)
+
+// validateBranding 校验品牌配置，空字段表示不设置；CustomCSS 中的危险片段会被原地剥离
+func validateBranding(b *model.Branding) error {
+	if b.PrimaryColor != "" && !brandingColorPattern.MatchString(b.PrimaryColor) {
+		return fmt.Errorf("%w: 主题色必须为 #RRGGBB 格式", ErrInvalidBranding)
+	}
+	if b.LogoURL != "" && !isHTTPURL(b.LogoURL) {
+		return fmt.Errorf("%w: Logo 地址必须为 http(s) 绝对 URL", ErrInvalidBranding)
+	}
+	if b.FaviconURL != "" && !isHTTPURL(b.FaviconURL) {
+		return fmt.Errorf("%w: Favicon 地址必须为 http(s) 绝对 URL", ErrInvalidBranding)
+	}
+	if len(b.CustomCSS) > MaxCustomCSSLength {
+		return fmt.Errorf("%w: 自定义 CSS 不能超过 %d 字节", ErrInvalidBranding, MaxCustomCSSLength)
+	}
+	b.CustomCSS = sanitizeCSS(b.CustomCSS)
+	return nil
+}
+
+// sanitizeCSS 反复剥离危险片段，直到不再变化，防止 <scr<script>ipt> 之类的嵌套绕过
+func sanitizeCSS(css string) string {
+	for {
+		cleaned := cssDangerousPattern.ReplaceAllString(css, "")
+		if cleaned == css {
+			return cleaned
+		}
+		css = cleaned
+	}
+}
+
+// isHTTPURL 检查是否为 http(s) 绝对 URL
+func isHTTPURL(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
@@ -2,6 +2,8 @@ package service
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"testing"
 
 	"github.com/pu-ac-cn/uac-backend/internal/model"
@@ -12,6 +14,7 @@ import (
 type mockOrgRepository struct {
 	orgs     map[string]*model.Organization
 	slugMap  map[string]string // slug -> id
+	cascaded []string          // 级联删除的组织 ID
 	createFn func(ctx context.Context, org *model.Organization) error
 	getFn    func(ctx context.Context, id string) (*model.Organization, error)
 }
@@ -83,6 +86,14 @@ func (m *mockOrgRepository) List(ctx context.Context, filter *repository.OrgFilt
 	return result, int64(len(result)), nil
 }
 
+func (m *mockOrgRepository) DeleteCascade(ctx context.Context, id string) error {
+	if err := m.Delete(ctx, id); err != nil {
+		return err
+	}
+	m.cascaded = append(m.cascaded, id)
+	return nil
+}
+
 func (m *mockOrgRepository) ExistsBySlug(ctx context.Context, slug string) (bool, error) {
 	_, exists := m.slugMap[slug]
 	return exists, nil
@@ -291,6 +302,45 @@ func TestOrganizationService_Delete(t *testing.T) {
 	}
 }
 
+func TestOrganizationService_Delete_HasApps(t *testing.T) {
+	repo := newMockOrgRepository()
+	appRepo := newMockAppRepository()
+	svc := NewOrganizationService(repo, appRepo)
+	ctx := context.Background()
+
+	org := &model.Organization{Name: "测试组织", Slug: "org-with-apps"}
+	_ = svc.Create(ctx, org)
+	if _, err := NewApplicationService(appRepo, repo).Create(ctx, &model.Application{Name: "测试应用", OrgID: &org.ID}); err != nil {
+		t.Fatalf("创建应用失败: %v", err)
+	}
+
+	// 存在应用时拒绝直接删除，试运行校验给出相同结果
+	if err := svc.CheckDelete(ctx, org.ID); err != repository.ErrOrgHasApps {
+		t.Errorf("期望错误 %v，实际错误 %v", repository.ErrOrgHasApps, err)
+	}
+	if err := svc.Delete(ctx, org.ID); err != repository.ErrOrgHasApps {
+		t.Errorf("期望错误 %v，实际错误 %v", repository.ErrOrgHasApps, err)
+	}
+	if _, exists := repo.orgs[org.ID]; !exists {
+		t.Error("拒绝删除时组织不应被删除")
+	}
+
+	// 级联删除
+	if err := svc.DeleteCascade(ctx, org.ID); err != nil {
+		t.Fatalf("级联删除失败: %v", err)
+	}
+	if _, exists := repo.orgs[org.ID]; exists {
+		t.Error("级联删除后组织应不存在")
+	}
+	if len(repo.cascaded) != 1 || repo.cascaded[0] != org.ID {
+		t.Errorf("期望级联删除 %s，实际 %v", org.ID, repo.cascaded)
+	}
+
+	if err := svc.DeleteCascade(ctx, ""); err != ErrOrgIDEmpty {
+		t.Errorf("期望错误 %v，实际错误 %v", ErrOrgIDEmpty, err)
+	}
+}
+
 func TestOrganizationService_UpdateBranding(t *testing.T) {
 	repo := newMockOrgRepository()
 	svc := NewOrganizationService(repo)
@@ -316,3 +366,116 @@ func TestOrganizationService_UpdateBranding(t *testing.T) {
 		t.Errorf("Logo URL 不匹配，期望 %s，实际 %s", branding.LogoURL, updated.Branding.LogoURL)
 	}
 }
+
+func TestOrganizationService_Create_GeneratedSlug(t *testing.T) {
+	repo := newMockOrgRepository()
+	svc := NewOrganizationService(repo)
+	ctx := context.Background()
+
+	first := &model.Organization{Name: "Foo"}
+	second := &model.Organization{Name: "foo"}
+	third := &model.Organization{Name: "  FOO  "}
+	for _, org := range []*model.Organization{first, second, third} {
+		if err := svc.Create(ctx, org); err != nil {
+			t.Fatalf("创建组织失败: %v", err)
+		}
+	}
+	if first.Slug != "foo" || second.Slug != "foo-2" || third.Slug != "foo-3" {
+		t.Errorf("期望 foo、foo-2、foo-3，实际 %s、%s、%s", first.Slug, second.Slug, third.Slug)
+	}
+
+	// 名称转写规则
+	org := &model.Organization{Name: "Acme Corp. (北京) R&D"}
+	if err := svc.Create(ctx, org); err != nil {
+		t.Fatalf("创建组织失败: %v", err)
+	}
+	if org.Slug != "acme-corp-rd" {
+		t.Errorf("期望 acme-corp-rd，实际 %s", org.Slug)
+	}
+
+	// 名称中没有可用字符时回退为随机 slug
+	org = &model.Organization{Name: "研发部"}
+	if err := svc.Create(ctx, org); err != nil {
+		t.Fatalf("创建组织失败: %v", err)
+	}
+	if org.Slug == "" {
+		t.Error("期望生成随机 slug")
+	}
+}
+
+func TestValidateBranding(t *testing.T) {
+	tests := []struct {
+		name     string
+		branding model.Branding
+		valid    bool
+	}{
+		{"空配置", model.Branding{}, true},
+		{"合法配置", model.Branding{PrimaryColor: "#1677ff", LogoURL: "https://cdn.example.com/logo.png", FaviconURL: "http://cdn.example.com/favicon.ico"}, true},
+		{"颜色缺少井号", model.Branding{PrimaryColor: "1677ff"}, false},
+		{"颜色为三位简写", model.Branding{PrimaryColor: "#fff"}, false},
+		{"颜色包含非法字符", model.Branding{PrimaryColor: "#12345g"}, false},
+		{"颜色注入样式", model.Branding{PrimaryColor: "#123456;background:url(x)"}, false},
+		{"Logo 为相对路径", model.Branding{LogoURL: "/logo.png"}, false},
+		{"Logo 为 javascript 协议", model.Branding{LogoURL: "javascript:alert(1)"}, false},
+		{"Favicon 为 data URL", model.Branding{FaviconURL: "data:image/png;base64,AAAA"}, false},
+		{"CSS 超长", model.Branding{CustomCSS: strings.Repeat("a", MaxCustomCSSLength+1)}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := tt.branding
+			err := validateBranding(&b)
+			if tt.valid && err != nil {
+				t.Errorf("期望校验通过，实际错误 %v", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidBranding) {
+				t.Errorf("期望错误 %v，实际错误 %v", ErrInvalidBranding, err)
+			}
+		})
+	}
+}
+
+func TestValidateBranding_SanitizesCSS(t *testing.T) {
+	tests := map[string]string{
+		".btn { color: red; }":                            ".btn { color: red; }",
+		"a{}</style><script>alert(1)</script>":            "a{}alert(1)",
+		"a{}</STYLE ><SCRIPT src=x>":                      "a{}",
+		"<scr<script>ipt>alert(1)":                        "alert(1)",
+		"a{background:url(javascript:alert(1))}":          "a{background:url(alert(1))}",
+		"a{width:expression(alert(1))}":                   "a{width:alert(1))}",
+		"@import url(https://evil.example.com/x.css);a{}": " url(https://evil.example.com/x.css);a{}",
+	}
+	for input, want := range tests {
+		b := model.Branding{CustomCSS: input}
+		if err := validateBranding(&b); err != nil {
+			t.Fatalf("校验 %q 失败: %v", input, err)
+		}
+		if b.CustomCSS != want {
+			t.Errorf("剥离 %q 期望 %q，实际 %q", input, want, b.CustomCSS)
+		}
+	}
+}
+
+func TestOrganizationService_UpdateBranding_Invalid(t *testing.T) {
+	repo := newMockOrgRepository()
+	svc := NewOrganizationService(repo)
+	ctx := context.Background()
+
+	org := &model.Organization{Name: "测试组织", Slug: "test-org", Branding: model.Branding{PrimaryColor: "#000000"}}
+	if err := svc.Create(ctx, org); err != nil {
+		t.Fatalf("创建组织失败: %v", err)
+	}
+
+	err := svc.UpdateBranding(ctx, org.ID, &model.Branding{PrimaryColor: "red"})
+	if !errors.Is(err, ErrInvalidBranding) {
+		t.Errorf("期望错误 %v，实际错误 %v", ErrInvalidBranding, err)
+	}
+	updated, _ := svc.GetByID(ctx, org.ID)
+	if updated.Branding.PrimaryColor != "#000000" {
+		t.Errorf("非法配置不应保存，实际主题色 %s", updated.Branding.PrimaryColor)
+	}
+
+	if err := svc.Create(ctx, &model.Organization{Name: "另一个组织", Branding: model.Branding{LogoURL: "logo.png"}}); !errors.Is(err, ErrInvalidBranding) {
+		t.Errorf("创建时期望错误 %v，实际错误 %v", ErrInvalidBranding, err)
+	}
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/pu-ac-cn/uac-backend/internal/model"
+	"github.com/pu-ac-cn/uac-backend/internal/repository"
+)
+
+// ErrPasswordReused 新密码与最近使用过的密码相同
+var ErrPasswordReused = errors.New("新密码不能与最近使用过的密码相同")
+
+// DefaultPasswordHistorySize 默认禁止重用的最近密码数量
+const DefaultPasswordHistorySize = 5
+
+// passwordHistory 密码历史检查，repo 为空或 size 不大于 0 时不做检查
+type passwordHistory struct {
+	repo repository.PasswordHistoryRepository
+	size int
+}
+
+// newPasswordHistory 创建密码历史检查，size 为 0 时使用默认值，小于 0 表示关闭
+func newPasswordHistory(repo repository.PasswordHistoryRepository, size int) *passwordHistory {
+	if size == 0 {
+		size = DefaultPasswordHistorySize
+	}
+	return &passwordHistory{repo: repo, size: size}
+}
+
+func (h *passwordHistory) enabled() bool {
+	return h.repo != nil && h.size > 0
+}
+
+// check 检查新密码是否与当前密码或最近 size 次设置的密码相同
+// 当前密码单独比对，兼容启用密码历史之前创建、尚无历史记录的用户
+func (h *passwordHistory) check(ctx context.Context, user *model.User, newPassword string) error {
+	if !h.enabled() {
+		return nil
+	}
+	if user.PasswordHash != "" && user.VerifyPassword(newPassword) {
+		return ErrPasswordReused
+	}
+	history, err := h.repo.ListRecent(ctx, user.ID, h.size)
+	if err != nil {
+		return err
+	}
+	for _, entry := range history {
+		if entry.Matches(newPassword) {
+			return ErrPasswordReused
+		}
+	}
+	return nil
+}
+
+// record 记录用户当前的密码哈希，超出 size 的旧记录被淘汰
+func (h *passwordHistory) record(ctx context.Context, user *model.User) error {
+	if !h.enabled() {
+		return nil
+	}
+	return h.repo.Add(ctx, user.ID, user.PasswordHash, h.size)
+}
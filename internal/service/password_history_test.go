@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pu-ac-cn/uac-backend/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockPasswordHistoryRepository 内存密码历史仓库，按写入顺序保存
+type mockPasswordHistoryRepository struct {
+	entries map[string][]*model.PasswordHistory
+}
+
+func newMockPasswordHistoryRepository() *mockPasswordHistoryRepository {
+	return &mockPasswordHistoryRepository{entries: make(map[string][]*model.PasswordHistory)}
+}
+
+func (m *mockPasswordHistoryRepository) Add(ctx context.Context, userID, passwordHash string, keep int) error {
+	list := append(m.entries[userID], &model.PasswordHistory{UserID: userID, PasswordHash: passwordHash})
+	if len(list) > keep {
+		list = list[len(list)-keep:]
+	}
+	m.entries[userID] = list
+	return nil
+}
+
+func (m *mockPasswordHistoryRepository) ListRecent(ctx context.Context, userID string, limit int) ([]*model.PasswordHistory, error) {
+	list := m.entries[userID]
+	var result []*model.PasswordHistory
+	for i := len(list) - 1; i >= 0 && len(result) < limit; i-- {
+		result = append(result, list[i])
+	}
+	return result, nil
+}
+
+func TestUserService_ChangePassword_History(t *testing.T) {
+	historyRepo := newMockPasswordHistoryRepository()
+	svc := NewUserService(newMockUserRepository(), newMockBindingRepository(), nil, &UserServiceConfig{
+		PasswordHistory:     historyRepo,
+		PasswordHistorySize: 3,
+	})
+	ctx := context.Background()
+
+	user := &model.User{Username: "history", Email: "history@example.com", Status: model.StatusActive}
+	require.NoError(t, svc.Create(ctx, user, "Passw0rd1"))
+	require.NoError(t, svc.ChangePassword(ctx, user.ID, "Passw0rd1", "Passw0rd2"))
+
+	// 改回上一个密码被拒
+	assert.ErrorIs(t, svc.ChangePassword(ctx, user.ID, "Passw0rd2", "Passw0rd1"), ErrPasswordReused)
+	// 与当前密码相同同样被拒
+	assert.ErrorIs(t, svc.ChangePassword(ctx, user.ID, "Passw0rd2", "Passw0rd2"), ErrPasswordReused)
+
+	require.NoError(t, svc.ChangePassword(ctx, user.ID, "Passw0rd2", "Passw0rd3"))
+	require.NoError(t, svc.ChangePassword(ctx, user.ID, "Passw0rd3", "Passw0rd4"))
+
+	// 只保留最近 3 次，最早的密码已被淘汰，可以再次使用
+	assert.Len(t, historyRepo.entries[user.ID], 3)
+	assert.NoError(t, svc.ChangePassword(ctx, user.ID, "Passw0rd4", "Passw0rd1"))
+}
+
+func TestAuthService_ResetPassword_History(t *testing.T) {
+	userRepo := newMockUserRepository()
+	svc := NewAuthService(userRepo, &AuthServiceConfig{PasswordHistory: newMockPasswordHistoryRepository()})
+	ctx := context.Background()
+
+	user := &model.User{Username: "resethistory", Email: "reset@example.com", Status: model.StatusActive}
+	user.SetPassword("OldPass123")
+	require.NoError(t, userRepo.Create(ctx, user))
+
+	// 尚无历史记录的用户仍不能重设为当前密码
+	assert.ErrorIs(t, svc.ResetPassword(ctx, user.ID, "OldPass123"), ErrPasswordReused)
+
+	require.NoError(t, svc.ResetPassword(ctx, user.ID, "NewPass456"))
+	require.NoError(t, svc.ChangePassword(ctx, user.ID, "NewPass456", "NewPass789"))
+	assert.ErrorIs(t, svc.ChangePassword(ctx, user.ID, "NewPass789", "NewPass456"), ErrPasswordReused)
+}
+
+func TestPasswordHistory_Disabled(t *testing.T) {
+	userRepo := newMockUserRepository()
+	svc := NewAuthService(userRepo, &AuthServiceConfig{
+		PasswordHistory:     newMockPasswordHistoryRepository(),
+		PasswordHistorySize: -1,
+	})
+	ctx := context.Background()
+
+	user := &model.User{Username: "nohistory", Email: "nohistory@example.com", Status: model.StatusActive}
+	user.SetPassword("OldPass123")
+	require.NoError(t, userRepo.Create(ctx, user))
+
+	assert.NoError(t, svc.ResetPassword(ctx, user.ID, "OldPass123"))
+}
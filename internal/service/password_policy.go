@@ -0,0 +1,73 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// PasswordPolicy 密码强度策略
+type PasswordPolicy struct {
+	MinLength      int  // 最小长度（字节数）
+	RequireUpper   bool // 需要包含大写字母
+	RequireLower   bool // 需要包含小写字母
+	RequireDigit   bool // 需要包含数字
+	RequireSpecial bool // 需要包含特殊字符（标点或符号）
+}
+
+// DefaultPasswordPolicy 默认密码策略：最少 8 位，包含大写字母、小写字母和数字
+func DefaultPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{
+		MinLength:    8,
+		RequireUpper: true,
+		RequireLower: true,
+		RequireDigit: true,
+	}
+}
+
+// PasswordPolicyError 密码不满足策略，Violations 列出全部未满足的规则，便于前端逐项提示
+type PasswordPolicyError struct {
+	Violations []string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return "密码强度不足：" + strings.Join(e.Violations, "；")
+}
+
+// Validate 按策略检查密码，不满足时返回 *PasswordPolicyError
+func (p PasswordPolicy) Validate(password string) error {
+	var hasUpper, hasLower, hasDigit, hasSpecial bool
+	for _, c := range password {
+		switch {
+		case c >= 'A' && c <= 'Z':
+			hasUpper = true
+		case c >= 'a' && c <= 'z':
+			hasLower = true
+		case c >= '0' && c <= '9':
+			hasDigit = true
+		case unicode.IsPunct(c) || unicode.IsSymbol(c):
+			hasSpecial = true
+		}
+	}
+
+	var violations []string
+	if len(password) < p.MinLength {
+		violations = append(violations, fmt.Sprintf("长度至少 %d 位", p.MinLength))
+	}
+	if p.RequireUpper && !hasUpper {
+		violations = append(violations, "需要包含大写字母")
+	}
+	if p.RequireLower && !hasLower {
+		violations = append(violations, "需要包含小写字母")
+	}
+	if p.RequireDigit && !hasDigit {
+		violations = append(violations, "需要包含数字")
+	}
+	if p.RequireSpecial && !hasSpecial {
+		violations = append(violations, "需要包含特殊字符")
+	}
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+// Package service 业务逻辑层
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// pendingTokensPrefix 用户未使用的一次性令牌索引前缀，集合成员为令牌的完整 key
+// 用于在怀疑邮箱泄露时按用户一次性作废密码重置、邮箱验证等令牌
+const pendingTokensPrefix = "pending_tokens:"
+
+// pendingTokensExpiry 索引的有效期，取各类一次性令牌中最长的有效期
+const pendingTokensExpiry = EmailVerificationExpiry
+
+// storePendingToken 存储一次性令牌并登记到用户的令牌索引
+func storePendingToken(ctx context.Context, client *redis.Client, key, userID string, ttl time.Duration) error {
+	indexKey := pendingTokensPrefix + userID
+	pipe := client.TxPipeline()
+	pipe.Set(ctx, key, userID, ttl)
+	pipe.SAdd(ctx, indexKey, key)
+	pipe.Expire(ctx, indexKey, pendingTokensExpiry)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// claimPendingToken 原子地取出并删除一次性令牌，返回对应的用户 ID 和剩余有效期
+// 令牌不存在时返回 redis.Nil；并发请求中只有一个能取到令牌
+func claimPendingToken(ctx context.Context, client *redis.Client, key string) (string, time.Duration, error) {
+	pipe := client.TxPipeline()
+	ttlCmd := pipe.PTTL(ctx, key)
+	getCmd := pipe.GetDel(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return "", 0, err
+	}
+	return getCmd.Val(), ttlCmd.Val(), nil
+}
+
+// restorePendingToken 放回已取出但未使用的一次性令牌，沿用取出时的剩余有效期
+func restorePendingToken(ctx context.Context, client *redis.Client, key, userID string, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	_ = client.Set(ctx, key, userID, ttl).Err()
+}
+
+// consumePendingToken 删除已使用的一次性令牌并从用户的令牌索引中移除
+func consumePendingToken(ctx context.Context, client *redis.Client, key, userID string) {
+	pipe := client.TxPipeline()
+	pipe.Del(ctx, key)
+	pipe.SRem(ctx, pendingTokensPrefix+userID, key)
+	_, _ = pipe.Exec(ctx)
+}
+
+// invalidatePendingTokens 删除用户全部未使用的一次性令牌，返回删除的数量
+func invalidatePendingTokens(ctx context.Context, client *redis.Client, userID string) (int, error) {
+	indexKey := pendingTokensPrefix + userID
+	keys, err := client.SMembers(ctx, indexKey).Result()
+	if err != nil {
+		return 0, fmt.Errorf("获取待使用令牌失败: %w", err)
+	}
+
+	deleted := int64(0)
+	if len(keys) > 0 {
+		if deleted, err = client.Del(ctx, keys...).Result(); err != nil {
+			return 0, fmt.Errorf("删除待使用令牌失败: %w", err)
+		}
+	}
+	if err := client.Del(ctx, indexKey).Err(); err != nil {
+		return 0, fmt.Errorf("删除令牌索引失败: %w", err)
+	}
+	return int(deleted), nil
+}
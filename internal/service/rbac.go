@@ -3,10 +3,13 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"time"
 
 	"github.com/pu-ac-cn/uac-backend/internal/model"
 	"github.com/pu-ac-cn/uac-backend/internal/repository"
+	"github.com/redis/go-redis/v9"
 )
 
 var (
@@ -14,11 +17,19 @@ var (
 	ErrRoleCodeExists      = errors.New("角色代码已存在")
 	ErrPermissionNotFound  = errors.New("权限不存在")
 	ErrPermissionExists    = errors.New("权限已存在")
-	ErrSystemRole          = errors.New("系统内置角色不能删除")
-	ErrSystemPermission    = errors.New("系统内置权限不能删除")
+	ErrSystemRole          = errors.New("系统内置角色不能修改或删除")
+	ErrSystemPermission    = errors.New("系统内置权限不能修改或删除")
 	ErrRoleAlreadyAssigned = errors.New("用户已拥有该角色")
+	ErrRoleNotAssignable   = errors.New("无权分配该角色")
+	ErrRoleOrgMismatch     = errors.New("用户不属于该角色所在的组织")
+	ErrRoleExpiryInvalid   = errors.New("角色到期时间必须晚于当前时间")
 )
 
+// OrgAccessChecker 组织成员关系检查，UserService 实现了该接口
+type OrgAccessChecker interface {
+	HasOrgAccess(ctx context.Context, userID, orgID string) (bool, error)
+}
+
 // RBACService RBAC 服务接口
 type RBACService interface {
 	// 角色管理
@@ -28,27 +39,57 @@ type RBACService interface {
 	UpdateRole(ctx context.Context, role *model.Role) error
 	DeleteRole(ctx context.Context, id string) error
 	ListRoles(ctx context.Context, orgID string, page *repository.Pagination) ([]*model.Role, int64, error)
+	// DeleteOrgScopedRBAC 删除组织级角色和权限及其分配，用于清理已删除组织遗留的数据
+	// 删除组织时已在同一事务中清理，orgID 为空时返回 ErrOrgIDEmpty
+	DeleteOrgScopedRBAC(ctx context.Context, orgID string) error
+	// OrgRoleUserIDs 返回角色缓存受组织删除影响的用户，需在删除组织前获取，删除后传给 InvalidateUserRolesCache
+	OrgRoleUserIDs(ctx context.Context, orgID string) ([]string, error)
+	// InvalidateUserRolesCache 清除用户的角色缓存，用于不经过本服务修改用户角色的操作
+	InvalidateUserRolesCache(ctx context.Context, userIDs ...string)
 
 	// 权限管理
 	CreatePermission(ctx context.Context, perm *model.Permission) error
 	GetPermission(ctx context.Context, id string) (*model.Permission, error)
 	DeletePermission(ctx context.Context, id string) error
-	ListPermissions(ctx context.Context, orgID string) ([]*model.Permission, error)
+	ListPermissions(ctx context.Context, filter *repository.PermissionFilter, page *repository.Pagination) ([]*model.Permission, int64, error)
 
 	// 角色权限关联
-	AddPermissionsToRole(ctx context.Context, roleID string, permissionIDs []string) error
-	RemovePermissionsFromRole(ctx context.Context, roleID string, permissionIDs []string) error
+	// AddPermissionsToRole / RemovePermissionsFromRole 调整角色权限
+	// 系统内置角色不可调整，超级管理员调整 super_admin 角色除外
+	AddPermissionsToRole(ctx context.Context, operatorID, roleID string, permissionIDs []string) error
+	RemovePermissionsFromRole(ctx context.Context, operatorID, roleID string, permissionIDs []string) error
+	// SetRolePermissions 将角色权限整体替换为 permissionIDs，空列表表示清空
+	SetRolePermissions(ctx context.Context, operatorID, roleID string, permissionIDs []string) error
+	AddPermissionToRoles(ctx context.Context, permissionID string, roleIDs []string) ([]string, error)
 	GetRolePermissions(ctx context.Context, roleID string) ([]model.Permission, error)
+	ListAssignablePermissions(ctx context.Context, roleID string) ([]*model.Permission, error)
 
 	// 用户角色
+	// AssignRole / AssignRoleByCode 为用户分配角色，已拥有时返回 ErrRoleAlreadyAssigned
+	// 组织角色只能分配给该组织的成员，否则返回 ErrRoleOrgMismatch
 	AssignRole(ctx context.Context, userID, roleID string) error
 	AssignRoleByCode(ctx context.Context, userID, roleCode string) error
+	// AssignRoleWithExpiry 为用户分配到 expiresAt 为止有效的角色，过期后不再授予任何权限
+	AssignRoleWithExpiry(ctx context.Context, userID, roleID string, expiresAt time.Time) error
 	RevokeRole(ctx context.Context, userID, roleID string) error
 	GetUserRoles(ctx context.Context, userID string) ([]*model.Role, error)
+	// GetUserRolesCached 返回用户的角色代码，配置 Redis 时缓存 UserRolesCacheTTL，分配、撤销、删除角色及合并用户时失效
+	GetUserRolesCached(ctx context.Context, userID string) ([]string, error)
+	GetRoleUsers(ctx context.Context, roleID string, page *repository.Pagination) ([]*model.User, int64, error)
 	HasRole(ctx context.Context, userID, roleCode string) (bool, error)
+	ListAssignableRoles(ctx context.Context, operatorID, userID string, orgIDs []string) ([]*model.Role, error)
+
+	// 应用角色（服务账号）
+	// AssignAppRole 为应用分配角色，只有超级管理员可以分配超级管理员角色
+	AssignAppRole(ctx context.Context, operatorID, appID, roleID string) error
+	RevokeAppRole(ctx context.Context, appID, roleID string) error
+	GetAppRoles(ctx context.Context, appID string) ([]*model.Role, error)
 
 	// 权限检查
 	CheckPermission(ctx context.Context, userID, resource, action string) (bool, error)
+	// CheckResourceAccess 检查用户对具体资源的访问权限：拥有资源级权限或是资源所有者（ownerID == userID）时放行
+	// ownerID 为空表示资源没有记录所有者，只按权限判断
+	CheckResourceAccess(ctx context.Context, userID, resource, action, ownerID string) (bool, error)
 	GetUserPermissions(ctx context.Context, userID string) ([]string, error)
 
 	// 初始化
@@ -59,15 +100,47 @@ type rbacService struct {
 	roleRepo     repository.RoleRepository
 	permRepo     repository.PermissionRepository
 	userRoleRepo repository.UserRoleRepository
+	appRoleRepo  repository.AppRoleRepository
+	orgAccess    OrgAccessChecker
+	redis        *redis.Client
+	// 初始化时创建并受保护的系统内置权限
+	systemPermissions []model.Permission
 }
 
+// RBACServiceConfig RBAC 服务配置
+type RBACServiceConfig struct {
+	// ExtraSystemPermissions 在默认权限之外额外创建的系统内置权限
+	ExtraSystemPermissions []model.Permission
+	// AppRoleRepo 应用角色仓库，未设置时不支持为应用分配角色
+	AppRoleRepo repository.AppRoleRepository
+	// OrgAccess 分配组织角色时校验用户属于该组织，未设置时不校验
+	OrgAccess OrgAccessChecker
+	// Redis 用户角色缓存，未设置时每次查询数据库
+	Redis *redis.Client
+}
+
+// 用户角色缓存
+const (
+	userRolesCachePrefix = "user_roles_cache:"
+	UserRolesCacheTTL    = 60 * time.Second
+)
+
 // NewRBACService 创建 RBAC 服务
-func NewRBACService(roleRepo repository.RoleRepository, permRepo repository.PermissionRepository, userRoleRepo repository.UserRoleRepository) RBACService {
-	return &rbacService{
+func NewRBACService(roleRepo repository.RoleRepository, permRepo repository.PermissionRepository, userRoleRepo repository.UserRoleRepository, cfg ...*RBACServiceConfig) RBACService {
+	s := &rbacService{
 		roleRepo:     roleRepo,
 		permRepo:     permRepo,
 		userRoleRepo: userRoleRepo,
 	}
+	var extra []model.Permission
+	if len(cfg) > 0 && cfg[0] != nil {
+		extra = cfg[0].ExtraSystemPermissions
+		s.appRoleRepo = cfg[0].AppRoleRepo
+		s.orgAccess = cfg[0].OrgAccess
+		s.redis = cfg[0].Redis
+	}
+	s.systemPermissions = model.SystemPermissions(extra)
+	return s
 }
 
 // 角色管理
@@ -82,6 +155,8 @@ func (s *rbacService) CreateRole(ctx context.Context, role *model.Role) error {
 	if role.Status == "" {
 		role.Status = model.StatusActive
 	}
+	// 系统内置角色只能由初始化创建
+	role.IsSystem = false
 
 	return s.roleRepo.Create(ctx, role)
 }
@@ -108,10 +183,10 @@ func (s *rbacService) UpdateRole(ctx context.Context, role *model.Role) error {
 		return ErrRoleNotFound
 	}
 
-	// 系统角色不能修改代码
-	if existing.IsSystem && role.Code != existing.Code {
+	if existing.IsSystem {
 		return ErrSystemRole
 	}
+	role.IsSystem = false
 
 	return s.roleRepo.Update(ctx, role)
 }
@@ -126,7 +201,45 @@ func (s *rbacService) DeleteRole(ctx context.Context, id string) error {
 		return ErrSystemRole
 	}
 
-	return s.roleRepo.Delete(ctx, id)
+	// 删除后分配记录随之删除，需先取出持有该角色的用户以清除其角色缓存
+	var userIDs []string
+	if s.redis != nil {
+		if userIDs, err = s.userRoleRepo.ListUserIDsByRole(ctx, id); err != nil {
+			return err
+		}
+	}
+	if err := s.roleRepo.Delete(ctx, id); err != nil {
+		return err
+	}
+	s.invalidateUserRoles(ctx, userIDs...)
+	return nil
+}
+
+func (s *rbacService) DeleteOrgScopedRBAC(ctx context.Context, orgID string) error {
+	userIDs, err := s.OrgRoleUserIDs(ctx, orgID)
+	if err != nil {
+		return err
+	}
+	if err := s.roleRepo.DeleteOrgScoped(ctx, orgID); err != nil {
+		return err
+	}
+	s.invalidateUserRoles(ctx, userIDs...)
+	return nil
+}
+
+// OrgRoleUserIDs 返回持有组织级角色的用户，未配置缓存时无需清除，直接返回空
+func (s *rbacService) OrgRoleUserIDs(ctx context.Context, orgID string) ([]string, error) {
+	if orgID == "" {
+		return nil, ErrOrgIDEmpty
+	}
+	if s.redis == nil {
+		return nil, nil
+	}
+	return s.userRoleRepo.ListUserIDsByOrg(ctx, orgID)
+}
+
+func (s *rbacService) InvalidateUserRolesCache(ctx context.Context, userIDs ...string) {
+	s.invalidateUserRoles(ctx, userIDs...)
 }
 
 func (s *rbacService) ListRoles(ctx context.Context, orgID string, page *repository.Pagination) ([]*model.Role, int64, error) {
@@ -146,6 +259,8 @@ func (s *rbacService) CreatePermission(ctx context.Context, perm *model.Permissi
 	if err == nil && existing != nil {
 		return ErrPermissionExists
 	}
+	// 系统内置权限只能由初始化创建
+	perm.IsSystem = false
 
 	return s.permRepo.Create(ctx, perm)
 }
@@ -171,34 +286,184 @@ func (s *rbacService) DeletePermission(ctx context.Context, id string) error {
 	return s.permRepo.Delete(ctx, id)
 }
 
-func (s *rbacService) ListPermissions(ctx context.Context, orgID string) ([]*model.Permission, error) {
-	return s.permRepo.List(ctx, orgID)
+func (s *rbacService) ListPermissions(ctx context.Context, filter *repository.PermissionFilter, page *repository.Pagination) ([]*model.Permission, int64, error) {
+	// 设置默认分页
+	if page == nil {
+		page = &repository.Pagination{Page: 1, PageSize: 20}
+	}
+	if page.Page < 1 {
+		page.Page = 1
+	}
+	if page.PageSize < 1 || page.PageSize > 100 {
+		page.PageSize = 20
+	}
+	return s.permRepo.List(ctx, filter, page)
 }
 
 // 角色权限关联
 
-func (s *rbacService) AddPermissionsToRole(ctx context.Context, roleID string, permissionIDs []string) error {
+func (s *rbacService) AddPermissionsToRole(ctx context.Context, operatorID, roleID string, permissionIDs []string) error {
+	if err := s.checkRolePermissionsModifiable(ctx, operatorID, roleID); err != nil {
+		return err
+	}
 	return s.roleRepo.AddPermissions(ctx, roleID, permissionIDs)
 }
 
-func (s *rbacService) RemovePermissionsFromRole(ctx context.Context, roleID string, permissionIDs []string) error {
+func (s *rbacService) RemovePermissionsFromRole(ctx context.Context, operatorID, roleID string, permissionIDs []string) error {
+	if err := s.checkRolePermissionsModifiable(ctx, operatorID, roleID); err != nil {
+		return err
+	}
 	return s.roleRepo.RemovePermissions(ctx, roleID, permissionIDs)
 }
 
+func (s *rbacService) SetRolePermissions(ctx context.Context, operatorID, roleID string, permissionIDs []string) error {
+	if err := s.checkRolePermissionsModifiable(ctx, operatorID, roleID); err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool, len(permissionIDs))
+	unique := make([]string, 0, len(permissionIDs))
+	for _, id := range permissionIDs {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+
+	err := s.roleRepo.SetPermissions(ctx, roleID, unique)
+	if errors.Is(err, repository.ErrPermissionNotFound) {
+		return ErrPermissionNotFound
+	}
+	return err
+}
+
+// checkRolePermissionsModifiable 系统内置角色的权限不可调整，仅超级管理员可调整 super_admin 角色
+func (s *rbacService) checkRolePermissionsModifiable(ctx context.Context, operatorID, roleID string) error {
+	role, err := s.roleRepo.GetByID(ctx, roleID)
+	if err != nil {
+		return ErrRoleNotFound
+	}
+	if !role.IsSystem {
+		return nil
+	}
+	if role.Code == model.RoleSuperAdmin && operatorID != "" {
+		isSuperAdmin, err := s.userRoleRepo.HasRole(ctx, operatorID, model.RoleSuperAdmin)
+		if err != nil {
+			return err
+		}
+		if isSuperAdmin {
+			return nil
+		}
+	}
+	return ErrSystemRole
+}
+
+// AddPermissionToRoles 将权限批量添加到多个角色，返回实际新增了该权限的角色 ID
+// 已拥有该权限的角色会被跳过；列表中包含系统内置角色时整体拒绝，不做任何修改
+func (s *rbacService) AddPermissionToRoles(ctx context.Context, permissionID string, roleIDs []string) ([]string, error) {
+	if _, err := s.permRepo.GetByID(ctx, permissionID); err != nil {
+		return nil, ErrPermissionNotFound
+	}
+
+	seen := make(map[string]bool, len(roleIDs))
+	added := make([]string, 0, len(roleIDs))
+	for _, roleID := range roleIDs {
+		if seen[roleID] {
+			continue
+		}
+		seen[roleID] = true
+
+		role, err := s.roleRepo.GetByID(ctx, roleID)
+		if err != nil {
+			return nil, ErrRoleNotFound
+		}
+		if role.IsSystem {
+			return nil, ErrSystemRole
+		}
+		if roleHasPermission(role, permissionID) {
+			continue
+		}
+		added = append(added, roleID)
+	}
+
+	if len(added) == 0 {
+		return added, nil
+	}
+	if err := s.roleRepo.AddPermissionToRoles(ctx, permissionID, added); err != nil {
+		return nil, err
+	}
+	return added, nil
+}
+
+// roleHasPermission 检查角色是否已拥有指定权限
+func roleHasPermission(role *model.Role, permissionID string) bool {
+	for _, perm := range role.Permissions {
+		if perm.ID == permissionID {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *rbacService) GetRolePermissions(ctx context.Context, roleID string) ([]model.Permission, error) {
 	return s.roleRepo.GetPermissions(ctx, roleID)
 }
 
+// ListAssignablePermissions 列出可分配给角色的权限
+// 包括系统级权限和角色所属组织的权限，排除角色已拥有的权限
+func (s *rbacService) ListAssignablePermissions(ctx context.Context, roleID string) ([]*model.Permission, error) {
+	role, err := s.roleRepo.GetByID(ctx, roleID)
+	if err != nil {
+		return nil, ErrRoleNotFound
+	}
+
+	// 系统角色的 OrgID 为空，此时 List 会返回全部权限，需按组织再过滤一次
+	perms, _, err := s.permRepo.List(ctx, &repository.PermissionFilter{OrgID: role.OrgID}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	assigned := make(map[string]bool, len(role.Permissions))
+	for _, perm := range role.Permissions {
+		assigned[perm.ID] = true
+	}
+
+	assignable := make([]*model.Permission, 0, len(perms))
+	for _, perm := range perms {
+		if perm.OrgID != "" && perm.OrgID != role.OrgID {
+			continue
+		}
+		if assigned[perm.ID] {
+			continue
+		}
+		assignable = append(assignable, perm)
+	}
+	return assignable, nil
+}
+
 // 用户角色
 
 func (s *rbacService) AssignRole(ctx context.Context, userID, roleID string) error {
 	// 检查角色是否存在
-	_, err := s.roleRepo.GetByID(ctx, roleID)
+	role, err := s.roleRepo.GetByID(ctx, roleID)
+	if err != nil {
+		return ErrRoleNotFound
+	}
+
+	return s.assignRole(ctx, userID, role, nil)
+}
+
+func (s *rbacService) AssignRoleWithExpiry(ctx context.Context, userID, roleID string, expiresAt time.Time) error {
+	if !expiresAt.After(time.Now()) {
+		return ErrRoleExpiryInvalid
+	}
+
+	role, err := s.roleRepo.GetByID(ctx, roleID)
 	if err != nil {
 		return ErrRoleNotFound
 	}
 
-	return s.userRoleRepo.Assign(ctx, userID, roleID)
+	return s.assignRole(ctx, userID, role, &expiresAt)
 }
 
 func (s *rbacService) AssignRoleByCode(ctx context.Context, userID, roleCode string) error {
@@ -208,21 +473,155 @@ func (s *rbacService) AssignRoleByCode(ctx context.Context, userID, roleCode str
 		return ErrRoleNotFound
 	}
 
-	return s.userRoleRepo.Assign(ctx, userID, role.ID)
+	return s.assignRole(ctx, userID, role, nil)
+}
+
+// assignRole 检查重复分配和组织边界后写入用户角色，expiresAt 为 nil 表示永久有效
+// 已过期的分配不算重复，会被新的分配覆盖
+func (s *rbacService) assignRole(ctx context.Context, userID string, role *model.Role, expiresAt *time.Time) error {
+	hasRole, err := s.userRoleRepo.HasRole(ctx, userID, role.Code)
+	if err != nil {
+		return err
+	}
+	if hasRole {
+		return ErrRoleAlreadyAssigned
+	}
+
+	if role.OrgID != "" && s.orgAccess != nil {
+		isMember, err := s.orgAccess.HasOrgAccess(ctx, userID, role.OrgID)
+		if err != nil {
+			return err
+		}
+		if !isMember {
+			return ErrRoleOrgMismatch
+		}
+	}
+
+	if expiresAt != nil {
+		err = s.userRoleRepo.AssignWithExpiry(ctx, userID, role.ID, expiresAt)
+	} else {
+		err = s.userRoleRepo.Assign(ctx, userID, role.ID)
+	}
+	if err != nil {
+		return err
+	}
+	s.invalidateUserRoles(ctx, userID)
+	return nil
 }
 
 func (s *rbacService) RevokeRole(ctx context.Context, userID, roleID string) error {
-	return s.userRoleRepo.Revoke(ctx, userID, roleID)
+	if err := s.userRoleRepo.Revoke(ctx, userID, roleID); err != nil {
+		return err
+	}
+	s.invalidateUserRoles(ctx, userID)
+	return nil
 }
 
 func (s *rbacService) GetUserRoles(ctx context.Context, userID string) ([]*model.Role, error) {
 	return s.userRoleRepo.GetUserRoles(ctx, userID)
 }
 
+// GetUserRolesCached 优先读取缓存的角色代码，缓存不可用时回退到数据库
+// 角色到期等不经过分配和撤销的变化最多延迟 UserRolesCacheTTL 生效
+func (s *rbacService) GetUserRolesCached(ctx context.Context, userID string) ([]string, error) {
+	key := userRolesCachePrefix + userID
+	if s.redis != nil {
+		if data, err := s.redis.Get(ctx, key).Bytes(); err == nil {
+			var codes []string
+			if json.Unmarshal(data, &codes) == nil {
+				return codes, nil
+			}
+		}
+	}
+
+	roles, err := s.userRoleRepo.GetUserRoles(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	codes := make([]string, len(roles))
+	for i, role := range roles {
+		codes[i] = role.Code
+	}
+
+	if s.redis != nil {
+		if data, err := json.Marshal(codes); err == nil {
+			s.redis.Set(ctx, key, data, UserRolesCacheTTL)
+		}
+	}
+	return codes, nil
+}
+
+// invalidateUserRoles 用户角色变更后删除缓存
+func (s *rbacService) invalidateUserRoles(ctx context.Context, userIDs ...string) {
+	invalidateUserRolesCache(ctx, s.redis, userIDs...)
+}
+
+// invalidateUserRolesCache 删除用户的角色缓存，client 为空时跳过
+// 合并用户等不经过 RBAC 服务修改 user_roles 的操作也需调用
+func invalidateUserRolesCache(ctx context.Context, client *redis.Client, userIDs ...string) {
+	if client == nil || len(userIDs) == 0 {
+		return
+	}
+	keys := make([]string, len(userIDs))
+	for i, id := range userIDs {
+		keys[i] = userRolesCachePrefix + id
+	}
+	client.Del(ctx, keys...)
+}
+
+func (s *rbacService) GetRoleUsers(ctx context.Context, roleID string, page *repository.Pagination) ([]*model.User, int64, error) {
+	return s.userRoleRepo.GetRoleUsers(ctx, roleID, page)
+}
+
 func (s *rbacService) HasRole(ctx context.Context, userID, roleCode string) (bool, error) {
 	return s.userRoleRepo.HasRole(ctx, userID, roleCode)
 }
 
+// ListAssignableRoles 列出操作者可分配给用户的角色，排除用户已拥有的角色
+// 超级管理员可分配全部角色；其他管理员不能分配超级管理员角色，组织角色仅限 orgIDs 内的组织
+func (s *rbacService) ListAssignableRoles(ctx context.Context, operatorID, userID string, orgIDs []string) ([]*model.Role, error) {
+	isSuperAdmin, err := s.userRoleRepo.HasRole(ctx, operatorID, model.RoleSuperAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	roles, _, err := s.roleRepo.List(ctx, "", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	userRoles, err := s.userRoleRepo.GetUserRoles(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	assigned := make(map[string]bool, len(userRoles))
+	for _, role := range userRoles {
+		assigned[role.ID] = true
+	}
+
+	allowedOrgs := make(map[string]bool, len(orgIDs))
+	for _, orgID := range orgIDs {
+		allowedOrgs[orgID] = true
+	}
+
+	assignable := make([]*model.Role, 0, len(roles))
+	for _, role := range roles {
+		if assigned[role.ID] || role.Status == model.StatusDisabled {
+			continue
+		}
+		if !isSuperAdmin {
+			if role.Code == model.RoleSuperAdmin {
+				continue
+			}
+			if role.OrgID != "" && !allowedOrgs[role.OrgID] {
+				continue
+			}
+		}
+		assignable = append(assignable, role)
+	}
+	return assignable, nil
+}
+
 // 权限检查
 
 func (s *rbacService) CheckPermission(ctx context.Context, userID, resource, action string) (bool, error) {
@@ -252,6 +651,13 @@ func (s *rbacService) CheckPermission(ctx context.Context, userID, resource, act
 	return false, nil
 }
 
+func (s *rbacService) CheckResourceAccess(ctx context.Context, userID, resource, action, ownerID string) (bool, error) {
+	if ownerID != "" && ownerID == userID {
+		return true, nil
+	}
+	return s.CheckPermission(ctx, userID, resource, action)
+}
+
 func (s *rbacService) GetUserPermissions(ctx context.Context, userID string) ([]string, error) {
 	roles, err := s.userRoleRepo.GetUserRoles(ctx, userID)
 	if err != nil {
@@ -280,19 +686,25 @@ func (s *rbacService) GetUserPermissions(ctx context.Context, userID string) ([]
 // 初始化默认角色和权限
 
 func (s *rbacService) InitDefaultRolesAndPermissions(ctx context.Context) error {
-	// 创建默认权限
-	defaultPerms := model.DefaultSystemPermissions()
-	for _, perm := range defaultPerms {
+	// 创建系统内置权限，同代码的已有权限标记为系统内置
+	for _, perm := range s.systemPermissions {
 		existing, _ := s.permRepo.GetByCode(ctx, perm.Code)
 		if existing == nil {
 			if err := s.permRepo.Create(ctx, &perm); err != nil {
 				return err
 			}
+			continue
+		}
+		if !existing.IsSystem {
+			existing.IsSystem = true
+			if err := s.permRepo.Update(ctx, existing); err != nil {
+				return err
+			}
 		}
 	}
 
 	// 获取所有权限 ID
-	allPerms, err := s.permRepo.List(ctx, "")
+	allPerms, _, err := s.permRepo.List(ctx, nil, nil)
 	if err != nil {
 		return err
 	}
@@ -322,3 +734,49 @@ func (s *rbacService) InitDefaultRolesAndPermissions(ctx context.Context) error
 
 	return nil
 }
+
+// 应用角色
+
+func (s *rbacService) AssignAppRole(ctx context.Context, operatorID, appID, roleID string) error {
+	if s.appRoleRepo == nil {
+		return ErrRoleNotAssignable
+	}
+	role, err := s.roleRepo.GetByID(ctx, roleID)
+	if err != nil {
+		return ErrRoleNotFound
+	}
+	if role.Code == model.RoleSuperAdmin {
+		isSuperAdmin, err := s.userRoleRepo.HasRole(ctx, operatorID, model.RoleSuperAdmin)
+		if err != nil {
+			return err
+		}
+		if !isSuperAdmin {
+			return ErrRoleNotAssignable
+		}
+	}
+
+	roles, err := s.appRoleRepo.GetAppRoles(ctx, appID)
+	if err != nil {
+		return err
+	}
+	for _, r := range roles {
+		if r.ID == roleID {
+			return ErrRoleAlreadyAssigned
+		}
+	}
+	return s.appRoleRepo.Assign(ctx, appID, roleID)
+}
+
+func (s *rbacService) RevokeAppRole(ctx context.Context, appID, roleID string) error {
+	if s.appRoleRepo == nil {
+		return nil
+	}
+	return s.appRoleRepo.Revoke(ctx, appID, roleID)
+}
+
+func (s *rbacService) GetAppRoles(ctx context.Context, appID string) ([]*model.Role, error) {
+	if s.appRoleRepo == nil {
+		return []*model.Role{}, nil
+	}
+	return s.appRoleRepo.GetAppRoles(ctx, appID)
+}
@@ -2,7 +2,9 @@ package service
 
 import (
 	"context"
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/pu-ac-cn/uac-backend/internal/model"
 	"github.com/pu-ac-cn/uac-backend/internal/repository"
@@ -61,11 +63,26 @@ func (m *MockRoleRepository) RemovePermissions(ctx context.Context, roleID strin
 	return args.Error(0)
 }
 
+func (m *MockRoleRepository) SetPermissions(ctx context.Context, roleID string, permissionIDs []string) error {
+	args := m.Called(ctx, roleID, permissionIDs)
+	return args.Error(0)
+}
+
+func (m *MockRoleRepository) AddPermissionToRoles(ctx context.Context, permissionID string, roleIDs []string) error {
+	args := m.Called(ctx, permissionID, roleIDs)
+	return args.Error(0)
+}
+
 func (m *MockRoleRepository) GetPermissions(ctx context.Context, roleID string) ([]model.Permission, error) {
 	args := m.Called(ctx, roleID)
 	return args.Get(0).([]model.Permission), args.Error(1)
 }
 
+func (m *MockRoleRepository) DeleteOrgScoped(ctx context.Context, orgID string) error {
+	args := m.Called(ctx, orgID)
+	return args.Error(0)
+}
+
 // MockPermissionRepository 权限仓库 Mock
 type MockPermissionRepository struct {
 	mock.Mock
@@ -92,14 +109,19 @@ func (m *MockPermissionRepository) GetByCode(ctx context.Context, code string) (
 	return args.Get(0).(*model.Permission), args.Error(1)
 }
 
+func (m *MockPermissionRepository) Update(ctx context.Context, perm *model.Permission) error {
+	args := m.Called(ctx, perm)
+	return args.Error(0)
+}
+
 func (m *MockPermissionRepository) Delete(ctx context.Context, id string) error {
 	args := m.Called(ctx, id)
 	return args.Error(0)
 }
 
-func (m *MockPermissionRepository) List(ctx context.Context, orgID string) ([]*model.Permission, error) {
-	args := m.Called(ctx, orgID)
-	return args.Get(0).([]*model.Permission), args.Error(1)
+func (m *MockPermissionRepository) List(ctx context.Context, filter *repository.PermissionFilter, page *repository.Pagination) ([]*model.Permission, int64, error) {
+	args := m.Called(ctx, filter, page)
+	return args.Get(0).([]*model.Permission), args.Get(1).(int64), args.Error(2)
 }
 
 func (m *MockPermissionRepository) BatchCreate(ctx context.Context, perms []model.Permission) error {
@@ -117,6 +139,11 @@ func (m *MockUserRoleRepository) Assign(ctx context.Context, userID, roleID stri
 	return args.Error(0)
 }
 
+func (m *MockUserRoleRepository) AssignWithExpiry(ctx context.Context, userID, roleID string, expiresAt *time.Time) error {
+	args := m.Called(ctx, userID, roleID, expiresAt)
+	return args.Error(0)
+}
+
 func (m *MockUserRoleRepository) Revoke(ctx context.Context, userID, roleID string) error {
 	args := m.Called(ctx, userID, roleID)
 	return args.Error(0)
@@ -137,6 +164,16 @@ func (m *MockUserRoleRepository) HasRole(ctx context.Context, userID, roleCode s
 	return args.Bool(0), args.Error(1)
 }
 
+func (m *MockUserRoleRepository) ListUserIDsByRole(ctx context.Context, roleID string) ([]string, error) {
+	args := m.Called(ctx, roleID)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockUserRoleRepository) ListUserIDsByOrg(ctx context.Context, orgID string) ([]string, error) {
+	args := m.Called(ctx, orgID)
+	return args.Get(0).([]string), args.Error(1)
+}
+
 // 测试用例
 
 func TestRBACService_CreateRole(t *testing.T) {
@@ -289,6 +326,7 @@ func TestRBACService_AssignRole(t *testing.T) {
 	}
 
 	roleRepo.On("GetByID", ctx, "role-1").Return(role, nil).Once()
+	userRoleRepo.On("HasRole", ctx, "user-1", "user").Return(false, nil).Once()
 	userRoleRepo.On("Assign", ctx, "user-1", "role-1").Return(nil).Once()
 
 	err := svc.AssignRole(ctx, "user-1", "role-1")
@@ -296,3 +334,664 @@ func TestRBACService_AssignRole(t *testing.T) {
 	roleRepo.AssertExpectations(t)
 	userRoleRepo.AssertExpectations(t)
 }
+
+func TestRBACService_AssignRole_AlreadyAssigned(t *testing.T) {
+	ctx := context.Background()
+	roleRepo := new(MockRoleRepository)
+	userRoleRepo := new(MockUserRoleRepository)
+	svc := NewRBACService(roleRepo, new(MockPermissionRepository), userRoleRepo)
+
+	role := &model.Role{BaseModel: model.BaseModel{ID: "role-1"}, Code: "user"}
+	roleRepo.On("GetByID", ctx, "role-1").Return(role, nil)
+	roleRepo.On("GetByCode", ctx, "user").Return(role, nil)
+	userRoleRepo.On("HasRole", ctx, "user-1", "user").Return(true, nil)
+
+	assert.Equal(t, ErrRoleAlreadyAssigned, svc.AssignRole(ctx, "user-1", "role-1"))
+	assert.Equal(t, ErrRoleAlreadyAssigned, svc.AssignRoleByCode(ctx, "user-1", "user"))
+	userRoleRepo.AssertNotCalled(t, "Assign", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRBACService_AssignRoleWithExpiry(t *testing.T) {
+	ctx := context.Background()
+	roleRepo := new(MockRoleRepository)
+	userRoleRepo := new(MockUserRoleRepository)
+	svc := NewRBACService(roleRepo, new(MockPermissionRepository), userRoleRepo)
+
+	role := &model.Role{BaseModel: model.BaseModel{ID: "role-1"}, Code: "user"}
+	expiresAt := time.Now().Add(time.Hour)
+	roleRepo.On("GetByID", ctx, "role-1").Return(role, nil).Once()
+	userRoleRepo.On("HasRole", ctx, "user-1", "user").Return(false, nil).Once()
+	userRoleRepo.On("AssignWithExpiry", ctx, "user-1", "role-1", &expiresAt).Return(nil).Once()
+
+	assert.NoError(t, svc.AssignRoleWithExpiry(ctx, "user-1", "role-1", expiresAt))
+	userRoleRepo.AssertExpectations(t)
+
+	// 到期时间必须在未来
+	assert.Equal(t, ErrRoleExpiryInvalid, svc.AssignRoleWithExpiry(ctx, "user-1", "role-1", time.Now().Add(-time.Minute)))
+	userRoleRepo.AssertNumberOfCalls(t, "AssignWithExpiry", 1)
+}
+
+// expiringUserRoleRepository 内存中的用户角色仓库，按到期时间过滤，模拟 user_roles 查询的过期条件
+type expiringUserRoleRepository struct {
+	repository.UserRoleRepository
+	roles     map[string]*model.Role
+	userRoles []model.UserRole
+}
+
+func (r *expiringUserRoleRepository) AssignWithExpiry(ctx context.Context, userID, roleID string, expiresAt *time.Time) error {
+	r.userRoles = append(r.userRoles, model.UserRole{UserID: userID, RoleID: roleID, ExpiresAt: expiresAt})
+	return nil
+}
+
+func (r *expiringUserRoleRepository) GetUserRoles(ctx context.Context, userID string) ([]*model.Role, error) {
+	now := time.Now()
+	var roles []*model.Role
+	for _, ur := range r.userRoles {
+		if ur.UserID == userID && (ur.ExpiresAt == nil || ur.ExpiresAt.After(now)) {
+			roles = append(roles, r.roles[ur.RoleID])
+		}
+	}
+	return roles, nil
+}
+
+func (r *expiringUserRoleRepository) HasRole(ctx context.Context, userID, roleCode string) (bool, error) {
+	roles, _ := r.GetUserRoles(ctx, userID)
+	for _, role := range roles {
+		if role.Code == roleCode {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func TestRBACService_ExpiredRoleGrantsNoPermission(t *testing.T) {
+	ctx := context.Background()
+	roleRepo := new(MockRoleRepository)
+	role := &model.Role{
+		BaseModel:   model.BaseModel{ID: "role-1"},
+		Code:        "auditor",
+		Permissions: []model.Permission{{Code: "audit:read"}},
+	}
+	roleRepo.On("GetByID", ctx, "role-1").Return(role, nil)
+	userRoleRepo := &expiringUserRoleRepository{roles: map[string]*model.Role{"role-1": role}}
+	svc := NewRBACService(roleRepo, new(MockPermissionRepository), userRoleRepo)
+
+	assert.NoError(t, svc.AssignRoleWithExpiry(ctx, "user-1", "role-1", time.Now().Add(time.Hour)))
+	allowed, err := svc.CheckPermission(ctx, "user-1", "audit", "read")
+	assert.NoError(t, err)
+	assert.True(t, allowed)
+
+	// 角色到期后不再授予权限
+	expired := time.Now().Add(-time.Second)
+	userRoleRepo.userRoles[0].ExpiresAt = &expired
+	allowed, err = svc.CheckPermission(ctx, "user-1", "audit", "read")
+	assert.NoError(t, err)
+	assert.False(t, allowed)
+	perms, err := svc.GetUserPermissions(ctx, "user-1")
+	assert.NoError(t, err)
+	assert.Empty(t, perms)
+}
+
+// stubOrgAccess 固定的组织成员关系
+type stubOrgAccess map[string]bool
+
+func (s stubOrgAccess) HasOrgAccess(ctx context.Context, userID, orgID string) (bool, error) {
+	return s[userID+"@"+orgID], nil
+}
+
+func TestRBACService_AssignRole_OrgBoundary(t *testing.T) {
+	ctx := context.Background()
+	roleRepo := new(MockRoleRepository)
+	userRoleRepo := new(MockUserRoleRepository)
+	svc := NewRBACService(roleRepo, new(MockPermissionRepository), userRoleRepo, &RBACServiceConfig{
+		OrgAccess: stubOrgAccess{"member@org-1": true},
+	})
+
+	role := &model.Role{BaseModel: model.BaseModel{ID: "role-org"}, OrgID: "org-1", Code: "org1_editor"}
+	roleRepo.On("GetByID", ctx, "role-org").Return(role, nil)
+	userRoleRepo.On("HasRole", ctx, mock.Anything, "org1_editor").Return(false, nil)
+	userRoleRepo.On("Assign", ctx, "member", "role-org").Return(nil).Once()
+
+	// 组织成员可以分配，非成员被拒绝
+	assert.NoError(t, svc.AssignRole(ctx, "member", "role-org"))
+	assert.Equal(t, ErrRoleOrgMismatch, svc.AssignRole(ctx, "outsider", "role-org"))
+	userRoleRepo.AssertExpectations(t)
+}
+
+func TestRBACService_ListAssignablePermissions(t *testing.T) {
+	ctx := context.Background()
+	roleRepo := new(MockRoleRepository)
+	permRepo := new(MockPermissionRepository)
+	userRoleRepo := new(MockUserRoleRepository)
+
+	svc := NewRBACService(roleRepo, permRepo, userRoleRepo)
+
+	sysRead := &model.Permission{BaseModel: model.BaseModel{ID: "perm-sys-read"}, Code: "user:read", IsSystem: true}
+	sysWrite := &model.Permission{BaseModel: model.BaseModel{ID: "perm-sys-write"}, Code: "user:write", IsSystem: true}
+	orgA := &model.Permission{BaseModel: model.BaseModel{ID: "perm-a"}, OrgID: "org-a", Code: "report:read"}
+	orgB := &model.Permission{BaseModel: model.BaseModel{ID: "perm-b"}, OrgID: "org-b", Code: "invoice:read"}
+
+	role := &model.Role{
+		BaseModel:   model.BaseModel{ID: "role-a"},
+		OrgID:       "org-a",
+		Permissions: []model.Permission{*sysWrite},
+	}
+
+	roleRepo.On("GetByID", ctx, "role-a").Return(role, nil).Once()
+	permRepo.On("List", ctx, &repository.PermissionFilter{OrgID: "org-a"}, (*repository.Pagination)(nil)).Return([]*model.Permission{sysRead, sysWrite, orgA, orgB}, int64(4), nil).Once()
+
+	perms, err := svc.ListAssignablePermissions(ctx, "role-a")
+	assert.NoError(t, err)
+
+	ids := make([]string, 0, len(perms))
+	for _, perm := range perms {
+		ids = append(ids, perm.ID)
+	}
+	assert.ElementsMatch(t, []string{"perm-sys-read", "perm-a"}, ids)
+	assert.NotContains(t, ids, "perm-b")
+	assert.NotContains(t, ids, "perm-sys-write")
+}
+
+func TestRBACService_ListAssignablePermissions_SystemRole(t *testing.T) {
+	ctx := context.Background()
+	roleRepo := new(MockRoleRepository)
+	permRepo := new(MockPermissionRepository)
+	userRoleRepo := new(MockUserRoleRepository)
+
+	svc := NewRBACService(roleRepo, permRepo, userRoleRepo)
+
+	sysRead := &model.Permission{BaseModel: model.BaseModel{ID: "perm-sys-read"}, Code: "user:read", IsSystem: true}
+	orgA := &model.Permission{BaseModel: model.BaseModel{ID: "perm-a"}, OrgID: "org-a", Code: "report:read"}
+
+	role := &model.Role{BaseModel: model.BaseModel{ID: "role-sys"}, IsSystem: true}
+	roleRepo.On("GetByID", ctx, "role-sys").Return(role, nil).Once()
+	permRepo.On("List", ctx, &repository.PermissionFilter{}, (*repository.Pagination)(nil)).Return([]*model.Permission{sysRead, orgA}, int64(2), nil).Once()
+
+	perms, err := svc.ListAssignablePermissions(ctx, "role-sys")
+	assert.NoError(t, err)
+	assert.Len(t, perms, 1)
+	assert.Equal(t, "perm-sys-read", perms[0].ID)
+}
+
+func TestRBACService_ListAssignablePermissions_RoleNotFound(t *testing.T) {
+	ctx := context.Background()
+	roleRepo := new(MockRoleRepository)
+	permRepo := new(MockPermissionRepository)
+	userRoleRepo := new(MockUserRoleRepository)
+
+	svc := NewRBACService(roleRepo, permRepo, userRoleRepo)
+
+	roleRepo.On("GetByID", ctx, "missing").Return(nil, assert.AnError).Once()
+
+	_, err := svc.ListAssignablePermissions(ctx, "missing")
+	assert.ErrorIs(t, err, ErrRoleNotFound)
+}
+
+func TestRBACService_AddPermissionToRoles(t *testing.T) {
+	ctx := context.Background()
+	roleRepo := new(MockRoleRepository)
+	permRepo := new(MockPermissionRepository)
+	userRoleRepo := new(MockUserRoleRepository)
+
+	svc := NewRBACService(roleRepo, permRepo, userRoleRepo)
+
+	perm := &model.Permission{BaseModel: model.BaseModel{ID: "perm-1"}, Code: "report:read"}
+	roleA := &model.Role{BaseModel: model.BaseModel{ID: "role-a"}}
+	roleB := &model.Role{BaseModel: model.BaseModel{ID: "role-b"}, Permissions: []model.Permission{*perm}}
+	roleC := &model.Role{BaseModel: model.BaseModel{ID: "role-c"}}
+
+	permRepo.On("GetByID", ctx, "perm-1").Return(perm, nil)
+	roleRepo.On("GetByID", ctx, "role-a").Return(roleA, nil).Once()
+	roleRepo.On("GetByID", ctx, "role-b").Return(roleB, nil).Once()
+	roleRepo.On("GetByID", ctx, "role-c").Return(roleC, nil).Once()
+	roleRepo.On("AddPermissionToRoles", ctx, "perm-1", []string{"role-a", "role-c"}).Return(nil).Once()
+
+	// role-b 已拥有该权限，重复的 role-a 只处理一次
+	added, err := svc.AddPermissionToRoles(ctx, "perm-1", []string{"role-a", "role-b", "role-c", "role-a"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"role-a", "role-c"}, added)
+
+	// 再次执行时所有角色都已拥有该权限，不再写入
+	roleA.Permissions = []model.Permission{*perm}
+	roleC.Permissions = []model.Permission{*perm}
+	roleRepo.On("GetByID", ctx, "role-a").Return(roleA, nil).Once()
+	roleRepo.On("GetByID", ctx, "role-b").Return(roleB, nil).Once()
+	roleRepo.On("GetByID", ctx, "role-c").Return(roleC, nil).Once()
+
+	added, err = svc.AddPermissionToRoles(ctx, "perm-1", []string{"role-a", "role-b", "role-c"})
+	assert.NoError(t, err)
+	assert.Empty(t, added)
+	roleRepo.AssertExpectations(t)
+	roleRepo.AssertNumberOfCalls(t, "AddPermissionToRoles", 1)
+}
+
+func TestRBACService_AddPermissionToRoles_SystemRoleRejected(t *testing.T) {
+	ctx := context.Background()
+	roleRepo := new(MockRoleRepository)
+	permRepo := new(MockPermissionRepository)
+	userRoleRepo := new(MockUserRoleRepository)
+
+	svc := NewRBACService(roleRepo, permRepo, userRoleRepo)
+
+	perm := &model.Permission{BaseModel: model.BaseModel{ID: "perm-1"}, Code: "report:read"}
+	roleA := &model.Role{BaseModel: model.BaseModel{ID: "role-a"}}
+	sysRole := &model.Role{BaseModel: model.BaseModel{ID: "role-sys"}, Code: model.RoleUser, IsSystem: true}
+
+	permRepo.On("GetByID", ctx, "perm-1").Return(perm, nil).Once()
+	roleRepo.On("GetByID", ctx, "role-a").Return(roleA, nil).Once()
+	roleRepo.On("GetByID", ctx, "role-sys").Return(sysRole, nil).Once()
+
+	// 包含系统角色时整体拒绝，其余角色也不会被修改
+	_, err := svc.AddPermissionToRoles(ctx, "perm-1", []string{"role-a", "role-sys"})
+	assert.ErrorIs(t, err, ErrSystemRole)
+	roleRepo.AssertNotCalled(t, "AddPermissionToRoles", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRBACService_AddPermissionToRoles_NotFound(t *testing.T) {
+	ctx := context.Background()
+	roleRepo := new(MockRoleRepository)
+	permRepo := new(MockPermissionRepository)
+	userRoleRepo := new(MockUserRoleRepository)
+
+	svc := NewRBACService(roleRepo, permRepo, userRoleRepo)
+
+	permRepo.On("GetByID", ctx, "missing").Return(nil, assert.AnError).Once()
+	_, err := svc.AddPermissionToRoles(ctx, "missing", []string{"role-a"})
+	assert.ErrorIs(t, err, ErrPermissionNotFound)
+
+	perm := &model.Permission{BaseModel: model.BaseModel{ID: "perm-1"}}
+	permRepo.On("GetByID", ctx, "perm-1").Return(perm, nil).Once()
+	roleRepo.On("GetByID", ctx, "missing").Return(nil, assert.AnError).Once()
+	_, err = svc.AddPermissionToRoles(ctx, "perm-1", []string{"missing"})
+	assert.ErrorIs(t, err, ErrRoleNotFound)
+}
+
+// assignableRoleFixtures 超级管理员、组织管理员、普通用户三个系统角色及 org-a、org-b 各一个组织角色
+func assignableRoleFixtures() []*model.Role {
+	return []*model.Role{
+		{BaseModel: model.BaseModel{ID: "role-super"}, Code: model.RoleSuperAdmin, IsSystem: true},
+		{BaseModel: model.BaseModel{ID: "role-org-admin"}, Code: model.RoleOrgAdmin, IsSystem: true},
+		{BaseModel: model.BaseModel{ID: "role-user"}, Code: model.RoleUser, IsSystem: true},
+		{BaseModel: model.BaseModel{ID: "role-a"}, Code: "auditor", OrgID: "org-a"},
+		{BaseModel: model.BaseModel{ID: "role-b"}, Code: "finance", OrgID: "org-b"},
+	}
+}
+
+func roleIDs(roles []*model.Role) []string {
+	ids := make([]string, 0, len(roles))
+	for _, role := range roles {
+		ids = append(ids, role.ID)
+	}
+	return ids
+}
+
+func TestRBACService_ListAssignableRoles_OrgAdmin(t *testing.T) {
+	ctx := context.Background()
+	roleRepo := new(MockRoleRepository)
+	permRepo := new(MockPermissionRepository)
+	userRoleRepo := new(MockUserRoleRepository)
+
+	svc := NewRBACService(roleRepo, permRepo, userRoleRepo)
+
+	roles := assignableRoleFixtures()
+	userRoleRepo.On("HasRole", ctx, "admin-1", model.RoleSuperAdmin).Return(false, nil).Once()
+	roleRepo.On("List", ctx, "", (*repository.Pagination)(nil)).Return(roles, int64(len(roles)), nil).Once()
+	userRoleRepo.On("GetUserRoles", ctx, "user-1").Return([]*model.Role{roles[2]}, nil).Once()
+
+	assignable, err := svc.ListAssignableRoles(ctx, "admin-1", "user-1", []string{"org-a"})
+	assert.NoError(t, err)
+
+	// 排除超级管理员角色、其他组织的角色和已拥有的角色
+	ids := roleIDs(assignable)
+	assert.ElementsMatch(t, []string{"role-org-admin", "role-a"}, ids)
+	assert.NotContains(t, ids, "role-super")
+	assert.NotContains(t, ids, "role-b")
+}
+
+func TestRBACService_ListAssignableRoles_SuperAdmin(t *testing.T) {
+	ctx := context.Background()
+	roleRepo := new(MockRoleRepository)
+	permRepo := new(MockPermissionRepository)
+	userRoleRepo := new(MockUserRoleRepository)
+
+	svc := NewRBACService(roleRepo, permRepo, userRoleRepo)
+
+	roles := assignableRoleFixtures()
+	userRoleRepo.On("HasRole", ctx, "admin-1", model.RoleSuperAdmin).Return(true, nil).Once()
+	roleRepo.On("List", ctx, "", (*repository.Pagination)(nil)).Return(roles, int64(len(roles)), nil).Once()
+	userRoleRepo.On("GetUserRoles", ctx, "user-1").Return([]*model.Role{roles[2]}, nil).Once()
+
+	assignable, err := svc.ListAssignableRoles(ctx, "admin-1", "user-1", nil)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"role-super", "role-org-admin", "role-a", "role-b"}, roleIDs(assignable))
+}
+
+func TestRBACService_InitDefault_ExtraSystemPermission(t *testing.T) {
+	ctx := context.Background()
+	roleRepo := new(MockRoleRepository)
+	permRepo := new(MockPermissionRepository)
+	userRoleRepo := new(MockUserRoleRepository)
+
+	svc := NewRBACService(roleRepo, permRepo, userRoleRepo, &RBACServiceConfig{
+		ExtraSystemPermissions: []model.Permission{
+			{Resource: "audit", Action: "read", Description: "查看审计日志"},
+			{Resource: model.ResourceUser, Action: model.ActionRead}, // 与默认权限重复，忽略
+		},
+	})
+
+	created := make(map[string]*model.Permission)
+	permRepo.On("GetByCode", ctx, mock.Anything).Return(nil, errors.New("record not found"))
+	permRepo.On("Create", ctx, mock.Anything).Run(func(args mock.Arguments) {
+		perm := args.Get(1).(*model.Permission)
+		perm.ID = "perm-" + perm.Code
+		created[perm.Code] = perm
+	}).Return(nil)
+	permRepo.On("List", ctx, (*repository.PermissionFilter)(nil), (*repository.Pagination)(nil)).Return([]*model.Permission{}, int64(0), nil)
+	roleRepo.On("GetByCode", ctx, mock.Anything).Return(&model.Role{IsSystem: true}, nil)
+
+	assert.NoError(t, svc.InitDefaultRolesAndPermissions(ctx))
+	assert.Len(t, created, len(model.DefaultSystemPermissions())+1)
+
+	// 额外权限作为系统内置权限创建，不可删除
+	perm := created["audit:read"]
+	if assert.NotNil(t, perm) {
+		assert.True(t, perm.IsSystem)
+		assert.Equal(t, "查看审计日志", perm.Description)
+
+		permRepo.On("GetByID", ctx, perm.ID).Return(perm, nil)
+		assert.Equal(t, ErrSystemPermission, svc.DeletePermission(ctx, perm.ID))
+		permRepo.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+	}
+}
+
+func TestRBACService_InitDefault_MarksExistingPermissionSystem(t *testing.T) {
+	ctx := context.Background()
+	roleRepo := new(MockRoleRepository)
+	permRepo := new(MockPermissionRepository)
+	userRoleRepo := new(MockUserRoleRepository)
+
+	svc := NewRBACService(roleRepo, permRepo, userRoleRepo, &RBACServiceConfig{
+		ExtraSystemPermissions: []model.Permission{{Resource: "audit", Action: "read"}},
+	})
+
+	existing := &model.Permission{BaseModel: model.BaseModel{ID: "perm-audit"}, Code: "audit:read"}
+	permRepo.On("GetByCode", ctx, "audit:read").Return(existing, nil)
+	permRepo.On("GetByCode", ctx, mock.Anything).Return(&model.Permission{IsSystem: true}, nil)
+	permRepo.On("Update", ctx, existing).Return(nil).Once()
+	permRepo.On("List", ctx, (*repository.PermissionFilter)(nil), (*repository.Pagination)(nil)).Return([]*model.Permission{}, int64(0), nil)
+	roleRepo.On("GetByCode", ctx, mock.Anything).Return(&model.Role{IsSystem: true}, nil)
+
+	assert.NoError(t, svc.InitDefaultRolesAndPermissions(ctx))
+	assert.True(t, existing.IsSystem)
+	permRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	permRepo.AssertExpectations(t)
+}
+
+func TestRBACService_UpdateRole_SystemRole(t *testing.T) {
+	ctx := context.Background()
+	roleRepo := new(MockRoleRepository)
+	svc := NewRBACService(roleRepo, new(MockPermissionRepository), new(MockUserRoleRepository))
+
+	systemRole := &model.Role{BaseModel: model.BaseModel{ID: "role-1"}, Code: model.RoleOrgAdmin, IsSystem: true}
+	roleRepo.On("GetByID", ctx, "role-1").Return(systemRole, nil)
+
+	err := svc.UpdateRole(ctx, &model.Role{BaseModel: model.BaseModel{ID: "role-1"}, Code: model.RoleOrgAdmin, Description: "改"})
+	assert.Equal(t, ErrSystemRole, err)
+	roleRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+}
+
+func TestRBACService_AddPermissionsToRole_SystemRole(t *testing.T) {
+	ctx := context.Background()
+	roleRepo := new(MockRoleRepository)
+	userRoleRepo := new(MockUserRoleRepository)
+	svc := NewRBACService(roleRepo, new(MockPermissionRepository), userRoleRepo)
+
+	superAdminRole := &model.Role{BaseModel: model.BaseModel{ID: "role-sa"}, Code: model.RoleSuperAdmin, IsSystem: true}
+	orgAdminRole := &model.Role{BaseModel: model.BaseModel{ID: "role-oa"}, Code: model.RoleOrgAdmin, IsSystem: true}
+	roleRepo.On("GetByID", ctx, "role-sa").Return(superAdminRole, nil)
+	roleRepo.On("GetByID", ctx, "role-oa").Return(orgAdminRole, nil)
+	userRoleRepo.On("HasRole", ctx, "admin", model.RoleSuperAdmin).Return(true, nil)
+	userRoleRepo.On("HasRole", ctx, "manager", model.RoleSuperAdmin).Return(false, nil)
+	roleRepo.On("AddPermissions", ctx, "role-sa", []string{"perm-1"}).Return(nil).Once()
+	roleRepo.On("RemovePermissions", ctx, "role-sa", []string{"perm-1"}).Return(nil).Once()
+
+	// 超级管理员可调整 super_admin 角色的权限
+	assert.NoError(t, svc.AddPermissionsToRole(ctx, "admin", "role-sa", []string{"perm-1"}))
+	assert.NoError(t, svc.RemovePermissionsFromRole(ctx, "admin", "role-sa", []string{"perm-1"}))
+
+	// 其他系统角色及非超级管理员一律拒绝
+	assert.Equal(t, ErrSystemRole, svc.AddPermissionsToRole(ctx, "admin", "role-oa", []string{"perm-1"}))
+	assert.Equal(t, ErrSystemRole, svc.AddPermissionsToRole(ctx, "manager", "role-sa", []string{"perm-1"}))
+	assert.Equal(t, ErrSystemRole, svc.RemovePermissionsFromRole(ctx, "manager", "role-sa", []string{"perm-1"}))
+	roleRepo.AssertExpectations(t)
+}
+
+func TestRBACService_ListPermissions_DefaultPagination(t *testing.T) {
+	ctx := context.Background()
+	permRepo := new(MockPermissionRepository)
+	svc := NewRBACService(new(MockRoleRepository), permRepo, new(MockUserRoleRepository))
+
+	filter := &repository.PermissionFilter{Resource: model.ResourceUser}
+	perms := []*model.Permission{{Code: "user:read"}}
+	permRepo.On("List", ctx, filter, &repository.Pagination{Page: 1, PageSize: 20}).Return(perms, int64(3), nil).Twice()
+
+	// 未提供分页参数时返回第一页
+	list, total, err := svc.ListPermissions(ctx, filter, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, perms, list)
+	assert.Equal(t, int64(3), total)
+
+	// 非法分页参数回退为默认值
+	_, _, err = svc.ListPermissions(ctx, filter, &repository.Pagination{Page: 0, PageSize: 1000})
+	assert.NoError(t, err)
+	permRepo.AssertExpectations(t)
+}
+
+// MockAppRoleRepository 应用角色仓库 Mock
+type MockAppRoleRepository struct {
+	mock.Mock
+}
+
+func (m *MockAppRoleRepository) Assign(ctx context.Context, appID, roleID string) error {
+	args := m.Called(ctx, appID, roleID)
+	return args.Error(0)
+}
+
+func (m *MockAppRoleRepository) Revoke(ctx context.Context, appID, roleID string) error {
+	args := m.Called(ctx, appID, roleID)
+	return args.Error(0)
+}
+
+func (m *MockAppRoleRepository) GetAppRoles(ctx context.Context, appID string) ([]*model.Role, error) {
+	args := m.Called(ctx, appID)
+	return args.Get(0).([]*model.Role), args.Error(1)
+}
+
+func TestRBACService_AssignAppRole(t *testing.T) {
+	ctx := context.Background()
+	roleRepo := new(MockRoleRepository)
+	userRoleRepo := new(MockUserRoleRepository)
+	appRoleRepo := new(MockAppRoleRepository)
+	svc := NewRBACService(roleRepo, new(MockPermissionRepository), userRoleRepo, &RBACServiceConfig{AppRoleRepo: appRoleRepo})
+
+	reader := &model.Role{BaseModel: model.BaseModel{ID: "role-1"}, Code: "user_reader"}
+	superAdmin := &model.Role{BaseModel: model.BaseModel{ID: "role-sa"}, Code: model.RoleSuperAdmin}
+	roleRepo.On("GetByID", ctx, "role-1").Return(reader, nil)
+	roleRepo.On("GetByID", ctx, "role-sa").Return(superAdmin, nil)
+	userRoleRepo.On("HasRole", ctx, "org-admin", model.RoleSuperAdmin).Return(false, nil)
+	appRoleRepo.On("GetAppRoles", ctx, "app-1").Return([]*model.Role{}, nil).Once()
+	appRoleRepo.On("Assign", ctx, "app-1", "role-1").Return(nil).Once()
+
+	assert.NoError(t, svc.AssignAppRole(ctx, "org-admin", "app-1", "role-1"))
+
+	// 只有超级管理员可以为应用分配超级管理员角色
+	assert.ErrorIs(t, svc.AssignAppRole(ctx, "org-admin", "app-1", "role-sa"), ErrRoleNotAssignable)
+
+	// 重复分配
+	appRoleRepo.On("GetAppRoles", ctx, "app-1").Return([]*model.Role{reader}, nil).Once()
+	assert.ErrorIs(t, svc.AssignAppRole(ctx, "org-admin", "app-1", "role-1"), ErrRoleAlreadyAssigned)
+	appRoleRepo.AssertExpectations(t)
+}
+
+func TestRBACService_SetRolePermissions(t *testing.T) {
+	ctx := context.Background()
+	roleRepo := new(MockRoleRepository)
+	svc := NewRBACService(roleRepo, new(MockPermissionRepository), new(MockUserRoleRepository))
+
+	role := &model.Role{BaseModel: model.BaseModel{ID: "role-1"}, Code: "editor"}
+	roleRepo.On("GetByID", ctx, "role-1").Return(role, nil)
+
+	// 替换后的权限集合恰好等于传入集合（重复 ID 只保留一次）
+	var stored []string
+	roleRepo.On("SetPermissions", ctx, "role-1", mock.Anything).Run(func(args mock.Arguments) {
+		stored = args.Get(2).([]string)
+	}).Return(nil).Twice()
+	assert.NoError(t, svc.SetRolePermissions(ctx, "admin", "role-1", []string{"perm-1", "perm-2", "perm-1"}))
+	assert.Equal(t, []string{"perm-1", "perm-2"}, stored)
+
+	// 空列表清空角色权限
+	assert.NoError(t, svc.SetRolePermissions(ctx, "admin", "role-1", []string{}))
+	assert.Empty(t, stored)
+
+	roleRepo.On("SetPermissions", ctx, "role-1", []string{"missing"}).Return(repository.ErrPermissionNotFound).Once()
+	assert.Equal(t, ErrPermissionNotFound, svc.SetRolePermissions(ctx, "admin", "role-1", []string{"missing"}))
+	roleRepo.AssertExpectations(t)
+}
+
+func TestRBACService_SetRolePermissions_SystemRole(t *testing.T) {
+	ctx := context.Background()
+	roleRepo := new(MockRoleRepository)
+	svc := NewRBACService(roleRepo, new(MockPermissionRepository), new(MockUserRoleRepository))
+
+	role := &model.Role{BaseModel: model.BaseModel{ID: "role-oa"}, Code: model.RoleOrgAdmin, IsSystem: true}
+	roleRepo.On("GetByID", ctx, "role-oa").Return(role, nil)
+
+	assert.Equal(t, ErrSystemRole, svc.SetRolePermissions(ctx, "admin", "role-oa", []string{"perm-1"}))
+	roleRepo.AssertNotCalled(t, "SetPermissions", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRBACService_GetUserRolesCached(t *testing.T) {
+	ctx := context.Background()
+	client, cleanup := setupTestRedis(t)
+	defer cleanup()
+
+	roleRepo := new(MockRoleRepository)
+	userRoleRepo := new(MockUserRoleRepository)
+	svc := NewRBACService(roleRepo, new(MockPermissionRepository), userRoleRepo, &RBACServiceConfig{Redis: client})
+
+	userRole := &model.Role{BaseModel: model.BaseModel{ID: "role-user"}, Code: model.RoleUser}
+	adminRole := &model.Role{BaseModel: model.BaseModel{ID: "role-admin"}, Code: model.RoleOrgAdmin}
+	userRoleRepo.On("GetUserRoles", ctx, "user-1").Return([]*model.Role{userRole}, nil).Once()
+
+	codes, err := svc.GetUserRolesCached(ctx, "user-1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{model.RoleUser}, codes)
+
+	// 缓存命中时不再查询数据库
+	codes, err = svc.GetUserRolesCached(ctx, "user-1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{model.RoleUser}, codes)
+	userRoleRepo.AssertNumberOfCalls(t, "GetUserRoles", 1)
+	assert.True(t, client.TTL(ctx, userRolesCachePrefix+"user-1").Val() <= UserRolesCacheTTL)
+
+	// 分配角色后缓存失效
+	roleRepo.On("GetByID", ctx, "role-admin").Return(adminRole, nil)
+	userRoleRepo.On("HasRole", ctx, "user-1", model.RoleOrgAdmin).Return(false, nil)
+	userRoleRepo.On("Assign", ctx, "user-1", "role-admin").Return(nil)
+	assert.NoError(t, svc.AssignRole(ctx, "user-1", "role-admin"))
+
+	userRoleRepo.On("GetUserRoles", ctx, "user-1").Return([]*model.Role{userRole, adminRole}, nil).Once()
+	codes, err = svc.GetUserRolesCached(ctx, "user-1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{model.RoleUser, model.RoleOrgAdmin}, codes)
+	userRoleRepo.AssertNumberOfCalls(t, "GetUserRoles", 2)
+
+	// 撤销角色后缓存失效
+	userRoleRepo.On("Revoke", ctx, "user-1", "role-admin").Return(nil)
+	assert.NoError(t, svc.RevokeRole(ctx, "user-1", "role-admin"))
+
+	userRoleRepo.On("GetUserRoles", ctx, "user-1").Return([]*model.Role{userRole}, nil).Once()
+	codes, err = svc.GetUserRolesCached(ctx, "user-1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{model.RoleUser}, codes)
+	userRoleRepo.AssertNumberOfCalls(t, "GetUserRoles", 3)
+}
+
+func TestRBACService_DeleteOrgScopedRBAC(t *testing.T) {
+	roleRepo := new(MockRoleRepository)
+	svc := NewRBACService(roleRepo, new(MockPermissionRepository), new(MockUserRoleRepository))
+	ctx := context.Background()
+
+	// 组织 ID 为空时不能删除，避免误删系统级角色
+	assert.ErrorIs(t, svc.DeleteOrgScopedRBAC(ctx, ""), ErrOrgIDEmpty)
+	roleRepo.AssertNotCalled(t, "DeleteOrgScoped", mock.Anything, mock.Anything)
+
+	roleRepo.On("DeleteOrgScoped", ctx, "org-1").Return(nil)
+	assert.NoError(t, svc.DeleteOrgScopedRBAC(ctx, "org-1"))
+	roleRepo.AssertExpectations(t)
+}
+
+func TestRBACService_DeleteRole_InvalidatesCache(t *testing.T) {
+	ctx := context.Background()
+	client, cleanup := setupTestRedis(t)
+	defer cleanup()
+
+	roleRepo := new(MockRoleRepository)
+	userRoleRepo := new(MockUserRoleRepository)
+	svc := NewRBACService(roleRepo, new(MockPermissionRepository), userRoleRepo, &RBACServiceConfig{Redis: client})
+
+	role := &model.Role{BaseModel: model.BaseModel{ID: "role-dev"}, OrgID: "org-1", Code: "dev"}
+	for _, id := range []string{"user-1", "user-2", "user-3"} {
+		client.Set(ctx, userRolesCachePrefix+id, `["dev"]`, UserRolesCacheTTL)
+	}
+
+	// 删除角色后清除持有者的缓存
+	roleRepo.On("GetByID", ctx, "role-dev").Return(role, nil)
+	roleRepo.On("Delete", ctx, "role-dev").Return(nil)
+	userRoleRepo.On("ListUserIDsByRole", ctx, "role-dev").Return([]string{"user-1"}, nil)
+	assert.NoError(t, svc.DeleteRole(ctx, "role-dev"))
+	assert.Zero(t, client.Exists(ctx, userRolesCachePrefix+"user-1").Val())
+	assert.EqualValues(t, 1, client.Exists(ctx, userRolesCachePrefix+"user-2").Val())
+
+	// 删除组织级角色后清除持有者的缓存
+	roleRepo.On("DeleteOrgScoped", ctx, "org-1").Return(nil)
+	userRoleRepo.On("ListUserIDsByOrg", ctx, "org-1").Return([]string{"user-2"}, nil)
+	assert.NoError(t, svc.DeleteOrgScopedRBAC(ctx, "org-1"))
+	assert.Zero(t, client.Exists(ctx, userRolesCachePrefix+"user-2").Val())
+	assert.EqualValues(t, 1, client.Exists(ctx, userRolesCachePrefix+"user-3").Val())
+}
+
+func TestRBACService_CheckResourceAccess(t *testing.T) {
+	ctx := context.Background()
+	roleRepo := new(MockRoleRepository)
+	permRepo := new(MockPermissionRepository)
+	userRoleRepo := new(MockUserRoleRepository)
+
+	svc := NewRBACService(roleRepo, permRepo, userRoleRepo)
+
+	// 资源所有者无需角色权限
+	allowed, err := svc.CheckResourceAccess(ctx, "user-1", model.ResourceApp, model.ActionWrite, "user-1")
+	assert.NoError(t, err)
+	assert.True(t, allowed)
+	userRoleRepo.AssertNotCalled(t, "GetUserRoles", ctx, "user-1")
+
+	// 非所有者且无权限
+	userRoleRepo.On("GetUserRoles", ctx, "user-2").Return([]*model.Role{}, nil).Once()
+	allowed, err = svc.CheckResourceAccess(ctx, "user-2", model.ResourceApp, model.ActionWrite, "user-1")
+	assert.NoError(t, err)
+	assert.False(t, allowed)
+
+	// 非所有者但拥有 app:write 权限
+	role := &model.Role{Code: "app_operator", Permissions: []model.Permission{{Code: "app:write"}}}
+	userRoleRepo.On("GetUserRoles", ctx, "user-3").Return([]*model.Role{role}, nil).Once()
+	allowed, err = svc.CheckResourceAccess(ctx, "user-3", model.ResourceApp, model.ActionWrite, "user-1")
+	assert.NoError(t, err)
+	assert.True(t, allowed)
+
+	// 历史数据没有所有者时回退到权限检查
+	userRoleRepo.On("GetUserRoles", ctx, "user-2").Return([]*model.Role{}, nil).Once()
+	allowed, err = svc.CheckResourceAccess(ctx, "user-2", model.ResourceApp, model.ActionWrite, "")
+	assert.NoError(t, err)
+	assert.False(t, allowed)
+	userRoleRepo.AssertExpectations(t)
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/pu-ac-cn/uac-backend/internal/model"
+	"github.com/pu-ac-cn/uac-backend/internal/repository"
+)
+
+// ScopeService scope 说明服务
+type ScopeService interface {
+	// InitDefaultScopes 创建缺失的系统内置 scope，已存在的不覆盖，便于管理员修改说明
+	InitDefaultScopes(ctx context.Context) error
+	// Describe 按 codes 的顺序返回 scope 说明，未登记说明的 scope 以代码作为展示名称
+	Describe(ctx context.Context, codes []string) ([]*model.Scope, error)
+}
+
+type scopeService struct {
+	repo repository.ScopeRepository
+}
+
+// NewScopeService 创建 scope 说明服务
+func NewScopeService(repo repository.ScopeRepository) ScopeService {
+	return &scopeService{repo: repo}
+}
+
+func (s *scopeService) InitDefaultScopes(ctx context.Context) error {
+	for _, scope := range model.DefaultSystemScopes() {
+		_, err := s.repo.GetByCode(ctx, scope.Code)
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, repository.ErrScopeNotFound) {
+			return err
+		}
+		if err := s.repo.Create(ctx, &scope); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *scopeService) Describe(ctx context.Context, codes []string) ([]*model.Scope, error) {
+	known, err := s.repo.ListByCodes(ctx, codes)
+	if err != nil {
+		return nil, err
+	}
+	byCode := make(map[string]*model.Scope, len(known))
+	for _, scope := range known {
+		byCode[scope.Code] = scope
+	}
+
+	scopes := make([]*model.Scope, 0, len(codes))
+	seen := make(map[string]bool, len(codes))
+	for _, code := range codes {
+		if code == "" || seen[code] {
+			continue
+		}
+		seen[code] = true
+		if scope, ok := byCode[code]; ok {
+			scopes = append(scopes, scope)
+			continue
+		}
+		scopes = append(scopes, &model.Scope{Code: code, DisplayName: code})
+	}
+	return scopes, nil
+}
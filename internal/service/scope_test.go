@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pu-ac-cn/uac-backend/internal/model"
+	"github.com/pu-ac-cn/uac-backend/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockScopeRepository 内存 scope 仓库
+type mockScopeRepository struct {
+	scopes map[string]*model.Scope
+}
+
+func newMockScopeRepository() *mockScopeRepository {
+	return &mockScopeRepository{scopes: make(map[string]*model.Scope)}
+}
+
+func (m *mockScopeRepository) Create(ctx context.Context, scope *model.Scope) error {
+	m.scopes[scope.Code] = scope
+	return nil
+}
+
+func (m *mockScopeRepository) GetByCode(ctx context.Context, code string) (*model.Scope, error) {
+	if scope, ok := m.scopes[code]; ok {
+		return scope, nil
+	}
+	return nil, repository.ErrScopeNotFound
+}
+
+func (m *mockScopeRepository) ListByCodes(ctx context.Context, codes []string) ([]*model.Scope, error) {
+	var result []*model.Scope
+	for _, code := range codes {
+		if scope, ok := m.scopes[code]; ok {
+			result = append(result, scope)
+		}
+	}
+	return result, nil
+}
+
+func TestScopeService_InitDefaultScopes(t *testing.T) {
+	repo := newMockScopeRepository()
+	svc := NewScopeService(repo)
+	ctx := context.Background()
+
+	// 管理员修改过的说明不被覆盖
+	require.NoError(t, repo.Create(ctx, &model.Scope{Code: "email", DisplayName: "工作邮箱"}))
+	require.NoError(t, svc.InitDefaultScopes(ctx))
+	require.NoError(t, svc.InitDefaultScopes(ctx))
+
+	assert.Len(t, repo.scopes, len(model.DefaultSystemScopes()))
+	assert.Equal(t, "工作邮箱", repo.scopes["email"].DisplayName)
+	assert.True(t, repo.scopes["openid"].IsSystem)
+	assert.NotEmpty(t, repo.scopes["profile"].Description)
+}
+
+func TestScopeService_Describe(t *testing.T) {
+	repo := newMockScopeRepository()
+	svc := NewScopeService(repo)
+	ctx := context.Background()
+	require.NoError(t, svc.InitDefaultScopes(ctx))
+
+	scopes, err := svc.Describe(ctx, []string{"email", "custom:read", "", "openid", "email"})
+	require.NoError(t, err)
+	require.Len(t, scopes, 3, "忽略空值和重复的 scope")
+
+	// 保持请求顺序，未登记的 scope 以代码作为展示名称
+	assert.Equal(t, "email", scopes[0].Code)
+	assert.Equal(t, "邮箱地址", scopes[0].DisplayName)
+	assert.Equal(t, &model.Scope{Code: "custom:read", DisplayName: "custom:read"}, scopes[1])
+	assert.Equal(t, "openid", scopes[2].Code)
+}
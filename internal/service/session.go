@@ -6,21 +6,32 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
+	"sort"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/pu-ac-cn/uac-backend/internal/metrics"
 	"github.com/pu-ac-cn/uac-backend/internal/model"
 	"github.com/redis/go-redis/v9"
 )
 
 var (
-	ErrSessionNotFound = errors.New("会话不存在")
-	ErrSessionExpired  = errors.New("会话已过期")
-	ErrTGTNotFound     = errors.New("TGT 不存在")
-	ErrTGTExpired      = errors.New("TGT 已过期")
-	ErrSTNotFound      = errors.New("Service Ticket 不存在")
-	ErrSTExpired       = errors.New("Service Ticket 已过期")
-	ErrSTUsed          = errors.New("Service Ticket 已被使用")
+	ErrSessionNotFound   = errors.New("会话不存在")
+	ErrSessionExpired    = errors.New("会话已过期")
+	ErrTGTNotFound       = errors.New("TGT 不存在")
+	ErrTGTExpired        = errors.New("TGT 已过期")
+	ErrSTNotFound        = errors.New("Service Ticket 不存在")
+	ErrSTExpired         = errors.New("Service Ticket 已过期")
+	ErrSTUsed            = errors.New("Service Ticket 已被使用")
+	ErrSTServiceMismatch = errors.New("服务不匹配")
+	ErrTooManySessions   = errors.New("活跃会话数已达上限")
+)
+
+// 会话数达到上限时的处理策略
+const (
+	SessionLimitReject      = "reject"       // 拒绝新登录
+	SessionLimitEvictOldest = "evict_oldest" // 结束最早创建的会话
 )
 
 // SessionService 会话服务接口
@@ -47,6 +58,10 @@ type SessionServiceConfig struct {
 	SessionExpiry time.Duration // 会话有效期，默认 7 天
 	TGTExpiry     time.Duration // TGT 有效期，默认 8 小时
 	STExpiry      time.Duration // ST 有效期，默认 5 分钟
+	// MaxActiveSessions 每个用户的最大活跃会话数，0 表示不限制
+	MaxActiveSessions int
+	// SessionLimitPolicy 达到上限时的策略：reject 或 evict_oldest，默认 evict_oldest
+	SessionLimitPolicy string
 }
 
 type sessionService struct {
@@ -68,6 +83,14 @@ func NewSessionService(redisClient *redis.Client, config *SessionServiceConfig)
 	if config.STExpiry == 0 {
 		config.STExpiry = 5 * time.Minute // 默认 5 分钟
 	}
+	switch config.SessionLimitPolicy {
+	case SessionLimitReject, SessionLimitEvictOldest:
+	case "":
+		config.SessionLimitPolicy = SessionLimitEvictOldest
+	default:
+		log.Printf("未知的会话上限策略 %q，使用 %s", config.SessionLimitPolicy, SessionLimitEvictOldest)
+		config.SessionLimitPolicy = SessionLimitEvictOldest
+	}
 	return &sessionService{
 		redis:  redisClient,
 		config: config,
@@ -92,6 +115,10 @@ func (s *sessionService) Create(ctx context.Context, session *model.Session) err
 	}
 	session.CreatedAt = time.Now()
 
+	if err := s.enforceSessionLimit(ctx, session.UserID); err != nil {
+		return err
+	}
+
 	// 序列化会话数据
 	data, err := json.Marshal(session)
 	if err != nil {
@@ -109,6 +136,7 @@ func (s *sessionService) Create(ctx context.Context, session *model.Session) err
 	if err := s.redis.Set(ctx, key, data, ttl).Err(); err != nil {
 		return fmt.Errorf("存储会话失败: %w", err)
 	}
+	metrics.SessionCreated()
 
 	// 添加到用户会话列表
 	userKey := userSessionsPrefix + session.UserID
@@ -121,6 +149,36 @@ func (s *sessionService) Create(ctx context.Context, session *model.Session) err
 	return nil
 }
 
+// enforceSessionLimit 在创建新会话前检查用户的活跃会话数
+// 达到上限时按策略拒绝新会话或结束最早创建的会话
+func (s *sessionService) enforceSessionLimit(ctx context.Context, userID string) error {
+	if s.config.MaxActiveSessions <= 0 {
+		return nil
+	}
+
+	sessions, err := s.ListByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	excess := len(sessions) - s.config.MaxActiveSessions + 1
+	if excess <= 0 {
+		return nil
+	}
+	if s.config.SessionLimitPolicy == SessionLimitReject {
+		return ErrTooManySessions
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].CreatedAt.Before(sessions[j].CreatedAt)
+	})
+	for _, old := range sessions[:excess] {
+		if err := s.Delete(ctx, old.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Get 获取会话
 func (s *sessionService) Get(ctx context.Context, sessionID string) (*model.Session, error) {
 	key := sessionKeyPrefix + sessionID
@@ -139,7 +197,7 @@ func (s *sessionService) Get(ctx context.Context, sessionID string) (*model.Sess
 
 	if session.IsExpired() {
 		// 直接删除 key，避免递归调用
-		s.redis.Del(ctx, key)
+		metrics.SessionsDeleted(s.redis.Del(ctx, key).Val())
 		// 从用户会话列表中移除
 		userKey := userSessionsPrefix + session.UserID
 		s.redis.SRem(ctx, userKey, sessionID)
@@ -159,9 +217,11 @@ func (s *sessionService) Delete(ctx context.Context, sessionID string) error {
 
 	// 删除会话
 	key := sessionKeyPrefix + sessionID
-	if err := s.redis.Del(ctx, key).Err(); err != nil {
+	deleted, err := s.redis.Del(ctx, key).Result()
+	if err != nil {
 		return fmt.Errorf("删除会话失败: %w", err)
 	}
+	metrics.SessionsDeleted(deleted)
 
 	// 从用户会话列表中移除
 	if session != nil {
@@ -183,7 +243,7 @@ func (s *sessionService) DeleteByUserID(ctx context.Context, userID string) erro
 	// 删除所有会话
 	for _, sessionID := range sessionIDs {
 		key := sessionKeyPrefix + sessionID
-		s.redis.Del(ctx, key)
+		metrics.SessionsDeleted(s.redis.Del(ctx, key).Val())
 	}
 
 	// 删除用户会话列表
@@ -333,7 +393,7 @@ func (s *sessionService) ValidateST(ctx context.Context, ticket, service string)
 
 	// 检查服务是否匹配
 	if st.Service != service {
-		return nil, errors.New("服务不匹配")
+		return nil, ErrSTServiceMismatch
 	}
 
 	// 标记为已使用
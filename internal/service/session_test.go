@@ -268,3 +268,52 @@ func TestSessionService_ExpiredSession(t *testing.T) {
 	_, err = svc.Get(ctx, session.ID)
 	assert.ErrorIs(t, err, ErrSessionExpired)
 }
+
+func TestSessionService_MaxActiveSessions_Reject(t *testing.T) {
+	client, cleanup := setupTestRedis(t)
+	defer cleanup()
+
+	svc := NewSessionService(client, &SessionServiceConfig{
+		MaxActiveSessions:  2,
+		SessionLimitPolicy: SessionLimitReject,
+	})
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		require.NoError(t, svc.Create(ctx, &model.Session{UserID: "user-123"}))
+	}
+
+	err := svc.Create(ctx, &model.Session{UserID: "user-123"})
+	assert.ErrorIs(t, err, ErrTooManySessions)
+
+	sessions, err := svc.ListByUserID(ctx, "user-123")
+	require.NoError(t, err)
+	assert.Len(t, sessions, 2)
+
+	// 上限按用户计算
+	assert.NoError(t, svc.Create(ctx, &model.Session{UserID: "user-456"}))
+}
+
+func TestSessionService_MaxActiveSessions_EvictOldest(t *testing.T) {
+	client, cleanup := setupTestRedis(t)
+	defer cleanup()
+
+	svc := NewSessionService(client, &SessionServiceConfig{MaxActiveSessions: 2})
+	ctx := context.Background()
+
+	var created []*model.Session
+	for i := 0; i < 3; i++ {
+		session := &model.Session{UserID: "user-123"}
+		require.NoError(t, svc.Create(ctx, session))
+		created = append(created, session)
+	}
+
+	_, err := svc.Get(ctx, created[0].ID)
+	assert.ErrorIs(t, err, ErrSessionNotFound)
+
+	sessions, err := svc.ListByUserID(ctx, "user-123")
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	ids := []string{sessions[0].ID, sessions[1].ID}
+	assert.ElementsMatch(t, []string{created[1].ID, created[2].ID}, ids)
+}
@@ -3,14 +3,21 @@ package service
 
 import (
 	"context"
+	"crypto"
+	"crypto/ecdsa"
 	"crypto/rand"
-	"crypto/rsa"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/pu-ac-cn/uac-backend/internal/metrics"
+	"github.com/pu-ac-cn/uac-backend/internal/model"
+	"github.com/redis/go-redis/v9"
 )
 
 // 令牌相关错误
@@ -27,14 +34,55 @@ var (
 // TokenClaims JWT 声明
 type TokenClaims struct {
 	jwt.RegisteredClaims
-	UserID   string   `json:"uid,omitempty"`
-	Username string   `json:"username,omitempty"`
-	Email    string   `json:"email,omitempty"`
-	OrgID    string   `json:"org_id,omitempty"`
-	AppID    string   `json:"app_id,omitempty"`
-	ClientID string   `json:"client_id,omitempty"`
-	Scopes   []string `json:"scopes,omitempty"`
-	Type     string   `json:"type,omitempty"` // access, refresh, id
+	UserID    string   `json:"uid,omitempty"`
+	Username  string   `json:"username,omitempty"`
+	Email     string   `json:"email,omitempty"`
+	OrgID     string   `json:"org_id,omitempty"`
+	AppID     string   `json:"app_id,omitempty"`
+	ClientID  string   `json:"client_id,omitempty"`
+	Scopes    []string `json:"scopes,omitempty"`
+	SessionID string   `json:"sid,omitempty"`       // 登录会话 ID
+	FamilyID  string   `json:"fid,omitempty"`       // 令牌族 ID，同一次授权轮换出的令牌共享
+	Nonce     string   `json:"nonce,omitempty"`     // OIDC nonce，仅写入 ID 令牌
+	AuthTime  int64    `json:"auth_time,omitempty"` // 用户实际认证时间（Unix 秒），仅写入 ID 令牌
+	Type      string   `json:"type,omitempty"`      // access, refresh, id
+	// AuthorizedParty 令牌签发给的客户端 client_id，仅写入 ID 令牌
+	AuthorizedParty string `json:"azp,omitempty"`
+	// Roles / Permissions 服务账号应用的角色代码和权限代码，仅写入客户端凭证令牌
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"perms,omitempty"`
+}
+
+// IsServiceAccount 是否为服务账号令牌（客户端凭证授权签发，没有用户上下文）
+func (c *TokenClaims) IsServiceAccount() bool {
+	return c.UserID == "" && c.ClientID != ""
+}
+
+// HasRole 令牌是否携带指定角色
+func (c *TokenClaims) HasRole(roleCode string) bool {
+	return containsString(c.Roles, roleCode)
+}
+
+// HasPermission 令牌是否携带指定权限，规则与 RBACService.CheckPermission 一致
+func (c *TokenClaims) HasPermission(resource, action string) bool {
+	if c.HasRole(model.RoleSuperAdmin) {
+		return true
+	}
+	return containsString(c.Permissions, model.BuildPermissionCode(resource, action)) ||
+		containsString(c.Permissions, model.BuildPermissionCode(resource, model.ActionAll))
+}
+
+// TokenInspection 令牌解析结果
+type TokenInspection struct {
+	KeyID          string                 `json:"kid"`
+	Algorithm      string                 `json:"alg"`
+	Claims         map[string]interface{} `json:"claims"`
+	SignatureValid bool                   `json:"signature_valid"`
+	ExpiresAt      *time.Time             `json:"expires_at,omitempty"`
+	Expired        bool                   `json:"expired"`
+	Revoked        bool                   `json:"revoked"`
+	Valid          bool                   `json:"valid"`
+	Reasons        []string               `json:"reasons"` // 令牌无效的原因，有效时为空
 }
 
 // AuthorizationCode 授权码
@@ -42,10 +90,15 @@ type AuthorizationCode struct {
 	Code                string    `json:"code"`
 	ClientID            string    `json:"client_id"`
 	UserID              string    `json:"user_id"`
+	Username            string    `json:"username,omitempty"`
+	Email               string    `json:"email,omitempty"`
 	RedirectURI         string    `json:"redirect_uri"`
 	Scopes              []string  `json:"scopes"`
 	CodeChallenge       string    `json:"code_challenge,omitempty"`
 	CodeChallengeMethod string    `json:"code_challenge_method,omitempty"`
+	Nonce               string    `json:"nonce,omitempty"`
+	AuthTime            time.Time `json:"auth_time,omitempty"`
+	SessionID           string    `json:"session_id,omitempty"`
 	ExpiresAt           time.Time `json:"expires_at"`
 	Used                bool      `json:"used"`
 }
@@ -54,95 +107,235 @@ type AuthorizationCode struct {
 type TokenService interface {
 	// GenerateAccessToken 生成访问令牌
 	GenerateAccessToken(ctx context.Context, claims *TokenClaims) (string, error)
+	// GenerateAccessTokenWithTTL 按指定有效期生成访问令牌，ttl <= 0 时使用默认有效期
+	// 有效期不超过全局访问令牌和刷新令牌有效期中的较大者，与撤销记录的保留时间一致
+	GenerateAccessTokenWithTTL(ctx context.Context, claims *TokenClaims, ttl time.Duration) (string, error)
 	// GenerateRefreshToken 生成刷新令牌
 	GenerateRefreshToken(ctx context.Context, claims *TokenClaims) (string, error)
+	// GenerateRefreshTokenWithTTL 按指定有效期生成刷新令牌，ttl <= 0 时使用默认有效期
+	GenerateRefreshTokenWithTTL(ctx context.Context, claims *TokenClaims, ttl time.Duration) (string, error)
 	// GenerateIDToken 生成 ID 令牌
 	GenerateIDToken(ctx context.Context, claims *TokenClaims) (string, error)
 	// ValidateToken 验证令牌
+	// 已轮换（撤销）的刷新令牌被再次提交时返回其声明和 ErrRefreshTokenUsed，调用方应撤销整个令牌族
 	ValidateToken(ctx context.Context, tokenString string) (*TokenClaims, error)
+	// ValidateIDTokenHint 验证作为 id_token_hint 提交的 ID 令牌（不检查过期）
+	ValidateIDTokenHint(ctx context.Context, tokenString string) (*TokenClaims, error)
 	// GenerateAuthorizationCode 生成授权码
 	GenerateAuthorizationCode(ctx context.Context, code *AuthorizationCode) (string, error)
 	// ValidateAuthorizationCode 验证授权码
 	ValidateAuthorizationCode(ctx context.Context, code string) (*AuthorizationCode, error)
-	// RevokeToken 撤销令牌
+	// RevokeToken 撤销令牌，签名无效的令牌无需撤销，直接忽略
 	RevokeToken(ctx context.Context, tokenString string) error
-	// GetPublicKey 获取公钥（用于 JWKS）
-	GetPublicKey() *rsa.PublicKey
+	// RotateRefreshToken 原子地将刷新令牌标记为已轮换，已被轮换过时返回 ErrRefreshTokenUsed
+	// 并发提交同一刷新令牌时只有一个请求成功，调用方应在签发新令牌前调用
+	RotateRefreshToken(ctx context.Context, claims *TokenClaims) error
+	// RevokeUserTokens 撤销用户在此之前签发的所有令牌
+	RevokeUserTokens(ctx context.Context, userID string) error
+	// RevokeUserClientTokens 撤销在此之前签发给指定客户端的该用户令牌，用于用户撤销对应用的授权
+	RevokeUserClientTokens(ctx context.Context, userID, clientID string) error
+	// RevokeTokenFamily 撤销令牌族下的所有令牌
+	RevokeTokenFamily(ctx context.Context, familyID string) error
+	// InspectToken 解析令牌并给出签名、过期、撤销等各项检查结果，仅供管理员排查问题
+	// 令牌格式无法解析时返回 ErrInvalidToken
+	InspectToken(ctx context.Context, tokenString string) (*TokenInspection, error)
+	// GetPublicKey 获取公钥（用于 JWKS），类型为 *rsa.PublicKey 或 *ecdsa.PublicKey
+	GetPublicKey() crypto.PublicKey
 	// GetKeyID 获取密钥 ID
 	GetKeyID() string
+	// SigningAlgorithm 令牌签名算法（RS256 或 ES256）
+	SigningAlgorithm() string
+	// AccessTokenTTL 访问令牌有效期，用于响应中的 expires_in
+	AccessTokenTTL() time.Duration
 }
 
 // tokenService 令牌服务实现
 type tokenService struct {
-	privateKey    *rsa.PrivateKey
-	publicKey     *rsa.PublicKey
+	privateKey    crypto.Signer
+	publicKey     crypto.PublicKey
+	signingMethod jwt.SigningMethod
 	keyID         string
 	issuer        string
 	accessExpiry  time.Duration
 	refreshExpiry time.Duration
 	codeExpiry    time.Duration
-	// 存储授权码和已撤销令牌（生产环境应使用 Redis）
-	codes         map[string]*AuthorizationCode
+	leeway        time.Duration
+	// 允许写入令牌的用户声明，为空表示不限制
+	accessTokenClaims []string
+	idTokenClaims     []string
+	// redis 存储撤销记录，多实例共享且重启后不丢失；未配置时使用下方的内存存储
+	redis *redis.Client
+	// mu 保护以下内存存储
+	mu sync.RWMutex
+	// 存储授权码
+	codes map[string]*AuthorizationCode
+	// 已撤销令牌的 jti 及其过期时间，仅在未配置 Redis 时使用，写入时清理已过期的记录
 	revokedTokens map[string]time.Time
+	// 用户撤销全部令牌的时间，仅在未配置 Redis 时使用
+	userRevokedAt map[string]time.Time
+	// 用户撤销应用授权的时间，key 为 userClientKey，仅在未配置 Redis 时使用
+	userClientRevokedAt map[string]time.Time
+	// 已撤销的令牌族，仅在未配置 Redis 时使用
+	revokedFamilies map[string]time.Time
 }
 
+// Redis key 前缀
+const (
+	// revokedTokenPrefix 后接令牌的 jti
+	revokedTokenPrefix  = "revoked_token:"
+	revokedFamilyPrefix = "revoked_family:"
+	userRevokedAtPrefix = "user_revoked_at:"
+	// userClientRevokedAtPrefix 后接 userClientKey
+	userClientRevokedAtPrefix = "user_client_revoked_at:"
+)
+
+// 支持的令牌签名算法
+const (
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+)
+
 // TokenServiceConfig 令牌服务配置
 type TokenServiceConfig struct {
-	PrivateKey    *rsa.PrivateKey
-	PublicKey     *rsa.PublicKey
+	// Algorithm 签名算法 RS256 或 ES256，为空时按私钥类型推断
+	Algorithm string
+	// PrivateKey 签名私钥，RS256 为 *rsa.PrivateKey，ES256 为 *ecdsa.PrivateKey
+	PrivateKey    crypto.Signer
+	PublicKey     crypto.PublicKey
 	KeyID         string
 	Issuer        string
 	AccessExpiry  time.Duration
 	RefreshExpiry time.Duration
 	CodeExpiry    time.Duration
+	// Leeway 校验 exp/iat/nbf 时容忍的时钟偏移，默认 30 秒
+	Leeway time.Duration
+	// AccessTokenClaims 允许写入访问令牌的用户声明（uid、username、email、org_id）
+	// 为空时保留全部；仅配置 sub 可使访问令牌不携带任何个人信息
+	AccessTokenClaims []string
+	// IDTokenClaims 允许写入 ID 令牌的用户声明，为空时保留全部
+	IDTokenClaims []string
+	// Redis 存储令牌撤销记录，为空时使用进程内存（仅适用于单实例和测试）
+	Redis *redis.Client
 }
 
+// 用户声明名称
+const (
+	ClaimUserID   = "uid"
+	ClaimUsername = "username"
+	ClaimEmail    = "email"
+	ClaimOrgID    = "org_id"
+)
+
 // NewTokenService 创建令牌服务
 func NewTokenService(cfg *TokenServiceConfig) TokenService {
+	leeway := cfg.Leeway
+	if leeway == 0 {
+		leeway = DefaultLeeway
+	}
 	return &tokenService{
-		privateKey:    cfg.PrivateKey,
-		publicKey:     cfg.PublicKey,
-		keyID:         cfg.KeyID,
-		issuer:        cfg.Issuer,
-		accessExpiry:  cfg.AccessExpiry,
-		refreshExpiry: cfg.RefreshExpiry,
-		codeExpiry:    cfg.CodeExpiry,
-		codes:         make(map[string]*AuthorizationCode),
-		revokedTokens: make(map[string]time.Time),
+		privateKey:          cfg.PrivateKey,
+		publicKey:           cfg.PublicKey,
+		signingMethod:       signingMethodFor(cfg.Algorithm, cfg.PrivateKey),
+		keyID:               cfg.KeyID,
+		issuer:              NormalizeIssuer(cfg.Issuer),
+		accessExpiry:        cfg.AccessExpiry,
+		refreshExpiry:       cfg.RefreshExpiry,
+		codeExpiry:          cfg.CodeExpiry,
+		leeway:              leeway,
+		accessTokenClaims:   cfg.AccessTokenClaims,
+		idTokenClaims:       cfg.IDTokenClaims,
+		redis:               cfg.Redis,
+		codes:               make(map[string]*AuthorizationCode),
+		revokedTokens:       make(map[string]time.Time),
+		userRevokedAt:       make(map[string]time.Time),
+		userClientRevokedAt: make(map[string]time.Time),
+		revokedFamilies:     make(map[string]time.Time),
 	}
 }
 
+// signingMethodFor 返回签名算法对应的 JWT 签名方法，未配置时按私钥类型推断
+func signingMethodFor(alg string, key crypto.Signer) jwt.SigningMethod {
+	switch alg {
+	case AlgES256:
+		return jwt.SigningMethodES256
+	case AlgRS256:
+		return jwt.SigningMethodRS256
+	}
+	if _, ok := key.(*ecdsa.PrivateKey); ok {
+		return jwt.SigningMethodES256
+	}
+	return jwt.SigningMethodRS256
+}
+
+// parse 解析并验证令牌签名
+// 解析器通过 WithValidMethods 只允许配置的签名算法，回调中再次确认 alg，
+// 防止以 none 或以公钥作为 HMAC 密钥伪造令牌的算法混淆攻击
+func (s *tokenService) parse(tokenString string, claims jwt.Claims, opts ...jwt.ParserOption) (*jwt.Token, error) {
+	opts = append([]jwt.ParserOption{jwt.WithValidMethods([]string{s.signingMethod.Alg()})}, opts...)
+	return jwt.ParseWithClaims(tokenString, claims, s.verificationKey, opts...)
+}
+
+// verificationKey 按令牌头中的 alg 选择验证公钥，只接受当前配置的签名算法
+func (s *tokenService) verificationKey(token *jwt.Token) (interface{}, error) {
+	if token.Method == jwt.SigningMethodNone || token.Method.Alg() != s.signingMethod.Alg() {
+		return nil, ErrInvalidSignature
+	}
+	return s.publicKey, nil
+}
+
 // GenerateAccessToken 生成访问令牌
 func (s *tokenService) GenerateAccessToken(ctx context.Context, claims *TokenClaims) (string, error) {
+	return s.GenerateAccessTokenWithTTL(ctx, claims, 0)
+}
+
+// GenerateAccessTokenWithTTL 按指定有效期生成访问令牌
+func (s *tokenService) GenerateAccessTokenWithTTL(ctx context.Context, claims *TokenClaims, ttl time.Duration) (string, error) {
+	if ttl <= 0 {
+		ttl = s.accessExpiry
+	}
+	ttl = s.capTTL(ttl)
 	now := time.Now()
 	claims.Type = "access"
 	claims.RegisteredClaims = jwt.RegisteredClaims{
 		Issuer:    s.issuer,
 		Subject:   claims.UserID,
 		IssuedAt:  jwt.NewNumericDate(now),
-		ExpiresAt: jwt.NewNumericDate(now.Add(s.accessExpiry)),
+		ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
 		ID:        generateTokenID(),
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token := jwt.NewWithClaims(s.signingMethod, filterUserClaims(claims, s.accessTokenClaims))
 	token.Header["kid"] = s.keyID
 
 	return token.SignedString(s.privateKey)
 }
 
 // GenerateRefreshToken 生成刷新令牌
+// 未指定令牌族时开启新的令牌族
 func (s *tokenService) GenerateRefreshToken(ctx context.Context, claims *TokenClaims) (string, error) {
+	return s.GenerateRefreshTokenWithTTL(ctx, claims, 0)
+}
+
+// GenerateRefreshTokenWithTTL 按指定有效期生成刷新令牌
+func (s *tokenService) GenerateRefreshTokenWithTTL(ctx context.Context, claims *TokenClaims, ttl time.Duration) (string, error) {
+	if ttl <= 0 {
+		ttl = s.refreshExpiry
+	}
+	ttl = s.capTTL(ttl)
 	now := time.Now()
 	claims.Type = "refresh"
+	if claims.FamilyID == "" {
+		claims.FamilyID = NewTokenFamilyID()
+	}
 	claims.RegisteredClaims = jwt.RegisteredClaims{
 		Issuer:    s.issuer,
 		Subject:   claims.UserID,
 		IssuedAt:  jwt.NewNumericDate(now),
-		ExpiresAt: jwt.NewNumericDate(now.Add(s.refreshExpiry)),
+		ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
 		ID:        generateTokenID(),
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token := jwt.NewWithClaims(s.signingMethod, claims)
 	token.Header["kid"] = s.keyID
 
 	return token.SignedString(s.privateKey)
@@ -152,16 +345,31 @@ func (s *tokenService) GenerateRefreshToken(ctx context.Context, claims *TokenCl
 func (s *tokenService) GenerateIDToken(ctx context.Context, claims *TokenClaims) (string, error) {
 	now := time.Now()
 	claims.Type = "id"
+	// aud 和 azp 均为请求方的 client_id，未设置 AppID 时使用 ClientID
+	clientID := claims.AppID
+	if clientID == "" {
+		clientID = claims.ClientID
+	}
+	claims.AuthorizedParty = clientID
 	claims.RegisteredClaims = jwt.RegisteredClaims{
 		Issuer:    s.issuer,
 		Subject:   claims.UserID,
-		Audience:  jwt.ClaimStrings{claims.AppID},
+		Audience:  jwt.ClaimStrings{clientID},
 		IssuedAt:  jwt.NewNumericDate(now),
 		ExpiresAt: jwt.NewNumericDate(now.Add(s.accessExpiry)),
 		ID:        generateTokenID(),
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	// 个人信息声明按 scope 授予：username 需要 profile，email 需要 email
+	idClaims := filterUserClaims(claims, s.idTokenClaims)
+	if !containsString(claims.Scopes, "profile") {
+		idClaims.Username = ""
+	}
+	if !containsString(claims.Scopes, "email") {
+		idClaims.Email = ""
+	}
+
+	token := jwt.NewWithClaims(s.signingMethod, idClaims)
 	token.Header["kid"] = s.keyID
 
 	return token.SignedString(s.privateKey)
@@ -169,51 +377,388 @@ func (s *tokenService) GenerateIDToken(ctx context.Context, claims *TokenClaims)
 
 // ValidateToken 验证令牌
 func (s *tokenService) ValidateToken(ctx context.Context, tokenString string) (*TokenClaims, error) {
-	// 检查是否已撤销
-	if _, revoked := s.revokedTokens[tokenString]; revoked {
-		return nil, ErrInvalidToken
-	}
+	claims, result, err := s.validateToken(ctx, tokenString)
+	metrics.TokenValidation(result)
+	return claims, err
+}
 
-	token, err := jwt.ParseWithClaims(tokenString, &TokenClaims{}, func(token *jwt.Token) (interface{}, error) {
-		// 验证签名算法
-		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
-			return nil, ErrInvalidSignature
-		}
-		return s.publicKey, nil
-	})
+// validateToken 验证令牌，同时返回用于监控指标的校验结果
+func (s *tokenService) validateToken(ctx context.Context, tokenString string) (*TokenClaims, string, error) {
+	token, err := s.parse(tokenString, &TokenClaims{}, jwt.WithLeeway(s.leeway), jwt.WithIssuedAt())
 
 	if err != nil {
 		if errors.Is(err, jwt.ErrTokenExpired) {
-			return nil, ErrTokenExpired
+			return nil, metrics.ValidationExpired, ErrTokenExpired
 		}
-		return nil, ErrInvalidToken
+		return nil, metrics.ValidationInvalid, ErrInvalidToken
 	}
 
 	claims, ok := token.Claims.(*TokenClaims)
 	if !ok || !token.Valid {
-		return nil, ErrInvalidToken
+		return nil, metrics.ValidationInvalid, ErrInvalidToken
 	}
 
 	// 验证签发者
 	if claims.Issuer != s.issuer {
-		return nil, ErrInvalidIssuer
+		return nil, metrics.ValidationInvalid, ErrInvalidIssuer
 	}
 
+	// 精简声明的令牌不含 uid，以 sub 为准
+	if claims.UserID == "" {
+		claims.UserID = claims.Subject
+	}
+
+	// 检查是否已撤销，已轮换的刷新令牌再次出现说明令牌可能被盗用
+	tokenRevoked, err := s.isTokenRevoked(ctx, claims.ID)
+	if err != nil {
+		return nil, metrics.ValidationError, err
+	}
+	if tokenRevoked {
+		if claims.Type == "refresh" && claims.FamilyID != "" {
+			return claims, metrics.ValidationRevoked, ErrRefreshTokenUsed
+		}
+		return nil, metrics.ValidationRevoked, ErrInvalidToken
+	}
+
+	// 检查令牌族撤销
+	familyRevoked, err := s.isFamilyRevoked(ctx, claims.FamilyID)
+	if err != nil {
+		return nil, metrics.ValidationError, err
+	}
+	if familyRevoked {
+		return nil, metrics.ValidationRevoked, ErrInvalidToken
+	}
+
+	// 检查用户级撤销
+	userRevoked, err := s.revokedForUser(ctx, claims.UserID, claims)
+	if err != nil {
+		return nil, metrics.ValidationError, err
+	}
+	if userRevoked {
+		return nil, metrics.ValidationRevoked, ErrInvalidToken
+	}
+
+	// 检查用户对应用授权的撤销
+	clientRevoked, err := s.revokedForClient(ctx, claims.UserID, claims)
+	if err != nil {
+		return nil, metrics.ValidationError, err
+	}
+	if clientRevoked {
+		return nil, metrics.ValidationRevoked, ErrInvalidToken
+	}
+
+	return claims, metrics.ValidationValid, nil
+}
+
+// ValidateIDTokenHint 验证 id_token_hint
+// 仅校验签名、签发者与令牌类型；按 OIDC 规范，已过期的 ID 令牌仍可用于注销
+func (s *tokenService) ValidateIDTokenHint(ctx context.Context, tokenString string) (*TokenClaims, error) {
+	token, err := s.parse(tokenString, &TokenClaims{}, jwt.WithoutClaimsValidation())
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	claims, ok := token.Claims.(*TokenClaims)
+	if !ok || claims.Type != "id" {
+		return nil, ErrInvalidToken
+	}
+	if claims.Issuer != s.issuer {
+		return nil, ErrInvalidIssuer
+	}
+	if claims.UserID == "" {
+		claims.UserID = claims.Subject
+	}
 	return claims, nil
 }
 
+// InspectToken 解析令牌
+// 与 ValidateToken 的检查项一致，但不在首个失败处中止，而是汇总所有无效原因
+func (s *tokenService) InspectToken(ctx context.Context, tokenString string) (*TokenInspection, error) {
+	parser := jwt.NewParser()
+	rawClaims := jwt.MapClaims{}
+	unverified, _, err := parser.ParseUnverified(tokenString, rawClaims)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	claims := &TokenClaims{}
+	if _, _, err := parser.ParseUnverified(tokenString, claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	result := &TokenInspection{
+		Algorithm: unverified.Method.Alg(),
+		Claims:    rawClaims,
+		Reasons:   []string{},
+	}
+	result.KeyID, _ = unverified.Header["kid"].(string)
+
+	// 签名
+	_, err = s.parse(tokenString, jwt.MapClaims{}, jwt.WithoutClaimsValidation())
+	result.SignatureValid = err == nil
+	if !result.SignatureValid {
+		result.Reasons = append(result.Reasons, ErrInvalidSignature.Error())
+	}
+	if result.KeyID != s.keyID {
+		result.Reasons = append(result.Reasons, fmt.Sprintf("密钥 ID %q 与当前签名密钥 %q 不一致", result.KeyID, s.keyID))
+	}
+
+	// 时间
+	now := time.Now()
+	if claims.ExpiresAt == nil {
+		result.Reasons = append(result.Reasons, "缺少过期时间")
+	} else {
+		expiresAt := claims.ExpiresAt.Time
+		result.ExpiresAt = &expiresAt
+		if now.After(expiresAt.Add(s.leeway)) {
+			result.Expired = true
+			result.Reasons = append(result.Reasons, fmt.Sprintf("%s（过期时间 %s）", ErrTokenExpired.Error(), expiresAt.Format(time.RFC3339)))
+		}
+	}
+	if claims.NotBefore != nil && now.Add(s.leeway).Before(claims.NotBefore.Time) {
+		result.Reasons = append(result.Reasons, "令牌尚未生效")
+	}
+	if claims.IssuedAt != nil && now.Add(s.leeway).Before(claims.IssuedAt.Time) {
+		result.Reasons = append(result.Reasons, "签发时间晚于当前时间")
+	}
+
+	if claims.Issuer != s.issuer {
+		result.Reasons = append(result.Reasons, fmt.Sprintf("%s: %q", ErrInvalidIssuer.Error(), claims.Issuer))
+	}
+
+	// 撤销
+	reason, err := s.revocationReason(ctx, tokenString, claims)
+	if err != nil {
+		return nil, err
+	}
+	if reason != "" {
+		result.Revoked = true
+		result.Reasons = append(result.Reasons, reason)
+	}
+
+	result.Valid = len(result.Reasons) == 0
+	return result, nil
+}
+
+// revocationReason 返回令牌被撤销的原因，未撤销时返回空字符串
+func (s *tokenService) revocationReason(ctx context.Context, tokenString string, claims *TokenClaims) (string, error) {
+	tokenRevoked, err := s.isTokenRevoked(ctx, claims.ID)
+	if err != nil {
+		return "", err
+	}
+	if tokenRevoked {
+		if claims.Type == "refresh" && claims.FamilyID != "" {
+			return "刷新令牌已被轮换使用", nil
+		}
+		return "令牌已被撤销", nil
+	}
+	familyRevoked, err := s.isFamilyRevoked(ctx, claims.FamilyID)
+	if err != nil {
+		return "", err
+	}
+	if familyRevoked {
+		return "令牌所属的令牌族已被撤销", nil
+	}
+	userID := claims.UserID
+	if userID == "" {
+		userID = claims.Subject
+	}
+	userRevoked, err := s.revokedForUser(ctx, userID, claims)
+	if err != nil {
+		return "", err
+	}
+	if userRevoked {
+		return "用户在签发后撤销了全部令牌", nil
+	}
+	clientRevoked, err := s.revokedForClient(ctx, userID, claims)
+	if err != nil {
+		return "", err
+	}
+	if clientRevoked {
+		return "用户已撤销对该应用的授权", nil
+	}
+	return "", nil
+}
+
+// isTokenRevoked 令牌本身是否已被撤销
+func (s *tokenService) isTokenRevoked(ctx context.Context, tokenID string) (bool, error) {
+	if tokenID == "" {
+		return false, nil
+	}
+	if s.redis != nil {
+		n, err := s.redis.Exists(ctx, revokedTokenPrefix+tokenID).Result()
+		if err != nil {
+			return false, fmt.Errorf("查询令牌撤销状态失败: %w", err)
+		}
+		return n > 0, nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, revoked := s.revokedTokens[tokenID]
+	return revoked, nil
+}
+
+// markTokenRevoked 按 jti 记录令牌撤销，保留到令牌过期为止
+// onlyIfNew 为 true 时仅在尚未撤销时写入，返回是否由本次调用写入
+func (s *tokenService) markTokenRevoked(ctx context.Context, claims *TokenClaims, onlyIfNew bool) (bool, error) {
+	if claims.ID == "" {
+		return false, ErrInvalidToken
+	}
+	ttl := s.revocationTTL()
+	if claims.ExpiresAt != nil {
+		ttl = time.Until(claims.ExpiresAt.Time) + s.leeway
+		if ttl <= 0 {
+			// 已过期的令牌无法通过校验，无需记录
+			return true, nil
+		}
+	}
+
+	if s.redis != nil {
+		key := revokedTokenPrefix + claims.ID
+		if onlyIfNew {
+			ok, err := s.redis.SetNX(ctx, key, time.Now().Unix(), ttl).Result()
+			if err != nil {
+				return false, fmt.Errorf("记录令牌撤销失败: %w", err)
+			}
+			return ok, nil
+		}
+		if err := s.redis.Set(ctx, key, time.Now().Unix(), ttl).Err(); err != nil {
+			return false, fmt.Errorf("记录令牌撤销失败: %w", err)
+		}
+		return true, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for id, expiresAt := range s.revokedTokens {
+		if !expiresAt.IsZero() && now.After(expiresAt) {
+			delete(s.revokedTokens, id)
+		}
+	}
+	if _, exists := s.revokedTokens[claims.ID]; exists && onlyIfNew {
+		return false, nil
+	}
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = now.Add(ttl)
+	}
+	s.revokedTokens[claims.ID] = expiresAt
+	return true, nil
+}
+
+// isFamilyRevoked 令牌族是否已被撤销
+func (s *tokenService) isFamilyRevoked(ctx context.Context, familyID string) (bool, error) {
+	if familyID == "" {
+		return false, nil
+	}
+	if s.redis != nil {
+		n, err := s.redis.Exists(ctx, revokedFamilyPrefix+familyID).Result()
+		if err != nil {
+			return false, fmt.Errorf("查询令牌族撤销状态失败: %w", err)
+		}
+		return n > 0, nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, revoked := s.revokedFamilies[familyID]
+	return revoked, nil
+}
+
+// revokedForUser 令牌是否在用户撤销全部令牌之前签发
+func (s *tokenService) revokedForUser(ctx context.Context, userID string, claims *TokenClaims) (bool, error) {
+	if userID == "" {
+		return false, nil
+	}
+	revokedAt, ok, err := s.revokedAt(ctx, userRevokedAtPrefix+userID, s.userRevokedAt, userID)
+	if err != nil || !ok {
+		return false, err
+	}
+	return claims.IssuedAt == nil || claims.IssuedAt.Time.Before(revokedAt), nil
+}
+
+// revokedAt 读取撤销时间，配置了 Redis 时读取 redisKey，否则读取内存中 memory[memoryKey]
+func (s *tokenService) revokedAt(ctx context.Context, redisKey string, memory map[string]time.Time, memoryKey string) (time.Time, bool, error) {
+	if s.redis != nil {
+		unix, err := s.redis.Get(ctx, redisKey).Int64()
+		if errors.Is(err, redis.Nil) {
+			return time.Time{}, false, nil
+		}
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("查询令牌撤销状态失败: %w", err)
+		}
+		return time.Unix(unix, 0), true, nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	revokedAt, ok := memory[memoryKey]
+	return revokedAt, ok, nil
+}
+
+// setRevokedAt 记录撤销时间，配置了 Redis 时写入 redisKey，否则写入内存中 memory[memoryKey]
+func (s *tokenService) setRevokedAt(ctx context.Context, redisKey string, memory map[string]time.Time, memoryKey string, revokedAt time.Time) error {
+	if s.redis != nil {
+		if err := s.redis.Set(ctx, redisKey, revokedAt.Unix(), s.revocationTTL()).Err(); err != nil {
+			return fmt.Errorf("记录令牌撤销失败: %w", err)
+		}
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	memory[memoryKey] = revokedAt
+	return nil
+}
+
+// revocationTTL 撤销记录的保留时间，覆盖令牌的最长有效期，0 表示不过期
+func (s *tokenService) revocationTTL() time.Duration {
+	if s.accessExpiry > s.refreshExpiry {
+		return s.accessExpiry
+	}
+	return s.refreshExpiry
+}
+
+// capTTL 令牌有效期不超过撤销记录的保留时间，否则令牌会在撤销记录过期后重新生效
+// 应用配置的有效期已在保存时校验，此处兜底处理历史数据
+func (s *tokenService) capTTL(ttl time.Duration) time.Duration {
+	if limit := s.revocationTTL(); limit > 0 && ttl > limit {
+		return limit
+	}
+	return ttl
+}
+
+// revokedForClient 令牌是否在用户撤销对其客户端的授权之前签发
+func (s *tokenService) revokedForClient(ctx context.Context, userID string, claims *TokenClaims) (bool, error) {
+	if userID == "" || claims.ClientID == "" {
+		return false, nil
+	}
+	key := userClientKey(userID, claims.ClientID)
+	revokedAt, ok, err := s.revokedAt(ctx, userClientRevokedAtPrefix+key, s.userClientRevokedAt, key)
+	if err != nil || !ok {
+		return false, err
+	}
+	return claims.IssuedAt == nil || claims.IssuedAt.Time.Before(revokedAt), nil
+}
+
+// userClientKey 用户撤销应用授权记录的 key
+func userClientKey(userID, clientID string) string {
+	return userID + "/" + clientID
+}
+
 // GenerateAuthorizationCode 生成授权码
 func (s *tokenService) GenerateAuthorizationCode(ctx context.Context, code *AuthorizationCode) (string, error) {
 	codeStr := generateSecureCode(32)
 	code.Code = codeStr
 	code.ExpiresAt = time.Now().Add(s.codeExpiry)
 	code.Used = false
+	s.mu.Lock()
 	s.codes[codeStr] = code
+	s.mu.Unlock()
 	return codeStr, nil
 }
 
 // ValidateAuthorizationCode 验证授权码
 func (s *tokenService) ValidateAuthorizationCode(ctx context.Context, codeStr string) (*AuthorizationCode, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	code, exists := s.codes[codeStr]
 	if !exists {
 		return nil, ErrInvalidToken
@@ -235,26 +780,127 @@ func (s *tokenService) ValidateAuthorizationCode(ctx context.Context, codeStr st
 }
 
 // RevokeToken 撤销令牌
+// 按 jti 记录，只接受本服务签发的令牌，伪造的令牌无法借此撤销他人的令牌
 func (s *tokenService) RevokeToken(ctx context.Context, tokenString string) error {
-	s.revokedTokens[tokenString] = time.Now()
+	claims := &TokenClaims{}
+	if _, err := s.parse(tokenString, claims, jwt.WithoutClaimsValidation()); err != nil {
+		return nil
+	}
+	_, err := s.markTokenRevoked(ctx, claims, false)
+	return err
+}
+
+// RotateRefreshToken 将刷新令牌标记为已轮换
+// 配置 Redis 时使用 SETNX，多实例并发提交同一刷新令牌时只有一个请求成功
+func (s *tokenService) RotateRefreshToken(ctx context.Context, claims *TokenClaims) error {
+	ok, err := s.markTokenRevoked(ctx, claims, true)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrRefreshTokenUsed
+	}
+	return nil
+}
+
+// RevokeUserTokens 撤销用户在此之前签发的所有令牌
+// 签发时间精度为秒，因此以当前秒为界
+func (s *tokenService) RevokeUserTokens(ctx context.Context, userID string) error {
+	return s.setRevokedAt(ctx, userRevokedAtPrefix+userID, s.userRevokedAt, userID, time.Now().Truncate(time.Second))
+}
+
+// RevokeUserClientTokens 撤销在此之前签发给指定客户端的该用户令牌
+// 与 RevokeUserTokens 相同以当前秒为界
+func (s *tokenService) RevokeUserClientTokens(ctx context.Context, userID, clientID string) error {
+	key := userClientKey(userID, clientID)
+	return s.setRevokedAt(ctx, userClientRevokedAtPrefix+key, s.userClientRevokedAt, key, time.Now().Truncate(time.Second))
+}
+
+// RevokeTokenFamily 撤销令牌族下的所有令牌
+// 撤销记录保留到该族的令牌全部过期为止
+func (s *tokenService) RevokeTokenFamily(ctx context.Context, familyID string) error {
+	if familyID == "" {
+		return nil
+	}
+	if s.redis != nil {
+		if err := s.redis.Set(ctx, revokedFamilyPrefix+familyID, time.Now().Unix(), s.revocationTTL()).Err(); err != nil {
+			return fmt.Errorf("撤销令牌族失败: %w", err)
+		}
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.revokedFamilies[familyID] = time.Now()
 	return nil
 }
 
 // GetPublicKey 获取公钥
-func (s *tokenService) GetPublicKey() *rsa.PublicKey {
+func (s *tokenService) GetPublicKey() crypto.PublicKey {
 	return s.publicKey
 }
 
+// AccessTokenTTL 获取访问令牌有效期
+func (s *tokenService) AccessTokenTTL() time.Duration {
+	return s.accessExpiry
+}
+
+// SigningAlgorithm 令牌签名算法
+func (s *tokenService) SigningAlgorithm() string {
+	return s.signingMethod.Alg()
+}
+
 // GetKeyID 获取密钥 ID
 func (s *tokenService) GetKeyID() string {
 	return s.keyID
 }
 
+// filterUserClaims 返回仅保留允许的用户声明的副本，allowed 为空时不做过滤
+func filterUserClaims(claims *TokenClaims, allowed []string) *TokenClaims {
+	filtered := *claims
+	if len(allowed) == 0 {
+		return &filtered
+	}
+	if !containsString(allowed, ClaimUserID) {
+		filtered.UserID = ""
+	}
+	if !containsString(allowed, ClaimUsername) {
+		filtered.Username = ""
+	}
+	if !containsString(allowed, ClaimEmail) {
+		filtered.Email = ""
+	}
+	if !containsString(allowed, ClaimOrgID) {
+		filtered.OrgID = ""
+	}
+	return &filtered
+}
+
+// containsString 检查列表中是否包含指定项
+func containsString(list []string, item string) bool {
+	for _, v := range list {
+		if v == item {
+			return true
+		}
+	}
+	return false
+}
+
 // generateTokenID 生成令牌 ID
 func generateTokenID() string {
 	return generateSecureCode(16)
 }
 
+// NormalizeIssuer 规范化签发者标识，去掉尾部斜杠
+// 令牌中的 iss 与 Discovery 文档的 issuer 必须逐字一致，否则客户端校验失败
+func NormalizeIssuer(issuer string) string {
+	return strings.TrimRight(issuer, "/")
+}
+
+// NewTokenFamilyID 生成令牌族 ID
+func NewTokenFamilyID() string {
+	return generateSecureCode(16)
+}
+
 // generateSecureCode 生成安全随机码
 func generateSecureCode(length int) string {
 	bytes := make([]byte, length)
@@ -281,4 +927,5 @@ const (
 	DefaultAccessExpiry  = 15 * time.Minute
 	DefaultRefreshExpiry = 7 * 24 * time.Hour
 	DefaultCodeExpiry    = 10 * time.Minute
+	DefaultLeeway        = 30 * time.Second
 )
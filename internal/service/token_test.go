@@ -2,10 +2,20 @@ package service
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
 )
 
 // 创建测试用的令牌服务
@@ -22,6 +32,146 @@ func newTestTokenService() TokenService {
 	})
 }
 
+// newTestES256TokenService 创建使用 ES256 签名的令牌服务
+func newTestES256TokenService(t *testing.T) TokenService {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("生成 ECDSA 密钥失败: %v", err)
+	}
+	return NewTokenService(&TokenServiceConfig{
+		Algorithm:     AlgES256,
+		PrivateKey:    privateKey,
+		PublicKey:     &privateKey.PublicKey,
+		KeyID:         "test-ec-key",
+		Issuer:        "test-issuer",
+		AccessExpiry:  15 * time.Minute,
+		RefreshExpiry: 7 * 24 * time.Hour,
+		CodeExpiry:    10 * time.Minute,
+	})
+}
+
+// TestTokenService_ES256 测试 ES256 签发和验证各类令牌
+func TestTokenService_ES256(t *testing.T) {
+	svc := newTestES256TokenService(t)
+	ctx := context.Background()
+
+	if alg := svc.SigningAlgorithm(); alg != AlgES256 {
+		t.Fatalf("签名算法 = %s, 期望 ES256", alg)
+	}
+
+	generators := map[string]func(context.Context, *TokenClaims) (string, error){
+		"access":  svc.GenerateAccessToken,
+		"refresh": svc.GenerateRefreshToken,
+		"id":      svc.GenerateIDToken,
+	}
+	for typ, generate := range generators {
+		token, err := generate(ctx, &TokenClaims{UserID: "user-123", ClientID: "client-1", Scopes: []string{"openid"}})
+		if err != nil {
+			t.Fatalf("生成 %s 令牌失败: %v", typ, err)
+		}
+
+		parsed, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+		if err != nil {
+			t.Fatalf("解析 %s 令牌失败: %v", typ, err)
+		}
+		if parsed.Header["alg"] != "ES256" || parsed.Header["kid"] != "test-ec-key" {
+			t.Errorf("%s 令牌头错误: %v", typ, parsed.Header)
+		}
+
+		claims, err := svc.ValidateToken(ctx, token)
+		if err != nil {
+			t.Fatalf("验证 %s 令牌失败: %v", typ, err)
+		}
+		if claims.UserID != "user-123" || claims.Type != typ {
+			t.Errorf("%s 令牌声明错误: uid=%s type=%s", typ, claims.UserID, claims.Type)
+		}
+	}
+}
+
+// TestTokenService_RejectsOtherAlgorithm 测试只接受配置的签名算法
+func TestTokenService_RejectsOtherAlgorithm(t *testing.T) {
+	ctx := context.Background()
+	rsaSvc := newTestTokenService()
+	ecSvc := newTestES256TokenService(t)
+
+	rsaToken, err := rsaSvc.GenerateAccessToken(ctx, &TokenClaims{UserID: "user-123"})
+	if err != nil {
+		t.Fatalf("生成令牌失败: %v", err)
+	}
+	if _, err := ecSvc.ValidateToken(ctx, rsaToken); err == nil {
+		t.Error("ES256 服务不应接受 RS256 令牌")
+	}
+
+	ecToken, err := ecSvc.GenerateAccessToken(ctx, &TokenClaims{UserID: "user-123"})
+	if err != nil {
+		t.Fatalf("生成令牌失败: %v", err)
+	}
+	if _, err := rsaSvc.ValidateToken(ctx, ecToken); err == nil {
+		t.Error("RS256 服务不应接受 ES256 令牌")
+	}
+}
+
+// TestTokenService_AlgorithmConfusion 测试把令牌头的 alg 改为 none 或 HS256 后验证失败
+func TestTokenService_AlgorithmConfusion(t *testing.T) {
+	svc := newTestTokenService()
+	ctx := context.Background()
+
+	token, err := svc.GenerateIDToken(ctx, &TokenClaims{UserID: "user-123", ClientID: "client-1"})
+	if err != nil {
+		t.Fatalf("生成令牌失败: %v", err)
+	}
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(token, claims); err != nil {
+		t.Fatalf("解析令牌失败: %v", err)
+	}
+
+	// 以公开的公钥作为 HMAC 密钥签名
+	pubDER, err := x509.MarshalPKIXPublicKey(svc.GetPublicKey())
+	if err != nil {
+		t.Fatalf("编码公钥失败: %v", err)
+	}
+	pubPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER})
+
+	forge := func(method jwt.SigningMethod, key interface{}) string {
+		forged := jwt.NewWithClaims(method, claims)
+		forged.Header["kid"] = svc.GetKeyID()
+		signed, err := forged.SignedString(key)
+		if err != nil {
+			t.Fatalf("伪造 %s 令牌失败: %v", method.Alg(), err)
+		}
+		return signed
+	}
+	// 保留原签名只修改头部的 alg
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","kid":"test-key-1","typ":"JWT"}`))
+	parts := strings.Split(token, ".")
+
+	forgeries := map[string]string{
+		"none":         forge(jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType),
+		"none 保留原签名":   header + "." + parts[1] + "." + parts[2],
+		"HS256 公钥 PEM": forge(jwt.SigningMethodHS256, pubPEM),
+		"HS256 公钥 DER": forge(jwt.SigningMethodHS256, pubDER),
+		"HS512 公钥 PEM": forge(jwt.SigningMethodHS512, pubPEM),
+	}
+	for name, forged := range forgeries {
+		t.Run(name, func(t *testing.T) {
+			if _, err := svc.ValidateToken(ctx, forged); err == nil {
+				t.Error("ValidateToken 应拒绝伪造的令牌")
+			}
+			if _, err := svc.ValidateIDTokenHint(ctx, forged); err == nil {
+				t.Error("ValidateIDTokenHint 应拒绝伪造的令牌")
+			}
+			if result, err := svc.InspectToken(ctx, forged); err == nil && result.SignatureValid {
+				t.Error("InspectToken 不应认为伪造令牌的签名有效")
+			}
+		})
+	}
+
+	// 原令牌仍然有效
+	if _, err := svc.ValidateToken(ctx, token); err != nil {
+		t.Errorf("原令牌验证失败: %v", err)
+	}
+}
+
 // TestTokenService_GenerateAccessToken 测试生成访问令牌
 func TestTokenService_GenerateAccessToken(t *testing.T) {
 	svc := newTestTokenService()
@@ -82,6 +232,36 @@ func TestTokenService_GenerateRefreshToken(t *testing.T) {
 	}
 }
 
+// TestTokenService_GenerateAccessTokenWithTTL 测试按指定有效期生成访问令牌
+func TestTokenService_GenerateAccessTokenWithTTL(t *testing.T) {
+	svc := newTestTokenService()
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		ttl  time.Duration
+		want int64
+	}{
+		{"自定义有效期", 60 * time.Second, 60},
+		{"零值使用默认有效期", 0, int64((15 * time.Minute).Seconds())},
+		{"超过全局有效期时截断", 30 * 24 * time.Hour, int64((7 * 24 * time.Hour).Seconds())},
+	}
+
+	for _, tt := range tests {
+		token, err := svc.GenerateAccessTokenWithTTL(ctx, &TokenClaims{UserID: "user-123"}, tt.ttl)
+		if err != nil {
+			t.Fatalf("%s: 生成访问令牌失败: %v", tt.name, err)
+		}
+		claims, err := svc.ValidateToken(ctx, token)
+		if err != nil {
+			t.Fatalf("%s: 验证令牌失败: %v", tt.name, err)
+		}
+		if got := claims.ExpiresAt.Unix() - claims.IssuedAt.Unix(); got != tt.want {
+			t.Errorf("%s: 有效期不匹配: 期望 %d, 实际 %d", tt.name, tt.want, got)
+		}
+	}
+}
+
 // TestTokenService_ValidateExpiredToken 测试验证过期令牌
 func TestTokenService_ValidateExpiredToken(t *testing.T) {
 	privateKey, _ := rsa.GenerateKey(rand.Reader, 2048)
@@ -105,6 +285,38 @@ func TestTokenService_ValidateExpiredToken(t *testing.T) {
 	}
 }
 
+// TestTokenService_Leeway 测试时钟偏移容忍
+func TestTokenService_Leeway(t *testing.T) {
+	privateKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	newService := func(accessExpiry time.Duration) TokenService {
+		return NewTokenService(&TokenServiceConfig{
+			PrivateKey:    privateKey,
+			PublicKey:     &privateKey.PublicKey,
+			KeyID:         "test-key-1",
+			Issuer:        "test-issuer",
+			AccessExpiry:  accessExpiry,
+			RefreshExpiry: 7 * 24 * time.Hour,
+			CodeExpiry:    10 * time.Minute,
+			Leeway:        time.Minute,
+		})
+	}
+	ctx := context.Background()
+
+	// 刚过期 30 秒，在容忍范围内
+	svc := newService(-30 * time.Second)
+	token, _ := svc.GenerateAccessToken(ctx, &TokenClaims{UserID: "user-123"})
+	if _, err := svc.ValidateToken(ctx, token); err != nil {
+		t.Errorf("过期 30 秒的令牌应在容忍范围内, 实际 %v", err)
+	}
+
+	// 过期 2 分钟，超出容忍范围
+	svc = newService(-2 * time.Minute)
+	token, _ = svc.GenerateAccessToken(ctx, &TokenClaims{UserID: "user-123"})
+	if _, err := svc.ValidateToken(ctx, token); err != ErrTokenExpired {
+		t.Errorf("期望 ErrTokenExpired, 实际 %v", err)
+	}
+}
+
 // TestTokenService_RevokeToken 测试撤销令牌
 func TestTokenService_RevokeToken(t *testing.T) {
 	svc := newTestTokenService()
@@ -129,6 +341,308 @@ func TestTokenService_RevokeToken(t *testing.T) {
 	}
 }
 
+// TestTokenService_RefreshTokenFamily 测试刷新令牌重用检测和令牌族撤销
+func TestTokenService_RefreshTokenFamily(t *testing.T) {
+	svc := newTestTokenService()
+	ctx := context.Background()
+
+	// 未指定令牌族时开启新的令牌族
+	oldRefresh, _ := svc.GenerateRefreshToken(ctx, &TokenClaims{UserID: "user-123"})
+	oldClaims, err := svc.ValidateToken(ctx, oldRefresh)
+	if err != nil {
+		t.Fatalf("令牌应该有效: %v", err)
+	}
+	if oldClaims.FamilyID == "" {
+		t.Fatal("刷新令牌应该属于令牌族")
+	}
+
+	// 轮换：撤销旧令牌，新令牌沿用令牌族
+	svc.RevokeToken(ctx, oldRefresh)
+	newClaims := &TokenClaims{UserID: "user-123", FamilyID: oldClaims.FamilyID}
+	newAccess, _ := svc.GenerateAccessToken(ctx, newClaims)
+	newRefresh, _ := svc.GenerateRefreshToken(ctx, newClaims)
+
+	// 再次提交已轮换的刷新令牌
+	reused, err := svc.ValidateToken(ctx, oldRefresh)
+	if err != ErrRefreshTokenUsed {
+		t.Fatalf("期望 ErrRefreshTokenUsed, 实际 %v", err)
+	}
+	if reused == nil || reused.FamilyID != oldClaims.FamilyID {
+		t.Fatalf("重用检测应返回原令牌族, 实际 %+v", reused)
+	}
+
+	// 其他令牌族不受影响
+	otherRefresh, _ := svc.GenerateRefreshToken(ctx, &TokenClaims{UserID: "user-123"})
+
+	svc.RevokeTokenFamily(ctx, reused.FamilyID)
+
+	for name, token := range map[string]string{"访问令牌": newAccess, "刷新令牌": newRefresh} {
+		if _, err := svc.ValidateToken(ctx, token); err != ErrInvalidToken {
+			t.Errorf("令牌族撤销后%s应失效, 实际 %v", name, err)
+		}
+	}
+	if _, err := svc.ValidateToken(ctx, otherRefresh); err != nil {
+		t.Errorf("其他令牌族应保持有效: %v", err)
+	}
+
+	// 撤销的访问令牌不会触发重用检测
+	svc.RevokeToken(ctx, otherRefresh)
+	access, _ := svc.GenerateAccessToken(ctx, &TokenClaims{UserID: "user-123", FamilyID: "family-x"})
+	svc.RevokeToken(ctx, access)
+	if _, err := svc.ValidateToken(ctx, access); err != ErrInvalidToken {
+		t.Errorf("期望 ErrInvalidToken, 实际 %v", err)
+	}
+}
+
+// newTestRedisTokenService 创建使用 Redis 存储撤销记录的令牌服务，共享同一私钥可模拟多个实例
+func newTestRedisTokenService(privateKey *rsa.PrivateKey, client *redis.Client) TokenService {
+	return NewTokenService(&TokenServiceConfig{
+		PrivateKey:    privateKey,
+		PublicKey:     &privateKey.PublicKey,
+		KeyID:         "test-key-1",
+		Issuer:        "test-issuer",
+		AccessExpiry:  15 * time.Minute,
+		RefreshExpiry: 7 * 24 * time.Hour,
+		CodeExpiry:    10 * time.Minute,
+		Redis:         client,
+	})
+}
+
+// TestTokenService_RevokeTokenFamily_Redis 测试令牌族撤销在实例间共享
+func TestTokenService_RevokeTokenFamily_Redis(t *testing.T) {
+	client, cleanup := setupTestRedis(t)
+	defer cleanup()
+	privateKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	instanceA := newTestRedisTokenService(privateKey, client)
+	instanceB := newTestRedisTokenService(privateKey, client)
+	ctx := context.Background()
+
+	refresh, _ := instanceA.GenerateRefreshToken(ctx, &TokenClaims{UserID: "user-123", FamilyID: "family-1"})
+	if _, err := instanceB.ValidateToken(ctx, refresh); err != nil {
+		t.Fatalf("令牌应该有效: %v", err)
+	}
+
+	if err := instanceA.RevokeTokenFamily(ctx, "family-1"); err != nil {
+		t.Fatalf("撤销令牌族失败: %v", err)
+	}
+	if _, err := instanceB.ValidateToken(ctx, refresh); err != ErrInvalidToken {
+		t.Errorf("其他实例上令牌族也应失效, 实际 %v", err)
+	}
+
+	// 撤销记录保留到刷新令牌过期为止
+	ttl := client.TTL(ctx, revokedFamilyPrefix+"family-1").Val()
+	if ttl <= 0 || ttl > 7*24*time.Hour {
+		t.Errorf("撤销记录的有效期应等于刷新令牌有效期, 实际 %v", ttl)
+	}
+
+	// Redis 不可用时拒绝令牌
+	cleanup()
+	if _, err := instanceB.ValidateToken(ctx, refresh); err == nil {
+		t.Error("无法查询撤销状态时令牌不应通过校验")
+	}
+}
+
+// TestTokenService_RotateRefreshToken_Redis 测试刷新令牌轮换在实例间共享且只能成功一次
+func TestTokenService_RotateRefreshToken_Redis(t *testing.T) {
+	client, cleanup := setupTestRedis(t)
+	defer cleanup()
+	privateKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	instanceA := newTestRedisTokenService(privateKey, client)
+	instanceB := newTestRedisTokenService(privateKey, client)
+	ctx := context.Background()
+
+	refresh, _ := instanceA.GenerateRefreshToken(ctx, &TokenClaims{UserID: "user-123"})
+	claims, err := instanceA.ValidateToken(ctx, refresh)
+	if err != nil {
+		t.Fatalf("令牌应该有效: %v", err)
+	}
+
+	// 两个实例同时轮换同一刷新令牌，只有一个成功
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var succeeded, reused int
+	for _, svc := range []TokenService{instanceA, instanceB, instanceA, instanceB} {
+		wg.Add(1)
+		go func(svc TokenService) {
+			defer wg.Done()
+			err := svc.RotateRefreshToken(ctx, claims)
+			mu.Lock()
+			defer mu.Unlock()
+			switch err {
+			case nil:
+				succeeded++
+			case ErrRefreshTokenUsed:
+				reused++
+			default:
+				t.Errorf("轮换失败: %v", err)
+			}
+		}(svc)
+	}
+	wg.Wait()
+	if succeeded != 1 || reused != 3 {
+		t.Errorf("期望 1 次成功 3 次重用, 实际成功 %d 次, 重用 %d 次", succeeded, reused)
+	}
+
+	// 其他实例上再次提交已轮换的令牌触发重用检测
+	if _, err := instanceB.ValidateToken(ctx, refresh); err != ErrRefreshTokenUsed {
+		t.Errorf("期望 ErrRefreshTokenUsed, 实际 %v", err)
+	}
+
+	// 撤销记录按 jti 存储，保留到令牌过期为止
+	ttl := client.TTL(ctx, revokedTokenPrefix+claims.ID).Val()
+	if ttl <= 0 || ttl > 7*24*time.Hour+DefaultLeeway {
+		t.Errorf("撤销记录的有效期应为令牌剩余有效期, 实际 %v", ttl)
+	}
+
+	// 撤销的访问令牌在其他实例上同样失效
+	access, _ := instanceA.GenerateAccessToken(ctx, &TokenClaims{UserID: "user-123"})
+	if err := instanceA.RevokeToken(ctx, access); err != nil {
+		t.Fatalf("撤销令牌失败: %v", err)
+	}
+	if _, err := instanceB.ValidateToken(ctx, access); err != ErrInvalidToken {
+		t.Errorf("期望 ErrInvalidToken, 实际 %v", err)
+	}
+}
+
+// TestTokenService_RotateRefreshToken 测试未配置 Redis 时按 jti 记录轮换并清理过期记录
+func TestTokenService_RotateRefreshToken(t *testing.T) {
+	svc := newTestTokenService()
+	impl := svc.(*tokenService)
+	ctx := context.Background()
+
+	refresh, _ := svc.GenerateRefreshToken(ctx, &TokenClaims{UserID: "user-123"})
+	claims, _ := svc.ValidateToken(ctx, refresh)
+	if err := svc.RotateRefreshToken(ctx, claims); err != nil {
+		t.Fatalf("首次轮换应成功: %v", err)
+	}
+	if err := svc.RotateRefreshToken(ctx, claims); err != ErrRefreshTokenUsed {
+		t.Errorf("重复轮换应返回 ErrRefreshTokenUsed, 实际 %v", err)
+	}
+	if _, ok := impl.revokedTokens[claims.ID]; !ok {
+		t.Error("撤销记录应以 jti 为 key")
+	}
+
+	// 过期的记录在下次写入时被清理
+	impl.revokedTokens["expired-jti"] = time.Now().Add(-time.Minute)
+	access, _ := svc.GenerateAccessToken(ctx, &TokenClaims{UserID: "user-123"})
+	_ = svc.RevokeToken(ctx, access)
+	if _, ok := impl.revokedTokens["expired-jti"]; ok {
+		t.Error("过期的撤销记录应被清理")
+	}
+
+	// 签名无效的令牌不会被记录
+	before := len(impl.revokedTokens)
+	_ = svc.RevokeToken(ctx, "not-a-token")
+	if len(impl.revokedTokens) != before {
+		t.Error("无效令牌不应写入撤销记录")
+	}
+}
+
+// TestTokenService_RevokeUserTokens_Redis 测试撤销用户全部令牌在实例间共享
+func TestTokenService_RevokeUserTokens_Redis(t *testing.T) {
+	client, cleanup := setupTestRedis(t)
+	defer cleanup()
+	privateKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	instanceA := newTestRedisTokenService(privateKey, client)
+	instanceB := newTestRedisTokenService(privateKey, client)
+	ctx := context.Background()
+
+	access, _ := instanceA.GenerateAccessToken(ctx, &TokenClaims{UserID: "user-123"})
+	other, _ := instanceA.GenerateAccessToken(ctx, &TokenClaims{UserID: "user-456"})
+
+	// 令牌签发时间精度为秒，确保撤销发生在之后的一秒
+	time.Sleep(time.Second)
+	if err := instanceA.RevokeUserTokens(ctx, "user-123"); err != nil {
+		t.Fatalf("撤销用户令牌失败: %v", err)
+	}
+
+	if _, err := instanceB.ValidateToken(ctx, access); err != ErrInvalidToken {
+		t.Errorf("其他实例上用户令牌也应失效, 实际 %v", err)
+	}
+	if _, err := instanceB.ValidateToken(ctx, other); err != nil {
+		t.Errorf("其他用户的令牌应保持有效: %v", err)
+	}
+	inspection, err := instanceB.InspectToken(ctx, access)
+	if err != nil || !inspection.Revoked {
+		t.Errorf("解析结果应标记为已撤销, 实际 %+v, %v", inspection, err)
+	}
+	if ttl := client.TTL(ctx, userRevokedAtPrefix+"user-123").Val(); ttl <= 0 {
+		t.Errorf("撤销记录应设置有效期, 实际 %v", ttl)
+	}
+
+	// 撤销之后签发的令牌有效
+	fresh, _ := instanceB.GenerateAccessToken(ctx, &TokenClaims{UserID: "user-123"})
+	if _, err := instanceA.ValidateToken(ctx, fresh); err != nil {
+		t.Errorf("撤销之后签发的令牌应有效: %v", err)
+	}
+}
+
+// TestTokenService_RevokeUserClientTokens_Redis 测试撤销应用授权在实例间共享
+func TestTokenService_RevokeUserClientTokens_Redis(t *testing.T) {
+	client, cleanup := setupTestRedis(t)
+	defer cleanup()
+	privateKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	instanceA := newTestRedisTokenService(privateKey, client)
+	instanceB := newTestRedisTokenService(privateKey, client)
+	ctx := context.Background()
+
+	revoked, _ := instanceA.GenerateAccessToken(ctx, &TokenClaims{UserID: "user-123", ClientID: "client-a"})
+	kept, _ := instanceA.GenerateAccessToken(ctx, &TokenClaims{UserID: "user-123", ClientID: "client-b"})
+
+	// 令牌签发时间精度为秒，确保撤销发生在之后的一秒
+	time.Sleep(time.Second)
+	if err := instanceA.RevokeUserClientTokens(ctx, "user-123", "client-a"); err != nil {
+		t.Fatalf("撤销应用授权失败: %v", err)
+	}
+
+	if _, err := instanceB.ValidateToken(ctx, revoked); err != ErrInvalidToken {
+		t.Errorf("其他实例上该应用的令牌也应失效, 实际 %v", err)
+	}
+	if _, err := instanceB.ValidateToken(ctx, kept); err != nil {
+		t.Errorf("其他应用的令牌应保持有效: %v", err)
+	}
+	if ttl := client.TTL(ctx, userClientRevokedAtPrefix+userClientKey("user-123", "client-a")).Val(); ttl <= 0 {
+		t.Errorf("撤销记录应设置有效期, 实际 %v", ttl)
+	}
+}
+
+// TestTokenService_ConcurrentRevoke 并发校验和撤销令牌，需配合 -race 运行
+func TestTokenService_ConcurrentRevoke(t *testing.T) {
+	svc := newTestTokenService()
+	ctx := context.Background()
+	refresh, _ := svc.GenerateRefreshToken(ctx, &TokenClaims{UserID: "user-123", FamilyID: "family-0"})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				_, _ = svc.ValidateToken(ctx, refresh)
+				_, _ = svc.InspectToken(ctx, refresh)
+			}
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				family := "family-" + string(rune('a'+i))
+				_ = svc.RevokeTokenFamily(ctx, family)
+				_ = svc.RevokeToken(ctx, family)
+				_ = svc.RevokeUserTokens(ctx, "user-"+family)
+				_ = svc.RevokeUserClientTokens(ctx, "user-"+family, "client")
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if err := svc.RevokeTokenFamily(ctx, "family-0"); err != nil {
+		t.Fatalf("撤销令牌族失败: %v", err)
+	}
+	if _, err := svc.ValidateToken(ctx, refresh); err != ErrInvalidToken {
+		t.Errorf("令牌族撤销后令牌应失效, 实际 %v", err)
+	}
+}
+
 // TestTokenService_AuthorizationCode 测试授权码
 func TestTokenService_AuthorizationCode(t *testing.T) {
 	svc := newTestTokenService()
@@ -163,6 +677,70 @@ func TestTokenService_AuthorizationCode(t *testing.T) {
 	}
 }
 
+// TestTokenService_IDTokenAudience 测试 ID 令牌的 aud 和 azp 为请求方 client_id
+func TestTokenService_IDTokenAudience(t *testing.T) {
+	svc := newTestTokenService()
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		claims *TokenClaims
+		want   string
+	}{
+		{"仅设置 ClientID", &TokenClaims{UserID: "user-123", ClientID: "client-a"}, "client-a"},
+		{"优先使用 AppID", &TokenClaims{UserID: "user-123", AppID: "client-b", ClientID: "client-a"}, "client-b"},
+	}
+
+	for _, tt := range tests {
+		idToken, err := svc.GenerateIDToken(ctx, tt.claims)
+		if err != nil {
+			t.Fatalf("%s: 生成 ID 令牌失败: %v", tt.name, err)
+		}
+		claims, err := svc.ValidateToken(ctx, idToken)
+		if err != nil {
+			t.Fatalf("%s: 验证令牌失败: %v", tt.name, err)
+		}
+		if len(claims.Audience) != 1 || claims.Audience[0] != tt.want {
+			t.Errorf("%s: aud 期望 [%s], 实际 %v", tt.name, tt.want, claims.Audience)
+		}
+		if claims.AuthorizedParty != tt.want {
+			t.Errorf("%s: azp 期望 %s, 实际 %s", tt.name, tt.want, claims.AuthorizedParty)
+		}
+	}
+}
+
+// TestTokenService_IDTokenNonceAndAuthTime 测试 ID 令牌携带 nonce 和 auth_time
+func TestTokenService_IDTokenNonceAndAuthTime(t *testing.T) {
+	svc := newTestTokenService()
+	ctx := context.Background()
+	authTime := time.Now().Add(-time.Hour).Unix()
+
+	idToken, err := svc.GenerateIDToken(ctx, &TokenClaims{
+		UserID:   "user-123",
+		AppID:    "client-a",
+		Scopes:   []string{"openid"},
+		Nonce:    "n-0S6_WzA2Mj",
+		AuthTime: authTime,
+	})
+	if err != nil {
+		t.Fatalf("生成 ID 令牌失败: %v", err)
+	}
+
+	raw := rawTokenClaims(t, idToken)
+	if raw["nonce"] != "n-0S6_WzA2Mj" {
+		t.Errorf("nonce 不匹配: %v", raw["nonce"])
+	}
+	if raw["auth_time"] != float64(authTime) {
+		t.Errorf("auth_time 不匹配: %v", raw["auth_time"])
+	}
+
+	// 访问令牌不携带 nonce
+	accessToken, _ := svc.GenerateAccessToken(ctx, &TokenClaims{UserID: "user-123"})
+	if _, ok := rawTokenClaims(t, accessToken)["nonce"]; ok {
+		t.Error("访问令牌不应包含 nonce")
+	}
+}
+
 // TestTokenClaimsSerialization 测试令牌声明序列化
 func TestTokenClaimsSerialization(t *testing.T) {
 	claims := &TokenClaims{
@@ -195,3 +773,203 @@ func TestTokenClaimsSerialization(t *testing.T) {
 		t.Errorf("Scopes 长度不匹配")
 	}
 }
+
+// rawTokenClaims 解析令牌载荷中的原始声明
+func rawTokenClaims(t *testing.T, tokenString string) jwt.MapClaims {
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(tokenString, claims); err != nil {
+		t.Fatalf("解析令牌失败: %v", err)
+	}
+	return claims
+}
+
+// TestTokenService_ClaimMinimization 测试访问令牌声明精简
+func TestTokenService_ClaimMinimization(t *testing.T) {
+	privateKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	svc := NewTokenService(&TokenServiceConfig{
+		PrivateKey:        privateKey,
+		PublicKey:         &privateKey.PublicKey,
+		KeyID:             "test-key-1",
+		Issuer:            "test-issuer",
+		AccessExpiry:      15 * time.Minute,
+		RefreshExpiry:     7 * 24 * time.Hour,
+		CodeExpiry:        10 * time.Minute,
+		AccessTokenClaims: []string{"sub"},
+	})
+	ctx := context.Background()
+
+	claims := &TokenClaims{
+		UserID:   "user-123",
+		Username: "testuser",
+		Email:    "test@example.com",
+		Scopes:   []string{"openid", "profile", "email"},
+	}
+
+	accessToken, err := svc.GenerateAccessToken(ctx, claims)
+	if err != nil {
+		t.Fatalf("生成访问令牌失败: %v", err)
+	}
+	raw := rawTokenClaims(t, accessToken)
+	if raw["sub"] != "user-123" {
+		t.Errorf("访问令牌 sub 期望 user-123, 实际 %v", raw["sub"])
+	}
+	for _, name := range []string{ClaimUserID, ClaimUsername, ClaimEmail} {
+		if _, ok := raw[name]; ok {
+			t.Errorf("访问令牌不应包含 %s", name)
+		}
+	}
+
+	// 验证后仍可从 sub 获得用户 ID
+	validated, err := svc.ValidateToken(ctx, accessToken)
+	if err != nil {
+		t.Fatalf("验证访问令牌失败: %v", err)
+	}
+	if validated.UserID != "user-123" {
+		t.Errorf("UserID 期望 user-123, 实际 %s", validated.UserID)
+	}
+
+	// ID 令牌仍按 scope 携带用户资料
+	idToken, err := svc.GenerateIDToken(ctx, claims)
+	if err != nil {
+		t.Fatalf("生成 ID 令牌失败: %v", err)
+	}
+	raw = rawTokenClaims(t, idToken)
+	if raw[ClaimUsername] != "testuser" {
+		t.Errorf("ID 令牌 username 期望 testuser, 实际 %v", raw[ClaimUsername])
+	}
+	if raw[ClaimEmail] != "test@example.com" {
+		t.Errorf("ID 令牌 email 期望 test@example.com, 实际 %v", raw[ClaimEmail])
+	}
+
+	// 未授予 email scope 时 ID 令牌不含邮箱
+	idToken, _ = svc.GenerateIDToken(ctx, &TokenClaims{
+		UserID:   "user-123",
+		Username: "testuser",
+		Email:    "test@example.com",
+		Scopes:   []string{"openid", "profile"},
+	})
+	raw = rawTokenClaims(t, idToken)
+	if _, ok := raw[ClaimEmail]; ok {
+		t.Error("未授予 email scope 时 ID 令牌不应包含 email")
+	}
+}
+
+// TestTokenService_DefaultClaims 测试默认保留全部用户声明
+func TestTokenService_DefaultClaims(t *testing.T) {
+	svc := newTestTokenService()
+
+	accessToken, _ := svc.GenerateAccessToken(context.Background(), &TokenClaims{
+		UserID:   "user-123",
+		Username: "testuser",
+		Email:    "test@example.com",
+	})
+	raw := rawTokenClaims(t, accessToken)
+	if raw[ClaimUsername] != "testuser" || raw[ClaimEmail] != "test@example.com" {
+		t.Errorf("默认配置下访问令牌应包含用户资料, 实际 %v", raw)
+	}
+}
+
+// TestTokenService_InspectToken 测试令牌解析结果
+func TestTokenService_InspectToken(t *testing.T) {
+	svc := newTestTokenService()
+	ctx := context.Background()
+
+	token, _ := svc.GenerateAccessToken(ctx, &TokenClaims{UserID: "user-123", Username: "alice", Scopes: []string{"openid"}})
+	result, err := svc.InspectToken(ctx, token)
+	if err != nil {
+		t.Fatalf("解析令牌失败: %v", err)
+	}
+	if !result.Valid || !result.SignatureValid || result.Expired || result.Revoked || len(result.Reasons) != 0 {
+		t.Errorf("有效令牌的检查结果不正确: %+v", result)
+	}
+	if result.KeyID != "test-key-1" || result.Algorithm != "RS256" {
+		t.Errorf("kid/alg 不正确: %s/%s", result.KeyID, result.Algorithm)
+	}
+	if result.Claims["sub"] != "user-123" || result.Claims["username"] != "alice" || result.Claims["type"] != "access" {
+		t.Errorf("声明不完整: %v", result.Claims)
+	}
+	if result.ExpiresAt == nil {
+		t.Error("应返回过期时间")
+	}
+
+	// 撤销后报告撤销原因
+	svc.RevokeToken(ctx, token)
+	result, _ = svc.InspectToken(ctx, token)
+	if result.Valid || !result.Revoked || len(result.Reasons) != 1 || result.Reasons[0] != "令牌已被撤销" {
+		t.Errorf("期望撤销原因, 实际 %+v", result)
+	}
+
+	// 令牌族撤销
+	refresh, _ := svc.GenerateRefreshToken(ctx, &TokenClaims{UserID: "user-456", FamilyID: "family-1"})
+	svc.RevokeTokenFamily(ctx, "family-1")
+	result, _ = svc.InspectToken(ctx, refresh)
+	if result.Valid || !result.Revoked || result.Reasons[0] != "令牌所属的令牌族已被撤销" {
+		t.Errorf("期望令牌族撤销原因, 实际 %+v", result)
+	}
+
+	// 格式错误
+	if _, err := svc.InspectToken(ctx, "not-a-jwt"); err != ErrInvalidToken {
+		t.Errorf("期望 ErrInvalidToken, 实际 %v", err)
+	}
+}
+
+// TestTokenService_InspectToken_Expired 测试过期与签名错误原因
+func TestTokenService_InspectToken_Expired(t *testing.T) {
+	privateKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	svc := NewTokenService(&TokenServiceConfig{
+		PrivateKey:    privateKey,
+		PublicKey:     &privateKey.PublicKey,
+		KeyID:         "test-key-1",
+		Issuer:        "test-issuer",
+		AccessExpiry:  -1 * time.Hour,
+		RefreshExpiry: 7 * 24 * time.Hour,
+		CodeExpiry:    10 * time.Minute,
+	})
+	ctx := context.Background()
+
+	token, _ := svc.GenerateAccessToken(ctx, &TokenClaims{UserID: "user-123"})
+	result, err := svc.InspectToken(ctx, token)
+	if err != nil {
+		t.Fatalf("解析令牌失败: %v", err)
+	}
+	if result.Valid || !result.Expired || !result.SignatureValid || result.Revoked {
+		t.Errorf("过期令牌的检查结果不正确: %+v", result)
+	}
+	if len(result.Reasons) != 1 || !strings.HasPrefix(result.Reasons[0], ErrTokenExpired.Error()) {
+		t.Errorf("期望过期原因, 实际 %v", result.Reasons)
+	}
+
+	// 其他密钥签发的令牌签名无效
+	result, _ = newTestTokenService().InspectToken(ctx, token)
+	if result.SignatureValid || result.Valid {
+		t.Errorf("其他密钥签发的令牌签名应无效: %+v", result)
+	}
+	if !containsString(result.Reasons, ErrInvalidSignature.Error()) {
+		t.Errorf("期望签名失败原因, 实际 %v", result.Reasons)
+	}
+}
+
+func TestTokenClaims_HasPermission(t *testing.T) {
+	claims := &TokenClaims{ClientID: "svc", Roles: []string{"reader"}, Permissions: []string{"user:read", "app:*"}}
+	if !claims.IsServiceAccount() {
+		t.Error("没有用户的客户端凭证令牌应为服务账号令牌")
+	}
+	if !claims.HasRole("reader") {
+		t.Error("应拥有 reader 角色")
+	}
+	if !claims.HasPermission("user", "read") || !claims.HasPermission("app", "delete") {
+		t.Error("应拥有 user:read 和 app:* 覆盖的权限")
+	}
+	if claims.HasPermission("user", "delete") {
+		t.Error("不应拥有 user:delete 权限")
+	}
+
+	superAdmin := &TokenClaims{ClientID: "svc", Roles: []string{"super_admin"}}
+	if !superAdmin.HasPermission("org", "delete") {
+		t.Error("超级管理员应拥有所有权限")
+	}
+
+	if (&TokenClaims{UserID: "user-1", ClientID: "web"}).IsServiceAccount() {
+		t.Error("用户令牌不应为服务账号令牌")
+	}
+}
@@ -5,9 +5,11 @@ import (
 	"errors"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/pu-ac-cn/uac-backend/internal/model"
 	"github.com/pu-ac-cn/uac-backend/internal/repository"
+	"github.com/redis/go-redis/v9"
 )
 
 var (
@@ -22,36 +24,99 @@ var (
 	ErrUserLocked        = errors.New("用户已被锁定")
 	ErrUserDisabled      = errors.New("用户已被禁用")
 	ErrPasswordIncorrect = errors.New("密码错误")
+	ErrPhoneInvalid      = errors.New("手机号格式无效")
+	ErrPhoneExists       = errors.New("手机号已存在")
+	ErrImportTooLarge    = errors.New("导入行数超过上限")
+	ErrImportMismatch    = errors.New("用户与密码数量不一致")
+	ErrMergeSameUser     = errors.New("不能将用户合并到自身")
+
+	ErrOrgUserQuotaExceeded = errors.New("组织用户数量已达上限")
 )
 
 var (
 	usernameRegex = regexp.MustCompile(`^[a-zA-Z0-9_]+$`)
 	emailRegex    = regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
+	e164Regex     = regexp.MustCompile(`^\+[1-9][0-9]{7,14}$`)
 )
 
+// MaxImportRows 单次批量导入的最大行数
+const MaxImportRows = 1000
+
+// ImportResult 批量导入中单行的处理结果
+type ImportResult struct {
+	Row      int    `json:"row"` // 行号，从 1 开始
+	Username string `json:"username"`
+	UserID   string `json:"user_id,omitempty"`
+	Success  bool   `json:"success"`
+	Error    string `json:"error,omitempty"` // 失败原因
+}
+
+// DefaultCountryCode 手机号未带国家码时默认使用的国家码
+const DefaultCountryCode = "86"
+
 type UserService interface {
 	Create(ctx context.Context, user *model.User, password string) error
+	// BatchCreate 逐条创建用户，单行失败不影响其他行，返回每行的处理结果
+	BatchCreate(ctx context.Context, users []*model.User, passwords []string) ([]ImportResult, error)
 	GetByID(ctx context.Context, id string) (*model.User, error)
 	GetByUsername(ctx context.Context, username string) (*model.User, error)
 	Update(ctx context.Context, user *model.User) error
 	Delete(ctx context.Context, id string) error
+	// RestoreUser 恢复已软删除的用户，用户名或邮箱已被其他用户占用时返回仓库层的已存在错误
+	RestoreUser(ctx context.Context, id string) error
 	List(ctx context.Context, filter *repository.UserFilter, page *repository.Pagination) ([]*model.User, int64, error)
 	Authenticate(ctx context.Context, username, password string) (*model.User, error)
 	ChangePassword(ctx context.Context, userID, oldPassword, newPassword string) error
+	MarkEmailVerified(ctx context.Context, userID string) error
 	BindOrganization(ctx context.Context, userID, orgID string) error
 	UnbindOrganization(ctx context.Context, userID, orgID string) error
 	ListUserOrganizations(ctx context.Context, userID string) ([]*model.UserOrgBinding, error)
+	// ListOrgMembers 分页列出组织成员，绑定中携带用户信息
+	ListOrgMembers(ctx context.Context, orgID string, page *repository.Pagination) ([]*model.UserOrgBinding, int64, error)
 	HasOrgAccess(ctx context.Context, userID, orgID string) (bool, error)
+	// MergeUsers 将源用户合并到目标用户，源用户被软删除，可重复执行
+	MergeUsers(ctx context.Context, sourceID, targetID string) error
+}
+
+// UserServiceConfig 用户服务配置
+type UserServiceConfig struct {
+	// AllowDuplicatePhone 允许多个用户使用同一手机号，默认要求唯一
+	AllowDuplicatePhone bool
+	// DefaultCountryCode 手机号未带国家码时补充的国家码，默认 86
+	DefaultCountryCode string
+	// TokenService 合并用户后撤销源用户已签发的令牌，未设置时跳过
+	TokenService TokenService
+	// PasswordHistory 密码历史存储，未设置时不检查密码重用
+	PasswordHistory repository.PasswordHistoryRepository
+	// PasswordHistorySize 禁止重用的最近密码数量，0 使用默认值 5，负数关闭检查
+	PasswordHistorySize int
+	// Redis 合并用户后清除源用户和目标用户的角色缓存，与 RBAC 服务使用同一实例
+	Redis *redis.Client
 }
 
 type userService struct {
 	userRepo    repository.UserRepository
 	bindingRepo repository.UserOrgBindingRepository
 	orgRepo     repository.OrganizationRepository
+	config      *UserServiceConfig
+	history     *passwordHistory
 }
 
-func NewUserService(userRepo repository.UserRepository, bindingRepo repository.UserOrgBindingRepository, orgRepo repository.OrganizationRepository) UserService {
-	return &userService{userRepo: userRepo, bindingRepo: bindingRepo, orgRepo: orgRepo}
+func NewUserService(userRepo repository.UserRepository, bindingRepo repository.UserOrgBindingRepository, orgRepo repository.OrganizationRepository, cfg ...*UserServiceConfig) UserService {
+	config := &UserServiceConfig{}
+	if len(cfg) > 0 && cfg[0] != nil {
+		config = cfg[0]
+	}
+	if config.DefaultCountryCode == "" {
+		config.DefaultCountryCode = DefaultCountryCode
+	}
+	return &userService{
+		userRepo:    userRepo,
+		bindingRepo: bindingRepo,
+		orgRepo:     orgRepo,
+		config:      config,
+		history:     newPasswordHistory(config.PasswordHistory, config.PasswordHistorySize),
+	}
 }
 
 func (s *userService) Create(ctx context.Context, user *model.User, password string) error {
@@ -61,13 +126,47 @@ func (s *userService) Create(ctx context.Context, user *model.User, password str
 	if err := s.validatePassword(password); err != nil {
 		return err
 	}
+	if err := s.checkPhone(ctx, user, nil); err != nil {
+		return err
+	}
 	if err := user.SetPassword(password); err != nil {
 		return errors.New("密码加密失败")
 	}
 	if user.Status == "" {
 		user.Status = model.StatusActive
 	}
-	return s.userRepo.Create(ctx, user)
+	if err := s.userRepo.Create(ctx, user); err != nil {
+		return err
+	}
+	// 记录失败只影响之后的重用检查，不影响用户创建
+	_ = s.history.record(ctx, user)
+	return nil
+}
+
+func (s *userService) BatchCreate(ctx context.Context, users []*model.User, passwords []string) ([]ImportResult, error) {
+	if len(users) != len(passwords) {
+		return nil, ErrImportMismatch
+	}
+	if len(users) > MaxImportRows {
+		return nil, ErrImportTooLarge
+	}
+
+	// 逐条容错：已成功创建的用户不因后续行失败而回滚
+	results := make([]ImportResult, len(users))
+	for i, user := range users {
+		result := ImportResult{Row: i + 1, Username: user.Username}
+		if err := ctx.Err(); err != nil {
+			return results[:i], err
+		}
+		if err := s.Create(ctx, user, passwords[i]); err != nil {
+			result.Error = err.Error()
+		} else {
+			result.Success = true
+			result.UserID = user.ID
+		}
+		results[i] = result
+	}
+	return results, nil
 }
 
 func (s *userService) GetByID(ctx context.Context, id string) (*model.User, error) {
@@ -88,6 +187,17 @@ func (s *userService) Update(ctx context.Context, user *model.User) error {
 	if user.ID == "" {
 		return ErrUserIDEmpty
 	}
+	current, err := s.userRepo.GetByID(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	if err := s.checkPhone(ctx, user, current); err != nil {
+		return err
+	}
+	// 更换手机号后需要重新验证，新号码不能沿用旧号码的验证状态用于登录
+	if user.Phone != current.Phone {
+		user.PhoneVerified = false
+	}
 	return s.userRepo.Update(ctx, user)
 }
 
@@ -98,6 +208,19 @@ func (s *userService) Delete(ctx context.Context, id string) error {
 	return s.userRepo.Delete(ctx, id)
 }
 
+func (s *userService) RestoreUser(ctx context.Context, id string) error {
+	if id == "" {
+		return ErrUserIDEmpty
+	}
+	if err := s.userRepo.Restore(ctx, id); err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	return nil
+}
+
 func (s *userService) List(ctx context.Context, filter *repository.UserFilter, page *repository.Pagination) ([]*model.User, int64, error) {
 	if page == nil {
 		page = &repository.Pagination{Page: 1, PageSize: 20}
@@ -117,7 +240,7 @@ func (s *userService) Authenticate(ctx context.Context, username, password strin
 		return nil, ErrUserDisabled
 	}
 	if !user.VerifyPassword(password) {
-		user.IncrementFailedLogin()
+		user.IncrementFailedLogin(time.Now(), MaxFailedAttempts, LockDuration)
 		_ = s.userRepo.Update(ctx, user)
 		return nil, ErrPasswordIncorrect
 	}
@@ -137,9 +260,32 @@ func (s *userService) ChangePassword(ctx context.Context, userID, oldPassword, n
 	if err := s.validatePassword(newPassword); err != nil {
 		return err
 	}
+	if err := s.history.check(ctx, user, newPassword); err != nil {
+		return err
+	}
 	if err := user.SetPassword(newPassword); err != nil {
 		return errors.New("密码加密失败")
 	}
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return err
+	}
+	_ = s.history.record(ctx, user)
+	return nil
+}
+
+// MarkEmailVerified 将用户邮箱标记为已验证，已验证时直接返回
+func (s *userService) MarkEmailVerified(ctx context.Context, userID string) error {
+	if userID == "" {
+		return ErrUserIDEmpty
+	}
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.EmailVerified {
+		return nil
+	}
+	user.EmailVerified = true
 	return s.userRepo.Update(ctx, user)
 }
 
@@ -154,9 +300,19 @@ func (s *userService) BindOrganization(ctx context.Context, userID, orgID string
 		return err
 	}
 	if s.orgRepo != nil {
-		if _, err := s.orgRepo.GetByID(ctx, orgID); err != nil {
+		org, err := s.orgRepo.GetByID(ctx, orgID)
+		if err != nil {
 			return errors.New("组织不存在")
 		}
+		if org.MaxUsers > 0 {
+			count, err := s.bindingRepo.CountByOrgID(ctx, orgID)
+			if err != nil {
+				return err
+			}
+			if count >= int64(org.MaxUsers) {
+				return ErrOrgUserQuotaExceeded
+			}
+		}
 	}
 	binding := &model.UserOrgBinding{UserID: userID, OrgID: orgID}
 	return s.bindingRepo.Create(ctx, binding)
@@ -179,6 +335,16 @@ func (s *userService) ListUserOrganizations(ctx context.Context, userID string)
 	return s.bindingRepo.ListByUserID(ctx, userID)
 }
 
+func (s *userService) ListOrgMembers(ctx context.Context, orgID string, page *repository.Pagination) ([]*model.UserOrgBinding, int64, error) {
+	if orgID == "" {
+		return nil, 0, ErrOrgIDEmpty
+	}
+	if page == nil {
+		page = &repository.Pagination{Page: 1, PageSize: 20}
+	}
+	return s.bindingRepo.ListByOrgID(ctx, orgID, page)
+}
+
 func (s *userService) HasOrgAccess(ctx context.Context, userID, orgID string) (bool, error) {
 	if userID == "" || orgID == "" {
 		return false, nil
@@ -186,6 +352,27 @@ func (s *userService) HasOrgAccess(ctx context.Context, userID, orgID string) (b
 	return s.bindingRepo.Exists(ctx, userID, orgID)
 }
 
+func (s *userService) MergeUsers(ctx context.Context, sourceID, targetID string) error {
+	if sourceID == "" || targetID == "" {
+		return ErrUserIDEmpty
+	}
+	if sourceID == targetID {
+		return ErrMergeSameUser
+	}
+	if err := s.userRepo.Merge(ctx, sourceID, targetID); err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	// 角色已转移到目标用户，缓存不会随 user_roles 变化失效
+	invalidateUserRolesCache(ctx, s.config.Redis, sourceID, targetID)
+	if s.config.TokenService != nil {
+		return s.config.TokenService.RevokeUserTokens(ctx, sourceID)
+	}
+	return nil
+}
+
 func (s *userService) validateUser(user *model.User) error {
 	if user == nil {
 		return errors.New("用户信息不能为空")
@@ -210,6 +397,78 @@ func (s *userService) validateUser(user *model.User) error {
 	return nil
 }
 
+// checkPhone 规范化手机号并在要求唯一时检查是否已被其他用户使用
+// current 为更新前的用户，nil 表示新建用户；更新时手机号未变化则不检查，避免历史重复数据阻塞其他更新
+func (s *userService) checkPhone(ctx context.Context, user *model.User, current *model.User) error {
+	phone, err := NormalizePhone(user.Phone, s.config.DefaultCountryCode)
+	if err != nil {
+		return err
+	}
+	user.Phone = phone
+	if phone == "" || s.config.AllowDuplicatePhone {
+		return nil
+	}
+
+	if current == nil {
+		exists, err := s.userRepo.ExistsByPhone(ctx, phone)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return ErrPhoneExists
+		}
+		return nil
+	}
+
+	if current.Phone == phone {
+		return nil
+	}
+	existing, err := s.userRepo.GetByPhone(ctx, phone)
+	if err == nil && existing.ID != current.ID {
+		return ErrPhoneExists
+	}
+	if err != nil && !errors.Is(err, repository.ErrUserNotFound) {
+		return err
+	}
+	return nil
+}
+
+// NormalizePhone 将手机号规范化为 E.164 格式（+国家码+号码）
+// 忽略空格、短横线、括号和点；以 00 开头视为国际前缀；未带国家码时去掉国内长途前缀 0 并补充 defaultCountryCode
+func NormalizePhone(phone, defaultCountryCode string) (string, error) {
+	phone = strings.TrimSpace(phone)
+	if phone == "" {
+		return "", nil
+	}
+
+	var digits strings.Builder
+	for i, r := range phone {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r == '+' && i == 0:
+		case r == ' ' || r == '-' || r == '(' || r == ')' || r == '.':
+		default:
+			return "", ErrPhoneInvalid
+		}
+	}
+
+	number := digits.String()
+	switch {
+	case strings.HasPrefix(phone, "+"):
+	case strings.HasPrefix(number, "00"):
+		number = number[2:]
+	default:
+		number = defaultCountryCode + strings.TrimPrefix(number, "0")
+	}
+
+	normalized := "+" + number
+	if !e164Regex.MatchString(normalized) {
+		return "", ErrPhoneInvalid
+	}
+	return normalized, nil
+}
+
 func (s *userService) validatePassword(password string) error {
 	if password == "" {
 		return ErrPasswordEmpty
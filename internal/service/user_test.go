@@ -2,7 +2,10 @@ package service
 
 import (
 	"context"
+	"sort"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/pu-ac-cn/uac-backend/internal/model"
 	"github.com/pu-ac-cn/uac-backend/internal/repository"
@@ -12,6 +15,11 @@ type mockUserRepository struct {
 	users       map[string]*model.User
 	usernameMap map[string]string
 	emailMap    map[string]string
+	deleted     map[string]*model.User // 已软删除的用户
+	// 登录统计，RecordLogin 可能被并发调用
+	loginMu     sync.Mutex
+	firstLogins map[string]time.Time
+	loginCounts map[string]int
 }
 
 func newMockUserRepository() *mockUserRepository {
@@ -19,6 +27,9 @@ func newMockUserRepository() *mockUserRepository {
 		users:       make(map[string]*model.User),
 		usernameMap: make(map[string]string),
 		emailMap:    make(map[string]string),
+		deleted:     make(map[string]*model.User),
+		firstLogins: make(map[string]time.Time),
+		loginCounts: make(map[string]int),
 	}
 }
 
@@ -65,16 +76,36 @@ func (m *mockUserRepository) Update(ctx context.Context, user *model.User) error
 	return nil
 }
 
+// Delete 软删除：用户移入 deleted，查询不再可见
 func (m *mockUserRepository) Delete(ctx context.Context, id string) error {
 	if user, exists := m.users[id]; exists {
 		delete(m.usernameMap, user.Username)
 		delete(m.emailMap, user.Email)
 		delete(m.users, id)
+		m.deleted[id] = user
 		return nil
 	}
 	return repository.ErrUserNotFound
 }
 
+func (m *mockUserRepository) Restore(ctx context.Context, id string) error {
+	user, exists := m.deleted[id]
+	if !exists {
+		return repository.ErrUserNotFound
+	}
+	if _, taken := m.usernameMap[user.Username]; taken {
+		return repository.ErrUserUsernameExists
+	}
+	if _, taken := m.emailMap[user.Email]; taken {
+		return repository.ErrUserEmailExists
+	}
+	delete(m.deleted, id)
+	m.users[id] = user
+	m.usernameMap[user.Username] = id
+	m.emailMap[user.Email] = id
+	return nil
+}
+
 func (m *mockUserRepository) List(ctx context.Context, filter *repository.UserFilter, page *repository.Pagination) ([]*model.User, int64, error) {
 	var result []*model.User
 	for _, user := range m.users {
@@ -93,6 +124,86 @@ func (m *mockUserRepository) ExistsByEmail(ctx context.Context, email string) (b
 	return exists, nil
 }
 
+func (m *mockUserRepository) GetByPhone(ctx context.Context, phone string) (*model.User, error) {
+	for _, user := range m.users {
+		if user.Phone == phone {
+			return user, nil
+		}
+	}
+	return nil, repository.ErrUserNotFound
+}
+
+func (m *mockUserRepository) ExistsByPhone(ctx context.Context, phone string) (bool, error) {
+	_, err := m.GetByPhone(ctx, phone)
+	return err == nil, nil
+}
+
+func (m *mockUserRepository) CountByPhone(ctx context.Context, phone string) (int64, error) {
+	var count int64
+	for _, user := range m.users {
+		if user.Phone == phone {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *mockUserRepository) RecordLogin(ctx context.Context, userID string, at time.Time) (bool, error) {
+	m.loginMu.Lock()
+	defer m.loginMu.Unlock()
+	m.loginCounts[userID]++
+	if _, ok := m.firstLogins[userID]; ok {
+		return false, nil
+	}
+	m.firstLogins[userID] = at
+	return true, nil
+}
+
+// ListInactive 返回用户的副本，与数据库查询一样不会反映之后的修改
+func (m *mockUserRepository) ListInactive(ctx context.Context, before time.Time, afterID string, limit int) ([]*model.User, error) {
+	var result []*model.User
+	for _, user := range m.users {
+		if user.ID > afterID && isInactiveUser(user, before) {
+			copied := *user
+			result = append(result, &copied)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+func (m *mockUserRepository) DisableInactive(ctx context.Context, id string, before time.Time) (bool, error) {
+	user, exists := m.users[id]
+	if !exists || !isInactiveUser(user, before) {
+		return false, nil
+	}
+	user.Status = model.StatusDisabled
+	return true, nil
+}
+
+// isInactiveUser 与 inactiveUsersQuery 的条件一致
+func isInactiveUser(user *model.User, before time.Time) bool {
+	lastActive := user.CreatedAt
+	if user.LastLoginAt != nil {
+		lastActive = *user.LastLoginAt
+	}
+	return user.Status == model.StatusActive && lastActive.Before(before)
+}
+
+// Merge 只模拟用户本身的变化：目标必须存在，源用户被删除，重复执行不报错
+func (m *mockUserRepository) Merge(ctx context.Context, sourceID, targetID string) error {
+	if _, exists := m.users[targetID]; !exists {
+		return repository.ErrUserNotFound
+	}
+	if _, exists := m.users[sourceID]; exists {
+		return m.Delete(ctx, sourceID)
+	}
+	return nil
+}
+
 type mockBindingRepository struct {
 	bindings map[string]*model.UserOrgBinding
 }
@@ -154,6 +265,16 @@ func (m *mockBindingRepository) Exists(ctx context.Context, userID, orgID string
 	return exists, nil
 }
 
+func (m *mockBindingRepository) CountByOrgID(ctx context.Context, orgID string) (int64, error) {
+	var count int64
+	for _, binding := range m.bindings {
+		if binding.OrgID == orgID {
+			count++
+		}
+	}
+	return count, nil
+}
+
 func TestUserService_Create(t *testing.T) {
 	userRepo := newMockUserRepository()
 	bindingRepo := newMockBindingRepository()
@@ -170,6 +291,178 @@ func TestUserService_Create(t *testing.T) {
 	}
 }
 
+func TestUserService_BatchCreate(t *testing.T) {
+	svc := NewUserService(newMockUserRepository(), newMockBindingRepository(), nil)
+	ctx := context.Background()
+
+	if err := svc.Create(ctx, &model.User{Username: "existing", Email: "existing@example.com"}, "password123"); err != nil {
+		t.Fatalf("创建用户失败: %v", err)
+	}
+
+	users := []*model.User{
+		{Username: "alice", Email: "alice@example.com"},
+		{Username: "existing", Email: "other@example.com"},
+		{Username: "bob", Email: "not-an-email"},
+		{Username: "carol", Email: "carol@example.com"},
+	}
+	passwords := []string{"password123", "password123", "password123", "password123"}
+
+	results, err := svc.BatchCreate(ctx, users, passwords)
+	if err != nil {
+		t.Fatalf("批量创建失败: %v", err)
+	}
+	if len(results) != len(users) {
+		t.Fatalf("期望 %d 条结果，实际 %d", len(users), len(results))
+	}
+
+	// 失败行不影响前后已成功的行
+	wantSuccess := []bool{true, false, false, true}
+	for i, result := range results {
+		if result.Row != i+1 {
+			t.Errorf("第 %d 条结果行号错误: %d", i, result.Row)
+		}
+		if result.Success != wantSuccess[i] {
+			t.Errorf("第 %d 行期望成功=%v，实际=%v（%s）", i+1, wantSuccess[i], result.Success, result.Error)
+		}
+	}
+	if results[1].Error != repository.ErrUserUsernameExists.Error() {
+		t.Errorf("期望用户名已存在，实际: %s", results[1].Error)
+	}
+	if results[2].Error != ErrEmailInvalid.Error() {
+		t.Errorf("期望邮箱格式无效，实际: %s", results[2].Error)
+	}
+	if results[3].UserID == "" {
+		t.Error("成功的行应返回用户 ID")
+	}
+	if _, err := svc.GetByUsername(ctx, "alice"); err != nil {
+		t.Errorf("已成功导入的用户应保留: %v", err)
+	}
+}
+
+func TestUserService_BatchCreate_Limits(t *testing.T) {
+	svc := NewUserService(newMockUserRepository(), newMockBindingRepository(), nil)
+	ctx := context.Background()
+
+	if _, err := svc.BatchCreate(ctx, []*model.User{{Username: "alice"}}, nil); err != ErrImportMismatch {
+		t.Errorf("期望 ErrImportMismatch，实际: %v", err)
+	}
+
+	users := make([]*model.User, MaxImportRows+1)
+	passwords := make([]string, MaxImportRows+1)
+	if _, err := svc.BatchCreate(ctx, users, passwords); err != ErrImportTooLarge {
+		t.Errorf("期望 ErrImportTooLarge，实际: %v", err)
+	}
+}
+
+func TestUserService_PhoneUnique(t *testing.T) {
+	svc := NewUserService(newMockUserRepository(), newMockBindingRepository(), nil)
+	ctx := context.Background()
+
+	first := &model.User{Username: "phone1", Email: "phone1@example.com", Phone: "138 0013 8000"}
+	if err := svc.Create(ctx, first, "password123"); err != nil {
+		t.Fatalf("创建用户失败: %v", err)
+	}
+	if first.Phone != "+8613800138000" {
+		t.Errorf("手机号应规范化为 E.164, 实际 %s", first.Phone)
+	}
+
+	// 不同写法的同一号码视为重复
+	second := &model.User{Username: "phone2", Email: "phone2@example.com", Phone: "+86-138-0013-8000"}
+	if err := svc.Create(ctx, second, "password123"); err != ErrPhoneExists {
+		t.Errorf("期望 ErrPhoneExists, 实际 %v", err)
+	}
+
+	// 未填写手机号不受唯一性限制
+	for _, name := range []string{"nophone1", "nophone2"} {
+		user := &model.User{Username: name, Email: name + "@example.com"}
+		if err := svc.Create(ctx, user, "password123"); err != nil {
+			t.Errorf("空手机号用户创建失败: %v", err)
+		}
+	}
+
+	// 更新为他人的手机号被拒绝，保持自己的手机号不受影响
+	other := &model.User{Username: "phone3", Email: "phone3@example.com", Phone: "13900139000"}
+	if err := svc.Create(ctx, other, "password123"); err != nil {
+		t.Fatalf("创建用户失败: %v", err)
+	}
+	update := *other
+	update.Phone = "13800138000"
+	if err := svc.Update(ctx, &update); err != ErrPhoneExists {
+		t.Errorf("期望 ErrPhoneExists, 实际 %v", err)
+	}
+	update.Phone = "139-0013-9000"
+	if err := svc.Update(ctx, &update); err != nil {
+		t.Errorf("保留原手机号应更新成功: %v", err)
+	}
+}
+
+func TestUserService_UpdatePhoneResetsVerified(t *testing.T) {
+	svc := NewUserService(newMockUserRepository(), newMockBindingRepository(), nil, nil)
+	ctx := context.Background()
+
+	user := &model.User{Username: "verified", Email: "verified@example.com", Phone: "13800138000", PhoneVerified: true}
+	if err := svc.Create(ctx, user, "password123"); err != nil {
+		t.Fatalf("创建用户失败: %v", err)
+	}
+
+	// 仅书写格式不同的同一号码保留验证状态
+	update := *user
+	update.Phone = "+86 138-0013-8000"
+	if err := svc.Update(ctx, &update); err != nil {
+		t.Fatalf("更新用户失败: %v", err)
+	}
+	if !update.PhoneVerified {
+		t.Error("手机号未变化时不应清除验证状态")
+	}
+
+	changed := update
+	changed.Phone = "13900139000"
+	if err := svc.Update(ctx, &changed); err != nil {
+		t.Fatalf("更新用户失败: %v", err)
+	}
+	if changed.PhoneVerified {
+		t.Error("更换手机号后应清除验证状态")
+	}
+}
+
+func TestUserService_PhoneDuplicateAllowed(t *testing.T) {
+	svc := NewUserService(newMockUserRepository(), newMockBindingRepository(), nil, &UserServiceConfig{AllowDuplicatePhone: true})
+	ctx := context.Background()
+
+	for _, name := range []string{"shared1", "shared2"} {
+		user := &model.User{Username: name, Email: name + "@example.com", Phone: "13800138000"}
+		if err := svc.Create(ctx, user, "password123"); err != nil {
+			t.Errorf("允许重复手机号时创建失败: %v", err)
+		}
+	}
+}
+
+func TestNormalizePhone(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+		err   error
+	}{
+		{"", "", nil},
+		{"13800138000", "+8613800138000", nil},
+		{"+86 138-0013-8000", "+8613800138000", nil},
+		{"0086 13800138000", "+8613800138000", nil},
+		{"(010) 6552 9988", "+861065529988", nil},
+		{"+1 (415) 555-2671", "+14155552671", nil},
+		{"138abc", "", ErrPhoneInvalid},
+		{"+0123456789", "", ErrPhoneInvalid},
+		{"12345", "", ErrPhoneInvalid},
+		{"+1234567890123456", "", ErrPhoneInvalid},
+	}
+
+	for _, tt := range tests {
+		got, err := NormalizePhone(tt.input, DefaultCountryCode)
+		if err != tt.err || got != tt.want {
+			t.Errorf("NormalizePhone(%q) = %q, %v; 期望 %q, %v", tt.input, got, err, tt.want, tt.err)
+		}
+	}
+}
+
 func TestUserService_Authenticate(t *testing.T) {
 	userRepo := newMockUserRepository()
 	bindingRepo := newMockBindingRepository()
@@ -224,3 +517,135 @@ func TestUserService_BindOrganization(t *testing.T) {
 		t.Error("解绑后不应该有访问权限")
 	}
 }
+
+func TestUserService_BindOrganization_QuotaExceeded(t *testing.T) {
+	userRepo := newMockUserRepository()
+	bindingRepo := newMockBindingRepository()
+	orgRepo := newMockOrgRepository()
+	svc := NewUserService(userRepo, bindingRepo, orgRepo)
+	orgSvc := NewOrganizationService(orgRepo)
+	ctx := context.Background()
+
+	org := &model.Organization{Name: "配额组织", Slug: "quota-user-org", MaxUsers: 1}
+	_ = orgSvc.Create(ctx, org)
+
+	first := &model.User{Username: "quotauser1", Email: "quota1@example.com"}
+	second := &model.User{Username: "quotauser2", Email: "quota2@example.com"}
+	_ = svc.Create(ctx, first, "password123")
+	_ = svc.Create(ctx, second, "password123")
+
+	if err := svc.BindOrganization(ctx, first.ID, org.ID); err != nil {
+		t.Fatalf("未达上限时绑定失败: %v", err)
+	}
+	if err := svc.BindOrganization(ctx, second.ID, org.ID); err != ErrOrgUserQuotaExceeded {
+		t.Errorf("期望错误 %v, 实际 %v", ErrOrgUserQuotaExceeded, err)
+	}
+
+	// 上限为 0 表示不限
+	org.MaxUsers = 0
+	if err := svc.BindOrganization(ctx, second.ID, org.ID); err != nil {
+		t.Errorf("不限配额时绑定失败: %v", err)
+	}
+}
+
+// stubRevokeTokenService 记录被撤销令牌的用户
+type stubRevokeTokenService struct {
+	TokenService
+	revoked []string
+}
+
+func (s *stubRevokeTokenService) RevokeUserTokens(ctx context.Context, userID string) error {
+	s.revoked = append(s.revoked, userID)
+	return nil
+}
+
+func TestUserService_DeleteAndRestore(t *testing.T) {
+	userRepo := newMockUserRepository()
+	svc := NewUserService(userRepo, newMockBindingRepository(), nil)
+	authSvc := NewAuthService(userRepo, nil)
+	ctx := context.Background()
+
+	user := &model.User{Username: "restore", Email: "restore@example.com"}
+	if err := svc.Create(ctx, user, "Password123"); err != nil {
+		t.Fatalf("创建用户失败: %v", err)
+	}
+	if err := svc.Delete(ctx, user.ID); err != nil {
+		t.Fatalf("删除用户失败: %v", err)
+	}
+
+	// 软删除后查询和登录都视为不存在
+	if _, err := svc.GetByID(ctx, user.ID); err == nil {
+		t.Error("删除后不应查到用户")
+	}
+	if _, err := authSvc.Authenticate(ctx, "restore", "Password123"); err != ErrInvalidCredentials {
+		t.Errorf("删除后登录应返回 ErrInvalidCredentials，实际: %v", err)
+	}
+
+	if err := svc.RestoreUser(ctx, user.ID); err != nil {
+		t.Fatalf("恢复用户失败: %v", err)
+	}
+	if _, err := authSvc.Authenticate(ctx, "restore", "Password123"); err != nil {
+		t.Errorf("恢复后应可以登录，实际: %v", err)
+	}
+
+	// 未被删除的用户不能恢复
+	if err := svc.RestoreUser(ctx, user.ID); err != ErrUserNotFound {
+		t.Errorf("未删除的用户恢复应返回 ErrUserNotFound，实际: %v", err)
+	}
+	if err := svc.RestoreUser(ctx, ""); err != ErrUserIDEmpty {
+		t.Errorf("空 ID 应返回 ErrUserIDEmpty，实际: %v", err)
+	}
+
+	// 删除后用户名被重新注册，恢复时冲突
+	if err := svc.Delete(ctx, user.ID); err != nil {
+		t.Fatalf("删除用户失败: %v", err)
+	}
+	if err := svc.Create(ctx, &model.User{Username: "restore", Email: "other@example.com"}, "Password123"); err != nil {
+		t.Fatalf("删除后应可重新注册用户名: %v", err)
+	}
+	if err := svc.RestoreUser(ctx, user.ID); err != repository.ErrUserUsernameExists {
+		t.Errorf("用户名被占用时恢复应返回 ErrUserUsernameExists，实际: %v", err)
+	}
+}
+
+func TestUserService_MergeUsers(t *testing.T) {
+	userRepo := newMockUserRepository()
+	tokenSvc := &stubRevokeTokenService{}
+	client, cleanup := setupTestRedis(t)
+	defer cleanup()
+	svc := NewUserService(userRepo, newMockBindingRepository(), newMockOrgRepository(), &UserServiceConfig{TokenService: tokenSvc, Redis: client})
+	ctx := context.Background()
+
+	source := &model.User{Username: "social_user", Email: "social@example.com"}
+	target := &model.User{Username: "local_user", Email: "local@example.com"}
+	_ = svc.Create(ctx, source, "password123")
+	_ = svc.Create(ctx, target, "password123")
+
+	if err := svc.MergeUsers(ctx, source.ID, source.ID); err != ErrMergeSameUser {
+		t.Errorf("合并到自身应返回 ErrMergeSameUser，实际: %v", err)
+	}
+	if err := svc.MergeUsers(ctx, source.ID, "missing"); err != ErrUserNotFound {
+		t.Errorf("目标用户不存在应返回 ErrUserNotFound，实际: %v", err)
+	}
+
+	for _, id := range []string{source.ID, target.ID} {
+		client.Set(ctx, userRolesCachePrefix+id, `["user"]`, UserRolesCacheTTL)
+	}
+	if err := svc.MergeUsers(ctx, source.ID, target.ID); err != nil {
+		t.Fatalf("合并用户失败: %v", err)
+	}
+	if _, err := svc.GetByID(ctx, source.ID); err == nil {
+		t.Error("合并后源用户应被删除")
+	}
+	if n := client.Exists(ctx, userRolesCachePrefix+source.ID, userRolesCachePrefix+target.ID).Val(); n != 0 {
+		t.Errorf("合并后应清除源用户和目标用户的角色缓存，剩余 %d 个", n)
+	}
+	if len(tokenSvc.revoked) != 1 || tokenSvc.revoked[0] != source.ID {
+		t.Errorf("合并后应撤销源用户的令牌，实际: %v", tokenSvc.revoked)
+	}
+
+	// 重复合并不报错
+	if err := svc.MergeUsers(ctx, source.ID, target.ID); err != nil {
+		t.Errorf("重复合并不应报错: %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+// Package service 业务逻辑层
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrVerificationTokenInvalid = errors.New("验证链接无效或已过期")
+)
+
+// EmailVerificationExpiry 邮箱验证令牌有效期
+const EmailVerificationExpiry = 24 * time.Hour
+
+// emailVerifyKeyPrefix 邮箱验证令牌 Redis key 前缀
+const emailVerifyKeyPrefix = "email_verify:"
+
+// EmailVerificationService 邮箱验证服务接口
+type EmailVerificationService interface {
+	// SendVerificationEmail 为用户生成一次性验证令牌并发送验证邮件
+	SendVerificationEmail(ctx context.Context, userID string) error
+	// VerifyEmail 校验验证令牌并将用户邮箱标记为已验证
+	VerifyEmail(ctx context.Context, token string) error
+}
+
+type emailVerificationService struct {
+	redis       *redis.Client
+	userService UserService
+	sender      EmailSender
+	verifyURL   string
+}
+
+// NewEmailVerificationService 创建邮箱验证服务
+// verifyURL 为验证链接地址，令牌以 token 查询参数附加
+func NewEmailVerificationService(redisClient *redis.Client, userSvc UserService, sender EmailSender, verifyURL string) EmailVerificationService {
+	if sender == nil {
+		sender = NewLogEmailSender()
+	}
+	return &emailVerificationService{
+		redis:       redisClient,
+		userService: userSvc,
+		sender:      sender,
+		verifyURL:   verifyURL,
+	}
+}
+
+// SendVerificationEmail 发送验证邮件
+func (s *emailVerificationService) SendVerificationEmail(ctx context.Context, userID string) error {
+	user, err := s.userService.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	// 已验证的邮箱无需重复发送
+	if user.EmailVerified {
+		return nil
+	}
+
+	token := generateSecureCode(32)
+	if err := storePendingToken(ctx, s.redis, emailVerifyKeyPrefix+token, user.ID, EmailVerificationExpiry); err != nil {
+		return fmt.Errorf("存储验证令牌失败: %w", err)
+	}
+
+	link := s.verifyURL + "?token=" + url.QueryEscape(token)
+	body := fmt.Sprintf("请在 24 小时内点击以下链接完成邮箱验证：%s", link)
+	return s.sender.Send(ctx, user.Email, "验证您的邮箱", body)
+}
+
+// VerifyEmail 校验邮箱验证令牌
+func (s *emailVerificationService) VerifyEmail(ctx context.Context, token string) error {
+	if token == "" {
+		return ErrVerificationTokenInvalid
+	}
+	key := emailVerifyKeyPrefix + token
+	userID, err := s.redis.Get(ctx, key).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return ErrVerificationTokenInvalid
+		}
+		return fmt.Errorf("获取验证令牌失败: %w", err)
+	}
+
+	if err := s.userService.MarkEmailVerified(ctx, userID); err != nil {
+		return err
+	}
+
+	// 令牌一次性使用
+	consumePendingToken(ctx, s.redis, key, userID)
+	return nil
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/pu-ac-cn/uac-backend/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// captureEmailSender 记录最后一封邮件的发送器
+type captureEmailSender struct {
+	to    string
+	body  string
+	count int
+}
+
+func (s *captureEmailSender) Send(ctx context.Context, to, subject, body string) error {
+	s.to = to
+	s.body = body
+	s.count++
+	return nil
+}
+
+// tokenFromBody 从邮件正文中提取 token 参数
+func (s *captureEmailSender) tokenFromBody(t *testing.T) string {
+	idx := strings.Index(s.body, "http")
+	require.GreaterOrEqual(t, idx, 0)
+	u, err := url.Parse(s.body[idx:])
+	require.NoError(t, err)
+	return u.Query().Get("token")
+}
+
+func setupVerificationTest(t *testing.T) (EmailVerificationService, UserService, *captureEmailSender, *model.User) {
+	client, cleanup := setupTestRedis(t)
+	t.Cleanup(cleanup)
+
+	userSvc := NewUserService(newMockUserRepository(), newMockBindingRepository(), nil)
+	user := &model.User{Username: "verifyuser", Email: "verify@example.com"}
+	require.NoError(t, userSvc.Create(context.Background(), user, "Password123"))
+
+	sender := &captureEmailSender{}
+	svc := NewEmailVerificationService(client, userSvc, sender, "http://localhost:8080/api/v1/auth/verify-email")
+	return svc, userSvc, sender, user
+}
+
+func TestEmailVerification_SendAndVerify(t *testing.T) {
+	svc, userSvc, sender, user := setupVerificationTest(t)
+	ctx := context.Background()
+
+	require.NoError(t, svc.SendVerificationEmail(ctx, user.ID))
+	assert.Equal(t, "verify@example.com", sender.to)
+
+	token := sender.tokenFromBody(t)
+	require.NotEmpty(t, token)
+
+	require.NoError(t, svc.VerifyEmail(ctx, token))
+	updated, err := userSvc.GetByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.True(t, updated.EmailVerified)
+
+	// 令牌只能使用一次
+	assert.ErrorIs(t, svc.VerifyEmail(ctx, token), ErrVerificationTokenInvalid)
+}
+
+func TestEmailVerification_InvalidToken(t *testing.T) {
+	svc, _, _, _ := setupVerificationTest(t)
+
+	assert.ErrorIs(t, svc.VerifyEmail(context.Background(), "not-exist"), ErrVerificationTokenInvalid)
+	assert.ErrorIs(t, svc.VerifyEmail(context.Background(), ""), ErrVerificationTokenInvalid)
+}
+
+func TestEmailVerification_AlreadyVerified(t *testing.T) {
+	svc, userSvc, sender, user := setupVerificationTest(t)
+	ctx := context.Background()
+
+	require.NoError(t, userSvc.MarkEmailVerified(ctx, user.ID))
+	// 重复标记应幂等
+	require.NoError(t, userSvc.MarkEmailVerified(ctx, user.ID))
+
+	// 已验证的邮箱不再发送验证邮件
+	require.NoError(t, svc.SendVerificationEmail(ctx, user.ID))
+	assert.Equal(t, 0, sender.count)
+}
@@ -0,0 +1,173 @@
+package response
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 内置支持的语言
+const (
+	LangZH = "zh" // 中文
+	LangEN = "en" // 英文
+
+	// DefaultLang 请求未指定语言或指定的语言不受支持时使用的语言
+	DefaultLang = LangZH
+)
+
+var (
+	messagesMu sync.RWMutex
+	// messages 按语言组织的错误码消息，语言使用 Accept-Language 中的主语言标签（小写）
+	messages = map[string]map[int]string{
+		LangZH: {
+			CodeSuccess:              "操作成功",
+			CodeInvalidRequest:       "请求参数无效",
+			CodeInvalidFormat:        "参数格式错误",
+			CodeMissingParam:         "必填参数缺失",
+			CodeRequestTooLarge:      "请求体过大",
+			CodeInvalidCredentials:   "用户名或密码错误",
+			CodeInvalidToken:         "令牌无效或已过期",
+			CodeInvalidClient:        "客户端认证失败",
+			CodeAccountLocked:        "账户已被锁定，请稍后重试",
+			CodeMFARequired:          "需要进行多因素认证",
+			CodeInvalidCode:          "验证码错误",
+			CodeAccessDenied:         "用户拒绝授权",
+			CodeForbidden:            "无权访问该资源",
+			CodeTooManySessions:      "活跃会话数已达上限，请先退出其他设备",
+			CodeInvalidAuthCode:      "授权码无效或已过期",
+			CodeInvalidRefreshToken:  "刷新令牌无效或已过期",
+			CodeUnsupportedGrantType: "不支持的授权类型",
+			CodeUnsupportedResponse:  "不支持的响应类型",
+			CodeInvalidScope:         "请求的权限范围无效",
+			CodePKCEFailed:           "PKCE 验证失败",
+			CodeUserNotFound:         "用户不存在",
+			CodeOrgNotFound:          "组织不存在",
+			CodeAppNotFound:          "应用不存在",
+			CodeRoleNotFound:         "角色不存在",
+			CodePermissionNotFound:   "权限不存在",
+			CodeSessionNotFound:      "会话不存在",
+			CodeBindingNotFound:      "用户不是该组织成员",
+			CodeUserExists:           "该用户名已被注册",
+			CodeEmailExists:          "该邮箱已被注册",
+			CodePhoneExists:          "该手机号已被注册",
+			CodeBindingExists:        "用户已是该组织成员",
+			CodeOrgSlugExists:        "组织标识已被使用",
+			CodeClientIDExists:       "Client ID 已被使用",
+			CodeServerError:          "服务器内部错误，请稍后重试",
+			CodeUnavailable:          "服务暂时不可用",
+			CodeTooManyReq:           "请求过于频繁，请稍后重试",
+		},
+		LangEN: {
+			CodeSuccess:              "Success",
+			CodeInvalidRequest:       "Invalid request parameters",
+			CodeInvalidFormat:        "Invalid parameter format",
+			CodeMissingParam:         "Missing required parameter",
+			CodeRequestTooLarge:      "Request body too large",
+			CodeInvalidCredentials:   "Invalid username or password",
+			CodeInvalidToken:         "Token is invalid or expired",
+			CodeInvalidClient:        "Client authentication failed",
+			CodeAccountLocked:        "Account is locked, please try again later",
+			CodeMFARequired:          "Multi-factor authentication required",
+			CodeInvalidCode:          "Invalid verification code",
+			CodeAccessDenied:         "User denied the authorization",
+			CodeForbidden:            "Access to this resource is forbidden",
+			CodeTooManySessions:      "Too many active sessions, please sign out on another device first",
+			CodeInvalidAuthCode:      "Authorization code is invalid or expired",
+			CodeInvalidRefreshToken:  "Refresh token is invalid or expired",
+			CodeUnsupportedGrantType: "Unsupported grant type",
+			CodeUnsupportedResponse:  "Unsupported response type",
+			CodeInvalidScope:         "Invalid scope",
+			CodePKCEFailed:           "PKCE verification failed",
+			CodeUserNotFound:         "User not found",
+			CodeOrgNotFound:          "Organization not found",
+			CodeAppNotFound:          "Application not found",
+			CodeRoleNotFound:         "Role not found",
+			CodePermissionNotFound:   "Permission not found",
+			CodeSessionNotFound:      "Session not found",
+			CodeBindingNotFound:      "User is not a member of this organization",
+			CodeUserExists:           "Username is already registered",
+			CodeEmailExists:          "Email is already registered",
+			CodePhoneExists:          "Phone number is already registered",
+			CodeBindingExists:        "User is already a member of this organization",
+			CodeOrgSlugExists:        "Organization slug is already in use",
+			CodeClientIDExists:       "Client ID is already in use",
+			CodeServerError:          "Internal server error, please try again later",
+			CodeUnavailable:          "Service temporarily unavailable",
+			CodeTooManyReq:           "Too many requests, please try again later",
+		},
+	}
+)
+
+// RegisterMessages 注册或覆盖某种语言的错误码消息，未提供的错误码回退到默认语言
+// lang 为主语言标签，如 "ja"，应在启动时调用
+func RegisterMessages(lang string, msgs map[int]string) {
+	lang = strings.ToLower(lang)
+	messagesMu.Lock()
+	defer messagesMu.Unlock()
+	table, ok := messages[lang]
+	if !ok {
+		table = make(map[int]string, len(msgs))
+		messages[lang] = table
+	}
+	for code, msg := range msgs {
+		table[code] = msg
+	}
+}
+
+// Message 返回错误码在当前请求语言下的消息
+func Message(c *gin.Context, code int) string {
+	lang := Language(c)
+	messagesMu.RLock()
+	defer messagesMu.RUnlock()
+	if msg, ok := messages[lang][code]; ok {
+		return msg
+	}
+	if msg, ok := messages[DefaultLang][code]; ok {
+		return msg
+	}
+	return "未知错误"
+}
+
+// Language 按 Accept-Language 头选择已注册的语言，按 q 值从高到低匹配主语言标签
+// 如 "en-US,en;q=0.9" 匹配 en，没有可用语言时返回 DefaultLang
+func Language(c *gin.Context) string {
+	header := c.GetHeader("Accept-Language")
+	if header == "" {
+		return DefaultLang
+	}
+
+	type candidate struct {
+		lang string
+		q    float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		base, _, _ := strings.Cut(strings.TrimSpace(tag), "-")
+		if base == "" || base == "*" || q <= 0 {
+			continue
+		}
+		candidates = append(candidates, candidate{lang: strings.ToLower(base), q: q})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+
+	messagesMu.RLock()
+	defer messagesMu.RUnlock()
+	for _, cand := range candidates {
+		if _, ok := messages[cand.lang]; ok {
+			return cand.lang
+		}
+	}
+	return DefaultLang
+}
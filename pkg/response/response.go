@@ -7,11 +7,20 @@ import (
 )
 
 // Response 标准响应结构
-// 字段顺序：code -> msg -> data
+// 字段顺序：code -> msg -> data -> request_id
 type Response struct {
-	Code int         `json:"code"` // 业务状态码，0 表示成功
-	Msg  string      `json:"msg"`  // 响应消息（中文）
-	Data interface{} `json:"data"` // 响应数据
+	Code      int         `json:"code"`                 // 业务状态码，0 表示成功
+	Msg       string      `json:"msg"`                  // 响应消息，按 Accept-Language 选择语言
+	Data      interface{} `json:"data"`                 // 响应数据
+	RequestID string      `json:"request_id,omitempty"` // 请求 ID，与日志中的 request_id 一致，便于问题追踪
+}
+
+// RequestIDKey gin context 中保存请求 ID 的键，由 Logger 中间件写入
+const RequestIDKey = "request_id"
+
+// requestID 读取当前请求的请求 ID，未经过 Logger 中间件时为空
+func requestID(c *gin.Context) string {
+	return c.GetString(RequestIDKey)
 }
 
 // 业务错误码
@@ -19,9 +28,10 @@ const (
 	CodeSuccess = 0 // 操作成功
 
 	// 参数错误 10xxx
-	CodeInvalidRequest = 10001 // 请求参数无效
-	CodeInvalidFormat  = 10002 // 参数格式错误
-	CodeMissingParam   = 10003 // 必填参数缺失
+	CodeInvalidRequest  = 10001 // 请求参数无效
+	CodeInvalidFormat   = 10002 // 参数格式错误
+	CodeMissingParam    = 10003 // 必填参数缺失
+	CodeRequestTooLarge = 10004 // 请求体过大
 
 	// 认证错误 20xxx
 	CodeInvalidCredentials = 20001 // 用户名或密码错误
@@ -32,6 +42,7 @@ const (
 	CodeInvalidCode        = 20006 // 验证码错误
 	CodeAccessDenied       = 20007 // 用户拒绝授权
 	CodeForbidden          = 20008 // 无权访问该资源
+	CodeTooManySessions    = 20009 // 活跃会话数已达上限
 
 	// OAuth 错误 30xxx
 	CodeInvalidAuthCode      = 30001 // 授权码无效或已过期
@@ -47,11 +58,16 @@ const (
 	CodeAppNotFound        = 40003 // 应用不存在
 	CodeRoleNotFound       = 40004 // 角色不存在
 	CodePermissionNotFound = 40005 // 权限不存在
+	CodeSessionNotFound    = 40006 // 会话不存在
+	CodeBindingNotFound    = 40007 // 用户不是该组织成员
 
 	// 冲突错误 50xxx
-	CodeUserExists  = 50001 // 该用户名已被注册
-	CodeEmailExists = 50002 // 该邮箱已被注册
-	CodePhoneExists = 50003 // 该手机号已被注册
+	CodeUserExists     = 50001 // 该用户名已被注册
+	CodeEmailExists    = 50002 // 该邮箱已被注册
+	CodePhoneExists    = 50003 // 该手机号已被注册
+	CodeBindingExists  = 50004 // 用户已是该组织成员
+	CodeOrgSlugExists  = 50005 // 组织标识已被使用
+	CodeClientIDExists = 50006 // Client ID 已被使用
 
 	// 服务器错误 90xxx
 	CodeServerError = 90001 // 服务器内部错误
@@ -59,76 +75,75 @@ const (
 	CodeTooManyReq  = 90003 // 请求过于频繁
 )
 
-// 错误码对应的消息
-var codeMessages = map[int]string{
-	CodeSuccess:              "操作成功",
-	CodeInvalidRequest:       "请求参数无效",
-	CodeInvalidFormat:        "参数格式错误",
-	CodeMissingParam:         "必填参数缺失",
-	CodeInvalidCredentials:   "用户名或密码错误",
-	CodeInvalidToken:         "令牌无效或已过期",
-	CodeInvalidClient:        "客户端认证失败",
-	CodeAccountLocked:        "账户已被锁定，请稍后重试",
-	CodeMFARequired:          "需要进行多因素认证",
-	CodeInvalidCode:          "验证码错误",
-	CodeAccessDenied:         "用户拒绝授权",
-	CodeForbidden:            "无权访问该资源",
-	CodeInvalidAuthCode:      "授权码无效或已过期",
-	CodeInvalidRefreshToken:  "刷新令牌无效或已过期",
-	CodeUnsupportedGrantType: "不支持的授权类型",
-	CodeUnsupportedResponse:  "不支持的响应类型",
-	CodeInvalidScope:         "请求的权限范围无效",
-	CodePKCEFailed:           "PKCE 验证失败",
-	CodeUserNotFound:         "用户不存在",
-	CodeOrgNotFound:          "组织不存在",
-	CodeAppNotFound:          "应用不存在",
-	CodeRoleNotFound:         "角色不存在",
-	CodePermissionNotFound:   "权限不存在",
-	CodeUserExists:           "该用户名已被注册",
-	CodeEmailExists:          "该邮箱已被注册",
-	CodePhoneExists:          "该手机号已被注册",
-	CodeServerError:          "服务器内部错误，请稍后重试",
-	CodeUnavailable:          "服务暂时不可用",
-	CodeTooManyReq:           "请求过于频繁，请稍后重试",
-}
-
 // Success 成功响应
 func Success(c *gin.Context, data interface{}) {
 	c.JSON(http.StatusOK, Response{
-		Code: CodeSuccess,
-		Msg:  codeMessages[CodeSuccess],
-		Data: data,
+		Code:      CodeSuccess,
+		Msg:       Message(c, CodeSuccess),
+		Data:      data,
+		RequestID: requestID(c),
+	})
+}
+
+// PageData 分页列表数据
+type PageData struct {
+	List     any   `json:"list"`
+	Total    int64 `json:"total"`
+	Page     int   `json:"page"`
+	PageSize int   `json:"page_size"`
+}
+
+// Page 分页列表成功响应
+func Page(c *gin.Context, list any, total int64, page, pageSize int) {
+	Success(c, PageData{
+		List:     list,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
 	})
 }
 
 // SuccessWithMsg 成功响应（自定义消息）
 func SuccessWithMsg(c *gin.Context, msg string, data interface{}) {
 	c.JSON(http.StatusOK, Response{
-		Code: CodeSuccess,
-		Msg:  msg,
-		Data: data,
+		Code:      CodeSuccess,
+		Msg:       msg,
+		Data:      data,
+		RequestID: requestID(c),
 	})
 }
 
 // Error 错误响应
 func Error(c *gin.Context, code int) {
-	msg, ok := codeMessages[code]
-	if !ok {
-		msg = "未知错误"
-	}
 	c.JSON(codeToHTTPStatus(code), Response{
-		Code: code,
-		Msg:  msg,
-		Data: nil,
+		Code:      code,
+		Msg:       Message(c, code),
+		Data:      nil,
+		RequestID: requestID(c),
 	})
 }
 
 // ErrorWithMsg 错误响应（自定义消息）
+// 自定义消息只有中文，请求其它语言时返回错误码对应的通用消息
 func ErrorWithMsg(c *gin.Context, code int, msg string) {
+	if Language(c) != DefaultLang {
+		msg = Message(c, code)
+	}
+	c.JSON(codeToHTTPStatus(code), Response{
+		Code:      code,
+		Msg:       msg,
+		Data:      nil,
+		RequestID: requestID(c),
+	})
+}
+
+// ErrorWithData 错误响应（携带数据），用于需要客户端继续下一步的错误，如 MFA
+func ErrorWithData(c *gin.Context, code int, data interface{}) {
 	c.JSON(codeToHTTPStatus(code), Response{
-		Code: code,
-		Msg:  msg,
-		Data: nil,
+		Code:      code,
+		Msg:       Message(c, code),
+		Data:      data,
+		RequestID: requestID(c),
 	})
 }
 
@@ -137,6 +152,8 @@ func codeToHTTPStatus(code int) int {
 	switch {
 	case code == CodeSuccess:
 		return http.StatusOK
+	case code == CodeRequestTooLarge:
+		return http.StatusRequestEntityTooLarge
 	case code >= 10000 && code < 20000:
 		return http.StatusBadRequest
 	case code >= 20000 && code < 30000:
@@ -152,6 +169,8 @@ func codeToHTTPStatus(code int) int {
 		return http.StatusConflict
 	case code == CodeTooManyReq:
 		return http.StatusTooManyRequests
+	case code == CodeUnavailable:
+		return http.StatusServiceUnavailable
 	default:
 		return http.StatusInternalServerError
 	}
@@ -0,0 +1,90 @@
+package response
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// doRequest 以指定 Accept-Language 调用 handler 并解析响应
+func doRequest(t *testing.T, acceptLanguage string, handler gin.HandlerFunc) Response {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/", handler)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if acceptLanguage != "" {
+		req.Header.Set("Accept-Language", acceptLanguage)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var resp Response
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("解析响应失败: %v", err)
+	}
+	return resp
+}
+
+// TestErrorAcceptLanguage 测试按 Accept-Language 返回对应语言的消息
+func TestErrorAcceptLanguage(t *testing.T) {
+	notFound := func(c *gin.Context) { Error(c, CodeUserNotFound) }
+
+	tests := []struct {
+		acceptLanguage string
+		want           string
+	}{
+		{"", "用户不存在"},
+		{"en", "User not found"},
+		{"en-US,en;q=0.9", "User not found"},
+		{"zh-CN,zh;q=0.9,en;q=0.8", "用户不存在"},
+		{"zh;q=0.5,en-GB;q=0.8", "User not found"},
+		{"fr-FR,de;q=0.9", "用户不存在"}, // 不支持的语言回退中文
+		{"en;q=0,zh;q=0.1", "用户不存在"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.acceptLanguage, func(t *testing.T) {
+			resp := doRequest(t, tt.acceptLanguage, notFound)
+			if resp.Code != CodeUserNotFound || resp.Msg != tt.want {
+				t.Errorf("期望 %d %q, 实际 %d %q", CodeUserNotFound, tt.want, resp.Code, resp.Msg)
+			}
+		})
+	}
+
+	if resp := doRequest(t, "en", func(c *gin.Context) { Success(c, nil) }); resp.Msg != "Success" {
+		t.Errorf("Success 期望英文消息, 实际 %q", resp.Msg)
+	}
+}
+
+// TestErrorWithMsgAcceptLanguage 测试自定义中文消息在其它语言下回退为通用消息
+func TestErrorWithMsgAcceptLanguage(t *testing.T) {
+	handler := func(c *gin.Context) { ErrorWithMsg(c, CodeInvalidRequest, "参数错误: username 不能为空") }
+
+	if resp := doRequest(t, "zh-CN", handler); resp.Msg != "参数错误: username 不能为空" {
+		t.Errorf("中文请求期望保留自定义消息, 实际 %q", resp.Msg)
+	}
+	if resp := doRequest(t, "en", handler); resp.Msg != "Invalid request parameters" {
+		t.Errorf("英文请求期望通用英文消息, 实际 %q", resp.Msg)
+	}
+}
+
+// TestRegisterMessages 测试注册新语言，缺失的错误码回退中文
+func TestRegisterMessages(t *testing.T) {
+	RegisterMessages("JA", map[int]string{CodeUserNotFound: "ユーザーが存在しません"})
+	t.Cleanup(func() {
+		messagesMu.Lock()
+		delete(messages, "ja")
+		messagesMu.Unlock()
+	})
+
+	if resp := doRequest(t, "ja-JP", func(c *gin.Context) { Error(c, CodeUserNotFound) }); resp.Msg != "ユーザーが存在しません" {
+		t.Errorf("期望日文消息, 实际 %q", resp.Msg)
+	}
+	if resp := doRequest(t, "ja", func(c *gin.Context) { Error(c, CodeOrgNotFound) }); resp.Msg != "组织不存在" {
+		t.Errorf("未注册的错误码期望回退中文, 实际 %q", resp.Msg)
+	}
+}
@@ -2,12 +2,19 @@
 package web
 
 import (
+	"crypto/sha256"
 	"embed"
+	"encoding/hex"
+	"fmt"
+	"io"
 	"io/fs"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"sync"
 
 	"github.com/gin-gonic/gin"
 )
@@ -43,14 +50,27 @@ func DefaultConfig() *StaticConfig {
 		Mode:      ModeEmbed,
 		DiskPath:  "./web/dist",
 		IndexFile: "index.html",
-		APIPrefix: []string{"/api/", "/oauth/", "/.well-known/", "/health"},
+		APIPrefix: []string{"/api/", "/oauth/", "/.well-known/", "/health", "/metrics"},
 	}
 }
 
+// 缓存策略
+const (
+	// CacheImmutable 文件名带内容 hash 的资源内容不会变化，长期缓存
+	CacheImmutable = "public, max-age=31536000, immutable"
+	// CacheNoCache 其它文件每次使用前需通过 ETag 向服务端确认
+	CacheNoCache = "no-cache"
+)
+
+// hashedAssetPattern 匹配构建工具生成的带内容 hash 的文件名，如 index-3f2a9c1b.js、logo.8d2e4f6a.png
+var hashedAssetPattern = regexp.MustCompile(`[.-]([A-Za-z0-9_]{8,})\.(js|mjs|css|png|jpe?g|gif|svg|webp|avif|ico|woff2?|ttf|eot)$`)
+
 // StaticHandler 静态文件处理器
 type StaticHandler struct {
 	config *StaticConfig
 	fs     http.FileSystem
+	// etags 嵌入模式下按内容计算的 ETag，嵌入文件不会变化，计算一次后缓存
+	etags sync.Map
 }
 
 // NewStaticHandler 创建静态文件处理器
@@ -108,16 +128,35 @@ func (h *StaticHandler) IsStaticFile(path string) bool {
 	return false
 }
 
-// FileExists 检查文件是否存在
-func (h *StaticHandler) FileExists(path string) bool {
+// cleanPath 规范化请求路径，返回以 / 开头、位于 dist 根目录内的路径
+// 包含 .. 片段、反斜杠或空字符的路径视为越界，返回 false
+func cleanPath(p string) (string, bool) {
+	if strings.ContainsAny(p, "\\\x00") {
+		return "", false
+	}
+	for _, segment := range strings.Split(p, "/") {
+		if segment == ".." {
+			return "", false
+		}
+	}
+	return path.Clean("/" + p), true
+}
+
+// FileExists 检查文件是否存在，越界的路径视为不存在
+func (h *StaticHandler) FileExists(p string) bool {
+	name, ok := cleanPath(p)
+	if !ok {
+		return false
+	}
+
 	if h.config.Mode == ModeDisk {
-		fullPath := filepath.Join(h.config.DiskPath, path)
+		fullPath := filepath.Join(h.config.DiskPath, filepath.FromSlash(name))
 		_, err := os.Stat(fullPath)
 		return err == nil
 	}
 
 	// embed 模式
-	file, err := h.fs.Open(path)
+	file, err := h.fs.Open(name)
 	if err != nil {
 		return false
 	}
@@ -125,8 +164,14 @@ func (h *StaticHandler) FileExists(path string) bool {
 	return true
 }
 
-// ServeFile 服务单个文件
-func (h *StaticHandler) ServeFile(c *gin.Context, path string) {
+// ServeFile 服务单个文件，越界的路径返回 404
+func (h *StaticHandler) ServeFile(c *gin.Context, p string) {
+	path, ok := cleanPath(p)
+	if !ok {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
 	// 尝试打开文件
 	file, err := h.fs.Open(path)
 	if err != nil {
@@ -143,24 +188,75 @@ func (h *StaticHandler) ServeFile(c *gin.Context, path string) {
 		return
 	}
 
-	// 如果是目录，尝试返回 index.html
+	// 如果是目录，尝试返回目录下的 index.html
 	if stat.IsDir() {
 		indexPath := strings.TrimSuffix(path, "/") + "/" + h.config.IndexFile
-		if h.FileExists(indexPath) {
-			path = indexPath
-		} else {
+		if !h.FileExists(indexPath) {
 			h.serveIndex(c)
 			return
 		}
+		h.ServeFile(c, indexPath)
+		return
 	}
 
-	// 使用 Gin 的文件服务
-	c.FileFromFS(path, h.fs)
+	h.serveContent(c, path, file, stat)
 }
 
 // serveIndex 返回首页（用于 SPA 路由）
 func (h *StaticHandler) serveIndex(c *gin.Context) {
-	c.FileFromFS(h.config.IndexFile, h.fs)
+	file, err := h.fs.Open(h.config.IndexFile)
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil || stat.IsDir() {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	h.serveContent(c, h.config.IndexFile, file, stat)
+}
+
+// serveContent 设置缓存头和 ETag 后返回文件内容，If-None-Match 命中时返回 304
+func (h *StaticHandler) serveContent(c *gin.Context, path string, file http.File, stat fs.FileInfo) {
+	c.Header("Cache-Control", CacheControl(path))
+	if etag, err := h.etag(path, file, stat); err == nil {
+		c.Header("ETag", etag)
+	}
+	http.ServeContent(c.Writer, c.Request, stat.Name(), stat.ModTime(), file)
+}
+
+// etag 生成文件的 ETag：磁盘模式基于修改时间和大小（支持热更新），嵌入模式没有修改时间，基于内容 hash
+func (h *StaticHandler) etag(path string, file http.File, stat fs.FileInfo) (string, error) {
+	if !stat.ModTime().IsZero() {
+		return fmt.Sprintf(`"%x-%x"`, stat.ModTime().UnixNano(), stat.Size()), nil
+	}
+	if cached, ok := h.etags.Load(path); ok {
+		return cached.(string), nil
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	etag := `"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`
+	h.etags.Store(path, etag)
+	return etag, nil
+}
+
+// CacheControl 返回文件的 Cache-Control：带内容 hash 的资源长期缓存，其余（包括 index.html）每次协商
+func CacheControl(path string) string {
+	match := hashedAssetPattern.FindStringSubmatch(strings.ToLower(filepath.Base(path)))
+	// hash 片段至少包含一个数字，避免把 my-component.js 这类普通文件名误判为带 hash
+	if match != nil && strings.ContainsAny(match[1], "0123456789") {
+		return CacheImmutable
+	}
+	return CacheNoCache
 }
 
 // Middleware 返回 Gin 中间件
@@ -0,0 +1,152 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupDiskStatic 在临时目录中创建前端构建产物，返回磁盘模式的路由
+func setupDiskStatic(t *testing.T, files map[string]string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	dir := t.TempDir()
+	for name, content := range files {
+		full := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(full), 0o755))
+		require.NoError(t, os.WriteFile(full, []byte(content), 0o644))
+	}
+
+	cfg := DefaultConfig()
+	cfg.Mode = ModeDisk
+	cfg.DiskPath = dir
+	router := gin.New()
+	NewStaticHandler(cfg).SetupRoutes(router)
+	return router
+}
+
+func get(router *gin.Engine, path string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestStaticHandler_CacheControl(t *testing.T) {
+	router := setupDiskStatic(t, map[string]string{
+		"index.html":                "<html></html>",
+		"assets/index-3f2a9c1b.js":  "console.log(1)",
+		"assets/style.8d2e4f6a.css": "body{}",
+		"assets/logo-B7x9Qk2m.png":  "png",
+		"favicon.ico":               "ico",
+		"my-component.js":           "export {}",
+	})
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/assets/index-3f2a9c1b.js", CacheImmutable},
+		{"/assets/style.8d2e4f6a.css", CacheImmutable},
+		{"/assets/logo-B7x9Qk2m.png", CacheImmutable},
+		{"/favicon.ico", CacheNoCache},
+		{"/my-component.js", CacheNoCache},
+		{"/index.html", CacheNoCache},
+		{"/dashboard/users", CacheNoCache}, // SPA 路由返回 index.html
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			w := get(router, tt.path, nil)
+			require.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, tt.want, w.Header().Get("Cache-Control"))
+			assert.NotEmpty(t, w.Header().Get("ETag"))
+		})
+	}
+
+	// index.html 直接返回内容而不是重定向
+	w := get(router, "/dashboard/users", nil)
+	assert.Equal(t, "<html></html>", w.Body.String())
+}
+
+func TestStaticHandler_ETagNotModified(t *testing.T) {
+	router := setupDiskStatic(t, map[string]string{
+		"index.html":               "<html></html>",
+		"assets/index-3f2a9c1b.js": "console.log(1)",
+	})
+
+	for _, path := range []string{"/assets/index-3f2a9c1b.js", "/"} {
+		w := get(router, path, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		etag := w.Header().Get("ETag")
+		require.NotEmpty(t, etag)
+
+		w = get(router, path, map[string]string{"If-None-Match": etag})
+		assert.Equal(t, http.StatusNotModified, w.Code, path)
+		assert.Empty(t, w.Body.String())
+
+		w = get(router, path, map[string]string{"If-None-Match": `"stale"`})
+		assert.Equal(t, http.StatusOK, w.Code, path)
+	}
+}
+
+func TestStaticHandler_EmbedETag(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	NewStaticHandler(DefaultConfig()).SetupRoutes(router)
+
+	// 嵌入文件没有修改时间，ETag 基于内容生成且稳定
+	w := get(router, "/", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	etag := w.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+	assert.Equal(t, etag, get(router, "/login", nil).Header().Get("ETag"))
+	assert.Equal(t, http.StatusNotModified, get(router, "/", map[string]string{"If-None-Match": etag}).Code)
+}
+
+func TestStaticHandler_PathTraversal(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	root := t.TempDir()
+	dist := filepath.Join(root, "dist")
+	require.NoError(t, os.MkdirAll(dist, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dist, "index.html"), []byte("<html></html>"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "secret.txt"), []byte("top-secret"), 0o644))
+
+	cfg := DefaultConfig()
+	cfg.Mode = ModeDisk
+	cfg.DiskPath = dist
+	handler := NewStaticHandler(cfg)
+	router := gin.New()
+	handler.SetupRoutes(router)
+
+	for _, p := range []string{"/../secret.txt", "/assets/../../secret.txt", "/..%2fsecret.txt", "/%2e%2e/secret.txt", "/../../etc/passwd"} {
+		w := get(router, p, nil)
+		assert.NotContains(t, w.Body.String(), "top-secret", p)
+		assert.NotContains(t, w.Body.String(), "root:", p)
+	}
+	assert.Equal(t, http.StatusNotFound, get(router, "/../secret.txt", nil).Code)
+
+	assert.False(t, handler.FileExists("../secret.txt"))
+	assert.False(t, handler.FileExists("/assets/../../secret.txt"))
+	assert.True(t, handler.FileExists("/index.html"))
+}
+
+func TestStaticHandler_MetricsNotIntercepted(t *testing.T) {
+	router := setupDiskStatic(t, map[string]string{"index.html": "<html></html>"})
+	router.GET("/metrics", func(c *gin.Context) { c.String(http.StatusOK, "login_total 1") })
+
+	w := get(router, "/metrics", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "login_total 1", w.Body.String())
+
+	// 未注册的指标子路径按 API 返回 404，而不是首页
+	w = get(router, "/metrics/unknown", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}